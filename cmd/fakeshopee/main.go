@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+
+	"ecommerce/internal/adapter/fakeshopee"
+)
+
+// Local Shopee Open Platform for development :
+//   go run ./cmd/fakeshopee -addr :8089
+// then set SHOPEE_API_BASE_URL=http://localhost:8089 and SHOPEE_API_BASE_PREFIX=/api/v2
+// and register the partner below (partner_id / partner_key) in /shopee/partner.
+
+func main() {
+	addr := flag.String("addr", ":8089", "listen address")
+	partnerID := flag.Int64("partner-id", 1000001, "seeded partner_id")
+	partnerKey := flag.String("partner-key", "fake-partner-key", "seeded partner key")
+	shops := flag.Int("shops", 2, "seeded shops")
+	orders := flag.Int("orders", 120, "seeded orders per shop, spread over the last 30 days")
+	seedFile := flag.String("seed", "", "json seed file, replaces the generated seed")
+	tokenTTL := flag.Duration("token-ttl", 4*time.Hour, "access token lifetime")
+	flag.Parse()
+
+	logger, _ := zap.NewDevelopment()
+	defer logger.Sync()
+
+	seed := fakeshopee.DefaultSeed(*partnerID, *partnerKey, *shops, *orders, time.Now())
+	if *seedFile != "" {
+		var err error
+		if seed, err = fakeshopee.LoadSeed(*seedFile); err != nil {
+			log.Fatal("Failed to load seed:", err)
+		}
+	}
+
+	srv := fakeshopee.New(seed, &fakeshopee.Options{AccessTokenTTL: *tokenTTL})
+
+	logger.Info("fakeshopee listening",
+		zap.String("addr", *addr),
+		zap.Int("partners", len(seed.Partners)),
+		zap.Int("shops", len(seed.Shops)),
+		zap.Int("orders", len(seed.Orders)),
+	)
+	if err := http.ListenAndServe(*addr, srv); err != nil {
+		log.Fatal("fakeshopee failed:", err)
+	}
+}
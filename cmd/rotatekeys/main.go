@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+
+	"go.uber.org/zap"
+
+	"ecommerce/internal/application/auth"
+	"ecommerce/internal/application/marketplace"
+	"ecommerce/internal/application/shopee"
+	"ecommerce/internal/application/shopee/partner"
+	"ecommerce/internal/env"
+	"ecommerce/internal/infrastructure"
+	"ecommerce/internal/pkg"
+)
+
+// Re-encrypts stored secrets with the active master key :
+//   1. add the new key to CRYPTO_MASTER_KEYS (keep the old one) and set CRYPTO_ACTIVE_KEY_ID to it
+//   2. restart the server (new writes use the new key, old values still open)
+//   3. go run ./cmd/rotatekeys [-dry-run]
+//   4. once it reports failed=0, drop the old key from CRYPTO_MASTER_KEYS
+// Plaintext values written before encryption was enabled are encrypted by the same pass.
+
+func main() {
+	envSet := flag.String("env", os.Getenv("ENV"), "env file to load (internal/env/.<env>.env), default dev")
+	dryRun := flag.Bool("dry-run", false, "count what would be rotated, write nothing")
+	flag.Parse()
+	if *envSet == "" {
+		*envSet = "dev"
+	}
+
+	logger, _ := zap.NewDevelopment()
+	defer logger.Sync()
+
+	cfg, err := env.LoadEnv(*envSet, logger)
+	if err != nil {
+		log.Fatal("Failed to load configuration:", err)
+	}
+
+	cipher, err := infrastructure.NewSecretCipher(cfg, logger)
+	if err != nil {
+		log.Fatal("Failed to init secret cipher:", err)
+	}
+	if !cipher.Enabled() {
+		log.Fatal("CRYPTO_MASTER_KEYS is empty : nothing to rotate to")
+	}
+
+	mongoDriver := infrastructure.NewMongoClient(logger)
+	mongoClient, err := mongoDriver.Connect(cfg)
+	if err != nil {
+		log.Fatal("Mongo Error:", err)
+	}
+	defer mongoDriver.Disconnect(mongoClient)
+
+	// same collections as Container.InitRepositories
+	authDB := mongoClient.Database(cfg.DB.ConfigDBAuthName)
+	partnerRepo := partner.NewShopeePartnerRepository(authDB.Collection("shopee_partner"), logger, cipher)
+	authRepo := shopee.NewShopeeAuthRepository(authDB.Collection("shopee_shop_auth"), logger, cipher)
+	authReqRepo := shopee.NewShopeeAuthRequestRepository(authDB.Collection("shopee_auth_request"), logger, cipher)
+	appRepo := marketplace.NewMarketplaceAppRepository(authDB.Collection("marketplace_app"), logger, cipher)
+	shopAuthRepo := marketplace.NewMarketplaceShopAuthRepository(authDB.Collection("marketplace_shop_auth"), logger, cipher)
+	mfaRepo := auth.NewMFARepository(authDB.Collection("user_mfa"), logger, cipher)
+
+	// every tenant's secrets
+	ctx := pkg.WithoutTenant(context.Background())
+	passes := []func(context.Context, bool) (*pkg.SecretRotationResult, error){
+		partnerRepo.RotateShopeePartnerSecrets,
+		authRepo.RotateShopeeAuthSecrets,
+		authReqRepo.RotateShopeeAuthRequestSecrets,
+		appRepo.RotateMarketplaceAppSecrets,
+		shopAuthRepo.RotateMarketplaceShopAuthSecrets,
+		mfaRepo.RotateMFASecrets,
+	}
+
+	failed := 0
+	for _, pass := range passes {
+		res, err := pass(ctx, *dryRun)
+		if err != nil {
+			logger.Fatal("rotation aborted", zap.Error(err))
+		}
+		logger.Info("rotation",
+			zap.String("collection", res.Collection),
+			zap.String("active_kid", cipher.ActiveKeyID()),
+			zap.Bool("dry_run", *dryRun),
+			zap.Int("scanned", res.Scanned),
+			zap.Int("rotated", res.Rotated),
+			zap.Int("skipped", res.Skipped),
+			zap.Int("failed", res.Failed),
+		)
+		failed += res.Failed
+	}
+
+	if failed > 0 {
+		// a kid was dropped too early, or a value is corrupted : keep every old key until this is 0
+		logger.Error("some secrets could not be re-encrypted", zap.Int("failed", failed))
+		mongoDriver.Disconnect(mongoClient)
+		os.Exit(1)
+	}
+}
@@ -7,6 +7,7 @@ import (
 
 	"ecommerce/internal/env"
 	"ecommerce/internal/infrastructure"
+	"ecommerce/internal/pkg"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
@@ -69,6 +70,8 @@ func main() {
 
 	container := infrastructure.NewContainer(cfg, mongoClient, logger, valid)
 
+	container.InitSecrets()
+
 	container.InitMiddleware()
 	middlewareConfig := container.Middleware
 
@@ -167,6 +170,11 @@ func main() {
 
 	container.InitHandlers(api)
 
+	// Background workers : every tenant, each worker scopes its work to the tenant it touches
+	workerCtx, stopWorkers := context.WithCancel(pkg.WithoutTenant(context.Background()))
+	defer stopWorkers()
+	container.StartWorkers(workerCtx)
+
 	// app.Use(container.OAuthMiddleware.Handler())
 	// Auth routes
 	// auth := app.Group("/auth")
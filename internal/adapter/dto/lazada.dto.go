@@ -0,0 +1,251 @@
+package dto
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// ----------------- Lazada Open Platform (/rest) -----------------
+// Lazada mixes numbers and strings for ids and amounts depending on the endpoint / country :
+// LazadaID and LazadaAmount accept both.
+
+type LazadaID string
+
+func (id *LazadaID) UnmarshalJSON(b []byte) error {
+	b = bytes.TrimSpace(b)
+	if bytes.Equal(b, []byte("null")) {
+		*id = ""
+		return nil
+	}
+	if len(b) > 0 && b[0] == '"' {
+		var s string
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
+		}
+		*id = LazadaID(s)
+		return nil
+	}
+	*id = LazadaID(b)
+	return nil
+}
+
+func (id LazadaID) String() string { return string(id) }
+
+// LazadaAmount : 1200.5, "1200.50" or "1,200.50"
+type LazadaAmount float64
+
+func (a *LazadaAmount) UnmarshalJSON(b []byte) error {
+	b = bytes.TrimSpace(b)
+	if bytes.Equal(b, []byte("null")) || bytes.Equal(b, []byte(`""`)) {
+		*a = 0
+		return nil
+	}
+	s := string(b)
+	if b[0] == '"' {
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
+		}
+	}
+	f, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(s), ",", ""), 64)
+	if err != nil {
+		return err
+	}
+	*a = LazadaAmount(f)
+	return nil
+}
+
+// envelope shared by every answer : code "0" = success
+type IResLazadaResponse struct {
+	Code      string `json:"code"`
+	Type      string `json:"type"` // ISV / SYSTEM / PLATFORM
+	Message   string `json:"message"`
+	RequestID string `json:"request_id"`
+}
+
+// -- /auth/token/create, /auth/token/refresh : not wrapped in data
+type IResLazadaCountryUserInfo struct {
+	Country   string   `json:"country"`
+	UserID    LazadaID `json:"user_id"`
+	SellerID  LazadaID `json:"seller_id"`
+	ShortCode string   `json:"short_code"`
+}
+
+type IResLazadaToken struct {
+	AccessToken      string                      `json:"access_token"`
+	RefreshToken     string                      `json:"refresh_token"`
+	ExpiresIn        int64                       `json:"expires_in"`         // seconds
+	RefreshExpiresIn int64                       `json:"refresh_expires_in"` // seconds
+	Country          string                      `json:"country"`            // "cb" for cross border
+	Account          string                      `json:"account"`
+	AccountPlatform  string                      `json:"account_platform"`
+	CountryUserInfo  []IResLazadaCountryUserInfo `json:"country_user_info"`
+}
+
+// -- /seller/get
+type IResLazadaSeller struct {
+	SellerID  LazadaID `json:"seller_id"`
+	Name      string   `json:"name"`
+	ShortCode string   `json:"short_code"`
+	Email     string   `json:"email"`
+	Location  string   `json:"location"`
+	Status    string   `json:"status"`
+	Verified  bool     `json:"verified"`
+}
+
+// -- /orders/get, /order/get
+type IOptionLazadaOrderQuery struct {
+	UpdateAfter  string // ISO 8601
+	UpdateBefore string // ISO 8601
+	Status       string // "" : all
+	Offset       int
+	Limit        int // max 100
+}
+
+type IResLazadaAddress struct {
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Phone     string `json:"phone"`
+	Address1  string `json:"address1"`
+	Address2  string `json:"address2"`
+	Address3  string `json:"address3"` // province
+	Address4  string `json:"address4"` // district
+	Address5  string `json:"address5"` // sub district
+	City      string `json:"city"`
+	PostCode  string `json:"post_code"`
+	Country   string `json:"country"`
+}
+
+type IResLazadaOrder struct {
+	OrderID            LazadaID          `json:"order_id"`
+	OrderNumber        LazadaID          `json:"order_number"`
+	CreatedAt          string            `json:"created_at"` // "2006-01-02 15:04:05 -0700"
+	UpdatedAt          string            `json:"updated_at"`
+	Price              LazadaAmount      `json:"price"`
+	ShippingFee        LazadaAmount      `json:"shipping_fee"`
+	PaymentMethod      string            `json:"payment_method"`
+	Statuses           []string          `json:"statuses"`
+	ItemsCount         int               `json:"items_count"`
+	CustomerFirstName  string            `json:"customer_first_name"`
+	CustomerLastName   string            `json:"customer_last_name"`
+	Remarks            string            `json:"remarks"`
+	GiftMessage        string            `json:"gift_message"`
+	AddressShipping    IResLazadaAddress `json:"address_shipping"`
+	AddressBilling     IResLazadaAddress `json:"address_billing"`
+	PromisedShippingAt string            `json:"promised_shipping_times"`
+}
+
+type IResLazadaOrderList struct {
+	Count      int               `json:"count"`
+	CountTotal int               `json:"countTotal"`
+	Orders     []IResLazadaOrder `json:"orders"`
+}
+
+// -- /order/items/get, /orders/items/get
+type IResLazadaOrderItem struct {
+	OrderItemID      LazadaID     `json:"order_item_id"`
+	OrderID          LazadaID     `json:"order_id"`
+	Name             string       `json:"name"`
+	Sku              string       `json:"sku"` // seller sku
+	ShopSku          string       `json:"shop_sku"`
+	SkuID            LazadaID     `json:"sku_id"`
+	ProductID        LazadaID     `json:"product_id"`
+	Variation        string       `json:"variation"`
+	ItemPrice        LazadaAmount `json:"item_price"`
+	PaidPrice        LazadaAmount `json:"paid_price"`
+	Currency         string       `json:"currency"`
+	Status           string       `json:"status"`
+	TrackingCode     string       `json:"tracking_code"`
+	ShipmentProvider string       `json:"shipment_provider"`
+	PackageID        string       `json:"package_id"`
+	ProductMainImage string       `json:"product_main_image"`
+	ShippingType     string       `json:"shipping_type"`
+	PaidAt           string       `json:"paid_at"`
+}
+
+type IResLazadaOrderItems struct {
+	OrderID     LazadaID              `json:"order_id"`
+	OrderNumber LazadaID              `json:"order_number"`
+	OrderItems  []IResLazadaOrderItem `json:"order_items"`
+}
+
+// -- /products/get
+type IOptionLazadaProductQuery struct {
+	Filter      string // all / live / inactive / deleted / ...
+	UpdateAfter string // ISO 8601, "" : no filter
+	Offset      int
+	Limit       int // max 50
+}
+
+type IResLazadaSku struct {
+	SkuID        LazadaID     `json:"SkuId"`
+	SellerSku    string       `json:"SellerSku"`
+	ShopSku      string       `json:"ShopSku"`
+	Quantity     int64        `json:"quantity"`
+	Price        LazadaAmount `json:"price"`
+	SpecialPrice LazadaAmount `json:"special_price"`
+	Status       string       `json:"Status"`
+	Color        string       `json:"color_family"`
+	Size         string       `json:"size"`
+}
+
+type IResLazadaProduct struct {
+	ItemID     LazadaID        `json:"item_id"`
+	Status     string          `json:"status"`
+	Attributes json.RawMessage `json:"attributes"` // "name" is the only field read
+	Skus       []IResLazadaSku `json:"skus"`
+}
+
+// Name : attributes.name
+func (p IResLazadaProduct) Name() string {
+	var attr struct {
+		Name string `json:"name"`
+	}
+	_ = json.Unmarshal(p.Attributes, &attr)
+	return attr.Name
+}
+
+type IResLazadaProductList struct {
+	TotalProducts int                 `json:"total_products"`
+	Products      []IResLazadaProduct `json:"products"`
+}
+
+// -- /product/price_quantity/update : InterfaceBody, sent as the xml "payload" parameter
+type IBLazadaSkuStock struct {
+	ItemID    string
+	SkuID     string
+	SellerSku string
+	Quantity  int64
+}
+
+type IResLazadaFieldError struct {
+	Field     string `json:"field"`
+	Message   string `json:"message"`
+	SellerSku string `json:"seller_sku"`
+}
+
+// -- /order/pack, /order/rts : InterfaceBody
+type IBLazadaPack struct {
+	OrderItemIDs     []string
+	ShippingProvider string
+	DeliveryType     string // dropship (default) / pickup / send_to_warehouse
+}
+
+type IResLazadaPackItem struct {
+	OrderItemID      LazadaID `json:"order_item_id"`
+	TrackingNumber   string   `json:"tracking_number"`
+	ShipmentProvider string   `json:"shipment_provider"`
+	PackageID        string   `json:"package_id"`
+}
+
+type IResLazadaPack struct {
+	OrderItems []IResLazadaPackItem `json:"order_items"`
+}
+
+type IBLazadaReadyToShip struct {
+	OrderItemIDs     []string
+	ShipmentProvider string
+	TrackingNumber   string
+	DeliveryType     string
+}
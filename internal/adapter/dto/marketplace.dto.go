@@ -0,0 +1,187 @@
+package dto
+
+import "time"
+
+// ----------------- channel-neutral model (adapter.IMarketplace) -----------------
+// What the ERP side reads, whatever the channel ; the channel payload stays in the channel dto.
+
+type MarketplaceChannelEnum string
+
+const (
+	CHANNEL_SHOPEE MarketplaceChannelEnum = "SHOPEE"
+	CHANNEL_LAZADA MarketplaceChannelEnum = "LAZADA"
+	CHANNEL_TIKTOK MarketplaceChannelEnum = "TIKTOK"
+)
+
+// unified order status : channel statuses are folded into these, the raw one is kept in ChannelStatus
+type MarketplaceOrderStatusEnum string
+
+const (
+	MP_ORDER_UNPAID        MarketplaceOrderStatusEnum = "UNPAID"
+	MP_ORDER_READY_TO_SHIP MarketplaceOrderStatusEnum = "READY_TO_SHIP" // paid, waiting for the seller
+	MP_ORDER_SHIPPED       MarketplaceOrderStatusEnum = "SHIPPED"       // handed to the carrier
+	MP_ORDER_DELIVERED     MarketplaceOrderStatusEnum = "DELIVERED"
+	MP_ORDER_COMPLETED     MarketplaceOrderStatusEnum = "COMPLETED" // closed, funds released
+	MP_ORDER_IN_CANCEL     MarketplaceOrderStatusEnum = "IN_CANCEL" // cancellation requested
+	MP_ORDER_CANCELLED     MarketplaceOrderStatusEnum = "CANCELLED"
+	MP_ORDER_RETURNED      MarketplaceOrderStatusEnum = "RETURNED" // returned / failed delivery
+	MP_ORDER_UNKNOWN       MarketplaceOrderStatusEnum = "UNKNOWN"
+)
+
+type MarketplaceAddress struct {
+	Name        string `json:"name"`
+	Phone       string `json:"phone"`
+	FullAddress string `json:"full_address"`
+	District    string `json:"district"`
+	City        string `json:"city"`
+	State       string `json:"state"`
+	PostCode    string `json:"post_code"`
+	Country     string `json:"country"`
+}
+
+type MarketplaceOrderItem struct {
+	LineID      string  `json:"line_id"`    // Shopee order_item_id, Lazada order_item_id
+	ItemID      string  `json:"item_id"`    // Shopee item_id, Lazada product (item) id
+	VariantID   string  `json:"variant_id"` // Shopee model_id, Lazada sku_id
+	SKU         string  `json:"sku"`        // seller sku : model sku, else item sku
+	Name        string  `json:"name"`
+	VariantName string  `json:"variant_name"`
+	Quantity    int     `json:"quantity"`
+	UnitPrice   float64 `json:"unit_price"` // list price
+	PaidPrice   float64 `json:"paid_price"` // per unit, after discounts
+	ImageURL    string  `json:"image_url"`
+	PackageID   string  `json:"package_id"`
+}
+
+type MarketplacePackage struct {
+	PackageID      string   `json:"package_id"`
+	Carrier        string   `json:"carrier"`
+	TrackingNumber string   `json:"tracking_number"`
+	Status         string   `json:"status"` // channel logistics status
+	LineIDs        []string `json:"line_ids"`
+}
+
+type MarketplaceOrder struct {
+	Channel       MarketplaceChannelEnum     `json:"channel"`
+	ShopID        string                     `json:"shop_id"`
+	OrderID       string                     `json:"order_id"`     // id used to call the channel back
+	OrderNumber   string                     `json:"order_number"` // shown to buyer / seller
+	Status        MarketplaceOrderStatusEnum `json:"status"`
+	ChannelStatus string                     `json:"channel_status"`
+
+	Currency      string  `json:"currency"`
+	TotalAmount   float64 `json:"total_amount"`
+	ShippingFee   float64 `json:"shipping_fee"`
+	PaymentMethod string  `json:"payment_method"`
+	COD           bool    `json:"cod"`
+	// what the buyer paid for shipping, and the seller funded voucher on top of the item paid prices :
+	// 0 when the channel does not tell (Shopee : known once the escrow is synced)
+	BuyerShippingFee float64 `json:"buyer_shipping_fee"`
+	SellerVoucher    float64 `json:"seller_voucher"`
+	// stocked and shipped by the channel (Shopee FBS) : nothing to reserve, pick or ship
+	FulfilledByChannel bool `json:"fulfilled_by_channel"`
+
+	BuyerName       string             `json:"buyer_name"`
+	BuyerNote       string             `json:"buyer_note"`
+	ShippingAddress MarketplaceAddress `json:"shipping_address"`
+
+	Items    []MarketplaceOrderItem `json:"items"`
+	Packages []MarketplacePackage   `json:"packages"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	PaidAt    time.Time `json:"paid_at"`
+	ShipBy    time.Time `json:"ship_by"`
+}
+
+// [UpdatedFrom, UpdatedTo) ; Cursor is opaque, "" = first page
+type MarketplaceOrderQuery struct {
+	UpdatedFrom time.Time
+	UpdatedTo   time.Time
+	Status      MarketplaceOrderStatusEnum // "" : all
+	Cursor      string
+	PageSize    int
+}
+
+type MarketplaceOrderPage struct {
+	Orders     []MarketplaceOrder `json:"orders"`
+	More       bool               `json:"more"`
+	NextCursor string             `json:"next_cursor"`
+}
+
+type MarketplaceShop struct {
+	Channel MarketplaceChannelEnum `json:"channel"`
+	ShopID  string                 `json:"shop_id"`
+	Name    string                 `json:"name"`
+	Region  string                 `json:"region"`
+	Status  string                 `json:"status"`
+}
+
+type MarketplaceToken struct {
+	ShopID           string    `json:"shop_id"`
+	Account          string    `json:"account"` // seller login / email when the channel gives one
+	Region           string    `json:"region"`
+	AccessToken      string    `json:"access_token"`
+	RefreshToken     string    `json:"refresh_token"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+}
+
+type MarketplaceVariant struct {
+	VariantID string  `json:"variant_id"`
+	SKU       string  `json:"sku"`
+	Name      string  `json:"name"`
+	Price     float64 `json:"price"`
+	Stock     int64   `json:"stock"`
+}
+
+type MarketplaceItem struct {
+	ItemID   string               `json:"item_id"`
+	Name     string               `json:"name"`
+	SKU      string               `json:"sku"`
+	Status   string               `json:"status"`
+	Variants []MarketplaceVariant `json:"variants"`
+}
+
+type MarketplaceItemQuery struct {
+	UpdatedFrom time.Time // zero : no filter
+	Cursor      string
+	PageSize    int
+}
+
+type MarketplaceItemPage struct {
+	Items      []MarketplaceItem `json:"items"`
+	More       bool              `json:"more"`
+	NextCursor string            `json:"next_cursor"`
+}
+
+// one sellable unit : Shopee needs ItemID (+ VariantID), Lazada needs ItemID + SKU (seller sku)
+type MarketplaceStockUpdate struct {
+	ItemID    string `json:"item_id" validate:"required"`
+	VariantID string `json:"variant_id"`
+	SKU       string `json:"sku"`
+	Quantity  int64  `json:"quantity" validate:"gte=0"`
+}
+
+type MarketplaceStockResult struct {
+	ItemID    string `json:"item_id"`
+	VariantID string `json:"variant_id"`
+	SKU       string `json:"sku"`
+	OK        bool   `json:"ok"`
+	Error     string `json:"error,omitempty"`
+}
+
+// TrackingNumber set : seller ships with its own carrier, otherwise the channel arranges pickup
+type MarketplaceShipRequest struct {
+	OrderID        string   `json:"order_id"`
+	PackageID      string   `json:"package_id"`
+	LineIDs        []string `json:"line_ids"` // Lazada : "" = every line of the order
+	Carrier        string   `json:"carrier"`
+	TrackingNumber string   `json:"tracking_number"`
+}
+
+type MarketplaceShipResult struct {
+	OrderID        string `json:"order_id"`
+	PackageID      string `json:"package_id"`
+	TrackingNumber string `json:"tracking_number"`
+}
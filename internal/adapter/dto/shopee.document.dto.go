@@ -0,0 +1,59 @@
+package dto
+
+// ----------------- /api/v2/logistics/*_shipping_document -----------------
+
+type IEnumShippingDocumentType string
+
+const (
+	NORMAL_AIR_WAYBILL      IEnumShippingDocumentType = "NORMAL_AIR_WAYBILL"
+	THERMAL_AIR_WAYBILL     IEnumShippingDocumentType = "THERMAL_AIR_WAYBILL"
+	NORMAL_JOB_AIR_WAYBILL  IEnumShippingDocumentType = "NORMAL_JOB_AIR_WAYBILL"
+	THERMAL_JOB_AIR_WAYBILL IEnumShippingDocumentType = "THERMAL_JOB_AIR_WAYBILL"
+)
+
+// READY/FAILED/PROCESSING
+type IEnumShippingDocumentStatus string
+
+const (
+	DOCUMENT_READY      IEnumShippingDocumentStatus = "READY"
+	DOCUMENT_FAILED     IEnumShippingDocumentStatus = "FAILED"
+	DOCUMENT_PROCESSING IEnumShippingDocumentStatus = "PROCESSING"
+)
+
+// -- create_shipping_document / get_shipping_document_result / download_shipping_document : InterfaceBody
+type IBShippingDocumentOrder struct {
+	OrderSN              string                    `json:"order_sn"`
+	PackageNumber        string                    `json:"package_number,omitempty"`
+	TrackingNumber       string                    `json:"tracking_number,omitempty"`
+	ShippingDocumentType IEnumShippingDocumentType `json:"shipping_document_type,omitempty"`
+}
+
+type IBCreateShippingDocument struct {
+	OrderList []IBShippingDocumentOrder `json:"order_list"`
+}
+
+type IBGetShippingDocumentResult struct {
+	OrderList []IBShippingDocumentOrder `json:"order_list"`
+}
+
+type IBDownloadShippingDocument struct {
+	ShippingDocumentType IEnumShippingDocumentType `json:"shipping_document_type"`
+	OrderList            []IBShippingDocumentOrder `json:"order_list"`
+}
+
+type IResShippingDocumentResult struct {
+	OrderSN       string                      `json:"order_sn"`
+	PackageNumber string                      `json:"package_number"`
+	Status        IEnumShippingDocumentStatus `json:"status"` // get_shipping_document_result only
+	FailError     string                      `json:"fail_error"`
+	FailMessage   string                      `json:"fail_message"`
+}
+
+type IResShippingDocumentWrapper struct {
+	ResultList []IResShippingDocumentResult `json:"result_list"`
+}
+
+type IResShippingDocument struct {
+	IResShopeeResponse
+	Response IResShippingDocumentWrapper `json:"response"`
+}
@@ -0,0 +1,147 @@
+package dto
+
+// ----------------- /api/v2/logistics/* -----------------
+
+// -- get_shipping_parameter
+type IResShippingInfoNeeded struct {
+	Dropoff       []string `json:"dropoff"`
+	Pickup        []string `json:"pickup"`
+	NonIntegrated []string `json:"non_integrated"`
+}
+
+type IResShippingTimeSlot struct {
+	Date         int64  `json:"date"`
+	TimeText     string `json:"time_text"`
+	PickupTimeID string `json:"pickup_time_id"`
+}
+
+type IResShippingPickupAddress struct {
+	AddressID    int64                  `json:"address_id"`
+	Region       string                 `json:"region"`
+	State        string                 `json:"state"`
+	City         string                 `json:"city"`
+	District     string                 `json:"district"`
+	Town         string                 `json:"town"`
+	Address      string                 `json:"address"`
+	Zipcode      string                 `json:"zipcode"`
+	AddressFlag  []string               `json:"address_flag"`
+	TimeSlotList []IResShippingTimeSlot `json:"time_slot_list"`
+}
+
+type IResShippingDropoffBranch struct {
+	BranchID int64  `json:"branch_id"`
+	Region   string `json:"region"`
+	State    string `json:"state"`
+	City     string `json:"city"`
+	Address  string `json:"address"`
+	Zipcode  string `json:"zipcode"`
+	District string `json:"district"`
+	Town     string `json:"town"`
+}
+
+type IResShippingParameterWrapper struct {
+	InfoNeeded IResShippingInfoNeeded `json:"info_needed"`
+	Dropoff    struct {
+		BranchList []IResShippingDropoffBranch `json:"branch_list"`
+	} `json:"dropoff"`
+	Pickup struct {
+		AddressList []IResShippingPickupAddress `json:"address_list"`
+	} `json:"pickup"`
+}
+
+type IResShippingParameter struct {
+	IResShopeeResponse
+	Response IResShippingParameterWrapper `json:"response"`
+}
+
+// -- ship_order / batch_ship_order : InterfaceBody
+// exactly one of Pickup / Dropoff / NonIntegrated
+type IBShipPickup struct {
+	AddressID      int64  `json:"address_id"`
+	PickupTimeID   string `json:"pickup_time_id,omitempty"`
+	TrackingNumber string `json:"tracking_number,omitempty"`
+}
+
+type IBShipDropoff struct {
+	BranchID       int64  `json:"branch_id,omitempty"`
+	SenderRealName string `json:"sender_real_name,omitempty"`
+	TrackingNumber string `json:"tracking_number,omitempty"`
+	Slug           string `json:"slug,omitempty"`
+}
+
+type IBShipNonIntegrated struct {
+	TrackingNumber string `json:"tracking_number"`
+}
+
+type IBShipOrder struct {
+	OrderSN       string               `json:"order_sn"`
+	PackageNumber string               `json:"package_number,omitempty"`
+	Pickup        *IBShipPickup        `json:"pickup,omitempty"`
+	Dropoff       *IBShipDropoff       `json:"dropoff,omitempty"`
+	NonIntegrated *IBShipNonIntegrated `json:"non_integrated,omitempty"`
+}
+
+type IBBatchShipOrderItem struct {
+	OrderSN       string `json:"order_sn"`
+	PackageNumber string `json:"package_number,omitempty"`
+}
+
+type IBBatchShipOrder struct {
+	OrderList     []IBBatchShipOrderItem `json:"order_list"`
+	Pickup        *IBShipPickup          `json:"pickup,omitempty"`
+	Dropoff       *IBShipDropoff         `json:"dropoff,omitempty"`
+	NonIntegrated *IBShipNonIntegrated   `json:"non_integrated,omitempty"`
+}
+
+type IResShipOrder struct {
+	IResShopeeResponse
+}
+
+type IResBatchShipOrderResult struct {
+	OrderSN       string `json:"order_sn"`
+	PackageNumber string `json:"package_number"`
+	FailError     string `json:"fail_error"`
+	FailMessage   string `json:"fail_message"`
+}
+
+type IResBatchShipOrderWrapper struct {
+	ResultList []IResBatchShipOrderResult `json:"result_list"`
+}
+
+type IResBatchShipOrder struct {
+	IResShopeeResponse
+	Response IResBatchShipOrderWrapper `json:"response"`
+}
+
+// -- get_tracking_number
+type IResTrackingNumberWrapper struct {
+	TrackingNumber          string `json:"tracking_number"`
+	PlpNumber               string `json:"plp_number"`
+	FirstMileTrackingNumber string `json:"first_mile_tracking_number"`
+	LastMileTrackingNumber  string `json:"last_mile_tracking_number"`
+	Hint                    string `json:"hint"`
+}
+
+type IResTrackingNumber struct {
+	IResShopeeResponse
+	Response IResTrackingNumberWrapper `json:"response"`
+}
+
+// -- get_tracking_info
+type IResTrackingInfoEvent struct {
+	UpdateTime      int64  `json:"update_time"`
+	Description     string `json:"description"`
+	LogisticsStatus string `json:"logistics_status"`
+}
+
+type IResTrackingInfoWrapper struct {
+	OrderSN         string                  `json:"order_sn"`
+	PackageNumber   string                  `json:"package_number"`
+	LogisticsStatus string                  `json:"logistics_status"`
+	TrackingInfo    []IResTrackingInfoEvent `json:"tracking_info"`
+}
+
+type IResTrackingInfo struct {
+	IResShopeeResponse
+	Response IResTrackingInfoWrapper `json:"response"`
+}
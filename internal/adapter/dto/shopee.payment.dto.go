@@ -0,0 +1,104 @@
+package dto
+
+// ----------------- /api/v2/payment/* -----------------
+
+// -- get_escrow_detail
+type IResEscrowOrderIncome struct {
+	EscrowAmount             float64 `json:"escrow_amount"`
+	BuyerTotalAmount         float64 `json:"buyer_total_amount"`
+	OriginalPrice            float64 `json:"original_price"`
+	SellerDiscount           float64 `json:"seller_discount"`
+	ShopeeDiscount           float64 `json:"shopee_discount"`
+	VoucherFromSeller        float64 `json:"voucher_from_seller"`
+	VoucherFromShopee        float64 `json:"voucher_from_shopee"`
+	Coins                    float64 `json:"coins"`
+	BuyerPaidShippingFee     float64 `json:"buyer_paid_shipping_fee"`
+	FinalShippingFee         float64 `json:"final_shipping_fee"`
+	ActualShippingFee        float64 `json:"actual_shipping_fee"`
+	EstimatedShippingFee     float64 `json:"estimated_shipping_fee"`
+	ShopeeShippingRebate     float64 `json:"shopee_shipping_rebate"`
+	ReverseShippingFee       float64 `json:"reverse_shipping_fee"`
+	CommissionFee            float64 `json:"commission_fee"`
+	ServiceFee               float64 `json:"service_fee"`
+	SellerTransactionFee     float64 `json:"seller_transaction_fee"`
+	CreditCardTransactionFee float64 `json:"credit_card_transaction_fee"`
+	EscrowTax                float64 `json:"escrow_tax"`
+	SellerReturnRefund       float64 `json:"seller_return_refund"`
+	SellerLostCompensation   float64 `json:"seller_lost_compensation"`
+	CostOfGoodsSold          float64 `json:"cost_of_goods_sold"`
+	OriginalCostOfGoodsSold  float64 `json:"original_cost_of_goods_sold"`
+	DrcAdjustableRefund      float64 `json:"drc_adjustable_refund"`
+}
+
+type IResEscrowDetailWrapper struct {
+	OrderSN           string                `json:"order_sn"`
+	BuyerUserName     string                `json:"buyer_user_name"`
+	ReturnOrderSNList []string              `json:"return_order_sn_list"`
+	OrderIncome       IResEscrowOrderIncome `json:"order_income"`
+}
+
+type IResEscrowDetail struct {
+	IResShopeeResponse
+	Response IResEscrowDetailWrapper `json:"response"`
+}
+
+// -- get_escrow_list
+type IResEscrowListItem struct {
+	OrderSN           string  `json:"order_sn"`
+	PayoutAmount      float64 `json:"payout_amount"`
+	EscrowReleaseTime int64   `json:"escrow_release_time"`
+}
+
+type IResEscrowListWrapper struct {
+	EscrowList []IResEscrowListItem `json:"escrow_list"`
+	More       bool                 `json:"more"`
+}
+
+type IResEscrowList struct {
+	IResShopeeResponse
+	Response IResEscrowListWrapper `json:"response"`
+}
+
+// -- get_payout_detail
+type IResPayoutInfo struct {
+	FromCurrency   string  `json:"from_currency"`
+	PayoutCurrency string  `json:"payout_currency"`
+	FromAmount     float64 `json:"from_amount"`
+	PayoutAmount   float64 `json:"payout_amount"`
+	ExchangeRate   string  `json:"exchange_rate"`
+	PayoutTime     int64   `json:"payout_time"`
+	PayService     string  `json:"pay_service"`
+	PayeeID        string  `json:"payee_id"`
+}
+
+type IResPayoutOfflineAdjustment struct {
+	AdjustmentAmount float64 `json:"adjustment_amount"`
+	Module           string  `json:"module"`
+	Remark           string  `json:"remark"`
+	Scenario         string  `json:"scenario"`
+	AdjustmentLevel  string  `json:"adjustment_level"`
+	OrderSN          string  `json:"order_sn"`
+}
+
+type IResPayout struct {
+	PayoutInfo            IResPayoutInfo                `json:"payout_info"`
+	EscrowList            []IResEscrowListItem          `json:"escrow_list"`
+	OfflineAdjustmentList []IResPayoutOfflineAdjustment `json:"offline_adjustment_list"`
+}
+
+type IResPayoutDetailWrapper struct {
+	PayoutList []IResPayout `json:"payout_list"`
+	More       bool         `json:"more"`
+}
+
+type IResPayoutDetail struct {
+	IResShopeeResponse
+	Response IResPayoutDetailWrapper `json:"response"`
+}
+
+type IOptionShopeePageQuery struct {
+	TimeFrom int64 // Unix
+	TimeTo   int64 // Unix
+	PageSize int32 // max 100
+	PageNo   int32 // from 1
+}
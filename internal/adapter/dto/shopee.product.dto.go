@@ -0,0 +1,302 @@
+package dto
+
+import "encoding/json"
+
+// ----------------- /api/v2/product/* -----------------
+
+// NORMAL/BANNED/UNLIST/REVIEWING/SELLER_DELETE/SHOPEE_DELETE
+type IEnumShopeeItemStatus string
+
+const (
+	ITEM_NORMAL        IEnumShopeeItemStatus = "NORMAL"
+	ITEM_BANNED        IEnumShopeeItemStatus = "BANNED"
+	ITEM_UNLIST        IEnumShopeeItemStatus = "UNLIST"
+	ITEM_REVIEWING     IEnumShopeeItemStatus = "REVIEWING"
+	ITEM_SELLER_DELETE IEnumShopeeItemStatus = "SELLER_DELETE"
+	ITEM_SHOPEE_DELETE IEnumShopeeItemStatus = "SHOPEE_DELETE"
+)
+
+type IOptionShopeeItemListQuery struct {
+	Offset         int32
+	PageSize       int32 // max 100
+	ItemStatus     []IEnumShopeeItemStatus
+	UpdateTimeFrom int64 // Unix
+	UpdateTimeTo   int64 // Unix
+}
+
+// -- get_item_list
+type IResItemListItem struct {
+	ItemID     int64  `json:"item_id"`
+	ItemStatus string `json:"item_status"`
+	UpdateTime int64  `json:"update_time"`
+}
+
+type IResGetItemListWrapper struct {
+	Item        []IResItemListItem `json:"item"`
+	TotalCount  int64              `json:"total_count"`
+	HasNextPage bool               `json:"has_next_page"`
+	NextOffset  int32              `json:"next_offset"`
+}
+
+type IResGetItemList struct {
+	IResShopeeResponse
+	Response IResGetItemListWrapper `json:"response"`
+}
+
+// -- get_item_base_info
+type IResItemPriceInfo struct {
+	Currency                     string  `json:"currency"`
+	OriginalPrice                float64 `json:"original_price"`
+	CurrentPrice                 float64 `json:"current_price"`
+	InflatedPriceOfOriginalPrice float64 `json:"inflated_price_of_original_price"`
+	InflatedPriceOfCurrentPrice  float64 `json:"inflated_price_of_current_price"`
+}
+
+type IResItemSellerStock struct {
+	LocationID string `json:"location_id,omitempty"`
+	Stock      int64  `json:"stock"`
+}
+
+type IResItemStockSummary struct {
+	TotalReservedStock  int64 `json:"total_reserved_stock"`
+	TotalAvailableStock int64 `json:"total_available_stock"`
+}
+
+type IResItemStockInfoV2 struct {
+	SummaryInfo IResItemStockSummary  `json:"summary_info"`
+	SellerStock []IResItemSellerStock `json:"seller_stock"`
+}
+
+type IResItemImage struct {
+	ImageURLList []string `json:"image_url_list"`
+	ImageIDList  []string `json:"image_id_list"`
+}
+
+type IItemDimension struct {
+	PackageLength int64 `json:"package_length"`
+	PackageWidth  int64 `json:"package_width"`
+	PackageHeight int64 `json:"package_height"`
+}
+
+type IItemLogisticInfo struct {
+	LogisticID   int64   `json:"logistic_id"`
+	LogisticName string  `json:"logistic_name,omitempty"`
+	Enabled      bool    `json:"enabled"`
+	ShippingFee  float64 `json:"shipping_fee,omitempty"`
+	SizeID       int64   `json:"size_id,omitempty"`
+	IsFree       bool    `json:"is_free"`
+}
+
+type IItemPreOrder struct {
+	IsPreOrder bool  `json:"is_pre_order"`
+	DaysToShip int64 `json:"days_to_ship"`
+}
+
+type IItemBrand struct {
+	BrandID           int64  `json:"brand_id"`
+	OriginalBrandName string `json:"original_brand_name"`
+}
+
+type IItemAttributeValue struct {
+	ValueID           int64  `json:"value_id"`
+	OriginalValueName string `json:"original_value_name,omitempty"`
+	ValueUnit         string `json:"value_unit,omitempty"`
+}
+
+type IItemAttribute struct {
+	AttributeID           int64                 `json:"attribute_id"`
+	OriginalAttributeName string                `json:"original_attribute_name,omitempty"`
+	AttributeValueList    []IItemAttributeValue `json:"attribute_value_list"`
+}
+
+type IResItemBaseInfo struct {
+	ItemID        int64               `json:"item_id"`
+	CategoryID    int64               `json:"category_id"`
+	ItemName      string              `json:"item_name"`
+	Description   string              `json:"description"`
+	ItemSKU       string              `json:"item_sku"`
+	CreateTime    int64               `json:"create_time"`
+	UpdateTime    int64               `json:"update_time"`
+	AttributeList []IItemAttribute    `json:"attribute_list"`
+	PriceInfo     []IResItemPriceInfo `json:"price_info"`
+	StockInfoV2   IResItemStockInfoV2 `json:"stock_info_v2"`
+	Image         IResItemImage       `json:"image"`
+	Weight        json.Number         `json:"weight"` // string in response
+	Dimension     IItemDimension      `json:"dimension"`
+	LogisticInfo  []IItemLogisticInfo `json:"logistic_info"`
+	PreOrder      IItemPreOrder       `json:"pre_order"`
+	Condition     string              `json:"condition"`
+	ItemStatus    string              `json:"item_status"`
+	HasModel      bool                `json:"has_model"`
+	PromotionID   int64               `json:"promotion_id"`
+	Brand         IItemBrand          `json:"brand"`
+	ItemDangerous int                 `json:"item_dangerous"`
+}
+
+type IResGetItemBaseInfoWrapper struct {
+	ItemList []IResItemBaseInfo `json:"item_list"`
+}
+
+type IResGetItemBaseInfo struct {
+	IResShopeeResponse
+	Response IResGetItemBaseInfoWrapper `json:"response"`
+}
+
+// -- get_model_list
+type IResItemTierOption struct {
+	Option string `json:"option"`
+	Image  struct {
+		ImageID  string `json:"image_id"`
+		ImageURL string `json:"image_url"`
+	} `json:"image"`
+}
+
+type IResItemTierVariation struct {
+	Name       string               `json:"name"`
+	OptionList []IResItemTierOption `json:"option_list"`
+}
+
+type IResItemModel struct {
+	ModelID     int64               `json:"model_id"`
+	TierIndex   []int               `json:"tier_index"`
+	ModelSKU    string              `json:"model_sku"`
+	ModelStatus string              `json:"model_status"`
+	PriceInfo   []IResItemPriceInfo `json:"price_info"`
+	StockInfoV2 IResItemStockInfoV2 `json:"stock_info_v2"`
+}
+
+type IResGetModelListWrapper struct {
+	TierVariation []IResItemTierVariation `json:"tier_variation"`
+	Model         []IResItemModel         `json:"model"`
+}
+
+type IResGetModelList struct {
+	IResShopeeResponse
+	Response IResGetModelListWrapper `json:"response"`
+}
+
+// -- add_item / update_item : InterfaceBody
+type IBAddItem struct {
+	OriginalPrice float64               `json:"original_price"`
+	Description   string                `json:"description"`
+	Weight        float64               `json:"weight"`
+	ItemName      string                `json:"item_name"`
+	ItemStatus    IEnumShopeeItemStatus `json:"item_status,omitempty"`
+	Dimension     *IItemDimension       `json:"dimension,omitempty"`
+	LogisticInfo  []IItemLogisticInfo   `json:"logistic_info"`
+	AttributeList []IItemAttribute      `json:"attribute_list,omitempty"`
+	CategoryID    int64                 `json:"category_id"`
+	Image         IBItemImage           `json:"image"`
+	PreOrder      *IItemPreOrder        `json:"pre_order,omitempty"`
+	ItemSKU       string                `json:"item_sku,omitempty"`
+	Condition     string                `json:"condition,omitempty"`
+	Brand         *IItemBrand           `json:"brand,omitempty"`
+	SellerStock   []IResItemSellerStock `json:"seller_stock"`
+}
+
+type IBItemImage struct {
+	ImageIDList []string `json:"image_id_list"`
+}
+
+// only non-nil fields are sent
+type IBUpdateItem struct {
+	ItemID        int64                  `json:"item_id"`
+	ItemName      *string                `json:"item_name,omitempty"`
+	Description   *string                `json:"description,omitempty"`
+	ItemSKU       *string                `json:"item_sku,omitempty"`
+	Weight        *float64               `json:"weight,omitempty"`
+	CategoryID    *int64                 `json:"category_id,omitempty"`
+	ItemStatus    *IEnumShopeeItemStatus `json:"item_status,omitempty"`
+	Dimension     *IItemDimension        `json:"dimension,omitempty"`
+	LogisticInfo  []IItemLogisticInfo    `json:"logistic_info,omitempty"`
+	AttributeList []IItemAttribute       `json:"attribute_list,omitempty"`
+	Image         *IBItemImage           `json:"image,omitempty"`
+	PreOrder      *IItemPreOrder         `json:"pre_order,omitempty"`
+	Condition     *string                `json:"condition,omitempty"`
+	Brand         *IItemBrand            `json:"brand,omitempty"`
+}
+
+type IResAddItemWrapper struct {
+	ItemID     int64  `json:"item_id"`
+	ItemName   string `json:"item_name"`
+	ItemStatus string `json:"item_status"`
+}
+
+type IResAddItem struct {
+	IResShopeeResponse
+	Response IResAddItemWrapper `json:"response"`
+}
+
+// -- update_price
+type IBItemPrice struct {
+	ModelID       int64   `json:"model_id,omitempty"` // 0 : item without model
+	OriginalPrice float64 `json:"original_price"`
+}
+
+type IBUpdatePrice struct {
+	ItemID    int64         `json:"item_id"`
+	PriceList []IBItemPrice `json:"price_list"`
+}
+
+type IResItemFailure struct {
+	ItemID       int64  `json:"item_id,omitempty"`
+	ModelID      int64  `json:"model_id,omitempty"`
+	FailedReason string `json:"failed_reason"`
+}
+
+type IResUpdatePriceWrapper struct {
+	SuccessList []IBItemPrice     `json:"success_list"`
+	FailureList []IResItemFailure `json:"failure_list"`
+}
+
+type IResUpdatePrice struct {
+	IResShopeeResponse
+	Response IResUpdatePriceWrapper `json:"response"`
+}
+
+// -- update_stock
+type IBItemStock struct {
+	ModelID     int64                 `json:"model_id,omitempty"` // 0 : item without model
+	SellerStock []IResItemSellerStock `json:"seller_stock"`
+}
+
+type IBUpdateStock struct {
+	ItemID    int64         `json:"item_id"`
+	StockList []IBItemStock `json:"stock_list"`
+}
+
+type IResItemStockSuccess struct {
+	ModelID    int64  `json:"model_id"`
+	LocationID string `json:"location_id"`
+	Stock      int64  `json:"stock"`
+}
+
+type IResUpdateStockWrapper struct {
+	SuccessList []IResItemStockSuccess `json:"success_list"`
+	FailureList []IResItemFailure      `json:"failure_list"`
+}
+
+type IResUpdateStock struct {
+	IResShopeeResponse
+	Response IResUpdateStockWrapper `json:"response"`
+}
+
+// -- unlist_item
+type IBUnlistItemEntry struct {
+	ItemID int64 `json:"item_id"`
+	Unlist bool  `json:"unlist"`
+}
+
+type IBUnlistItem struct {
+	ItemList []IBUnlistItemEntry `json:"item_list"`
+}
+
+type IResUnlistItemWrapper struct {
+	SuccessList []IBUnlistItemEntry `json:"success_list"`
+	FailureList []IResItemFailure   `json:"failure_list"`
+}
+
+type IResUnlistItem struct {
+	IResShopeeResponse
+	Response IResUnlistItemWrapper `json:"response"`
+}
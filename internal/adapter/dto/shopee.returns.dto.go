@@ -0,0 +1,170 @@
+package dto
+
+// ----------------- /api/v2/returns/* -----------------
+
+// REQUESTED/ACCEPTED/CANCELLED/JUDGING/CLOSED/PROCESSING/SELLER_DISPUTE
+type IEnumShopeeReturnStatus string
+
+const (
+	RETURN_REQUESTED      IEnumShopeeReturnStatus = "REQUESTED"
+	RETURN_ACCEPTED       IEnumShopeeReturnStatus = "ACCEPTED"
+	RETURN_CANCELLED      IEnumShopeeReturnStatus = "CANCELLED"
+	RETURN_JUDGING        IEnumShopeeReturnStatus = "JUDGING"
+	RETURN_CLOSED         IEnumShopeeReturnStatus = "CLOSED"
+	RETURN_PROCESSING     IEnumShopeeReturnStatus = "PROCESSING"
+	RETURN_SELLER_DISPUTE IEnumShopeeReturnStatus = "SELLER_DISPUTE"
+)
+
+// proposed_solution of /returns/offer
+type IEnumShopeeReturnSolution string
+
+const (
+	RETURN_SOLUTION_RETURN_REFUND IEnumShopeeReturnSolution = "RETURN_REFUND"
+	RETURN_SOLUTION_REFUND        IEnumShopeeReturnSolution = "REFUND"
+)
+
+type IOptionShopeeReturnListQuery struct {
+	PageNo         int32 // from 0
+	PageSize       int32 // max 100
+	CreateTimeFrom int64 // Unix
+	CreateTimeTo   int64 // Unix
+	UpdateTimeFrom int64 // Unix
+	UpdateTimeTo   int64 // Unix
+	Status         IEnumShopeeReturnStatus
+}
+
+type IResReturnUser struct {
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	Portrait string `json:"portrait"`
+}
+
+type IResReturnItem struct {
+	ItemID       int64    `json:"item_id"`
+	ModelID      int64    `json:"model_id"`
+	Name         string   `json:"name"`
+	Images       []string `json:"images"`
+	Amount       int64    `json:"amount"`
+	ItemPrice    float64  `json:"item_price"`
+	IsAddOnDeal  bool     `json:"is_add_on_deal"`
+	IsMainItem   bool     `json:"is_main_item"`
+	ItemSKU      string   `json:"item_sku"`
+	VariationSKU string   `json:"variation_sku"`
+	RefundAmount float64  `json:"refund_amount"`
+}
+
+type IResReturnNegotiation struct {
+	NegotiationStatus       string  `json:"negotiation_status"`
+	LatestSolution          string  `json:"latest_solution"`
+	LatestOfferRefundAmount float64 `json:"latest_offer_refund_amount"`
+	LatestOfferCreator      string  `json:"latest_offer_creator"`
+	CounterOfferChance      int64   `json:"counter_offer_chance"`
+	OfferDueDate            int64   `json:"offer_due_date"`
+	MaxRefundableAmount     float64 `json:"max_refundable_amount"`
+}
+
+type IResReturnSellerProof struct {
+	SellerProofStatus      string `json:"seller_proof_status"`
+	SellerEvidenceDeadline int64  `json:"seller_evidence_deadline"`
+}
+
+// -- get_return_detail : also one entry of get_return_list
+type IResReturnDetail struct {
+	ReturnSN             string                  `json:"return_sn"`
+	OrderSN              string                  `json:"order_sn"`
+	Status               IEnumShopeeReturnStatus `json:"status"`
+	Reason               string                  `json:"reason"`
+	TextReason           string                  `json:"text_reason"`
+	RefundAmount         float64                 `json:"refund_amount"`
+	AmountBeforeDiscount float64                 `json:"amount_before_discount"`
+	Currency             string                  `json:"currency"`
+	CreateTime           int64                   `json:"create_time"`
+	UpdateTime           int64                   `json:"update_time"`
+	DueDate              int64                   `json:"due_date"`
+	TrackingNumber       string                  `json:"tracking_number"`
+	NeedsLogistics       bool                    `json:"needs_logistics"`
+	ReturnShipDueDate    int64                   `json:"return_ship_due_date"`
+	ReturnSellerDueDate  int64                   `json:"return_seller_due_date"`
+	DisputeReason        []int64                 `json:"dispute_reason"`
+	DisputeTextReason    []string                `json:"dispute_text_reason"`
+	Image                []string                `json:"image"`
+	User                 IResReturnUser          `json:"user"`
+	Item                 []IResReturnItem        `json:"item"`
+	Negotiation          IResReturnNegotiation   `json:"negotiation"`
+	SellerProof          IResReturnSellerProof   `json:"seller_proof"`
+}
+
+type IResReturnDetailResponse struct {
+	IResShopeeResponse
+	Response IResReturnDetail `json:"response"`
+}
+
+// -- get_return_list
+type IResReturnListWrapper struct {
+	More   bool               `json:"more"`
+	Return []IResReturnDetail `json:"return"`
+}
+
+type IResReturnList struct {
+	IResShopeeResponse
+	Response IResReturnListWrapper `json:"response"`
+}
+
+// -- confirm
+type IBConfirmReturn struct {
+	ReturnSN string `json:"return_sn"`
+}
+
+type IResReturnSNWrapper struct {
+	ReturnSN string `json:"return_sn"`
+}
+
+type IResReturnSN struct {
+	IResShopeeResponse
+	Response IResReturnSNWrapper `json:"response"`
+}
+
+// -- dispute
+type IBDisputeReturn struct {
+	ReturnSN          string   `json:"return_sn"`
+	Email             string   `json:"email"`
+	DisputeReason     int64    `json:"dispute_reason"`
+	DisputeTextReason string   `json:"dispute_text_reason"`
+	Images            []string `json:"images,omitempty"` // url from /returns/convert_image
+}
+
+type IResDisputeReturnWrapper struct {
+	Msg string `json:"msg"`
+}
+
+type IResDisputeReturn struct {
+	IResShopeeResponse
+	Response IResDisputeReturnWrapper `json:"response"`
+}
+
+// -- offer
+type IBOfferReturn struct {
+	ReturnSN                     string                    `json:"return_sn"`
+	ProposedSolution             IEnumShopeeReturnSolution `json:"proposed_solution"`
+	ProposedAdjustedRefundAmount float64                   `json:"proposed_adjusted_refund_amount,omitempty"`
+}
+
+// -- convert_image : multipart "images"
+type IFileUpload struct {
+	Filename string
+	Data     []byte
+}
+
+type IResReturnImage struct {
+	Thumbnail string `json:"thumbnail"`
+	URL       string `json:"url"`
+}
+
+type IResConvertImageWrapper struct {
+	Images []IResReturnImage `json:"images"`
+}
+
+type IResConvertImage struct {
+	IResShopeeResponse
+	Response IResConvertImageWrapper `json:"response"`
+}
@@ -0,0 +1,483 @@
+package fakeshopee
+
+import (
+	"crypto/hmac"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"ecommerce/internal/adapter"
+)
+
+const (
+	orderListMaxRange    = 15 * 24 * time.Hour
+	orderListMaxPageSize = 100
+	orderDetailMaxSN     = 50
+)
+
+// call : verified caller of an /api/v2 path
+type call struct {
+	PartnerID string
+	ShopID    string
+}
+
+type apiHandler func(w http.ResponseWriter, r *http.Request, c *call)
+
+// public : partner_id + path + timestamp
+func (s *Server) public(method string, next apiHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		c, err := s.verify(r, method, false)
+		s.mu.Unlock()
+		if err != nil {
+			writeError(w, err.Status, err.Code, err.Message)
+			return
+		}
+		next(w, r, c)
+	}
+}
+
+// shop : partner_id + path + timestamp + access_token + shop_id
+func (s *Server) shop(method string, next apiHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		c, err := s.verify(r, method, true)
+		s.mu.Unlock()
+		if err != nil {
+			writeError(w, err.Status, err.Code, err.Message)
+			return
+		}
+		next(w, r, c)
+	}
+}
+
+// verify : count, faults, rate limit, then signature. Caller holds s.mu.
+func (s *Server) verify(r *http.Request, method string, shopAPI bool) (*call, *apiError) {
+	apiPath := r.URL.Path
+	s.requests[apiPath]++
+
+	if f := s.nextFault(apiPath); f != nil {
+		return nil, &apiError{f.Status, f.Error, f.Message}
+	}
+	if s.rateLimit > 0 {
+		sec := s.opts.Now().Unix()
+		if sec != s.rateWindow {
+			s.rateWindow, s.rateCount = sec, 0
+		}
+		s.rateCount++
+		if s.rateCount > s.rateLimit {
+			return nil, &apiError{http.StatusTooManyRequests, "error_too_many_request", "Too many requests, please try again later."}
+		}
+	}
+	if r.Method != method {
+		return nil, &apiError{http.StatusMethodNotAllowed, "error_param", "Wrong method, use " + method + "."}
+	}
+
+	q := r.URL.Query()
+	partnerID := q.Get("partner_id")
+	partnerKey, ok := s.partners[partnerID]
+	if !ok {
+		return nil, &apiError{http.StatusForbidden, "error_auth", "Invalid partner_id."}
+	}
+
+	ts, err := strconv.ParseInt(q.Get("timestamp"), 10, 64)
+	if err != nil {
+		return nil, &apiError{http.StatusBadRequest, "error_param", "Missing or invalid timestamp."}
+	}
+	if age := s.opts.Now().Sub(time.Unix(ts, 0)); age > s.opts.TimestampWindow || age < -s.opts.TimestampWindow {
+		return nil, &apiError{http.StatusForbidden, "error_auth", "Invalid timestamp."}
+	}
+
+	auth := adapter.PUBLIC
+	if shopAPI {
+		auth = adapter.SHOP
+	}
+	base, _ := adapter.ShopeeSignBase(auth, partnerID, apiPath, q.Get("timestamp"), q.Get("access_token"), q.Get("shop_id"))
+	if !hmacEqual(adapter.ShopeeSign(partnerKey, base), q.Get("sign")) {
+		return nil, &apiError{http.StatusForbidden, "error_sign", "Wrong sign."}
+	}
+
+	c := &call{PartnerID: partnerID}
+	if !shopAPI {
+		return c, nil
+	}
+
+	c.ShopID = q.Get("shop_id")
+	g, ok := s.access[q.Get("access_token")]
+	if !ok || g.PartnerID != partnerID || g.ShopID != c.ShopID {
+		return nil, &apiError{http.StatusForbidden, "error_auth", "Invalid access_token."}
+	}
+	if !s.opts.Now().Before(g.ExpireAt) {
+		return nil, &apiError{http.StatusForbidden, "error_auth", "Access token expired."}
+	}
+	return c, nil
+}
+
+// nextFault : first matching fault, consumed when it has Times left. Caller holds s.mu.
+func (s *Server) nextFault(apiPath string) *Fault {
+	for i, f := range s.faults {
+		if f.Path != "" && f.Path != apiPath {
+			continue
+		}
+		if f.Times > 0 {
+			f.Times--
+			if f.Times == 0 {
+				s.faults = append(s.faults[:i], s.faults[i+1:]...)
+			}
+		}
+		return f
+	}
+	return nil
+}
+
+func hmacEqual(expected string, got string) bool {
+	return hmac.Equal([]byte(expected), []byte(strings.ToLower(got)))
+}
+
+// ----------------- [PUBLIC] -----------------
+
+// seller consent is implied : redirect with a code for shop_id (or the first shop of the partner)
+func (s *Server) handleAuthPartner(w http.ResponseWriter, r *http.Request, c *call) {
+	redirect := r.URL.Query().Get("redirect")
+	target, err := url.Parse(redirect)
+	if redirect == "" || err != nil {
+		writeError(w, http.StatusBadRequest, "error_param", "Invalid redirect.")
+		return
+	}
+
+	shopID := r.URL.Query().Get("shop_id")
+	if shopID == "" {
+		s.mu.Lock()
+		var first int64
+		for _, shop := range s.shops {
+			if strconv.FormatInt(shop.PartnerID, 10) == c.PartnerID && (first == 0 || shop.ShopID < first) {
+				first = shop.ShopID
+			}
+		}
+		s.mu.Unlock()
+		shopID = strconv.FormatInt(first, 10)
+	}
+
+	code, err := s.IssueAuthCode(c.PartnerID, shopID)
+	if err != nil {
+		e := err.(*apiError)
+		writeError(w, e.Status, e.Code, e.Message)
+		return
+	}
+
+	q := target.Query()
+	q.Set("code", code)
+	q.Set("shop_id", shopID)
+	target.RawQuery = q.Encode()
+	http.Redirect(w, r, target.String(), http.StatusFound)
+}
+
+type tokenBody struct {
+	Code         string `json:"code"`
+	RefreshToken string `json:"refresh_token"`
+	PartnerID    int64  `json:"partner_id"`
+	ShopID       int64  `json:"shop_id"`
+}
+
+func (s *Server) handleTokenGet(w http.ResponseWriter, r *http.Request, c *call) {
+	var body tokenBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "error_param", "Invalid body.")
+		return
+	}
+	shopID := strconv.FormatInt(body.ShopID, 10)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	g, ok := s.codes[body.Code]
+	if !ok || g.PartnerID != c.PartnerID || g.ShopID != shopID || !s.opts.Now().Before(g.ExpireAt) {
+		writeError(w, http.StatusForbidden, "error_auth", "Invalid code.")
+		return
+	}
+	delete(s.codes, body.Code) // single use
+	s.authedAt[shopID] = s.opts.Now()
+
+	access, refresh := s.issueTokens(c.PartnerID, shopID)
+	writeOK(w, map[string]any{
+		"access_token":  access,
+		"refresh_token": refresh,
+		"expire_in":     int(s.opts.AccessTokenTTL.Seconds()),
+		"shop_id":       body.ShopID,
+	})
+}
+
+func (s *Server) handleAccessTokenGet(w http.ResponseWriter, r *http.Request, c *call) {
+	var body tokenBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "error_param", "Invalid body.")
+		return
+	}
+	shopID := strconv.FormatInt(body.ShopID, 10)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	g, ok := s.refresh[body.RefreshToken]
+	if !ok || g.PartnerID != c.PartnerID || g.ShopID != shopID {
+		writeError(w, http.StatusForbidden, "error_auth", "Invalid refresh_token.")
+		return
+	}
+	if !s.opts.Now().Before(g.ExpireAt) {
+		writeError(w, http.StatusForbidden, "error_auth", "Refresh token expired.")
+		return
+	}
+	delete(s.refresh, body.RefreshToken) // rotated
+
+	access, refresh := s.issueTokens(c.PartnerID, shopID)
+	writeOK(w, map[string]any{
+		"partner_id":    body.PartnerID,
+		"access_token":  access,
+		"refresh_token": refresh,
+		"expire_in":     int(s.opts.AccessTokenTTL.Seconds()),
+		"shop_id":       body.ShopID,
+	})
+}
+
+// issueTokens : caller holds s.mu
+func (s *Server) issueTokens(partnerID string, shopID string) (string, string) {
+	now := s.opts.Now()
+	access, refresh := randomToken(16), randomToken(16)
+	s.access[access] = grant{PartnerID: partnerID, ShopID: shopID, ExpireAt: now.Add(s.opts.AccessTokenTTL)}
+	s.refresh[refresh] = grant{PartnerID: partnerID, ShopID: shopID, ExpireAt: now.Add(s.opts.RefreshTokenTTL)}
+	return access, refresh
+}
+
+func (s *Server) handleGetShopsByPartner(w http.ResponseWriter, r *http.Request, c *call) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := []map[string]any{}
+	for shopID, at := range s.authedAt {
+		shop := s.shops[shopID]
+		if strconv.FormatInt(shop.PartnerID, 10) != c.PartnerID {
+			continue
+		}
+		list = append(list, map[string]any{
+			"shop_id":            shop.ShopID,
+			"region":             shop.Region,
+			"auth_time":          at.Unix(),
+			"expire_time":        at.Add(365 * 24 * time.Hour).Unix(),
+			"sip_affi_shop_list": []any{},
+		})
+	}
+	writeOK(w, map[string]any{"authed_shop_list": list, "more": false})
+}
+
+// ----------------- [SHOP] -----------------
+
+func (s *Server) handleGetShopInfo(w http.ResponseWriter, r *http.Request, c *call) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	shop := s.shops[c.ShopID]
+	at := s.authedAt[c.ShopID]
+	writeOK(w, map[string]any{
+		"shop_name":   shop.ShopName,
+		"region":      shop.Region,
+		"status":      shop.Status,
+		"is_cb":       false,
+		"is_sip":      false,
+		"auth_time":   at.Unix(),
+		"expire_time": at.Add(365 * 24 * time.Hour).Unix(),
+	})
+}
+
+func (s *Server) handleGetProfile(w http.ResponseWriter, r *http.Request, c *call) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	shop := s.shops[c.ShopID]
+	writeOK(w, map[string]any{"response": map[string]any{
+		"shop_logo":   "",
+		"description": shop.Description,
+		"shop_name":   shop.ShopName,
+	}})
+}
+
+// cursor is the offset in the filtered list
+func (s *Server) handleGetOrderList(w http.ResponseWriter, r *http.Request, c *call) {
+	q := r.URL.Query()
+	field := q.Get("time_range_field")
+	if field != "create_time" && field != "update_time" {
+		writeError(w, http.StatusBadRequest, "error_param", "time_range_field must be create_time or update_time.")
+		return
+	}
+	from, errFrom := strconv.ParseInt(q.Get("time_from"), 10, 64)
+	to, errTo := strconv.ParseInt(q.Get("time_to"), 10, 64)
+	if errFrom != nil || errTo != nil || to < from {
+		writeError(w, http.StatusBadRequest, "error_param", "Invalid time_from or time_to.")
+		return
+	}
+	if time.Duration(to-from)*time.Second > orderListMaxRange {
+		writeError(w, http.StatusBadRequest, "error_param", "The time range should not exceed 15 days.")
+		return
+	}
+	pageSize, err := strconv.Atoi(q.Get("page_size"))
+	if err != nil || pageSize < 1 || pageSize > orderListMaxPageSize {
+		writeError(w, http.StatusBadRequest, "error_param", "page_size should be between 1 and 100.")
+		return
+	}
+	offset := 0
+	if cur := q.Get("cursor"); cur != "" {
+		if offset, err = strconv.Atoi(cur); err != nil || offset < 0 {
+			writeError(w, http.StatusBadRequest, "error_param", "Invalid cursor.")
+			return
+		}
+	}
+	status := q.Get("order_status")
+	withStatus := strings.Contains(q.Get("response_optional_fields"), "order_status")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	matched := []*Order{}
+	for _, o := range s.orders[c.ShopID] {
+		t := o.CreateTime
+		if field == "update_time" {
+			t = o.UpdateTime
+		}
+		if t < from || t > to || (status != "" && o.OrderStatus != status) {
+			continue
+		}
+		matched = append(matched, o)
+	}
+
+	list := []map[string]any{}
+	end := offset + pageSize
+	for i := offset; i < end && i < len(matched); i++ {
+		item := map[string]any{"order_sn": matched[i].OrderSN, "booking_sn": matched[i].BookingSN}
+		if withStatus {
+			item["order_status"] = matched[i].OrderStatus
+		}
+		list = append(list, item)
+	}
+	more := end < len(matched)
+	next := ""
+	if more {
+		next = strconv.Itoa(end)
+	}
+	writeOK(w, map[string]any{"response": map[string]any{
+		"more":        more,
+		"next_cursor": next,
+		"order_list":  list,
+	}})
+}
+
+func (s *Server) handleGetOrderDetail(w http.ResponseWriter, r *http.Request, c *call) {
+	raw := r.URL.Query().Get("order_sn_list")
+	if raw == "" {
+		writeError(w, http.StatusBadRequest, "error_param", "order_sn_list is required.")
+		return
+	}
+	snList := strings.Split(raw, ",")
+	if len(snList) > orderDetailMaxSN {
+		writeError(w, http.StatusBadRequest, "error_param", "order_sn_list should not exceed 50.")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := []any{}
+	warning := []string{}
+	for _, sn := range snList {
+		o, ok := s.orderBySN[sn]
+		if !ok || strconv.FormatInt(o.ShopID, 10) != c.ShopID {
+			warning = append(warning, "order_sn "+sn+" not found")
+			continue
+		}
+		list = append(list, o.IResOrderListWithDetails)
+	}
+	writeOK(w, map[string]any{
+		"warning":  warning,
+		"response": map[string]any{"order_list": list},
+	})
+}
+
+// ----------------- [Control plane] -----------------
+
+func (s *Server) handleAdminAuthCode(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "error_param", "Wrong method, use POST.")
+		return
+	}
+	var body struct {
+		PartnerID string `json:"partner_id"`
+		ShopID    string `json:"shop_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "error_param", "Invalid body.")
+		return
+	}
+	code, err := s.IssueAuthCode(body.PartnerID, body.ShopID)
+	if err != nil {
+		e := err.(*apiError)
+		writeError(w, e.Status, e.Code, e.Message)
+		return
+	}
+	writeOK(w, map[string]any{"code": code, "shop_id": body.ShopID})
+}
+
+func (s *Server) handleAdminFaults(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		var f Fault
+		if err := json.NewDecoder(r.Body).Decode(&f); err != nil {
+			writeError(w, http.StatusBadRequest, "error_param", "Invalid body.")
+			return
+		}
+		s.InjectFault(f)
+		writeOK(w, map[string]any{})
+	case http.MethodDelete:
+		s.ClearFaults()
+		writeOK(w, map[string]any{})
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "error_param", "Wrong method, use POST or DELETE.")
+	}
+}
+
+func (s *Server) handleAdminRateLimit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "error_param", "Wrong method, use POST.")
+		return
+	}
+	var body struct {
+		PerSecond int `json:"per_second"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "error_param", "Invalid body.")
+		return
+	}
+	s.SetRateLimit(body.PerSecond)
+	writeOK(w, map[string]any{"per_second": body.PerSecond})
+}
+
+func (s *Server) handleAdminExpireTokens(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "error_param", "Wrong method, use POST.")
+		return
+	}
+	s.ExpireAccessTokens(r.URL.Query().Get("shop_id"))
+	writeOK(w, map[string]any{})
+}
+
+func (s *Server) handleAdminStats(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	requests := make(map[string]int, len(s.requests))
+	for k, v := range s.requests {
+		requests[k] = v
+	}
+	writeOK(w, map[string]any{
+		"requests":   requests,
+		"faults":     s.faults,
+		"rate_limit": s.rateLimit,
+		"shops":      len(s.shops),
+		"orders":     len(s.orderBySN),
+	})
+}
@@ -0,0 +1,126 @@
+package fakeshopee
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"ecommerce/internal/adapter/dto"
+)
+
+type Partner struct {
+	PartnerID  int64  `json:"partner_id"`
+	PartnerKey string `json:"partner_key"`
+}
+
+type Shop struct {
+	ShopID      int64  `json:"shop_id"`
+	PartnerID   int64  `json:"partner_id"`
+	ShopName    string `json:"shop_name"`
+	Region      string `json:"region"`
+	Status      string `json:"status"`
+	Description string `json:"description"`
+}
+
+// Order : get_order_detail payload + owning shop
+type Order struct {
+	ShopID int64 `json:"shop_id"`
+	dto.IResOrderListWithDetails
+}
+
+// Seed : initial state of the fake, can be loaded from json
+type Seed struct {
+	Partners []Partner `json:"partners"`
+	Shops    []Shop    `json:"shops"`
+	Orders   []Order   `json:"orders"`
+}
+
+func LoadSeed(path string) (*Seed, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var seed Seed
+	if err := json.Unmarshal(b, &seed); err != nil {
+		return nil, fmt.Errorf("fakeshopee.LoadSeed : %w", err)
+	}
+	return &seed, nil
+}
+
+var seedStatuses = []dto.IEnumShopeeOrderStatus{
+	dto.UNPAID, dto.READY_TO_SHIP, dto.PROCESSED, dto.SHIPPED, dto.COMPLETED, dto.COMPLETED, dto.CANCELLED,
+}
+
+// DefaultSeed : one partner, `shops` shops (ids 2000001..) with `orders` orders each,
+// spread over the 30 days before now. Deterministic for a given now.
+func DefaultSeed(partnerID int64, partnerKey string, shops int, orders int, now time.Time) *Seed {
+	seed := &Seed{Partners: []Partner{{PartnerID: partnerID, PartnerKey: partnerKey}}}
+
+	for i := 0; i < shops; i++ {
+		shopID := int64(2000001 + i)
+		seed.Shops = append(seed.Shops, Shop{
+			ShopID:      shopID,
+			PartnerID:   partnerID,
+			ShopName:    fmt.Sprintf("Fake Shop %d", i+1),
+			Region:      "TH",
+			Status:      "NORMAL",
+			Description: "seeded by fakeshopee",
+		})
+
+		step := 30 * 24 * time.Hour
+		if orders > 0 {
+			step /= time.Duration(orders)
+		}
+		for n := 0; n < orders; n++ {
+			created := now.Add(-30 * 24 * time.Hour).Add(step * time.Duration(n))
+			status := seedStatuses[n%len(seedStatuses)]
+			sn := fmt.Sprintf("FAKE%d%06d", i+1, n+1)
+			price := float64(100 + (n%20)*25)
+
+			seed.Orders = append(seed.Orders, Order{
+				ShopID: shopID,
+				IResOrderListWithDetails: dto.IResOrderListWithDetails{
+					OrderSN:         sn,
+					Region:          "TH",
+					Currency:        "THB",
+					TotalAmount:     price + 40,
+					OrderStatus:     string(status),
+					ShippingCarrier: "Fake Express",
+					PaymentMethod:   "Online Payment",
+					CreateTime:      created.Unix(),
+					UpdateTime:      created.Add(time.Hour).Unix(),
+					DaysToShip:      2,
+					ShipByDate:      created.Add(48 * time.Hour).Unix(),
+					BuyerUserID:     100000 + n,
+					BuyerUsername:   "buyer" + strconv.Itoa(n+1),
+					PayTime:         created.Add(5 * time.Minute).Unix(),
+					RecipientAddress: dto.IResOrderDetailReceiptAddress{
+						Name:        "Buyer " + strconv.Itoa(n+1),
+						Phone:       "66800000000",
+						City:        "Bangkok",
+						Region:      "TH",
+						Zipcode:     "10110",
+						FullAddress: "1 Fake Road, Bangkok 10110",
+					},
+					ItemList: []dto.IResOrderDetailItem{{
+						ItemID:               int64(3000001 + n%10),
+						ItemName:             fmt.Sprintf("Fake Item %d", n%10+1),
+						ItemSKU:              fmt.Sprintf("SKU-%03d", n%10+1),
+						ModelQtyPurchased:    1,
+						ModelOriginalPrice:   price,
+						ModelDiscountedPrice: price,
+						OrderItemID:          int64(3000001 + n%10),
+					}},
+					PackageList: []dto.IResOrderDetailPackage{{
+						PackageNumber:   "PKG" + sn,
+						LogisticsStatus: "LOGISTICS_NOT_START",
+						ShippingCarrier: "Fake Express",
+					}},
+				},
+			})
+		}
+	}
+	return seed
+}
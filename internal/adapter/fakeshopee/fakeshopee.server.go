@@ -0,0 +1,261 @@
+// Package fakeshopee is an in-memory Shopee Open Platform v2 for tests and local development.
+//
+// It checks partner / shop signatures with the adapter's own ShopeeSignBase / ShopeeSign,
+// issues and expires tokens, serves seeded shops and orders, and can inject errors and rate limits.
+// Use it with httptest.NewServer(fakeshopee.New(seed, nil)) or run cmd/fakeshopee.
+package fakeshopee
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type Options struct {
+	AccessTokenTTL  time.Duration // default 4h, like Shopee
+	RefreshTokenTTL time.Duration // default 30 days
+	AuthCodeTTL     time.Duration // default 10 min
+	TimestampWindow time.Duration // max age of the timestamp query, default 5 min
+	Now             func() time.Time
+}
+
+// Fault : returned instead of the real answer
+type Fault struct {
+	Path    string `json:"path"` // "" : every /api/v2 path
+	Status  int    `json:"status"`
+	Error   string `json:"error"`
+	Message string `json:"message"`
+	Times   int    `json:"times"` // 0 : until ClearFaults
+}
+
+type grant struct {
+	PartnerID string
+	ShopID    string
+	ExpireAt  time.Time
+}
+
+type Server struct {
+	mu   sync.Mutex
+	opts Options
+
+	partners   map[string]string // partner_id -> partner_key
+	shops      map[string]*Shop
+	orders     map[string][]*Order // shop_id -> orders
+	orderBySN  map[string]*Order
+	authedAt   map[string]time.Time // shop_id -> auth_time
+	codes      map[string]grant
+	access     map[string]grant
+	refresh    map[string]grant
+	faults     []*Fault
+	rateLimit  int // per second, 0 : off
+	rateWindow int64
+	rateCount  int
+	requests   map[string]int
+
+	mux *http.ServeMux
+}
+
+func New(seed *Seed, opts *Options) *Server {
+	o := Options{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.AccessTokenTTL <= 0 {
+		o.AccessTokenTTL = 4 * time.Hour
+	}
+	if o.RefreshTokenTTL <= 0 {
+		o.RefreshTokenTTL = 30 * 24 * time.Hour
+	}
+	if o.AuthCodeTTL <= 0 {
+		o.AuthCodeTTL = 10 * time.Minute
+	}
+	if o.TimestampWindow <= 0 {
+		o.TimestampWindow = 5 * time.Minute
+	}
+	if o.Now == nil {
+		o.Now = time.Now
+	}
+
+	s := &Server{
+		opts:      o,
+		partners:  map[string]string{},
+		shops:     map[string]*Shop{},
+		orders:    map[string][]*Order{},
+		orderBySN: map[string]*Order{},
+		authedAt:  map[string]time.Time{},
+		codes:     map[string]grant{},
+		access:    map[string]grant{},
+		refresh:   map[string]grant{},
+		requests:  map[string]int{},
+	}
+	if seed != nil {
+		for _, p := range seed.Partners {
+			s.partners[strconv.FormatInt(p.PartnerID, 10)] = p.PartnerKey
+		}
+		for i := range seed.Shops {
+			shop := seed.Shops[i]
+			s.shops[strconv.FormatInt(shop.ShopID, 10)] = &shop
+		}
+		s.AddOrders(seed.Orders...)
+	}
+	s.routes()
+	return s
+}
+
+func (s *Server) routes() {
+	s.mux = http.NewServeMux()
+
+	// PUBLIC
+	s.mux.HandleFunc("/api/v2/shop/auth_partner", s.public(http.MethodGet, s.handleAuthPartner))
+	s.mux.HandleFunc("/api/v2/auth/token/get", s.public(http.MethodPost, s.handleTokenGet))
+	s.mux.HandleFunc("/api/v2/auth/access_token/get", s.public(http.MethodPost, s.handleAccessTokenGet))
+	s.mux.HandleFunc("/api/v2/public/get_shops_by_partner", s.public(http.MethodGet, s.handleGetShopsByPartner))
+
+	// SHOP
+	s.mux.HandleFunc("/api/v2/shop/get_shop_info", s.shop(http.MethodGet, s.handleGetShopInfo))
+	s.mux.HandleFunc("/api/v2/shop/get_profile", s.shop(http.MethodGet, s.handleGetProfile))
+	s.mux.HandleFunc("/api/v2/order/get_order_list", s.shop(http.MethodGet, s.handleGetOrderList))
+	s.mux.HandleFunc("/api/v2/order/get_order_detail", s.shop(http.MethodGet, s.handleGetOrderDetail))
+
+	// control plane for cmd/fakeshopee
+	s.mux.HandleFunc("/_fake/auth_code", s.handleAdminAuthCode)
+	s.mux.HandleFunc("/_fake/faults", s.handleAdminFaults)
+	s.mux.HandleFunc("/_fake/rate_limit", s.handleAdminRateLimit)
+	s.mux.HandleFunc("/_fake/expire_tokens", s.handleAdminExpireTokens)
+	s.mux.HandleFunc("/_fake/stats", s.handleAdminStats)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// "/api/v2//auth/token/get" : prefix config may carry a trailing slash
+	r.URL.Path = path.Clean(r.URL.Path)
+	h, pattern := s.mux.Handler(r)
+	if pattern == "" {
+		writeError(w, http.StatusNotFound, "error_not_found", "Wrong path: "+r.URL.Path)
+		return
+	}
+	h.ServeHTTP(w, r)
+}
+
+// ----------------- [Control] -----------------
+
+func (s *Server) AddOrders(orders ...Order) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range orders {
+		o := orders[i]
+		shopID := strconv.FormatInt(o.ShopID, 10)
+		if old, ok := s.orderBySN[o.OrderSN]; ok {
+			*old = o
+			continue
+		}
+		s.orders[shopID] = append(s.orders[shopID], &o)
+		s.orderBySN[o.OrderSN] = &o
+	}
+	for _, list := range s.orders {
+		sort.SliceStable(list, func(i, j int) bool { return list[i].CreateTime < list[j].CreateTime })
+	}
+}
+
+// IssueAuthCode : what Shopee sends to the redirect url after the seller authorizes the shop
+func (s *Server) IssueAuthCode(partnerID string, shopID string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	shop, ok := s.shops[shopID]
+	if !ok || strconv.FormatInt(shop.PartnerID, 10) != partnerID {
+		return "", errShopNotFound
+	}
+	code := randomToken(16)
+	s.codes[code] = grant{PartnerID: partnerID, ShopID: shopID, ExpireAt: s.opts.Now().Add(s.opts.AuthCodeTTL)}
+	return code, nil
+}
+
+func (s *Server) InjectFault(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if f.Status == 0 {
+		f.Status = http.StatusInternalServerError
+	}
+	if f.Error == "" {
+		f.Error = "error_server"
+	}
+	s.faults = append(s.faults, &f)
+}
+
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = nil
+}
+
+// SetRateLimit : max calls per second over every /api/v2 path, 0 turns it off
+func (s *Server) SetRateLimit(perSecond int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rateLimit = perSecond
+	s.rateCount = 0
+}
+
+// ExpireAccessTokens : every access token of the shop ("" : all shops) expires now
+func (s *Server) ExpireAccessTokens(shopID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.opts.Now()
+	for k, g := range s.access {
+		if shopID == "" || g.ShopID == shopID {
+			g.ExpireAt = now
+			s.access[k] = g
+		}
+	}
+}
+
+// RequestCount : calls received on an api path, faulted and rejected ones included
+func (s *Server) RequestCount(apiPath string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[apiPath]
+}
+
+// ----------------- [Helpers] -----------------
+
+var errShopNotFound = &apiError{http.StatusForbidden, "error_auth", "Shop is not authorized to the partner."}
+
+type apiError struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *apiError) Error() string { return e.Code + ": " + e.Message }
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status int, code string, message string) {
+	writeJSON(w, status, map[string]any{
+		"request_id": randomToken(8),
+		"error":      code,
+		"message":    message,
+	})
+}
+
+func writeOK(w http.ResponseWriter, body map[string]any) {
+	body["request_id"] = randomToken(8)
+	body["error"] = ""
+	body["message"] = ""
+	writeJSON(w, http.StatusOK, body)
+}
+
+func randomToken(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,232 @@
+package adapter
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"ecommerce/internal/adapter/dto"
+	"ecommerce/internal/env"
+)
+
+// Lazada limits
+const (
+	LazadaOrderListMaxLimit   = 100
+	LazadaOrderItemsMaxOrder  = 50
+	LazadaProductListMaxLimit = 50
+)
+
+type IReqLazadaAdapter struct {
+	AppKey      string
+	AppSecret   string
+	AccessToken string
+	Region      string // seller country : picks the api host
+}
+
+type ILazadaService interface {
+	// path : /auth/token/create
+	GetAccessToken(ctx context.Context, params *IReqLazadaAdapter, code string) (*dto.IResLazadaToken, error)
+	// path : /auth/token/refresh
+	RefreshAccessToken(ctx context.Context, params *IReqLazadaAdapter, refreshToken string) (*dto.IResLazadaToken, error)
+
+	// path : /seller/get
+	GetSeller(ctx context.Context, params *IReqLazadaAdapter) (*dto.IResLazadaSeller, error)
+
+	// path : /orders/get : one page, caller follows Offset / CountTotal
+	GetOrders(ctx context.Context, params *IReqLazadaAdapter, opts *dto.IOptionLazadaOrderQuery) (*dto.IResLazadaOrderList, error)
+	// path : /order/get
+	GetOrder(ctx context.Context, params *IReqLazadaAdapter, orderID string) (*dto.IResLazadaOrder, error)
+	// path : /order/items/get
+	GetOrderItems(ctx context.Context, params *IReqLazadaAdapter, orderID string) ([]dto.IResLazadaOrderItem, error)
+	// path : /orders/items/get : max 50 order_id
+	GetMultipleOrderItems(ctx context.Context, params *IReqLazadaAdapter, orderIDs []string) ([]dto.IResLazadaOrderItems, error)
+
+	// path : /products/get : one page
+	GetProducts(ctx context.Context, params *IReqLazadaAdapter, opts *dto.IOptionLazadaProductQuery) (*dto.IResLazadaProductList, error)
+	// path : /product/price_quantity/update : rejected skus come back in LazadaAPIError.Detail
+	UpdatePriceQuantity(ctx context.Context, params *IReqLazadaAdapter, skus []dto.IBLazadaSkuStock) error
+
+	// path : /order/pack
+	PackOrder(ctx context.Context, params *IReqLazadaAdapter, body *dto.IBLazadaPack) (*dto.IResLazadaPack, error)
+	// path : /order/rts
+	ReadyToShip(ctx context.Context, params *IReqLazadaAdapter, body *dto.IBLazadaReadyToShip) error
+}
+
+type lazadaApi struct {
+	Config     *env.Config
+	Logger     *zap.Logger
+	HttpClient *http.Client
+}
+
+func NewLazadaAPI(config *env.Config, log *zap.Logger) ILazadaService {
+	timeout := 10 * time.Second
+	if config.Lazada.LazadaHttpTimeout > 0 {
+		timeout = time.Duration(config.Lazada.LazadaHttpTimeout) * time.Second
+	}
+	return &lazadaApi{
+		Config:     config,
+		Logger:     log,
+		HttpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+func (s *lazadaApi) GetAccessToken(ctx context.Context, params *IReqLazadaAdapter, code string) (*dto.IResLazadaToken, error) {
+	if code == "" {
+		return nil, errors.New("adapter.lazada.GetAccessToken : code is required")
+	}
+	q := url.Values{}
+	q.Set("code", code)
+	return CallLazada[dto.IResLazadaToken](ctx, s, "/auth/token/create", params, q)
+}
+
+func (s *lazadaApi) RefreshAccessToken(ctx context.Context, params *IReqLazadaAdapter, refreshToken string) (*dto.IResLazadaToken, error) {
+	if refreshToken == "" {
+		return nil, errors.New("adapter.lazada.RefreshAccessToken : refresh token is required")
+	}
+	q := url.Values{}
+	q.Set("refresh_token", refreshToken)
+	return CallLazada[dto.IResLazadaToken](ctx, s, "/auth/token/refresh", params, q)
+}
+
+func (s *lazadaApi) GetSeller(ctx context.Context, params *IReqLazadaAdapter) (*dto.IResLazadaSeller, error) {
+	return CallLazada[dto.IResLazadaSeller](ctx, s, "/seller/get", params, nil)
+}
+
+func (s *lazadaApi) GetOrders(ctx context.Context, params *IReqLazadaAdapter, opts *dto.IOptionLazadaOrderQuery) (*dto.IResLazadaOrderList, error) {
+	limit := opts.Limit
+	if limit <= 0 || limit > LazadaOrderListMaxLimit {
+		limit = LazadaOrderListMaxLimit
+	}
+	q := url.Values{}
+	q.Set("sort_by", "updated_at")
+	q.Set("sort_direction", "ASC")
+	q.Set("offset", strconv.Itoa(opts.Offset))
+	q.Set("limit", strconv.Itoa(limit))
+	if opts.UpdateAfter != "" {
+		q.Set("update_after", opts.UpdateAfter)
+	}
+	if opts.UpdateBefore != "" {
+		q.Set("update_before", opts.UpdateBefore)
+	}
+	if opts.Status != "" {
+		q.Set("status", opts.Status)
+	}
+	return CallLazada[dto.IResLazadaOrderList](ctx, s, "/orders/get", params, q)
+}
+
+func (s *lazadaApi) GetOrder(ctx context.Context, params *IReqLazadaAdapter, orderID string) (*dto.IResLazadaOrder, error) {
+	q := url.Values{}
+	q.Set("order_id", orderID)
+	return CallLazada[dto.IResLazadaOrder](ctx, s, "/order/get", params, q)
+}
+
+func (s *lazadaApi) GetOrderItems(ctx context.Context, params *IReqLazadaAdapter, orderID string) ([]dto.IResLazadaOrderItem, error) {
+	q := url.Values{}
+	q.Set("order_id", orderID)
+	res, err := CallLazada[[]dto.IResLazadaOrderItem](ctx, s, "/order/items/get", params, q)
+	if err != nil {
+		return nil, err
+	}
+	return *res, nil
+}
+
+func (s *lazadaApi) GetMultipleOrderItems(ctx context.Context, params *IReqLazadaAdapter, orderIDs []string) ([]dto.IResLazadaOrderItems, error) {
+	if len(orderIDs) == 0 {
+		return []dto.IResLazadaOrderItems{}, nil
+	}
+	if len(orderIDs) > LazadaOrderItemsMaxOrder {
+		return nil, errors.New("adapter.lazada.GetMultipleOrderItems : max 50 order_id per request")
+	}
+	q := url.Values{}
+	q.Set("order_ids", lazadaIDList(orderIDs))
+	res, err := CallLazada[[]dto.IResLazadaOrderItems](ctx, s, "/orders/items/get", params, q)
+	if err != nil {
+		return nil, err
+	}
+	return *res, nil
+}
+
+func (s *lazadaApi) GetProducts(ctx context.Context, params *IReqLazadaAdapter, opts *dto.IOptionLazadaProductQuery) (*dto.IResLazadaProductList, error) {
+	limit := opts.Limit
+	if limit <= 0 || limit > LazadaProductListMaxLimit {
+		limit = LazadaProductListMaxLimit
+	}
+	filter := opts.Filter
+	if filter == "" {
+		filter = "all"
+	}
+	q := url.Values{}
+	q.Set("filter", filter)
+	q.Set("offset", strconv.Itoa(opts.Offset))
+	q.Set("limit", strconv.Itoa(limit))
+	if opts.UpdateAfter != "" {
+		q.Set("update_after", opts.UpdateAfter)
+	}
+	return CallLazada[dto.IResLazadaProductList](ctx, s, "/products/get", params, q)
+}
+
+// xml payload of /product/price_quantity/update
+type lazadaStockSku struct {
+	ItemID    string `xml:"ItemId,omitempty"`
+	SkuID     string `xml:"SkuId,omitempty"`
+	SellerSku string `xml:"SellerSku,omitempty"`
+	Quantity  int64  `xml:"Quantity"`
+}
+
+type lazadaStockPayload struct {
+	XMLName xml.Name         `xml:"Request"`
+	Skus    []lazadaStockSku `xml:"Product>Skus>Sku"`
+}
+
+func (s *lazadaApi) UpdatePriceQuantity(ctx context.Context, params *IReqLazadaAdapter, skus []dto.IBLazadaSkuStock) error {
+	var payload lazadaStockPayload
+	for _, sku := range skus {
+		payload.Skus = append(payload.Skus, lazadaStockSku{ItemID: sku.ItemID, SkuID: sku.SkuID, SellerSku: sku.SellerSku, Quantity: sku.Quantity})
+	}
+	b, err := xml.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	q := url.Values{}
+	q.Set("payload", string(b))
+	_, err = CallLazada[struct{}](ctx, s, "/product/price_quantity/update", params, q)
+	return err
+}
+
+func (s *lazadaApi) PackOrder(ctx context.Context, params *IReqLazadaAdapter, body *dto.IBLazadaPack) (*dto.IResLazadaPack, error) {
+	deliveryType := body.DeliveryType
+	if deliveryType == "" {
+		deliveryType = "dropship"
+	}
+	q := url.Values{}
+	q.Set("order_item_ids", lazadaIDList(body.OrderItemIDs))
+	q.Set("delivery_type", deliveryType)
+	q.Set("shipping_provider", body.ShippingProvider)
+	return CallLazada[dto.IResLazadaPack](ctx, s, "/order/pack", params, q)
+}
+
+func (s *lazadaApi) ReadyToShip(ctx context.Context, params *IReqLazadaAdapter, body *dto.IBLazadaReadyToShip) error {
+	deliveryType := body.DeliveryType
+	if deliveryType == "" {
+		deliveryType = "dropship"
+	}
+	q := url.Values{}
+	q.Set("order_item_ids", lazadaIDList(body.OrderItemIDs))
+	q.Set("delivery_type", deliveryType)
+	q.Set("shipment_provider", body.ShipmentProvider)
+	q.Set("tracking_number", body.TrackingNumber)
+	_, err := CallLazada[struct{}](ctx, s, "/order/rts", params, q)
+	return err
+}
+
+// lazadaIDList : ["1","2"] -> "[1,2]" (Lazada list parameters are json arrays of numbers)
+func lazadaIDList(ids []string) string {
+	return "[" + strings.Join(ids, ",") + "]"
+}
@@ -0,0 +1,238 @@
+package adapter
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"ecommerce/internal/adapter/dto"
+)
+
+// lazadaEndpoint : one row of the Lazada endpoint registry
+type lazadaEndpoint struct {
+	Path   string
+	Method string
+	// Token : access_token is a system parameter of the call
+	Token bool
+	// AuthHost : served by auth.lazada.com instead of the country api host
+	AuthHost bool
+	// Wrapped : payload sits under "data", otherwise at the top level (token endpoints)
+	Wrapped bool
+}
+
+var lazadaEndpoints = map[string]lazadaEndpoint{}
+
+func init() {
+	for _, e := range []lazadaEndpoint{
+		// auth
+		{Path: "/auth/token/create", Method: "POST", AuthHost: true},
+		{Path: "/auth/token/refresh", Method: "POST", AuthHost: true},
+
+		// seller
+		{Path: "/seller/get", Method: "GET", Token: true, Wrapped: true},
+
+		// order
+		{Path: "/orders/get", Method: "GET", Token: true, Wrapped: true},
+		{Path: "/order/get", Method: "GET", Token: true, Wrapped: true},
+		{Path: "/order/items/get", Method: "GET", Token: true, Wrapped: true},
+		{Path: "/orders/items/get", Method: "GET", Token: true, Wrapped: true},
+
+		// product
+		{Path: "/products/get", Method: "GET", Token: true, Wrapped: true},
+		{Path: "/product/price_quantity/update", Method: "POST", Token: true, Wrapped: true},
+
+		// fulfillment
+		{Path: "/order/pack", Method: "POST", Token: true, Wrapped: true},
+		{Path: "/order/rts", Method: "POST", Token: true, Wrapped: true},
+	} {
+		lazadaEndpoints[e.Path] = e
+	}
+}
+
+// api host per seller country
+var lazadaRegionHosts = map[string]string{
+	"th": "https://api.lazada.co.th/rest",
+	"my": "https://api.lazada.com.my/rest",
+	"sg": "https://api.lazada.sg/rest",
+	"vn": "https://api.lazada.vn/rest",
+	"ph": "https://api.lazada.com.ph/rest",
+	"id": "https://api.lazada.co.id/rest",
+}
+
+// lazadaEnvelope : the fields every Lazada answer shares
+type lazadaEnvelope struct {
+	Code      string                     `json:"code"`
+	Type      string                     `json:"type"`
+	Message   string                     `json:"message"`
+	RequestID string                     `json:"request_id"`
+	Detail    []dto.IResLazadaFieldError `json:"detail"`
+	Data      json.RawMessage            `json:"data"`
+}
+
+// lazadaSystemParams : set and signed by send
+var lazadaSystemParams = map[string]bool{"app_key": true, "timestamp": true, "sign_method": true, "access_token": true, "sign": true}
+
+// LazadaSign : HMAC-SHA256(app secret, api path + sorted key1value1key2value2...), upper hex ;
+// every parameter but sign is signed, system ones included
+func LazadaSign(appSecret string, path string, params url.Values) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		if k != "sign" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(path)
+	for _, k := range keys {
+		b.WriteString(k)
+		b.WriteString(params.Get(k))
+	}
+	h := hmac.New(sha256.New, []byte(appSecret))
+	h.Write([]byte(b.String()))
+	return strings.ToUpper(hex.EncodeToString(h.Sum(nil)))
+}
+
+func (s *lazadaApi) baseURL(e lazadaEndpoint, region string) string {
+	if e.AuthHost {
+		return s.Config.Lazada.LazadaAuthBaseUrl
+	}
+	if s.Config.Lazada.LazadaApiBaseUrl != "" {
+		return s.Config.Lazada.LazadaApiBaseUrl
+	}
+	if host, ok := lazadaRegionHosts[strings.ToLower(region)]; ok {
+		return host
+	}
+	if host, ok := lazadaRegionHosts[strings.ToLower(s.Config.Lazada.LazadaDefaultRegion)]; ok {
+		return host
+	}
+	return lazadaRegionHosts["th"]
+}
+
+// send : one signed attempt ; GET sends the parameters as query, POST as a form body
+func (s *lazadaApi) send(ctx context.Context, e lazadaEndpoint, params *IReqLazadaAdapter, business url.Values) (*http.Response, []byte, error) {
+	if params.AppKey == "" || params.AppSecret == "" {
+		return nil, nil, errors.New("adapter.lazada : app key / secret is required")
+	}
+	if e.Token && params.AccessToken == "" {
+		return nil, nil, newLazadaAPIError(e.Path, 0, "", "MissingAccessToken", "access token is required")
+	}
+
+	q := url.Values{}
+	for k, v := range business {
+		if lazadaSystemParams[k] {
+			return nil, nil, fmt.Errorf("adapter.CallLazada %s : %s is a system parameter, it can't be sent as a business one", e.Path, k)
+		}
+		q[k] = v
+	}
+	q.Set("app_key", params.AppKey)
+	q.Set("timestamp", strconv.FormatInt(time.Now().UnixMilli(), 10))
+	q.Set("sign_method", "sha256")
+	if e.Token {
+		q.Set("access_token", params.AccessToken)
+	}
+	q.Set("sign", LazadaSign(params.AppSecret, e.Path, q))
+
+	endpoint := strings.TrimRight(s.baseURL(e, params.Region), "/") + e.Path
+	var (
+		httpReq *http.Request
+		err     error
+	)
+	if e.Method == "GET" {
+		httpReq, err = http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"?"+q.Encode(), nil)
+	} else {
+		httpReq, err = http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(q.Encode()))
+		if err == nil {
+			httpReq.Header.Set("Content-Type", "application/x-www-form-urlencoded;charset=utf-8")
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resp, err := s.HttpClient.Do(httpReq)
+	if err != nil {
+		// *url.Error carries the full url : access_token and sign must not reach logs / answers
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			urlErr.URL = e.Path
+		}
+		s.Logger.Debug("adapter.CallLazada.resp", zap.String("path", e.Path), zap.Error(err))
+		return nil, nil, newLazadaAPIError(e.Path, 0, "", "error_network", err.Error())
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp, bodyBytes, nil
+}
+
+// decodeLazadaEnvelope : code "0" (or absent on token endpoints) = success
+func decodeLazadaEnvelope(e lazadaEndpoint, resp *http.Response, bodyBytes []byte) (*lazadaEnvelope, error) {
+	var env lazadaEnvelope
+	if err := json.Unmarshal(bytes.TrimSpace(bodyBytes), &env); err != nil {
+		if resp.StatusCode >= 300 {
+			return nil, newLazadaAPIError(e.Path, resp.StatusCode, "", "error_http", http.StatusText(resp.StatusCode))
+		}
+		return nil, errors.New("invalidate parse bodyBytes in adapter")
+	}
+	if env.Code != "" && env.Code != "0" {
+		apiErr := newLazadaAPIError(e.Path, resp.StatusCode, env.RequestID, env.Code, env.Message)
+		apiErr.Detail = env.Detail
+		return nil, apiErr
+	}
+	if resp.StatusCode >= 300 {
+		return nil, newLazadaAPIError(e.Path, resp.StatusCode, env.RequestID, "error_http", http.StatusText(resp.StatusCode))
+	}
+	return &env, nil
+}
+
+// CallLazada : signed request to a registered endpoint, Resp is "data"
+// (or the whole body for endpoints that are not Wrapped)
+func CallLazada[Resp any](ctx context.Context, s *lazadaApi, path string, params *IReqLazadaAdapter, business url.Values) (*Resp, error) {
+	e, ok := lazadaEndpoints[path]
+	if !ok {
+		return nil, fmt.Errorf("adapter.CallLazada : unknown lazada endpoint %s", path)
+	}
+
+	resp, bodyBytes, err := s.send(ctx, e, params, business)
+	if err != nil {
+		return nil, err
+	}
+	env, err := decodeLazadaEnvelope(e, resp, bodyBytes)
+	if err != nil {
+		s.Logger.Debug("adapter.CallLazada", zap.String("path", e.Path), zap.Error(err))
+		return nil, err
+	}
+
+	out := new(Resp)
+	raw := json.RawMessage(bodyBytes)
+	if e.Wrapped {
+		raw = env.Data
+	}
+	if len(raw) == 0 || string(raw) == "null" {
+		return out, nil
+	}
+	if err := json.Unmarshal(raw, out); err != nil {
+		s.Logger.Debug("adapter.CallLazada.parse", zap.String("path", e.Path), zap.String("request_id", env.RequestID), zap.Error(err))
+		return nil, errors.New("invalidate parse bodyBytes in adapter")
+	}
+	return out, nil
+}
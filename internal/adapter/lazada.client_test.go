@@ -0,0 +1,65 @@
+package adapter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"ecommerce/internal/env"
+)
+
+// the worked example of the Lazada open platform signature guide
+func TestLazadaSignKnownVector(t *testing.T) {
+	params := url.Values{
+		"app_key":      {"123456"},
+		"access_token": {"test"},
+		"timestamp":    {"1517820392000"},
+		"sign_method":  {"sha256"},
+		"order_id":     {"1234"},
+	}
+	const want = "4190D32361CFB9581350222F345CB77F3B19F0E31D162316848A2C1FFD5FAB4A"
+	if got := LazadaSign("helloworld", "/order/get", params); got != want {
+		t.Fatalf("sign = %s, want %s", got, want)
+	}
+
+	// a sign already in the params is not signed itself
+	params.Set("sign", "stale")
+	if got := LazadaSign("helloworld", "/order/get", params); got != want {
+		t.Errorf("with a sign param: sign = %s, want %s", got, want)
+	}
+}
+
+func TestCallLazadaRejectsSystemParams(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Write([]byte(`{"code":"0","data":{}}`))
+	}))
+	defer srv.Close()
+
+	cfg := &env.Config{Lazada: &env.LazadaConfig{LazadaApiBaseUrl: srv.URL, LazadaHttpTimeout: 5}}
+	s := NewLazadaAPI(cfg, zap.NewNop()).(*lazadaApi)
+	params := &IReqLazadaAdapter{AppKey: "100", AppSecret: "secret", AccessToken: "token"}
+
+	for _, k := range []string{"app_key", "timestamp", "sign_method", "access_token", "sign"} {
+		business := url.Values{"order_id": {"900"}, k: {"forged"}}
+		if _, err := CallLazada[struct{}](context.Background(), s, "/order/get", params, business); err == nil {
+			t.Errorf("%s: call went through", k)
+		}
+	}
+	if n := hits.Load(); n != 0 {
+		t.Fatalf("%d requests sent, want none", n)
+	}
+
+	if _, err := CallLazada[struct{}](context.Background(), s, "/order/get", params, url.Values{"order_id": {"900"}}); err != nil {
+		t.Fatalf("plain params: %v", err)
+	}
+	if n := hits.Load(); n != 1 {
+		t.Errorf("%d requests sent, want 1", n)
+	}
+}
@@ -0,0 +1,108 @@
+package adapter
+
+import (
+	"net/http"
+	"strings"
+
+	"ecommerce/internal/adapter/dto"
+)
+
+// Lazada failures use the marketplace kinds : match with errors.Is(err, adapter.ErrLazadaXxx)
+var (
+	ErrLazadaInvalidToken        = &MarketplaceErrorKind{Code: "LAZADA_INVALID_TOKEN", Status: http.StatusFailedDependency, msg: "lazada access token is invalid or expired"}
+	ErrLazadaRefreshTokenExpired = &MarketplaceErrorKind{Code: "LAZADA_REFRESH_TOKEN_EXPIRED", Status: http.StatusFailedDependency, msg: "lazada refresh token expired, the seller must be re-authorized"}
+	ErrLazadaRateLimited         = &MarketplaceErrorKind{Code: "LAZADA_RATE_LIMITED", Status: http.StatusTooManyRequests, msg: "lazada rate limit reached"}
+	ErrLazadaInvalidParams       = &MarketplaceErrorKind{Code: "LAZADA_INVALID_PARAMS", Status: http.StatusBadRequest, msg: "lazada rejected the request parameters"}
+	ErrLazadaNotFound            = &MarketplaceErrorKind{Code: "LAZADA_NOT_FOUND", Status: http.StatusNotFound, msg: "lazada resource not found"}
+	ErrLazadaUpstreamUnavailable = &MarketplaceErrorKind{Code: "LAZADA_UPSTREAM_UNAVAILABLE", Status: http.StatusServiceUnavailable, msg: "lazada is unavailable, retry later"}
+)
+
+const (
+	lazadaUpstreamErrorCode   = "LAZADA_UPSTREAM_ERROR"
+	lazadaUpstreamErrorStatus = http.StatusBadGateway
+)
+
+// LazadaAPIError : one failed Lazada call ; unwraps to its kind
+type LazadaAPIError struct {
+	Path       string
+	HTTPStatus int
+	RequestID  string
+	Code       string // Lazada "code"
+	Message    string // Lazada "message"
+	Kind       *MarketplaceErrorKind
+	// Detail : per field / seller sku rejections (product updates)
+	Detail []dto.IResLazadaFieldError
+}
+
+func newLazadaAPIError(path string, httpStatus int, requestID string, code string, message string) *LazadaAPIError {
+	return &LazadaAPIError{
+		Path:       path,
+		HTTPStatus: httpStatus,
+		RequestID:  requestID,
+		Code:       code,
+		Message:    message,
+		Kind:       classifyLazadaError(path, httpStatus, code, message),
+	}
+}
+
+func (e *LazadaAPIError) Error() string {
+	if e.Message == "" {
+		return e.Code
+	}
+	return e.Code + ": " + e.Message
+}
+
+func (e *LazadaAPIError) Unwrap() error {
+	if e.Kind == nil {
+		return nil
+	}
+	return e.Kind
+}
+
+func (e *LazadaAPIError) StatusCode() int {
+	if e.Kind == nil {
+		return lazadaUpstreamErrorStatus
+	}
+	return e.Kind.Status
+}
+
+func (e *LazadaAPIError) ErrorCode() string {
+	if e.Kind == nil {
+		return lazadaUpstreamErrorCode
+	}
+	return e.Kind.Code
+}
+
+func (e *LazadaAPIError) UpstreamRequestID() string { return e.RequestID }
+
+// classifyLazadaError : Lazada "code" / "message" / status -> kind, nil when unknown
+func classifyLazadaError(path string, httpStatus int, code string, message string) *MarketplaceErrorKind {
+	msg := strings.ToLower(message)
+
+	switch {
+	case code == "error_network", code == "ServiceTimeout", code == "ServiceUnavailable",
+		code == "InternalError", code == "ISPError", httpStatus >= 500:
+		return ErrLazadaUpstreamUnavailable
+
+	case code == "ApiCallLimit", code == "AppCallLimit", code == "SellerCallLimit",
+		strings.HasSuffix(code, "CallLimit"), httpStatus == http.StatusTooManyRequests:
+		return ErrLazadaRateLimited
+
+	case code == "IllegalRefreshToken",
+		strings.HasSuffix(path, "/auth/token/refresh") && strings.Contains(msg, "refresh"):
+		return ErrLazadaRefreshTokenExpired
+
+	case code == "IllegalAccessToken", code == "MissingAccessToken", code == "AccessTokenExpired":
+		return ErrLazadaInvalidToken
+
+	case strings.Contains(msg, "not found"), strings.Contains(msg, "invalid order id"),
+		strings.Contains(msg, "invalid order item id"):
+		return ErrLazadaNotFound
+
+	case code == "InvalidCode", code == "IncompleteSignature", code == "InvalidSignature",
+		code == "MissingParameter", code == "InvalidParameter", code == "IllegalParameter",
+		code == "InvalidApiPath", code == "InvalidAppKey", httpStatus == http.StatusBadRequest:
+		return ErrLazadaInvalidParams
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.uber.org/zap"
+)
+
+// offline senders : nothing leaves the machine, tokens can be read back from the log / files
+
+type consoleMailSender struct {
+	From   string
+	Logger *zap.Logger
+}
+
+func NewConsoleMailSender(from string, log *zap.Logger) IMailSender {
+	return &consoleMailSender{From: from, Logger: log}
+}
+
+func (s *consoleMailSender) Send(ctx context.Context, msg *Message) error {
+	if _, err := encode(s.From, msg); err != nil {
+		return err
+	}
+	s.Logger.Info("mail.console.Send",
+		zap.String("from", s.From),
+		zap.String("to", msg.To),
+		zap.String("subject", msg.Subject),
+		zap.String("text", msg.Text),
+	)
+	return nil
+}
+
+type fileMailSender struct {
+	From   string
+	Dir    string
+	Logger *zap.Logger
+}
+
+func NewFileMailSender(from string, dir string, log *zap.Logger) (IMailSender, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(abs, 0o750); err != nil {
+		return nil, fmt.Errorf("mail.NewFileMailSender : %w", err)
+	}
+	return &fileMailSender{From: from, Dir: abs, Logger: log}, nil
+}
+
+func (s *fileMailSender) Send(ctx context.Context, msg *Message) error {
+	body, err := encode(s.From, msg)
+	if err != nil {
+		return err
+	}
+	// time prefix keeps `ls` in sending order
+	name := fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102T150405"), bson.NewObjectID().Hex())
+	path := filepath.Join(s.Dir, name)
+	if err := os.WriteFile(path, body, 0o640); err != nil {
+		return fmt.Errorf("mail.file.Send : %w", err)
+	}
+	s.Logger.Info("mail.file.Send: written", zap.String("to", msg.To), zap.String("path", path))
+	return nil
+}
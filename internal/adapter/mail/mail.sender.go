@@ -0,0 +1,75 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"ecommerce/internal/env"
+)
+
+var ErrInvalidAddress = errors.New("mail : invalid address")
+
+// Message : plain text only, bodies are utf-8 (Thai names included)
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+}
+
+type IMailSender interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+func NewMailSender(cfg *env.Config, log *zap.Logger) (IMailSender, error) {
+	switch cfg.Mail.MailDriver {
+	case "", "console":
+		return NewConsoleMailSender(cfg.Mail.MailFrom, log), nil
+	case "file":
+		return NewFileMailSender(cfg.Mail.MailFrom, cfg.Mail.MailFileDir, log)
+	case "smtp":
+		return NewSMTPMailSender(cfg.Mail, log)
+	default:
+		return nil, fmt.Errorf("mail.NewMailSender : unsupported driver %q", cfg.Mail.MailDriver)
+	}
+}
+
+// encode : RFC 5322 message, the same bytes go to the smtp server and to .eml files
+func encode(from string, msg *Message) ([]byte, error) {
+	if _, err := mail.ParseAddress(from); err != nil {
+		return nil, fmt.Errorf("%w : from %q", ErrInvalidAddress, from)
+	}
+	if _, err := mail.ParseAddress(msg.To); err != nil {
+		return nil, fmt.Errorf("%w : to %q", ErrInvalidAddress, msg.To)
+	}
+	// header injection : subjects come from templates but may carry user input
+	if strings.ContainsAny(msg.Subject, "\r\n") {
+		return nil, errors.New("mail : subject contains a line break")
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	qp := quotedprintable.NewWriter(&buf)
+	if _, err := qp.Write([]byte(strings.ReplaceAll(msg.Text, "\n", "\r\n"))); err != nil {
+		return nil, err
+	}
+	if err := qp.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,64 @@
+package mail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+
+	"go.uber.org/zap"
+
+	"ecommerce/internal/env"
+)
+
+type smtpMailSender struct {
+	Addr   string
+	From   string
+	Auth   smtp.Auth
+	Logger *zap.Logger
+}
+
+// NewSMTPMailSender : STARTTLS is used whenever the server offers it (net/smtp.SendMail),
+// credentials are only sent over TLS or to localhost
+func NewSMTPMailSender(cfg *env.MailConfig, log *zap.Logger) (IMailSender, error) {
+	if cfg.MailSMTPHost == "" {
+		return nil, errors.New("mail.NewSMTPMailSender : MAIL_SMTP_HOST is required")
+	}
+	s := &smtpMailSender{
+		Addr:   net.JoinHostPort(cfg.MailSMTPHost, strconv.Itoa(cfg.MailSMTPPort)),
+		From:   cfg.MailFrom,
+		Logger: log,
+	}
+	if cfg.MailSMTPUsername != "" {
+		s.Auth = smtp.PlainAuth("", cfg.MailSMTPUsername, cfg.MailSMTPPassword, cfg.MailSMTPHost)
+	}
+	return s, nil
+}
+
+func (s *smtpMailSender) Send(ctx context.Context, msg *Message) error {
+	body, err := encode(s.From, msg)
+	if err != nil {
+		return err
+	}
+	from, _ := mail.ParseAddress(s.From)
+	to, _ := mail.ParseAddress(msg.To)
+
+	// net/smtp has no context : run it aside and give up waiting when ctx ends
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(s.Addr, s.Auth, from.Address, []string{to.Address}, body)
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("mail.smtp.Send : %w", err)
+		}
+		s.Logger.Info("mail.smtp.Send: sent", zap.String("to", to.Address), zap.String("subject", msg.Subject))
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,515 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"ecommerce/internal/adapter/dto"
+	"ecommerce/internal/env"
+)
+
+// Lazada behind IMarketplace : order = /order(s)/get + /orders/items/get, stock = price_quantity/update,
+// ship = pack + rts (dropship)
+
+const lazadaTimeLayout = "2006-01-02 15:04:05 -0700"
+
+type lazadaMarketplace struct {
+	Config *env.Config
+	Lazada ILazadaService
+}
+
+func NewLazadaMarketplace(cfg *env.Config, lazada ILazadaService) IMarketplace {
+	return &lazadaMarketplace{Config: cfg, Lazada: lazada}
+}
+
+func (m *lazadaMarketplace) Channel() dto.MarketplaceChannelEnum { return dto.CHANNEL_LAZADA }
+
+func (m *lazadaMarketplace) params(creds *IReqMarketplaceAdapter) *IReqLazadaAdapter {
+	return &IReqLazadaAdapter{
+		AppKey:      creds.AppKey,
+		AppSecret:   creds.AppSecret,
+		AccessToken: creds.AccessToken,
+		Region:      creds.Region,
+	}
+}
+
+func (m *lazadaMarketplace) AuthURL(creds *IReqMarketplaceAdapter, redirectURL string, state string) (string, error) {
+	if creds.AppKey == "" || redirectURL == "" {
+		return "", ErrMarketplaceInvalidParams
+	}
+	u, err := url.Parse(m.Config.Lazada.LazadaAuthorizeUrl)
+	if err != nil {
+		return "", errors.New("error parse url from env")
+	}
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("force_auth", "true")
+	q.Set("redirect_uri", redirectURL)
+	q.Set("client_id", creds.AppKey)
+	if state != "" {
+		q.Set("state", state)
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+func (m *lazadaMarketplace) ExchangeCode(ctx context.Context, creds *IReqMarketplaceAdapter, code string) (*dto.MarketplaceToken, error) {
+	if code == "" {
+		return nil, ErrMarketplaceInvalidParams
+	}
+	res, err := m.Lazada.GetAccessToken(ctx, m.params(creds), code)
+	if err != nil {
+		return nil, err
+	}
+	return lazadaToken(res, creds.ShopID), nil
+}
+
+func (m *lazadaMarketplace) RefreshToken(ctx context.Context, creds *IReqMarketplaceAdapter) (*dto.MarketplaceToken, error) {
+	if creds.RefreshToken == "" {
+		return nil, ErrMarketplaceInvalidParams
+	}
+	res, err := m.Lazada.RefreshAccessToken(ctx, m.params(creds), creds.RefreshToken)
+	if err != nil {
+		return nil, err
+	}
+	return lazadaToken(res, creds.ShopID), nil
+}
+
+// lazadaToken : seller id / country from country_user_info (the seller of the token country,
+// first entry for cross border accounts) ; shopID wins when already known
+func lazadaToken(res *dto.IResLazadaToken, shopID string) *dto.MarketplaceToken {
+	region := strings.ToLower(res.Country)
+	var seller *dto.IResLazadaCountryUserInfo
+	for i := range res.CountryUserInfo {
+		if strings.EqualFold(res.CountryUserInfo[i].Country, res.Country) {
+			seller = &res.CountryUserInfo[i]
+			break
+		}
+	}
+	if seller == nil && len(res.CountryUserInfo) > 0 {
+		seller = &res.CountryUserInfo[0]
+	}
+	if seller != nil {
+		shopID = firstNonEmpty(shopID, seller.SellerID.String())
+		if region == "" || region == "cb" {
+			region = strings.ToLower(seller.Country)
+		}
+	}
+	now := time.Now()
+	return &dto.MarketplaceToken{
+		ShopID:           shopID,
+		Account:          res.Account,
+		Region:           region,
+		AccessToken:      res.AccessToken,
+		RefreshToken:     res.RefreshToken,
+		ExpiresAt:        now.Add(time.Duration(res.ExpiresIn) * time.Second),
+		RefreshExpiresAt: now.Add(time.Duration(res.RefreshExpiresIn) * time.Second),
+	}
+}
+
+func (m *lazadaMarketplace) GetShop(ctx context.Context, creds *IReqMarketplaceAdapter) (*dto.MarketplaceShop, error) {
+	res, err := m.Lazada.GetSeller(ctx, m.params(creds))
+	if err != nil {
+		return nil, err
+	}
+	return &dto.MarketplaceShop{
+		Channel: dto.CHANNEL_LAZADA,
+		ShopID:  firstNonEmpty(res.SellerID.String(), creds.ShopID),
+		Name:    res.Name,
+		Region:  creds.Region,
+		Status:  res.Status,
+	}, nil
+}
+
+// ListOrders : Cursor is the /orders/get offset ; status filtered after mapping (see Shopee)
+func (m *lazadaMarketplace) ListOrders(ctx context.Context, creds *IReqMarketplaceAdapter, q *dto.MarketplaceOrderQuery) (*dto.MarketplaceOrderPage, error) {
+	if q.UpdatedFrom.IsZero() {
+		return nil, ErrMarketplaceInvalidParams
+	}
+	offset := 0
+	if q.Cursor != "" {
+		var err error
+		if offset, err = strconv.Atoi(q.Cursor); err != nil || offset < 0 {
+			return nil, fmt.Errorf("%w : invalid cursor", ErrMarketplaceInvalidParams)
+		}
+	}
+	opts := &dto.IOptionLazadaOrderQuery{
+		UpdateAfter: q.UpdatedFrom.Format(time.RFC3339),
+		Offset:      offset,
+		Limit:       q.PageSize,
+	}
+	if !q.UpdatedTo.IsZero() {
+		opts.UpdateBefore = q.UpdatedTo.Format(time.RFC3339)
+	}
+
+	params := m.params(creds)
+	list, err := m.Lazada.GetOrders(ctx, params, opts)
+	if err != nil {
+		return nil, err
+	}
+	orders, err := m.withItems(ctx, params, creds.ShopID, list.Orders)
+	if err != nil {
+		return nil, err
+	}
+	if q.Status != "" {
+		kept := orders[:0]
+		for _, o := range orders {
+			if o.Status == q.Status {
+				kept = append(kept, o)
+			}
+		}
+		orders = kept
+	}
+
+	page := &dto.MarketplaceOrderPage{Orders: orders}
+	if next := offset + len(list.Orders); len(list.Orders) > 0 && next < list.CountTotal {
+		page.More = true
+		page.NextCursor = strconv.Itoa(next)
+	}
+	return page, nil
+}
+
+func (m *lazadaMarketplace) GetOrders(ctx context.Context, creds *IReqMarketplaceAdapter, orderIDs []string) ([]dto.MarketplaceOrder, error) {
+	params := m.params(creds)
+	raw := make([]dto.IResLazadaOrder, 0, len(orderIDs))
+	for _, id := range orderIDs {
+		o, err := m.Lazada.GetOrder(ctx, params, id)
+		if err != nil {
+			return nil, err
+		}
+		raw = append(raw, *o)
+	}
+	return m.withItems(ctx, params, creds.ShopID, raw)
+}
+
+// withItems : one /orders/items/get per 50 orders
+func (m *lazadaMarketplace) withItems(ctx context.Context, params *IReqLazadaAdapter, shopID string, orders []dto.IResLazadaOrder) ([]dto.MarketplaceOrder, error) {
+	items := map[string][]dto.IResLazadaOrderItem{}
+	for start := 0; start < len(orders); start += LazadaOrderItemsMaxOrder {
+		end := min(start+LazadaOrderItemsMaxOrder, len(orders))
+		ids := make([]string, 0, end-start)
+		for _, o := range orders[start:end] {
+			ids = append(ids, o.OrderID.String())
+		}
+		res, err := m.Lazada.GetMultipleOrderItems(ctx, params, ids)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range res {
+			items[r.OrderID.String()] = r.OrderItems
+		}
+	}
+
+	out := make([]dto.MarketplaceOrder, 0, len(orders))
+	for _, o := range orders {
+		out = append(out, LazadaOrderToMarketplace(shopID, o, items[o.OrderID.String()]))
+	}
+	return out, nil
+}
+
+// ListItems : Cursor is the /products/get offset, one variant per Lazada sku
+func (m *lazadaMarketplace) ListItems(ctx context.Context, creds *IReqMarketplaceAdapter, q *dto.MarketplaceItemQuery) (*dto.MarketplaceItemPage, error) {
+	offset := 0
+	if q.Cursor != "" {
+		var err error
+		if offset, err = strconv.Atoi(q.Cursor); err != nil || offset < 0 {
+			return nil, fmt.Errorf("%w : invalid cursor", ErrMarketplaceInvalidParams)
+		}
+	}
+	opts := &dto.IOptionLazadaProductQuery{Offset: offset, Limit: q.PageSize}
+	if !q.UpdatedFrom.IsZero() {
+		opts.UpdateAfter = q.UpdatedFrom.Format(time.RFC3339)
+	}
+
+	res, err := m.Lazada.GetProducts(ctx, m.params(creds), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]dto.MarketplaceItem, 0, len(res.Products))
+	for _, p := range res.Products {
+		item := dto.MarketplaceItem{ItemID: p.ItemID.String(), Name: p.Name(), Status: p.Status}
+		for _, sku := range p.Skus {
+			price := float64(sku.Price)
+			if sku.SpecialPrice > 0 {
+				price = float64(sku.SpecialPrice)
+			}
+			item.Variants = append(item.Variants, dto.MarketplaceVariant{
+				VariantID: sku.SkuID.String(),
+				SKU:       sku.SellerSku,
+				Name:      strings.Trim(strings.Join([]string{sku.Color, sku.Size}, " / "), " /"),
+				Price:     price,
+				Stock:     sku.Quantity,
+			})
+		}
+		if len(p.Skus) == 1 {
+			item.SKU = p.Skus[0].SellerSku
+		}
+		items = append(items, item)
+	}
+
+	page := &dto.MarketplaceItemPage{Items: items}
+	if next := offset + len(res.Products); len(res.Products) > 0 && next < res.TotalProducts {
+		page.More = true
+		page.NextCursor = strconv.Itoa(next)
+	}
+	return page, nil
+}
+
+// UpdateStock : one price_quantity/update for the whole batch ; Lazada applies the valid skus
+// and lists the rejected ones (by seller sku) in the error detail
+func (m *lazadaMarketplace) UpdateStock(ctx context.Context, creds *IReqMarketplaceAdapter, updates []dto.MarketplaceStockUpdate) ([]dto.MarketplaceStockResult, error) {
+	results := make([]dto.MarketplaceStockResult, len(updates))
+	skus := make([]dto.IBLazadaSkuStock, 0, len(updates))
+	sent := []int{}
+	for i, u := range updates {
+		results[i] = dto.MarketplaceStockResult{ItemID: u.ItemID, VariantID: u.VariantID, SKU: u.SKU}
+		if u.ItemID == "" || (u.SKU == "" && u.VariantID == "") {
+			results[i].Error = "item_id and sku (or variant_id) are required"
+			continue
+		}
+		skus = append(skus, dto.IBLazadaSkuStock{ItemID: u.ItemID, SkuID: u.VariantID, SellerSku: u.SKU, Quantity: u.Quantity})
+		sent = append(sent, i)
+	}
+	if len(skus) == 0 {
+		return results, nil
+	}
+
+	err := m.Lazada.UpdatePriceQuantity(ctx, m.params(creds), skus)
+	var apiErr *LazadaAPIError
+	switch {
+	case err == nil:
+		for _, i := range sent {
+			results[i].OK = true
+		}
+	case errors.As(err, &apiErr) && len(apiErr.Detail) > 0:
+		rejected := map[string]string{}
+		for _, d := range apiErr.Detail {
+			rejected[d.SellerSku] = firstNonEmpty(d.Message, apiErr.Message)
+		}
+		for _, i := range sent {
+			if reason, ko := rejected[updates[i].SKU]; ko {
+				results[i].Error = reason
+				continue
+			}
+			results[i].OK = true
+		}
+	case errors.Is(err, ErrLazadaInvalidParams), errors.Is(err, ErrLazadaNotFound):
+		for _, i := range sent {
+			results[i].Error = err.Error()
+		}
+	default:
+		return nil, err
+	}
+	return results, nil
+}
+
+// ShipOrder : pack the lines still pending (dropship), then rts with the pack (or given) tracking number
+func (m *lazadaMarketplace) ShipOrder(ctx context.Context, creds *IReqMarketplaceAdapter, req *dto.MarketplaceShipRequest) (*dto.MarketplaceShipResult, error) {
+	if req.OrderID == "" {
+		return nil, ErrMarketplaceInvalidParams
+	}
+	params := m.params(creds)
+	items, err := m.Lazada.GetOrderItems(ctx, params, req.OrderID)
+	if err != nil {
+		return nil, err
+	}
+
+	wanted := map[string]bool{}
+	for _, id := range req.LineIDs {
+		wanted[id] = true
+	}
+	var toPack, toShip []string
+	provider, tracking, packageID := req.Carrier, req.TrackingNumber, req.PackageID
+	for _, it := range items {
+		id := it.OrderItemID.String()
+		if len(wanted) > 0 && !wanted[id] {
+			continue
+		}
+		switch it.Status {
+		case "pending", "repacked":
+			toPack = append(toPack, id)
+		case "packed", "ready_to_ship_pending":
+		default:
+			continue
+		}
+		toShip = append(toShip, id)
+		provider = firstNonEmpty(provider, it.ShipmentProvider)
+		tracking = firstNonEmpty(tracking, it.TrackingCode)
+		packageID = firstNonEmpty(packageID, it.PackageID)
+	}
+	if len(toShip) == 0 {
+		return nil, fmt.Errorf("%w : no line of order %s is waiting to be shipped", ErrMarketplaceInvalidParams, req.OrderID)
+	}
+
+	if len(toPack) > 0 {
+		packed, err := m.Lazada.PackOrder(ctx, params, &dto.IBLazadaPack{OrderItemIDs: toPack, ShippingProvider: provider})
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range packed.OrderItems {
+			if req.TrackingNumber == "" && p.TrackingNumber != "" {
+				tracking = p.TrackingNumber
+			}
+			provider = firstNonEmpty(p.ShipmentProvider, provider)
+			packageID = firstNonEmpty(p.PackageID, packageID)
+		}
+	}
+	if tracking == "" {
+		return nil, fmt.Errorf("%w : lazada gave no tracking number, pass one", ErrMarketplaceInvalidParams)
+	}
+
+	if err := m.Lazada.ReadyToShip(ctx, params, &dto.IBLazadaReadyToShip{
+		OrderItemIDs:     toShip,
+		ShipmentProvider: provider,
+		TrackingNumber:   tracking,
+	}); err != nil {
+		return nil, err
+	}
+	return &dto.MarketplaceShipResult{OrderID: req.OrderID, PackageID: packageID, TrackingNumber: tracking}, nil
+}
+
+// LazadaOrderStatusToMarketplace : Lazada keeps one status per line ; the order takes the
+// status of its live lines, CANCELLED only when every line is
+func LazadaOrderStatusToMarketplace(statuses []string) dto.MarketplaceOrderStatusEnum {
+	status := dto.MP_ORDER_UNKNOWN
+	for _, st := range statuses {
+		mapped := lazadaLineStatus(st)
+		if mapped == dto.MP_ORDER_CANCELLED {
+			if status == dto.MP_ORDER_UNKNOWN {
+				status = mapped
+			}
+			continue
+		}
+		if status == dto.MP_ORDER_UNKNOWN || status == dto.MP_ORDER_CANCELLED {
+			status = mapped
+		}
+	}
+	return status
+}
+
+func lazadaLineStatus(status string) dto.MarketplaceOrderStatusEnum {
+	switch strings.ToLower(status) {
+	case "unpaid":
+		return dto.MP_ORDER_UNPAID
+	case "pending", "packed", "repacked", "ready_to_ship_pending", "ready_to_ship", "topack", "toship":
+		return dto.MP_ORDER_READY_TO_SHIP
+	case "shipped", "shipped_back_pending":
+		return dto.MP_ORDER_SHIPPED
+	case "delivered":
+		return dto.MP_ORDER_DELIVERED
+	case "confirmed":
+		return dto.MP_ORDER_COMPLETED
+	case "canceled", "cancelled":
+		return dto.MP_ORDER_CANCELLED
+	case "returned", "failed_delivery", "shipped_back", "shipped_back_success", "lost_by_3pl", "damaged_by_3pl":
+		return dto.MP_ORDER_RETURNED
+	}
+	return dto.MP_ORDER_UNKNOWN
+}
+
+func LazadaOrderToMarketplace(shopID string, o dto.IResLazadaOrder, items []dto.IResLazadaOrderItem) dto.MarketplaceOrder {
+	a := o.AddressShipping
+	lines := []string{}
+	for _, l := range []string{a.Address1, a.Address2, a.Address5, a.Address4, a.Address3} {
+		if strings.TrimSpace(l) != "" {
+			lines = append(lines, strings.TrimSpace(l))
+		}
+	}
+
+	out := dto.MarketplaceOrder{
+		Channel:       dto.CHANNEL_LAZADA,
+		ShopID:        shopID,
+		OrderID:       o.OrderID.String(),
+		OrderNumber:   firstNonEmpty(o.OrderNumber.String(), o.OrderID.String()),
+		Status:        LazadaOrderStatusToMarketplace(o.Statuses),
+		ChannelStatus: strings.Join(o.Statuses, ","),
+		TotalAmount:   float64(o.Price),
+		ShippingFee:   float64(o.ShippingFee),
+		// shipping_fee is charged to the buyer ; seller vouchers are already in the item paid_price
+		BuyerShippingFee: float64(o.ShippingFee),
+		PaymentMethod:    o.PaymentMethod,
+		COD:              strings.EqualFold(o.PaymentMethod, "COD"),
+		BuyerName:        strings.TrimSpace(o.CustomerFirstName + " " + o.CustomerLastName),
+		BuyerNote:        firstNonEmpty(o.Remarks, o.GiftMessage),
+		ShippingAddress: dto.MarketplaceAddress{
+			Name:        strings.TrimSpace(a.FirstName + " " + a.LastName),
+			Phone:       a.Phone,
+			FullAddress: strings.Join(lines, ", "),
+			District:    a.Address4,
+			City:        a.City,
+			State:       a.Address3,
+			PostCode:    a.PostCode,
+			Country:     a.Country,
+		},
+		CreatedAt: lazadaTime(o.CreatedAt),
+		UpdatedAt: lazadaTime(o.UpdatedAt),
+		ShipBy:    lazadaTime(o.PromisedShippingAt),
+	}
+
+	packages := map[string]int{}
+	for _, it := range items {
+		id := it.OrderItemID.String()
+		out.Currency = firstNonEmpty(out.Currency, it.Currency)
+		if out.PaidAt.IsZero() {
+			out.PaidAt = lazadaTime(it.PaidAt)
+		}
+		// one row per unit on Lazada : quantity is always 1
+		out.Items = append(out.Items, dto.MarketplaceOrderItem{
+			LineID:      id,
+			ItemID:      it.ProductID.String(),
+			VariantID:   it.SkuID.String(),
+			SKU:         it.Sku,
+			Name:        it.Name,
+			VariantName: it.Variation,
+			Quantity:    1,
+			UnitPrice:   float64(it.ItemPrice),
+			PaidPrice:   float64(it.PaidPrice),
+			ImageURL:    it.ProductMainImage,
+			PackageID:   it.PackageID,
+		})
+		if it.PackageID == "" {
+			continue
+		}
+		idx, ok := packages[it.PackageID]
+		if !ok {
+			idx = len(out.Packages)
+			packages[it.PackageID] = idx
+			out.Packages = append(out.Packages, dto.MarketplacePackage{
+				PackageID:      it.PackageID,
+				Carrier:        it.ShipmentProvider,
+				TrackingNumber: it.TrackingCode,
+				Status:         it.Status,
+			})
+		}
+		out.Packages[idx].LineIDs = append(out.Packages[idx].LineIDs, id)
+	}
+	return out
+}
+
+// lazadaTime : "" / unparsable -> zero time
+func lazadaTime(s string) time.Time {
+	if s == "" {
+		return time.Time{}
+	}
+	for _, layout := range []string{lazadaTimeLayout, time.RFC3339, "2006-01-02 15:04:05"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,107 @@
+package adapter
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+
+	"ecommerce/internal/adapter/dto"
+)
+
+// IMarketplace : what the ERP side needs from a sales channel (auth, shop, orders, items, stock, shipping).
+// One implementation per channel ; the channel client (IShopeeService, ILazadaService, ...) stays
+// available for everything that has no neutral form (returns, escrow, labels, ...).
+type IMarketplace interface {
+	Channel() dto.MarketplaceChannelEnum
+
+	// seller consent page ; state comes back untouched on the redirect
+	AuthURL(creds *IReqMarketplaceAdapter, redirectURL string, state string) (string, error)
+	// code from the consent redirect -> tokens (Shopee also needs creds.ShopID)
+	ExchangeCode(ctx context.Context, creds *IReqMarketplaceAdapter, code string) (*dto.MarketplaceToken, error)
+	// creds.RefreshToken -> new tokens
+	RefreshToken(ctx context.Context, creds *IReqMarketplaceAdapter) (*dto.MarketplaceToken, error)
+
+	GetShop(ctx context.Context, creds *IReqMarketplaceAdapter) (*dto.MarketplaceShop, error)
+
+	// one page of orders updated in the query window, with items
+	ListOrders(ctx context.Context, creds *IReqMarketplaceAdapter, q *dto.MarketplaceOrderQuery) (*dto.MarketplaceOrderPage, error)
+	GetOrders(ctx context.Context, creds *IReqMarketplaceAdapter, orderIDs []string) ([]dto.MarketplaceOrder, error)
+
+	ListItems(ctx context.Context, creds *IReqMarketplaceAdapter, q *dto.MarketplaceItemQuery) (*dto.MarketplaceItemPage, error)
+	// absolute quantities ; one result per update, a rejected unit does not fail the others
+	UpdateStock(ctx context.Context, creds *IReqMarketplaceAdapter, updates []dto.MarketplaceStockUpdate) ([]dto.MarketplaceStockResult, error)
+
+	ShipOrder(ctx context.Context, creds *IReqMarketplaceAdapter, req *dto.MarketplaceShipRequest) (*dto.MarketplaceShipResult, error)
+}
+
+// IReqMarketplaceAdapter : credentials of one shop on one channel
+// (Shopee : AppKey = partner_id, AppSecret = partner key ; Lazada : app_key / app_secret)
+type IReqMarketplaceAdapter struct {
+	AppKey       string
+	AppSecret    string
+	ShopID       string
+	AccessToken  string
+	RefreshToken string
+	Region       string // Lazada : api host (th, my, sg, ...), "" = config default
+}
+
+// MarketplaceErrorKind : channel-neutral failures of the port ; channel errors keep their own kinds
+// (ShopeeErrorKind, LazadaErrorKind)
+type MarketplaceErrorKind struct {
+	Code   string
+	Status int
+	msg    string
+}
+
+func (k *MarketplaceErrorKind) Error() string     { return k.msg }
+func (k *MarketplaceErrorKind) StatusCode() int   { return k.Status }
+func (k *MarketplaceErrorKind) ErrorCode() string { return k.Code }
+
+var (
+	ErrMarketplaceUnsupported    = &MarketplaceErrorKind{Code: "MARKETPLACE_UNSUPPORTED", Status: http.StatusBadRequest, msg: "marketplace channel is not supported"}
+	ErrMarketplaceNotImplemented = &MarketplaceErrorKind{Code: "MARKETPLACE_NOT_IMPLEMENTED", Status: http.StatusNotImplemented, msg: "operation is not available on this channel"}
+	ErrMarketplaceInvalidParams  = &MarketplaceErrorKind{Code: "MARKETPLACE_INVALID_PARAMS", Status: http.StatusBadRequest, msg: "invalid marketplace request"}
+)
+
+// MarketplaceRegistry : channel -> implementation, built once in the container
+type MarketplaceRegistry struct {
+	channels map[dto.MarketplaceChannelEnum]IMarketplace
+}
+
+func NewMarketplaceRegistry(markets ...IMarketplace) *MarketplaceRegistry {
+	r := &MarketplaceRegistry{channels: map[dto.MarketplaceChannelEnum]IMarketplace{}}
+	for _, m := range markets {
+		if m != nil {
+			r.channels[m.Channel()] = m
+		}
+	}
+	return r
+}
+
+// ParseMarketplaceChannel : "shopee" / "SHOPEE" -> CHANNEL_SHOPEE
+func ParseMarketplaceChannel(s string) (dto.MarketplaceChannelEnum, bool) {
+	switch ch := dto.MarketplaceChannelEnum(strings.ToUpper(strings.TrimSpace(s))); ch {
+	case dto.CHANNEL_SHOPEE, dto.CHANNEL_LAZADA, dto.CHANNEL_TIKTOK:
+		return ch, true
+	}
+	return "", false
+}
+
+// Get : ErrMarketplaceUnsupported for a channel without implementation (TikTok Shop for now)
+func (r *MarketplaceRegistry) Get(channel dto.MarketplaceChannelEnum) (IMarketplace, error) {
+	m, ok := r.channels[channel]
+	if !ok {
+		return nil, ErrMarketplaceUnsupported
+	}
+	return m, nil
+}
+
+func (r *MarketplaceRegistry) Channels() []dto.MarketplaceChannelEnum {
+	out := make([]dto.MarketplaceChannelEnum, 0, len(r.channels))
+	for ch := range r.channels {
+		out = append(out, ch)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
@@ -0,0 +1,533 @@
+package adapter
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"ecommerce/internal/adapter/dto"
+	"ecommerce/internal/env"
+)
+
+// Shopee behind IMarketplace : thin mapping over IShopeeService, no extra call logic
+// (signing, rate limit, retry, breaker and error kinds all come from the Shopee client)
+
+const (
+	shopeeAuthPartnerPath    = "/api/v2/shop/auth_partner"
+	shopeeOrderMaxWindow     = 15 * 24 * time.Hour // get_order_list time_from / time_to
+	shopeeOrderDetailMaxSN   = 50                  // get_order_detail order_sn_list
+	shopeeRefreshTokenMaxAge = 30 * 24 * time.Hour
+)
+
+type shopeeMarketplace struct {
+	Config *env.Config
+	Shopee IShopeeService
+}
+
+func NewShopeeMarketplace(cfg *env.Config, shopee IShopeeService) IMarketplace {
+	return &shopeeMarketplace{Config: cfg, Shopee: shopee}
+}
+
+func (m *shopeeMarketplace) Channel() dto.MarketplaceChannelEnum { return dto.CHANNEL_SHOPEE }
+
+func (m *shopeeMarketplace) params(creds *IReqMarketplaceAdapter) *IReqShopeeAdapter {
+	return &IReqShopeeAdapter{
+		PartnerID:   creds.AppKey,
+		SecretKey:   creds.AppSecret,
+		ShopID:      creds.ShopID,
+		AccessToken: creds.AccessToken,
+	}
+}
+
+// AuthURL : shop/auth_partner, signed partner_id + path + timestamp
+func (m *shopeeMarketplace) AuthURL(creds *IReqMarketplaceAdapter, redirectURL string, state string) (string, error) {
+	if creds.AppKey == "" || creds.AppSecret == "" || redirectURL == "" {
+		return "", ErrMarketplaceInvalidParams
+	}
+	u, err := url.Parse(m.Config.Shopee.ShopeeApiBaseUrl)
+	if err != nil {
+		return "", errors.New("error parse url from env")
+	}
+	// Shopee has no state parameter : carried on the redirect url
+	if state != "" {
+		r, err := url.Parse(redirectURL)
+		if err != nil {
+			return "", ErrMarketplaceInvalidParams
+		}
+		rq := r.Query()
+		rq.Set("state", state)
+		r.RawQuery = rq.Encode()
+		redirectURL = r.String()
+	}
+
+	timest := strconv.FormatInt(time.Now().Unix(), 10)
+	h := hmac.New(sha256.New, []byte(creds.AppSecret))
+	h.Write([]byte(creds.AppKey + shopeeAuthPartnerPath + timest))
+
+	q := url.Values{}
+	q.Set("partner_id", creds.AppKey)
+	q.Set("timestamp", timest)
+	q.Set("sign", hex.EncodeToString(h.Sum(nil)))
+	q.Set("redirect", redirectURL)
+	u.Path = shopeeAuthPartnerPath
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+func (m *shopeeMarketplace) ExchangeCode(ctx context.Context, creds *IReqMarketplaceAdapter, code string) (*dto.MarketplaceToken, error) {
+	if creds.ShopID == "" || code == "" {
+		return nil, ErrMarketplaceInvalidParams
+	}
+	params := m.params(creds)
+	params.Code = &code
+	res, err := m.Shopee.GetAccessToken(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	return &dto.MarketplaceToken{
+		ShopID:           creds.ShopID,
+		AccessToken:      res.AccessToken,
+		RefreshToken:     res.RefreshToken,
+		ExpiresAt:        now.Add(time.Duration(res.ExpireIn) * time.Second),
+		RefreshExpiresAt: now.Add(shopeeRefreshTokenMaxAge),
+	}, nil
+}
+
+func (m *shopeeMarketplace) RefreshToken(ctx context.Context, creds *IReqMarketplaceAdapter) (*dto.MarketplaceToken, error) {
+	if creds.ShopID == "" || creds.RefreshToken == "" {
+		return nil, ErrMarketplaceInvalidParams
+	}
+	res, err := m.Shopee.GetRefreshToken(ctx, m.params(creds), creds.RefreshToken)
+	if err != nil {
+		return nil, err
+	}
+	expireIn, _ := res.ExpireIn.Int64()
+	now := time.Now()
+	return &dto.MarketplaceToken{
+		ShopID:           creds.ShopID,
+		AccessToken:      res.AccessToken,
+		RefreshToken:     res.RefreshToken,
+		ExpiresAt:        now.Add(time.Duration(expireIn) * time.Second),
+		RefreshExpiresAt: now.Add(shopeeRefreshTokenMaxAge),
+	}, nil
+}
+
+func (m *shopeeMarketplace) GetShop(ctx context.Context, creds *IReqMarketplaceAdapter) (*dto.MarketplaceShop, error) {
+	res, err := m.Shopee.GetShopInfo(ctx, m.params(creds))
+	if err != nil {
+		return nil, err
+	}
+	return &dto.MarketplaceShop{
+		Channel: dto.CHANNEL_SHOPEE,
+		ShopID:  creds.ShopID,
+		Name:    res.ShopName,
+		Region:  res.Region,
+		Status:  res.Status,
+	}, nil
+}
+
+// ListOrders : get_order_list (update_time, max 15 days) + get_order_detail ;
+// the status filter is applied after mapping since one unified status covers several Shopee ones
+func (m *shopeeMarketplace) ListOrders(ctx context.Context, creds *IReqMarketplaceAdapter, q *dto.MarketplaceOrderQuery) (*dto.MarketplaceOrderPage, error) {
+	if q.UpdatedFrom.IsZero() || q.UpdatedTo.IsZero() || !q.UpdatedTo.After(q.UpdatedFrom) {
+		return nil, ErrMarketplaceInvalidParams
+	}
+	if q.UpdatedTo.Sub(q.UpdatedFrom) > shopeeOrderMaxWindow {
+		return nil, fmt.Errorf("%w : shopee order window is max 15 days", ErrMarketplaceInvalidParams)
+	}
+
+	page, err := m.Shopee.GetOrderListPageByShopID(ctx, m.params(creds), &dto.IOptionShopeeQuery{
+		TimeRange:  dto.UPDATE_TIME,
+		TimeFrom:   q.UpdatedFrom.Unix(),
+		TimeTo:     q.UpdatedTo.Unix(),
+		PageSize:   int32(q.PageSize),
+		CursorPage: q.Cursor,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sn := make([]string, 0, len(page.OrderList))
+	for _, o := range page.OrderList {
+		sn = append(sn, o.OrderSN)
+	}
+	orders, err := m.GetOrders(ctx, creds, sn)
+	if err != nil {
+		return nil, err
+	}
+	if q.Status != "" {
+		kept := orders[:0]
+		for _, o := range orders {
+			if o.Status == q.Status {
+				kept = append(kept, o)
+			}
+		}
+		orders = kept
+	}
+
+	return &dto.MarketplaceOrderPage{Orders: orders, More: page.More, NextCursor: page.NextCursor}, nil
+}
+
+func (m *shopeeMarketplace) GetOrders(ctx context.Context, creds *IReqMarketplaceAdapter, orderIDs []string) ([]dto.MarketplaceOrder, error) {
+	out := make([]dto.MarketplaceOrder, 0, len(orderIDs))
+	for start := 0; start < len(orderIDs); start += shopeeOrderDetailMaxSN {
+		end := min(start+shopeeOrderDetailMaxSN, len(orderIDs))
+		params := m.params(creds)
+		params.OrderSN = orderIDs[start:end]
+		details, err := m.Shopee.GetOrderDetailListByOrderSN(ctx, params)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range details {
+			out = append(out, ShopeeOrderToMarketplace(creds.ShopID, d))
+		}
+	}
+	return out, nil
+}
+
+// ListItems : Cursor is the get_item_list offset ; one get_model_list per item with variants
+func (m *shopeeMarketplace) ListItems(ctx context.Context, creds *IReqMarketplaceAdapter, q *dto.MarketplaceItemQuery) (*dto.MarketplaceItemPage, error) {
+	offset := int64(0)
+	if q.Cursor != "" {
+		var err error
+		if offset, err = strconv.ParseInt(q.Cursor, 10, 32); err != nil || offset < 0 {
+			return nil, fmt.Errorf("%w : invalid cursor", ErrMarketplaceInvalidParams)
+		}
+	}
+	opts := &dto.IOptionShopeeItemListQuery{
+		Offset:     int32(offset),
+		PageSize:   int32(q.PageSize),
+		ItemStatus: []dto.IEnumShopeeItemStatus{dto.ITEM_NORMAL, dto.ITEM_UNLIST},
+	}
+	if !q.UpdatedFrom.IsZero() {
+		opts.UpdateTimeFrom = q.UpdatedFrom.Unix()
+		opts.UpdateTimeTo = time.Now().Unix()
+	}
+
+	params := m.params(creds)
+	list, err := m.Shopee.GetItemListByShopID(ctx, params, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int64, 0, len(list.Item))
+	for _, it := range list.Item {
+		ids = append(ids, it.ItemID)
+	}
+	items := make([]dto.MarketplaceItem, 0, len(ids))
+	for start := 0; start < len(ids); start += ShopeeItemBaseInfoMaxItem {
+		end := min(start+ShopeeItemBaseInfoMaxItem, len(ids))
+		base, err := m.Shopee.GetItemBaseInfo(ctx, params, ids[start:end])
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range base {
+			item := dto.MarketplaceItem{
+				ItemID: strconv.FormatInt(b.ItemID, 10),
+				Name:   b.ItemName,
+				SKU:    b.ItemSKU,
+				Status: b.ItemStatus,
+			}
+			if !b.HasModel {
+				item.Variants = []dto.MarketplaceVariant{{
+					SKU:   b.ItemSKU,
+					Name:  b.ItemName,
+					Price: shopeeCurrentPrice(b.PriceInfo),
+					Stock: shopeeSellerStock(b.StockInfoV2),
+				}}
+				items = append(items, item)
+				continue
+			}
+			models, err := m.Shopee.GetModelList(ctx, params, b.ItemID)
+			if err != nil {
+				return nil, err
+			}
+			for _, md := range models.Model {
+				item.Variants = append(item.Variants, dto.MarketplaceVariant{
+					VariantID: strconv.FormatInt(md.ModelID, 10),
+					SKU:       md.ModelSKU,
+					Name:      shopeeModelName(models.TierVariation, md.TierIndex),
+					Price:     shopeeCurrentPrice(md.PriceInfo),
+					Stock:     shopeeSellerStock(md.StockInfoV2),
+				})
+			}
+			items = append(items, item)
+		}
+	}
+
+	page := &dto.MarketplaceItemPage{Items: items, More: list.HasNextPage}
+	if list.HasNextPage {
+		page.NextCursor = strconv.FormatInt(int64(list.NextOffset), 10)
+	}
+	return page, nil
+}
+
+// UpdateStock : one update_stock per item, models of the same item grouped
+func (m *shopeeMarketplace) UpdateStock(ctx context.Context, creds *IReqMarketplaceAdapter, updates []dto.MarketplaceStockUpdate) ([]dto.MarketplaceStockResult, error) {
+	results := make([]dto.MarketplaceStockResult, len(updates))
+	byItem := map[int64][]int{}
+	order := []int64{}
+	for i, u := range updates {
+		results[i] = dto.MarketplaceStockResult{ItemID: u.ItemID, VariantID: u.VariantID, SKU: u.SKU}
+		itemID, err := strconv.ParseInt(u.ItemID, 10, 64)
+		if err != nil {
+			results[i].Error = "invalid item_id"
+			continue
+		}
+		if u.VariantID != "" {
+			if _, err := strconv.ParseInt(u.VariantID, 10, 64); err != nil {
+				results[i].Error = "invalid variant_id"
+				continue
+			}
+		}
+		if _, ok := byItem[itemID]; !ok {
+			order = append(order, itemID)
+		}
+		byItem[itemID] = append(byItem[itemID], i)
+	}
+
+	params := m.params(creds)
+	for _, itemID := range order {
+		idx := byItem[itemID]
+		body := &dto.IBUpdateStock{ItemID: itemID}
+		for _, i := range idx {
+			modelID, _ := strconv.ParseInt(updates[i].VariantID, 10, 64)
+			body.StockList = append(body.StockList, dto.IBItemStock{
+				ModelID:     modelID,
+				SellerStock: []dto.IResItemSellerStock{{Stock: updates[i].Quantity}},
+			})
+		}
+
+		res, err := m.Shopee.UpdateStock(ctx, params, body)
+		if err != nil {
+			// whole call rejected : an outage / dead token fails the batch, a bad item only itself
+			if !errors.Is(err, ErrShopeeInvalidParams) && !errors.Is(err, ErrShopeeNotFound) {
+				return nil, err
+			}
+			for _, i := range idx {
+				results[i].Error = err.Error()
+			}
+			continue
+		}
+
+		failed := map[int64]string{}
+		for _, f := range res.FailureList {
+			failed[f.ModelID] = f.FailedReason
+		}
+		for _, i := range idx {
+			modelID, _ := strconv.ParseInt(updates[i].VariantID, 10, 64)
+			if reason, ko := failed[modelID]; ko {
+				results[i].Error = reason
+				continue
+			}
+			results[i].OK = true
+		}
+	}
+	return results, nil
+}
+
+// ShipOrder : tracking number given -> non_integrated, otherwise pickup at the default
+// address (first slot) when the channel offers it, dropoff else
+func (m *shopeeMarketplace) ShipOrder(ctx context.Context, creds *IReqMarketplaceAdapter, req *dto.MarketplaceShipRequest) (*dto.MarketplaceShipResult, error) {
+	if req.OrderID == "" {
+		return nil, ErrMarketplaceInvalidParams
+	}
+	params := m.params(creds)
+	body := &dto.IBShipOrder{OrderSN: req.OrderID, PackageNumber: req.PackageID}
+
+	if req.TrackingNumber != "" {
+		body.NonIntegrated = &dto.IBShipNonIntegrated{TrackingNumber: req.TrackingNumber}
+	} else {
+		sp, err := m.Shopee.GetShippingParameter(ctx, params, req.OrderID, req.PackageID)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case sp.InfoNeeded.Pickup != nil && len(sp.Pickup.AddressList) > 0:
+			body.Pickup = shopeeDefaultPickup(sp.Pickup.AddressList)
+		case sp.InfoNeeded.Dropoff != nil:
+			body.Dropoff = &dto.IBShipDropoff{}
+			if len(sp.Dropoff.BranchList) > 0 {
+				body.Dropoff.BranchID = sp.Dropoff.BranchList[0].BranchID
+			}
+		default:
+			return nil, fmt.Errorf("%w : order needs a tracking number (non integrated channel)", ErrMarketplaceInvalidParams)
+		}
+	}
+
+	if err := m.Shopee.ShipOrder(ctx, params, body); err != nil {
+		return nil, err
+	}
+
+	res := &dto.MarketplaceShipResult{OrderID: req.OrderID, PackageID: req.PackageID, TrackingNumber: req.TrackingNumber}
+	if res.TrackingNumber == "" {
+		// usually not assigned yet right after ship_order : the label flow polls it
+		if tn, err := m.Shopee.GetTrackingNumber(ctx, params, req.OrderID, req.PackageID); err == nil {
+			res.TrackingNumber = tn.TrackingNumber
+		}
+	}
+	return res, nil
+}
+
+// ShopeeOrderStatusToMarketplace : Shopee order_status -> unified status
+func ShopeeOrderStatusToMarketplace(status string) dto.MarketplaceOrderStatusEnum {
+	switch status {
+	case "UNPAID", "INVOICE_PENDING":
+		return dto.MP_ORDER_UNPAID
+	case "READY_TO_SHIP", "PROCESSED", "RETRY_SHIP":
+		return dto.MP_ORDER_READY_TO_SHIP
+	case "SHIPPED":
+		return dto.MP_ORDER_SHIPPED
+	case "TO_CONFIRM_RECEIVE":
+		return dto.MP_ORDER_DELIVERED
+	case "COMPLETED":
+		return dto.MP_ORDER_COMPLETED
+	case "IN_CANCEL":
+		return dto.MP_ORDER_IN_CANCEL
+	case "CANCELLED":
+		return dto.MP_ORDER_CANCELLED
+	case "TO_RETURN":
+		return dto.MP_ORDER_RETURNED
+	}
+	return dto.MP_ORDER_UNKNOWN
+}
+
+func ShopeeOrderToMarketplace(shopID string, d dto.IResOrderListWithDetails) dto.MarketplaceOrder {
+	shippingFee := d.EstimatedShippingFee
+	if d.ActualShippingFeeConfirmed {
+		shippingFee = d.ActualShippingFee
+	}
+	o := dto.MarketplaceOrder{
+		Channel:            dto.CHANNEL_SHOPEE,
+		ShopID:             shopID,
+		OrderID:            d.OrderSN,
+		OrderNumber:        d.OrderSN,
+		Status:             ShopeeOrderStatusToMarketplace(d.OrderStatus),
+		ChannelStatus:      d.OrderStatus,
+		Currency:           d.Currency,
+		TotalAmount:        d.TotalAmount,
+		ShippingFee:        shippingFee,
+		PaymentMethod:      d.PaymentMethod,
+		COD:                d.COD,
+		FulfilledByChannel: d.FulfillmentFlag == "fulfilled_by_shopee",
+		BuyerName:          d.BuyerUsername,
+		BuyerNote:          d.MessageToSeller,
+		ShippingAddress: dto.MarketplaceAddress{
+			Name:        d.RecipientAddress.Name,
+			Phone:       d.RecipientAddress.Phone,
+			FullAddress: d.RecipientAddress.FullAddress,
+			District:    d.RecipientAddress.District,
+			City:        d.RecipientAddress.City,
+			State:       d.RecipientAddress.State,
+			PostCode:    d.RecipientAddress.Zipcode,
+			Country:     d.RecipientAddress.Region,
+		},
+		CreatedAt: unixTime(d.CreateTime),
+		UpdatedAt: unixTime(d.UpdateTime),
+		PaidAt:    unixTime(d.PayTime),
+		ShipBy:    unixTime(d.ShipByDate),
+	}
+
+	// order_item_id is shared by the lines of a bundle : item_id/model_id identifies the line
+	lineID := func(itemID int64, modelID int64) string {
+		return strconv.FormatInt(itemID, 10) + ":" + strconv.FormatInt(modelID, 10)
+	}
+	packageOf := map[string]string{}
+	for _, p := range d.PackageList {
+		pkg := dto.MarketplacePackage{
+			PackageID: p.PackageNumber,
+			Carrier:   p.ShippingCarrier,
+			Status:    p.LogisticsStatus,
+		}
+		for _, it := range p.ItemList {
+			id := lineID(it.ItemID, it.ModelID)
+			pkg.LineIDs = append(pkg.LineIDs, id)
+			packageOf[id] = p.PackageNumber
+		}
+		o.Packages = append(o.Packages, pkg)
+	}
+
+	for _, it := range d.ItemList {
+		id := lineID(it.ItemID, it.ModelID)
+		sku := it.ModelSKU
+		if sku == "" {
+			sku = it.ItemSKU
+		}
+		variantID := ""
+		if it.ModelID != 0 {
+			variantID = strconv.FormatInt(it.ModelID, 10)
+		}
+		o.Items = append(o.Items, dto.MarketplaceOrderItem{
+			LineID:      id,
+			ItemID:      strconv.FormatInt(it.ItemID, 10),
+			VariantID:   variantID,
+			SKU:         sku,
+			Name:        it.ItemName,
+			VariantName: it.ModelName,
+			Quantity:    it.ModelQtyPurchased,
+			UnitPrice:   it.ModelOriginalPrice,
+			PaidPrice:   it.ModelDiscountedPrice,
+			ImageURL:    it.ImageInfo.ImageURL,
+			PackageID:   packageOf[id],
+		})
+	}
+	return o
+}
+
+func shopeeDefaultPickup(addresses []dto.IResShippingPickupAddress) *dto.IBShipPickup {
+	addr := addresses[0]
+	for _, a := range addresses {
+		for _, flag := range a.AddressFlag {
+			if flag == "default_address" || flag == "pickup_address" {
+				addr = a
+			}
+		}
+	}
+	pickup := &dto.IBShipPickup{AddressID: addr.AddressID}
+	if len(addr.TimeSlotList) > 0 {
+		pickup.PickupTimeID = addr.TimeSlotList[0].PickupTimeID
+	}
+	return pickup
+}
+
+func shopeeCurrentPrice(prices []dto.IResItemPriceInfo) float64 {
+	if len(prices) == 0 {
+		return 0
+	}
+	return prices[0].CurrentPrice
+}
+
+func shopeeSellerStock(stock dto.IResItemStockInfoV2) int64 {
+	var total int64
+	for _, s := range stock.SellerStock {
+		total += s.Stock
+	}
+	return total
+}
+
+// shopeeModelName : "Red / XL" from tier_variation + tier_index
+func shopeeModelName(tiers []dto.IResItemTierVariation, index []int) string {
+	names := make([]string, 0, len(index))
+	for t, i := range index {
+		if t < len(tiers) && i >= 0 && i < len(tiers[t].OptionList) {
+			names = append(names, tiers[t].OptionList[i].Option)
+		}
+	}
+	return strings.Join(names, " / ")
+}
+
+// unixTime : 0 -> zero time (field not set by the channel)
+func unixTime(sec int64) time.Time {
+	if sec <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0)
+}
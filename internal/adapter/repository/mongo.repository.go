@@ -1,8 +1,20 @@
 package repository
 
 import (
+	"ecommerce/internal/application/auth"
+	"ecommerce/internal/application/fulfillment"
+	"ecommerce/internal/application/inventory"
+	"ecommerce/internal/application/invoice"
+	"ecommerce/internal/application/marketplace"
+	"ecommerce/internal/application/product"
+	"ecommerce/internal/application/purchase"
 	"ecommerce/internal/application/shopee"
+	"ecommerce/internal/application/shopee/label"
 	"ecommerce/internal/application/shopee/partner"
+	"ecommerce/internal/application/shopee/push"
+	"ecommerce/internal/application/shopee/returns"
+	"ecommerce/internal/application/stocksync"
+	"ecommerce/internal/application/tenant"
 	"ecommerce/internal/application/users"
 )
 
@@ -13,6 +25,35 @@ type IMongoCollectionRepository interface {
   UsersCollection() users.UserRepository
   ShopeeShopCollection() shopee.ShopeeShopDetailsRepository
   ShopeeOrderCollection() shopee.ShopeeOrderRepository
+  ShopeeOrderSyncCollection() shopee.ShopeeOrderSyncRepository
+  ShopeePushEventCollection() push.ShopeePushEventRepository
+  ShopeeLabelCollection() label.ShopeeLabelRepository
+  ShopeeReturnCollection() returns.ShopeeReturnRepository
+  MarketplaceAppCollection() marketplace.MarketplaceAppRepository
+  MarketplaceShopAuthCollection() marketplace.MarketplaceShopAuthRepository
+  MarketplaceOrderCollection() marketplace.MarketplaceOrderRepository
+  ProductCollection() product.ProductRepository
+  SKUMappingCollection() product.SKUMappingRepository
+  WarehouseCollection() inventory.WarehouseRepository
+  InventoryBalanceCollection() inventory.BalanceRepository
+  InventoryMovementCollection() inventory.MovementRepository
+  InventoryReservationCollection() inventory.ReservationRepository
+  StockSyncRuleCollection() stocksync.StockSyncRuleRepository
+  StockPushLogCollection() stocksync.StockPushLogRepository
+  SupplierCollection() purchase.SupplierRepository
+  PurchaseOrderCollection() purchase.PurchaseOrderRepository
+  GoodsReceiptCollection() purchase.GoodsReceiptRepository
+  PurchaseSequenceCollection() purchase.SequenceRepository
+  WaveCollection() fulfillment.WaveRepository
+  FulfillmentOrderCollection() fulfillment.FulfillmentOrderRepository
+  TaxProfileCollection() invoice.TaxProfileRepository
+  TaxDocumentCollection() invoice.TaxDocumentRepository
+  RoleCollection() users.RoleRepository
+  PermissionCollection() users.PermissionRepository
+  TenantCollection() tenant.TenantRepository
+  SessionCollection() auth.SessionRepository
+  AccountTokenCollection() users.AccountTokenRepository
+  MFACollection() auth.MFARepository
 }
 
 type mongoCollectionRepository struct {
@@ -22,6 +63,35 @@ type mongoCollectionRepository struct {
   userRepo users.UserRepository
   shopeeShopRepo shopee.ShopeeShopDetailsRepository
   shopeeOrderRepo shopee.ShopeeOrderRepository
+  shopeeOrderSyncRepo shopee.ShopeeOrderSyncRepository
+  shopeePushEventRepo push.ShopeePushEventRepository
+  shopeeLabelRepo label.ShopeeLabelRepository
+  shopeeReturnRepo returns.ShopeeReturnRepository
+  marketplaceAppRepo marketplace.MarketplaceAppRepository
+  marketplaceShopAuthRepo marketplace.MarketplaceShopAuthRepository
+  marketplaceOrderRepo marketplace.MarketplaceOrderRepository
+  productRepo product.ProductRepository
+  skuMappingRepo product.SKUMappingRepository
+  warehouseRepo inventory.WarehouseRepository
+  inventoryBalanceRepo inventory.BalanceRepository
+  inventoryMovementRepo inventory.MovementRepository
+  inventoryReservationRepo inventory.ReservationRepository
+  stockSyncRuleRepo stocksync.StockSyncRuleRepository
+  stockPushLogRepo stocksync.StockPushLogRepository
+  supplierRepo purchase.SupplierRepository
+  purchaseOrderRepo purchase.PurchaseOrderRepository
+  goodsReceiptRepo purchase.GoodsReceiptRepository
+  purchaseSequenceRepo purchase.SequenceRepository
+  waveRepo fulfillment.WaveRepository
+  fulfillmentOrderRepo fulfillment.FulfillmentOrderRepository
+  taxProfileRepo invoice.TaxProfileRepository
+  taxDocumentRepo invoice.TaxDocumentRepository
+  roleRepo users.RoleRepository
+  permissionRepo users.PermissionRepository
+  tenantRepo tenant.TenantRepository
+  sessionRepo auth.SessionRepository
+  accountTokenRepo users.AccountTokenRepository
+  mfaRepo auth.MFARepository
 }
 
 func NewMongoCollectionRepository(
@@ -31,6 +101,35 @@ func NewMongoCollectionRepository(
   users users.UserRepository,
   shop shopee.ShopeeShopDetailsRepository,
   shopeeOrder shopee.ShopeeOrderRepository,
+  shopeeOrderSync shopee.ShopeeOrderSyncRepository,
+  shopeePushEvent push.ShopeePushEventRepository,
+  shopeeLabel label.ShopeeLabelRepository,
+  shopeeReturn returns.ShopeeReturnRepository,
+  marketplaceApp marketplace.MarketplaceAppRepository,
+  marketplaceShopAuth marketplace.MarketplaceShopAuthRepository,
+  marketplaceOrder marketplace.MarketplaceOrderRepository,
+  product product.ProductRepository,
+  skuMapping product.SKUMappingRepository,
+  warehouse inventory.WarehouseRepository,
+  inventoryBalance inventory.BalanceRepository,
+  inventoryMovement inventory.MovementRepository,
+  inventoryReservation inventory.ReservationRepository,
+  stockSyncRule stocksync.StockSyncRuleRepository,
+  stockPushLog stocksync.StockPushLogRepository,
+  supplier purchase.SupplierRepository,
+  purchaseOrder purchase.PurchaseOrderRepository,
+  goodsReceipt purchase.GoodsReceiptRepository,
+  purchaseSequence purchase.SequenceRepository,
+  wave fulfillment.WaveRepository,
+  fulfillmentOrder fulfillment.FulfillmentOrderRepository,
+  taxProfile invoice.TaxProfileRepository,
+  taxDocument invoice.TaxDocumentRepository,
+  role users.RoleRepository,
+  permission users.PermissionRepository,
+  tenant tenant.TenantRepository,
+  session auth.SessionRepository,
+  accountToken users.AccountTokenRepository,
+  mfa auth.MFARepository,
   // logger *zap.Logger, cfg *env.Config,
 ) IMongoCollectionRepository {
 	return &mongoCollectionRepository{
@@ -40,6 +139,35 @@ func NewMongoCollectionRepository(
     userRepo: users,
     shopeeShopRepo: shop,
     shopeeOrderRepo: shopeeOrder,
+    shopeeOrderSyncRepo: shopeeOrderSync,
+    shopeePushEventRepo: shopeePushEvent,
+    shopeeLabelRepo: shopeeLabel,
+    shopeeReturnRepo: shopeeReturn,
+    marketplaceAppRepo: marketplaceApp,
+    marketplaceShopAuthRepo: marketplaceShopAuth,
+    marketplaceOrderRepo: marketplaceOrder,
+    productRepo: product,
+    skuMappingRepo: skuMapping,
+    warehouseRepo: warehouse,
+    inventoryBalanceRepo: inventoryBalance,
+    inventoryMovementRepo: inventoryMovement,
+    inventoryReservationRepo: inventoryReservation,
+    stockSyncRuleRepo: stockSyncRule,
+    stockPushLogRepo: stockPushLog,
+    supplierRepo: supplier,
+    purchaseOrderRepo: purchaseOrder,
+    goodsReceiptRepo: goodsReceipt,
+    purchaseSequenceRepo: purchaseSequence,
+    waveRepo: wave,
+    fulfillmentOrderRepo: fulfillmentOrder,
+    taxProfileRepo: taxProfile,
+    taxDocumentRepo: taxDocument,
+    roleRepo: role,
+    permissionRepo: permission,
+    tenantRepo: tenant,
+    sessionRepo: session,
+    accountTokenRepo: accountToken,
+    mfaRepo: mfa,
 	}
 }
 
@@ -65,3 +193,119 @@ func (m *mongoCollectionRepository) ShopeeShopCollection() shopee.ShopeeShopDeta
 func (m *mongoCollectionRepository) ShopeeOrderCollection() shopee.ShopeeOrderRepository{
   return m.shopeeOrderRepo
 }
+
+func (m *mongoCollectionRepository) ShopeeOrderSyncCollection() shopee.ShopeeOrderSyncRepository {
+  return m.shopeeOrderSyncRepo
+}
+
+func (m *mongoCollectionRepository) ShopeePushEventCollection() push.ShopeePushEventRepository {
+  return m.shopeePushEventRepo
+}
+
+func (m *mongoCollectionRepository) ShopeeLabelCollection() label.ShopeeLabelRepository {
+  return m.shopeeLabelRepo
+}
+
+func (m *mongoCollectionRepository) ShopeeReturnCollection() returns.ShopeeReturnRepository {
+  return m.shopeeReturnRepo
+}
+
+func (m *mongoCollectionRepository) MarketplaceAppCollection() marketplace.MarketplaceAppRepository {
+  return m.marketplaceAppRepo
+}
+
+func (m *mongoCollectionRepository) MarketplaceShopAuthCollection() marketplace.MarketplaceShopAuthRepository {
+  return m.marketplaceShopAuthRepo
+}
+
+func (m *mongoCollectionRepository) MarketplaceOrderCollection() marketplace.MarketplaceOrderRepository {
+  return m.marketplaceOrderRepo
+}
+
+func (m *mongoCollectionRepository) ProductCollection() product.ProductRepository {
+  return m.productRepo
+}
+
+func (m *mongoCollectionRepository) SKUMappingCollection() product.SKUMappingRepository {
+  return m.skuMappingRepo
+}
+
+func (m *mongoCollectionRepository) WarehouseCollection() inventory.WarehouseRepository {
+  return m.warehouseRepo
+}
+
+func (m *mongoCollectionRepository) InventoryBalanceCollection() inventory.BalanceRepository {
+  return m.inventoryBalanceRepo
+}
+
+func (m *mongoCollectionRepository) InventoryMovementCollection() inventory.MovementRepository {
+  return m.inventoryMovementRepo
+}
+
+func (m *mongoCollectionRepository) InventoryReservationCollection() inventory.ReservationRepository {
+  return m.inventoryReservationRepo
+}
+
+func (m *mongoCollectionRepository) StockSyncRuleCollection() stocksync.StockSyncRuleRepository {
+  return m.stockSyncRuleRepo
+}
+
+func (m *mongoCollectionRepository) StockPushLogCollection() stocksync.StockPushLogRepository {
+  return m.stockPushLogRepo
+}
+
+func (m *mongoCollectionRepository) SupplierCollection() purchase.SupplierRepository {
+  return m.supplierRepo
+}
+
+func (m *mongoCollectionRepository) PurchaseOrderCollection() purchase.PurchaseOrderRepository {
+  return m.purchaseOrderRepo
+}
+
+func (m *mongoCollectionRepository) GoodsReceiptCollection() purchase.GoodsReceiptRepository {
+  return m.goodsReceiptRepo
+}
+
+func (m *mongoCollectionRepository) PurchaseSequenceCollection() purchase.SequenceRepository {
+  return m.purchaseSequenceRepo
+}
+
+func (m *mongoCollectionRepository) WaveCollection() fulfillment.WaveRepository {
+  return m.waveRepo
+}
+
+func (m *mongoCollectionRepository) FulfillmentOrderCollection() fulfillment.FulfillmentOrderRepository {
+  return m.fulfillmentOrderRepo
+}
+
+func (m *mongoCollectionRepository) TaxProfileCollection() invoice.TaxProfileRepository {
+  return m.taxProfileRepo
+}
+
+func (m *mongoCollectionRepository) TaxDocumentCollection() invoice.TaxDocumentRepository {
+  return m.taxDocumentRepo
+}
+
+func (m *mongoCollectionRepository) RoleCollection() users.RoleRepository {
+  return m.roleRepo
+}
+
+func (m *mongoCollectionRepository) PermissionCollection() users.PermissionRepository {
+  return m.permissionRepo
+}
+
+func (m *mongoCollectionRepository) TenantCollection() tenant.TenantRepository {
+  return m.tenantRepo
+}
+
+func (m *mongoCollectionRepository) SessionCollection() auth.SessionRepository {
+  return m.sessionRepo
+}
+
+func (m *mongoCollectionRepository) AccountTokenCollection() users.AccountTokenRepository {
+  return m.accountTokenRepo
+}
+
+func (m *mongoCollectionRepository) MFACollection() auth.MFARepository {
+  return m.mfaRepo
+}
@@ -1,15 +1,8 @@
 package adapter
 
 import (
-	"bytes"
 	"context"
-	"crypto/hmac"
-	"crypto/sha256"
-	"encoding/hex"
-	"encoding/json"
 	"errors"
-	"fmt"
-	"io"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -44,6 +37,7 @@ type IReqShopeeAdapter struct {
   SecretKey string
   Code *string
   OrderSN []string
+  MerchantID string // MERCHANT api only
 }
 
 type IShopeeService interface {
@@ -51,35 +45,104 @@ type IShopeeService interface {
 	// waiting reface replace body abd query gen
 	// GenerateBodyQueryParams()(,error)
 
-  RequestHTTP(method string, url string , body *[]byte) (*http.Response, error)
+  // client health : circuit breaker per endpoint, reset "" = all
+  GetCircuitBreakers() []ShopeeCircuitBreakerState
+  ResetCircuitBreaker(path string) bool
 
 	GenerateSignWithPathURL(state string, pathUrl string, partnerID string, partnerKey string, shopID string, code string, accessToken string) (*IResGenerateSignWithUri, error)
 
-	GetAccessToken(partnerID string, shopID string, code string, signCode string) (*IResShopeeAuthResponse, error)
-	GetRefreshToken(partnerID string, shopID string, refreshToken string, signCode string) (*dto.IResShopeeAuthRefreshResponse, error)
+  // path : */api/v2/auth/token/get : params.Code required
+	GetAccessToken(ctx context.Context, params *IReqShopeeAdapter) (*IResShopeeAuthResponse, error)
+  // path : */api/v2/auth/access_token/get
+	GetRefreshToken(ctx context.Context, params *IReqShopeeAdapter, refreshToken string) (*dto.IResShopeeAuthRefreshResponse, error)
 	// ExchangeToken(ctx context.Context, code string, redirectURI string, partnerID string) (*ShopeeAuthResponse, error)
-	GetShopByPartnerPublic(partnerID string, signCode string) (*dto.IResGetShopByPartnerPublic, error)
+  // path : */api/v2/public/get_shops_by_partner
+	GetShopByPartnerPublic(ctx context.Context, params *IReqShopeeAdapter) (*dto.IResGetShopByPartnerPublic, error)
 
-	GetOrderListByShopID(partnerID string, accessToken string, shopID string, signCode string, optsShopee *dto.IOptionShopeeQuery) (*dto.IResGetOrderListByShopIDShop, error)
-  GetOrderDetailByOrderSN(partnerID string, partnerKey string,accessToken string, shopID string, orderList []string, pending bool, option bool) (*dto.IResOrderDetailByOrderSN, error)
+  // path : */api/v2/order/get_order_list
+	GetOrderListByShopID(ctx context.Context, params *IReqShopeeAdapter, optsShopee *dto.IOptionShopeeQuery) (*dto.IResGetOrderListByShopIDShop, error)
+  // path : */api/v2/order/get_order_detail : params.OrderSN
+  GetOrderDetailByOrderSN(ctx context.Context, params *IReqShopeeAdapter, pending bool, option bool) (*dto.IResOrderDetailByOrderSN, error)
   
   // path : */api/v2/order/get_order_detail
   GetOrderDetailListByOrderSN(ctx context.Context, params *IReqShopeeAdapter) ([]dto.IResOrderListWithDetails,error)
+  // path : */api/v2/order/get_order_list : one page, caller follows More/NextCursor
+  GetOrderListPageByShopID(ctx context.Context, params *IReqShopeeAdapter, opts *dto.IOptionShopeeQuery) (*dto.IResGetOrderListByShopIDShopWrapper, error)
 
 
   // path : */api/v2/shop/get_profile 
   GetShopProfile(ctx context.Context, params *IReqShopeeAdapter ) (*dto.IResShopGetProfile_ResponseDTO, error)  
   // path : */api/v2/shop/get_shop_info
   GetShopInfo(ctx context.Context, params *IReqShopeeAdapter) (*dto.IResShopGetShopInfoDTO ,error)
+
+  // path : */api/v2/product/get_item_list : one page, caller follows HasNextPage/NextOffset
+  GetItemListByShopID(ctx context.Context, params *IReqShopeeAdapter, opts *dto.IOptionShopeeItemListQuery) (*dto.IResGetItemListWrapper, error)
+  // path : */api/v2/product/get_item_base_info : max 50 item_id
+  GetItemBaseInfo(ctx context.Context, params *IReqShopeeAdapter, itemID []int64) ([]dto.IResItemBaseInfo, error)
+  // path : */api/v2/product/get_model_list
+  GetModelList(ctx context.Context, params *IReqShopeeAdapter, itemID int64) (*dto.IResGetModelListWrapper, error)
+  // path : */api/v2/product/add_item
+  AddItem(ctx context.Context, params *IReqShopeeAdapter, body *dto.IBAddItem) (*dto.IResAddItemWrapper, error)
+  // path : */api/v2/product/update_item
+  UpdateItem(ctx context.Context, params *IReqShopeeAdapter, body *dto.IBUpdateItem) (*dto.IResAddItemWrapper, error)
+  // path : */api/v2/product/update_price
+  UpdatePrice(ctx context.Context, params *IReqShopeeAdapter, body *dto.IBUpdatePrice) (*dto.IResUpdatePriceWrapper, error)
+  // path : */api/v2/product/update_stock
+  UpdateStock(ctx context.Context, params *IReqShopeeAdapter, body *dto.IBUpdateStock) (*dto.IResUpdateStockWrapper, error)
+  // path : */api/v2/product/unlist_item
+  UnlistItem(ctx context.Context, params *IReqShopeeAdapter, body *dto.IBUnlistItem) (*dto.IResUnlistItemWrapper, error)
+
+  // path : */api/v2/logistics/get_shipping_parameter
+  GetShippingParameter(ctx context.Context, params *IReqShopeeAdapter, orderSN string, packageNumber string) (*dto.IResShippingParameterWrapper, error)
+  // path : */api/v2/logistics/ship_order
+  ShipOrder(ctx context.Context, params *IReqShopeeAdapter, body *dto.IBShipOrder) error
+  // path : */api/v2/logistics/batch_ship_order : max 50 orders, same shipping method
+  BatchShipOrder(ctx context.Context, params *IReqShopeeAdapter, body *dto.IBBatchShipOrder) (*dto.IResBatchShipOrderWrapper, error)
+  // path : */api/v2/logistics/get_tracking_number
+  GetTrackingNumber(ctx context.Context, params *IReqShopeeAdapter, orderSN string, packageNumber string) (*dto.IResTrackingNumberWrapper, error)
+  // path : */api/v2/logistics/get_tracking_info
+  GetTrackingInfo(ctx context.Context, params *IReqShopeeAdapter, orderSN string, packageNumber string) (*dto.IResTrackingInfoWrapper, error)
+
+  // path : */api/v2/logistics/create_shipping_document : async, poll GetShippingDocumentResult
+  CreateShippingDocument(ctx context.Context, params *IReqShopeeAdapter, body *dto.IBCreateShippingDocument) ([]dto.IResShippingDocumentResult, error)
+  // path : */api/v2/logistics/get_shipping_document_result
+  GetShippingDocumentResult(ctx context.Context, params *IReqShopeeAdapter, body *dto.IBGetShippingDocumentResult) ([]dto.IResShippingDocumentResult, error)
+  // path : */api/v2/logistics/download_shipping_document : raw file (pdf)
+  DownloadShippingDocument(ctx context.Context, params *IReqShopeeAdapter, body *dto.IBDownloadShippingDocument) ([]byte, error)
+
+  // path : */api/v2/payment/get_escrow_detail
+  GetEscrowDetail(ctx context.Context, params *IReqShopeeAdapter, orderSN string) (*dto.IResEscrowDetailWrapper, error)
+  // path : */api/v2/payment/get_escrow_list : one page, range max 14 days
+  GetEscrowList(ctx context.Context, params *IReqShopeeAdapter, opts *dto.IOptionShopeePageQuery) (*dto.IResEscrowListWrapper, error)
+  // path : */api/v2/payment/get_payout_detail : one page, range max 14 days
+  GetPayoutDetail(ctx context.Context, params *IReqShopeeAdapter, opts *dto.IOptionShopeePageQuery) (*dto.IResPayoutDetailWrapper, error)
+
+  // path : */api/v2/returns/get_return_list : one page
+  GetReturnList(ctx context.Context, params *IReqShopeeAdapter, opts *dto.IOptionShopeeReturnListQuery) (*dto.IResReturnListWrapper, error)
+  // path : */api/v2/returns/get_return_detail
+  GetReturnDetail(ctx context.Context, params *IReqShopeeAdapter, returnSN string) (*dto.IResReturnDetail, error)
+  // path : */api/v2/returns/confirm : accept the return / refund
+  ConfirmReturn(ctx context.Context, params *IReqShopeeAdapter, body *dto.IBConfirmReturn) (*dto.IResReturnSNWrapper, error)
+  // path : */api/v2/returns/dispute
+  DisputeReturn(ctx context.Context, params *IReqShopeeAdapter, body *dto.IBDisputeReturn) (*dto.IResDisputeReturnWrapper, error)
+  // path : */api/v2/returns/offer
+  OfferReturn(ctx context.Context, params *IReqShopeeAdapter, body *dto.IBOfferReturn) (*dto.IResReturnSNWrapper, error)
+  // path : */api/v2/returns/convert_image : multipart, max 3 images
+  ConvertReturnImage(ctx context.Context, params *IReqShopeeAdapter, files []dto.IFileUpload) ([]dto.IResReturnImage, error)
 }
 
 type shopeeApi struct {
   Config *env.Config
 
+	// scheme + host every request goes to (SHOPEE_API_BASE_URL, a fake server in tests)
 	BaseURL    string
-	PrefixURL  string
 	Logger     *zap.Logger
 	HttpClient *http.Client
+
+  // outbound guards, shared by every Call (shopee.resilience.go)
+  Limiter  *shopeeRateLimiter
+  Retry    shopeeRetryPolicy
+  Breakers *shopeeBreakers
 }
 
 type IResShopeeAuthResponse struct {
@@ -91,13 +154,20 @@ type IResShopeeAuthResponse struct {
 	Message      string `json:"message"`
 }
 
-func NewShopeeAPI(config *env.Config, baseURL string, prefix string, log *zap.Logger) IShopeeService {
+func NewShopeeAPI(config *env.Config, baseURL string, log *zap.Logger) IShopeeService {
+  timeout := 10 * time.Second
+  if config.Shopee.ShopeeHttpTimeout > 0 {
+    timeout = time.Duration(config.Shopee.ShopeeHttpTimeout) * time.Second
+  }
+
 	return &shopeeApi{
     Config: config,
 		BaseURL:    baseURL,
-		PrefixURL:  prefix,
 		Logger:     log,
-		HttpClient: &http.Client{Timeout: 10 * time.Second},
+		HttpClient: &http.Client{Timeout: timeout},
+    Limiter:  newShopeeRateLimiter(config.Shopee),
+    Retry:    newShopeeRetryPolicy(config.Shopee),
+    Breakers: newShopeeBreakers(config.Shopee),
 	}
 }
 
@@ -111,125 +181,39 @@ type IResGenerateSignWithUri struct {
   URL       *url.URL
 }
 
-func (s *shopeeApi) RequestHTTP(method string, url string , body *[]byte) (*http.Response, error) {
-  switch method {
-  case "GET":
-    // s.Logger.Debug("adapter.RequestHTTP.GET", zap.String("url" , url))
-    return http.Get(url)
-  case "POST":
-    req,err := http.NewRequest("POST", url, bytes.NewBuffer(*body))
-    if err != nil {return nil, err}
-    req.Header.Set("Content-Type","application/json")
-    return http.DefaultClient.Do(req)
-  default: 
-    return nil, errors.New("adapter.RequestHTTP: upsupport method" )
+func (s *shopeeApi) GetCircuitBreakers() []ShopeeCircuitBreakerState {
+  return s.Breakers.snapshot()
+}
+
+func (s *shopeeApi) ResetCircuitBreaker(path string) bool {
+  if path != "" {
+    path = normalizeShopeePath(path)
   }
+  return s.Breakers.reset(path)
 }
 
 // Tip : func auto complete fill  /api/v2/***(shopee)
+// path → method comes from the endpoint registry (shopee.client.go), "/api/v2" is added when missing
 func (s *shopeeApi) GenerateSignWithPathURL(state string, pathUrl string, partnerID string, partnerKey string, shopID string, code string, accessToken string) (*IResGenerateSignWithUri, error) {
-  // var url string
-	var method string
-  Url,err := url.Parse(s.Config.Shopee.ShopeeApiBaseUrl) 
-  if err != nil { return nil , errors.New("error parse url from env")}
-
-	// host := s.Config.Shopee.ShopeeApiBaseUrl
-	timest := strconv.FormatInt(time.Now().Unix(), 10)
-	path := fmt.Sprintf("%s", pathUrl)
-	// s.Logger.Sugar().Debugf("adapter.shopee.GenerateSignWithPathURL: %s", path)
-	var baseString string
-  var sign string
-  q := Url.Query()
-  q.Set("partner_id", partnerID)
-  q.Set("timestamp", timest)
-	// baseString := fmt.Sprintf("%s%s%s", partnerID, path, timest)
-	switch state {
-	case "PUBLIC":
-		// For Public APIs: partner_id, api path, timestamp
-		baseString = fmt.Sprintf("%s%s%s", partnerID, path, timest)
-	  h := hmac.New(sha256.New, []byte(partnerKey))
-	  h.Write([]byte(baseString))
-	  sign = hex.EncodeToString(h.Sum(nil))
-    // q.Set("sign", sign)
-
-	case "SHOP":
-		// For Shop APIs: partner_id, api path, timestamp, access_token, shop_id
-    // s.Logger.Debug("adapter.GenerateSignWithPathURL.SHOP", zap.String("val", partnerID+path+timest+accessToken+shopID))
-		baseString = fmt.Sprintf("%s%s%s%s%s", partnerID, path, timest, accessToken, shopID)
-	  h := hmac.New(sha256.New, []byte(partnerKey))
-	  h.Write([]byte(baseString))
-	  sign = hex.EncodeToString(h.Sum(nil))
-
-    // q.Set("sign", sign)
-    q.Set("shop_id", shopID)
-    q.Set("access_token", accessToken)
-
-	case "MERCHANT":
-		// Not available
-		// For Merchant APIs: partner_id, api path, timestamp, access_token, merchant_id
-		merchantID := ""
-		baseString = fmt.Sprintf("%s%s%s%s%s", partnerID, path, timest, accessToken, merchantID)
-	  h := hmac.New(sha256.New, []byte(partnerKey))
-	  h.Write([]byte(baseString))
-	  sign = hex.EncodeToString(h.Sum(nil))
-
-    // q.Set("sign", sign)
-    q.Set("merchant_id", merchantID)
-    q.Set("access_token", accessToken)
-
-	default:
-		s.Logger.Error("adapter.shopee.GenerateSignWithPathURL: invalid state")
-		return nil, errors.New("adapter.shopee.GenerateSignWithPathURL: invalid state")
-	}
-
-  q.Set("sign", sign)
-  // Set query 
-  Url.RawQuery = q.Encode() 
-	// s.Logger.Sugar().Debugf("adapter.shopee.GenerateSignWithPathURL: baseString - %s", baseString)
-  // s.Logger.Info("shopee.adapter.GenerateSignWithPathURL", zap.String("val", path))
-	switch path {
-
-	case "/api/v2/auth/token/get":
-		method = "GET"
-		// break
-
-	case "/api/v2/auth/access_token/get":
-		method = "POST"
-		// break
-
-	case "/api/v2/public/get_shops_by_partner":
-		method = "GET"
-	// break
-	// url = fmt.Sprintf("%s%s?partner_id=%s&timestamp=%s&sign=%s", host, path, partnerID, timest, sign)
-	case "/api/v2/order/get_order_list":
-
-		method = "GET"
-
-  case "/api/v2/order/get_order_detail":
-    method = "GET"
-
-  case "/api/v2/shop/get_profile":
-    method = "GET"
-
-  case "/api/v2/shop/get_shop_info":
-    method = "GET"
-    // url = fmt.Sprintf("%s%spartner_id=%s&timestamp=%s&sign=%s&shop_id=%s&access_token=%s", s.Config.Shopee.ShopeeApiBaseUrl,path,  ) 
-
-	default:
-		s.Logger.Error(`adapter.shopee.GenerateSignWithPathURL:invalid path `+ method)
-    return nil, errors.New("adapter.shopee.GenerateSignWithPathURL: invalid path" + method + ":" + path )
-	}
+  e, ok := LookupShopeeEndpoint(pathUrl)
+  if !ok {
+		s.Logger.Error("adapter.shopee.GenerateSignWithPathURL:invalid path", zap.String("path", pathUrl))
+    return nil, errors.New("adapter.shopee.GenerateSignWithPathURL: invalid path:" + pathUrl)
+  }
 
-  Url.Path = path
-  
-	return &IResGenerateSignWithUri{
-		Method:    method,
-		Path:      path,
-		Sign:      sign,
-		Code:      code,
-		TimeStamp: timest,
-    URL: Url,
-  }, nil
+  params := &IReqShopeeAdapter{
+    PartnerID: partnerID,
+    SecretKey: partnerKey,
+    ShopID: shopID,
+    AccessToken: accessToken,
+    Code: &code,
+  }
+  gen, err := s.signShopeeURL(e, ShopeeTypeAPIEnum(state), params)
+  if err != nil {
+		s.Logger.Error("adapter.shopee.GenerateSignWithPathURL: invalid state")
+    return nil, err
+  }
+  return gen, nil
 }
 
 // InterfaceBody : IBXXX
@@ -239,410 +223,114 @@ type IBGetAccessToken struct {
 	ShopID    int64  `json:"shop_id"`
 }
 
-func (s *shopeeApi) GetAccessToken(partnerID string, shopID string, code string, signCode string) (*IResShopeeAuthResponse, error) {
-
-	// seperate component
-	timeStp := strconv.FormatInt(time.Now().Unix(), 10)
-	url := fmt.Sprintf("%s%s/auth/token/get?partner_id=%s&timestamp=%s&sign=%s", s.BaseURL, s.PrefixURL, partnerID, timeStp, signCode)
-
-  // body : code , partner_id, shop_id || main_account_id
-  // s.Logger.Error("shopee.adapter.GetAccessToken", zap.String("error",url))
-
-	partnerIDInt, err := strconv.ParseInt(partnerID, 10, 64)
+// GetAccessToken : PUBLIC, params.Code is the code from the auth_partner redirect
+func (s *shopeeApi) GetAccessToken(ctx context.Context, params *IReqShopeeAdapter) (*IResShopeeAuthResponse, error) {
+	partnerIDInt, err := strconv.ParseInt(params.PartnerID, 10, 64)
 	if err != nil {
-    // s.Logger.Error("shopee.adapter.GetAccessToken", zap.String("error", "parseIDInt failed"))
 		return nil, errors.New("failed to convert partnerID to int64")
 	}
-	shopIDInt, err := strconv.ParseInt(shopID, 10, 64)
+	shopIDInt, err := strconv.ParseInt(params.ShopID, 10, 64)
 	if err != nil {
 		return nil, errors.New("failed to convert shopID to int64")
 	}
+  if params.Code == nil || *params.Code == "" {
+    return nil, errors.New("adapter.GetAccessToken : code is required")
+  }
 
 	payload := &IBGetAccessToken{
-		Code:      code,
+		Code:      *params.Code,
 		PartnerID: partnerIDInt,
 		ShopID:    shopIDInt}
 
+	return Call[*IBGetAccessToken, IResShopeeAuthResponse](ctx, s, "/api/v2/auth/token/get", params, payload)
+}
 
-
-  // s.Logger.Info("shopee.adapter.GetAccessToken", zap.Any("val", payload) )
-
-	body, _ := json.Marshal(payload)
-
-	req, err := http.NewRequestWithContext(context.Background(), "POST", url, bytes.NewBuffer(body))
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Content-Type", "application/json")
-
-	// s.logger.Debug("adapter.GetAccessToken: Outgoing Request Before",
-	// 	zap.String("url", req.URL.String()),
-	// 	zap.String("method", req.Method),
-	// 	zap.Any("headers", req.Header),
-	// 	zap.ByteString("body", body),
-	// )
-  s.Logger.Info("shopee.adapter.GetAccessToken", zap.String("val", "xx"))
-
-	resp, err := s.HttpClient.Do(req)
+// GetRefreshToken : PUBLIC, Shopee rotates the refresh token on every call
+func (s *shopeeApi) GetRefreshToken(ctx context.Context, params *IReqShopeeAdapter, refreshToken string) (*dto.IResShopeeAuthRefreshResponse, error) {
+	partnerIDInt, err := strconv.ParseInt(params.PartnerID, 10, 64)
 	if err != nil {
-		return nil, err
+		return nil, errors.New("failed to convert partnerID to int64")
 	}
-
-	defer resp.Body.Close()
-
-	bodyBytes, err := io.ReadAll(resp.Body)
+	shopIDInt, err := strconv.ParseInt(params.ShopID, 10, 64)
 	if err != nil {
-		return nil, err
+		return nil, errors.New("failed to convert shopID to int64")
 	}
 
-	s.Logger.Info("adapter.GetAccessToken: Outgoing Request After",
-		zap.String("url", req.URL.String()),
-		zap.String("method", req.Method),
-		zap.Any("headers", req.Header),
-		zap.ByteString("body", bodyBytes),
-	)
-
-
-	// s.logger.Sugar().Debugf("Shopee Raw Response Body: %s", zap.ByteString("response",bodyBytes))
-	// s.logger.Sugar().Debugf("adapter.GetAccessToken : %s", resp.Body)
-
-	var authResp IResShopeeAuthResponse
-	// for debug
-	if err := json.Unmarshal(bodyBytes, &authResp); err != nil {
-		s.Logger.Error("adapter.GetAccessToken : json.Unmarshal(bodyBytes ,&authResp) :", zap.Error(err))
-		return nil, err
-  }
-
-  if authResp.Error != "" {
-    return nil , errors.New(authResp.Message)
-  } 
-   
-  // s.Logger.Debug("shopee.adapter.GetAccessToken", zap.Any("val", authResp))
-
-	// for before debug
-	// if err := json.NewDecoder(resp.Body).Decode(&authResp); err != nil {
-	// 	return nil, err
-	// }
-	// if authResp.Error != "" {
-	// 	return nil, errors.New(authResp.Message)
-	// }
-
-	return &authResp, nil
-}
-
-func (s *shopeeApi) GetRefreshToken(partnerID string, shopID string, refreshToken string, signCode string) (*dto.IResShopeeAuthRefreshResponse, error) {
-
-	// Public Api
-	timeStp := strconv.FormatInt(time.Now().Unix(), 10)
-	url := fmt.Sprintf("%s%s/auth/access_token/get?partner_id=%s&timestamp=%s&sign=%s", s.BaseURL, s.PrefixURL, partnerID, timeStp, signCode)
-
-	partnerIDInt, err := func() (int32, error) {
-		i64, err := strconv.ParseInt(partnerID, 10, 64)
-		return int32(i64), err
-	}()
-
-	shopIDInt, err := func() (int32, error) {
-		i64, err := strconv.ParseInt(shopID, 10, 64)
-		return int32(i64), err
-	}()
-
 	payload := &dto.IBGetRefreshToken{
 		RefreshToken: refreshToken,
 		PartnerID:    int32(partnerIDInt),
 		ShopID:       int32(shopIDInt),
 	}
 
-	body, _ := json.Marshal(payload)
-	req, err := http.NewRequestWithContext(context.Background(), "POST", url, bytes.NewBuffer(body))
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-
-	s.Logger.Debug("adapter.GetAccessToken: Outgoing Request Before",
-		zap.String("url", req.URL.String()),
-		zap.String("method", req.Method),
-		zap.Any("headers", req.Header),
-		zap.ByteString("body", body),
-	)
-
-	resp, err := s.HttpClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	bodyBytes, err := io.ReadAll(resp.Body)
+	res, err := Call[*dto.IBGetRefreshToken, dto.IResShopeeAuthRefreshResponse](ctx, s, "/api/v2/auth/access_token/get", params, payload)
 	if err != nil {
+		s.Logger.Error("adapter.GetRefreshToken : shopee error", zap.Error(err))
 		return nil, err
 	}
-
-	s.Logger.Debug("adapter.GetRefreshToken.bodyBytes", zap.Any("bodyBytes", bodyBytes))
-
-	var resShopeeAuthRefreshToken dto.IResShopeeAuthRefreshResponse
-	if err := json.Unmarshal(bodyBytes, &resShopeeAuthRefreshToken); err != nil {
-		s.Logger.Error("adapter.GetAccessToken : json.Unmarshal(bodyBytes ,&authRes) :", zap.Error(err))
-		return nil, err
-	}
-
-	return &resShopeeAuthRefreshToken, nil
+	return res, nil
 }
 
-func (s *shopeeApi) GetShopByPartnerPublic(partnerID string, signCode string) (*dto.IResGetShopByPartnerPublic, error) {
-	// https://partner.test-stable.shopeemobile.com/api/v2/public/get_shops_by_partner
-	// query: partner_id, timestamp, sign
-
-	// Public Api
-	timeStp := strconv.FormatInt(time.Now().Unix(), 10)
-
-	url := fmt.Sprintf("%s%s/public/get_shops_by_partner?partner_id=%s&timestamp=%s&sign=%s", s.BaseURL, s.PrefixURL, partnerID, timeStp, signCode)
-
-	req, err := http.NewRequestWithContext(context.Background(), "GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := s.HttpClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	bodyBytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	s.Logger.Debug("bodyBytes", zap.Any("bodyBytes", bodyBytes))
-
-	var resGetShopByPartnerPublic dto.IResGetShopByPartnerPublic
-	if err := json.Unmarshal(bodyBytes, &resGetShopByPartnerPublic); err != nil {
-		s.Logger.Error("adapter.GetAccessToken : json.Unmarshal(bodyBytes ,&authRes) :", zap.Error(err))
-		return nil, err
-	}
-
-	if resGetShopByPartnerPublic.Error != "" {
-		return nil, errors.New(resGetShopByPartnerPublic.Message)
-	}
-
-	s.Logger.Debug("resGetShopByPartnerPublic", zap.Any("resGetShopByPartnerPublic", resGetShopByPartnerPublic))
-
-	return &resGetShopByPartnerPublic, nil
+// GetShopByPartnerPublic : PUBLIC, every shop that authorized the partner
+func (s *shopeeApi) GetShopByPartnerPublic(ctx context.Context, params *IReqShopeeAdapter) (*dto.IResGetShopByPartnerPublic, error) {
+	return Call[url.Values, dto.IResGetShopByPartnerPublic](ctx, s, "/api/v2/public/get_shops_by_partner", params, nil)
 }
 
-func (s *shopeeApi) GetOrderListByShopID(partnerID string, accessToken string, shopID string, signCode string, optsShopee *dto.IOptionShopeeQuery) (*dto.IResGetOrderListByShopIDShop, error) {
-
-	// var optsTimeRan dto.IEnumShopeeTimeRange = dto.CREATE_TIME
-	// var OrderStatus dto.IEnumShopeeOrderStatus = dto.PROCESSED
-
-	timeStp := strconv.FormatInt(time.Now().Unix(), 10)
-
+func (s *shopeeApi) GetOrderListByShopID(ctx context.Context, params *IReqShopeeAdapter, optsShopee *dto.IOptionShopeeQuery) (*dto.IResGetOrderListByShopIDShop, error) {
 	q := url.Values{}
-	paramsQuery := map[string]string{
-		// common
-		"partner_id":   partnerID,
-		"timestamp":    timeStp,
-		"access_token": accessToken,
-		"shop_id":      shopID,
-		"sign":         signCode,
-		// required
-		// "time_range_field": string(optsShopee.TimeRange),
-		// "time_from":        string(strconv.FormatInt(optsShopee.TimeFrom, 10)),
-		// "time_to":          string(strconv.FormatInt(int64(optsShopee.TimeTo), 10)),
-		// "page_size":        string(strconv.FormatInt(int64(optsShopee.PageSize), 10)),
-		"time_range_field": "",
-		"time_from":        "",
-		"time_to":          "",
-		"page_size":        "",
-		// opts
-		"cursor":                       string(optsShopee.CursorPage),
-		"order_status":                 string(optsShopee.OrderStatus),
-		"response_optional_fields":     string(optsShopee.ResponseOptionsField),
-		"request_order_status_pending": string(strconv.FormatBool(optsShopee.RequestOrderStatus)),
-		"logistics_channel_id":         string(optsShopee.LogisticsChanelID),
-	}
 
 	// Set Params Reuired
-	if string(optsShopee.TimeRange) == "" {
-		paramsQuery["time_range_field"] = "create_time"
-	} else {
-		paramsQuery["time_range_field"] = string(optsShopee.TimeRange)
-	}
-
-	if string(strconv.FormatInt(optsShopee.TimeFrom, 10)) == "" {
-		paramsQuery["time_from"] = time.Now().Truncate(24 * time.Hour).Format(time.RFC3339)
-	} else {
-		paramsQuery["time_from"] = string(strconv.FormatInt(int64(optsShopee.TimeFrom), 10))
-	}
-
-	if string(strconv.FormatInt(int64(optsShopee.TimeTo), 10)) == "" {
-		paramsQuery["time_to"] = time.Now().Truncate(24 * time.Hour).Add(24*time.Hour + 59*time.Minute + 59*time.Second).Format(time.RFC3339)
+	if optsShopee.TimeRange == "" {
+		q.Set("time_range_field", string(dto.CREATE_TIME))
 	} else {
-		paramsQuery["time_to"] = string(strconv.FormatInt(int64(optsShopee.TimeTo), 10))
+		q.Set("time_range_field", string(optsShopee.TimeRange))
 	}
-
-	if string(strconv.FormatInt(int64(optsShopee.PageSize), 10)) == "" {
-		paramsQuery["page_size"] = "20"
+	q.Set("time_from", strconv.FormatInt(optsShopee.TimeFrom, 10))
+	q.Set("time_to", strconv.FormatInt(optsShopee.TimeTo, 10))
+	if optsShopee.PageSize <= 0 {
+		q.Set("page_size", "20")
 	} else {
-		paramsQuery["page_size"] = string(strconv.FormatInt(int64(optsShopee.PageSize), 10))
+		q.Set("page_size", strconv.FormatInt(int64(optsShopee.PageSize), 10))
 	}
 	// End Set Params Reuired
 
-	for k, v := range paramsQuery {
-		if v != "" {
-			q.Set(k, v)
-		}
-	}
-
-	queryString := q.Encode()
-	url := fmt.Sprintf("%s%s/order/get_order_list?%s&response_optional_fields=order_status", s.BaseURL, s.PrefixURL, queryString)
-	// s.logger.Debug("GetOrderListByShopID", zap.String("url", url))
-
-	req, err := http.NewRequestWithContext(context.Background(), "GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("Content-Type", "application/json")
+	if optsShopee.CursorPage != "" { q.Set("cursor", optsShopee.CursorPage) }
+	if optsShopee.OrderStatus != "" { q.Set("order_status", string(optsShopee.OrderStatus)) }
+	if optsShopee.RequestOrderStatus { q.Set("request_order_status_pending", "true") }
+	if optsShopee.LogisticsChanelID != "" { q.Set("logistics_channel_id", string(optsShopee.LogisticsChanelID)) }
+	q.Set("response_optional_fields", string(dto.OrderStatus))
 
-	// s.logger.Debug("GetOrderListByShopID", zap.String("url", url))
-
-	resp, err := s.HttpClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	bodyBytes, err := io.ReadAll(resp.Body)
+	res, err := Call[url.Values, dto.IResGetOrderListByShopIDShopWrapper](ctx, s, "/api/v2/order/get_order_list", params, q)
 	if err != nil {
+		s.Logger.Error("adapter.GetOrderListByShopID", zap.Error(err))
 		return nil, err
 	}
-
-	// s.Logger.Debug("adapter.GetOrderListByShopID.bodyBytes", zap.Any("bodyBytes", bodyBytes))
-
-	var resGetShopByPartnerPublic dto.IResGetOrderListByShopIDShop
-	if err := json.Unmarshal(bodyBytes, &resGetShopByPartnerPublic); err != nil {
-		s.Logger.Error("adapter.GetAccessToken : json.Unmarshal(bodyBytes ,&authRes) :", zap.Error(err))
-		return nil, err
-	}
-	if resGetShopByPartnerPublic.Error != "" {
-		s.Logger.Error("adapter.GetAccessToken : json.Unmarshal(bodyBytes ,&authRes) :",
-			zap.String("error", resGetShopByPartnerPublic.Error),
-			zap.String("message", resGetShopByPartnerPublic.Message))
-		return nil, errors.New(resGetShopByPartnerPublic.Error)
-	}
-	// if resGetShopByPartnerPublic.OrderList == nil {
-	//   resGetShopByPartnerPublic.OrderList = []dto.IResOrderList{}
-	// }
-
-	// s.Logger.Debug("resGetShopByPartnerPublic", zap.Any("resGetShopByPartnerPublic", resGetShopByPartnerPublic))
-
-	return &resGetShopByPartnerPublic, nil
+	return &dto.IResGetOrderListByShopIDShop{Response: *res}, nil
 }
 
-func (s *shopeeApi) GetOrderDetailByOrderSN(partnerID string, partnerKey string,accessToken string, shopID string, orderList []string, pending bool, option bool) (*dto.IResOrderDetailByOrderSN, error) {
-
-  genData,err := s.GenerateSignWithPathURL("SHOP", "/api/v2/order/get_order_detail", partnerID, partnerKey, shopID, "", accessToken)
-  if err != nil {
-    s.Logger.Error("adapter.GetOrderDetailByOrderSN : s.GenerateSignWithPathURL error", zap.Error(err))
-    return nil, err
-  }
-
-	timeStp := strconv.FormatInt(time.Now().Unix(), 10)
-
+func (s *shopeeApi) GetOrderDetailByOrderSN(ctx context.Context, params *IReqShopeeAdapter, pending bool, option bool) (*dto.IResOrderDetailByOrderSN, error) {
 	q := url.Values{}
-	paramsQuery := map[string]string{
-		// common
-		"partner_id":   partnerID,
-		"timestamp":    timeStp,
-		"access_token": accessToken,
-		"shop_id":      shopID,
-		"sign":         genData.Sign,
-		// required
-		"order_sn_list": "",
-		// opts
-		"request_order_status_pending": "",
-		"response_optional_fields":     "",
-	}
-
-	if len(orderList) > 0 {
-		paramsQuery["order_sn_list"] = strings.Join(orderList, ",")
+	if len(params.OrderSN) > 0 {
+		q.Set("order_sn_list", strings.Join(params.OrderSN, ","))
 	}
 	if pending {
-		paramsQuery["request_order_status_pending"] = "true"
+		q.Set("request_order_status_pending", "true")
 	}
 	if option {
-		paramsQuery["response_optional_fields"] = "total_amount"
-	}
-	for k, v := range paramsQuery {
-		if v != "" {
-			q.Set(k, v)
-		}
+		q.Set("response_optional_fields", "total_amount")
 	}
 
-	queryString := q.Encode()
-
-	url := fmt.Sprintf("%s%s/order/get_order_detail?%s", s.BaseURL, s.PrefixURL, queryString)
-
-	// s.Logger.Debug("GetOrderListByShopID", zap.String("url", url))
-
-	req, err := http.NewRequestWithContext(context.Background(), "GET", url, nil)
+	res, err := Call[url.Values, dto.IResOrderDetailByOrderSNShopWrapper](ctx, s, "/api/v2/order/get_order_detail", params, q)
 	if err != nil {
+		s.Logger.Error("adapter.GetOrderDetailByOrderSN", zap.Error(err))
 		return nil, err
 	}
-
-	req.Header.Set("Content-Type", "application/json")
-
-	// s.Logger.Debug("GetOrderListByShopID", zap.String("url", url))
-
-	resp, err := s.HttpClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	bodyBytes, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	// s.Logger.Debug("adapter.GetOrderListByShopID.bodyBytes", zap.Any("bodyBytes", bodyBytes))
-
-	var resGetOrderDetailByOrderSN dto.IResOrderDetailByOrderSN
-	if err := json.Unmarshal(bodyBytes, &resGetOrderDetailByOrderSN); err != nil {
-		s.Logger.Error("adapter.GetAccessToken : json.Unmarshal(bodyBytes ,&authRes) :", zap.Error(err))
-		return nil, err
-	}
-
-	if resGetOrderDetailByOrderSN.Error != "" {
-		s.Logger.Error("adapter.GetAccessToken : json.Unmarshal(bodyBytes ,&resGetOrderDetailByOrderSN) :",
-			zap.String("error", resGetOrderDetailByOrderSN.Error),
-			zap.String("message", resGetOrderDetailByOrderSN.Message))
-		return nil, errors.New(resGetOrderDetailByOrderSN.Error)
-	}
-
-	// s.Logger.Debug("resGetShopByPartnerPublic", zap.Any("resGetShopByPartnerPublic", resGetOrderDetailByOrderSN))
-
-	return &resGetOrderDetailByOrderSN, nil
+	return &dto.IResOrderDetailByOrderSN{Response: *res}, nil
 }
 
 func (s *shopeeApi)GetOrderDetailListByOrderSN(ctx context.Context, params *IReqShopeeAdapter) ([]dto.IResOrderListWithDetails,error) {
-  state := "SHOP"
-  path  := "/api/v2/order/get_order_detail"
-  gen,err := s.GenerateSignWithPathURL(state, path, params.PartnerID, params.SecretKey, params.ShopID, "", params.AccessToken  )
-  if err != nil {
-    s.Logger.Debug("adapter.GetShopProfile", zap.Error(err))
-    return nil , err }
-
   // cutomize url.Params
-
-  orderSNList := strings.Join(params.OrderSN, ",")
-
-  qUrl := gen.URL.Query()
-  qUrl.Set("order_sn_list", orderSNList)
+  q := url.Values{}
+  q.Set("order_sn_list", strings.Join(params.OrderSN, ","))
   optsQ := []string{
     "total_amount",
     "pending_terms", 
@@ -654,103 +342,38 @@ func (s *shopeeApi)GetOrderDetailListByOrderSN(ctx context.Context, params *IReq
     "package_list", 
     "note", 
   }
-  qUrl.Set("response_optional_fields", strings.Join(optsQ, ",")  )
-
-  gen.URL.RawQuery = qUrl.Encode()
-  // s.Logger.Debug("adapter.GetOrderDetailListByOrderSN", zap.String("cUrl", gen.URL.String()))
-
-  var resp *http.Response
-  resp, err = s.RequestHTTP(gen.Method, gen.URL.String(), nil)
-  if err != nil {
-    s.Logger.Debug("adapter.GetShopProfile.resp", zap.Error(err))
-    return nil,err}
-  defer resp.Body.Close()
-
-  bodyBytes,err := io.ReadAll(resp.Body)
-  if err != nil {
-    s.Logger.Debug("adapter.GetShopProfile.bodyBytes", zap.Error(err))
-    return nil, err
-  }
-
-  // s.Logger.Debug("adapter.GetShopeeProfile", zap.String("bodyBytes", string(bodyBytes) ))
-
-  var parse dto.IResOrderDetailByOrderSN
-  if err := json.Unmarshal(bodyBytes, &parse) ; err != nil { 
-    return nil, errors.New("invalidate parse bodyBytes in adapter") 
-  }
-  if parse.Error != "" {
-    return nil , errors.New(parse.Error)
-  }
+  q.Set("response_optional_fields", strings.Join(optsQ, ",")  )
 
-  // s.Logger.Debug("adapter.GetShopeeProfile", zap.Any("parse", parse.Response.OrderList))
+  res, err := Call[url.Values, dto.IResOrderDetailByOrderSNShopWrapper](ctx, s, "/api/v2/order/get_order_detail", params, q)
+  if err != nil { return nil, err }
+  return res.OrderList, nil
+}
 
-  return parse.Response.OrderList, nil
+func (s *shopeeApi)GetOrderListPageByShopID(ctx context.Context, params *IReqShopeeAdapter, opts *dto.IOptionShopeeQuery) (*dto.IResGetOrderListByShopIDShopWrapper, error) {
+  timeRange := opts.TimeRange
+  if timeRange == "" { timeRange = dto.UPDATE_TIME }
+  pageSize := opts.PageSize
+  if pageSize <= 0 || pageSize > 100 { pageSize = 100 }
+
+  q := url.Values{}
+  q.Set("time_range_field", string(timeRange))
+  q.Set("time_from", strconv.FormatInt(opts.TimeFrom, 10))
+  q.Set("time_to", strconv.FormatInt(opts.TimeTo, 10))
+  q.Set("page_size", strconv.FormatInt(int64(pageSize), 10))
+  q.Set("response_optional_fields", string(dto.OrderStatus))
+  if opts.CursorPage != "" { q.Set("cursor", opts.CursorPage) }
+  if opts.OrderStatus != "" { q.Set("order_status", string(opts.OrderStatus)) }
+  if opts.RequestOrderStatus { q.Set("request_order_status_pending", "true") }
+
+  return Call[url.Values, dto.IResGetOrderListByShopIDShopWrapper](ctx, s, "/api/v2/order/get_order_list", params, q)
 }
 
 func (s *shopeeApi)GetShopProfile(ctx context.Context, params *IReqShopeeAdapter ) (*dto.IResShopGetProfile_ResponseDTO, error)  {
-  // 0. generate sign
-  state := "SHOP"
-  path  := "/api/v2/shop/get_profile"
-  gen,err := s.GenerateSignWithPathURL(state, path, params.PartnerID, params.SecretKey, params.ShopID, "", params.AccessToken  )
-  if err != nil {
-    s.Logger.Debug("adapter.GetShopProfile", zap.Error(err))
-    return nil , err }
-
-  var resp *http.Response
-  resp, err = s.RequestHTTP(gen.Method, gen.URL.String(), nil)
-  if err != nil {
-    s.Logger.Debug("adapter.GetShopProfile.resp", zap.Error(err))
-    return nil,err}
-  defer resp.Body.Close()
-
-  bodyBytes,err := io.ReadAll(resp.Body)
-  if err != nil {
-    s.Logger.Debug("adapter.GetShopProfile.bodyBytes", zap.Error(err))
-    return nil, err}
-
-  // s.Logger.Info("adapter.GetShopeeProfile", zap.String("bodyBytes", string(bodyBytes) ))
-
-  var parse dto.IResShopGetProfileDTO
-  if err := json.Unmarshal(bodyBytes, &parse) ; err != nil { return nil, errors.New("invalidate parse bodyBytes") }
-  if parse.Error != "" {
-    return nil , errors.New(parse.Error)
-  }
-
-  // s.Logger.Info("adapter.GetShopeeProfile", zap.Any("parse", parse) )
-
-  // parse to Entity 
-  return &parse.Resoponse, nil 
+  return Call[url.Values, dto.IResShopGetProfile_ResponseDTO](ctx, s, string(SHOP_GET_PROFILE_API), params, nil)
 }
 
 func (s *shopeeApi)GetShopInfo(ctx context.Context, params *IReqShopeeAdapter) (*dto.IResShopGetShopInfoDTO ,error) {
-  // 0. generate sign
-  state := "SHOP"
-  path  := "/api/v2/shop/get_shop_info"
-  gen,err := s.GenerateSignWithPathURL(state, path, params.PartnerID, params.SecretKey, params.ShopID, "", params.AccessToken  )
-  if err != nil {
-    s.Logger.Debug("adapter.GetShopInfo", zap.Error(err))
-    return nil , err }
-
-  var resp *http.Response
-  resp, err = s.RequestHTTP(gen.Method, gen.URL.String(), nil)
-  if err != nil {
-    s.Logger.Debug("adapter.GetShopInfo.resp", zap.Error(err))
-    return nil,err}
-  defer resp.Body.Close()
-
-  bodyBytes,err := io.ReadAll(resp.Body)
-  if err != nil {
-    s.Logger.Debug("adapter.GetShopInfo.bodyBytes", zap.Error(err))
-    return nil, err}
-
-  // s.Logger.Info("adapter.GetShopeeInfo", zap.String("bodyBytes", string(bodyBytes) ))
-  var parse dto.IResShopGetShopInfoDTO
-  if err := json.Unmarshal(bodyBytes, &parse) ; err != nil { return nil, errors.New("invalidate parse bodyBytes") }
-  if parse.Error != "" { return nil , errors.New(parse.Error) }
-
-  // s.Logger.Info("adapter.GetShopInfo", zap.Any("parse", parse) )
-  // parse to Entity 
-  return &parse, nil 
+  return Call[url.Values, dto.IResShopGetShopInfoDTO](ctx, s, string(SHOP_GET_SHOP_INFO_API), params, nil)
 }
 
 // ------------------------------------ Demo template -----------------------------------
@@ -0,0 +1,470 @@
+package adapter
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"ecommerce/internal/adapter/dto"
+)
+
+// ShopeeBodyEnum : where Call puts the request value
+type ShopeeBodyEnum string
+
+const (
+	BODY_QUERY     ShopeeBodyEnum = "QUERY"     // url.Values / IShopeeQuery, merged into the signed query
+	BODY_JSON      ShopeeBodyEnum = "JSON"      // json body
+	BODY_MULTIPART ShopeeBodyEnum = "MULTIPART" // *ShopeeMultipartForm
+)
+
+// ShopeeEndpoint : one row of the endpoint registry
+type ShopeeEndpoint struct {
+	Path   string
+	Method string
+	Auth   ShopeeTypeAPIEnum
+	Body   ShopeeBodyEnum
+	// Wrapped : payload sits under "response", otherwise at the top level (auth / public / shop info)
+	Wrapped bool
+	// ReadOnly : POST without side effect, retried like a GET
+	ReadOnly bool
+}
+
+// retryable : a write is only replayed when Shopee rejected it before processing (rate limit)
+func (e ShopeeEndpoint) retryable(o shopeeOutcome) bool {
+	if !o.Retryable {
+		return false
+	}
+	return o.RateLimited || e.Method == "GET" || e.ReadOnly
+}
+
+// ::TABLE_METHOD
+// new endpoint = one row here + a few lines calling Call
+var shopeeEndpoints = map[string]ShopeeEndpoint{}
+
+func init() {
+	for _, e := range []ShopeeEndpoint{
+		// auth / public
+		{Path: "/api/v2/auth/token/get", Method: "POST", Auth: PUBLIC, Body: BODY_JSON},
+		{Path: "/api/v2/auth/access_token/get", Method: "POST", Auth: PUBLIC, Body: BODY_JSON},
+		{Path: "/api/v2/public/get_shops_by_partner", Method: "GET", Auth: PUBLIC, Body: BODY_QUERY},
+
+		// shop
+		{Path: string(SHOP_GET_PROFILE_API), Method: "GET", Auth: SHOP, Body: BODY_QUERY, Wrapped: true},
+		{Path: string(SHOP_GET_SHOP_INFO_API), Method: "GET", Auth: SHOP, Body: BODY_QUERY},
+
+		// order
+		{Path: "/api/v2/order/get_order_list", Method: "GET", Auth: SHOP, Body: BODY_QUERY, Wrapped: true},
+		{Path: "/api/v2/order/get_order_detail", Method: "GET", Auth: SHOP, Body: BODY_QUERY, Wrapped: true},
+
+		// product
+		{Path: "/api/v2/product/get_item_list", Method: "GET", Auth: SHOP, Body: BODY_QUERY, Wrapped: true},
+		{Path: "/api/v2/product/get_item_base_info", Method: "GET", Auth: SHOP, Body: BODY_QUERY, Wrapped: true},
+		{Path: "/api/v2/product/get_model_list", Method: "GET", Auth: SHOP, Body: BODY_QUERY, Wrapped: true},
+		{Path: "/api/v2/product/add_item", Method: "POST", Auth: SHOP, Body: BODY_JSON, Wrapped: true},
+		{Path: "/api/v2/product/update_item", Method: "POST", Auth: SHOP, Body: BODY_JSON, Wrapped: true},
+		{Path: "/api/v2/product/update_price", Method: "POST", Auth: SHOP, Body: BODY_JSON, Wrapped: true},
+		{Path: "/api/v2/product/update_stock", Method: "POST", Auth: SHOP, Body: BODY_JSON, Wrapped: true},
+		{Path: "/api/v2/product/unlist_item", Method: "POST", Auth: SHOP, Body: BODY_JSON, Wrapped: true},
+
+		// logistics
+		{Path: "/api/v2/logistics/get_shipping_parameter", Method: "GET", Auth: SHOP, Body: BODY_QUERY, Wrapped: true},
+		{Path: "/api/v2/logistics/get_tracking_number", Method: "GET", Auth: SHOP, Body: BODY_QUERY, Wrapped: true},
+		{Path: "/api/v2/logistics/get_tracking_info", Method: "GET", Auth: SHOP, Body: BODY_QUERY, Wrapped: true},
+		{Path: "/api/v2/logistics/ship_order", Method: "POST", Auth: SHOP, Body: BODY_JSON, Wrapped: true},
+		{Path: "/api/v2/logistics/batch_ship_order", Method: "POST", Auth: SHOP, Body: BODY_JSON, Wrapped: true},
+		{Path: "/api/v2/logistics/create_shipping_document", Method: "POST", Auth: SHOP, Body: BODY_JSON, Wrapped: true},
+		{Path: "/api/v2/logistics/get_shipping_document_result", Method: "POST", Auth: SHOP, Body: BODY_JSON, Wrapped: true, ReadOnly: true},
+		{Path: "/api/v2/logistics/download_shipping_document", Method: "POST", Auth: SHOP, Body: BODY_JSON, ReadOnly: true},
+
+		// payment
+		{Path: "/api/v2/payment/get_escrow_detail", Method: "GET", Auth: SHOP, Body: BODY_QUERY, Wrapped: true},
+		{Path: "/api/v2/payment/get_escrow_list", Method: "GET", Auth: SHOP, Body: BODY_QUERY, Wrapped: true},
+		{Path: "/api/v2/payment/get_payout_detail", Method: "GET", Auth: SHOP, Body: BODY_QUERY, Wrapped: true},
+
+		// returns
+		{Path: "/api/v2/returns/get_return_list", Method: "GET", Auth: SHOP, Body: BODY_QUERY, Wrapped: true},
+		{Path: "/api/v2/returns/get_return_detail", Method: "GET", Auth: SHOP, Body: BODY_QUERY, Wrapped: true},
+		{Path: "/api/v2/returns/confirm", Method: "POST", Auth: SHOP, Body: BODY_JSON, Wrapped: true},
+		{Path: "/api/v2/returns/dispute", Method: "POST", Auth: SHOP, Body: BODY_JSON, Wrapped: true},
+		{Path: "/api/v2/returns/offer", Method: "POST", Auth: SHOP, Body: BODY_JSON, Wrapped: true},
+		{Path: "/api/v2/returns/convert_image", Method: "POST", Auth: SHOP, Body: BODY_MULTIPART, Wrapped: true},
+	} {
+		shopeeEndpoints[e.Path] = e
+	}
+}
+
+// normalizeShopeePath : "/auth/token/get" -> "/api/v2/auth/token/get"
+func normalizeShopeePath(path string) string {
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	if strings.HasPrefix(path, "/api/") {
+		return path
+	}
+	return "/api/v2" + path
+}
+
+// LookupShopeeEndpoint : registry row for path (with or without the /api/v2 prefix)
+func LookupShopeeEndpoint(path string) (ShopeeEndpoint, bool) {
+	e, ok := shopeeEndpoints[normalizeShopeePath(path)]
+	return e, ok
+}
+
+// IShopeeQuery : typed query request, the alternative to a plain url.Values
+type IShopeeQuery interface {
+	Query() url.Values
+}
+
+// ShopeeMultipartForm : request value of BODY_MULTIPART endpoints
+type ShopeeMultipartForm struct {
+	Field string
+	Files []dto.IFileUpload
+}
+
+// shopeeEnvelope : the fields every Shopee answer shares
+type shopeeEnvelope struct {
+	RequestID string          `json:"request_id"`
+	Error     string          `json:"error"`
+	Message   string          `json:"message"`
+	Response  json.RawMessage `json:"response"`
+}
+
+// ShopeeSignBase : string signed for an auth type ; id is the shop_id (SHOP) or merchant_id (MERCHANT).
+// fakeshopee checks signatures with the same function.
+func ShopeeSignBase(auth ShopeeTypeAPIEnum, partnerID string, path string, timestamp string, accessToken string, id string) (string, error) {
+	switch auth {
+	case PUBLIC:
+		// partner_id, api path, timestamp
+		return partnerID + path + timestamp, nil
+	case SHOP, MERCHANT:
+		// partner_id, api path, timestamp, access_token, shop_id / merchant_id
+		return partnerID + path + timestamp + accessToken + id, nil
+	}
+	return "", errors.New("adapter.shopee.signShopeeURL: invalid state")
+}
+
+// ShopeeSign : hex HMAC-SHA256 of baseString with the partner key
+func ShopeeSign(partnerKey string, baseString string) string {
+	h := hmac.New(sha256.New, []byte(partnerKey))
+	h.Write([]byte(baseString))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// signShopeeURL : timestamp + sign + auth query for one endpoint
+func (s *shopeeApi) signShopeeURL(e ShopeeEndpoint, auth ShopeeTypeAPIEnum, params *IReqShopeeAdapter) (*IResGenerateSignWithUri, error) {
+	Url, err := url.Parse(s.BaseURL)
+	if err != nil || Url.Scheme == "" || Url.Host == "" {
+		return nil, errors.New("adapter.shopee.signShopeeURL: invalid base url")
+	}
+
+	timest := strconv.FormatInt(time.Now().Unix(), 10)
+	q := Url.Query()
+	q.Set("partner_id", params.PartnerID)
+	q.Set("timestamp", timest)
+
+	id := ""
+	switch auth {
+	case SHOP:
+		id = params.ShopID
+		q.Set("shop_id", params.ShopID)
+		q.Set("access_token", params.AccessToken)
+	case MERCHANT:
+		id = params.MerchantID
+		q.Set("merchant_id", params.MerchantID)
+		q.Set("access_token", params.AccessToken)
+	}
+	baseString, err := ShopeeSignBase(auth, params.PartnerID, e.Path, timest, params.AccessToken, id)
+	if err != nil {
+		return nil, err
+	}
+	sign := ShopeeSign(params.SecretKey, baseString)
+
+	q.Set("sign", sign)
+	Url.RawQuery = q.Encode()
+	// a base url path (proxy / gateway) goes in front, the signed path stays the Shopee one
+	Url.Path = strings.TrimRight(Url.Path, "/") + e.Path
+
+	code := ""
+	if params.Code != nil {
+		code = *params.Code
+	}
+
+	return &IResGenerateSignWithUri{
+		Method:    e.Method,
+		Path:      e.Path,
+		Sign:      sign,
+		Code:      code,
+		TimeStamp: timest,
+		URL:       Url,
+	}, nil
+}
+
+// shopeeSignedParams : query parameters signShopeeURL sets for an auth type
+func shopeeSignedParams(auth ShopeeTypeAPIEnum) []string {
+	switch auth {
+	case SHOP:
+		return []string{"partner_id", "timestamp", "sign", "shop_id", "access_token"}
+	case MERCHANT:
+		return []string{"partner_id", "timestamp", "sign", "merchant_id", "access_token"}
+	}
+	return []string{"partner_id", "timestamp", "sign"}
+}
+
+// encodeShopeeRequest : req -> (extra query, body, content type) following e.Body
+func encodeShopeeRequest(e ShopeeEndpoint, req any) (url.Values, []byte, string, error) {
+	switch e.Body {
+	case BODY_QUERY:
+		switch v := req.(type) {
+		case nil:
+			return nil, nil, "", nil
+		case url.Values:
+			return v, nil, "", nil
+		case IShopeeQuery:
+			return v.Query(), nil, "", nil
+		}
+		return nil, nil, "", fmt.Errorf("adapter.Call %s : query request must be url.Values or IShopeeQuery, got %T", e.Path, req)
+
+	case BODY_JSON:
+		if req == nil {
+			return nil, nil, "", nil
+		}
+		b, err := json.Marshal(req)
+		if err != nil {
+			return nil, nil, "", err
+		}
+		return nil, b, "application/json", nil
+
+	case BODY_MULTIPART:
+		form, ok := req.(*ShopeeMultipartForm)
+		if !ok || form == nil {
+			return nil, nil, "", fmt.Errorf("adapter.Call %s : multipart request must be *ShopeeMultipartForm, got %T", e.Path, req)
+		}
+		var buf bytes.Buffer
+		w := multipart.NewWriter(&buf)
+		for _, f := range form.Files {
+			part, err := w.CreateFormFile(form.Field, f.Filename)
+			if err != nil {
+				return nil, nil, "", err
+			}
+			if _, err := part.Write(f.Data); err != nil {
+				return nil, nil, "", err
+			}
+		}
+		if err := w.Close(); err != nil {
+			return nil, nil, "", err
+		}
+		return nil, buf.Bytes(), w.FormDataContentType(), nil
+	}
+	return nil, nil, "", fmt.Errorf("adapter.Call %s : unsupported body type %q", e.Path, e.Body)
+}
+
+// do : breaker + rate limit + retry around send ; the body is returned whatever the status
+func (s *shopeeApi) do(ctx context.Context, path string, params *IReqShopeeAdapter, req any) (ShopeeEndpoint, *http.Response, []byte, error) {
+	e, ok := LookupShopeeEndpoint(path)
+	if !ok {
+		return e, nil, nil, fmt.Errorf("adapter.Call : unknown shopee endpoint %s", path)
+	}
+
+	extra, body, contentType, err := encodeShopeeRequest(e, req)
+	if err != nil {
+		return e, nil, nil, err
+	}
+	for _, k := range shopeeSignedParams(e.Auth) {
+		if _, ok := extra[k]; ok {
+			return e, nil, nil, fmt.Errorf("adapter.Call %s : query parameter %s is set by the signature", e.Path, k)
+		}
+	}
+
+	breaker := s.Breakers.get(e.Path)
+	var (
+		lastResp *http.Response
+		lastBody []byte
+		lastErr  error
+	)
+	for attempt := 0; ; attempt++ {
+		if err := s.Breakers.allow(breaker, time.Now()); err != nil {
+			// opened by our own retries : the upstream answer says more than "circuit open"
+			if attempt > 0 {
+				return e, lastResp, lastBody, lastErr
+			}
+			return e, nil, nil, err
+		}
+		if err := s.Limiter.Wait(ctx, params.PartnerID, params.ShopID); err != nil {
+			// no attempt made : free a half-open probe slot
+			s.Breakers.release(breaker)
+			return e, nil, nil, err
+		}
+
+		resp, bodyBytes, err := s.send(ctx, e, params, extra, body, contentType)
+		code := ""
+		if err == nil {
+			code = peekShopeeErrorCode(bodyBytes)
+		}
+		outcome := classifyShopeeAttempt(resp, code, err)
+		s.Breakers.record(breaker, outcome, time.Now())
+		if outcome.RateLimited {
+			s.Limiter.Drain(params.PartnerID, params.ShopID)
+		}
+
+		if err != nil && outcome.Failure {
+			// transport failure (refused, reset, client timeout) : typed like a Shopee outage
+			err = newShopeeAPIError(e.Path, 0, "", "error_network", err.Error())
+		}
+		if !e.retryable(outcome) || attempt >= s.Retry.Max {
+			return e, resp, bodyBytes, err
+		}
+
+		lastResp, lastBody, lastErr = resp, bodyBytes, err
+
+		wait := s.Retry.backoff(attempt, resp)
+		s.Logger.Debug("adapter.Call.retry",
+			zap.String("path", e.Path),
+			zap.Int("attempt", attempt+1),
+			zap.String("reason", outcome.Reason),
+			zap.Duration("wait", wait))
+		if err := sleepCtx(ctx, wait); err != nil {
+			return e, nil, nil, err
+		}
+	}
+}
+
+// send : one signed attempt (fresh timestamp / sign each time)
+func (s *shopeeApi) send(ctx context.Context, e ShopeeEndpoint, params *IReqShopeeAdapter, extra url.Values, body []byte, contentType string) (*http.Response, []byte, error) {
+	gen, err := s.signShopeeURL(e, e.Auth, params)
+	if err != nil {
+		s.Logger.Debug("adapter.Call.sign", zap.String("path", e.Path), zap.Error(err))
+		return nil, nil, err
+	}
+	if len(extra) > 0 {
+		q := gen.URL.Query()
+		for k, v := range extra {
+			q[k] = v
+		}
+		gen.URL.RawQuery = q.Encode()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, e.Method, gen.URL.String(), reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	if contentType != "" {
+		httpReq.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := s.HttpClient.Do(httpReq)
+	if err != nil {
+		// *url.Error carries the full url : access_token and sign must not reach logs / answers
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			urlErr.URL = e.Path
+		}
+		s.Logger.Debug("adapter.Call.resp", zap.String("path", e.Path), zap.Error(err))
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		s.Logger.Debug("adapter.Call.bodyBytes", zap.String("path", e.Path), zap.Error(err))
+		return nil, nil, err
+	}
+	return resp, bodyBytes, nil
+}
+
+// peekShopeeErrorCode : "error" of a json answer, "" for files / success
+func peekShopeeErrorCode(bodyBytes []byte) string {
+	trimmed := bytes.TrimSpace(bodyBytes)
+	if !bytes.HasPrefix(trimmed, []byte("{")) {
+		return ""
+	}
+	var env shopeeEnvelope
+	if err := json.Unmarshal(trimmed, &env); err != nil {
+		return ""
+	}
+	return env.Error
+}
+
+// decodeShopeeEnvelope : uniform error / message / request_id check
+func decodeShopeeEnvelope(e ShopeeEndpoint, resp *http.Response, bodyBytes []byte) (*shopeeEnvelope, error) {
+	var env shopeeEnvelope
+	if err := json.Unmarshal(bodyBytes, &env); err != nil {
+		if resp.StatusCode >= 300 {
+			return nil, newShopeeAPIError(e.Path, resp.StatusCode, "", "error_http", http.StatusText(resp.StatusCode))
+		}
+		return nil, errors.New("invalidate parse bodyBytes in adapter")
+	}
+	if env.Error != "" {
+		return nil, newShopeeAPIError(e.Path, resp.StatusCode, env.RequestID, env.Error, env.Message)
+	}
+	if resp.StatusCode >= 300 {
+		return nil, newShopeeAPIError(e.Path, resp.StatusCode, env.RequestID, "error_http", http.StatusText(resp.StatusCode))
+	}
+	return &env, nil
+}
+
+// Call : signed request to a registered endpoint, Resp is the "response" payload
+// (or the whole body for endpoints that are not Wrapped)
+func Call[Req any, Resp any](ctx context.Context, s *shopeeApi, path string, params *IReqShopeeAdapter, req Req) (*Resp, error) {
+	e, resp, bodyBytes, err := s.do(ctx, path, params, req)
+	if err != nil {
+		return nil, err
+	}
+
+	env, err := decodeShopeeEnvelope(e, resp, bodyBytes)
+	if err != nil {
+		s.Logger.Debug("adapter.Call", zap.String("path", e.Path), zap.Error(err))
+		return nil, err
+	}
+
+	out := new(Resp)
+	raw := bodyBytes
+	if e.Wrapped {
+		raw = env.Response
+	}
+	if len(raw) == 0 || string(raw) == "null" {
+		return out, nil
+	}
+	if err := json.Unmarshal(raw, out); err != nil {
+		s.Logger.Debug("adapter.Call.parse", zap.String("path", e.Path), zap.String("request_id", env.RequestID), zap.Error(err))
+		return nil, errors.New("invalidate parse bodyBytes in adapter")
+	}
+	return out, nil
+}
+
+// CallRaw : signed request to a registered endpoint that may answer a file ;
+// a json body is checked as an envelope, anything else is returned as is
+func CallRaw[Req any](ctx context.Context, s *shopeeApi, path string, params *IReqShopeeAdapter, req Req) ([]byte, error) {
+	e, resp, bodyBytes, err := s.do(ctx, path, params, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") || bytes.HasPrefix(bytes.TrimSpace(bodyBytes), []byte("{")) {
+		if _, err := decodeShopeeEnvelope(e, resp, bodyBytes); err != nil {
+			return nil, err
+		}
+		return nil, nil
+	}
+	if resp.StatusCode >= 300 {
+		return nil, newShopeeAPIError(e.Path, resp.StatusCode, "", "error_http", http.StatusText(resp.StatusCode))
+	}
+	return bodyBytes, nil
+}
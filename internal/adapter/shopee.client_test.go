@@ -0,0 +1,45 @@
+package adapter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"ecommerce/internal/env"
+)
+
+func TestShopeeCallRejectsSignedQueryParams(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Write([]byte(`{"error":"","message":"","response":{}}`))
+	}))
+	defer srv.Close()
+
+	cfg := &env.Config{Shopee: &env.ShopeeConfig{ShopeeHttpTimeout: 5, ShopeeBreakerThreshold: 100, ShopeeBreakerCooldown: 1}}
+	s := NewShopeeAPI(cfg, srv.URL, zap.NewNop()).(*shopeeApi)
+	params := &IReqShopeeAdapter{PartnerID: "1", SecretKey: "key", ShopID: "2", AccessToken: "token"}
+	const path = "/api/v2/order/get_order_list"
+
+	for _, k := range []string{"partner_id", "timestamp", "sign", "shop_id", "access_token"} {
+		q := url.Values{"page_size": {"10"}, k: {"forged"}}
+		if _, err := Call[url.Values, struct{}](context.Background(), s, path, params, q); err == nil {
+			t.Errorf("%s: call went through", k)
+		}
+	}
+	if n := hits.Load(); n != 0 {
+		t.Fatalf("%d requests sent, want none", n)
+	}
+
+	if _, err := Call[url.Values, struct{}](context.Background(), s, path, params, url.Values{"page_size": {"10"}}); err != nil {
+		t.Fatalf("plain query: %v", err)
+	}
+	if n := hits.Load(); n != 1 {
+		t.Errorf("%d requests sent, want 1", n)
+	}
+}
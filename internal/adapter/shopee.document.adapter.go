@@ -0,0 +1,39 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+
+	"ecommerce/internal/adapter/dto"
+)
+
+// Shopee limit for /api/v2/logistics/*_shipping_document
+const ShopeeShippingDocumentMaxOrder = 50
+
+func (s *shopeeApi) CreateShippingDocument(ctx context.Context, params *IReqShopeeAdapter, body *dto.IBCreateShippingDocument) ([]dto.IResShippingDocumentResult, error) {
+	res, err := Call[*dto.IBCreateShippingDocument, dto.IResShippingDocumentWrapper](ctx, s, "/api/v2/logistics/create_shipping_document", params, body)
+	if err != nil {
+		return nil, err
+	}
+	return res.ResultList, nil
+}
+
+func (s *shopeeApi) GetShippingDocumentResult(ctx context.Context, params *IReqShopeeAdapter, body *dto.IBGetShippingDocumentResult) ([]dto.IResShippingDocumentResult, error) {
+	res, err := Call[*dto.IBGetShippingDocumentResult, dto.IResShippingDocumentWrapper](ctx, s, "/api/v2/logistics/get_shipping_document_result", params, body)
+	if err != nil {
+		return nil, err
+	}
+	return res.ResultList, nil
+}
+
+// DownloadShippingDocument : the file on success, a json error body otherwise
+func (s *shopeeApi) DownloadShippingDocument(ctx context.Context, params *IReqShopeeAdapter, body *dto.IBDownloadShippingDocument) ([]byte, error) {
+	bodyBytes, err := CallRaw(ctx, s, "/api/v2/logistics/download_shipping_document", params, body)
+	if err != nil {
+		return nil, err
+	}
+	if len(bodyBytes) == 0 {
+		return nil, errors.New("adapter.DownloadShippingDocument : empty document")
+	}
+	return bodyBytes, nil
+}
@@ -0,0 +1,125 @@
+package adapter
+
+import (
+	"net/http"
+	"strings"
+)
+
+// ShopeeErrorKind : what went wrong with a Shopee call, independent of the endpoint ;
+// match with errors.Is(err, adapter.ErrShopeeXxx)
+type ShopeeErrorKind struct {
+	Code   string // stable, machine readable : the frontend switches on it
+	Status int    // HTTP status we answer with
+	msg    string
+}
+
+func (k *ShopeeErrorKind) Error() string     { return k.msg }
+func (k *ShopeeErrorKind) StatusCode() int   { return k.Status }
+func (k *ShopeeErrorKind) ErrorCode() string { return k.Code }
+
+// 401 stays reserved for our own JWT : a dead shop token is a failed dependency (424)
+var (
+	ErrShopeeInvalidToken        = &ShopeeErrorKind{Code: "SHOPEE_INVALID_TOKEN", Status: http.StatusFailedDependency, msg: "shopee access token is invalid or expired"}
+	ErrShopeeRefreshTokenExpired = &ShopeeErrorKind{Code: "SHOPEE_REFRESH_TOKEN_EXPIRED", Status: http.StatusFailedDependency, msg: "shopee refresh token expired, the shop must be re-authorized"}
+	ErrShopeeRateLimited         = &ShopeeErrorKind{Code: "SHOPEE_RATE_LIMITED", Status: http.StatusTooManyRequests, msg: "shopee rate limit reached"}
+	ErrShopeeInvalidParams       = &ShopeeErrorKind{Code: "SHOPEE_INVALID_PARAMS", Status: http.StatusBadRequest, msg: "shopee rejected the request parameters"}
+	ErrShopeeShopNotAuthorized   = &ShopeeErrorKind{Code: "SHOPEE_SHOP_NOT_AUTHORIZED", Status: http.StatusForbidden, msg: "shop is not authorized for this partner"}
+	ErrShopeeNotFound            = &ShopeeErrorKind{Code: "SHOPEE_NOT_FOUND", Status: http.StatusNotFound, msg: "shopee resource not found"}
+	ErrShopeeUpstreamUnavailable = &ShopeeErrorKind{Code: "SHOPEE_UPSTREAM_UNAVAILABLE", Status: http.StatusServiceUnavailable, msg: "shopee is unavailable, retry later"}
+)
+
+// unclassified Shopee error
+const (
+	shopeeUpstreamErrorCode   = "SHOPEE_UPSTREAM_ERROR"
+	shopeeUpstreamErrorStatus = http.StatusBadGateway
+)
+
+// ShopeeAPIError : one failed Shopee call ; unwraps to its ShopeeErrorKind
+type ShopeeAPIError struct {
+	Path       string
+	HTTPStatus int
+	RequestID  string
+	Code       string // Shopee "error"
+	Message    string // Shopee "message"
+	Kind       *ShopeeErrorKind
+}
+
+func newShopeeAPIError(path string, httpStatus int, requestID string, code string, message string) *ShopeeAPIError {
+	return &ShopeeAPIError{
+		Path:       path,
+		HTTPStatus: httpStatus,
+		RequestID:  requestID,
+		Code:       code,
+		Message:    message,
+		Kind:       classifyShopeeError(path, httpStatus, code, message),
+	}
+}
+
+func (e *ShopeeAPIError) Error() string {
+	if e.Message == "" {
+		return e.Code
+	}
+	return e.Code + ": " + e.Message
+}
+
+func (e *ShopeeAPIError) Unwrap() error {
+	if e.Kind == nil {
+		return nil
+	}
+	return e.Kind
+}
+
+func (e *ShopeeAPIError) StatusCode() int {
+	if e.Kind == nil {
+		return shopeeUpstreamErrorStatus
+	}
+	return e.Kind.Status
+}
+
+func (e *ShopeeAPIError) ErrorCode() string {
+	if e.Kind == nil {
+		return shopeeUpstreamErrorCode
+	}
+	return e.Kind.Code
+}
+
+func (e *ShopeeAPIError) UpstreamRequestID() string { return e.RequestID }
+
+// classifyShopeeError : Shopee "error" / "message" / status -> kind, nil when unknown
+func classifyShopeeError(path string, httpStatus int, code string, message string) *ShopeeErrorKind {
+	msg := strings.ToLower(message)
+
+	switch {
+	case code == "error_circuit_open", code == "error_network",
+		code == "error_server", code == "error_inner", code == "error_busy",
+		httpStatus >= 500:
+		return ErrShopeeUpstreamUnavailable
+
+	case code == shopeeRateLimitCode, httpStatus == http.StatusTooManyRequests:
+		return ErrShopeeRateLimited
+
+	case strings.HasSuffix(path, "/auth/access_token/get") &&
+		(code == "error_auth" || code == "invalid_refresh_token" || strings.Contains(msg, "refresh_token") || strings.Contains(msg, "refresh token")):
+		return ErrShopeeRefreshTokenExpired
+
+	case code == "invalid_access_token", code == "error_auth" && strings.Contains(msg, "access_token"),
+		code == "error_auth" && strings.Contains(msg, "access token"):
+		return ErrShopeeInvalidToken
+
+	case code == "error_permission", code == "error_shop_not_authorized",
+		strings.Contains(msg, "not authorized"), strings.Contains(msg, "no permission"):
+		return ErrShopeeShopNotAuthorized
+
+	case code == "error_not_found", strings.HasSuffix(code, "_not_found"):
+		return ErrShopeeNotFound
+
+	case code == "error_auth":
+		// token/get with a used / expired code, bad sign, bad partner
+		return ErrShopeeInvalidParams
+
+	case strings.HasPrefix(code, "error_param"), code == "error_invalid_param", code == "error_data_check",
+		httpStatus == http.StatusBadRequest:
+		return ErrShopeeInvalidParams
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package adapter
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestClassifyShopeeError(t *testing.T) {
+	const (
+		tokenGet = "/api/v2/auth/access_token/get"
+		orders   = "/api/v2/order/get_order_list"
+	)
+	cases := []struct {
+		name    string
+		path    string
+		status  int
+		code    string
+		message string
+		want    *ShopeeErrorKind
+	}{
+		{"server error code", orders, http.StatusOK, "error_server", "", ErrShopeeUpstreamUnavailable},
+		{"busy", orders, http.StatusOK, "error_busy", "", ErrShopeeUpstreamUnavailable},
+		{"open breaker", orders, 0, "error_circuit_open", "", ErrShopeeUpstreamUnavailable},
+		{"http 502", orders, http.StatusBadGateway, "", "", ErrShopeeUpstreamUnavailable},
+		{"rate limit code", orders, http.StatusOK, shopeeRateLimitCode, "", ErrShopeeRateLimited},
+		{"http 429", orders, http.StatusTooManyRequests, "", "", ErrShopeeRateLimited},
+		{"dead refresh token", tokenGet, http.StatusOK, "error_auth", "Invalid refresh_token", ErrShopeeRefreshTokenExpired},
+		{"invalid refresh token code", tokenGet, http.StatusOK, "invalid_refresh_token", "", ErrShopeeRefreshTokenExpired},
+		{"invalid access token code", orders, http.StatusOK, "invalid_access_token", "", ErrShopeeInvalidToken},
+		{"auth on the access token", orders, http.StatusForbidden, "error_auth", "Invalid access_token.", ErrShopeeInvalidToken},
+		{"permission", orders, http.StatusOK, "error_permission", "", ErrShopeeShopNotAuthorized},
+		{"not authorized message", orders, http.StatusOK, "error_auth", "shop is not authorized", ErrShopeeShopNotAuthorized},
+		{"not found", orders, http.StatusOK, "error_not_found", "", ErrShopeeNotFound},
+		{"order not found", "/api/v2/order/get_order_detail", http.StatusOK, "error_order_not_found", "", ErrShopeeNotFound},
+		{"auth outside token/get", orders, http.StatusOK, "error_auth", "wrong sign", ErrShopeeInvalidParams},
+		{"used auth code", tokenGet, http.StatusOK, "error_param", "Invalid code", ErrShopeeInvalidParams},
+		{"param", orders, http.StatusOK, "error_param", "time_to is invalid", ErrShopeeInvalidParams},
+		{"data check", orders, http.StatusOK, "error_data_check", "", ErrShopeeInvalidParams},
+		{"http 400", orders, http.StatusBadRequest, "", "", ErrShopeeInvalidParams},
+		{"unknown", orders, http.StatusOK, "error_something_new", "", nil},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := classifyShopeeError(c.path, c.status, c.code, c.message)
+			if got != c.want {
+				t.Fatalf("kind = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestShopeeAPIErrorUnclassified(t *testing.T) {
+	err := newShopeeAPIError("/api/v2/order/get_order_list", http.StatusOK, "req-1", "error_something_new", "what")
+	if err.StatusCode() != http.StatusBadGateway || err.ErrorCode() != "SHOPEE_UPSTREAM_ERROR" {
+		t.Errorf("status %d code %s, want 502 SHOPEE_UPSTREAM_ERROR", err.StatusCode(), err.ErrorCode())
+	}
+	if errors.Unwrap(err) != nil {
+		t.Errorf("unwraps to %v, want nil", errors.Unwrap(err))
+	}
+
+	err = newShopeeAPIError("/api/v2/order/get_order_list", http.StatusOK, "req-2", shopeeRateLimitCode, "")
+	if !errors.Is(err, ErrShopeeRateLimited) || err.StatusCode() != http.StatusTooManyRequests {
+		t.Errorf("rate limited: is %v, status %d", errors.Is(err, ErrShopeeRateLimited), err.StatusCode())
+	}
+}
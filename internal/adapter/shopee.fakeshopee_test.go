@@ -0,0 +1,321 @@
+package adapter_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"ecommerce/internal/adapter"
+	"ecommerce/internal/adapter/dto"
+	"ecommerce/internal/adapter/fakeshopee"
+	"ecommerce/internal/env"
+)
+
+// The real adapter against the in-memory Shopee : signing, token flow, pagination, faults.
+
+const (
+	fakePartnerID  = "1000001"
+	fakePartnerKey = "fake-partner-key"
+	fakeShopID     = "2000001"
+	fakeOrders     = 120
+)
+
+type fakeEnv struct {
+	Fake *fakeshopee.Server
+	API  adapter.IShopeeService
+	Seed *fakeshopee.Seed
+	Now  time.Time
+}
+
+// newFakeEnv : seeded fake + adapter pointed at it ; retries are quick, breakers stay closed
+func newFakeEnv(t *testing.T, retries int) *fakeEnv {
+	t.Helper()
+	now := time.Now()
+	partnerID, _ := strconv.ParseInt(fakePartnerID, 10, 64)
+	seed := fakeshopee.DefaultSeed(partnerID, fakePartnerKey, 1, fakeOrders, now)
+	fake := fakeshopee.New(seed, nil)
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	cfg := &env.Config{Shopee: &env.ShopeeConfig{
+		ShopeeHttpTimeout:      5,
+		ShopeeRetryMax:         retries,
+		ShopeeRetryBaseMs:      1,
+		ShopeeRetryMaxMs:       5,
+		ShopeeBreakerThreshold: 100,
+		ShopeeBreakerCooldown:  1,
+	}}
+	return &fakeEnv{Fake: fake, API: adapter.NewShopeeAPI(cfg, srv.URL, zap.NewNop()), Seed: seed, Now: now}
+}
+
+// authorize : auth code -> tokens, the params of every SHOP call
+func (f *fakeEnv) authorize(t *testing.T) (*adapter.IReqShopeeAdapter, string) {
+	t.Helper()
+	code, err := f.Fake.IssueAuthCode(fakePartnerID, fakeShopID)
+	if err != nil {
+		t.Fatalf("IssueAuthCode: %v", err)
+	}
+	res, err := f.API.GetAccessToken(context.Background(), &adapter.IReqShopeeAdapter{
+		PartnerID: fakePartnerID, SecretKey: fakePartnerKey, ShopID: fakeShopID, Code: &code,
+	})
+	if err != nil {
+		t.Fatalf("GetAccessToken: %v", err)
+	}
+	if res.AccessToken == "" || res.RefreshToken == "" {
+		t.Fatalf("GetAccessToken: empty tokens %+v", res)
+	}
+	return &adapter.IReqShopeeAdapter{
+		PartnerID: fakePartnerID, SecretKey: fakePartnerKey, ShopID: fakeShopID, AccessToken: res.AccessToken,
+	}, res.RefreshToken
+}
+
+func shopeeErrorCode(err error) string {
+	var apiErr *adapter.ShopeeAPIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Code
+	}
+	return ""
+}
+
+func TestFakeShopeeAuthCodeToToken(t *testing.T) {
+	f := newFakeEnv(t, 0)
+	ctx := context.Background()
+
+	code, err := f.Fake.IssueAuthCode(fakePartnerID, fakeShopID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := &adapter.IReqShopeeAdapter{PartnerID: fakePartnerID, SecretKey: fakePartnerKey, ShopID: fakeShopID, Code: &code}
+	res, err := f.API.GetAccessToken(ctx, params)
+	if err != nil {
+		t.Fatalf("GetAccessToken: %v", err)
+	}
+
+	info, err := f.API.GetShopInfo(ctx, &adapter.IReqShopeeAdapter{
+		PartnerID: fakePartnerID, SecretKey: fakePartnerKey, ShopID: fakeShopID, AccessToken: res.AccessToken,
+	})
+	if err != nil {
+		t.Fatalf("GetShopInfo: %v", err)
+	}
+	if info.ShopName != f.Seed.Shops[0].ShopName {
+		t.Errorf("shop_name = %q, want %q", info.ShopName, f.Seed.Shops[0].ShopName)
+	}
+
+	// codes are single use
+	if _, err := f.API.GetAccessToken(ctx, params); !errors.Is(err, adapter.ErrShopeeInvalidParams) {
+		t.Errorf("reused code: err = %v, want ErrShopeeInvalidParams", err)
+	}
+}
+
+func TestFakeShopeeOrderListPagination(t *testing.T) {
+	f := newFakeEnv(t, 0)
+	params, _ := f.authorize(t)
+
+	from := f.Now.Add(-14 * 24 * time.Hour).Unix()
+	to := f.Now.Unix()
+	want := map[string]bool{}
+	for _, o := range f.Seed.Orders {
+		if o.UpdateTime >= from && o.UpdateTime <= to {
+			want[o.OrderSN] = true
+		}
+	}
+
+	got := map[string]bool{}
+	opts := &dto.IOptionShopeeQuery{TimeRange: dto.UPDATE_TIME, TimeFrom: from, TimeTo: to, PageSize: 20}
+	pages := 0
+	for {
+		page, err := f.API.GetOrderListPageByShopID(context.Background(), params, opts)
+		if err != nil {
+			t.Fatalf("GetOrderListPageByShopID page %d: %v", pages, err)
+		}
+		pages++
+		for _, o := range page.OrderList {
+			if got[o.OrderSN] {
+				t.Fatalf("order %s returned twice", o.OrderSN)
+			}
+			got[o.OrderSN] = true
+		}
+		if !page.More {
+			break
+		}
+		if page.NextCursor == "" {
+			t.Fatal("more without next_cursor")
+		}
+		opts.CursorPage = page.NextCursor
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("listed %d orders, want %d", len(got), len(want))
+	}
+	for sn := range want {
+		if !got[sn] {
+			t.Errorf("order %s missing", sn)
+		}
+	}
+	if wantPages := (len(want) + 19) / 20; pages != wantPages {
+		t.Errorf("pages = %d, want %d", pages, wantPages)
+	}
+}
+
+func TestFakeShopeeOrderDetailBatch(t *testing.T) {
+	f := newFakeEnv(t, 0)
+	params, _ := f.authorize(t)
+
+	sn := make([]string, 0, 51)
+	for _, o := range f.Seed.Orders[:51] {
+		sn = append(sn, o.OrderSN)
+	}
+
+	params.OrderSN = sn[:50]
+	list, err := f.API.GetOrderDetailListByOrderSN(context.Background(), params)
+	if err != nil {
+		t.Fatalf("50 orders: %v", err)
+	}
+	if len(list) != 50 {
+		t.Fatalf("50 orders: got %d details", len(list))
+	}
+	for i, o := range list {
+		if o.OrderSN != sn[i] || len(o.ItemList) == 0 {
+			t.Errorf("detail %d = %s with %d items", i, o.OrderSN, len(o.ItemList))
+		}
+	}
+
+	params.OrderSN = sn
+	if _, err := f.API.GetOrderDetailListByOrderSN(context.Background(), params); !errors.Is(err, adapter.ErrShopeeInvalidParams) {
+		t.Errorf("51 orders: err = %v, want ErrShopeeInvalidParams", err)
+	}
+}
+
+func TestFakeShopeeExpiredToken(t *testing.T) {
+	f := newFakeEnv(t, 0)
+	ctx := context.Background()
+	params, refresh := f.authorize(t)
+
+	f.Fake.ExpireAccessTokens(fakeShopID)
+	if _, err := f.API.GetShopInfo(ctx, params); !errors.Is(err, adapter.ErrShopeeInvalidToken) {
+		t.Fatalf("expired token: err = %v, want ErrShopeeInvalidToken", err)
+	}
+
+	res, err := f.API.GetRefreshToken(ctx, params, refresh)
+	if err != nil {
+		t.Fatalf("GetRefreshToken: %v", err)
+	}
+	params.AccessToken = res.AccessToken
+	if _, err := f.API.GetShopInfo(ctx, params); err != nil {
+		t.Fatalf("refreshed token: %v", err)
+	}
+
+	// refresh tokens rotate
+	if _, err := f.API.GetRefreshToken(ctx, params, refresh); !errors.Is(err, adapter.ErrShopeeRefreshTokenExpired) {
+		t.Errorf("old refresh token: err = %v, want ErrShopeeRefreshTokenExpired", err)
+	}
+}
+
+func TestFakeShopeeInjectFaultRetried(t *testing.T) {
+	f := newFakeEnv(t, 2)
+	params, _ := f.authorize(t)
+	const path = "/api/v2/shop/get_shop_info"
+
+	// one transient 500 : retried once, then the real answer
+	f.Fake.InjectFault(fakeshopee.Fault{Path: path, Error: "error_server", Times: 1})
+	if _, err := f.API.GetShopInfo(context.Background(), params); err != nil {
+		t.Fatalf("transient fault: %v", err)
+	}
+	if n := f.Fake.RequestCount(path); n != 2 {
+		t.Errorf("transient fault: %d requests, want 2", n)
+	}
+
+	// lasting outage : 1 + 2 retries, then a typed error
+	f.Fake.InjectFault(fakeshopee.Fault{Path: path, Error: "error_server"})
+	_, err := f.API.GetShopInfo(context.Background(), params)
+	if !errors.Is(err, adapter.ErrShopeeUpstreamUnavailable) {
+		t.Fatalf("outage: err = %v, want ErrShopeeUpstreamUnavailable", err)
+	}
+	if n := f.Fake.RequestCount(path); n != 5 {
+		t.Errorf("outage: %d requests, want 5", n)
+	}
+	f.Fake.ClearFaults()
+}
+
+func TestFakeShopeeInjectFaultNotRetried(t *testing.T) {
+	f := newFakeEnv(t, 2)
+	params, _ := f.authorize(t)
+	const path = "/api/v2/shop/get_shop_info"
+
+	// a non transient error is not replayed
+	f.Fake.InjectFault(fakeshopee.Fault{Path: path, Status: 403, Error: "error_permission", Message: "no permission", Times: 1})
+	if _, err := f.API.GetShopInfo(context.Background(), params); !errors.Is(err, adapter.ErrShopeeShopNotAuthorized) {
+		t.Fatalf("err = %v, want ErrShopeeShopNotAuthorized", err)
+	}
+	if n := f.Fake.RequestCount(path); n != 1 {
+		t.Errorf("%d requests, want 1", n)
+	}
+}
+
+func TestFakeShopeeRateLimit(t *testing.T) {
+	f := newFakeEnv(t, 0)
+	params, _ := f.authorize(t)
+	ctx := context.Background()
+
+	f.Fake.SetRateLimit(1)
+	var limited error
+	for i := 0; i < 3 && limited == nil; i++ {
+		_, limited = f.API.GetShopInfo(ctx, params)
+	}
+	if !errors.Is(limited, adapter.ErrShopeeRateLimited) {
+		t.Fatalf("err = %v, want ErrShopeeRateLimited", limited)
+	}
+	if code := shopeeErrorCode(limited); code != "error_too_many_request" {
+		t.Errorf("shopee code = %q", code)
+	}
+
+	f.Fake.SetRateLimit(0)
+	if _, err := f.API.GetShopInfo(ctx, params); err != nil {
+		t.Fatalf("limit off: %v", err)
+	}
+}
+
+func TestFakeShopeeBadSignature(t *testing.T) {
+	f := newFakeEnv(t, 0)
+	params, _ := f.authorize(t)
+
+	params.SecretKey = "not-the-partner-key"
+	_, err := f.API.GetShopInfo(context.Background(), params)
+	if code := shopeeErrorCode(err); code != "error_sign" {
+		t.Fatalf("err = %v, want error_sign", err)
+	}
+}
+
+// GenerateSignWithPathURL and the fake share ShopeeSignBase / ShopeeSign : a url signed by hand is accepted
+func TestFakeShopeeGenerateSignWithPathURL(t *testing.T) {
+	f := newFakeEnv(t, 0)
+	params, _ := f.authorize(t)
+
+	gen, err := f.API.GenerateSignWithPathURL(string(adapter.SHOP), "/shop/get_shop_info",
+		params.PartnerID, params.SecretKey, params.ShopID, "", params.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, err := adapter.ShopeeSignBase(adapter.SHOP, params.PartnerID, gen.Path, gen.TimeStamp, params.AccessToken, params.ShopID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := adapter.ShopeeSign(params.SecretKey, base); gen.Sign != want {
+		t.Fatalf("sign = %s, want %s", gen.Sign, want)
+	}
+
+	resp, err := http.Get(gen.URL.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("signed url: status %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,51 @@
+package adapter
+
+import (
+	"context"
+	"net/url"
+
+	"ecommerce/internal/adapter/dto"
+)
+
+// Shopee limit for /api/v2/logistics/batch_ship_order
+const ShopeeBatchShipMaxOrder = 50
+
+func (s *shopeeApi) GetShippingParameter(ctx context.Context, params *IReqShopeeAdapter, orderSN string, packageNumber string) (*dto.IResShippingParameterWrapper, error) {
+	q := url.Values{}
+	q.Set("order_sn", orderSN)
+	if packageNumber != "" {
+		q.Set("package_number", packageNumber)
+	}
+
+	return Call[url.Values, dto.IResShippingParameterWrapper](ctx, s, "/api/v2/logistics/get_shipping_parameter", params, q)
+}
+
+func (s *shopeeApi) ShipOrder(ctx context.Context, params *IReqShopeeAdapter, body *dto.IBShipOrder) error {
+	_, err := Call[*dto.IBShipOrder, struct{}](ctx, s, "/api/v2/logistics/ship_order", params, body)
+	return err
+}
+
+func (s *shopeeApi) BatchShipOrder(ctx context.Context, params *IReqShopeeAdapter, body *dto.IBBatchShipOrder) (*dto.IResBatchShipOrderWrapper, error) {
+	return Call[*dto.IBBatchShipOrder, dto.IResBatchShipOrderWrapper](ctx, s, "/api/v2/logistics/batch_ship_order", params, body)
+}
+
+func (s *shopeeApi) GetTrackingNumber(ctx context.Context, params *IReqShopeeAdapter, orderSN string, packageNumber string) (*dto.IResTrackingNumberWrapper, error) {
+	q := url.Values{}
+	q.Set("order_sn", orderSN)
+	if packageNumber != "" {
+		q.Set("package_number", packageNumber)
+	}
+	q.Set("response_optional_fields", "plp_number,first_mile_tracking_number,last_mile_tracking_number")
+
+	return Call[url.Values, dto.IResTrackingNumberWrapper](ctx, s, "/api/v2/logistics/get_tracking_number", params, q)
+}
+
+func (s *shopeeApi) GetTrackingInfo(ctx context.Context, params *IReqShopeeAdapter, orderSN string, packageNumber string) (*dto.IResTrackingInfoWrapper, error) {
+	q := url.Values{}
+	q.Set("order_sn", orderSN)
+	if packageNumber != "" {
+		q.Set("package_number", packageNumber)
+	}
+
+	return Call[url.Values, dto.IResTrackingInfoWrapper](ctx, s, "/api/v2/logistics/get_tracking_info", params, q)
+}
@@ -0,0 +1,53 @@
+package adapter
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"time"
+
+	"ecommerce/internal/adapter/dto"
+)
+
+// Shopee limits for /api/v2/payment/get_escrow_list and get_payout_detail
+const (
+	ShopeePaymentMaxRange    = time.Hour * 24 * 14
+	ShopeePaymentMaxPageSize = 100
+)
+
+func paymentPageQuery(fromKey string, toKey string, opts *dto.IOptionShopeePageQuery) url.Values {
+	pageSize := opts.PageSize
+	if pageSize <= 0 || pageSize > ShopeePaymentMaxPageSize {
+		pageSize = ShopeePaymentMaxPageSize
+	}
+	pageNo := opts.PageNo
+	if pageNo <= 0 {
+		pageNo = 1
+	}
+
+	q := url.Values{}
+	q.Set(fromKey, strconv.FormatInt(opts.TimeFrom, 10))
+	q.Set(toKey, strconv.FormatInt(opts.TimeTo, 10))
+	q.Set("page_size", strconv.FormatInt(int64(pageSize), 10))
+	q.Set("page_no", strconv.FormatInt(int64(pageNo), 10))
+	return q
+}
+
+func (s *shopeeApi) GetEscrowDetail(ctx context.Context, params *IReqShopeeAdapter, orderSN string) (*dto.IResEscrowDetailWrapper, error) {
+	q := url.Values{}
+	q.Set("order_sn", orderSN)
+
+	return Call[url.Values, dto.IResEscrowDetailWrapper](ctx, s, "/api/v2/payment/get_escrow_detail", params, q)
+}
+
+func (s *shopeeApi) GetEscrowList(ctx context.Context, params *IReqShopeeAdapter, opts *dto.IOptionShopeePageQuery) (*dto.IResEscrowListWrapper, error) {
+	q := paymentPageQuery("release_time_from", "release_time_to", opts)
+
+	return Call[url.Values, dto.IResEscrowListWrapper](ctx, s, "/api/v2/payment/get_escrow_list", params, q)
+}
+
+func (s *shopeeApi) GetPayoutDetail(ctx context.Context, params *IReqShopeeAdapter, opts *dto.IOptionShopeePageQuery) (*dto.IResPayoutDetailWrapper, error) {
+	q := paymentPageQuery("payout_time_from", "payout_time_to", opts)
+
+	return Call[url.Values, dto.IResPayoutDetailWrapper](ctx, s, "/api/v2/payment/get_payout_detail", params, q)
+}
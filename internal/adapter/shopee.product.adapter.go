@@ -0,0 +1,95 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"ecommerce/internal/adapter/dto"
+)
+
+// Shopee limits for /api/v2/product/*
+const (
+	ShopeeItemListMaxPageSize = 100
+	ShopeeItemBaseInfoMaxItem = 50
+)
+
+func (s *shopeeApi) GetItemListByShopID(ctx context.Context, params *IReqShopeeAdapter, opts *dto.IOptionShopeeItemListQuery) (*dto.IResGetItemListWrapper, error) {
+	pageSize := opts.PageSize
+	if pageSize <= 0 || pageSize > ShopeeItemListMaxPageSize {
+		pageSize = ShopeeItemListMaxPageSize
+	}
+	status := opts.ItemStatus
+	if len(status) == 0 {
+		status = []dto.IEnumShopeeItemStatus{dto.ITEM_NORMAL}
+	}
+
+	q := url.Values{}
+	q.Set("offset", strconv.FormatInt(int64(opts.Offset), 10))
+	q.Set("page_size", strconv.FormatInt(int64(pageSize), 10))
+	for _, st := range status {
+		q.Add("item_status", string(st))
+	}
+	if opts.UpdateTimeFrom > 0 {
+		q.Set("update_time_from", strconv.FormatInt(opts.UpdateTimeFrom, 10))
+	}
+	if opts.UpdateTimeTo > 0 {
+		q.Set("update_time_to", strconv.FormatInt(opts.UpdateTimeTo, 10))
+	}
+
+	return Call[url.Values, dto.IResGetItemListWrapper](ctx, s, "/api/v2/product/get_item_list", params, q)
+}
+
+func (s *shopeeApi) GetItemBaseInfo(ctx context.Context, params *IReqShopeeAdapter, itemID []int64) ([]dto.IResItemBaseInfo, error) {
+	if len(itemID) == 0 {
+		return []dto.IResItemBaseInfo{}, nil
+	}
+	if len(itemID) > ShopeeItemBaseInfoMaxItem {
+		return nil, fmt.Errorf("adapter.GetItemBaseInfo : max %d item_id per request", ShopeeItemBaseInfoMaxItem)
+	}
+
+	ids := make([]byte, 0, len(itemID)*12)
+	for i, id := range itemID {
+		if i > 0 {
+			ids = append(ids, ',')
+		}
+		ids = strconv.AppendInt(ids, id, 10)
+	}
+
+	q := url.Values{}
+	q.Set("item_id_list", string(ids))
+
+	res, err := Call[url.Values, dto.IResGetItemBaseInfoWrapper](ctx, s, "/api/v2/product/get_item_base_info", params, q)
+	if err != nil {
+		return nil, err
+	}
+	return res.ItemList, nil
+}
+
+func (s *shopeeApi) GetModelList(ctx context.Context, params *IReqShopeeAdapter, itemID int64) (*dto.IResGetModelListWrapper, error) {
+	q := url.Values{}
+	q.Set("item_id", strconv.FormatInt(itemID, 10))
+
+	return Call[url.Values, dto.IResGetModelListWrapper](ctx, s, "/api/v2/product/get_model_list", params, q)
+}
+
+func (s *shopeeApi) AddItem(ctx context.Context, params *IReqShopeeAdapter, body *dto.IBAddItem) (*dto.IResAddItemWrapper, error) {
+	return Call[*dto.IBAddItem, dto.IResAddItemWrapper](ctx, s, "/api/v2/product/add_item", params, body)
+}
+
+func (s *shopeeApi) UpdateItem(ctx context.Context, params *IReqShopeeAdapter, body *dto.IBUpdateItem) (*dto.IResAddItemWrapper, error) {
+	return Call[*dto.IBUpdateItem, dto.IResAddItemWrapper](ctx, s, "/api/v2/product/update_item", params, body)
+}
+
+func (s *shopeeApi) UpdatePrice(ctx context.Context, params *IReqShopeeAdapter, body *dto.IBUpdatePrice) (*dto.IResUpdatePriceWrapper, error) {
+	return Call[*dto.IBUpdatePrice, dto.IResUpdatePriceWrapper](ctx, s, "/api/v2/product/update_price", params, body)
+}
+
+func (s *shopeeApi) UpdateStock(ctx context.Context, params *IReqShopeeAdapter, body *dto.IBUpdateStock) (*dto.IResUpdateStockWrapper, error) {
+	return Call[*dto.IBUpdateStock, dto.IResUpdateStockWrapper](ctx, s, "/api/v2/product/update_stock", params, body)
+}
+
+func (s *shopeeApi) UnlistItem(ctx context.Context, params *IReqShopeeAdapter, body *dto.IBUnlistItem) (*dto.IResUnlistItemWrapper, error) {
+	return Call[*dto.IBUnlistItem, dto.IResUnlistItemWrapper](ctx, s, "/api/v2/product/unlist_item", params, body)
+}
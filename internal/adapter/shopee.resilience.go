@@ -0,0 +1,413 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"ecommerce/internal/env"
+)
+
+// ----------------- rate limit : token bucket per partner / per shop -----------------
+
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64 // tokens per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int, now time.Time) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: now}
+}
+
+// reserve : take one token, the wait before it may be used (tokens can go negative = queued)
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// cancel : give back a reserved token (caller gave up waiting)
+func (b *tokenBucket) cancel() {
+	b.mu.Lock()
+	b.tokens++
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.mu.Unlock()
+}
+
+// drain : Shopee said error_too_many_request, stop bursting until the bucket refills
+func (b *tokenBucket) drain() {
+	b.mu.Lock()
+	if b.tokens > 0 {
+		b.tokens = 0
+	}
+	b.mu.Unlock()
+}
+
+type shopeeRateLimiter struct {
+	mu           sync.Mutex
+	partnerRate  float64
+	partnerBurst int
+	shopRate     float64
+	shopBurst    int
+	buckets      map[string]*tokenBucket
+}
+
+func newShopeeRateLimiter(cfg *env.ShopeeConfig) *shopeeRateLimiter {
+	l := &shopeeRateLimiter{buckets: map[string]*tokenBucket{}}
+	if cfg != nil {
+		l.partnerRate, l.partnerBurst = cfg.ShopeeRatePartnerRPS, cfg.ShopeeRatePartnerBurst
+		l.shopRate, l.shopBurst = cfg.ShopeeRateShopRPS, cfg.ShopeeRateShopBurst
+	}
+	return l
+}
+
+// bucket : nil when the limit is off (rate <= 0) or the key is empty
+func (l *shopeeRateLimiter) bucket(key string, id string, rate float64, burst int) *tokenBucket {
+	if rate <= 0 || id == "" {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b, ok := l.buckets[key+id]
+	if !ok {
+		b = newTokenBucket(rate, burst, time.Now())
+		l.buckets[key+id] = b
+	}
+	return b
+}
+
+func (l *shopeeRateLimiter) bucketsFor(partnerID string, shopID string) []*tokenBucket {
+	res := []*tokenBucket{}
+	if b := l.bucket("partner:", partnerID, l.partnerRate, l.partnerBurst); b != nil {
+		res = append(res, b)
+	}
+	if shopID == "" {
+		return res
+	}
+	if b := l.bucket("shop:", partnerID+":"+shopID, l.shopRate, l.shopBurst); b != nil {
+		res = append(res, b)
+	}
+	return res
+}
+
+// Wait : block until both the partner and the shop bucket allow one request ; canceled, the request
+// is not sent and every token already reserved for it goes back
+func (l *shopeeRateLimiter) Wait(ctx context.Context, partnerID string, shopID string) error {
+	buckets := l.bucketsFor(partnerID, shopID)
+	for i, b := range buckets {
+		wait := b.reserve(time.Now())
+		if wait <= 0 {
+			continue
+		}
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			for _, reserved := range buckets[:i+1] {
+				reserved.cancel()
+			}
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+	return nil
+}
+
+func (l *shopeeRateLimiter) Drain(partnerID string, shopID string) {
+	for _, b := range l.bucketsFor(partnerID, shopID) {
+		b.drain()
+	}
+}
+
+// ----------------- retry : retryable Shopee codes / 5xx, jittered backoff -----------------
+
+// Shopee error codes worth another attempt (transient on their side)
+var shopeeRetryableCodes = map[string]bool{
+	"error_too_many_request": true,
+	"error_server":           true,
+	"error_inner":            true,
+	"error_busy":             true,
+	"error_network":          true,
+}
+
+const shopeeRateLimitCode = "error_too_many_request"
+
+type shopeeRetryPolicy struct {
+	Max  int
+	Base time.Duration
+	Cap  time.Duration
+}
+
+func newShopeeRetryPolicy(cfg *env.ShopeeConfig) shopeeRetryPolicy {
+	p := shopeeRetryPolicy{Max: 3, Base: 200 * time.Millisecond, Cap: 5 * time.Second}
+	if cfg != nil {
+		p.Max = cfg.ShopeeRetryMax
+		p.Base = time.Duration(cfg.ShopeeRetryBaseMs) * time.Millisecond
+		p.Cap = time.Duration(cfg.ShopeeRetryMaxMs) * time.Millisecond
+	}
+	if p.Base <= 0 {
+		p.Base = 200 * time.Millisecond
+	}
+	if p.Cap < p.Base {
+		p.Cap = p.Base
+	}
+	return p
+}
+
+// backoff : full jitter, rand(0, min(cap, base*2^attempt)), never below Retry-After
+func (p shopeeRetryPolicy) backoff(attempt int, resp *http.Response) time.Duration {
+	d := p.Base << attempt
+	if d <= 0 || d > p.Cap {
+		d = p.Cap
+	}
+	d = time.Duration(rand.Int63n(int64(d)) + 1)
+
+	if resp != nil {
+		if sec, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && sec > 0 {
+			if ra := time.Duration(sec) * time.Second; ra > d {
+				d = ra
+			}
+		}
+	}
+	return d
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// shopeeOutcome : how one attempt went, for retry and breaker
+type shopeeOutcome struct {
+	Retryable   bool // worth another attempt
+	RateLimited bool // error_too_many_request / 429 : Shopee did not process it
+	Canceled    bool // our ctx gave up : says nothing about the endpoint
+	Failure     bool // counts against the endpoint breaker
+	Reason      string
+}
+
+func classifyShopeeAttempt(resp *http.Response, code string, err error) shopeeOutcome {
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return shopeeOutcome{Canceled: true, Reason: err.Error()}
+		}
+		return shopeeOutcome{Retryable: true, Failure: true, Reason: err.Error()}
+	}
+	if code == shopeeRateLimitCode || resp.StatusCode == http.StatusTooManyRequests {
+		return shopeeOutcome{Retryable: true, RateLimited: true, Reason: shopeeRateLimitCode}
+	}
+	if shopeeRetryableCodes[code] {
+		return shopeeOutcome{Retryable: true, Failure: true, Reason: code}
+	}
+	if resp.StatusCode >= 500 {
+		return shopeeOutcome{Retryable: true, Failure: true, Reason: "http " + strconv.Itoa(resp.StatusCode)}
+	}
+	return shopeeOutcome{}
+}
+
+// ----------------- circuit breaker per endpoint -----------------
+
+type ShopeeCircuitStateEnum string
+
+const (
+	CIRCUIT_CLOSED    ShopeeCircuitStateEnum = "CLOSED"
+	CIRCUIT_OPEN      ShopeeCircuitStateEnum = "OPEN"
+	CIRCUIT_HALF_OPEN ShopeeCircuitStateEnum = "HALF_OPEN"
+)
+
+// ShopeeCircuitBreakerState : admin view of one endpoint breaker
+type ShopeeCircuitBreakerState struct {
+	Path          string                 `json:"path"`
+	State         ShopeeCircuitStateEnum `json:"state"`
+	Failures      int                    `json:"failures"`
+	TotalFailures int64                  `json:"total_failures"`
+	TotalRequests int64                  `json:"total_requests"`
+	LastError     string                 `json:"last_error,omitempty"`
+	LastFailureAt *time.Time             `json:"last_failure_at,omitempty"`
+	OpenedAt      *time.Time             `json:"opened_at,omitempty"`
+	RetryAt       *time.Time             `json:"retry_at,omitempty"`
+}
+
+type shopeeBreaker struct {
+	mu            sync.Mutex
+	path          string
+	state         ShopeeCircuitStateEnum
+	failures      int
+	totalFailures int64
+	totalRequests int64
+	lastError     string
+	lastFailureAt time.Time
+	openedAt      time.Time
+	probing       bool
+}
+
+type shopeeBreakers struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	m         map[string]*shopeeBreaker
+}
+
+func newShopeeBreakers(cfg *env.ShopeeConfig) *shopeeBreakers {
+	b := &shopeeBreakers{threshold: 5, cooldown: 30 * time.Second, m: map[string]*shopeeBreaker{}}
+	if cfg != nil {
+		if cfg.ShopeeBreakerThreshold > 0 {
+			b.threshold = cfg.ShopeeBreakerThreshold
+		}
+		if cfg.ShopeeBreakerCooldown > 0 {
+			b.cooldown = time.Duration(cfg.ShopeeBreakerCooldown) * time.Second
+		}
+	}
+	return b
+}
+
+func (bs *shopeeBreakers) get(path string) *shopeeBreaker {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	b, ok := bs.m[path]
+	if !ok {
+		b = &shopeeBreaker{path: path, state: CIRCUIT_CLOSED}
+		bs.m[path] = b
+	}
+	return b
+}
+
+// allow : closed passes, open rejects until cooldown, then a single half-open probe
+func (bs *shopeeBreakers) allow(b *shopeeBreaker, now time.Time) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case CIRCUIT_OPEN:
+		if now.Sub(b.openedAt) < bs.cooldown {
+			return newShopeeAPIError(b.path, http.StatusServiceUnavailable, "", "error_circuit_open", "circuit open until "+b.openedAt.Add(bs.cooldown).Format(time.RFC3339))
+		}
+		b.state = CIRCUIT_HALF_OPEN
+		b.probing = true
+	case CIRCUIT_HALF_OPEN:
+		if b.probing {
+			return newShopeeAPIError(b.path, http.StatusServiceUnavailable, "", "error_circuit_open", "circuit half-open, probe in flight")
+		}
+		b.probing = true
+	}
+	b.totalRequests++
+	return nil
+}
+
+func (bs *shopeeBreakers) record(b *shopeeBreaker, o shopeeOutcome, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	// canceled : neutral like release, a half-open breaker waits for the next probe
+	if o.Canceled {
+		return
+	}
+	if !o.Failure {
+		// rate limited : endpoint is alive, only a half-open probe needs another go
+		if o.RateLimited && b.state == CIRCUIT_HALF_OPEN {
+			return
+		}
+		b.state = CIRCUIT_CLOSED
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	b.totalFailures++
+	b.lastError = o.Reason
+	b.lastFailureAt = now
+	if b.state == CIRCUIT_HALF_OPEN || b.failures >= bs.threshold {
+		b.state = CIRCUIT_OPEN
+		b.openedAt = now
+	}
+}
+
+// release : allowed but never sent
+func (bs *shopeeBreakers) release(b *shopeeBreaker) {
+	b.mu.Lock()
+	b.probing = false
+	b.totalRequests--
+	b.mu.Unlock()
+}
+
+func (bs *shopeeBreakers) snapshot() []ShopeeCircuitBreakerState {
+	bs.mu.Lock()
+	list := make([]*shopeeBreaker, 0, len(bs.m))
+	for _, b := range bs.m {
+		list = append(list, b)
+	}
+	bs.mu.Unlock()
+
+	res := make([]ShopeeCircuitBreakerState, 0, len(list))
+	for _, b := range list {
+		b.mu.Lock()
+		st := ShopeeCircuitBreakerState{
+			Path:          b.path,
+			State:         b.state,
+			Failures:      b.failures,
+			TotalFailures: b.totalFailures,
+			TotalRequests: b.totalRequests,
+			LastError:     b.lastError,
+		}
+		if !b.lastFailureAt.IsZero() {
+			t := b.lastFailureAt
+			st.LastFailureAt = &t
+		}
+		if b.state != CIRCUIT_CLOSED {
+			opened, retry := b.openedAt, b.openedAt.Add(bs.cooldown)
+			st.OpenedAt, st.RetryAt = &opened, &retry
+		}
+		b.mu.Unlock()
+		res = append(res, st)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Path < res[j].Path })
+	return res
+}
+
+// reset : path "" closes every breaker, false when the path has none
+func (bs *shopeeBreakers) reset(path string) bool {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	found := false
+	for p, b := range bs.m {
+		if path != "" && p != path {
+			continue
+		}
+		b.mu.Lock()
+		b.state, b.failures, b.probing = CIRCUIT_CLOSED, 0, false
+		b.mu.Unlock()
+		found = true
+	}
+	return found || path == ""
+}
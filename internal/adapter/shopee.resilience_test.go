@@ -0,0 +1,121 @@
+package adapter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"ecommerce/internal/env"
+)
+
+var (
+	outcomeOK          = shopeeOutcome{}
+	outcomeFailure     = shopeeOutcome{Retryable: true, Failure: true, Reason: "error_server"}
+	outcomeRateLimited = shopeeOutcome{Retryable: true, RateLimited: true, Reason: shopeeRateLimitCode}
+	outcomeCanceled    = shopeeOutcome{Canceled: true, Reason: context.Canceled.Error()}
+)
+
+func TestShopeeBreakerThreshold(t *testing.T) {
+	f, ok, rl, cancel := outcomeFailure, outcomeOK, outcomeRateLimited, outcomeCanceled
+	cases := []struct {
+		name     string
+		outcomes []shopeeOutcome
+		want     ShopeeCircuitStateEnum
+	}{
+		{"below threshold", []shopeeOutcome{f, f}, CIRCUIT_CLOSED},
+		{"threshold opens", []shopeeOutcome{f, f, f}, CIRCUIT_OPEN},
+		{"success resets the count", []shopeeOutcome{f, f, ok, f, f}, CIRCUIT_CLOSED},
+		{"rate limit is no failure", []shopeeOutcome{f, f, rl}, CIRCUIT_CLOSED},
+		{"canceled keeps the count", []shopeeOutcome{f, f, cancel, f}, CIRCUIT_OPEN},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			bs := newShopeeBreakers(&env.ShopeeConfig{ShopeeBreakerThreshold: 3, ShopeeBreakerCooldown: 30})
+			b := bs.get("/api/v2/order/get_order_list")
+			now := time.Now()
+			for _, o := range c.outcomes {
+				if err := bs.allow(b, now); err != nil {
+					t.Fatalf("allow: %v", err)
+				}
+				bs.record(b, o, now)
+			}
+			if b.state != c.want {
+				t.Fatalf("state %s, want %s", b.state, c.want)
+			}
+			if c.want == CIRCUIT_OPEN && bs.allow(b, now.Add(time.Second)) == nil {
+				t.Error("open breaker let a call through before the cooldown")
+			}
+		})
+	}
+}
+
+func TestShopeeBreakerHalfOpenProbe(t *testing.T) {
+	cases := []struct {
+		name      string
+		probe     shopeeOutcome
+		want      ShopeeCircuitStateEnum
+		nextAllow bool // a call right after the probe
+	}{
+		{"success closes", outcomeOK, CIRCUIT_CLOSED, true},
+		{"failure reopens", outcomeFailure, CIRCUIT_OPEN, false},
+		{"rate limited probe stays half-open", outcomeRateLimited, CIRCUIT_HALF_OPEN, true},
+		{"canceled probe stays half-open", outcomeCanceled, CIRCUIT_HALF_OPEN, true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			bs := newShopeeBreakers(&env.ShopeeConfig{ShopeeBreakerThreshold: 2, ShopeeBreakerCooldown: 30})
+			b := bs.get("/api/v2/order/get_order_detail")
+			now := time.Now()
+			for i := 0; i < 2; i++ {
+				if err := bs.allow(b, now); err != nil {
+					t.Fatalf("allow: %v", err)
+				}
+				bs.record(b, outcomeFailure, now)
+			}
+
+			afterCooldown := now.Add(31 * time.Second)
+			if err := bs.allow(b, afterCooldown); err != nil {
+				t.Fatalf("probe refused: %v", err)
+			}
+			if b.state != CIRCUIT_HALF_OPEN {
+				t.Fatalf("state %s, want HALF_OPEN", b.state)
+			}
+			// a single probe : the next caller waits for its outcome
+			if bs.allow(b, afterCooldown) == nil {
+				t.Fatal("second call allowed while the probe is in flight")
+			}
+
+			bs.record(b, c.probe, afterCooldown)
+			if b.state != c.want {
+				t.Fatalf("state %s, want %s", b.state, c.want)
+			}
+			if got := bs.allow(b, afterCooldown) == nil; got != c.nextAllow {
+				t.Errorf("next call allowed %v, want %v", got, c.nextAllow)
+			}
+		})
+	}
+}
+
+func TestShopeeRateLimiterCancelGivesBackEveryToken(t *testing.T) {
+	// partner burst 2, shop burst 1 : the second call gets its partner token and queues on the shop
+	l := newShopeeRateLimiter(&env.ShopeeConfig{
+		ShopeeRatePartnerRPS: 0.001, ShopeeRatePartnerBurst: 2,
+		ShopeeRateShopRPS: 0.001, ShopeeRateShopBurst: 1,
+	})
+	if err := l.Wait(context.Background(), "1", "10"); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := l.Wait(ctx, "1", "10"); err == nil {
+		t.Fatal("wait on an empty shop bucket returned before the deadline")
+	}
+
+	// another shop of the partner still has the partner token the canceled call took
+	other, cancelOther := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancelOther()
+	if err := l.Wait(other, "1", "20"); err != nil {
+		t.Fatalf("partner token not given back: %v", err)
+	}
+}
@@ -0,0 +1,82 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"ecommerce/internal/adapter/dto"
+)
+
+// Shopee limits for /api/v2/returns/*
+const (
+	ShopeeReturnListMaxPageSize = 100
+	ShopeeReturnMaxImage        = 3
+	ShopeeReturnListMaxRange    = time.Hour * 24 * 14
+)
+
+func (s *shopeeApi) GetReturnList(ctx context.Context, params *IReqShopeeAdapter, opts *dto.IOptionShopeeReturnListQuery) (*dto.IResReturnListWrapper, error) {
+	pageSize := opts.PageSize
+	if pageSize <= 0 || pageSize > ShopeeReturnListMaxPageSize {
+		pageSize = ShopeeReturnListMaxPageSize
+	}
+
+	q := url.Values{}
+	q.Set("page_no", strconv.FormatInt(int64(opts.PageNo), 10))
+	q.Set("page_size", strconv.FormatInt(int64(pageSize), 10))
+	if opts.CreateTimeFrom > 0 {
+		q.Set("create_time_from", strconv.FormatInt(opts.CreateTimeFrom, 10))
+	}
+	if opts.CreateTimeTo > 0 {
+		q.Set("create_time_to", strconv.FormatInt(opts.CreateTimeTo, 10))
+	}
+	if opts.UpdateTimeFrom > 0 {
+		q.Set("update_time_from", strconv.FormatInt(opts.UpdateTimeFrom, 10))
+	}
+	if opts.UpdateTimeTo > 0 {
+		q.Set("update_time_to", strconv.FormatInt(opts.UpdateTimeTo, 10))
+	}
+	if opts.Status != "" {
+		q.Set("status", string(opts.Status))
+	}
+
+	return Call[url.Values, dto.IResReturnListWrapper](ctx, s, "/api/v2/returns/get_return_list", params, q)
+}
+
+func (s *shopeeApi) GetReturnDetail(ctx context.Context, params *IReqShopeeAdapter, returnSN string) (*dto.IResReturnDetail, error) {
+	q := url.Values{}
+	q.Set("return_sn", returnSN)
+
+	return Call[url.Values, dto.IResReturnDetail](ctx, s, "/api/v2/returns/get_return_detail", params, q)
+}
+
+func (s *shopeeApi) ConfirmReturn(ctx context.Context, params *IReqShopeeAdapter, body *dto.IBConfirmReturn) (*dto.IResReturnSNWrapper, error) {
+	return Call[*dto.IBConfirmReturn, dto.IResReturnSNWrapper](ctx, s, "/api/v2/returns/confirm", params, body)
+}
+
+func (s *shopeeApi) DisputeReturn(ctx context.Context, params *IReqShopeeAdapter, body *dto.IBDisputeReturn) (*dto.IResDisputeReturnWrapper, error) {
+	return Call[*dto.IBDisputeReturn, dto.IResDisputeReturnWrapper](ctx, s, "/api/v2/returns/dispute", params, body)
+}
+
+func (s *shopeeApi) OfferReturn(ctx context.Context, params *IReqShopeeAdapter, body *dto.IBOfferReturn) (*dto.IResReturnSNWrapper, error) {
+	return Call[*dto.IBOfferReturn, dto.IResReturnSNWrapper](ctx, s, "/api/v2/returns/offer", params, body)
+}
+
+// ConvertReturnImage : upload dispute evidence, the returned url goes into IBDisputeReturn.Images
+func (s *shopeeApi) ConvertReturnImage(ctx context.Context, params *IReqShopeeAdapter, files []dto.IFileUpload) ([]dto.IResReturnImage, error) {
+	if len(files) == 0 {
+		return []dto.IResReturnImage{}, nil
+	}
+	if len(files) > ShopeeReturnMaxImage {
+		return nil, fmt.Errorf("adapter.ConvertReturnImage : max %d images per request", ShopeeReturnMaxImage)
+	}
+
+	form := &ShopeeMultipartForm{Field: "images", Files: files}
+	res, err := Call[*ShopeeMultipartForm, dto.IResConvertImageWrapper](ctx, s, "/api/v2/returns/convert_image", params, form)
+	if err != nil {
+		return nil, err
+	}
+	return res.Images, nil
+}
@@ -0,0 +1,30 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"ecommerce/internal/env"
+)
+
+var ErrBlobNotFound = errors.New("blob not found")
+
+// IBlobStore : flat key space, "/" separated keys
+type IBlobStore interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	Exists(ctx context.Context, key string) (bool, error)
+	Delete(ctx context.Context, key string) error
+}
+
+func NewBlobStore(cfg *env.Config, log *zap.Logger) (IBlobStore, error) {
+	switch cfg.Blob.BlobDriver {
+	case "", "local":
+		return NewLocalBlobStore(cfg.Blob.BlobLocalDir, log)
+	default:
+		return nil, fmt.Errorf("storage.NewBlobStore : unsupported driver %q", cfg.Blob.BlobDriver)
+	}
+}
@@ -0,0 +1,299 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+
+	"ecommerce/internal/application/tenant"
+	"ecommerce/internal/application/users"
+	"ecommerce/internal/env"
+)
+
+// In-memory stand-ins for the auth usecase tests ; filters follow the mongo repositories.
+
+const testPassword = "correct-horse"
+
+type fakeUserRepository struct {
+	users.UserRepository
+	mu    sync.Mutex
+	users map[string]*users.UserEntity
+}
+
+func (r *fakeUserRepository) GetUserDetailByUsername(ctx context.Context, username string) (*users.UserEntity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.users[username]
+	if !ok {
+		return nil, users.ErrUserNotFound
+	}
+	c := *u
+	return &c, nil
+}
+
+func (r *fakeUserRepository) UpdateUserDetail(ctx context.Context, user users.UserEntity) (*users.UserEntity, error) {
+	return r.GetUserDetailByUsername(ctx, user.Username)
+}
+
+type fakeSessionRepository struct {
+	mu       sync.Mutex
+	sessions map[bson.ObjectID]*SessionModel
+}
+
+func (r *fakeSessionRepository) InitRepository() error { return nil }
+
+func (r *fakeSessionRepository) CreateSession(ctx context.Context, session *SessionModel) (*SessionModel, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	session.ID = bson.NewObjectID()
+	c := *session
+	r.sessions[c.ID] = &c
+	return session, nil
+}
+
+func (r *fakeSessionRepository) find(id string) *SessionModel {
+	objectID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return nil
+	}
+	return r.sessions[objectID]
+}
+
+func (r *fakeSessionRepository) GetSessionByID(ctx context.Context, id string) (*SessionModel, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.find(id)
+	if s == nil {
+		return nil, ErrSessionNotFound
+	}
+	c := *s
+	return &c, nil
+}
+
+func (r *fakeSessionRepository) RotateSession(ctx context.Context, id string, jti string, nextJTI string) (*SessionModel, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.find(id)
+	if s == nil || s.JTI != jti || s.RevokedAt != nil || !s.ExpiresAt.After(time.Now()) {
+		return nil, ErrSessionRotated
+	}
+	s.JTI = nextJTI
+	s.LastUsedAt = time.Now()
+	s.Rotations++
+	c := *s
+	return &c, nil
+}
+
+func (r *fakeSessionRepository) RevokeSession(ctx context.Context, id string, reason SessionRevokeEnum) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.find(id)
+	if s == nil || s.RevokedAt != nil {
+		return ErrSessionNotFound
+	}
+	now := time.Now()
+	s.RevokedAt, s.RevokedReason = &now, &reason
+	return nil
+}
+
+func (r *fakeSessionRepository) RevokeUserSessions(ctx context.Context, userID string, reason SessionRevokeEnum) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var n int64
+	for _, s := range r.sessions {
+		if s.UserID == userID && s.RevokedAt == nil {
+			now := time.Now()
+			s.RevokedAt, s.RevokedReason = &now, &reason
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (r *fakeSessionRepository) GetActiveSessionsByUser(ctx context.Context, userID string) ([]SessionModel, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	res := []SessionModel{}
+	for _, s := range r.sessions {
+		if s.UserID == userID && s.RevokedAt == nil && s.ExpiresAt.After(time.Now()) {
+			res = append(res, *s)
+		}
+	}
+	return res, nil
+}
+
+type fakeAccessService struct {
+	users.IAccessService
+	mfaRequired bool
+}
+
+func (a *fakeAccessService) ResolveUserAccess(ctx context.Context, username string) (*users.UserAccessDTO, error) {
+	return &users.UserAccessDTO{Username: username, MFARequired: a.mfaRequired}, nil
+}
+
+type fakeTenantService struct{ tenant.ITenantService }
+
+func (fakeTenantService) CheckTenantActive(ctx context.Context, tenantID string) error { return nil }
+
+type testAuth struct {
+	Service  *authService
+	User     *users.UserEntity
+	Sessions *fakeSessionRepository
+	MFA      *fakeMFARepository
+	Access   *fakeAccessService
+}
+
+func newTestAuth(t *testing.T) *testAuth {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &users.UserEntity{ID: bson.NewObjectID().Hex(), Username: "alice", Email: "alice@example.com", PasswordHash: string(hash)}
+
+	cfg := &env.Config{
+		JWT: &env.JWTConfig{AuthJWTSecretKey: "test-secret", AuthJWTAccessIN: 5, AuthJWTRefreshIN: 60},
+		MFA: &env.MFAConfig{MFAIssuer: "ecommerce", MFAChallengeIN: 5, MFAMaxAttempts: 3, MFALockMinutes: 15},
+	}
+	ta := &testAuth{
+		User:     user,
+		Sessions: &fakeSessionRepository{sessions: map[bson.ObjectID]*SessionModel{}},
+		MFA:      &fakeMFARepository{records: map[string]*MFAModel{}},
+		Access:   &fakeAccessService{},
+	}
+	ta.Service = NewAuthService(cfg, zap.NewNop(),
+		&fakeUserRepository{users: map[string]*users.UserEntity{user.Username: user}},
+		ta.Sessions, ta.MFA, ta.Access, nil, fakeTenantService{},
+	).(*authService)
+	return ta
+}
+
+func (ta *testAuth) login(t *testing.T) *AuthWithJwtDTO {
+	t.Helper()
+	res, err := ta.Service.GetJwtFromLogin(context.Background(), ta.User.Username, testPassword, &SessionClient{UserAgent: "test"})
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	return res
+}
+
+type fakeMFARepository struct {
+	MFARepository
+	mu      sync.Mutex
+	records map[string]*MFAModel // user_id ->
+}
+
+func (r *fakeMFARepository) GetMFAByUser(ctx context.Context, userID string) (*MFAModel, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m, ok := r.records[userID]
+	if !ok {
+		return nil, ErrMFANotFound
+	}
+	c := *m
+	c.RecoveryCodes = append([]RecoveryCodeModel(nil), m.RecoveryCodes...)
+	return &c, nil
+}
+
+func (r *fakeMFARepository) SavePending(ctx context.Context, userID string, username string, secret string) (*MFAModel, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m, ok := r.records[userID]
+	if ok && m.Enabled {
+		return nil, ErrMFAEnabled
+	}
+	if !ok {
+		m = &MFAModel{ID: bson.NewObjectID(), UserID: userID, CreatedAt: time.Now()}
+		r.records[userID] = m
+	}
+	m.Username, m.PendingSecret, m.UpdatedAt = username, secret, time.Now()
+	c := *m
+	return &c, nil
+}
+
+func (r *fakeMFARepository) Enable(ctx context.Context, userID string, step int64, recoveryHashes []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m, ok := r.records[userID]
+	if !ok || m.PendingSecret == "" {
+		return ErrMFANotFound
+	}
+	if m.Enabled {
+		return ErrMFAEnabled
+	}
+	now := time.Now()
+	m.Enabled, m.Secret, m.PendingSecret = true, m.PendingSecret, ""
+	m.RecoveryCodes = recoveryModels(recoveryHashes)
+	m.LastStep, m.FailedAttempts, m.LockedUntil, m.EnabledAt = step, 0, nil, &now
+	return nil
+}
+
+func (r *fakeMFARepository) SetRecoveryCodes(ctx context.Context, userID string, recoveryHashes []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m, ok := r.records[userID]
+	if !ok || !m.Enabled {
+		return ErrMFANotFound
+	}
+	m.RecoveryCodes = recoveryModels(recoveryHashes)
+	return nil
+}
+
+func (r *fakeMFARepository) UseStep(ctx context.Context, userID string, step int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m, ok := r.records[userID]
+	if !ok || m.LastStep >= step {
+		return ErrMFAStepUsed
+	}
+	m.LastStep, m.FailedAttempts, m.LockedUntil = step, 0, nil
+	return nil
+}
+
+func (r *fakeMFARepository) UseRecoveryCode(ctx context.Context, userID string, hash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m, ok := r.records[userID]
+	if !ok {
+		return ErrMFACodeInvalid
+	}
+	for i := range m.RecoveryCodes {
+		if m.RecoveryCodes[i].Hash == hash && m.RecoveryCodes[i].UsedAt == nil {
+			now := time.Now()
+			m.RecoveryCodes[i].UsedAt = &now
+			m.FailedAttempts, m.LockedUntil = 0, nil
+			return nil
+		}
+	}
+	return ErrMFACodeInvalid
+}
+
+func (r *fakeMFARepository) RegisterFailure(ctx context.Context, userID string, maxAttempts int, lockFor time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m, ok := r.records[userID]
+	if !ok {
+		return ErrMFANotFound
+	}
+	m.FailedAttempts++
+	if maxAttempts > 0 && m.FailedAttempts >= maxAttempts {
+		until := time.Now().Add(lockFor)
+		m.LockedUntil, m.FailedAttempts = &until, 0
+	}
+	return nil
+}
+
+func (r *fakeMFARepository) DeleteMFA(ctx context.Context, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.records[userID]; !ok {
+		return ErrMFANotFound
+	}
+	delete(r.records, userID)
+	return nil
+}
@@ -1,8 +1,10 @@
 package auth
 
 import (
+	"ecommerce/internal/application/users"
 	"ecommerce/internal/delivery/http/response"
 	"ecommerce/internal/env"
+	"errors"
 	"time"
 
 	"github.com/go-playground/validator/v10"
@@ -15,6 +17,28 @@ type AuthHandler interface {
 
   PostUserAuthLogin(c *fiber.Ctx) error
   PostUserAuthRefresh(c *fiber.Ctx) error
+  PostUserAuthLogout(c *fiber.Ctx) error
+  PostUserAuthLogoutAll(c *fiber.Ctx) error
+
+  // sessions of the caller : /user/me/sessions
+  GetUserMeSessions(c *fiber.Ctx) error
+  DeleteUserMeSession(c *fiber.Ctx) error
+
+  // account : public reset / verification, resend for the caller
+  PostUserAuthForgotPassword(c *fiber.Ctx) error
+  PostUserAuthResetPassword(c *fiber.Ctx) error
+  PostUserAuthVerifyEmail(c *fiber.Ctx) error
+  PostUserMeVerifyEmail(c *fiber.Ctx) error
+
+  // 2fa : second login step, self-service under /user/me/mfa, admin reset
+  PostUserAuthMFAVerify(c *fiber.Ctx) error
+  PostUserAuthMFAEnroll(c *fiber.Ctx) error
+  GetUserMeMFA(c *fiber.Ctx) error
+  PostUserMeMFAEnroll(c *fiber.Ctx) error
+  PostUserMeMFAActivate(c *fiber.Ctx) error
+  PostUserMeMFADisable(c *fiber.Ctx) error
+  PostUserMeMFARecoveryCodes(c *fiber.Ctx) error
+  DeleteUserMFA(c *fiber.Ctx) error
 }
 
 type authHandler struct {
@@ -35,6 +59,43 @@ func NewAuthHandle(cfg *env.Config,src IAuthService, log *zap.Logger, valid *val
 
 func (d *authHandler) CheckAuth(c *fiber.Ctx) error{ return response.SuccessResponse(c,"check-auth","") }
 
+// refresh cookie is sent to /auth/refresh and /auth/logout only
+const refreshCookiePath = "/api/v1/auth"
+
+// setRefreshCookie : the cookie dies with the session, not AuthJWTRefreshIN after this refresh
+func (d *authHandler) setRefreshCookie(c *fiber.Ctx, res *AuthWithJwtDTO) {
+  expires := time.Now().Add(time.Duration(d.Config.JWT.AuthJWTRefreshIN) * time.Minute)
+  if res.RefreshExpiresAt != nil { expires = *res.RefreshExpiresAt }
+  c.Cookie(&fiber.Cookie{
+    Name: "refresh_token",
+    Value: res.RefreshToken,
+    Expires: expires,
+    HTTPOnly: true, Secure: true,
+    SameSite: fiber.CookieSameSiteStrictMode,
+    Path: refreshCookiePath,
+  })
+}
+
+func (d *authHandler) clearRefreshCookie(c *fiber.Ctx) {
+  c.Cookie(&fiber.Cookie{
+    Name: "refresh_token",
+    Value: "",
+    Expires: time.Unix(0, 0),
+    HTTPOnly: true, Secure: true,
+    SameSite: fiber.CookieSameSiteStrictMode,
+    Path: refreshCookiePath,
+  })
+}
+
+func sessionClient(c *fiber.Ctx) *SessionClient {
+  return &SessionClient{ UserAgent: c.Get(fiber.HeaderUserAgent), IP: c.IP() }
+}
+
+func localString(c *fiber.Ctx, key string) string {
+  if v, ok := c.Locals(key).(string); ok { return v }
+  return ""
+}
+
 type IReqUserLogin struct {
   Username string `json:"username" validate:"required"`
   Password string `json:"password" validate:"required"`
@@ -50,7 +111,7 @@ func (d *authHandler)PostUserAuthLogin(c *fiber.Ctx) error {
   if err := d.Validate.Struct(req) ; err != nil { return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostUserAuthLogin", "Invalidate Body") }
 
 
-  res,err := d.Service.GetJwtFromLogin(c.Context(), req.Username, req.Password)
+  res,err := d.Service.GetJwtFromLogin(c.Context(), req.Username, req.Password, sessionClient(c))
   if err != nil {return response.ErrorResponse(c,fiber.StatusBadRequest, "handler.PostUserAuthLogin", "username or password invalid")}
 
     // user, perms, err := service.AuthenticateUser(req.Username, req.Password)
@@ -72,14 +133,8 @@ func (d *authHandler)PostUserAuthLogin(c *fiber.Ctx) error {
     //     return err
     // }
 
-    // Test to Set Coockie
-    c.Cookie(&fiber.Cookie{
-    Name: "refresh_token",
-    Value: res.RefreshToken,
-    Expires: time.Now().Add(time.Duration(d.Config.JWT.AuthJWTRefreshIN) * time.Minute),
-    HTTPOnly: true, Secure: true,
-    SameSite: fiber.CookieSameSiteStrictMode,
-    Path: "/api/v1/auth/refresh"}) 
+    // 2fa challenge : no tokens yet
+    if res.RefreshToken != "" { d.setRefreshCookie(c, res) }
 
     return response.SuccessResponse(c,"handler.PostUserAuthLogin", res)
 }
@@ -89,17 +144,222 @@ func (d *authHandler)PostUserAuthRefresh(c *fiber.Ctx) error {
   if refreshToken ==""{
     return response.ErrorResponse(c,fiber.StatusBadGateway,"handler.PostUserAuthRefresh","refresh token not found!") }
 
-  res,err := d.Service.GetJwtFromRefresh(c.Context(),refreshToken)
-  if err != nil {return response.ErrorResponse(c,fiber.StatusBadGateway, "handler.PostUserAuthRefresh", "Authurization not permission")}
+  res,err := d.Service.GetJwtFromRefresh(c.Context(),refreshToken, sessionClient(c))
+  if err != nil {
+    // the cookie is dead either way (spent, revoked or expired)
+    d.clearRefreshCookie(c)
+    if errors.Is(err, ErrRefreshReuse) || errors.Is(err, ErrSessionRevoked) || errors.Is(err, ErrMFAEnrollRequired) {
+      return response.ErrorResponse(c,fiber.StatusUnauthorized, "handler.PostUserAuthRefresh", err.Error())
+    }
+    return response.ErrorResponse(c,fiber.StatusBadGateway, "handler.PostUserAuthRefresh", "Authurization not permission")
+  }
 
-  c.Cookie(&fiber.Cookie{
-    Name: "refresh_token",
-    Value: res.RefreshToken,
-    Expires: time.Now().Add(time.Duration(d.Config.JWT.AuthJWTRefreshIN) * time.Minute),
-    HTTPOnly: true , Secure: true,
-    SameSite: fiber.CookieSameSiteStrictMode,
-    Path: "/api/v1/auth/refresh",
-  })
+  d.setRefreshCookie(c, res)
 
   return response.SuccessResponse(c,"handler.PostUserAuthRefresh", res)
 }
+
+// PostUserAuthLogout : ends the session of the refresh cookie, the access token runs out on its own
+func (d *authHandler)PostUserAuthLogout(c *fiber.Ctx) error {
+  refreshToken := c.Cookies("refresh_token")
+  d.clearRefreshCookie(c)
+  if refreshToken == "" { return response.SuccessResponse(c,"handler.PostUserAuthLogout", "") }
+
+  if err := d.Service.Logout(c.Context(), refreshToken); err != nil {
+    d.Logger.Info("handler.PostUserAuthLogout:", zap.Error(err))
+    // expired or forged cookie : nothing left to end
+    if errors.Is(err, ErrInvalidRefresh) { return response.SuccessResponse(c,"handler.PostUserAuthLogout", "") }
+    return response.ErrorResponse(c,fiber.StatusInternalServerError, "handler.PostUserAuthLogout", err)
+  }
+  return response.SuccessResponse(c,"handler.PostUserAuthLogout", "")
+}
+
+// PostUserAuthLogoutAll : log out all devices of the access token owner
+func (d *authHandler)PostUserAuthLogoutAll(c *fiber.Ctx) error {
+  revoked, err := d.Service.LogoutAll(c.Context(), localString(c, "user_id"))
+  if err != nil { return response.ErrorResponse(c,fiber.StatusInternalServerError, "handler.PostUserAuthLogoutAll", err) }
+
+  d.clearRefreshCookie(c)
+  return response.SuccessResponse(c,"handler.PostUserAuthLogoutAll", fiber.Map{"revoked": revoked})
+}
+
+func (d *authHandler)GetUserMeSessions(c *fiber.Ctx) error {
+  res, err := d.Service.GetSessions(c.Context(), localString(c, "user_id"), localString(c, "session_id"))
+  if err != nil { return response.ErrorResponse(c,fiber.StatusInternalServerError, "handler.GetUserMeSessions", err) }
+  return response.SuccessResponse(c,"handler.GetUserMeSessions", res)
+}
+
+func (d *authHandler)DeleteUserMeSession(c *fiber.Ctx) error {
+  if err := d.Service.RevokeSession(c.Context(), localString(c, "user_id"), c.Params("sessionID")); err != nil {
+    if errors.Is(err, ErrSessionNotFound) { return response.ErrorResponse(c,fiber.StatusNotFound, "handler.DeleteUserMeSession", err.Error()) }
+    return response.ErrorResponse(c,fiber.StatusInternalServerError, "handler.DeleteUserMeSession", err)
+  }
+  return response.SuccessResponse(c,"handler.DeleteUserMeSession", "")
+}
+
+type IReqForgotPassword struct {
+  Email string `json:"email" validate:"required,email"`
+}
+
+type IReqResetPassword struct {
+  Token    string `json:"token" validate:"required"`
+  // bcrypt reads 72 bytes at most
+  Password string `json:"password" validate:"required,min=8,max=72"`
+}
+
+type IReqVerifyEmail struct {
+  Token string `json:"token" validate:"required"`
+}
+
+// PostUserAuthForgotPassword : same answer for known and unknown addresses
+func (d *authHandler)PostUserAuthForgotPassword(c *fiber.Ctx) error {
+  var req IReqForgotPassword
+  if err := c.BodyParser(&req); err != nil { return response.ErrorResponse(c,fiber.StatusBadRequest, "handler.PostUserAuthForgotPassword", "invalid body") }
+  if err := d.Validate.Struct(req); err != nil { return response.ErrorResponse(c,fiber.StatusBadRequest, "handler.PostUserAuthForgotPassword", err) }
+
+  if err := d.Service.ForgotPassword(c.Context(), req.Email); err != nil {
+    d.Logger.Error("handler.PostUserAuthForgotPassword:", zap.Error(err))
+    return response.ErrorResponse(c,fiber.StatusInternalServerError, "handler.PostUserAuthForgotPassword", "request failed, try again")
+  }
+  return response.SuccessResponse(c,"handler.PostUserAuthForgotPassword", "if the address belongs to an account, a reset link was sent")
+}
+
+func (d *authHandler)PostUserAuthResetPassword(c *fiber.Ctx) error {
+  var req IReqResetPassword
+  if err := c.BodyParser(&req); err != nil { return response.ErrorResponse(c,fiber.StatusBadRequest, "handler.PostUserAuthResetPassword", "invalid body") }
+  if err := d.Validate.Struct(req); err != nil { return response.ErrorResponse(c,fiber.StatusBadRequest, "handler.PostUserAuthResetPassword", err) }
+
+  if err := d.Service.ResetPassword(c.Context(), req.Token, req.Password); err != nil {
+    if errors.Is(err, users.ErrAccountTokenInvalid) { return response.ErrorResponse(c,fiber.StatusBadRequest, "handler.PostUserAuthResetPassword", err.Error()) }
+    return response.ErrorResponse(c,fiber.StatusInternalServerError, "handler.PostUserAuthResetPassword", err)
+  }
+  d.clearRefreshCookie(c)
+  return response.SuccessResponse(c,"handler.PostUserAuthResetPassword", "")
+}
+
+func (d *authHandler)PostUserAuthVerifyEmail(c *fiber.Ctx) error {
+  var req IReqVerifyEmail
+  if err := c.BodyParser(&req); err != nil { return response.ErrorResponse(c,fiber.StatusBadRequest, "handler.PostUserAuthVerifyEmail", "invalid body") }
+  if err := d.Validate.Struct(req); err != nil { return response.ErrorResponse(c,fiber.StatusBadRequest, "handler.PostUserAuthVerifyEmail", err) }
+
+  res, err := d.Service.VerifyEmail(c.Context(), req.Token)
+  if err != nil {
+    if errors.Is(err, users.ErrAccountTokenInvalid) { return response.ErrorResponse(c,fiber.StatusBadRequest, "handler.PostUserAuthVerifyEmail", err.Error()) }
+    return response.ErrorResponse(c,fiber.StatusInternalServerError, "handler.PostUserAuthVerifyEmail", err)
+  }
+  return response.SuccessResponse(c,"handler.PostUserAuthVerifyEmail", res)
+}
+
+func (d *authHandler)PostUserMeVerifyEmail(c *fiber.Ctx) error {
+  if err := d.Service.ResendEmailVerification(c.Context(), localString(c, "username")); err != nil {
+    if errors.Is(err, users.ErrEmailVerified) { return response.ErrorResponse(c,fiber.StatusConflict, "handler.PostUserMeVerifyEmail", err.Error()) }
+    if errors.Is(err, users.ErrUserNotFound) { return response.ErrorResponse(c,fiber.StatusNotFound, "handler.PostUserMeVerifyEmail", err.Error()) }
+    return response.ErrorResponse(c,fiber.StatusInternalServerError, "handler.PostUserMeVerifyEmail", err)
+  }
+  return response.SuccessResponse(c,"handler.PostUserMeVerifyEmail", "")
+}
+
+type IReqMFAVerify struct {
+  MFAToken     string `json:"mfa_token" validate:"required"`
+  Code         string `json:"code"`
+  RecoveryCode string `json:"recovery_code"`
+}
+
+type IReqMFACode struct {
+  Code         string `json:"code"`
+  RecoveryCode string `json:"recovery_code"`
+}
+
+type IReqMFAChallenge struct {
+  MFAToken string `json:"mfa_token" validate:"required"`
+}
+
+// mfaError : status of the 2fa errors, anything else is a server error
+func mfaError(c *fiber.Ctx, from string, err error) error {
+  switch {
+  case errors.Is(err, ErrMFACodeInvalid), errors.Is(err, ErrMFAChallenge):
+    return response.ErrorResponse(c,fiber.StatusUnauthorized, from, err.Error())
+  case errors.Is(err, ErrMFALocked):
+    return response.ErrorResponse(c,fiber.StatusTooManyRequests, from, err.Error())
+  case errors.Is(err, ErrMFAEnabled), errors.Is(err, ErrMFARequiredByRole):
+    return response.ErrorResponse(c,fiber.StatusConflict, from, err.Error())
+  case errors.Is(err, ErrMFANotFound), errors.Is(err, users.ErrUserNotFound):
+    return response.ErrorResponse(c,fiber.StatusNotFound, from, err.Error())
+  case errors.Is(err, ErrMFACodeRequired):
+    return response.ErrorResponse(c,fiber.StatusBadRequest, from, err.Error())
+  }
+  return response.ErrorResponse(c,fiber.StatusInternalServerError, from, err)
+}
+
+// PostUserAuthMFAVerify : challenge + code -> the tokens of a normal login
+func (d *authHandler)PostUserAuthMFAVerify(c *fiber.Ctx) error {
+  var req IReqMFAVerify
+  if err := c.BodyParser(&req); err != nil { return response.ErrorResponse(c,fiber.StatusBadRequest, "handler.PostUserAuthMFAVerify", "invalid body") }
+  if err := d.Validate.Struct(req); err != nil { return response.ErrorResponse(c,fiber.StatusBadRequest, "handler.PostUserAuthMFAVerify", err) }
+
+  res, err := d.Service.VerifyMFALogin(c.Context(), req.MFAToken, req.Code, req.RecoveryCode, sessionClient(c))
+  if err != nil { return mfaError(c, "handler.PostUserAuthMFAVerify", err) }
+
+  d.setRefreshCookie(c, res)
+  return response.SuccessResponse(c,"handler.PostUserAuthMFAVerify", res)
+}
+
+// PostUserAuthMFAEnroll : forced enrollment, secret for the challenge owner
+func (d *authHandler)PostUserAuthMFAEnroll(c *fiber.Ctx) error {
+  var req IReqMFAChallenge
+  if err := c.BodyParser(&req); err != nil { return response.ErrorResponse(c,fiber.StatusBadRequest, "handler.PostUserAuthMFAEnroll", "invalid body") }
+  if err := d.Validate.Struct(req); err != nil { return response.ErrorResponse(c,fiber.StatusBadRequest, "handler.PostUserAuthMFAEnroll", err) }
+
+  res, err := d.Service.EnrollMFAChallenge(c.Context(), req.MFAToken)
+  if err != nil { return mfaError(c, "handler.PostUserAuthMFAEnroll", err) }
+  return response.SuccessResponse(c,"handler.PostUserAuthMFAEnroll", res)
+}
+
+func (d *authHandler)GetUserMeMFA(c *fiber.Ctx) error {
+  res, err := d.Service.GetMFAStatus(c.Context(), localString(c, "user_id"), localString(c, "username"))
+  if err != nil { return mfaError(c, "handler.GetUserMeMFA", err) }
+  return response.SuccessResponse(c,"handler.GetUserMeMFA", res)
+}
+
+func (d *authHandler)PostUserMeMFAEnroll(c *fiber.Ctx) error {
+  res, err := d.Service.EnrollMFA(c.Context(), localString(c, "user_id"), localString(c, "username"))
+  if err != nil { return mfaError(c, "handler.PostUserMeMFAEnroll", err) }
+  return response.SuccessResponse(c,"handler.PostUserMeMFAEnroll", res)
+}
+
+// PostUserMeMFAActivate : first code of the enrollment, answers the recovery codes (shown once)
+func (d *authHandler)PostUserMeMFAActivate(c *fiber.Ctx) error {
+  var req IReqMFACode
+  if err := c.BodyParser(&req); err != nil { return response.ErrorResponse(c,fiber.StatusBadRequest, "handler.PostUserMeMFAActivate", "invalid body") }
+
+  codes, err := d.Service.ActivateMFA(c.Context(), localString(c, "user_id"), req.Code)
+  if err != nil { return mfaError(c, "handler.PostUserMeMFAActivate", err) }
+  return response.SuccessResponse(c,"handler.PostUserMeMFAActivate", fiber.Map{"recovery_codes": codes})
+}
+
+func (d *authHandler)PostUserMeMFADisable(c *fiber.Ctx) error {
+  var req IReqMFACode
+  if err := c.BodyParser(&req); err != nil { return response.ErrorResponse(c,fiber.StatusBadRequest, "handler.PostUserMeMFADisable", "invalid body") }
+
+  if err := d.Service.DisableMFA(c.Context(), localString(c, "user_id"), localString(c, "username"), req.Code, req.RecoveryCode); err != nil {
+    return mfaError(c, "handler.PostUserMeMFADisable", err)
+  }
+  return response.SuccessResponse(c,"handler.PostUserMeMFADisable", "")
+}
+
+func (d *authHandler)PostUserMeMFARecoveryCodes(c *fiber.Ctx) error {
+  var req IReqMFACode
+  if err := c.BodyParser(&req); err != nil { return response.ErrorResponse(c,fiber.StatusBadRequest, "handler.PostUserMeMFARecoveryCodes", "invalid body") }
+
+  codes, err := d.Service.RegenerateRecoveryCodes(c.Context(), localString(c, "user_id"), req.Code)
+  if err != nil { return mfaError(c, "handler.PostUserMeMFARecoveryCodes", err) }
+  return response.SuccessResponse(c,"handler.PostUserMeMFARecoveryCodes", fiber.Map{"recovery_codes": codes})
+}
+
+// DeleteUserMFA : admin reset, /users/:userId/mfa
+func (d *authHandler)DeleteUserMFA(c *fiber.Ctx) error {
+  if err := d.Service.ResetUserMFA(c.Context(), localString(c, "username"), c.Params("userId")); err != nil {
+    return mfaError(c, "handler.DeleteUserMFA", err)
+  }
+  return response.SuccessResponse(c,"handler.DeleteUserMFA", "")
+}
@@ -0,0 +1,365 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+
+	"ecommerce/internal/application/users"
+	"ecommerce/internal/pkg"
+)
+
+// TOTP two-factor authentication.
+//
+// Login with a password returns a short-lived challenge (mfa_token) instead of tokens when the user
+// has 2fa enabled, or has none while one of the roles requires it ; /auth/mfa/verify trades the
+// challenge plus a TOTP or recovery code for the usual access / refresh pair.
+// A forced enrollment goes /auth/mfa/enroll (secret + otpauth URI) then /auth/mfa/verify with the
+// first code. Accepted time steps are never accepted again, wrong codes lock the second step for a while.
+
+const (
+	// codes of the previous / next 30 s step are accepted too (clock drift)
+	mfaSkew           = 1
+	mfaRecoveryCodes  = 10
+	mfaRecoveryLength = 10
+)
+
+var (
+	ErrMFAEnabled        = errors.New("2fa is already enabled")
+	ErrMFACodeInvalid    = errors.New("invalid 2fa code")
+	ErrMFALocked         = errors.New("too many invalid 2fa codes, try again later")
+	ErrMFAChallenge      = errors.New("invalid or expired 2fa challenge")
+	ErrMFARequiredByRole = errors.New("2fa is required by one of your roles")
+	ErrMFAEnrollRequired = errors.New("2fa is required by one of your roles, log in again to enroll")
+	ErrMFACodeRequired   = errors.New("code or recovery_code is required")
+)
+
+type MFAEnrollmentDTO struct {
+	Secret string `json:"secret"`
+	// otpauth:// URI, rendered as a QR code by the client
+	URI     string `json:"uri"`
+	Issuer  string `json:"issuer"`
+	Account string `json:"account"`
+}
+
+type MFAStatusDTO struct {
+	Enabled           bool       `json:"enabled"`
+	Pending           bool       `json:"pending"`
+	Required          bool       `json:"required"`
+	RecoveryCodesLeft int        `json:"recovery_codes_left"`
+	EnabledAt         *time.Time `json:"enabled_at,omitempty"`
+}
+
+var recoveryEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// newRecoveryCodes : plain codes for the user ("xxxxx-xxxxx") and their bcrypt hashes for the store
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, mfaRecoveryCodes)
+	hashes := make([]string, mfaRecoveryCodes)
+	for i := range codes {
+		b := make([]byte, 8)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		raw := strings.ToLower(recoveryEncoding.EncodeToString(b))[:mfaRecoveryLength]
+		hash, err := bcrypt.GenerateFromPassword([]byte(raw), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, nil, err
+		}
+		codes[i] = raw[:mfaRecoveryLength/2] + "-" + raw[mfaRecoveryLength/2:]
+		hashes[i] = string(hash)
+	}
+	return codes, hashes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// mfaChallenge : login answer carrying the challenge token only
+func (s *authService) mfaChallenge(user *users.UserEntity, tenantID string, enroll bool) (*AuthWithJwtDTO, error) {
+	now := time.Now()
+	claims := &AuthClaimsEntiy{
+		Type:     MFAChallenge,
+		Username: user.Username,
+		TenantID: tenantID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        newJTI(),
+			Subject:   user.ID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute * time.Duration(s.Config.MFA.MFAChallengeIN))),
+		},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(s.Config.JWT.AuthJWTSecretKey))
+	if err != nil {
+		return nil, err
+	}
+	return &AuthWithJwtDTO{
+		Username:          user.Username,
+		Email:             user.Email,
+		FullName:          user.FullName,
+		MFARequired:       true,
+		MFAEnrollRequired: enroll,
+		MFAToken:          token,
+	}, nil
+}
+
+// challengeUser : user of a valid challenge token
+func (s *authService) challengeUser(ctx context.Context, mfaToken string) (*users.UserEntity, *AuthClaimsEntiy, error) {
+	claims, err := s.parseToken(mfaToken, MFAChallenge)
+	if err != nil {
+		return nil, nil, ErrMFAChallenge
+	}
+	user, err := s.UserRepository.GetUserDetailByUsername(ctx, claims.Username)
+	if err != nil || user.ID != claims.Subject {
+		return nil, nil, ErrMFAChallenge
+	}
+	return user, claims, nil
+}
+
+func mfaLocked(mfa *MFAModel) bool {
+	return mfa.LockedUntil != nil && mfa.LockedUntil.After(time.Now())
+}
+
+func (s *authService) mfaFailure(ctx context.Context, mfa *MFAModel) error {
+	lockFor := time.Minute * time.Duration(s.Config.MFA.MFALockMinutes)
+	if err := s.MFARepository.RegisterFailure(ctx, mfa.UserID, s.Config.MFA.MFAMaxAttempts, lockFor); err != nil {
+		s.Logger.Error("usecase.mfaFailure.RegisterFailure:", zap.String("username", mfa.Username), zap.Error(err))
+	}
+	s.Logger.Warn("usecase.mfaFailure: invalid 2fa code", zap.String("username", mfa.Username))
+	return ErrMFACodeInvalid
+}
+
+// checkCode : second factor of an enabled 2fa, a TOTP code or an unused recovery code
+func (s *authService) checkCode(ctx context.Context, mfa *MFAModel, code string, recoveryCode string) error {
+	if mfaLocked(mfa) {
+		return ErrMFALocked
+	}
+	switch {
+	case code != "":
+		step, ok := pkg.ValidateTOTP(mfa.Secret, code, time.Now(), mfaSkew)
+		if !ok {
+			return s.mfaFailure(ctx, mfa)
+		}
+		if err := s.MFARepository.UseStep(ctx, mfa.UserID, step); err != nil {
+			if errors.Is(err, ErrMFAStepUsed) {
+				return s.mfaFailure(ctx, mfa)
+			}
+			return err
+		}
+		return nil
+	case recoveryCode != "":
+		normalized := normalizeRecoveryCode(recoveryCode)
+		for _, rc := range mfa.RecoveryCodes {
+			if rc.UsedAt != nil || bcrypt.CompareHashAndPassword([]byte(rc.Hash), []byte(normalized)) != nil {
+				continue
+			}
+			if err := s.MFARepository.UseRecoveryCode(ctx, mfa.UserID, rc.Hash); err != nil {
+				if errors.Is(err, ErrMFACodeInvalid) {
+					return s.mfaFailure(ctx, mfa)
+				}
+				return err
+			}
+			s.Logger.Info("usecase.checkCode: recovery code used", zap.String("username", mfa.Username))
+			return nil
+		}
+		return s.mfaFailure(ctx, mfa)
+	}
+	return ErrMFACodeRequired
+}
+
+// activate : first code of a pending enrollment, returns the recovery codes to show once
+func (s *authService) activate(ctx context.Context, mfa *MFAModel, code string) ([]string, error) {
+	if mfa.Enabled {
+		return nil, ErrMFAEnabled
+	}
+	if mfa.PendingSecret == "" {
+		return nil, ErrMFANotFound
+	}
+	if mfaLocked(mfa) {
+		return nil, ErrMFALocked
+	}
+	if code == "" {
+		return nil, ErrMFACodeRequired
+	}
+	step, ok := pkg.ValidateTOTP(mfa.PendingSecret, code, time.Now(), mfaSkew)
+	if !ok {
+		return nil, s.mfaFailure(ctx, mfa)
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.MFARepository.Enable(ctx, mfa.UserID, step, hashes); err != nil {
+		return nil, err
+	}
+	s.Logger.Info("usecase.activate: 2fa enabled", zap.String("username", mfa.Username))
+	return codes, nil
+}
+
+func (s *authService) enroll(ctx context.Context, userID string, username string) (*MFAEnrollmentDTO, error) {
+	secret, err := pkg.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.MFARepository.SavePending(ctx, userID, username, secret); err != nil {
+		return nil, err
+	}
+	issuer := s.Config.MFA.MFAIssuer
+	return &MFAEnrollmentDTO{
+		Secret:  secret,
+		URI:     pkg.TOTPProvisioningURI(issuer, username, secret),
+		Issuer:  issuer,
+		Account: username,
+	}, nil
+}
+
+func (s *authService) VerifyMFALogin(ctx context.Context, mfaToken string, code string, recoveryCode string, client *SessionClient) (*AuthWithJwtDTO, error) {
+	user, claims, err := s.challengeUser(ctx, mfaToken)
+	if err != nil {
+		return nil, err
+	}
+	tenantID := userTenant(user)
+	if err := s.Tenants.CheckTenantActive(ctx, tenantID); err != nil {
+		return nil, err
+	}
+	access, err := s.Access.ResolveUserAccess(ctx, claims.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	mfa, err := s.MFARepository.GetMFAByUser(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	var recoveryCodes []string
+	if mfa.Enabled {
+		err = s.checkCode(ctx, mfa, code, recoveryCode)
+	} else {
+		// forced enrollment : the first code enables 2fa and completes the login
+		recoveryCodes, err = s.activate(ctx, mfa, code)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.completeLogin(ctx, user, tenantID, access, client)
+	if err != nil {
+		return nil, err
+	}
+	res.RecoveryCodes = recoveryCodes
+	return res, nil
+}
+
+func (s *authService) EnrollMFAChallenge(ctx context.Context, mfaToken string) (*MFAEnrollmentDTO, error) {
+	user, _, err := s.challengeUser(ctx, mfaToken)
+	if err != nil {
+		return nil, err
+	}
+	return s.enroll(ctx, user.ID, user.Username)
+}
+
+func (s *authService) GetMFAStatus(ctx context.Context, userID string, username string) (*MFAStatusDTO, error) {
+	access, err := s.Access.ResolveUserAccess(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	res := &MFAStatusDTO{Required: access.MFARequired}
+
+	mfa, err := s.MFARepository.GetMFAByUser(ctx, userID)
+	if errors.Is(err, ErrMFANotFound) {
+		return res, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	res.Enabled = mfa.Enabled
+	res.Pending = !mfa.Enabled && mfa.PendingSecret != ""
+	res.EnabledAt = mfa.EnabledAt
+	for _, rc := range mfa.RecoveryCodes {
+		if rc.UsedAt == nil {
+			res.RecoveryCodesLeft++
+		}
+	}
+	return res, nil
+}
+
+func (s *authService) EnrollMFA(ctx context.Context, userID string, username string) (*MFAEnrollmentDTO, error) {
+	return s.enroll(ctx, userID, username)
+}
+
+func (s *authService) ActivateMFA(ctx context.Context, userID string, code string) ([]string, error) {
+	mfa, err := s.MFARepository.GetMFAByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.activate(ctx, mfa, code)
+}
+
+func (s *authService) DisableMFA(ctx context.Context, userID string, username string, code string, recoveryCode string) error {
+	mfa, err := s.MFARepository.GetMFAByUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if mfa.Enabled {
+		access, err := s.Access.ResolveUserAccess(ctx, username)
+		if err != nil {
+			return err
+		}
+		if access.MFARequired {
+			return ErrMFARequiredByRole
+		}
+		if err := s.checkCode(ctx, mfa, code, recoveryCode); err != nil {
+			return err
+		}
+	}
+	s.Logger.Info("usecase.DisableMFA: 2fa removed", zap.String("username", username))
+	return s.MFARepository.DeleteMFA(ctx, userID)
+}
+
+func (s *authService) RegenerateRecoveryCodes(ctx context.Context, userID string, code string) ([]string, error) {
+	mfa, err := s.MFARepository.GetMFAByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !mfa.Enabled {
+		return nil, ErrMFANotFound
+	}
+	// a TOTP code only : recovery codes can't mint new ones
+	if code == "" {
+		return nil, ErrMFACodeRequired
+	}
+	if err := s.checkCode(ctx, mfa, code, ""); err != nil {
+		return nil, err
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.MFARepository.SetRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// ResetUserMFA : admin, the user enrolls again (forced on next login when a role requires it)
+func (s *authService) ResetUserMFA(ctx context.Context, actor string, username string) error {
+	// tenant scoped : an admin only reaches users of the own tenant
+	user, err := s.UserRepository.GetUserDetailByUsername(ctx, username)
+	if err != nil {
+		return users.ErrUserNotFound
+	}
+	if err := s.MFARepository.DeleteMFA(ctx, user.ID); err != nil {
+		return err
+	}
+	s.Logger.Warn("usecase.ResetUserMFA: 2fa reset", zap.String("username", username), zap.String("actor", actor))
+	return nil
+}
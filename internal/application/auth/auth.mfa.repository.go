@@ -0,0 +1,307 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"go.uber.org/zap"
+
+	"ecommerce/internal/pkg"
+)
+
+var (
+	ErrMFANotFound = errors.New("2fa is not set up")
+	// the code's time step was already accepted once
+	ErrMFAStepUsed = errors.New("code already used")
+)
+
+// RecoveryCodeModel : bcrypt of a one-time recovery code
+type RecoveryCodeModel struct {
+	Hash   string     `bson:"hash"`
+	UsedAt *time.Time `bson:"used_at,omitempty"`
+}
+
+// MFAModel : one per user ; PendingSecret is an enrollment waiting for its first code,
+// Secret the active one once Enabled. Both are sealed by the secret cipher.
+type MFAModel struct {
+	ID             bson.ObjectID       `bson:"_id"`
+	UserID         string              `bson:"user_id"`
+	Username       string              `bson:"username"`
+	Enabled        bool                `bson:"enabled"`
+	Secret         string              `bson:"secret,omitempty"`
+	PendingSecret  string              `bson:"pending_secret,omitempty"`
+	RecoveryCodes  []RecoveryCodeModel `bson:"recovery_codes,omitempty"`
+	LastStep       int64               `bson:"last_step"`
+	FailedAttempts int                 `bson:"failed_attempts"`
+	LockedUntil    *time.Time          `bson:"locked_until,omitempty"`
+	EnabledAt      *time.Time          `bson:"enabled_at,omitempty"`
+	CreatedAt      time.Time           `bson:"created_at"`
+	UpdatedAt      time.Time           `bson:"updated_at"`
+}
+
+type MFARepository interface {
+	InitRepository() error
+	GetMFAByUser(ctx context.Context, userID string) (*MFAModel, error)
+	// SavePending : starts (or restarts) an enrollment, an enabled 2fa is left untouched
+	SavePending(ctx context.Context, userID string, username string, secret string) (*MFAModel, error)
+	// Enable : pending secret becomes the active one with new recovery codes
+	Enable(ctx context.Context, userID string, step int64, recoveryHashes []string) error
+	SetRecoveryCodes(ctx context.Context, userID string, recoveryHashes []string) error
+	// UseStep : accepts step only if it is newer than the last accepted one, clears failures
+	UseStep(ctx context.Context, userID string, step int64) error
+	// UseRecoveryCode : spends the code with this hash, clears failures
+	UseRecoveryCode(ctx context.Context, userID string, hash string) error
+	// RegisterFailure : counts a wrong code and locks the user once maxAttempts is reached
+	RegisterFailure(ctx context.Context, userID string, maxAttempts int, lockFor time.Duration) error
+	DeleteMFA(ctx context.Context, userID string) error
+	RotateMFASecrets(ctx context.Context, dryRun bool) (*pkg.SecretRotationResult, error)
+}
+
+// secret / pending_secret are sealed by Cipher on write and opened on read : callers only see plaintext
+type mfaRepository struct {
+	Logger *zap.Logger
+	DB     *mongo.Collection
+	Cipher pkg.ISecretCipher
+}
+
+func NewMFARepository(db *mongo.Collection, log *zap.Logger, cipher pkg.ISecretCipher) MFARepository {
+	return &mfaRepository{Logger: log, DB: db, Cipher: cipher}
+}
+
+func (r *mfaRepository) InitRepository() error {
+	indexs := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+	}
+	if _, err := r.DB.Indexes().CreateMany(context.TODO(), indexs); err != nil {
+		return errors.New("MFARepository.InitRepository: failed create indexs")
+	}
+	r.Logger.Info("MFARepository.InitRepository: index created")
+	return nil
+}
+
+func (r *mfaRepository) openModel(model *MFAModel) error {
+	secret, err := r.Cipher.Decrypt(model.Secret)
+	if err != nil {
+		r.Logger.Error("MFARepository: failed to decrypt secret", zap.String("user_id", model.UserID), zap.Error(err))
+		return errors.New("MFARepository: failed to decrypt secret")
+	}
+	pending, err := r.Cipher.Decrypt(model.PendingSecret)
+	if err != nil {
+		r.Logger.Error("MFARepository: failed to decrypt pending_secret", zap.String("user_id", model.UserID), zap.Error(err))
+		return errors.New("MFARepository: failed to decrypt pending_secret")
+	}
+	model.Secret = secret
+	model.PendingSecret = pending
+	return nil
+}
+
+func (r *mfaRepository) GetMFAByUser(ctx context.Context, userID string) (*MFAModel, error) {
+	var model MFAModel
+	if err := r.DB.FindOne(ctx, bson.M{"user_id": userID}).Decode(&model); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrMFANotFound
+		}
+		return nil, err
+	}
+	if err := r.openModel(&model); err != nil {
+		return nil, err
+	}
+	return &model, nil
+}
+
+func (r *mfaRepository) SavePending(ctx context.Context, userID string, username string, secret string) (*MFAModel, error) {
+	sealed, err := r.Cipher.Encrypt(secret)
+	if err != nil {
+		return nil, errors.New("MFARepository: failed to encrypt pending_secret")
+	}
+	now := time.Now()
+	update := bson.M{
+		"$set": bson.M{"username": username, "pending_secret": sealed, "updated_at": now},
+		"$setOnInsert": bson.M{
+			"_id": bson.NewObjectID(), "enabled": false, "last_step": int64(0),
+			"failed_attempts": 0, "created_at": now,
+		},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var model MFAModel
+	if err := r.DB.FindOneAndUpdate(ctx, bson.M{"user_id": userID, "enabled": bson.M{"$ne": true}}, update, opts).Decode(&model); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			// enabled document exists : the upsert tried a second one
+			return nil, ErrMFAEnabled
+		}
+		return nil, err
+	}
+	if err := r.openModel(&model); err != nil {
+		return nil, err
+	}
+	return &model, nil
+}
+
+func recoveryModels(hashes []string) []RecoveryCodeModel {
+	res := make([]RecoveryCodeModel, len(hashes))
+	for i, h := range hashes {
+		res[i] = RecoveryCodeModel{Hash: h}
+	}
+	return res
+}
+
+func (r *mfaRepository) Enable(ctx context.Context, userID string, step int64, recoveryHashes []string) error {
+	model, err := r.GetMFAByUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if model.PendingSecret == "" {
+		return ErrMFANotFound
+	}
+	sealed, err := r.Cipher.Encrypt(model.PendingSecret)
+	if err != nil {
+		return errors.New("MFARepository: failed to encrypt secret")
+	}
+	now := time.Now()
+	update := bson.M{
+		"$set": bson.M{
+			"enabled": true, "secret": sealed, "recovery_codes": recoveryModels(recoveryHashes),
+			"last_step": step, "failed_attempts": 0, "enabled_at": now, "updated_at": now,
+		},
+		"$unset": bson.M{"pending_secret": "", "locked_until": ""},
+	}
+	res, err := r.DB.UpdateOne(ctx, bson.M{"_id": model.ID, "enabled": false}, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrMFAEnabled
+	}
+	return nil
+}
+
+func (r *mfaRepository) SetRecoveryCodes(ctx context.Context, userID string, recoveryHashes []string) error {
+	res, err := r.DB.UpdateOne(ctx,
+		bson.M{"user_id": userID, "enabled": true},
+		bson.M{"$set": bson.M{"recovery_codes": recoveryModels(recoveryHashes), "updated_at": time.Now()}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrMFANotFound
+	}
+	return nil
+}
+
+func (r *mfaRepository) UseStep(ctx context.Context, userID string, step int64) error {
+	res, err := r.DB.UpdateOne(ctx,
+		bson.M{"user_id": userID, "last_step": bson.M{"$lt": step}},
+		bson.M{"$set": bson.M{"last_step": step, "failed_attempts": 0, "updated_at": time.Now()}, "$unset": bson.M{"locked_until": ""}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrMFAStepUsed
+	}
+	return nil
+}
+
+func (r *mfaRepository) UseRecoveryCode(ctx context.Context, userID string, hash string) error {
+	filter := bson.M{
+		"user_id":        userID,
+		"recovery_codes": bson.M{"$elemMatch": bson.M{"hash": hash, "used_at": nil}},
+	}
+	now := time.Now()
+	update := bson.M{
+		"$set":   bson.M{"recovery_codes.$.used_at": now, "failed_attempts": 0, "updated_at": now},
+		"$unset": bson.M{"locked_until": ""},
+	}
+	res, err := r.DB.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrMFACodeInvalid
+	}
+	return nil
+}
+
+func (r *mfaRepository) RegisterFailure(ctx context.Context, userID string, maxAttempts int, lockFor time.Duration) error {
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var model MFAModel
+	err := r.DB.FindOneAndUpdate(ctx, bson.M{"user_id": userID},
+		bson.M{"$inc": bson.M{"failed_attempts": 1}, "$set": bson.M{"updated_at": time.Now()}}, opts).Decode(&model)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return ErrMFANotFound
+		}
+		return err
+	}
+	if maxAttempts > 0 && model.FailedAttempts >= maxAttempts {
+		_, err := r.DB.UpdateOne(ctx, bson.M{"_id": model.ID},
+			bson.M{"$set": bson.M{"locked_until": time.Now().Add(lockFor), "failed_attempts": 0}})
+		return err
+	}
+	return nil
+}
+
+func (r *mfaRepository) DeleteMFA(ctx context.Context, userID string) error {
+	res, err := r.DB.DeleteOne(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrMFANotFound
+	}
+	return nil
+}
+
+func (r *mfaRepository) RotateMFASecrets(ctx context.Context, dryRun bool) (*pkg.SecretRotationResult, error) {
+	res := &pkg.SecretRotationResult{Collection: r.DB.Name()}
+
+	cursor, err := r.DB.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var model MFAModel
+		if err := cursor.Decode(&model); err != nil {
+			return res, err
+		}
+		res.Scanned++
+		if !r.Cipher.NeedsRotation(model.Secret) && !r.Cipher.NeedsRotation(model.PendingSecret) {
+			continue
+		}
+
+		secret, err := pkg.ReencryptSecret(r.Cipher, model.Secret)
+		if err == nil {
+			model.PendingSecret, err = pkg.ReencryptSecret(r.Cipher, model.PendingSecret)
+		}
+		if err != nil {
+			r.Logger.Error("MFARepository.RotateMFASecrets", zap.String("user_id", model.UserID), zap.Error(err))
+			res.Failed++
+			continue
+		}
+		if dryRun {
+			res.Rotated++
+			continue
+		}
+
+		// only if nobody rewrote it meanwhile
+		filter := bson.M{"_id": model.ID, "updated_at": model.UpdatedAt}
+		upd, err := r.DB.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"secret": secret, "pending_secret": model.PendingSecret}})
+		if err != nil {
+			return res, err
+		}
+		if upd.ModifiedCount == 1 {
+			res.Rotated++
+		} else {
+			res.Skipped++
+		}
+	}
+	return res, cursor.Err()
+}
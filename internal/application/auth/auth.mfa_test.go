@@ -0,0 +1,197 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"ecommerce/internal/application/users"
+	"ecommerce/internal/pkg"
+)
+
+// enableMFA : self-service enrollment, returns the secret, the step spent by activation and the recovery codes
+func (ta *testAuth) enableMFA(t *testing.T) (string, int64, []string) {
+	t.Helper()
+	ctx := context.Background()
+	enrollment, err := ta.Service.EnrollMFA(ctx, ta.User.ID, ta.User.Username)
+	if err != nil {
+		t.Fatalf("EnrollMFA: %v", err)
+	}
+	step := pkg.TOTPStep(time.Now())
+	codes, err := ta.Service.ActivateMFA(ctx, ta.User.ID, totpAt(t, enrollment.Secret, step))
+	if err != nil {
+		t.Fatalf("ActivateMFA: %v", err)
+	}
+	if len(codes) != mfaRecoveryCodes {
+		t.Fatalf("%d recovery codes, want %d", len(codes), mfaRecoveryCodes)
+	}
+	return enrollment.Secret, step, codes
+}
+
+func totpAt(t *testing.T, secret string, step int64) string {
+	t.Helper()
+	code, err := pkg.TOTPCode(secret, step)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+// challenge : password step of a 2fa login
+func (ta *testAuth) challenge(t *testing.T) string {
+	t.Helper()
+	res := ta.login(t)
+	if !res.MFARequired || res.MFAToken == "" || res.AccessToken != "" || res.RefreshToken != "" {
+		t.Fatalf("login with 2fa must answer a challenge only: %+v", res)
+	}
+	return res.MFAToken
+}
+
+func (ta *testAuth) verify(t *testing.T, mfaToken string, code string, recoveryCode string) (*AuthWithJwtDTO, error) {
+	t.Helper()
+	return ta.Service.VerifyMFALogin(context.Background(), mfaToken, code, recoveryCode, &SessionClient{UserAgent: "test"})
+}
+
+func TestMFALoginChallenge(t *testing.T) {
+	ta := newTestAuth(t)
+	secret, step, _ := ta.enableMFA(t)
+
+	token := ta.challenge(t)
+	res, err := ta.verify(t, token, totpAt(t, secret, step+1), "")
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if res.AccessToken == "" || res.RefreshToken == "" {
+		t.Fatal("verify must issue the token pair")
+	}
+
+	// the challenge is not an access or refresh token
+	if _, err := ta.Service.GetJwtFromRefresh(context.Background(), token, nil); err == nil {
+		t.Error("challenge accepted as a refresh token")
+	}
+	if _, err := ta.verify(t, "garbage", totpAt(t, secret, step+1), ""); !errors.Is(err, ErrMFAChallenge) {
+		t.Errorf("bad challenge: err = %v, want ErrMFAChallenge", err)
+	}
+}
+
+func TestMFAReusedStepRejected(t *testing.T) {
+	ta := newTestAuth(t)
+	secret, step, _ := ta.enableMFA(t)
+	token := ta.challenge(t)
+
+	// the activation code's step is already spent
+	if _, err := ta.verify(t, token, totpAt(t, secret, step), ""); !errors.Is(err, ErrMFACodeInvalid) {
+		t.Fatalf("activation step replayed: err = %v, want ErrMFACodeInvalid", err)
+	}
+	if _, err := ta.verify(t, token, totpAt(t, secret, step+1), ""); err != nil {
+		t.Fatalf("next step: %v", err)
+	}
+	if _, err := ta.verify(t, token, totpAt(t, secret, step+1), ""); !errors.Is(err, ErrMFACodeInvalid) {
+		t.Fatalf("same code twice: err = %v, want ErrMFACodeInvalid", err)
+	}
+}
+
+func TestMFARecoveryCodeSpent(t *testing.T) {
+	ta := newTestAuth(t)
+	_, _, codes := ta.enableMFA(t)
+	token := ta.challenge(t)
+
+	// typed without the dash, upper case : still the same code
+	typed := codes[0][:5] + codes[0][6:]
+	if _, err := ta.verify(t, token, "", " "+typed+" "); err != nil {
+		t.Fatalf("recovery code: %v", err)
+	}
+	if _, err := ta.verify(t, token, "", codes[0]); !errors.Is(err, ErrMFACodeInvalid) {
+		t.Fatalf("spent recovery code: err = %v, want ErrMFACodeInvalid", err)
+	}
+
+	status, err := ta.Service.GetMFAStatus(context.Background(), ta.User.ID, ta.User.Username)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.Enabled || status.RecoveryCodesLeft != mfaRecoveryCodes-1 {
+		t.Errorf("status = %+v, want enabled with %d codes left", status, mfaRecoveryCodes-1)
+	}
+}
+
+func TestMFALockout(t *testing.T) {
+	ta := newTestAuth(t)
+	secret, step, codes := ta.enableMFA(t)
+	token := ta.challenge(t)
+	max := ta.Service.Config.MFA.MFAMaxAttempts
+
+	// a good code clears the failures before the limit
+	for i := 0; i < max-1; i++ {
+		if _, err := ta.verify(t, token, "000000", ""); !errors.Is(err, ErrMFACodeInvalid) {
+			t.Fatalf("bad code %d: err = %v", i, err)
+		}
+	}
+	if _, err := ta.verify(t, token, totpAt(t, secret, step+1), ""); err != nil {
+		t.Fatalf("good code: %v", err)
+	}
+
+	for i := 0; i < max; i++ {
+		if _, err := ta.verify(t, token, "", "wrong-code"); !errors.Is(err, ErrMFACodeInvalid) {
+			t.Fatalf("bad recovery code %d: err = %v", i, err)
+		}
+	}
+	// locked : even a valid code or recovery code is refused
+	if _, err := ta.verify(t, token, "", codes[1]); !errors.Is(err, ErrMFALocked) {
+		t.Fatalf("after %d failures: err = %v, want ErrMFALocked", max, err)
+	}
+	m, _ := ta.MFA.GetMFAByUser(context.Background(), ta.User.ID)
+	if m.LockedUntil == nil || m.LockedUntil.Before(time.Now().Add(time.Duration(ta.Service.Config.MFA.MFALockMinutes-1)*time.Minute)) {
+		t.Errorf("locked_until = %v", m.LockedUntil)
+	}
+	if m.RecoveryCodes[1].UsedAt != nil {
+		t.Error("recovery code spent while locked")
+	}
+}
+
+func TestMFARequiredByRole(t *testing.T) {
+	ta := newTestAuth(t)
+	ctx := context.Background()
+	first := ta.login(t)
+
+	ta.Access.mfaRequired = true
+	// sessions opened before the role change can't refresh without 2fa
+	if _, err := ta.Service.GetJwtFromRefresh(ctx, first.RefreshToken, nil); !errors.Is(err, ErrMFAEnrollRequired) {
+		t.Fatalf("refresh: err = %v, want ErrMFAEnrollRequired", err)
+	}
+
+	res := ta.login(t)
+	if !res.MFARequired || !res.MFAEnrollRequired || res.AccessToken != "" {
+		t.Fatalf("login must ask for enrollment: %+v", res)
+	}
+	enrollment, err := ta.Service.EnrollMFAChallenge(ctx, res.MFAToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := ta.verify(t, res.MFAToken, totpAt(t, enrollment.Secret, pkg.TOTPStep(time.Now())), "")
+	if err != nil {
+		t.Fatalf("forced enrollment: %v", err)
+	}
+	if out.AccessToken == "" || len(out.RecoveryCodes) != mfaRecoveryCodes {
+		t.Fatalf("enrollment login must issue tokens and recovery codes: %+v", out)
+	}
+
+	if err := ta.Service.DisableMFA(ctx, ta.User.ID, ta.User.Username, "", out.RecoveryCodes[0]); !errors.Is(err, ErrMFARequiredByRole) {
+		t.Fatalf("disable: err = %v, want ErrMFARequiredByRole", err)
+	}
+}
+
+func TestMFAAdminReset(t *testing.T) {
+	ta := newTestAuth(t)
+	ta.enableMFA(t)
+
+	if err := ta.Service.ResetUserMFA(context.Background(), "root", ta.User.Username); err != nil {
+		t.Fatal(err)
+	}
+	if res := ta.login(t); res.MFARequired || res.AccessToken == "" {
+		t.Fatalf("after reset the password alone logs in: %+v", res)
+	}
+	if err := ta.Service.ResetUserMFA(context.Background(), "root", "nobody"); !errors.Is(err, users.ErrUserNotFound) {
+		t.Errorf("unknown user: err = %v", err)
+	}
+}
@@ -0,0 +1,177 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"go.uber.org/zap"
+)
+
+type SessionRevokeEnum string
+
+const (
+	SESSION_LOGOUT         SessionRevokeEnum = "LOGOUT"
+	SESSION_LOGOUT_ALL     SessionRevokeEnum = "LOGOUT_ALL"
+	SESSION_REVOKED        SessionRevokeEnum = "REVOKED"
+	SESSION_PASSWORD_RESET SessionRevokeEnum = "PASSWORD_RESET"
+	// an already rotated refresh token came back : the whole family is treated as stolen
+	SESSION_REUSE SessionRevokeEnum = "REUSE_DETECTED"
+)
+
+var (
+	ErrSessionNotFound = errors.New("session not found")
+	// current jti moved on before this rotation landed
+	ErrSessionRotated = errors.New("refresh token already rotated")
+)
+
+// SessionModel : one document per login (token family) ; _id hex is the "sid" claim,
+// JTI is the only refresh token of the family still accepted and moves on every rotation.
+// ExpiresAt is fixed at login : rotations never extend the family, the user logs in again.
+type SessionModel struct {
+	ID            bson.ObjectID      `bson:"_id"`
+	JTI           string             `bson:"jti"`
+	UserID        string             `bson:"user_id"`
+	Username      string             `bson:"username"`
+	TenantID      string             `bson:"tenant_id,omitempty"`
+	UserAgent     string             `bson:"user_agent"`
+	IP            string             `bson:"ip"`
+	Rotations     int                `bson:"rotations"`
+	CreatedAt     time.Time          `bson:"created_at"`
+	LastUsedAt    time.Time          `bson:"last_used_at"`
+	ExpiresAt     time.Time          `bson:"expires_at"`
+	RevokedAt     *time.Time         `bson:"revoked_at,omitempty"`
+	RevokedReason *SessionRevokeEnum `bson:"revoked_reason,omitempty"`
+}
+
+type SessionRepository interface {
+	InitRepository() error
+	CreateSession(ctx context.Context, session *SessionModel) (*SessionModel, error)
+	GetSessionByID(ctx context.Context, id string) (*SessionModel, error)
+	// RotateSession : swap jti -> nextJTI only while jti is still the current one and the session is live,
+	// expires_at is left as is
+	RotateSession(ctx context.Context, id string, jti string, nextJTI string) (*SessionModel, error)
+	RevokeSession(ctx context.Context, id string, reason SessionRevokeEnum) error
+	// RevokeUserSessions : returns the number of sessions revoked
+	RevokeUserSessions(ctx context.Context, userID string, reason SessionRevokeEnum) (int64, error)
+	GetActiveSessionsByUser(ctx context.Context, userID string) ([]SessionModel, error)
+}
+
+type sessionRepository struct {
+	Logger *zap.Logger
+	DB     *mongo.Collection
+}
+
+func NewSessionRepository(db *mongo.Collection, log *zap.Logger) SessionRepository {
+	return &sessionRepository{Logger: log, DB: db}
+}
+
+func (r *sessionRepository) InitRepository() error {
+	indexs := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "revoked_at", Value: 1}}},
+		// expired sessions are dropped by mongo
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	}
+	if _, err := r.DB.Indexes().CreateMany(context.TODO(), indexs); err != nil {
+		return errors.New("SessionRepository.InitRepository: failed create indexs")
+	}
+	r.Logger.Info("SessionRepository.InitRepository: index created")
+	return nil
+}
+
+func activeFilter(filter bson.M) bson.M {
+	filter["revoked_at"] = nil
+	filter["expires_at"] = bson.M{"$gt": time.Now()}
+	return filter
+}
+
+func (r *sessionRepository) CreateSession(ctx context.Context, session *SessionModel) (*SessionModel, error) {
+	session.ID = bson.NewObjectID()
+	if _, err := r.DB.InsertOne(ctx, session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+func (r *sessionRepository) GetSessionByID(ctx context.Context, id string) (*SessionModel, error) {
+	objectID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrSessionNotFound
+	}
+	var model SessionModel
+	if err := r.DB.FindOne(ctx, bson.M{"_id": objectID}).Decode(&model); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrSessionNotFound
+		}
+		return nil, err
+	}
+	return &model, nil
+}
+
+func (r *sessionRepository) RotateSession(ctx context.Context, id string, jti string, nextJTI string) (*SessionModel, error) {
+	objectID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrSessionNotFound
+	}
+	update := bson.M{
+		"$set": bson.M{"jti": nextJTI, "last_used_at": time.Now()},
+		"$inc": bson.M{"rotations": 1},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var model SessionModel
+	if err := r.DB.FindOneAndUpdate(ctx, activeFilter(bson.M{"_id": objectID, "jti": jti}), update, opts).Decode(&model); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrSessionRotated
+		}
+		return nil, err
+	}
+	return &model, nil
+}
+
+func (r *sessionRepository) RevokeSession(ctx context.Context, id string, reason SessionRevokeEnum) error {
+	objectID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrSessionNotFound
+	}
+	res, err := r.DB.UpdateOne(ctx,
+		bson.M{"_id": objectID, "revoked_at": nil},
+		bson.M{"$set": bson.M{"revoked_at": time.Now(), "revoked_reason": reason}},
+	)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+func (r *sessionRepository) RevokeUserSessions(ctx context.Context, userID string, reason SessionRevokeEnum) (int64, error) {
+	res, err := r.DB.UpdateMany(ctx,
+		bson.M{"user_id": userID, "revoked_at": nil},
+		bson.M{"$set": bson.M{"revoked_at": time.Now(), "revoked_reason": reason}},
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.ModifiedCount, nil
+}
+
+func (r *sessionRepository) GetActiveSessionsByUser(ctx context.Context, userID string) ([]SessionModel, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "last_used_at", Value: -1}})
+	cursor, err := r.DB.Find(ctx, activeFilter(bson.M{"user_id": userID}), opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	sessions := []SessionModel{}
+	if err := cursor.All(ctx, &sessions); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
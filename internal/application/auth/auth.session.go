@@ -0,0 +1,193 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+
+	"ecommerce/internal/application/users"
+	"ecommerce/internal/pkg"
+)
+
+// Refresh-token sessions.
+//
+// Every login opens a session (token family) ; its refresh token carries the session id ("sid")
+// and a one-time "jti". A refresh swaps the session jti for a new one, so the previous refresh
+// token stops working. Presenting a token whose jti is no longer the current one means it was
+// copied : the session is revoked and the legitimate holder has to log in again.
+// A session ends AuthJWTRefreshIN minutes after its login however often it is refreshed :
+// every refresh token of the family carries that same exp.
+// Access tokens are not checked against sessions, they run out after AuthJWTAccessIN minutes.
+
+var (
+	ErrInvalidRefresh = errors.New("invalid refresh token")
+	ErrSessionRevoked = errors.New("session is revoked")
+	ErrRefreshReuse   = errors.New("refresh token reuse detected, session revoked")
+)
+
+// SessionClient : who opened / refreshed the session, shown in the session list
+type SessionClient struct {
+	UserAgent string
+	IP        string
+}
+
+type SessionEntity struct {
+	ID         string    `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	// the session of the access token making the request
+	Current bool `json:"current"`
+}
+
+func SessionModelToEntity(model *SessionModel, currentID string) *SessionEntity {
+	return &SessionEntity{
+		ID:         model.ID.Hex(),
+		UserAgent:  model.UserAgent,
+		IP:         model.IP,
+		CreatedAt:  model.CreatedAt,
+		LastUsedAt: model.LastUsedAt,
+		ExpiresAt:  model.ExpiresAt,
+		Current:    model.ID.Hex() == currentID,
+	}
+}
+
+func newJTI() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (s *authService) refreshExpiry() time.Time {
+	return time.Now().Add(time.Minute * time.Duration(s.Config.JWT.AuthJWTRefreshIN))
+}
+
+// openSession : new token family for a login, the session holds its first jti
+func (s *authService) openSession(ctx context.Context, userID string, username string, tenantID string, client *SessionClient) (*SessionModel, error) {
+	now := time.Now()
+	model := &SessionModel{
+		JTI:        newJTI(),
+		UserID:     userID,
+		Username:   username,
+		TenantID:   tenantID,
+		CreatedAt:  now,
+		LastUsedAt: now,
+		ExpiresAt:  s.refreshExpiry(),
+	}
+	if tenantID == pkg.DEFAULT_TENANT {
+		model.TenantID = ""
+	}
+	if client != nil {
+		model.UserAgent = client.UserAgent
+		model.IP = client.IP
+	}
+	return s.SessionRepository.CreateSession(ctx, model)
+}
+
+// rotateSession : one-time use of the presented jti, reuse revokes the session
+func (s *authService) rotateSession(ctx context.Context, claims *AuthClaimsEntiy) (*SessionModel, error) {
+	if claims.SessionID == "" || claims.ID == "" {
+		// tokens issued before sessions existed : log in again
+		return nil, ErrInvalidRefresh
+	}
+	session, err := s.SessionRepository.GetSessionByID(ctx, claims.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session.RevokedAt != nil {
+		return nil, ErrSessionRevoked
+	}
+	if session.UserID != claims.Subject {
+		return nil, ErrInvalidRefresh
+	}
+
+	rotated, err := s.SessionRepository.RotateSession(ctx, claims.SessionID, claims.ID, newJTI())
+	if errors.Is(err, ErrSessionRotated) {
+		s.Logger.Warn("usecase.rotateSession: refresh token reuse",
+			zap.String("session_id", claims.SessionID), zap.String("username", claims.Username))
+		if err := s.SessionRepository.RevokeSession(ctx, claims.SessionID, SESSION_REUSE); err != nil && !errors.Is(err, ErrSessionNotFound) {
+			s.Logger.Error("usecase.rotateSession.RevokeSession:", zap.Error(err))
+		}
+		return nil, ErrRefreshReuse
+	}
+	if err != nil {
+		return nil, err
+	}
+	return rotated, nil
+}
+
+func (s *authService) Logout(ctx context.Context, refresh string) error {
+	claims, err := s.parseRefresh(refresh)
+	if err != nil {
+		return ErrInvalidRefresh
+	}
+	if claims.SessionID == "" {
+		return nil
+	}
+	// logging out twice is not an error
+	if err := s.SessionRepository.RevokeSession(ctx, claims.SessionID, SESSION_LOGOUT); err != nil && !errors.Is(err, ErrSessionNotFound) {
+		return err
+	}
+	return nil
+}
+
+func (s *authService) LogoutAll(ctx context.Context, userID string) (int64, error) {
+	return s.SessionRepository.RevokeUserSessions(ctx, userID, SESSION_LOGOUT_ALL)
+}
+
+func (s *authService) GetSessions(ctx context.Context, userID string, currentID string) ([]SessionEntity, error) {
+	models, err := s.SessionRepository.GetActiveSessionsByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]SessionEntity, len(models))
+	for i := range models {
+		res[i] = *SessionModelToEntity(&models[i], currentID)
+	}
+	return res, nil
+}
+
+func (s *authService) RevokeSession(ctx context.Context, userID string, sessionID string) error {
+	session, err := s.SessionRepository.GetSessionByID(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	// someone else's session looks the same as a missing one
+	if session.UserID != userID {
+		return ErrSessionNotFound
+	}
+	return s.SessionRepository.RevokeSession(ctx, sessionID, SESSION_REVOKED)
+}
+
+func (s *authService) ForgotPassword(ctx context.Context, email string) error {
+	return s.Accounts.ForgotPassword(ctx, email)
+}
+
+func (s *authService) ResetPassword(ctx context.Context, token string, password string) error {
+	user, err := s.Accounts.ResetPassword(ctx, token, password)
+	if err != nil {
+		return err
+	}
+	// whoever knew the old password may hold a refresh token
+	revoked, err := s.SessionRepository.RevokeUserSessions(ctx, user.ID, SESSION_PASSWORD_RESET)
+	if err != nil {
+		s.Logger.Error("usecase.ResetPassword.RevokeUserSessions:", zap.String("username", user.Username), zap.Error(err))
+		return nil
+	}
+	s.Logger.Info("usecase.ResetPassword: sessions revoked", zap.String("username", user.Username), zap.Int64("revoked", revoked))
+	return nil
+}
+
+func (s *authService) VerifyEmail(ctx context.Context, token string) (*users.UserDTO, error) {
+	return s.Accounts.VerifyEmail(ctx, token)
+}
+
+func (s *authService) ResendEmailVerification(ctx context.Context, username string) error {
+	return s.Accounts.SendEmailVerification(ctx, username)
+}
@@ -0,0 +1,190 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"ecommerce/internal/application/users"
+)
+
+func (ta *testAuth) refresh(t *testing.T, token string) (*AuthWithJwtDTO, error) {
+	t.Helper()
+	return ta.Service.GetJwtFromRefresh(context.Background(), token, &SessionClient{UserAgent: "test"})
+}
+
+func TestRefreshRotatesToken(t *testing.T) {
+	ta := newTestAuth(t)
+	first := ta.login(t)
+
+	second, err := ta.refresh(t, first.RefreshToken)
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken || second.SessionID != first.SessionID {
+		t.Fatalf("refresh must rotate the token within the session")
+	}
+	third, err := ta.refresh(t, second.RefreshToken)
+	if err != nil {
+		t.Fatalf("refresh of the rotated token: %v", err)
+	}
+
+	session, err := ta.Sessions.GetSessionByID(context.Background(), first.SessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.Rotations != 2 {
+		t.Errorf("rotations = %d, want 2", session.Rotations)
+	}
+	claims, err := ta.Service.parseRefresh(third.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.ID != session.JTI {
+		t.Errorf("refresh jti %s is not the session jti %s", claims.ID, session.JTI)
+	}
+}
+
+func TestRefreshKeepsSessionExpiry(t *testing.T) {
+	ta := newTestAuth(t)
+	first := ta.login(t)
+	loginExpiry := *first.RefreshExpiresAt
+
+	// later refreshes inherit the login expiry, they never extend it
+	time.Sleep(1100 * time.Millisecond)
+	next, err := ta.refresh(t, first.RefreshToken)
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if !next.RefreshExpiresAt.Equal(loginExpiry) {
+		t.Errorf("session expiry moved from %v to %v", loginExpiry, *next.RefreshExpiresAt)
+	}
+	claims, err := ta.Service.parseRefresh(next.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.ExpiresAt.Unix() != loginExpiry.Unix() {
+		t.Errorf("refresh token exp = %v, want the session expiry %v", claims.ExpiresAt.Time, loginExpiry)
+	}
+
+	// past the absolute expiry the family is dead, whatever the token exp says
+	ta.Sessions.mu.Lock()
+	for _, s := range ta.Sessions.sessions {
+		s.ExpiresAt = time.Now().Add(-time.Second)
+	}
+	ta.Sessions.mu.Unlock()
+	if _, err := ta.refresh(t, next.RefreshToken); err == nil {
+		t.Fatal("refresh after the session expiry must fail")
+	}
+}
+
+func TestRefreshReuseRevokesFamily(t *testing.T) {
+	ta := newTestAuth(t)
+	first := ta.login(t)
+
+	second, err := ta.refresh(t, first.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ta.refresh(t, first.RefreshToken); !errors.Is(err, ErrRefreshReuse) {
+		t.Fatalf("replayed token: err = %v, want ErrRefreshReuse", err)
+	}
+	// the legitimate holder is logged out too
+	if _, err := ta.refresh(t, second.RefreshToken); !errors.Is(err, ErrSessionRevoked) {
+		t.Fatalf("current token after reuse: err = %v, want ErrSessionRevoked", err)
+	}
+	session, _ := ta.Sessions.GetSessionByID(context.Background(), first.SessionID)
+	if session.RevokedReason == nil || *session.RevokedReason != SESSION_REUSE {
+		t.Errorf("revoked reason = %v, want %s", session.RevokedReason, SESSION_REUSE)
+	}
+}
+
+func TestLogout(t *testing.T) {
+	ta := newTestAuth(t)
+	ctx := context.Background()
+	res := ta.login(t)
+
+	if err := ta.Service.Logout(ctx, res.RefreshToken); err != nil {
+		t.Fatalf("logout: %v", err)
+	}
+	if _, err := ta.refresh(t, res.RefreshToken); !errors.Is(err, ErrSessionRevoked) {
+		t.Fatalf("refresh after logout: err = %v, want ErrSessionRevoked", err)
+	}
+	if err := ta.Service.Logout(ctx, res.RefreshToken); err != nil {
+		t.Errorf("second logout: %v", err)
+	}
+	if err := ta.Service.Logout(ctx, "not-a-jwt"); !errors.Is(err, ErrInvalidRefresh) {
+		t.Errorf("garbage token: err = %v, want ErrInvalidRefresh", err)
+	}
+}
+
+func TestLogoutAll(t *testing.T) {
+	ta := newTestAuth(t)
+	a, b := ta.login(t), ta.login(t)
+
+	n, err := ta.Service.LogoutAll(context.Background(), ta.User.ID)
+	if err != nil || n != 2 {
+		t.Fatalf("LogoutAll = %d, %v ; want 2 sessions", n, err)
+	}
+	for _, res := range []*AuthWithJwtDTO{a, b} {
+		if _, err := ta.refresh(t, res.RefreshToken); !errors.Is(err, ErrSessionRevoked) {
+			t.Errorf("refresh after logout-all: err = %v", err)
+		}
+	}
+}
+
+func TestRevokeSessionOfAnotherUser(t *testing.T) {
+	ta := newTestAuth(t)
+	res := ta.login(t)
+
+	if err := ta.Service.RevokeSession(context.Background(), "someone-else", res.SessionID); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("err = %v, want ErrSessionNotFound", err)
+	}
+	if _, err := ta.refresh(t, res.RefreshToken); err != nil {
+		t.Fatalf("session must survive: %v", err)
+	}
+}
+
+// fakeAccountService : a reset token that works once, for the account of the test user
+type fakeAccountService struct {
+	users.IAccountService
+	user  *users.UserEntity
+	token string
+}
+
+func (a *fakeAccountService) ResetPassword(ctx context.Context, token string, password string) (*users.UserEntity, error) {
+	if token == "" || token != a.token {
+		return nil, users.ErrAccountTokenInvalid
+	}
+	a.token = ""
+	return a.user, nil
+}
+
+func TestResetPasswordRevokesSessions(t *testing.T) {
+	ta := newTestAuth(t)
+	ctx := context.Background()
+	a, b := ta.login(t), ta.login(t)
+	ta.Service.Accounts = &fakeAccountService{user: ta.User, token: "reset-token"}
+
+	// a rejected token leaves the sessions alone
+	if err := ta.Service.ResetPassword(ctx, "wrong-token", "new-password"); !errors.Is(err, users.ErrAccountTokenInvalid) {
+		t.Fatalf("err = %v, want ErrAccountTokenInvalid", err)
+	}
+	if active, _ := ta.Sessions.GetActiveSessionsByUser(ctx, ta.User.ID); len(active) != 2 {
+		t.Fatalf("%d sessions after a rejected reset, want 2", len(active))
+	}
+
+	if err := ta.Service.ResetPassword(ctx, "reset-token", "new-password"); err != nil {
+		t.Fatalf("ResetPassword: %v", err)
+	}
+	for _, res := range []*AuthWithJwtDTO{a, b} {
+		if _, err := ta.refresh(t, res.RefreshToken); !errors.Is(err, ErrSessionRevoked) {
+			t.Errorf("refresh after reset: err = %v, want ErrSessionRevoked", err)
+		}
+		session, _ := ta.Sessions.GetSessionByID(ctx, res.SessionID)
+		if session.RevokedReason == nil || *session.RevokedReason != SESSION_PASSWORD_RESET {
+			t.Errorf("revoked reason = %v, want %s", session.RevokedReason, SESSION_PASSWORD_RESET)
+		}
+	}
+}
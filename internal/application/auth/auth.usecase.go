@@ -2,9 +2,12 @@ package auth
 
 import (
 	"context"
+	"ecommerce/internal/application/tenant"
 	"ecommerce/internal/application/users"
 	"ecommerce/internal/env"
+	"ecommerce/internal/pkg"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -13,9 +16,30 @@ import (
 )
 
 type IAuthService interface {
-  GetJwtFromLogin(ctx context.Context,user string, pssw string) (*AuthWithJwtDTO,error)
-  GetJwtFromRefresh(ctx context.Context, refresh string) (*AuthWithJwtDTO, error)
-  // GetJwtFromRefresh
+  GetJwtFromLogin(ctx context.Context,user string, pssw string, client *SessionClient) (*AuthWithJwtDTO,error)
+  GetJwtFromRefresh(ctx context.Context, refresh string, client *SessionClient) (*AuthWithJwtDTO, error)
+
+  // sessions : see auth.session.go
+  Logout(ctx context.Context, refresh string) error
+  LogoutAll(ctx context.Context, userID string) (int64, error)
+  GetSessions(ctx context.Context, userID string, currentID string) ([]SessionEntity, error)
+  RevokeSession(ctx context.Context, userID string, sessionID string) error
+
+  // account : see users.IAccountService, a password reset ends every session
+  ForgotPassword(ctx context.Context, email string) error
+  ResetPassword(ctx context.Context, token string, password string) error
+  VerifyEmail(ctx context.Context, token string) (*users.UserDTO, error)
+  ResendEmailVerification(ctx context.Context, username string) error
+
+  // 2fa : see auth.mfa.go
+  VerifyMFALogin(ctx context.Context, mfaToken string, code string, recoveryCode string, client *SessionClient) (*AuthWithJwtDTO, error)
+  EnrollMFAChallenge(ctx context.Context, mfaToken string) (*MFAEnrollmentDTO, error)
+  GetMFAStatus(ctx context.Context, userID string, username string) (*MFAStatusDTO, error)
+  EnrollMFA(ctx context.Context, userID string, username string) (*MFAEnrollmentDTO, error)
+  ActivateMFA(ctx context.Context, userID string, code string) ([]string, error)
+  DisableMFA(ctx context.Context, userID string, username string, code string, recoveryCode string) error
+  RegenerateRecoveryCodes(ctx context.Context, userID string, code string) ([]string, error)
+  ResetUserMFA(ctx context.Context, actor string, username string) error
 }
 
 type authService struct {
@@ -23,15 +47,30 @@ type authService struct {
   Logger *zap.Logger
 
   UserRepository users.UserRepository
+  SessionRepository SessionRepository
+  MFARepository MFARepository
+  Access users.IAccessService
+  Accounts users.IAccountService
+  Tenants tenant.ITenantService
 }
 
 func NewAuthService(cfg *env.Config, log *zap.Logger,
   userRepo users.UserRepository,
+  sessionRepo SessionRepository,
+  mfaRepo MFARepository,
+  access users.IAccessService,
+  accounts users.IAccountService,
+  tenants tenant.ITenantService,
 ) IAuthService {
   return &authService{
     Config: cfg,
     Logger: log,
     UserRepository: userRepo,
+    SessionRepository: sessionRepo,
+    MFARepository: mfaRepo,
+    Access: access,
+    Accounts: accounts,
+    Tenants: tenants,
   }
 }
 
@@ -41,7 +80,19 @@ type AuthWithJwtDTO struct {
   FullName  string `json:"full_name"`
   AccessToken  string `json:"access_token"`
   RefreshToken string `json:"refresh_token"`
+  SessionID string `json:"session_id"`
+  // end of the session : refreshing never pushes it further
+  RefreshExpiresAt *time.Time `json:"refresh_expires_at,omitempty"`
   TenantID  *string`json:"tenant_id,omitempty"`
+  Roles     []string `json:"roles"`
+  Permissions []string `json:"permissions"`
+
+  // 2fa : password accepted, tokens come from /auth/mfa/verify with this challenge
+  MFARequired bool `json:"mfa_required,omitempty"`
+  MFAEnrollRequired bool `json:"mfa_enroll_required,omitempty"` // a role requires 2fa, enroll with the challenge first
+  MFAToken string `json:"mfa_token,omitempty"`
+  // shown once, when 2fa gets enabled during login
+  RecoveryCodes []string `json:"recovery_codes,omitempty"`
 }
 
 
@@ -50,16 +101,100 @@ type JwtType string
 const (
   Access JwtType = "access"
   Refresh JwtType = "refresh"
+  // between password and second factor, opens /auth/mfa/* only
+  MFAChallenge JwtType = "mfa"
 )
 
 
 type AuthClaimsEntiy struct {
-  Type JwtType 
-  Username string
+  Type JwtType `json:"type"`
+  Username string `json:"username"`
+  // rbac : access token only, resolved again on every refresh
+  Roles       []string `json:"roles,omitempty"`
+  Permissions []string `json:"perms,omitempty"`
+  TenantID    string `json:"tenant_id,omitempty"`
+  // session (token family) of both tokens, the refresh jti is RegisteredClaims.ID
+  SessionID   string `json:"sid,omitempty"`
   jwt.RegisteredClaims
 } 
 
-func (s *authService) GetJwtFromLogin(ctx context.Context, user string, pssw string) ( *AuthWithJwtDTO ,error) {
+// userTenant : tenant carried by the tokens, pkg.DEFAULT_TENANT for platform accounts
+func userTenant(user *users.UserEntity) string {
+  if user.TenantID == nil || *user.TenantID == "" { return pkg.DEFAULT_TENANT }
+  return *user.TenantID
+}
+
+// parseRefresh : signature, expiry and type of a refresh token, the session is not looked at
+func (s *authService) parseRefresh(refresh string) (*AuthClaimsEntiy, error) {
+  return s.parseToken(refresh, Refresh)
+}
+
+func (s *authService) parseToken(tokenStr string, want JwtType) (*AuthClaimsEntiy, error) {
+  secret := []byte(s.Config.JWT.AuthJWTSecretKey)
+
+  token,err := jwt.ParseWithClaims(tokenStr, &AuthClaimsEntiy{},func(token *jwt.Token) (interface{}, error ){
+  if _,ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+      return nil, errors.New("unexpected signing method")
+    }
+    return secret, nil
+  } )
+  if err != nil { return nil, err }
+
+  claims, ok := token.Claims.(*AuthClaimsEntiy)
+  if !ok || !token.Valid {
+    return nil, ErrInvalidRefresh
+  }
+
+  if claims.Type != want {
+    return nil, fmt.Errorf("not a %s token type", want)
+  }
+
+  if claims.ExpiresAt != nil && claims.ExpiresAt.Time.Before(time.Now()){
+    return nil, fmt.Errorf("%s token expired", want)
+  }
+  return claims, nil
+}
+
+// signTokens : access + refresh pair of a session, login and refresh share the lifetimes
+func (s *authService) signTokens(user *users.UserEntity, access *users.UserAccessDTO, tenantID string, session *SessionModel) (string, string, error) {
+  secret := []byte(s.Config.JWT.AuthJWTSecretKey)
+  now := time.Now()
+
+  accessTokenClaims := &AuthClaimsEntiy{
+    Type: Access,
+    Username: user.Username,
+    Roles: access.RoleNames,
+    Permissions: access.Effective,
+    TenantID: tenantID,
+    SessionID: session.ID.Hex(),
+    RegisteredClaims: jwt.RegisteredClaims{
+      Subject: user.ID,
+      IssuedAt: jwt.NewNumericDate(now),
+      ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute * time.Duration(s.Config.JWT.AuthJWTAccessIN)) ),
+    },
+  }
+
+  refreshTokenClaims := &AuthClaimsEntiy{
+    Type: Refresh,
+    Username: user.Username,
+    TenantID: tenantID,
+    SessionID: session.ID.Hex(),
+    RegisteredClaims: jwt.RegisteredClaims{
+      ID: session.JTI,
+      Subject: user.ID,
+      IssuedAt: jwt.NewNumericDate(now),
+      ExpiresAt: jwt.NewNumericDate(session.ExpiresAt),
+    },
+  }
+
+  signAccess, err := jwt.NewWithClaims(jwt.SigningMethodHS256, accessTokenClaims).SignedString(secret)
+  if err != nil { return "", "", err }
+  signRefresh, err := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshTokenClaims).SignedString(secret)
+  if err != nil { return "", "", err }
+  return signAccess, signRefresh, nil
+}
+
+func (s *authService) GetJwtFromLogin(ctx context.Context, user string, pssw string, client *SessionClient) ( *AuthWithJwtDTO ,error) {
   // check user
   userRes, err := s.UserRepository.GetUserDetailByUsername(ctx, user)
   if err != nil {
@@ -76,44 +211,48 @@ func (s *authService) GetJwtFromLogin(ctx context.Context, user string, pssw str
     return nil , checkPassword
   }
 
-  // // generate jwt
-  accessClaims := jwt.MapClaims{
-    "sub": userRes.ID,
-    "type": "access",
-    "username": userRes.Username,
-    "iat" : time.Now().Unix(),
-    "exp" : time.Now().Add(time.Minute * time.Duration(s.Config.JWT.AuthJWTAccessIN)).Unix(),
+  tenantID := userTenant(userRes)
+  if err := s.Tenants.CheckTenantActive(ctx, tenantID); err != nil {
+    s.Logger.Info("usecase.GetJwtFromLogin.CheckTenantActive:", zap.String("tenant_id", tenantID), zap.Error(err))
+    return nil, err
   }
 
-  refreshClaims := jwt.MapClaims{
-    "sub": userRes.ID,
-    "type": "refresh",
-    "username": userRes.Username,
-    "iat" : time.Now().Unix(),
-    "exp" : time.Now().Add(time.Hour * 24 * 30).Unix(),
+  // rbac : permissions travel in the access token
+  access, err := s.Access.ResolveUserAccess(ctx, userRes.Username)
+  if err != nil {
+    s.Logger.Error("usecase.GetJwtFromLogin.ResolveUserAccess:", zap.Error(err))
+    return nil, err
   }
 
-  accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims)
-  refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims)
+  // 2fa : enrolled, or required by a role -> second step before any token
+  mfa, err := s.MFARepository.GetMFAByUser(ctx, userRes.ID)
+  if err != nil && !errors.Is(err, ErrMFANotFound) { return nil, err }
+  if (mfa != nil && mfa.Enabled) || access.MFARequired {
+    return s.mfaChallenge(userRes, tenantID, mfa == nil || !mfa.Enabled)
+  }
 
+  return s.completeLogin(ctx, userRes, tenantID, access, client)
+} 
 
-  // // sign token
-  accessTokenString, err := accessToken.SignedString( []byte(s.Config.JWT.AuthJWTSecretKey))
+// completeLogin : every login is a new token family
+func (s *authService) completeLogin(ctx context.Context, userRes *users.UserEntity, tenantID string, access *users.UserAccessDTO, client *SessionClient) (*AuthWithJwtDTO, error) {
+  session, err := s.openSession(ctx, userRes.ID, userRes.Username, tenantID, client)
   if err != nil {
-  s.Logger.Info("usecase.GetJwtFromLogin:", zap.String("jwt:", err.Error()))
-    return nil, err 
+    s.Logger.Error("usecase.completeLogin.openSession:", zap.Error(err))
+    return nil, err
   }
 
-  refreshTokenString, err := refreshToken.SignedString([]byte(s.Config.JWT.AuthJWTSecretKey)) 
+  accessTokenString, refreshTokenString, err := s.signTokens(userRes, access, tenantID, session)
   if err != nil {
-    return nil , err
+    s.Logger.Info("usecase.completeLogin:", zap.String("jwt:", err.Error()))
+    return nil, err 
   }
 
   //stanmp login in User repo 
   var onTime = time.Now()
-  var loginAt = &users.UserEntity{ Username : user, LastLoginAt: &onTime } 
+  var loginAt = &users.UserEntity{ Username : userRes.Username, LastLoginAt: &onTime } 
   _,errO := s.UserRepository.UpdateUserDetail(ctx, *loginAt)
-  if errO != nil { return nil, err} 
+  if errO != nil { return nil, errO} 
 
   loginMeta := &AuthWithJwtDTO {
     Username: userRes.Username,
@@ -121,72 +260,48 @@ func (s *authService) GetJwtFromLogin(ctx context.Context, user string, pssw str
     FullName: userRes.FullName,
     AccessToken: accessTokenString,
     RefreshToken: refreshTokenString,
+    SessionID: session.ID.Hex(),
+    RefreshExpiresAt: &session.ExpiresAt,
+    TenantID: &tenantID,
+    Roles: access.RoleNames,
+    Permissions: access.Effective,
   }
 
   return loginMeta, nil
 } 
 
 
-func (s *authService) GetJwtFromRefresh(ctx context.Context, refresh string) (*AuthWithJwtDTO, error) {
-
-  secret := []byte(s.Config.JWT.AuthJWTSecretKey)
+func (s *authService) GetJwtFromRefresh(ctx context.Context, refresh string, client *SessionClient) (*AuthWithJwtDTO, error) {
 
   // 1.parse refresh 
-  token,err := jwt.ParseWithClaims(refresh, &AuthClaimsEntiy{},func(token *jwt.Token) (interface{}, error ){
-  if _,ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-      return nil, errors.New("unexpected signing method")
-    }
-    return secret, nil
-  } )
+  claims, err := s.parseRefresh(refresh)
   if err != nil { return nil, err }
 
-  // 2.Extract refresh 
-  claims, ok := token.Claims.(*AuthClaimsEntiy)
-  if !ok || !token.Valid {
-    return nil, errors.New("invalid refresh token")
-  }
-
-  // 3.Check type
-  if claims.Type != "refresh" {
-    return nil, errors.New("not a refresh token type")
-  }
-
-  if claims.ExpiresAt != nil && claims.ExpiresAt.Time.Before(time.Now()){
-    return nil, errors.New("refresh token expired ")
-  }
-
-  // 4.Verify user
+  // 2.Verify user
   user,err := s.UserRepository.GetUserDetailByUsername(ctx,claims.Username) 
   if err!=nil {
     return nil, errors.New("user not found")
   } 
 
-  accessTokenClaims := &AuthClaimsEntiy{
-    Type: Access,
-    Username: claims.Username,
-    RegisteredClaims: jwt.RegisteredClaims{
-      Subject: user.ID,
-      IssuedAt: jwt.NewNumericDate(time.Now()),
-      ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute * time.Duration(s.Config.JWT.AuthJWTAccessIN)) ),
-    },
-  }
+  // suspended tenant : no new tokens, the current access token runs out
+  tenantID := userTenant(user)
+  if err := s.Tenants.CheckTenantActive(ctx, tenantID); err != nil { return nil, err }
 
-  refreshTokenClaims := &AuthClaimsEntiy{
-    Type: Refresh,
-    Username: claims.Username,
-    RegisteredClaims: jwt.RegisteredClaims{
-      Subject: user.ID,
-      IssuedAt: jwt.NewNumericDate(time.Now()),
-      ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute * time.Duration(s.Config.JWT.AuthJWTRefreshIN))),
-    },
+  // 3.Resolve grants again : role changes apply from here
+  access, err := s.Access.ResolveUserAccess(ctx, claims.Username)
+  if err != nil { return nil, err }
+
+  // a role started requiring 2fa : no refresh until the user logs in (and enrolls) again
+  if access.MFARequired {
+    mfa, err := s.MFARepository.GetMFAByUser(ctx, user.ID)
+    if err != nil || !mfa.Enabled { return nil, ErrMFAEnrollRequired }
   }
 
-  accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, accessTokenClaims)
-  refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshTokenClaims)
-  
-  signAccess, err := accessToken.SignedString(secret) 
-  if err != nil { return nil, err}
-  signRefresh, err := refreshToken.SignedString(secret)
+  // 4.One-time use : the presented jti is spent, reuse revokes the session
+  session, err := s.rotateSession(ctx, claims)
+  if err != nil { return nil, err }
+
+  signAccess, signRefresh, err := s.signTokens(user, access, tenantID, session)
   if err != nil { return nil, err}
 
 
@@ -194,7 +309,7 @@ func (s *authService) GetJwtFromRefresh(ctx context.Context, refresh string) (*A
   var onTime = time.Now()
   var loginAt = &users.UserEntity{ Username : claims.Username, LastLoginAt: &onTime } 
   _,errO := s.UserRepository.UpdateUserDetail(ctx, *loginAt)
-  if errO != nil { return nil, err} 
+  if errO != nil { return nil, errO} 
 
   refreshMeta := &AuthWithJwtDTO {
     Username: user.Username,
@@ -202,6 +317,11 @@ func (s *authService) GetJwtFromRefresh(ctx context.Context, refresh string) (*A
     FullName: user.FullName,
     AccessToken: signAccess,
     RefreshToken: signRefresh,
+    SessionID: session.ID.Hex(),
+    RefreshExpiresAt: &session.ExpiresAt,
+    TenantID: &tenantID,
+    Roles: access.RoleNames,
+    Permissions: access.Effective,
   }
 
   return refreshMeta,nil 
@@ -0,0 +1,259 @@
+package fulfillment
+
+import (
+	"errors"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	"ecommerce/internal/adapter/dto"
+	"ecommerce/internal/application/inventory"
+	"ecommerce/internal/delivery/http/response"
+)
+
+// no shop ids = READY_TO_SHIP orders of every shop
+type IReqWave struct {
+	ShopIDs   []string `json:"shop_ids" validate:"dive,required"`
+	Warehouse string   `json:"warehouse"`                           // default INVENTORY_DEFAULT_WAREHOUSE
+	MaxOrders int      `json:"max_orders" validate:"gte=0,lte=500"` // default 100
+	Note      string   `json:"note"`
+}
+
+type IReqWaveQuery struct {
+	Warehouse string `query:"warehouse"`
+	Page      int    `query:"page"`
+	Size      int    `query:"size"`
+}
+
+// no order ids = every order of the wave still PICKING
+type IReqPickConfirm struct {
+	OrderIDs []string `json:"order_ids" validate:"dive,required"`
+}
+
+// code : barcode, master sku or channel sku
+type IReqPackScan struct {
+	Code     string `json:"code" validate:"required,max=128"`
+	Quantity int64  `json:"quantity" validate:"gte=0"` // default 1
+}
+
+// Shopee : one of pickup / dropoff / non_integrated (see the order shipping_parameter) ;
+// package_number "" = every package not shipped yet, ignored when shipping a whole wave.
+// Other channels ship every pending line of the order in one call.
+type IReqFulfillmentShip struct {
+	PackageNumber string                   `json:"package_number"`
+	Pickup        *dto.IBShipPickup        `json:"pickup"`
+	Dropoff       *dto.IBShipDropoff       `json:"dropoff"`
+	NonIntegrated *dto.IBShipNonIntegrated `json:"non_integrated"`
+	// other channels (Lazada) : carrier, and the tracking number when the channel gives none on pack
+	Carrier        string `json:"carrier"`
+	TrackingNumber string `json:"tracking_number"`
+}
+
+type IReqFulfillmentOrderQuery struct {
+	WaveID  string                `query:"wave_id"`
+	Status  FulfillmentStatusEnum `query:"status"`
+	Channel string                `query:"channel"`
+	ShopID  string                `query:"shop_id"`
+	OrderSN string                `query:"order_sn"`
+	Page    int                   `query:"page"`
+	Size    int                   `query:"size"`
+}
+
+type IFulfillmentHandler interface {
+	GetWaves(c *fiber.Ctx) error
+	PostWave(c *fiber.Ctx) error
+	GetWaveByID(c *fiber.Ctx) error
+	GetPickList(c *fiber.Ctx) error
+	PostConfirmPicked(c *fiber.Ctx) error
+	PostShipWave(c *fiber.Ctx) error
+
+	GetFulfillmentOrders(c *fiber.Ctx) error
+	GetFulfillmentOrderByID(c *fiber.Ctx) error
+	PostPackScan(c *fiber.Ctx) error
+	PostPackReset(c *fiber.Ctx) error
+	PostShipFulfillmentOrder(c *fiber.Ctx) error
+	PostCancelFulfillmentOrder(c *fiber.Ctx) error
+}
+
+type fulfillmentHandler struct {
+	Logger   *zap.Logger
+	Validate *validator.Validate
+	Service  IFulfillmentService
+}
+
+func NewFulfillmentHandler(log *zap.Logger, valid *validator.Validate, srv IFulfillmentService) IFulfillmentHandler {
+	return &fulfillmentHandler{
+		Logger:   log,
+		Validate: valid,
+		Service:  srv,
+	}
+}
+
+// actor : username set by the auth middleware
+func actor(c *fiber.Ctx) string {
+	if username, ok := c.Locals("username").(string); ok {
+		return username
+	}
+	return ""
+}
+
+func fulfillmentErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrWaveNotFound), errors.Is(err, ErrFulfillmentOrderNotFound), errors.Is(err, inventory.ErrWarehouseNotFound):
+		return fiber.StatusNotFound
+	case errors.Is(err, ErrFulfillmentStatus), errors.Is(err, ErrFulfillmentConflict), errors.Is(err, ErrNoOrderToWave),
+		errors.Is(err, ErrScanNotInOrder), errors.Is(err, ErrScanOverQuantity):
+		return fiber.StatusConflict
+	case errors.Is(err, ErrShipFailed):
+		return fiber.StatusBadGateway
+	}
+	return fiber.StatusBadRequest
+}
+
+// -- waves
+
+func (d *fulfillmentHandler) GetWaves(c *fiber.Ctx) error {
+	var query IReqWaveQuery
+	if err := c.QueryParser(&query); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.GetWaves", "invalid query")
+	}
+
+	res, err := d.Service.GetWaves(c.Context(), &query)
+	if err != nil {
+		return response.ErrorResponse(c, fiber.StatusInternalServerError, "handler.GetWaves", err)
+	}
+	return response.SuccessResponse(c, "handler.GetWaves", res)
+}
+
+func (d *fulfillmentHandler) PostWave(c *fiber.Ctx) error {
+	var reqBody IReqWave
+	if err := c.BodyParser(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostWave", "invalid body")
+	}
+	if err := d.Validate.Struct(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostWave", err)
+	}
+
+	res, err := d.Service.CreateWave(c.Context(), actor(c), &reqBody)
+	if err != nil {
+		return response.ErrorResponse(c, fulfillmentErrorStatus(err), "handler.PostWave", err)
+	}
+	return response.SuccessResponse(c, "handler.PostWave", res)
+}
+
+func (d *fulfillmentHandler) GetWaveByID(c *fiber.Ctx) error {
+	res, err := d.Service.GetWaveByID(c.Context(), c.Params("waveID"))
+	if err != nil {
+		return response.ErrorResponse(c, fulfillmentErrorStatus(err), "handler.GetWaveByID", err)
+	}
+	return response.SuccessResponse(c, "handler.GetWaveByID", res)
+}
+
+func (d *fulfillmentHandler) GetPickList(c *fiber.Ctx) error {
+	res, err := d.Service.GetPickList(c.Context(), c.Params("waveID"))
+	if err != nil {
+		return response.ErrorResponse(c, fulfillmentErrorStatus(err), "handler.GetPickList", err)
+	}
+	return response.SuccessResponse(c, "handler.GetPickList", res)
+}
+
+func (d *fulfillmentHandler) PostConfirmPicked(c *fiber.Ctx) error {
+	var reqBody IReqPickConfirm
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&reqBody); err != nil {
+			return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostConfirmPicked", "invalid body")
+		}
+	}
+	if err := d.Validate.Struct(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostConfirmPicked", err)
+	}
+
+	res, err := d.Service.ConfirmPicked(c.Context(), c.Params("waveID"), actor(c), &reqBody)
+	if err != nil {
+		return response.ErrorResponse(c, fulfillmentErrorStatus(err), "handler.PostConfirmPicked", err)
+	}
+	return response.SuccessResponse(c, "handler.PostConfirmPicked", res)
+}
+
+func (d *fulfillmentHandler) PostShipWave(c *fiber.Ctx) error {
+	var reqBody IReqFulfillmentShip
+	if err := c.BodyParser(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostShipWave", "invalid body")
+	}
+
+	res, err := d.Service.ShipWave(c.Context(), c.Params("waveID"), actor(c), &reqBody)
+	if err != nil {
+		return response.ErrorResponse(c, fulfillmentErrorStatus(err), "handler.PostShipWave", err)
+	}
+	return response.SuccessResponse(c, "handler.PostShipWave", res)
+}
+
+// -- orders
+
+func (d *fulfillmentHandler) GetFulfillmentOrders(c *fiber.Ctx) error {
+	var query IReqFulfillmentOrderQuery
+	if err := c.QueryParser(&query); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.GetFulfillmentOrders", "invalid query")
+	}
+
+	res, err := d.Service.GetFulfillmentOrders(c.Context(), &query)
+	if err != nil {
+		return response.ErrorResponse(c, fulfillmentErrorStatus(err), "handler.GetFulfillmentOrders", err)
+	}
+	return response.SuccessResponse(c, "handler.GetFulfillmentOrders", res)
+}
+
+func (d *fulfillmentHandler) GetFulfillmentOrderByID(c *fiber.Ctx) error {
+	res, err := d.Service.GetFulfillmentOrderByID(c.Context(), c.Params("fulfillmentID"))
+	if err != nil {
+		return response.ErrorResponse(c, fulfillmentErrorStatus(err), "handler.GetFulfillmentOrderByID", err)
+	}
+	return response.SuccessResponse(c, "handler.GetFulfillmentOrderByID", res)
+}
+
+func (d *fulfillmentHandler) PostPackScan(c *fiber.Ctx) error {
+	var reqBody IReqPackScan
+	if err := c.BodyParser(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostPackScan", "invalid body")
+	}
+	if err := d.Validate.Struct(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostPackScan", err)
+	}
+
+	res, err := d.Service.ScanItem(c.Context(), c.Params("fulfillmentID"), actor(c), &reqBody)
+	if err != nil {
+		return response.ErrorResponse(c, fulfillmentErrorStatus(err), "handler.PostPackScan", err)
+	}
+	return response.SuccessResponse(c, "handler.PostPackScan", res)
+}
+
+func (d *fulfillmentHandler) PostPackReset(c *fiber.Ctx) error {
+	res, err := d.Service.ResetPacking(c.Context(), c.Params("fulfillmentID"), actor(c))
+	if err != nil {
+		return response.ErrorResponse(c, fulfillmentErrorStatus(err), "handler.PostPackReset", err)
+	}
+	return response.SuccessResponse(c, "handler.PostPackReset", res)
+}
+
+func (d *fulfillmentHandler) PostShipFulfillmentOrder(c *fiber.Ctx) error {
+	var reqBody IReqFulfillmentShip
+	if err := c.BodyParser(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostShipFulfillmentOrder", "invalid body")
+	}
+
+	res, err := d.Service.ShipFulfillmentOrder(c.Context(), c.Params("fulfillmentID"), actor(c), &reqBody)
+	if err != nil {
+		d.Logger.Error("handler.PostShipFulfillmentOrder : ShipFulfillmentOrder", zap.Error(err))
+		return response.ErrorResponse(c, fulfillmentErrorStatus(err), "handler.PostShipFulfillmentOrder", err)
+	}
+	return response.SuccessResponse(c, "handler.PostShipFulfillmentOrder", res)
+}
+
+func (d *fulfillmentHandler) PostCancelFulfillmentOrder(c *fiber.Ctx) error {
+	res, err := d.Service.CancelFulfillmentOrder(c.Context(), c.Params("fulfillmentID"), actor(c))
+	if err != nil {
+		return response.ErrorResponse(c, fulfillmentErrorStatus(err), "handler.PostCancelFulfillmentOrder", err)
+	}
+	return response.SuccessResponse(c, "handler.PostCancelFulfillmentOrder", res)
+}
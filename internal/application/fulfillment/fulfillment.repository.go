@@ -0,0 +1,317 @@
+package fulfillment
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"go.uber.org/zap"
+
+	"ecommerce/internal/adapter/dto"
+	"ecommerce/internal/pkg"
+)
+
+// FulfillmentStatusEnum : warehouse side of an order, independent from the marketplace OrderStatus
+type FulfillmentStatusEnum string
+
+const (
+	FULFILLMENT_PICKING FulfillmentStatusEnum = "PICKING" // in a wave, not picked yet
+	FULFILLMENT_PICKED  FulfillmentStatusEnum = "PICKED"
+	FULFILLMENT_PACKED  FulfillmentStatusEnum = "PACKED"  // every line scanned
+	FULFILLMENT_SHIPPED FulfillmentStatusEnum = "SHIPPED" // handed to logistics
+	// out of the wave : order cancelled on the channel or pulled by the warehouse, may be waved again
+	FULFILLMENT_CANCELLED FulfillmentStatusEnum = "CANCELLED"
+)
+
+var (
+	ErrWaveNotFound             = errors.New("wave not found")
+	ErrFulfillmentOrderNotFound = errors.New("fulfillment order not found")
+	ErrDuplicateFulfillment     = errors.New("order already in a wave")
+	// optimistic concurrency : someone else updated the order (another scan), read again
+	ErrFulfillmentConflict = errors.New("fulfillment order changed concurrently, retry")
+)
+
+// WaveModel : batch of orders picked together from one warehouse
+type WaveModel struct {
+	ID         bson.ObjectID `bson:"_id"`
+	TenantID   string        `bson:"tenant_id,omitempty"` // missing for the platform tenant : see pkg.TenantFilter
+	Number     string        `bson:"number"`
+	Warehouse  string        `bson:"warehouse"`
+	ShopIDs    []string      `bson:"shop_ids"` // empty = every shop
+	OrderCount int           `bson:"order_count"`
+	Note       string        `bson:"note"`
+	CreatedAt  time.Time     `bson:"created_at"`
+	CreatedBy  string        `bson:"created_by"`
+}
+
+// one line per order item ; SKU "" = listing not mapped to the catalog
+type FulfillmentLineModel struct {
+	ItemID         string   `bson:"item_id"`
+	ModelID        string   `bson:"model_id"`
+	ChannelSKU     string   `bson:"channel_sku"`
+	SKU            string   `bson:"sku"`
+	Name           string   `bson:"name"`
+	Barcodes       []string `bson:"barcodes"` // snapshot taken when waved
+	Quantity       int64    `bson:"quantity"`
+	PackedQuantity int64    `bson:"packed_quantity"`
+}
+
+// package handed to logistics : split orders ship one package at a time
+type FulfillmentPackageModel struct {
+	PackageNumber  string    `bson:"package_number"`
+	TrackingNumber string    `bson:"tracking_number"`
+	ShippedAt      time.Time `bson:"shipped_at"`
+	ShippedBy      string    `bson:"shipped_by"`
+}
+
+// one document per channel order (channel, shop_id, order_sn), reused when a cancelled one is waved again
+type FulfillmentOrderModel struct {
+	ID         bson.ObjectID              `bson:"_id"`
+	TenantID   string                     `bson:"tenant_id,omitempty"`
+	WaveID     bson.ObjectID              `bson:"wave_id"`
+	WaveNumber string                     `bson:"wave_number"`
+	Channel    dto.MarketplaceChannelEnum `bson:"channel,omitempty"` // missing on documents from before Lazada : SHOPEE
+	ShopID     string                     `bson:"shop_id"`
+	OrderSN    string                     `bson:"order_sn"` // channel order id
+	Warehouse  string                     `bson:"warehouse"`
+	Status     FulfillmentStatusEnum      `bson:"status"`
+	Lines      []FulfillmentLineModel     `bson:"lines"`
+	Packages   []FulfillmentPackageModel  `bson:"packages"`
+	LastError  string                     `bson:"last_error"`
+	Version    int64                      `bson:"version"`
+	CreatedAt  time.Time                  `bson:"created_at"`
+	CreatedBy  string                     `bson:"created_by"`
+	UpdatedAt  time.Time                  `bson:"updated_at"`
+	UpdatedBy  string                     `bson:"updated_by"`
+	PickedAt   time.Time                  `bson:"picked_at,omitempty"`
+	PickedBy   string                     `bson:"picked_by,omitempty"`
+	PackedAt   time.Time                  `bson:"packed_at,omitempty"`
+	PackedBy   string                     `bson:"packed_by,omitempty"`
+	ShippedAt  time.Time                  `bson:"shipped_at,omitempty"`
+	ShippedBy  string                     `bson:"shipped_by,omitempty"`
+}
+
+type WaveFilter struct {
+	Warehouse string
+	Skip      int64
+	Limit     int64
+}
+
+type FulfillmentOrderFilter struct {
+	WaveID  bson.ObjectID // zero = any
+	Status  FulfillmentStatusEnum
+	Channel dto.MarketplaceChannelEnum
+	ShopID  string
+	OrderSN string
+	Skip    int64
+	Limit   int64 // 0 = no limit
+}
+
+type WaveRepository interface {
+	InitRepository() error
+	CreateWave(ctx context.Context, wave *WaveModel) (*WaveModel, error)
+	GetWaveByID(ctx context.Context, id string) (*WaveModel, error)
+	GetWaves(ctx context.Context, filter *WaveFilter) ([]WaveModel, error)
+}
+
+type FulfillmentOrderRepository interface {
+	InitRepository() error
+	// ErrDuplicateFulfillment when the order already has a fulfillment document
+	CreateFulfillmentOrder(ctx context.Context, order *FulfillmentOrderModel) (*FulfillmentOrderModel, error)
+	// applied only if the stored version is still order.Version : ErrFulfillmentConflict otherwise
+	UpdateFulfillmentOrder(ctx context.Context, order *FulfillmentOrderModel) (*FulfillmentOrderModel, error)
+	GetFulfillmentOrderByID(ctx context.Context, id string) (*FulfillmentOrderModel, error)
+	GetFulfillmentOrderByOrderSN(ctx context.Context, channel dto.MarketplaceChannelEnum, shopID string, orderSN string) (*FulfillmentOrderModel, error)
+	GetFulfillmentOrders(ctx context.Context, filter *FulfillmentOrderFilter) ([]FulfillmentOrderModel, error)
+}
+
+type waveRepository struct {
+	Logger *zap.Logger
+	DB     *mongo.Collection
+}
+
+func NewWaveRepository(db *mongo.Collection, log *zap.Logger) WaveRepository {
+	return &waveRepository{Logger: log, DB: db}
+}
+
+func (r *waveRepository) InitRepository() error {
+	indexs := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "number", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "warehouse", Value: 1}, {Key: "created_at", Value: -1}}},
+	}
+	if _, err := r.DB.Indexes().CreateMany(context.TODO(), indexs); err != nil {
+		return errors.New("WaveRepository.InitRepository: failed create indexs")
+	}
+	r.Logger.Info("WaveRepository.InitRepository: index created")
+	return nil
+}
+
+func (r *waveRepository) CreateWave(ctx context.Context, wave *WaveModel) (*WaveModel, error) {
+	if wave.ID.IsZero() {
+		wave.ID = bson.NewObjectID()
+	}
+	tenantID, err := pkg.TenantStamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	wave.TenantID = tenantID
+	if _, err := r.DB.InsertOne(ctx, wave); err != nil {
+		return nil, err
+	}
+	return wave, nil
+}
+
+func (r *waveRepository) GetWaveByID(ctx context.Context, id string) (*WaveModel, error) {
+	oid, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrWaveNotFound
+	}
+	var model WaveModel
+	if err := r.DB.FindOne(ctx, pkg.TenantFilter(ctx, bson.M{"_id": oid})).Decode(&model); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrWaveNotFound
+		}
+		return nil, err
+	}
+	return &model, nil
+}
+
+func (r *waveRepository) GetWaves(ctx context.Context, filter *WaveFilter) ([]WaveModel, error) {
+	query := pkg.TenantFilter(ctx, bson.M{})
+	if filter.Warehouse != "" {
+		query["warehouse"] = filter.Warehouse
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetSkip(filter.Skip).SetLimit(filter.Limit)
+	cursor, err := r.DB.Find(ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	waves := []WaveModel{}
+	if err := cursor.All(ctx, &waves); err != nil {
+		return nil, err
+	}
+	return waves, nil
+}
+
+type fulfillmentOrderRepository struct {
+	Logger *zap.Logger
+	DB     *mongo.Collection
+}
+
+func NewFulfillmentOrderRepository(db *mongo.Collection, log *zap.Logger) FulfillmentOrderRepository {
+	return &fulfillmentOrderRepository{Logger: log, DB: db}
+}
+
+func (r *fulfillmentOrderRepository) InitRepository() error {
+	indexs := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "channel", Value: 1}, {Key: "shop_id", Value: 1}, {Key: "order_sn", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "wave_id", Value: 1}, {Key: "status", Value: 1}}},
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "status", Value: 1}, {Key: "updated_at", Value: -1}}},
+	}
+	if _, err := r.DB.Indexes().CreateMany(context.TODO(), indexs); err != nil {
+		return errors.New("FulfillmentOrderRepository.InitRepository: failed create indexs")
+	}
+	r.Logger.Info("FulfillmentOrderRepository.InitRepository: index created")
+	return nil
+}
+
+func (r *fulfillmentOrderRepository) CreateFulfillmentOrder(ctx context.Context, order *FulfillmentOrderModel) (*FulfillmentOrderModel, error) {
+	order.ID = bson.NewObjectID()
+	tenantID, err := pkg.TenantStamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	order.TenantID = tenantID
+	order.Version = 1
+	if _, err := r.DB.InsertOne(ctx, order); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, ErrDuplicateFulfillment
+		}
+		return nil, err
+	}
+	return order, nil
+}
+
+func (r *fulfillmentOrderRepository) UpdateFulfillmentOrder(ctx context.Context, order *FulfillmentOrderModel) (*FulfillmentOrderModel, error) {
+	version := order.Version
+	next := *order
+	next.Version = version + 1
+	res, err := r.DB.ReplaceOne(ctx, pkg.TenantFilter(ctx, bson.M{"_id": order.ID, "version": version}), &next)
+	if err != nil {
+		return nil, err
+	}
+	if res.MatchedCount == 0 {
+		return nil, ErrFulfillmentConflict
+	}
+	return &next, nil
+}
+
+func (r *fulfillmentOrderRepository) GetFulfillmentOrderByID(ctx context.Context, id string) (*FulfillmentOrderModel, error) {
+	oid, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrFulfillmentOrderNotFound
+	}
+	return r.findOne(ctx, bson.M{"_id": oid})
+}
+
+func (r *fulfillmentOrderRepository) GetFulfillmentOrderByOrderSN(ctx context.Context, channel dto.MarketplaceChannelEnum, shopID string, orderSN string) (*FulfillmentOrderModel, error) {
+	return r.findOne(ctx, bson.M{"channel": channelFilter(channel), "shop_id": shopID, "order_sn": orderSN})
+}
+
+// channelFilter : Shopee documents written before the channel field have none
+func channelFilter(channel dto.MarketplaceChannelEnum) any {
+	if channel == dto.CHANNEL_SHOPEE {
+		return bson.M{"$in": bson.A{channel, nil}}
+	}
+	return channel
+}
+
+func (r *fulfillmentOrderRepository) findOne(ctx context.Context, filter bson.M) (*FulfillmentOrderModel, error) {
+	var model FulfillmentOrderModel
+	if err := r.DB.FindOne(ctx, pkg.TenantFilter(ctx, filter)).Decode(&model); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrFulfillmentOrderNotFound
+		}
+		return nil, err
+	}
+	return &model, nil
+}
+
+func (r *fulfillmentOrderRepository) GetFulfillmentOrders(ctx context.Context, filter *FulfillmentOrderFilter) ([]FulfillmentOrderModel, error) {
+	query := pkg.TenantFilter(ctx, bson.M{})
+	if !filter.WaveID.IsZero() {
+		query["wave_id"] = filter.WaveID
+	}
+	if filter.Status != "" {
+		query["status"] = filter.Status
+	}
+	if filter.Channel != "" {
+		query["channel"] = channelFilter(filter.Channel)
+	}
+	if filter.ShopID != "" {
+		query["shop_id"] = filter.ShopID
+	}
+	if filter.OrderSN != "" {
+		query["order_sn"] = filter.OrderSN
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}).SetSkip(filter.Skip)
+	if filter.Limit > 0 {
+		opts.SetLimit(filter.Limit)
+	}
+	cursor, err := r.DB.Find(ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	orders := []FulfillmentOrderModel{}
+	if err := cursor.All(ctx, &orders); err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
@@ -0,0 +1,976 @@
+package fulfillment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.uber.org/zap"
+
+	"ecommerce/internal/adapter/dto"
+	"ecommerce/internal/application/inventory"
+	"ecommerce/internal/application/marketplace"
+	"ecommerce/internal/application/product"
+	"ecommerce/internal/application/shopee/logistics"
+	"ecommerce/internal/env"
+)
+
+const (
+	pageSize    = 50
+	maxPageSize = 200
+
+	defaultWaveSize = 100
+	maxWaveSize     = 500
+	// READY_TO_SHIP orders read per round while looking for ones not waved yet
+	candidatePageSize = 200
+)
+
+var (
+	ErrNoOrderToWave = errors.New("no READY_TO_SHIP order left to wave")
+	// the workflow does not allow this action in the current status
+	ErrFulfillmentStatus = errors.New("action not allowed in the fulfillment status")
+	ErrScanNotInOrder    = errors.New("scanned item is not in this order")
+	ErrScanOverQuantity  = errors.New("scanned quantity exceeds the ordered quantity")
+	ErrShipFailed        = errors.New("logistics ship call failed")
+)
+
+type IFulfillmentService interface {
+	// CANCELLED on the channel pulls the order out of its wave
+	marketplace.IMarketplaceOrderListener
+
+	// READY_TO_SHIP orders (oldest paid first) not in a wave yet, across shops and channels
+	CreateWave(ctx context.Context, actor string, req *IReqWave) (*WaveEntity, error)
+	GetWaves(ctx context.Context, query *IReqWaveQuery) ([]WaveEntity, error)
+	// with the order count per fulfillment status
+	GetWaveByID(ctx context.Context, id string) (*WaveEntity, error)
+	// what is still to pick in the wave, grouped by warehouse location
+	GetPickList(ctx context.Context, waveID string) (*PickListEntity, error)
+	// no order ids = every PICKING order of the wave
+	ConfirmPicked(ctx context.Context, waveID string, actor string, req *IReqPickConfirm) ([]FulfillmentOrderEntity, error)
+	// every PACKED order of the wave, one result per order
+	ShipWave(ctx context.Context, waveID string, actor string, req *IReqFulfillmentShip) ([]FulfillmentShipResultEntity, error)
+
+	GetFulfillmentOrders(ctx context.Context, query *IReqFulfillmentOrderQuery) ([]FulfillmentOrderEntity, error)
+	GetFulfillmentOrderByID(ctx context.Context, id string) (*FulfillmentOrderEntity, error)
+	// barcode (or sku) x quantity against the order lines : PACKED once every line is complete
+	ScanItem(ctx context.Context, id string, actor string, req *IReqPackScan) (*FulfillmentOrderEntity, error)
+	// scans cleared, lines read again from the order (picks up sku mappings added since)
+	ResetPacking(ctx context.Context, id string, actor string) (*FulfillmentOrderEntity, error)
+	// logistics ship call : SHIPPED once every package of the order is handed over
+	ShipFulfillmentOrder(ctx context.Context, id string, actor string, req *IReqFulfillmentShip) (*FulfillmentOrderEntity, error)
+	CancelFulfillmentOrder(ctx context.Context, id string, actor string) (*FulfillmentOrderEntity, error)
+}
+
+type WaveEntity struct {
+	ID         string                        `json:"id"`
+	Number     string                        `json:"number"`
+	Warehouse  string                        `json:"warehouse"`
+	ShopIDs    []string                      `json:"shop_ids"`
+	OrderCount int                           `json:"order_count"`
+	Note       string                        `json:"note"`
+	CreatedAt  time.Time                     `json:"created_at"`
+	CreatedBy  string                        `json:"created_by"`
+	Statuses   map[FulfillmentStatusEnum]int `json:"statuses,omitempty"`
+}
+
+type FulfillmentLineEntity struct {
+	ItemID            string   `json:"item_id"`
+	ModelID           string   `json:"model_id"`
+	ChannelSKU        string   `json:"channel_sku"`
+	SKU               string   `json:"sku"`
+	Name              string   `json:"name"`
+	Barcodes          []string `json:"barcodes"`
+	Quantity          int64    `json:"quantity"`
+	PackedQuantity    int64    `json:"packed_quantity"`
+	RemainingQuantity int64    `json:"remaining_quantity"`
+}
+
+type FulfillmentPackageEntity struct {
+	PackageNumber  string    `json:"package_number"`
+	TrackingNumber string    `json:"tracking_number"`
+	ShippedAt      time.Time `json:"shipped_at"`
+	ShippedBy      string    `json:"shipped_by"`
+}
+
+type FulfillmentOrderEntity struct {
+	ID         string                     `json:"id"`
+	WaveID     string                     `json:"wave_id"`
+	WaveNumber string                     `json:"wave_number"`
+	Channel    dto.MarketplaceChannelEnum `json:"channel"`
+	ShopID     string                     `json:"shop_id"`
+	OrderSN    string                     `json:"order_sn"`
+	Warehouse  string                     `json:"warehouse"`
+	Status     FulfillmentStatusEnum      `json:"status"`
+	Lines      []FulfillmentLineEntity    `json:"lines"`
+	Packages   []FulfillmentPackageEntity `json:"packages"`
+	LastError  string                     `json:"last_error,omitempty"`
+	Version    int64                      `json:"version"`
+	CreatedAt  time.Time                  `json:"created_at"`
+	CreatedBy  string                     `json:"created_by"`
+	UpdatedAt  time.Time                  `json:"updated_at"`
+	UpdatedBy  string                     `json:"updated_by"`
+	PickedAt   *time.Time                 `json:"picked_at"`
+	PickedBy   string                     `json:"picked_by"`
+	PackedAt   *time.Time                 `json:"packed_at"`
+	PackedBy   string                     `json:"packed_by"`
+	ShippedAt  *time.Time                 `json:"shipped_at"`
+	ShippedBy  string                     `json:"shipped_by"`
+}
+
+// same sku over several orders of the wave, summed
+type PickLineEntity struct {
+	SKU        string   `json:"sku"` // "" = listing not mapped, pick by channel sku / name
+	ChannelSKU string   `json:"channel_sku"`
+	Name       string   `json:"name"`
+	Quantity   int64    `json:"quantity"`
+	Orders     []string `json:"orders"` // order_sn
+}
+
+type PickLocationEntity struct {
+	Location string           `json:"location"` // "" = no location assigned
+	Lines    []PickLineEntity `json:"lines"`
+}
+
+type PickListEntity struct {
+	WaveID     string               `json:"wave_id"`
+	WaveNumber string               `json:"wave_number"`
+	Warehouse  string               `json:"warehouse"`
+	OrderCount int                  `json:"order_count"`
+	Locations  []PickLocationEntity `json:"locations"`
+}
+
+type FulfillmentShipResultEntity struct {
+	ID      string                     `json:"id"`
+	Channel dto.MarketplaceChannelEnum `json:"channel"`
+	ShopID  string                     `json:"shop_id"`
+	OrderSN string                     `json:"order_sn"`
+	OK      bool                       `json:"ok"`
+	Status  FulfillmentStatusEnum      `json:"status"`
+	Error   string                     `json:"error,omitempty"`
+}
+
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func WaveModelToEntity(model *WaveModel) *WaveEntity {
+	return &WaveEntity{
+		ID:         model.ID.Hex(),
+		Number:     model.Number,
+		Warehouse:  model.Warehouse,
+		ShopIDs:    model.ShopIDs,
+		OrderCount: model.OrderCount,
+		Note:       model.Note,
+		CreatedAt:  model.CreatedAt,
+		CreatedBy:  model.CreatedBy,
+	}
+}
+
+func FulfillmentOrderModelToEntity(model *FulfillmentOrderModel) *FulfillmentOrderEntity {
+	lines := make([]FulfillmentLineEntity, 0, len(model.Lines))
+	for _, l := range model.Lines {
+		lines = append(lines, FulfillmentLineEntity{
+			ItemID:            l.ItemID,
+			ModelID:           l.ModelID,
+			ChannelSKU:        l.ChannelSKU,
+			SKU:               l.SKU,
+			Name:              l.Name,
+			Barcodes:          l.Barcodes,
+			Quantity:          l.Quantity,
+			PackedQuantity:    l.PackedQuantity,
+			RemainingQuantity: l.Quantity - l.PackedQuantity,
+		})
+	}
+	packages := make([]FulfillmentPackageEntity, 0, len(model.Packages))
+	for _, p := range model.Packages {
+		packages = append(packages, FulfillmentPackageEntity(p))
+	}
+	return &FulfillmentOrderEntity{
+		ID:         model.ID.Hex(),
+		WaveID:     model.WaveID.Hex(),
+		WaveNumber: model.WaveNumber,
+		Channel:    orderChannel(model),
+		ShopID:     model.ShopID,
+		OrderSN:    model.OrderSN,
+		Warehouse:  model.Warehouse,
+		Status:     model.Status,
+		Lines:      lines,
+		Packages:   packages,
+		LastError:  model.LastError,
+		Version:    model.Version,
+		CreatedAt:  model.CreatedAt,
+		CreatedBy:  model.CreatedBy,
+		UpdatedAt:  model.UpdatedAt,
+		UpdatedBy:  model.UpdatedBy,
+		PickedAt:   optionalTime(model.PickedAt),
+		PickedBy:   model.PickedBy,
+		PackedAt:   optionalTime(model.PackedAt),
+		PackedBy:   model.PackedBy,
+		ShippedAt:  optionalTime(model.ShippedAt),
+		ShippedBy:  model.ShippedBy,
+	}
+}
+
+type fulfillmentService struct {
+	Config *env.Config
+	Logger *zap.Logger
+
+	InventoryService   inventory.IInventoryService
+	ProductService     product.IProductService
+	LogisticsService   logistics.IShopeeLogisticsService
+	MarketplaceService marketplace.IMarketplaceService
+
+	MarketplaceOrderRepository marketplace.MarketplaceOrderRepository
+	WaveRepository             WaveRepository
+	FulfillmentOrderRepository FulfillmentOrderRepository
+}
+
+func NewFulfillmentService(cfg *env.Config, logger *zap.Logger,
+	inventoryService inventory.IInventoryService,
+	productService product.IProductService,
+	logisticsService logistics.IShopeeLogisticsService,
+	marketplaceService marketplace.IMarketplaceService,
+	marketplaceOrder marketplace.MarketplaceOrderRepository,
+	wave WaveRepository,
+	order FulfillmentOrderRepository,
+) IFulfillmentService {
+	return &fulfillmentService{
+		Config:                     cfg,
+		Logger:                     logger,
+		InventoryService:           inventoryService,
+		ProductService:             productService,
+		LogisticsService:           logisticsService,
+		MarketplaceService:         marketplaceService,
+		MarketplaceOrderRepository: marketplaceOrder,
+		WaveRepository:             wave,
+		FulfillmentOrderRepository: order,
+	}
+}
+
+// orderChannel : fulfillment documents from before Lazada carry no channel
+func orderChannel(model *FulfillmentOrderModel) dto.MarketplaceChannelEnum {
+	if model.Channel == "" {
+		return dto.CHANNEL_SHOPEE
+	}
+	return model.Channel
+}
+
+func paging(page int, size int) (int64, int64) {
+	if page <= 0 {
+		page = 1
+	}
+	if size <= 0 {
+		size = pageSize
+	}
+	if size > maxPageSize {
+		size = maxPageSize
+	}
+	return int64((page - 1) * size), int64(size)
+}
+
+func (s *fulfillmentService) checkWarehouse(ctx context.Context, code string) error {
+	warehouses, err := s.InventoryService.GetWarehouses(ctx)
+	if err != nil {
+		return err
+	}
+	for _, w := range warehouses {
+		if w.Code == code && w.Active {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w : %s", inventory.ErrWarehouseNotFound, code)
+}
+
+// -- waves
+
+func (s *fulfillmentService) CreateWave(ctx context.Context, actor string, req *IReqWave) (*WaveEntity, error) {
+	warehouse := req.Warehouse
+	if warehouse == "" {
+		warehouse = s.Config.Inventory.InventoryDefaultWarehouse
+	}
+	if err := s.checkWarehouse(ctx, warehouse); err != nil {
+		return nil, err
+	}
+	size := req.MaxOrders
+	if size <= 0 {
+		size = defaultWaveSize
+	}
+	if size > maxWaveSize {
+		size = maxWaveSize
+	}
+	shopIDs := req.ShopIDs
+	if shopIDs == nil {
+		shopIDs = []string{}
+	}
+
+	now := time.Now()
+	wave := &WaveModel{
+		ID:        bson.NewObjectID(),
+		Warehouse: warehouse,
+		ShopIDs:   shopIDs,
+		Note:      req.Note,
+		CreatedAt: now,
+		CreatedBy: actor,
+	}
+	wave.Number = fmt.Sprintf("WAVE-%s-%s", now.Format("20060102"), strings.ToUpper(wave.ID.Hex()[18:]))
+
+	barcodes := map[string][]string{}
+	var skip int64
+	for wave.OrderCount < size {
+		orders, err := s.MarketplaceOrderRepository.GetMarketplaceOrders(ctx, &marketplace.MarketplaceOrderFilter{
+			ShopIDs: shopIDs,
+			Status:  dto.MP_ORDER_READY_TO_SHIP,
+			Skip:    skip,
+			Limit:   candidatePageSize,
+		})
+		if err != nil {
+			return nil, err
+		}
+		skip += int64(len(orders))
+		for i := range orders {
+			if wave.OrderCount >= size {
+				break
+			}
+			added, err := s.addToWave(ctx, wave, &orders[i], actor, barcodes)
+			if err != nil {
+				s.Logger.Error("usecase.CreateWave : addToWave", zap.String("channel", string(orders[i].Channel)),
+					zap.String("order_id", orders[i].OrderID), zap.Error(err))
+				continue
+			}
+			if added {
+				wave.OrderCount++
+			}
+		}
+		if len(orders) < candidatePageSize {
+			break
+		}
+	}
+	if wave.OrderCount == 0 {
+		return nil, ErrNoOrderToWave
+	}
+
+	// orders are in first : if this insert fails they stay PICKING under an unknown wave, cancel them to wave again
+	if _, err := s.WaveRepository.CreateWave(ctx, wave); err != nil {
+		s.Logger.Error("usecase.CreateWave : CreateWave", zap.String("wave", wave.Number), zap.Error(err))
+		return nil, err
+	}
+	res := WaveModelToEntity(wave)
+	res.Statuses = map[FulfillmentStatusEnum]int{FULFILLMENT_PICKING: wave.OrderCount}
+	return res, nil
+}
+
+// addToWave : false = skipped (fulfilled by the channel, already in a wave)
+func (s *fulfillmentService) addToWave(ctx context.Context, wave *WaveModel, order *dto.MarketplaceOrder, actor string, barcodes map[string][]string) (bool, error) {
+	if order.FulfilledByChannel {
+		return false, nil
+	}
+	// Shopee PROCESSED / RETRY_SHIP also read READY_TO_SHIP : shipment already arranged
+	if order.Channel == dto.CHANNEL_SHOPEE && order.ChannelStatus != string(dto.MP_ORDER_READY_TO_SHIP) {
+		return false, nil
+	}
+	existing, err := s.FulfillmentOrderRepository.GetFulfillmentOrderByOrderSN(ctx, order.Channel, order.ShopID, order.OrderID)
+	if err != nil && !errors.Is(err, ErrFulfillmentOrderNotFound) {
+		return false, err
+	}
+	if existing != nil && existing.Status != FULFILLMENT_CANCELLED {
+		return false, nil
+	}
+
+	lines, err := s.lines(ctx, order, barcodes)
+	if err != nil {
+		return false, err
+	}
+	now := time.Now()
+	model := &FulfillmentOrderModel{
+		WaveID:     wave.ID,
+		WaveNumber: wave.Number,
+		Channel:    order.Channel,
+		ShopID:     order.ShopID,
+		OrderSN:    order.OrderID,
+		Warehouse:  wave.Warehouse,
+		Status:     FULFILLMENT_PICKING,
+		Lines:      lines,
+		Packages:   []FulfillmentPackageModel{},
+		CreatedAt:  now,
+		CreatedBy:  actor,
+		UpdatedAt:  now,
+		UpdatedBy:  actor,
+	}
+	if existing == nil {
+		_, err = s.FulfillmentOrderRepository.CreateFulfillmentOrder(ctx, model)
+	} else {
+		model.ID, model.Version = existing.ID, existing.Version
+		_, err = s.FulfillmentOrderRepository.UpdateFulfillmentOrder(ctx, model)
+	}
+	// another wave took it meanwhile
+	if errors.Is(err, ErrDuplicateFulfillment) || errors.Is(err, ErrFulfillmentConflict) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// lines : order items resolved to master skus, barcodes cached per sku for the whole wave
+func (s *fulfillmentService) lines(ctx context.Context, order *dto.MarketplaceOrder, barcodes map[string][]string) ([]FulfillmentLineModel, error) {
+	resolved, err := s.ProductService.ResolveMarketplaceItems(ctx, order)
+	if err != nil {
+		return nil, err
+	}
+	lines := make([]FulfillmentLineModel, 0, len(resolved))
+	for _, r := range resolved {
+		if r.Quantity <= 0 {
+			continue
+		}
+		line := FulfillmentLineModel{
+			ItemID:     r.ItemID,
+			ModelID:    r.VariantID,
+			ChannelSKU: r.ChannelSKU,
+			Name:       r.Name,
+			Barcodes:   []string{},
+			Quantity:   r.Quantity,
+		}
+		if r.Resolved {
+			line.SKU = r.SKU
+			line.Barcodes = s.barcodes(ctx, r.SKU, barcodes)
+		}
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
+
+func (s *fulfillmentService) barcodes(ctx context.Context, sku string, cache map[string][]string) []string {
+	if b, ok := cache[sku]; ok {
+		return b
+	}
+	b := []string{}
+	if p, err := s.ProductService.GetProductBySKU(ctx, sku); err == nil {
+		for _, v := range p.Variants {
+			if v.SKU == sku {
+				b = append(b, v.Barcodes...)
+			}
+		}
+	}
+	cache[sku] = b
+	return b
+}
+
+func (s *fulfillmentService) GetWaves(ctx context.Context, query *IReqWaveQuery) ([]WaveEntity, error) {
+	skip, limit := paging(query.Page, query.Size)
+	models, err := s.WaveRepository.GetWaves(ctx, &WaveFilter{Warehouse: query.Warehouse, Skip: skip, Limit: limit})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]WaveEntity, 0, len(models))
+	for i := range models {
+		out = append(out, *WaveModelToEntity(&models[i]))
+	}
+	return out, nil
+}
+
+func (s *fulfillmentService) GetWaveByID(ctx context.Context, id string) (*WaveEntity, error) {
+	wave, err := s.WaveRepository.GetWaveByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	orders, err := s.FulfillmentOrderRepository.GetFulfillmentOrders(ctx, &FulfillmentOrderFilter{WaveID: wave.ID})
+	if err != nil {
+		return nil, err
+	}
+	res := WaveModelToEntity(wave)
+	res.Statuses = map[FulfillmentStatusEnum]int{}
+	for _, o := range orders {
+		res.Statuses[o.Status]++
+	}
+	return res, nil
+}
+
+func (s *fulfillmentService) GetPickList(ctx context.Context, waveID string) (*PickListEntity, error) {
+	wave, err := s.WaveRepository.GetWaveByID(ctx, waveID)
+	if err != nil {
+		return nil, err
+	}
+	orders, err := s.FulfillmentOrderRepository.GetFulfillmentOrders(ctx, &FulfillmentOrderFilter{WaveID: wave.ID, Status: FULFILLMENT_PICKING})
+	if err != nil {
+		return nil, err
+	}
+
+	skus := map[string]bool{}
+	for _, o := range orders {
+		for _, l := range o.Lines {
+			if l.SKU != "" {
+				skus[l.SKU] = true
+			}
+		}
+	}
+	locations := map[string]string{}
+	if len(skus) > 0 {
+		list := make([]string, 0, len(skus))
+		for sku := range skus {
+			list = append(list, sku)
+		}
+		balances, err := s.InventoryService.GetStock(ctx, &inventory.IReqStockQuery{SKU: strings.Join(list, ","), Warehouse: wave.Warehouse})
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range balances {
+			locations[b.SKU] = b.Location
+		}
+	}
+
+	// location -> line key -> line ; unmapped listings are keyed by item / model
+	grouped := map[string]map[string]*PickLineEntity{}
+	for _, o := range orders {
+		for _, l := range o.Lines {
+			location, key := locations[l.SKU], l.SKU
+			if l.SKU == "" {
+				location, key = "", l.ItemID+":"+l.ModelID
+			}
+			if grouped[location] == nil {
+				grouped[location] = map[string]*PickLineEntity{}
+			}
+			line := grouped[location][key]
+			if line == nil {
+				line = &PickLineEntity{SKU: l.SKU, ChannelSKU: l.ChannelSKU, Name: l.Name, Orders: []string{}}
+				grouped[location][key] = line
+			}
+			line.Quantity += l.Quantity
+			if n := len(line.Orders); n == 0 || line.Orders[n-1] != o.OrderSN {
+				line.Orders = append(line.Orders, o.OrderSN)
+			}
+		}
+	}
+
+	res := &PickListEntity{
+		WaveID:     wave.ID.Hex(),
+		WaveNumber: wave.Number,
+		Warehouse:  wave.Warehouse,
+		OrderCount: len(orders),
+		Locations:  make([]PickLocationEntity, 0, len(grouped)),
+	}
+	for location, lines := range grouped {
+		group := PickLocationEntity{Location: location, Lines: make([]PickLineEntity, 0, len(lines))}
+		for _, l := range lines {
+			group.Lines = append(group.Lines, *l)
+		}
+		sort.Slice(group.Lines, func(i, j int) bool {
+			if group.Lines[i].SKU != group.Lines[j].SKU {
+				return group.Lines[i].SKU < group.Lines[j].SKU
+			}
+			return group.Lines[i].Name < group.Lines[j].Name
+		})
+		res.Locations = append(res.Locations, group)
+	}
+	// walk order : by location, unassigned last
+	sort.Slice(res.Locations, func(i, j int) bool {
+		a, b := res.Locations[i].Location, res.Locations[j].Location
+		if a == "" || b == "" {
+			return b == ""
+		}
+		return a < b
+	})
+	return res, nil
+}
+
+func (s *fulfillmentService) ConfirmPicked(ctx context.Context, waveID string, actor string, req *IReqPickConfirm) ([]FulfillmentOrderEntity, error) {
+	wave, err := s.WaveRepository.GetWaveByID(ctx, waveID)
+	if err != nil {
+		return nil, err
+	}
+	orders, err := s.FulfillmentOrderRepository.GetFulfillmentOrders(ctx, &FulfillmentOrderFilter{WaveID: wave.ID, Status: FULFILLMENT_PICKING})
+	if err != nil {
+		return nil, err
+	}
+
+	picking := map[string]bool{}
+	for _, o := range orders {
+		picking[o.ID.Hex()] = true
+	}
+	wanted := map[string]bool{}
+	for _, id := range req.OrderIDs {
+		if !picking[id] {
+			return nil, fmt.Errorf("%w : %s is not PICKING in wave %s", ErrFulfillmentStatus, id, wave.Number)
+		}
+		wanted[id] = true
+	}
+
+	now := time.Now()
+	out := []FulfillmentOrderEntity{}
+	for i := range orders {
+		o := &orders[i]
+		if len(wanted) > 0 && !wanted[o.ID.Hex()] {
+			continue
+		}
+		o.Status, o.PickedAt, o.PickedBy = FULFILLMENT_PICKED, now, actor
+		o.UpdatedAt, o.UpdatedBy = now, actor
+		saved, err := s.FulfillmentOrderRepository.UpdateFulfillmentOrder(ctx, o)
+		if err != nil {
+			// cancelled meanwhile : left out of the result
+			s.Logger.Error("usecase.ConfirmPicked : UpdateFulfillmentOrder", zap.String("order_sn", o.OrderSN), zap.Error(err))
+			continue
+		}
+		out = append(out, *FulfillmentOrderModelToEntity(saved))
+	}
+	return out, nil
+}
+
+func (s *fulfillmentService) ShipWave(ctx context.Context, waveID string, actor string, req *IReqFulfillmentShip) ([]FulfillmentShipResultEntity, error) {
+	wave, err := s.WaveRepository.GetWaveByID(ctx, waveID)
+	if err != nil {
+		return nil, err
+	}
+	orders, err := s.FulfillmentOrderRepository.GetFulfillmentOrders(ctx, &FulfillmentOrderFilter{WaveID: wave.ID, Status: FULFILLMENT_PACKED})
+	if err != nil {
+		return nil, err
+	}
+
+	// package and tracking numbers come from each order
+	method := *req
+	method.PackageNumber, method.TrackingNumber = "", ""
+
+	out := make([]FulfillmentShipResultEntity, 0, len(orders))
+	for i := range orders {
+		o := &orders[i]
+		res := FulfillmentShipResultEntity{ID: o.ID.Hex(), Channel: orderChannel(o), ShopID: o.ShopID, OrderSN: o.OrderSN, Status: o.Status}
+		saved, err := s.ship(ctx, o, actor, &method)
+		if saved != nil {
+			res.Status = saved.Status
+		}
+		if err != nil {
+			res.Error = err.Error()
+		} else {
+			res.OK = true
+		}
+		out = append(out, res)
+	}
+	return out, nil
+}
+
+// -- orders
+
+func (s *fulfillmentService) GetFulfillmentOrders(ctx context.Context, query *IReqFulfillmentOrderQuery) ([]FulfillmentOrderEntity, error) {
+	skip, limit := paging(query.Page, query.Size)
+	filter := &FulfillmentOrderFilter{
+		Status:  query.Status,
+		Channel: dto.MarketplaceChannelEnum(strings.ToUpper(query.Channel)),
+		ShopID:  query.ShopID,
+		OrderSN: query.OrderSN,
+		Skip:    skip,
+		Limit:   limit,
+	}
+	if query.WaveID != "" {
+		oid, err := bson.ObjectIDFromHex(query.WaveID)
+		if err != nil {
+			return nil, ErrWaveNotFound
+		}
+		filter.WaveID = oid
+	}
+	models, err := s.FulfillmentOrderRepository.GetFulfillmentOrders(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]FulfillmentOrderEntity, 0, len(models))
+	for i := range models {
+		out = append(out, *FulfillmentOrderModelToEntity(&models[i]))
+	}
+	return out, nil
+}
+
+func (s *fulfillmentService) GetFulfillmentOrderByID(ctx context.Context, id string) (*FulfillmentOrderEntity, error) {
+	model, err := s.FulfillmentOrderRepository.GetFulfillmentOrderByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return FulfillmentOrderModelToEntity(model), nil
+}
+
+func (s *fulfillmentService) ScanItem(ctx context.Context, id string, actor string, req *IReqPackScan) (*FulfillmentOrderEntity, error) {
+	model, err := s.FulfillmentOrderRepository.GetFulfillmentOrderByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if model.Status != FULFILLMENT_PICKED {
+		return nil, fmt.Errorf("%w : %s", ErrFulfillmentStatus, model.Status)
+	}
+	qty := req.Quantity
+	if qty <= 0 {
+		qty = 1
+	}
+
+	matched := s.match(ctx, model, strings.TrimSpace(req.Code))
+	if len(matched) == 0 {
+		return nil, fmt.Errorf("%w : %s", ErrScanNotInOrder, req.Code)
+	}
+	var remaining int64
+	for _, i := range matched {
+		remaining += model.Lines[i].Quantity - model.Lines[i].PackedQuantity
+	}
+	if qty > remaining {
+		return nil, fmt.Errorf("%w : %s (%d left)", ErrScanOverQuantity, req.Code, remaining)
+	}
+	for _, i := range matched {
+		take := min(qty, model.Lines[i].Quantity-model.Lines[i].PackedQuantity)
+		model.Lines[i].PackedQuantity += take
+		qty -= take
+	}
+
+	now := time.Now()
+	model.UpdatedAt, model.UpdatedBy = now, actor
+	complete := true
+	for _, l := range model.Lines {
+		if l.PackedQuantity < l.Quantity {
+			complete = false
+			break
+		}
+	}
+	if complete {
+		model.Status, model.PackedAt, model.PackedBy = FULFILLMENT_PACKED, now, actor
+	}
+	saved, err := s.FulfillmentOrderRepository.UpdateFulfillmentOrder(ctx, model)
+	if err != nil {
+		return nil, err
+	}
+	return FulfillmentOrderModelToEntity(saved), nil
+}
+
+// match : lines the scanned code stands for ; the barcode snapshot first, then the catalog
+// (barcode added after the wave was built)
+func (s *fulfillmentService) match(ctx context.Context, model *FulfillmentOrderModel, code string) []int {
+	matched := []int{}
+	for i, l := range model.Lines {
+		if code == l.SKU || code == l.ChannelSKU || contains(l.Barcodes, code) {
+			matched = append(matched, i)
+		}
+	}
+	if len(matched) > 0 || code == "" {
+		return matched
+	}
+
+	p, err := s.ProductService.GetProductByBarcode(ctx, code)
+	if err != nil {
+		return matched
+	}
+	for _, v := range p.Variants {
+		if !contains(v.Barcodes, code) {
+			continue
+		}
+		for i, l := range model.Lines {
+			if l.SKU != "" && l.SKU == v.SKU {
+				matched = append(matched, i)
+			}
+		}
+	}
+	return matched
+}
+
+func contains(list []string, v string) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *fulfillmentService) ResetPacking(ctx context.Context, id string, actor string) (*FulfillmentOrderEntity, error) {
+	model, err := s.FulfillmentOrderRepository.GetFulfillmentOrderByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if model.Status != FULFILLMENT_PICKED && model.Status != FULFILLMENT_PACKED {
+		return nil, fmt.Errorf("%w : %s", ErrFulfillmentStatus, model.Status)
+	}
+	if len(model.Packages) > 0 {
+		return nil, fmt.Errorf("%w : already partly shipped", ErrFulfillmentStatus)
+	}
+
+	order, err := s.MarketplaceService.GetStoredMarketplaceOrder(ctx, orderChannel(model), model.ShopID, model.OrderSN)
+	if err != nil {
+		return nil, err
+	}
+	lines, err := s.lines(ctx, order, map[string][]string{})
+	if err != nil {
+		return nil, err
+	}
+	model.Lines = lines
+	model.Status, model.PackedAt, model.PackedBy = FULFILLMENT_PICKED, time.Time{}, ""
+	model.UpdatedAt, model.UpdatedBy = time.Now(), actor
+	saved, err := s.FulfillmentOrderRepository.UpdateFulfillmentOrder(ctx, model)
+	if err != nil {
+		return nil, err
+	}
+	return FulfillmentOrderModelToEntity(saved), nil
+}
+
+func (s *fulfillmentService) ShipFulfillmentOrder(ctx context.Context, id string, actor string, req *IReqFulfillmentShip) (*FulfillmentOrderEntity, error) {
+	model, err := s.FulfillmentOrderRepository.GetFulfillmentOrderByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	saved, err := s.ship(ctx, model, actor, req)
+	if err != nil {
+		return nil, err
+	}
+	return FulfillmentOrderModelToEntity(saved), nil
+}
+
+// ship : hands the packages over to logistics (the given one, or every package not shipped yet) ;
+// what was shipped is recorded even when a later package fails
+func (s *fulfillmentService) ship(ctx context.Context, model *FulfillmentOrderModel, actor string, req *IReqFulfillmentShip) (*FulfillmentOrderModel, error) {
+	if model.Status != FULFILLMENT_PACKED {
+		return nil, fmt.Errorf("%w : %s", ErrFulfillmentStatus, model.Status)
+	}
+	channel := orderChannel(model)
+	order, err := s.MarketplaceService.GetStoredMarketplaceOrder(ctx, channel, model.ShopID, model.OrderSN)
+	if err != nil {
+		return nil, err
+	}
+
+	shipped := map[string]bool{}
+	for _, p := range model.Packages {
+		shipped[p.PackageNumber] = true
+	}
+	packages := []string{req.PackageNumber}
+	switch {
+	case channel != dto.CHANNEL_SHOPEE:
+		// one call ships every pending line
+		packages = []string{""}
+	case req.PackageNumber == "":
+		packages = pendingPackages(order, shipped)
+	}
+
+	var shipErr error
+	complete := false
+	for _, pack := range packages {
+		number, tracking, err := s.shipPackage(ctx, model, channel, pack, req)
+		if err != nil {
+			shipErr = fmt.Errorf("%w : %v", ErrShipFailed, err)
+			break
+		}
+		model.Packages = append(model.Packages, FulfillmentPackageModel{
+			PackageNumber:  number,
+			TrackingNumber: tracking,
+			ShippedAt:      time.Now(),
+			ShippedBy:      actor,
+		})
+		shipped[number] = true
+		complete = channel != dto.CHANNEL_SHOPEE
+	}
+
+	now := time.Now()
+	model.LastError = ""
+	if shipErr != nil {
+		model.LastError = shipErr.Error()
+	}
+	if complete || (len(model.Packages) > 0 && len(pendingPackages(order, shipped)) == 0) {
+		model.Status, model.ShippedAt, model.ShippedBy = FULFILLMENT_SHIPPED, now, actor
+	}
+	model.UpdatedAt, model.UpdatedBy = now, actor
+	saved, err := s.FulfillmentOrderRepository.UpdateFulfillmentOrder(ctx, model)
+	if err != nil {
+		// shipment may already be arranged on the channel : the tracking shows up on the channel order anyway
+		s.Logger.Error("usecase.ship : UpdateFulfillmentOrder", zap.String("order_sn", model.OrderSN), zap.Error(err))
+		if shipErr == nil {
+			return nil, err
+		}
+		return nil, shipErr
+	}
+	return saved, shipErr
+}
+
+// shipPackage : Shopee through the logistics service (pickup / dropoff / non integrated),
+// other channels through their marketplace port ; package number and tracking number back
+func (s *fulfillmentService) shipPackage(ctx context.Context, model *FulfillmentOrderModel, channel dto.MarketplaceChannelEnum, pack string, req *IReqFulfillmentShip) (string, string, error) {
+	if channel == dto.CHANNEL_SHOPEE {
+		res, err := s.LogisticsService.ShipOrder(ctx, model.ShopID, model.OrderSN, &logistics.IReqShopeeShipOrder{
+			PackageNumber: pack,
+			Pickup:        req.Pickup,
+			Dropoff:       req.Dropoff,
+			NonIntegrated: req.NonIntegrated,
+		})
+		if err != nil {
+			return "", "", err
+		}
+		return res.PackageNumber, res.TrackingNumber, nil
+	}
+	res, err := s.MarketplaceService.ShipMarketplaceOrder(ctx, channel, model.ShopID, &dto.MarketplaceShipRequest{
+		OrderID:        model.OrderSN,
+		PackageID:      pack,
+		Carrier:        req.Carrier,
+		TrackingNumber: req.TrackingNumber,
+	})
+	if err != nil {
+		return "", "", err
+	}
+	return res.PackageID, res.TrackingNumber, nil
+}
+
+// pendingPackages : packages of the order not shipped yet ; an order without package list ships as one ("")
+func pendingPackages(order *dto.MarketplaceOrder, shipped map[string]bool) []string {
+	if len(order.Packages) == 0 {
+		if len(shipped) > 0 {
+			return []string{}
+		}
+		return []string{""}
+	}
+	pending := []string{}
+	for _, p := range order.Packages {
+		if !shipped[p.PackageID] {
+			pending = append(pending, p.PackageID)
+		}
+	}
+	return pending
+}
+
+func (s *fulfillmentService) CancelFulfillmentOrder(ctx context.Context, id string, actor string) (*FulfillmentOrderEntity, error) {
+	model, err := s.FulfillmentOrderRepository.GetFulfillmentOrderByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	saved, err := s.cancel(ctx, model, actor, "")
+	if err != nil {
+		return nil, err
+	}
+	return FulfillmentOrderModelToEntity(saved), nil
+}
+
+func (s *fulfillmentService) cancel(ctx context.Context, model *FulfillmentOrderModel, actor string, reason string) (*FulfillmentOrderModel, error) {
+	switch {
+	case model.Status == FULFILLMENT_SHIPPED || model.Status == FULFILLMENT_CANCELLED:
+		return nil, fmt.Errorf("%w : %s", ErrFulfillmentStatus, model.Status)
+	case len(model.Packages) > 0:
+		return nil, fmt.Errorf("%w : already partly shipped", ErrFulfillmentStatus)
+	}
+	model.Status, model.LastError = FULFILLMENT_CANCELLED, reason
+	model.UpdatedAt, model.UpdatedBy = time.Now(), actor
+	return s.FulfillmentOrderRepository.UpdateFulfillmentOrder(ctx, model)
+}
+
+func (s *fulfillmentService) OnMarketplaceOrderSaved(ctx context.Context, order *dto.MarketplaceOrder) {
+	if order.Status != dto.MP_ORDER_CANCELLED {
+		return
+	}
+	model, err := s.FulfillmentOrderRepository.GetFulfillmentOrderByOrderSN(ctx, order.Channel, order.ShopID, order.OrderID)
+	if err != nil {
+		if !errors.Is(err, ErrFulfillmentOrderNotFound) {
+			s.Logger.Error("fulfillment.OnMarketplaceOrderSaved", zap.String("channel", string(order.Channel)),
+				zap.String("order_id", order.OrderID), zap.Error(err))
+		}
+		return
+	}
+	if model.Status == FULFILLMENT_SHIPPED || model.Status == FULFILLMENT_CANCELLED {
+		return
+	}
+	if model.Status == FULFILLMENT_PACKED {
+		s.Logger.Warn("fulfillment.OnMarketplaceOrderSaved : packed order cancelled, unpack it", zap.String("channel", string(order.Channel)),
+			zap.String("order_id", order.OrderID), zap.String("wave", model.WaveNumber))
+	}
+	if _, err := s.cancel(ctx, model, "system", "order cancelled on the channel"); err != nil {
+		s.Logger.Error("fulfillment.OnMarketplaceOrderSaved : cancel", zap.String("order_id", order.OrderID), zap.Error(err))
+	}
+}
@@ -0,0 +1,203 @@
+package inventory
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.uber.org/zap"
+
+	"ecommerce/internal/adapter/dto"
+	"ecommerce/internal/application/product"
+	"ecommerce/internal/env"
+)
+
+// In-memory stand-ins for the inventory usecase tests ; unique indexes and the balance
+// compare-and-swap behave like the mongo repositories.
+
+type fakeWarehouseRepository struct {
+	WarehouseRepository
+	codes map[string]bool
+}
+
+func (r *fakeWarehouseRepository) GetWarehouseByCode(ctx context.Context, code string) (*WarehouseModel, error) {
+	if !r.codes[code] {
+		return nil, ErrWarehouseNotFound
+	}
+	return &WarehouseModel{Code: code, Active: true}, nil
+}
+
+type fakeProductService struct{ product.IProductService }
+
+func (fakeProductService) GetProductBySKU(ctx context.Context, sku string) (*product.ProductEntity, error) {
+	return &product.ProductEntity{Code: sku}, nil
+}
+
+// ResolveMarketplaceItems : the seller sku on the channel is the master sku, "" is unmapped
+func (fakeProductService) ResolveMarketplaceItems(ctx context.Context, order *dto.MarketplaceOrder) ([]product.ResolvedSKUEntity, error) {
+	out := []product.ResolvedSKUEntity{}
+	for _, l := range product.MarketplaceOrderLines(order) {
+		out = append(out, product.ResolvedSKUEntity{ChannelLineEntity: l, Resolved: l.ChannelSKU != "", SKU: l.ChannelSKU})
+	}
+	return out, nil
+}
+
+type fakeBalanceRepository struct {
+	BalanceRepository
+	mu       sync.Mutex
+	balances map[string]*BalanceModel // sku|warehouse ->
+}
+
+func (r *fakeBalanceRepository) GetOrCreateBalance(ctx context.Context, sku string, warehouse string) (*BalanceModel, error) {
+	r.mu.Lock()
+	b, ok := r.balances[sku+"|"+warehouse]
+	if !ok {
+		b = &BalanceModel{ID: bson.NewObjectID(), SKU: sku, Warehouse: warehouse, UpdatedAt: time.Now()}
+		r.balances[sku+"|"+warehouse] = b
+	}
+	c := *b
+	r.mu.Unlock()
+	// let concurrent writers read the same version
+	runtime.Gosched()
+	return &c, nil
+}
+
+func (r *fakeBalanceRepository) CompareAndSwapBalance(ctx context.Context, id bson.ObjectID, version int64, onHandDelta int64, reservedDelta int64) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, b := range r.balances {
+		if b.ID == id && b.Version == version {
+			b.OnHand += onHandDelta
+			b.Reserved += reservedDelta
+			b.Version++
+			b.UpdatedAt = time.Now()
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *fakeBalanceRepository) get(sku string, warehouse string) BalanceModel {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if b, ok := r.balances[sku+"|"+warehouse]; ok {
+		return *b
+	}
+	return BalanceModel{}
+}
+
+type fakeMovementRepository struct {
+	MovementRepository
+	mu        sync.Mutex
+	movements []MovementModel
+}
+
+func (r *fakeMovementRepository) InsertMovement(ctx context.Context, movement *MovementModel) (*MovementModel, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if movement.IdempotencyKey != "" {
+		for _, m := range r.movements {
+			if m.IdempotencyKey == movement.IdempotencyKey {
+				return nil, ErrDuplicateMovement
+			}
+		}
+	}
+	movement.ID = bson.NewObjectID()
+	r.movements = append(r.movements, *movement)
+	return movement, nil
+}
+
+func (r *fakeMovementRepository) GetMovementByIdempotencyKey(ctx context.Context, key string) (*MovementModel, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, m := range r.movements {
+		if m.IdempotencyKey == key {
+			c := m
+			return &c, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeMovementRepository) all() []MovementModel {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]MovementModel(nil), r.movements...)
+}
+
+type fakeReservationRepository struct {
+	ReservationRepository
+	mu           sync.Mutex
+	reservations []ReservationModel
+}
+
+func (r *fakeReservationRepository) InsertReservation(ctx context.Context, reservation *ReservationModel) (*ReservationModel, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, m := range r.reservations {
+		if m.RefID == reservation.RefID && m.SKU == reservation.SKU {
+			return nil, ErrDuplicateReservation
+		}
+	}
+	reservation.ID = bson.NewObjectID()
+	r.reservations = append(r.reservations, *reservation)
+	return reservation, nil
+}
+
+func (r *fakeReservationRepository) GetReservationsByRefID(ctx context.Context, refID string) ([]ReservationModel, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	res := []ReservationModel{}
+	for _, m := range r.reservations {
+		if m.RefID == refID {
+			res = append(res, m)
+		}
+	}
+	return res, nil
+}
+
+func (r *fakeReservationRepository) TransitionReservation(ctx context.Context, id bson.ObjectID, from ReservationStatusEnum, to ReservationStatusEnum) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range r.reservations {
+		if r.reservations[i].ID == id && r.reservations[i].Status == from {
+			r.reservations[i].Status = to
+			r.reservations[i].UpdatedAt = time.Now()
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+type testInventory struct {
+	Service      *inventoryService
+	Balances     *fakeBalanceRepository
+	Movements    *fakeMovementRepository
+	Reservations *fakeReservationRepository
+}
+
+// newTestInventory : warehouses MAIN (default) and SPARE ; retries is the balance CAS budget
+func newTestInventory(t *testing.T, retries int) *testInventory {
+	t.Helper()
+	ti := &testInventory{
+		Balances:     &fakeBalanceRepository{balances: map[string]*BalanceModel{}},
+		Movements:    &fakeMovementRepository{},
+		Reservations: &fakeReservationRepository{},
+	}
+	cfg := &env.Config{Inventory: &env.InventoryConfig{InventoryDefaultWarehouse: "MAIN", InventoryMaxRetries: retries}}
+	ti.Service = NewInventoryService(cfg, zap.NewNop(), fakeProductService{},
+		&fakeWarehouseRepository{codes: map[string]bool{"MAIN": true, "SPARE": true}},
+		ti.Balances, ti.Movements, ti.Reservations,
+	).(*inventoryService)
+	return ti
+}
+
+func (ti *testInventory) receive(t *testing.T, sku string, warehouse string, qty int64) {
+	t.Helper()
+	if _, err := ti.Service.PostReceipt(context.Background(), "test", &IReqStockMovement{SKU: sku, Warehouse: warehouse, Quantity: qty}); err != nil {
+		t.Fatalf("receipt: %v", err)
+	}
+}
@@ -0,0 +1,271 @@
+package inventory
+
+import (
+	"errors"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	"ecommerce/internal/adapter"
+	"ecommerce/internal/application/product"
+	"ecommerce/internal/delivery/http/response"
+)
+
+type IReqWarehouse struct {
+	Code    string `json:"code" validate:"required,max=32,alphanumunicode"`
+	Name    string `json:"name" validate:"required,max=200"`
+	Address string `json:"address"`
+}
+
+type IReqStockQuery struct {
+	SKU       string `query:"sku"` // comma separated
+	Warehouse string `query:"warehouse"`
+}
+
+type IReqMovementQuery struct {
+	SKU       string           `query:"sku"`
+	Warehouse string           `query:"warehouse"`
+	Type      MovementTypeEnum `query:"type"`
+	RefType   string           `query:"ref_type"`
+	RefID     string           `query:"ref_id"`
+	From      string           `query:"from" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	To        string           `query:"to" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	Page      int              `query:"page"`
+	Size      int              `query:"size"`
+}
+
+// location "" = clear
+type IReqStockLocation struct {
+	Warehouse string `json:"warehouse" validate:"required"`
+	Location  string `json:"location" validate:"max=32"`
+}
+
+// receipt / return : quantity goes in
+type IReqStockMovement struct {
+	SKU            string `json:"sku" validate:"required"`
+	Warehouse      string `json:"warehouse" validate:"required"`
+	Quantity       int64  `json:"quantity" validate:"gt=0"`
+	RefType        string `json:"ref_type"`
+	RefID          string `json:"ref_id"`
+	Note           string `json:"note"`
+	IdempotencyKey string `json:"idempotency_key"`
+}
+
+type IReqStockAdjustment struct {
+	SKU            string `json:"sku" validate:"required"`
+	Warehouse      string `json:"warehouse" validate:"required"`
+	Quantity       int64  `json:"quantity" validate:"required"` // signed, not 0
+	Reason         string `json:"reason" validate:"required,max=500"`
+	IdempotencyKey string `json:"idempotency_key"`
+}
+
+type IReqStockTransfer struct {
+	SKU      string `json:"sku" validate:"required"`
+	From     string `json:"from" validate:"required"`
+	To       string `json:"to" validate:"required"`
+	Quantity int64  `json:"quantity" validate:"gt=0"`
+	Note     string `json:"note"`
+}
+
+type IInventoryHandler interface {
+	GetWarehouses(c *fiber.Ctx) error
+	PostWarehouse(c *fiber.Ctx) error
+	GetStock(c *fiber.Ctx) error
+	GetStockBySKU(c *fiber.Ctx) error
+	PutStockLocation(c *fiber.Ctx) error
+	GetMovements(c *fiber.Ctx) error
+	PostReceipt(c *fiber.Ctx) error
+	PostReturn(c *fiber.Ctx) error
+	PostAdjustment(c *fiber.Ctx) error
+	PostTransfer(c *fiber.Ctx) error
+	GetOrderReservations(c *fiber.Ctx) error
+}
+
+type inventoryHandler struct {
+	Logger   *zap.Logger
+	Validate *validator.Validate
+	Service  IInventoryService
+}
+
+func NewInventoryHandler(log *zap.Logger, valid *validator.Validate, srv IInventoryService) IInventoryHandler {
+	return &inventoryHandler{
+		Logger:   log,
+		Validate: valid,
+		Service:  srv,
+	}
+}
+
+// actor : username set by the auth middleware
+func actor(c *fiber.Ctx) string {
+	if username, ok := c.Locals("username").(string); ok {
+		return username
+	}
+	return ""
+}
+
+func inventoryErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrWarehouseNotFound), errors.Is(err, product.ErrSKUNotFound):
+		return fiber.StatusNotFound
+	case errors.Is(err, ErrInsufficientStock), errors.Is(err, ErrBalanceConflict),
+		errors.Is(err, ErrDuplicateWarehouse), errors.Is(err, ErrDuplicateMovement):
+		return fiber.StatusConflict
+	}
+	return fiber.StatusBadRequest
+}
+
+func (d *inventoryHandler) GetWarehouses(c *fiber.Ctx) error {
+	res, err := d.Service.GetWarehouses(c.Context())
+	if err != nil {
+		return response.ErrorResponse(c, fiber.StatusInternalServerError, "handler.GetWarehouses", err)
+	}
+	return response.SuccessResponse(c, "handler.GetWarehouses", res)
+}
+
+func (d *inventoryHandler) PostWarehouse(c *fiber.Ctx) error {
+	var reqBody IReqWarehouse
+	if err := c.BodyParser(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostWarehouse", "invalid body")
+	}
+	if err := d.Validate.Struct(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostWarehouse", err)
+	}
+
+	res, err := d.Service.CreateWarehouse(c.Context(), actor(c), &reqBody)
+	if err != nil {
+		return response.ErrorResponse(c, inventoryErrorStatus(err), "handler.PostWarehouse", err)
+	}
+	return response.SuccessResponse(c, "handler.PostWarehouse", res)
+}
+
+func (d *inventoryHandler) GetStock(c *fiber.Ctx) error {
+	var query IReqStockQuery
+	if err := c.QueryParser(&query); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.GetStock", "invalid query")
+	}
+
+	res, err := d.Service.GetStock(c.Context(), &query)
+	if err != nil {
+		return response.ErrorResponse(c, fiber.StatusInternalServerError, "handler.GetStock", err)
+	}
+	return response.SuccessResponse(c, "handler.GetStock", res)
+}
+
+func (d *inventoryHandler) GetStockBySKU(c *fiber.Ctx) error {
+	res, err := d.Service.GetStockBySKU(c.Context(), c.Params("sku"))
+	if err != nil {
+		return response.ErrorResponse(c, fiber.StatusInternalServerError, "handler.GetStockBySKU", err)
+	}
+	return response.SuccessResponse(c, "handler.GetStockBySKU", res)
+}
+
+func (d *inventoryHandler) PutStockLocation(c *fiber.Ctx) error {
+	var reqBody IReqStockLocation
+	if err := c.BodyParser(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PutStockLocation", "invalid body")
+	}
+	if err := d.Validate.Struct(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PutStockLocation", err)
+	}
+
+	res, err := d.Service.SetStockLocation(c.Context(), c.Params("sku"), &reqBody)
+	if err != nil {
+		return response.ErrorResponse(c, inventoryErrorStatus(err), "handler.PutStockLocation", err)
+	}
+	return response.SuccessResponse(c, "handler.PutStockLocation", res)
+}
+
+func (d *inventoryHandler) GetMovements(c *fiber.Ctx) error {
+	var query IReqMovementQuery
+	if err := c.QueryParser(&query); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.GetMovements", "invalid query")
+	}
+	if err := d.Validate.Struct(&query); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.GetMovements", "from and to are RFC3339")
+	}
+
+	res, err := d.Service.GetMovements(c.Context(), &query)
+	if err != nil {
+		return response.ErrorResponse(c, fiber.StatusInternalServerError, "handler.GetMovements", err)
+	}
+	return response.SuccessResponse(c, "handler.GetMovements", res)
+}
+
+func (d *inventoryHandler) PostReceipt(c *fiber.Ctx) error {
+	var reqBody IReqStockMovement
+	if err := c.BodyParser(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostReceipt", "invalid body")
+	}
+	if err := d.Validate.Struct(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostReceipt", err)
+	}
+
+	res, err := d.Service.PostReceipt(c.Context(), actor(c), &reqBody)
+	if err != nil {
+		return response.ErrorResponse(c, inventoryErrorStatus(err), "handler.PostReceipt", err)
+	}
+	return response.SuccessResponse(c, "handler.PostReceipt", res)
+}
+
+func (d *inventoryHandler) PostReturn(c *fiber.Ctx) error {
+	var reqBody IReqStockMovement
+	if err := c.BodyParser(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostReturn", "invalid body")
+	}
+	if err := d.Validate.Struct(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostReturn", err)
+	}
+
+	res, err := d.Service.PostReturn(c.Context(), actor(c), &reqBody)
+	if err != nil {
+		return response.ErrorResponse(c, inventoryErrorStatus(err), "handler.PostReturn", err)
+	}
+	return response.SuccessResponse(c, "handler.PostReturn", res)
+}
+
+func (d *inventoryHandler) PostAdjustment(c *fiber.Ctx) error {
+	var reqBody IReqStockAdjustment
+	if err := c.BodyParser(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostAdjustment", "invalid body")
+	}
+	if err := d.Validate.Struct(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostAdjustment", err)
+	}
+
+	res, err := d.Service.PostAdjustment(c.Context(), actor(c), &reqBody)
+	if err != nil {
+		return response.ErrorResponse(c, inventoryErrorStatus(err), "handler.PostAdjustment", err)
+	}
+	return response.SuccessResponse(c, "handler.PostAdjustment", res)
+}
+
+func (d *inventoryHandler) PostTransfer(c *fiber.Ctx) error {
+	var reqBody IReqStockTransfer
+	if err := c.BodyParser(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostTransfer", "invalid body")
+	}
+	if err := d.Validate.Struct(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostTransfer", err)
+	}
+
+	res, err := d.Service.PostTransfer(c.Context(), actor(c), &reqBody)
+	if err != nil {
+		return response.ErrorResponse(c, inventoryErrorStatus(err), "handler.PostTransfer", err)
+	}
+	return response.SuccessResponse(c, "handler.PostTransfer", res)
+}
+
+func (d *inventoryHandler) GetOrderReservations(c *fiber.Ctx) error {
+	channel, ok := adapter.ParseMarketplaceChannel(c.Params("channel"))
+	if !ok {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.GetOrderReservations", adapter.ErrMarketplaceUnsupported)
+	}
+	ref := OrderRef{Channel: channel, ShopID: c.Params("shopID"), OrderID: c.Params("orderID")}
+
+	res, err := d.Service.GetOrderReservations(c.Context(), ref)
+	if err != nil {
+		return response.ErrorResponse(c, fiber.StatusInternalServerError, "handler.GetOrderReservations", err)
+	}
+	return response.SuccessResponse(c, "handler.GetOrderReservations", res)
+}
@@ -0,0 +1,76 @@
+package inventory
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"ecommerce/internal/adapter/dto"
+	"ecommerce/internal/application/marketplace"
+	"ecommerce/internal/application/product"
+)
+
+// marketplaceOrderListener : stored channel order -> reservation lifecycle ; registered on the
+// marketplace service in the container, so Shopee (order sync, push) and Lazada (order sync) feed it
+type marketplaceOrderListener struct {
+	Logger *zap.Logger
+
+	InventoryService IInventoryService
+	ProductService   product.IProductService
+}
+
+func NewMarketplaceOrderListener(logger *zap.Logger, inventory IInventoryService, productService product.IProductService) marketplace.IMarketplaceOrderListener {
+	return &marketplaceOrderListener{
+		Logger:           logger,
+		InventoryService: inventory,
+		ProductService:   productService,
+	}
+}
+
+func (l *marketplaceOrderListener) OnMarketplaceOrderSaved(ctx context.Context, order *dto.MarketplaceOrder) {
+	// stocked by the channel : none of our stock moves
+	if order.FulfilledByChannel {
+		return
+	}
+	ref := OrderRef{Channel: order.Channel, ShopID: order.ShopID, OrderID: order.OrderID}
+
+	var err error
+	switch order.Status {
+	case dto.MP_ORDER_READY_TO_SHIP:
+		var lines []OrderLine
+		if lines, err = l.lines(ctx, order); err == nil {
+			err = l.InventoryService.ReserveOrder(ctx, ref, lines)
+		}
+	case dto.MP_ORDER_SHIPPED, dto.MP_ORDER_DELIVERED, dto.MP_ORDER_COMPLETED:
+		var lines []OrderLine
+		if lines, err = l.lines(ctx, order); err == nil {
+			err = l.InventoryService.ConsumeOrder(ctx, ref, lines)
+		}
+	case dto.MP_ORDER_CANCELLED:
+		err = l.InventoryService.ReleaseOrder(ctx, ref)
+	default:
+		return
+	}
+	if err != nil {
+		l.Logger.Error("inventory.OnMarketplaceOrderSaved", zap.String("channel", string(order.Channel)),
+			zap.String("order_id", order.OrderID), zap.String("status", string(order.Status)), zap.Error(err))
+	}
+}
+
+// lines : order items resolved to master skus ; unknown listings hold no stock
+func (l *marketplaceOrderListener) lines(ctx context.Context, order *dto.MarketplaceOrder) ([]OrderLine, error) {
+	resolved, err := l.ProductService.ResolveMarketplaceItems(ctx, order)
+	if err != nil {
+		return nil, err
+	}
+	lines := make([]OrderLine, 0, len(resolved))
+	for _, r := range resolved {
+		if !r.Resolved {
+			l.Logger.Warn("inventory.OnMarketplaceOrderSaved : unmapped listing", zap.String("channel", string(order.Channel)),
+				zap.String("order_id", order.OrderID), zap.String("item_id", r.ItemID), zap.String("variant_id", r.VariantID))
+			continue
+		}
+		lines = append(lines, OrderLine{SKU: r.SKU, Quantity: r.Quantity})
+	}
+	return lines, nil
+}
@@ -0,0 +1,86 @@
+package inventory
+
+import (
+	"context"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"ecommerce/internal/adapter/dto"
+)
+
+func lazadaOrder(status dto.MarketplaceOrderStatusEnum) *dto.MarketplaceOrder {
+	// Lazada : one row per unit
+	return &dto.MarketplaceOrder{
+		Channel: dto.CHANNEL_LAZADA,
+		ShopID:  "TH1",
+		OrderID: "900",
+		Status:  status,
+		Items: []dto.MarketplaceOrderItem{
+			{LineID: "1", ItemID: "10", VariantID: "11", SKU: "SKU-1", Quantity: 1},
+			{LineID: "2", ItemID: "10", VariantID: "11", SKU: "SKU-1", Quantity: 1},
+			{LineID: "3", ItemID: "20", VariantID: "21", SKU: "SKU-2", Quantity: 1},
+			{LineID: "4", ItemID: "30", VariantID: "31", Quantity: 1}, // not ours
+		},
+	}
+}
+
+func TestMarketplaceOrderListenerLazada(t *testing.T) {
+	ti := newTestInventory(t, 1000)
+	ctx := context.Background()
+	ti.receive(t, "SKU-1", "MAIN", 10)
+	ti.receive(t, "SKU-2", "MAIN", 10)
+	l := NewMarketplaceOrderListener(zap.NewNop(), ti.Service, fakeProductService{})
+
+	l.OnMarketplaceOrderSaved(ctx, lazadaOrder(dto.MP_ORDER_UNPAID))
+	if bal := ti.Balances.get("SKU-1", "MAIN"); bal.Reserved != 0 {
+		t.Fatalf("unpaid: reserved %d, want 0", bal.Reserved)
+	}
+
+	// synced twice : reserved once
+	l.OnMarketplaceOrderSaved(ctx, lazadaOrder(dto.MP_ORDER_READY_TO_SHIP))
+	l.OnMarketplaceOrderSaved(ctx, lazadaOrder(dto.MP_ORDER_READY_TO_SHIP))
+	if bal := ti.Balances.get("SKU-1", "MAIN"); bal.OnHand != 10 || bal.Reserved != 2 {
+		t.Errorf("SKU-1 %d/%d, want 10/2", bal.OnHand, bal.Reserved)
+	}
+	if bal := ti.Balances.get("SKU-2", "MAIN"); bal.Reserved != 1 {
+		t.Errorf("SKU-2 reserved %d, want 1", bal.Reserved)
+	}
+	reservations, _ := ti.Service.GetOrderReservations(ctx, OrderRef{Channel: dto.CHANNEL_LAZADA, ShopID: "TH1", OrderID: "900"})
+	if len(reservations) != 2 {
+		t.Fatalf("%d reservations, want 2", len(reservations))
+	}
+
+	l.OnMarketplaceOrderSaved(ctx, lazadaOrder(dto.MP_ORDER_SHIPPED))
+	l.OnMarketplaceOrderSaved(ctx, lazadaOrder(dto.MP_ORDER_DELIVERED))
+	if bal := ti.Balances.get("SKU-1", "MAIN"); bal.OnHand != 8 || bal.Reserved != 0 {
+		t.Errorf("SKU-1 after ship %d/%d, want 8/0", bal.OnHand, bal.Reserved)
+	}
+	if bal := ti.Balances.get("SKU-2", "MAIN"); bal.OnHand != 9 || bal.Reserved != 0 {
+		t.Errorf("SKU-2 after ship %d/%d, want 9/0", bal.OnHand, bal.Reserved)
+	}
+	checkBalance(t, ti, "SKU-1", "MAIN")
+	checkBalance(t, ti, "SKU-2", "MAIN")
+}
+
+func TestMarketplaceOrderListenerCancelAndChannelStock(t *testing.T) {
+	ti := newTestInventory(t, 1000)
+	ctx := context.Background()
+	ti.receive(t, "SKU-1", "MAIN", 10)
+	l := NewMarketplaceOrderListener(zap.NewNop(), ti.Service, fakeProductService{})
+
+	l.OnMarketplaceOrderSaved(ctx, lazadaOrder(dto.MP_ORDER_READY_TO_SHIP))
+	l.OnMarketplaceOrderSaved(ctx, lazadaOrder(dto.MP_ORDER_CANCELLED))
+	if bal := ti.Balances.get("SKU-1", "MAIN"); bal.OnHand != 10 || bal.Reserved != 0 {
+		t.Errorf("after cancel %d/%d, want 10/0", bal.OnHand, bal.Reserved)
+	}
+
+	// stocked by the channel : nothing moves
+	fbs := lazadaOrder(dto.MP_ORDER_READY_TO_SHIP)
+	fbs.Channel, fbs.OrderID, fbs.FulfilledByChannel = dto.CHANNEL_SHOPEE, "FBS1", true
+	l.OnMarketplaceOrderSaved(ctx, fbs)
+	if bal := ti.Balances.get("SKU-1", "MAIN"); bal.Reserved != 0 {
+		t.Errorf("fulfilled by channel: reserved %d, want 0", bal.Reserved)
+	}
+	checkBalance(t, ti, "SKU-1", "MAIN")
+}
@@ -0,0 +1,513 @@
+package inventory
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"go.uber.org/zap"
+
+	"ecommerce/internal/pkg"
+)
+
+type MovementTypeEnum string
+
+const (
+	MOVEMENT_RECEIPT      MovementTypeEnum = "RECEIPT"
+	MOVEMENT_SALE         MovementTypeEnum = "SALE"
+	MOVEMENT_ADJUSTMENT   MovementTypeEnum = "ADJUSTMENT"
+	MOVEMENT_TRANSFER_OUT MovementTypeEnum = "TRANSFER_OUT"
+	MOVEMENT_TRANSFER_IN  MovementTypeEnum = "TRANSFER_IN"
+	MOVEMENT_RETURN       MovementTypeEnum = "RETURN"
+	// reserved only, on hand untouched
+	MOVEMENT_RESERVE MovementTypeEnum = "RESERVE"
+	MOVEMENT_RELEASE MovementTypeEnum = "RELEASE"
+)
+
+type ReservationStatusEnum string
+
+const (
+	RESERVATION_RESERVED ReservationStatusEnum = "RESERVED"
+	RESERVATION_CONSUMED ReservationStatusEnum = "CONSUMED"
+	RESERVATION_RELEASED ReservationStatusEnum = "RELEASED"
+)
+
+var (
+	ErrWarehouseNotFound    = errors.New("warehouse not found")
+	ErrDuplicateWarehouse   = errors.New("warehouse code already exists")
+	ErrDuplicateMovement    = errors.New("movement already posted")
+	ErrDuplicateReservation = errors.New("reservation already exists")
+)
+
+// every model of this package carries the owner tenant, missing for the platform tenant : see pkg.TenantFilter
+type WarehouseModel struct {
+	ID        bson.ObjectID `bson:"_id"`
+	TenantID  string        `bson:"tenant_id,omitempty"`
+	Code      string        `bson:"code"`
+	Name      string        `bson:"name"`
+	Address   string        `bson:"address"`
+	Active    bool          `bson:"active"`
+	CreatedAt time.Time     `bson:"created_at"`
+	CreatedBy string        `bson:"created_by"`
+}
+
+// derived from the ledger : one document per (sku, warehouse), version bumps on every change
+type BalanceModel struct {
+	ID        bson.ObjectID `bson:"_id"`
+	TenantID  string        `bson:"tenant_id,omitempty"`
+	SKU       string        `bson:"sku"`
+	Warehouse string        `bson:"warehouse"`
+	OnHand    int64         `bson:"on_hand"`
+	Reserved  int64         `bson:"reserved"`
+	Version   int64         `bson:"version"`
+	UpdatedAt time.Time     `bson:"updated_at"`
+	// bin / shelf the sku is picked from in this warehouse, "" = not assigned
+	Location string `bson:"location"`
+}
+
+// append-only : never updated nor deleted, corrections are new movements
+type MovementModel struct {
+	ID             bson.ObjectID    `bson:"_id"`
+	TenantID       string           `bson:"tenant_id,omitempty"`
+	Type           MovementTypeEnum `bson:"type"`
+	SKU            string           `bson:"sku"`
+	Warehouse      string           `bson:"warehouse"`
+	OnHandDelta    int64            `bson:"on_hand_delta"`
+	ReservedDelta  int64            `bson:"reserved_delta"`
+	OnHandAfter    int64            `bson:"on_hand_after"`
+	ReservedAfter  int64            `bson:"reserved_after"`
+	BalanceVersion int64            `bson:"balance_version"` // version of the balance this movement produced
+	RefType        string           `bson:"ref_type"`        // ORDER, PURCHASE_ORDER, TRANSFER, MANUAL, ...
+	RefID          string           `bson:"ref_id"`
+	// unique when set : the same business event never moves stock twice
+	IdempotencyKey string    `bson:"idempotency_key,omitempty"`
+	Note           string    `bson:"note"`
+	CreatedAt      time.Time `bson:"created_at"`
+	CreatedBy      string    `bson:"created_by"`
+}
+
+// stock held for one order line until it ships or is cancelled
+type ReservationModel struct {
+	ID        bson.ObjectID         `bson:"_id"`
+	TenantID  string                `bson:"tenant_id,omitempty"`
+	RefID     string                `bson:"ref_id"` // channel:shop_id:order_id
+	SKU       string                `bson:"sku"`
+	Warehouse string                `bson:"warehouse"`
+	Quantity  int64                 `bson:"quantity"`
+	Status    ReservationStatusEnum `bson:"status"`
+	CreatedAt time.Time             `bson:"created_at"`
+	UpdatedAt time.Time             `bson:"updated_at"`
+}
+
+type BalanceFilter struct {
+	SKUs      []string
+	Warehouse string
+}
+
+type MovementFilter struct {
+	SKU       string
+	Warehouse string
+	Type      MovementTypeEnum
+	RefType   string
+	RefID     string
+	From      time.Time
+	To        time.Time
+	Skip      int64
+	Limit     int64
+}
+
+type WarehouseRepository interface {
+	InitRepository() error
+	CreateWarehouse(ctx context.Context, warehouse *WarehouseModel) (*WarehouseModel, error)
+	// default warehouse of the tenant of ctx : created when missing, untouched otherwise
+	EnsureWarehouse(ctx context.Context, code string, name string) error
+	GetWarehouseByCode(ctx context.Context, code string) (*WarehouseModel, error)
+	GetWarehouses(ctx context.Context) ([]WarehouseModel, error)
+}
+
+type BalanceRepository interface {
+	InitRepository() error
+	// zero balance (version 0) is created on first use
+	GetOrCreateBalance(ctx context.Context, sku string, warehouse string) (*BalanceModel, error)
+	// applied only if the balance is still at version : false = someone else won, read again
+	CompareAndSwapBalance(ctx context.Context, id bson.ObjectID, version int64, onHandDelta int64, reservedDelta int64) (bool, error)
+	GetBalances(ctx context.Context, filter *BalanceFilter) ([]BalanceModel, error)
+	// location only : quantities and version are left alone
+	SetBalanceLocation(ctx context.Context, sku string, warehouse string, location string) (*BalanceModel, error)
+}
+
+type MovementRepository interface {
+	InitRepository() error
+	InsertMovement(ctx context.Context, movement *MovementModel) (*MovementModel, error)
+	GetMovementByIdempotencyKey(ctx context.Context, key string) (*MovementModel, error)
+	GetMovements(ctx context.Context, filter *MovementFilter) ([]MovementModel, error)
+}
+
+type ReservationRepository interface {
+	InitRepository() error
+	InsertReservation(ctx context.Context, reservation *ReservationModel) (*ReservationModel, error)
+	GetReservationsByRefID(ctx context.Context, refID string) ([]ReservationModel, error)
+	// from -> to only : false when the reservation already left `from`
+	TransitionReservation(ctx context.Context, id bson.ObjectID, from ReservationStatusEnum, to ReservationStatusEnum) (bool, error)
+}
+
+type warehouseRepository struct {
+	Logger *zap.Logger
+	DB     *mongo.Collection
+}
+
+func NewWarehouseRepository(db *mongo.Collection, log *zap.Logger) WarehouseRepository {
+	return &warehouseRepository{Logger: log, DB: db}
+}
+
+func (r *warehouseRepository) InitRepository() error {
+	indexs := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "code", Value: 1}}, Options: options.Index().SetUnique(true)},
+	}
+	if _, err := r.DB.Indexes().CreateMany(context.TODO(), indexs); err != nil {
+		return errors.New("WarehouseRepository.InitRepository: failed create indexs")
+	}
+	r.Logger.Info("WarehouseRepository.InitRepository: index created")
+	return nil
+}
+
+func (r *warehouseRepository) CreateWarehouse(ctx context.Context, warehouse *WarehouseModel) (*WarehouseModel, error) {
+	warehouse.ID = bson.NewObjectID()
+	tenantID, err := pkg.TenantStamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	warehouse.TenantID = tenantID
+	if _, err := r.DB.InsertOne(ctx, warehouse); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, ErrDuplicateWarehouse
+		}
+		return nil, err
+	}
+	return warehouse, nil
+}
+
+func (r *warehouseRepository) EnsureWarehouse(ctx context.Context, code string, name string) error {
+	onInsert := bson.M{
+		"_id":        bson.NewObjectID(),
+		"name":       name,
+		"address":    "",
+		"active":     true,
+		"created_at": time.Now(),
+		"created_by": "system",
+	}
+	tenantID, err := pkg.TenantStamp(ctx)
+	if err != nil {
+		return err
+	}
+	if tenantID != "" {
+		onInsert["tenant_id"] = tenantID
+	}
+	_, err = r.DB.UpdateOne(ctx, pkg.TenantFilter(ctx, bson.M{"code": code}), bson.M{"$setOnInsert": onInsert}, options.UpdateOne().SetUpsert(true))
+	return err
+}
+
+func (r *warehouseRepository) GetWarehouseByCode(ctx context.Context, code string) (*WarehouseModel, error) {
+	var model WarehouseModel
+	if err := r.DB.FindOne(ctx, pkg.TenantFilter(ctx, bson.M{"code": code})).Decode(&model); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrWarehouseNotFound
+		}
+		return nil, err
+	}
+	return &model, nil
+}
+
+func (r *warehouseRepository) GetWarehouses(ctx context.Context) ([]WarehouseModel, error) {
+	cursor, err := r.DB.Find(ctx, pkg.TenantFilter(ctx, bson.M{}), options.Find().SetSort(bson.D{{Key: "code", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	warehouses := []WarehouseModel{}
+	if err := cursor.All(ctx, &warehouses); err != nil {
+		return nil, err
+	}
+	return warehouses, nil
+}
+
+type balanceRepository struct {
+	Logger *zap.Logger
+	DB     *mongo.Collection
+}
+
+func NewBalanceRepository(db *mongo.Collection, log *zap.Logger) BalanceRepository {
+	return &balanceRepository{Logger: log, DB: db}
+}
+
+func (r *balanceRepository) InitRepository() error {
+	indexs := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "sku", Value: 1}, {Key: "warehouse", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "warehouse", Value: 1}}},
+	}
+	if _, err := r.DB.Indexes().CreateMany(context.TODO(), indexs); err != nil {
+		return errors.New("BalanceRepository.InitRepository: failed create indexs")
+	}
+	r.Logger.Info("BalanceRepository.InitRepository: index created")
+	return nil
+}
+
+// newBalance : $setOnInsert of a zero balance in the tenant of ctx
+func newBalance(ctx context.Context) (bson.M, error) {
+	onInsert := bson.M{
+		"_id":        bson.NewObjectID(),
+		"on_hand":    int64(0),
+		"reserved":   int64(0),
+		"version":    int64(0),
+		"updated_at": time.Now(),
+	}
+	tenantID, err := pkg.TenantStamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if tenantID != "" {
+		onInsert["tenant_id"] = tenantID
+	}
+	return onInsert, nil
+}
+
+func (r *balanceRepository) GetOrCreateBalance(ctx context.Context, sku string, warehouse string) (*BalanceModel, error) {
+	onInsert, err := newBalance(ctx)
+	if err != nil {
+		return nil, err
+	}
+	filter := pkg.TenantFilter(ctx, bson.M{"sku": sku, "warehouse": warehouse})
+	update := bson.M{"$setOnInsert": onInsert}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var model BalanceModel
+	err = r.DB.FindOneAndUpdate(ctx, filter, update, opts).Decode(&model)
+	if mongo.IsDuplicateKeyError(err) {
+		// two first uses at once : the other upsert won, read it
+		err = r.DB.FindOne(ctx, filter).Decode(&model)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &model, nil
+}
+
+func (r *balanceRepository) CompareAndSwapBalance(ctx context.Context, id bson.ObjectID, version int64, onHandDelta int64, reservedDelta int64) (bool, error) {
+	update := bson.M{
+		"$inc": bson.M{"on_hand": onHandDelta, "reserved": reservedDelta, "version": int64(1)},
+		"$set": bson.M{"updated_at": time.Now()},
+	}
+	res, err := r.DB.UpdateOne(ctx, pkg.TenantFilter(ctx, bson.M{"_id": id, "version": version}), update)
+	if err != nil {
+		return false, err
+	}
+	return res.MatchedCount == 1, nil
+}
+
+func (r *balanceRepository) GetBalances(ctx context.Context, filter *BalanceFilter) ([]BalanceModel, error) {
+	query := pkg.TenantFilter(ctx, bson.M{})
+	if len(filter.SKUs) > 0 {
+		query["sku"] = bson.M{"$in": filter.SKUs}
+	}
+	if filter.Warehouse != "" {
+		query["warehouse"] = filter.Warehouse
+	}
+	cursor, err := r.DB.Find(ctx, query, options.Find().SetSort(bson.D{{Key: "sku", Value: 1}, {Key: "warehouse", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	balances := []BalanceModel{}
+	if err := cursor.All(ctx, &balances); err != nil {
+		return nil, err
+	}
+	return balances, nil
+}
+
+func (r *balanceRepository) SetBalanceLocation(ctx context.Context, sku string, warehouse string, location string) (*BalanceModel, error) {
+	onInsert, err := newBalance(ctx)
+	if err != nil {
+		return nil, err
+	}
+	filter := pkg.TenantFilter(ctx, bson.M{"sku": sku, "warehouse": warehouse})
+	update := bson.M{
+		"$set":         bson.M{"location": location},
+		"$setOnInsert": onInsert,
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var model BalanceModel
+	err = r.DB.FindOneAndUpdate(ctx, filter, update, opts).Decode(&model)
+	if mongo.IsDuplicateKeyError(err) {
+		err = r.DB.FindOneAndUpdate(ctx, filter, bson.M{"$set": bson.M{"location": location}}, opts).Decode(&model)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &model, nil
+}
+
+type movementRepository struct {
+	Logger *zap.Logger
+	DB     *mongo.Collection
+}
+
+func NewMovementRepository(db *mongo.Collection, log *zap.Logger) MovementRepository {
+	return &movementRepository{Logger: log, DB: db}
+}
+
+func (r *movementRepository) InitRepository() error {
+	indexs := []mongo.IndexModel{
+		{
+			Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "idempotency_key", Value: 1}},
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"idempotency_key": bson.M{"$type": "string"}}),
+		},
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "sku", Value: 1}, {Key: "warehouse", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "ref_type", Value: 1}, {Key: "ref_id", Value: 1}}},
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "created_at", Value: -1}}},
+	}
+	if _, err := r.DB.Indexes().CreateMany(context.TODO(), indexs); err != nil {
+		return errors.New("MovementRepository.InitRepository: failed create indexs")
+	}
+	r.Logger.Info("MovementRepository.InitRepository: index created")
+	return nil
+}
+
+func (r *movementRepository) InsertMovement(ctx context.Context, movement *MovementModel) (*MovementModel, error) {
+	movement.ID = bson.NewObjectID()
+	tenantID, err := pkg.TenantStamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	movement.TenantID = tenantID
+	if _, err := r.DB.InsertOne(ctx, movement); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, ErrDuplicateMovement
+		}
+		return nil, err
+	}
+	return movement, nil
+}
+
+func (r *movementRepository) GetMovementByIdempotencyKey(ctx context.Context, key string) (*MovementModel, error) {
+	var model MovementModel
+	if err := r.DB.FindOne(ctx, pkg.TenantFilter(ctx, bson.M{"idempotency_key": key})).Decode(&model); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &model, nil
+}
+
+func (r *movementRepository) GetMovements(ctx context.Context, filter *MovementFilter) ([]MovementModel, error) {
+	query := pkg.TenantFilter(ctx, bson.M{})
+	if filter.SKU != "" {
+		query["sku"] = filter.SKU
+	}
+	if filter.Warehouse != "" {
+		query["warehouse"] = filter.Warehouse
+	}
+	if filter.Type != "" {
+		query["type"] = filter.Type
+	}
+	if filter.RefType != "" {
+		query["ref_type"] = filter.RefType
+	}
+	if filter.RefID != "" {
+		query["ref_id"] = filter.RefID
+	}
+	created := bson.M{}
+	if !filter.From.IsZero() {
+		created["$gte"] = filter.From
+	}
+	if !filter.To.IsZero() {
+		created["$lt"] = filter.To
+	}
+	if len(created) > 0 {
+		query["created_at"] = created
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(filter.Skip).
+		SetLimit(filter.Limit)
+	cursor, err := r.DB.Find(ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	movements := []MovementModel{}
+	if err := cursor.All(ctx, &movements); err != nil {
+		return nil, err
+	}
+	return movements, nil
+}
+
+type reservationRepository struct {
+	Logger *zap.Logger
+	DB     *mongo.Collection
+}
+
+func NewReservationRepository(db *mongo.Collection, log *zap.Logger) ReservationRepository {
+	return &reservationRepository{Logger: log, DB: db}
+}
+
+func (r *reservationRepository) InitRepository() error {
+	indexs := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "ref_id", Value: 1}, {Key: "sku", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "status", Value: 1}, {Key: "sku", Value: 1}}},
+	}
+	if _, err := r.DB.Indexes().CreateMany(context.TODO(), indexs); err != nil {
+		return errors.New("ReservationRepository.InitRepository: failed create indexs")
+	}
+	r.Logger.Info("ReservationRepository.InitRepository: index created")
+	return nil
+}
+
+func (r *reservationRepository) InsertReservation(ctx context.Context, reservation *ReservationModel) (*ReservationModel, error) {
+	reservation.ID = bson.NewObjectID()
+	tenantID, err := pkg.TenantStamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reservation.TenantID = tenantID
+	if _, err := r.DB.InsertOne(ctx, reservation); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, ErrDuplicateReservation
+		}
+		return nil, err
+	}
+	return reservation, nil
+}
+
+func (r *reservationRepository) GetReservationsByRefID(ctx context.Context, refID string) ([]ReservationModel, error) {
+	cursor, err := r.DB.Find(ctx, pkg.TenantFilter(ctx, bson.M{"ref_id": refID}), options.Find().SetSort(bson.D{{Key: "sku", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	reservations := []ReservationModel{}
+	if err := cursor.All(ctx, &reservations); err != nil {
+		return nil, err
+	}
+	return reservations, nil
+}
+
+func (r *reservationRepository) TransitionReservation(ctx context.Context, id bson.ObjectID, from ReservationStatusEnum, to ReservationStatusEnum) (bool, error) {
+	res, err := r.DB.UpdateOne(ctx,
+		pkg.TenantFilter(ctx, bson.M{"_id": id, "status": from}),
+		bson.M{"$set": bson.M{"status": to, "updated_at": time.Now()}})
+	if err != nil {
+		return false, err
+	}
+	return res.ModifiedCount == 1, nil
+}
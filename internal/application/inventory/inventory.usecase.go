@@ -0,0 +1,747 @@
+package inventory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.uber.org/zap"
+
+	"ecommerce/internal/adapter/dto"
+	"ecommerce/internal/application/product"
+	"ecommerce/internal/env"
+)
+
+const (
+	movementPageSize    = 50
+	movementMaxPageSize = 200
+
+	REF_MANUAL   = "MANUAL"
+	REF_TRANSFER = "TRANSFER"
+	REF_ORDER    = "ORDER"
+	// goods receipt of a purchase order, ref id = po number
+	REF_PURCHASE_ORDER = "PURCHASE_ORDER"
+)
+
+var (
+	ErrInsufficientStock = errors.New("insufficient available stock")
+	// optimistic concurrency : the balance kept changing under us
+	ErrBalanceConflict = errors.New("stock balance changed concurrently, retry")
+	ErrSameWarehouse   = errors.New("transfer source and destination are the same warehouse")
+)
+
+// stockPolicy : what a movement may do to a balance
+type stockPolicy int
+
+const (
+	// manual outflows : never below what is physically there
+	policyNoNegativeOnHand stockPolicy = iota
+	// transfers out : only stock that is not promised to an order
+	policyNoNegativeAvailable
+	// order driven : the sale already happened on the channel, record it even when oversold
+	policyAllowNegative
+)
+
+type IInventoryService interface {
+	CreateWarehouse(ctx context.Context, actor string, req *IReqWarehouse) (*WarehouseEntity, error)
+	GetWarehouses(ctx context.Context) ([]WarehouseEntity, error)
+
+	GetStock(ctx context.Context, query *IReqStockQuery) ([]BalanceEntity, error)
+	// totals over every warehouse + per warehouse rows
+	GetStockBySKU(ctx context.Context, sku string) (*StockSummaryEntity, error)
+	GetMovements(ctx context.Context, query *IReqMovementQuery) ([]MovementEntity, error)
+	// pick location of a sku in one warehouse (pick lists are sorted by it)
+	SetStockLocation(ctx context.Context, sku string, req *IReqStockLocation) (*BalanceEntity, error)
+
+	PostReceipt(ctx context.Context, actor string, req *IReqStockMovement) (*MovementEntity, error)
+	PostReturn(ctx context.Context, actor string, req *IReqStockMovement) (*MovementEntity, error)
+	// quantity is signed : + found, - lost / damaged
+	PostAdjustment(ctx context.Context, actor string, req *IReqStockAdjustment) (*MovementEntity, error)
+	PostTransfer(ctx context.Context, actor string, req *IReqStockTransfer) ([]MovementEntity, error)
+
+	// order lifecycle, idempotent per (order, sku) : reserve on READY_TO_SHIP, consume on SHIPPED, release on CANCELLED
+	ReserveOrder(ctx context.Context, order OrderRef, lines []OrderLine) error
+	ConsumeOrder(ctx context.Context, order OrderRef, lines []OrderLine) error
+	ReleaseOrder(ctx context.Context, order OrderRef) error
+	GetOrderReservations(ctx context.Context, order OrderRef) ([]ReservationEntity, error)
+
+	// post a movement as is : used by other modules (purchase receipts, ...)
+	PostMovement(ctx context.Context, actor string, mv *MovementModel) (*MovementEntity, error)
+
+	AddStockListener(listener IStockListener)
+}
+
+// IStockListener : told after a posted movement changed available stock (on hand - reserved) ;
+// runs inline, keep it short
+type IStockListener interface {
+	OnStockChanged(ctx context.Context, sku string, warehouse string)
+}
+
+// OrderRef : channel order that holds stock
+type OrderRef struct {
+	Channel dto.MarketplaceChannelEnum
+	ShopID  string
+	OrderID string
+}
+
+func (o OrderRef) RefID() string {
+	return string(o.Channel) + ":" + o.ShopID + ":" + o.OrderID
+}
+
+// OrderLine : master sku + quantity (same sku on several lines is summed)
+type OrderLine struct {
+	SKU      string
+	Quantity int64
+}
+
+type WarehouseEntity struct {
+	ID        string    `json:"id"`
+	Code      string    `json:"code"`
+	Name      string    `json:"name"`
+	Address   string    `json:"address"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+	CreatedBy string    `json:"created_by"`
+}
+
+type BalanceEntity struct {
+	SKU       string    `json:"sku"`
+	Warehouse string    `json:"warehouse"`
+	OnHand    int64     `json:"on_hand"`
+	Reserved  int64     `json:"reserved"`
+	Available int64     `json:"available"`
+	Version   int64     `json:"version"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Location  string    `json:"location"`
+}
+
+type StockSummaryEntity struct {
+	SKU        string          `json:"sku"`
+	OnHand     int64           `json:"on_hand"`
+	Reserved   int64           `json:"reserved"`
+	Available  int64           `json:"available"`
+	Warehouses []BalanceEntity `json:"warehouses"`
+}
+
+type MovementEntity struct {
+	ID             string           `json:"id"`
+	Type           MovementTypeEnum `json:"type"`
+	SKU            string           `json:"sku"`
+	Warehouse      string           `json:"warehouse"`
+	OnHandDelta    int64            `json:"on_hand_delta"`
+	ReservedDelta  int64            `json:"reserved_delta"`
+	OnHandAfter    int64            `json:"on_hand_after"`
+	ReservedAfter  int64            `json:"reserved_after"`
+	BalanceVersion int64            `json:"balance_version"`
+	RefType        string           `json:"ref_type"`
+	RefID          string           `json:"ref_id"`
+	IdempotencyKey string           `json:"idempotency_key,omitempty"`
+	Note           string           `json:"note"`
+	CreatedAt      time.Time        `json:"created_at"`
+	CreatedBy      string           `json:"created_by"`
+}
+
+type ReservationEntity struct {
+	ID        string                `json:"id"`
+	RefID     string                `json:"ref_id"`
+	SKU       string                `json:"sku"`
+	Warehouse string                `json:"warehouse"`
+	Quantity  int64                 `json:"quantity"`
+	Status    ReservationStatusEnum `json:"status"`
+	CreatedAt time.Time             `json:"created_at"`
+	UpdatedAt time.Time             `json:"updated_at"`
+}
+
+func WarehouseModelToEntity(model *WarehouseModel) *WarehouseEntity {
+	return &WarehouseEntity{
+		ID:        model.ID.Hex(),
+		Code:      model.Code,
+		Name:      model.Name,
+		Address:   model.Address,
+		Active:    model.Active,
+		CreatedAt: model.CreatedAt,
+		CreatedBy: model.CreatedBy,
+	}
+}
+
+func BalanceModelToEntity(model *BalanceModel) *BalanceEntity {
+	return &BalanceEntity{
+		SKU:       model.SKU,
+		Warehouse: model.Warehouse,
+		OnHand:    model.OnHand,
+		Reserved:  model.Reserved,
+		Available: model.OnHand - model.Reserved,
+		Version:   model.Version,
+		UpdatedAt: model.UpdatedAt,
+		Location:  model.Location,
+	}
+}
+
+func MovementModelToEntity(model *MovementModel) *MovementEntity {
+	return &MovementEntity{
+		ID:             model.ID.Hex(),
+		Type:           model.Type,
+		SKU:            model.SKU,
+		Warehouse:      model.Warehouse,
+		OnHandDelta:    model.OnHandDelta,
+		ReservedDelta:  model.ReservedDelta,
+		OnHandAfter:    model.OnHandAfter,
+		ReservedAfter:  model.ReservedAfter,
+		BalanceVersion: model.BalanceVersion,
+		RefType:        model.RefType,
+		RefID:          model.RefID,
+		IdempotencyKey: model.IdempotencyKey,
+		Note:           model.Note,
+		CreatedAt:      model.CreatedAt,
+		CreatedBy:      model.CreatedBy,
+	}
+}
+
+func ReservationModelToEntity(model *ReservationModel) *ReservationEntity {
+	return &ReservationEntity{
+		ID:        model.ID.Hex(),
+		RefID:     model.RefID,
+		SKU:       model.SKU,
+		Warehouse: model.Warehouse,
+		Quantity:  model.Quantity,
+		Status:    model.Status,
+		CreatedAt: model.CreatedAt,
+		UpdatedAt: model.UpdatedAt,
+	}
+}
+
+type inventoryService struct {
+	Config *env.Config
+	Logger *zap.Logger
+
+	ProductService        product.IProductService
+	WarehouseRepository   WarehouseRepository
+	BalanceRepository     BalanceRepository
+	MovementRepository    MovementRepository
+	ReservationRepository ReservationRepository
+
+	stockListeners []IStockListener
+}
+
+func NewInventoryService(cfg *env.Config, logger *zap.Logger,
+	productService product.IProductService,
+	warehouse WarehouseRepository,
+	balance BalanceRepository,
+	movement MovementRepository,
+	reservation ReservationRepository,
+) IInventoryService {
+	return &inventoryService{
+		Config:                cfg,
+		Logger:                logger,
+		ProductService:        productService,
+		WarehouseRepository:   warehouse,
+		BalanceRepository:     balance,
+		MovementRepository:    movement,
+		ReservationRepository: reservation,
+	}
+}
+
+// apply : balance first (compare-and-swap on version), then the ledger row carrying the new version.
+// A ledger insert that fails is compensated on the balance, so both never drift apart.
+// The compensation bumps the version too : ledger versions of a balance increase but may skip.
+func (s *inventoryService) apply(ctx context.Context, mv *MovementModel, policy stockPolicy) (*MovementModel, error) {
+	if mv.IdempotencyKey != "" {
+		done, err := s.MovementRepository.GetMovementByIdempotencyKey(ctx, mv.IdempotencyKey)
+		if err != nil {
+			return nil, err
+		}
+		if done != nil {
+			return done, nil
+		}
+	}
+
+	bal, err := s.swap(ctx, mv.SKU, mv.Warehouse, mv.OnHandDelta, mv.ReservedDelta, policy)
+	if err != nil {
+		return nil, err
+	}
+
+	mv.OnHandAfter = bal.OnHand + mv.OnHandDelta
+	mv.ReservedAfter = bal.Reserved + mv.ReservedDelta
+	mv.BalanceVersion = bal.Version + 1
+	if mv.CreatedAt.IsZero() {
+		mv.CreatedAt = time.Now()
+	}
+	saved, err := s.MovementRepository.InsertMovement(ctx, mv)
+	if err == nil {
+		if saved.OnHandDelta != saved.ReservedDelta {
+			for _, listener := range s.stockListeners {
+				listener.OnStockChanged(ctx, saved.SKU, saved.Warehouse)
+			}
+		}
+		return saved, nil
+	}
+
+	if _, revertErr := s.swap(ctx, mv.SKU, mv.Warehouse, -mv.OnHandDelta, -mv.ReservedDelta, policyAllowNegative); revertErr != nil {
+		s.Logger.Error("usecase.inventory.apply : balance revert failed, ledger and balance differ",
+			zap.String("sku", mv.SKU), zap.String("warehouse", mv.Warehouse), zap.Error(revertErr))
+	}
+	if errors.Is(err, ErrDuplicateMovement) && mv.IdempotencyKey != "" {
+		// lost a race with the same business event : the winner is the answer
+		if done, getErr := s.MovementRepository.GetMovementByIdempotencyKey(ctx, mv.IdempotencyKey); getErr == nil && done != nil {
+			return done, nil
+		}
+	}
+	return nil, err
+}
+
+// AddStockListener : container wiring only, not safe once movements are flowing
+func (s *inventoryService) AddStockListener(listener IStockListener) {
+	s.stockListeners = append(s.stockListeners, listener)
+}
+
+// swap : retries on version conflicts, checks the policy against the balance it read
+func (s *inventoryService) swap(ctx context.Context, sku string, warehouse string, onHandDelta int64, reservedDelta int64, policy stockPolicy) (*BalanceModel, error) {
+	retries := s.Config.Inventory.InventoryMaxRetries
+	if retries <= 0 {
+		retries = 1
+	}
+	for attempt := 0; attempt < retries; attempt++ {
+		bal, err := s.BalanceRepository.GetOrCreateBalance(ctx, sku, warehouse)
+		if err != nil {
+			return nil, err
+		}
+		onHand, reserved := bal.OnHand+onHandDelta, bal.Reserved+reservedDelta
+		switch policy {
+		case policyNoNegativeOnHand:
+			if onHandDelta < 0 && onHand < 0 {
+				return nil, fmt.Errorf("%w : %s on hand %d in %s", ErrInsufficientStock, sku, bal.OnHand, warehouse)
+			}
+		case policyNoNegativeAvailable:
+			if onHandDelta < 0 && onHand-reserved < 0 {
+				return nil, fmt.Errorf("%w : %s available %d in %s", ErrInsufficientStock, sku, bal.OnHand-bal.Reserved, warehouse)
+			}
+		}
+
+		ok, err := s.BalanceRepository.CompareAndSwapBalance(ctx, bal.ID, bal.Version, onHandDelta, reservedDelta)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return bal, nil
+		}
+	}
+	return nil, ErrBalanceConflict
+}
+
+func (s *inventoryService) CreateWarehouse(ctx context.Context, actor string, req *IReqWarehouse) (*WarehouseEntity, error) {
+	created, err := s.WarehouseRepository.CreateWarehouse(ctx, &WarehouseModel{
+		Code:      strings.ToUpper(strings.TrimSpace(req.Code)),
+		Name:      req.Name,
+		Address:   req.Address,
+		Active:    true,
+		CreatedAt: time.Now(),
+		CreatedBy: actor,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return WarehouseModelToEntity(created), nil
+}
+
+func (s *inventoryService) GetWarehouses(ctx context.Context) ([]WarehouseEntity, error) {
+	models, err := s.WarehouseRepository.GetWarehouses(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]WarehouseEntity, 0, len(models))
+	for i := range models {
+		out = append(out, *WarehouseModelToEntity(&models[i]))
+	}
+	return out, nil
+}
+
+func (s *inventoryService) GetStock(ctx context.Context, query *IReqStockQuery) ([]BalanceEntity, error) {
+	filter := &BalanceFilter{Warehouse: query.Warehouse}
+	if query.SKU != "" {
+		filter.SKUs = strings.Split(query.SKU, ",")
+	}
+	models, err := s.BalanceRepository.GetBalances(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]BalanceEntity, 0, len(models))
+	for i := range models {
+		out = append(out, *BalanceModelToEntity(&models[i]))
+	}
+	return out, nil
+}
+
+func (s *inventoryService) GetStockBySKU(ctx context.Context, sku string) (*StockSummaryEntity, error) {
+	balances, err := s.GetStock(ctx, &IReqStockQuery{SKU: sku})
+	if err != nil {
+		return nil, err
+	}
+	res := &StockSummaryEntity{SKU: sku, Warehouses: balances}
+	for _, b := range balances {
+		res.OnHand += b.OnHand
+		res.Reserved += b.Reserved
+		res.Available += b.Available
+	}
+	return res, nil
+}
+
+func (s *inventoryService) GetMovements(ctx context.Context, query *IReqMovementQuery) ([]MovementEntity, error) {
+	page, size := query.Page, query.Size
+	if page <= 0 {
+		page = 1
+	}
+	if size <= 0 {
+		size = movementPageSize
+	}
+	if size > movementMaxPageSize {
+		size = movementMaxPageSize
+	}
+	filter := &MovementFilter{
+		SKU:       query.SKU,
+		Warehouse: query.Warehouse,
+		Type:      query.Type,
+		RefType:   query.RefType,
+		RefID:     query.RefID,
+		Skip:      int64((page - 1) * size),
+		Limit:     int64(size),
+	}
+	if query.From != "" {
+		filter.From, _ = time.Parse(time.RFC3339, query.From)
+	}
+	if query.To != "" {
+		filter.To, _ = time.Parse(time.RFC3339, query.To)
+	}
+
+	models, err := s.MovementRepository.GetMovements(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]MovementEntity, 0, len(models))
+	for i := range models {
+		out = append(out, *MovementModelToEntity(&models[i]))
+	}
+	return out, nil
+}
+
+func (s *inventoryService) SetStockLocation(ctx context.Context, sku string, req *IReqStockLocation) (*BalanceEntity, error) {
+	if err := s.checkTarget(ctx, sku, req.Warehouse); err != nil {
+		return nil, err
+	}
+	model, err := s.BalanceRepository.SetBalanceLocation(ctx, sku, req.Warehouse, strings.ToUpper(strings.TrimSpace(req.Location)))
+	if err != nil {
+		return nil, err
+	}
+	return BalanceModelToEntity(model), nil
+}
+
+// checkWarehouse : known warehouse of the tenant ; the default one is created on first use, startup
+// only ensures it for the platform tenant
+func (s *inventoryService) checkWarehouse(ctx context.Context, warehouse string) error {
+	_, err := s.WarehouseRepository.GetWarehouseByCode(ctx, warehouse)
+	if errors.Is(err, ErrWarehouseNotFound) && warehouse == s.Config.Inventory.InventoryDefaultWarehouse {
+		return s.WarehouseRepository.EnsureWarehouse(ctx, warehouse, "Default warehouse")
+	}
+	return err
+}
+
+// checkTarget : manual movements only touch known skus and warehouses
+func (s *inventoryService) checkTarget(ctx context.Context, sku string, warehouse string) error {
+	if err := s.checkWarehouse(ctx, warehouse); err != nil {
+		return err
+	}
+	if _, err := s.ProductService.GetProductBySKU(ctx, sku); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *inventoryService) manual(ctx context.Context, actor string, typ MovementTypeEnum, req *IReqStockMovement) (*MovementEntity, error) {
+	if err := s.checkTarget(ctx, req.SKU, req.Warehouse); err != nil {
+		return nil, err
+	}
+	refType := req.RefType
+	if refType == "" {
+		refType = REF_MANUAL
+	}
+	saved, err := s.apply(ctx, &MovementModel{
+		Type:           typ,
+		SKU:            req.SKU,
+		Warehouse:      req.Warehouse,
+		OnHandDelta:    req.Quantity,
+		RefType:        refType,
+		RefID:          req.RefID,
+		IdempotencyKey: req.IdempotencyKey,
+		Note:           req.Note,
+		CreatedBy:      actor,
+	}, policyNoNegativeOnHand)
+	if err != nil {
+		return nil, err
+	}
+	return MovementModelToEntity(saved), nil
+}
+
+func (s *inventoryService) PostReceipt(ctx context.Context, actor string, req *IReqStockMovement) (*MovementEntity, error) {
+	return s.manual(ctx, actor, MOVEMENT_RECEIPT, req)
+}
+
+func (s *inventoryService) PostReturn(ctx context.Context, actor string, req *IReqStockMovement) (*MovementEntity, error) {
+	return s.manual(ctx, actor, MOVEMENT_RETURN, req)
+}
+
+func (s *inventoryService) PostAdjustment(ctx context.Context, actor string, req *IReqStockAdjustment) (*MovementEntity, error) {
+	if err := s.checkTarget(ctx, req.SKU, req.Warehouse); err != nil {
+		return nil, err
+	}
+	saved, err := s.apply(ctx, &MovementModel{
+		Type:           MOVEMENT_ADJUSTMENT,
+		SKU:            req.SKU,
+		Warehouse:      req.Warehouse,
+		OnHandDelta:    req.Quantity,
+		RefType:        REF_MANUAL,
+		IdempotencyKey: req.IdempotencyKey,
+		Note:           req.Reason,
+		CreatedBy:      actor,
+	}, policyNoNegativeOnHand)
+	if err != nil {
+		return nil, err
+	}
+	return MovementModelToEntity(saved), nil
+}
+
+// PostTransfer : out of the source, then into the destination ; an inbound failure puts the stock back
+func (s *inventoryService) PostTransfer(ctx context.Context, actor string, req *IReqStockTransfer) ([]MovementEntity, error) {
+	if req.From == req.To {
+		return nil, ErrSameWarehouse
+	}
+	if err := s.checkTarget(ctx, req.SKU, req.From); err != nil {
+		return nil, err
+	}
+	if err := s.checkWarehouse(ctx, req.To); err != nil {
+		return nil, err
+	}
+
+	transferID := bson.NewObjectID().Hex()
+	out, err := s.apply(ctx, &MovementModel{
+		Type:        MOVEMENT_TRANSFER_OUT,
+		SKU:         req.SKU,
+		Warehouse:   req.From,
+		OnHandDelta: -req.Quantity,
+		RefType:     REF_TRANSFER,
+		RefID:       transferID,
+		Note:        req.Note,
+		CreatedBy:   actor,
+	}, policyNoNegativeAvailable)
+	if err != nil {
+		return nil, err
+	}
+	in, err := s.apply(ctx, &MovementModel{
+		Type:        MOVEMENT_TRANSFER_IN,
+		SKU:         req.SKU,
+		Warehouse:   req.To,
+		OnHandDelta: req.Quantity,
+		RefType:     REF_TRANSFER,
+		RefID:       transferID,
+		Note:        req.Note,
+		CreatedBy:   actor,
+	}, policyAllowNegative)
+	if err != nil {
+		if _, backErr := s.apply(ctx, &MovementModel{
+			Type:        MOVEMENT_TRANSFER_IN,
+			SKU:         req.SKU,
+			Warehouse:   req.From,
+			OnHandDelta: req.Quantity,
+			RefType:     REF_TRANSFER,
+			RefID:       transferID,
+			Note:        "transfer to " + req.To + " failed : stock returned",
+			CreatedBy:   actor,
+		}, policyAllowNegative); backErr != nil {
+			s.Logger.Error("usecase.PostTransfer : failed to return stock to source", zap.String("transfer_id", transferID), zap.Error(backErr))
+		}
+		return nil, err
+	}
+	return []MovementEntity{*MovementModelToEntity(out), *MovementModelToEntity(in)}, nil
+}
+
+func (s *inventoryService) PostMovement(ctx context.Context, actor string, mv *MovementModel) (*MovementEntity, error) {
+	mv.CreatedBy = actor
+	policy := policyNoNegativeOnHand
+	if mv.RefType == REF_ORDER {
+		policy = policyAllowNegative
+	}
+	saved, err := s.apply(ctx, mv, policy)
+	if err != nil {
+		return nil, err
+	}
+	return MovementModelToEntity(saved), nil
+}
+
+// sumLines : one quantity per sku
+func sumLines(lines []OrderLine) map[string]int64 {
+	qty := map[string]int64{}
+	for _, l := range lines {
+		if l.SKU != "" && l.Quantity > 0 {
+			qty[l.SKU] += l.Quantity
+		}
+	}
+	return qty
+}
+
+// ReserveOrder : ledger first (idempotency key), then the reservation row ; a retry after a
+// crash in between finds the movement and only writes the row
+func (s *inventoryService) ReserveOrder(ctx context.Context, order OrderRef, lines []OrderLine) error {
+	refID := order.RefID()
+	warehouse := s.Config.Inventory.InventoryDefaultWarehouse
+	if err := s.checkWarehouse(ctx, warehouse); err != nil {
+		return err
+	}
+
+	existing, err := s.ReservationRepository.GetReservationsByRefID(ctx, refID)
+	if err != nil {
+		return err
+	}
+	held := map[string]bool{}
+	for _, r := range existing {
+		held[r.SKU] = true
+	}
+
+	var errs []error
+	for sku, qty := range sumLines(lines) {
+		if held[sku] {
+			continue
+		}
+		if _, err := s.apply(ctx, &MovementModel{
+			Type:           MOVEMENT_RESERVE,
+			SKU:            sku,
+			Warehouse:      warehouse,
+			ReservedDelta:  qty,
+			RefType:        REF_ORDER,
+			RefID:          refID,
+			IdempotencyKey: "RESERVE:" + refID + ":" + sku,
+			CreatedBy:      "system",
+		}, policyAllowNegative); err != nil {
+			errs = append(errs, fmt.Errorf("%s : %w", sku, err))
+			continue
+		}
+		now := time.Now()
+		if _, err := s.ReservationRepository.InsertReservation(ctx, &ReservationModel{
+			RefID:     refID,
+			SKU:       sku,
+			Warehouse: warehouse,
+			Quantity:  qty,
+			Status:    RESERVATION_RESERVED,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}); err != nil && !errors.Is(err, ErrDuplicateReservation) {
+			errs = append(errs, fmt.Errorf("%s : %w", sku, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ConsumeOrder : reserved lines turn into a sale ; lines never reserved (order seen for the first
+// time as SHIPPED) are sold directly and recorded as consumed
+func (s *inventoryService) ConsumeOrder(ctx context.Context, order OrderRef, lines []OrderLine) error {
+	refID := order.RefID()
+	existing, err := s.ReservationRepository.GetReservationsByRefID(ctx, refID)
+	if err != nil {
+		return err
+	}
+	bySKU := map[string]ReservationModel{}
+	for _, r := range existing {
+		bySKU[r.SKU] = r
+	}
+
+	var errs []error
+	for sku, qty := range sumLines(lines) {
+		r, reserved := bySKU[sku]
+		if reserved && r.Status != RESERVATION_RESERVED {
+			continue
+		}
+		mv := &MovementModel{
+			Type:           MOVEMENT_SALE,
+			SKU:            sku,
+			Warehouse:      s.Config.Inventory.InventoryDefaultWarehouse,
+			OnHandDelta:    -qty,
+			RefType:        REF_ORDER,
+			RefID:          refID,
+			IdempotencyKey: "SALE:" + refID + ":" + sku,
+			CreatedBy:      "system",
+		}
+		if reserved {
+			mv.Warehouse, mv.OnHandDelta, mv.ReservedDelta = r.Warehouse, -r.Quantity, -r.Quantity
+		}
+		if _, err := s.apply(ctx, mv, policyAllowNegative); err != nil {
+			errs = append(errs, fmt.Errorf("%s : %w", sku, err))
+			continue
+		}
+
+		if reserved {
+			_, err = s.ReservationRepository.TransitionReservation(ctx, r.ID, RESERVATION_RESERVED, RESERVATION_CONSUMED)
+		} else {
+			now := time.Now()
+			_, err = s.ReservationRepository.InsertReservation(ctx, &ReservationModel{
+				RefID:     refID,
+				SKU:       sku,
+				Warehouse: mv.Warehouse,
+				Quantity:  qty,
+				Status:    RESERVATION_CONSUMED,
+				CreatedAt: now,
+				UpdatedAt: now,
+			})
+			if errors.Is(err, ErrDuplicateReservation) {
+				err = nil
+			}
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s : %w", sku, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *inventoryService) ReleaseOrder(ctx context.Context, order OrderRef) error {
+	refID := order.RefID()
+	existing, err := s.ReservationRepository.GetReservationsByRefID(ctx, refID)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, r := range existing {
+		if r.Status != RESERVATION_RESERVED {
+			continue
+		}
+		if _, err := s.apply(ctx, &MovementModel{
+			Type:           MOVEMENT_RELEASE,
+			SKU:            r.SKU,
+			Warehouse:      r.Warehouse,
+			ReservedDelta:  -r.Quantity,
+			RefType:        REF_ORDER,
+			RefID:          refID,
+			IdempotencyKey: "RELEASE:" + refID + ":" + r.SKU,
+			CreatedBy:      "system",
+		}, policyAllowNegative); err != nil {
+			errs = append(errs, fmt.Errorf("%s : %w", r.SKU, err))
+			continue
+		}
+		if _, err := s.ReservationRepository.TransitionReservation(ctx, r.ID, RESERVATION_RESERVED, RESERVATION_RELEASED); err != nil {
+			errs = append(errs, fmt.Errorf("%s : %w", r.SKU, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *inventoryService) GetOrderReservations(ctx context.Context, order OrderRef) ([]ReservationEntity, error) {
+	models, err := s.ReservationRepository.GetReservationsByRefID(ctx, order.RefID())
+	if err != nil {
+		return nil, err
+	}
+	out := make([]ReservationEntity, 0, len(models))
+	for i := range models {
+		out = append(out, *ReservationModelToEntity(&models[i]))
+	}
+	return out, nil
+}
@@ -0,0 +1,293 @@
+package inventory
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+
+	"ecommerce/internal/adapter/dto"
+)
+
+func balanceMovements(ti *testInventory, sku string, warehouse string) []MovementModel {
+	var mvs []MovementModel
+	for _, m := range ti.Movements.all() {
+		if m.SKU == sku && m.Warehouse == warehouse {
+			mvs = append(mvs, m)
+		}
+	}
+	sort.Slice(mvs, func(i, j int) bool { return mvs[i].BalanceVersion < mvs[j].BalanceVersion })
+	return mvs
+}
+
+// checkBalance : the balance is the sum of its movements
+func checkBalance(t *testing.T, ti *testInventory, sku string, warehouse string) {
+	t.Helper()
+	var onHand, reserved int64
+	for _, m := range balanceMovements(ti, sku, warehouse) {
+		onHand += m.OnHandDelta
+		reserved += m.ReservedDelta
+	}
+	if bal := ti.Balances.get(sku, warehouse); bal.OnHand != onHand || bal.Reserved != reserved {
+		t.Fatalf("%s/%s: balance %d/%d, ledger sums to %d/%d", sku, warehouse, bal.OnHand, bal.Reserved, onHand, reserved)
+	}
+}
+
+// checkLedger : without keyed duplicates racing (their compensation bumps the version too),
+// every version has exactly one movement and its after values replay the ledger
+func checkLedger(t *testing.T, ti *testInventory, sku string, warehouse string) {
+	t.Helper()
+	mvs := balanceMovements(ti, sku, warehouse)
+	var onHand, reserved int64
+	for i, m := range mvs {
+		if m.BalanceVersion != int64(i+1) {
+			t.Fatalf("%s/%s: movement %d has version %d", sku, warehouse, i, m.BalanceVersion)
+		}
+		onHand += m.OnHandDelta
+		reserved += m.ReservedDelta
+		if m.OnHandAfter != onHand || m.ReservedAfter != reserved {
+			t.Fatalf("%s/%s v%d: after %d/%d, ledger sums to %d/%d", sku, warehouse, m.BalanceVersion, m.OnHandAfter, m.ReservedAfter, onHand, reserved)
+		}
+	}
+	if bal := ti.Balances.get(sku, warehouse); bal.Version != int64(len(mvs)) {
+		t.Fatalf("%s/%s: balance v%d, %d movements", sku, warehouse, bal.Version, len(mvs))
+	}
+	checkBalance(t, ti, sku, warehouse)
+}
+
+func TestConcurrentPostingsNoLostUpdate(t *testing.T) {
+	ti := newTestInventory(t, 1000)
+	ctx := context.Background()
+	ti.receive(t, "SKU-1", "MAIN", 100)
+
+	const writers = 40
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if _, err := ti.Service.PostReceipt(ctx, "test", &IReqStockMovement{SKU: "SKU-1", Warehouse: "MAIN", Quantity: 3}); err != nil {
+				t.Errorf("receipt: %v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := ti.Service.PostAdjustment(ctx, "test", &IReqStockAdjustment{SKU: "SKU-1", Warehouse: "MAIN", Quantity: -2, Reason: "damaged"}); err != nil {
+				t.Errorf("adjustment: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if bal := ti.Balances.get("SKU-1", "MAIN"); bal.OnHand != 100+writers*(3-2) {
+		t.Fatalf("on hand %d, want %d", bal.OnHand, 100+writers)
+	}
+	checkLedger(t, ti, "SKU-1", "MAIN")
+}
+
+func TestConcurrentPostingsConflictLeavesNoTrace(t *testing.T) {
+	// a single CAS attempt : some writers lose, what they lost must not show anywhere
+	ti := newTestInventory(t, 1)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	posted := int64(0)
+	for i := 0; i < 40; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := ti.Service.PostReceipt(ctx, "test", &IReqStockMovement{SKU: "SKU-1", Warehouse: "MAIN", Quantity: 1})
+			if err != nil && !errors.Is(err, ErrBalanceConflict) {
+				t.Errorf("receipt: %v", err)
+				return
+			}
+			if err == nil {
+				mu.Lock()
+				posted++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if bal := ti.Balances.get("SKU-1", "MAIN"); bal.OnHand != posted {
+		t.Fatalf("on hand %d, %d receipts posted", bal.OnHand, posted)
+	}
+	checkLedger(t, ti, "SKU-1", "MAIN")
+}
+
+func TestConcurrentOutflowsNeverOverdraw(t *testing.T) {
+	ti := newTestInventory(t, 1000)
+	ctx := context.Background()
+	ti.receive(t, "SKU-1", "MAIN", 10)
+	ti.receive(t, "SKU-2", "MAIN", 10)
+	// 4 of SKU-1 promised to an order : only 6 may leave by transfer
+	if err := ti.Service.ReserveOrder(ctx, OrderRef{Channel: dto.CHANNEL_SHOPEE, ShopID: "1", OrderID: "A"}, []OrderLine{{SKU: "SKU-1", Quantity: 4}}); err != nil {
+		t.Fatal(err)
+	}
+
+	const writers = 25
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	transferred, adjusted := 0, 0
+	for i := 0; i < writers; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, err := ti.Service.PostTransfer(ctx, "test", &IReqStockTransfer{SKU: "SKU-1", From: "MAIN", To: "SPARE", Quantity: 1})
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				transferred++
+			case !errors.Is(err, ErrInsufficientStock):
+				t.Errorf("transfer: %v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := ti.Service.PostAdjustment(ctx, "test", &IReqStockAdjustment{SKU: "SKU-2", Warehouse: "MAIN", Quantity: -1, Reason: "lost"})
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				adjusted++
+			case !errors.Is(err, ErrInsufficientStock):
+				t.Errorf("adjustment: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if transferred != 6 || adjusted != 10 {
+		t.Fatalf("%d transfers and %d adjustments went through, want 6 and 10", transferred, adjusted)
+	}
+	if bal := ti.Balances.get("SKU-1", "MAIN"); bal.OnHand != 4 || bal.Reserved != 4 {
+		t.Errorf("SKU-1 MAIN %d/%d, want 4/4", bal.OnHand, bal.Reserved)
+	}
+	if bal := ti.Balances.get("SKU-1", "SPARE"); bal.OnHand != 6 {
+		t.Errorf("SKU-1 SPARE on hand %d, want 6", bal.OnHand)
+	}
+	if bal := ti.Balances.get("SKU-2", "MAIN"); bal.OnHand != 0 {
+		t.Errorf("SKU-2 on hand %d, want 0", bal.OnHand)
+	}
+	for _, m := range ti.Movements.all() {
+		if m.Type == MOVEMENT_TRANSFER_OUT && m.OnHandAfter-m.ReservedAfter < 0 {
+			t.Errorf("transfer left available %d", m.OnHandAfter-m.ReservedAfter)
+		}
+	}
+	checkLedger(t, ti, "SKU-1", "MAIN")
+	checkLedger(t, ti, "SKU-1", "SPARE")
+	checkLedger(t, ti, "SKU-2", "MAIN")
+}
+
+func TestReserveOrderReplay(t *testing.T) {
+	ti := newTestInventory(t, 1000)
+	ctx := context.Background()
+	ti.receive(t, "SKU-1", "MAIN", 20)
+	order := OrderRef{Channel: dto.CHANNEL_SHOPEE, ShopID: "1", OrderID: "A"}
+	// the same sku twice on the order : one reservation of the sum
+	lines := []OrderLine{{SKU: "SKU-1", Quantity: 2}, {SKU: "SKU-1", Quantity: 1}, {SKU: "SKU-2", Quantity: 5}}
+
+	// the same status push delivered several times at once, then again later
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := ti.Service.ReserveOrder(ctx, order, lines); err != nil {
+				t.Errorf("reserve: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if err := ti.Service.ReserveOrder(ctx, order, lines); err != nil {
+		t.Fatal(err)
+	}
+
+	if bal := ti.Balances.get("SKU-1", "MAIN"); bal.OnHand != 20 || bal.Reserved != 3 {
+		t.Errorf("SKU-1 %d/%d, want 20/3", bal.OnHand, bal.Reserved)
+	}
+	// oversold : recorded anyway, the sale already happened on the channel
+	if bal := ti.Balances.get("SKU-2", "MAIN"); bal.Reserved != 5 {
+		t.Errorf("SKU-2 reserved %d, want 5", bal.Reserved)
+	}
+	reservations, _ := ti.Service.GetOrderReservations(ctx, order)
+	if len(reservations) != 2 {
+		t.Fatalf("%d reservations, want 2", len(reservations))
+	}
+	reserves := 0
+	for _, m := range ti.Movements.all() {
+		if m.Type == MOVEMENT_RESERVE {
+			reserves++
+		}
+	}
+	if reserves != 2 {
+		t.Errorf("%d reserve movements, want 2", reserves)
+	}
+	checkBalance(t, ti, "SKU-1", "MAIN")
+	checkBalance(t, ti, "SKU-2", "MAIN")
+
+	// shipped twice : consumed once ; a late cancel changes nothing
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := ti.Service.ConsumeOrder(ctx, order, lines); err != nil {
+				t.Errorf("consume: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if err := ti.Service.ReleaseOrder(ctx, order); err != nil {
+		t.Fatal(err)
+	}
+	if bal := ti.Balances.get("SKU-1", "MAIN"); bal.OnHand != 17 || bal.Reserved != 0 {
+		t.Errorf("SKU-1 after ship %d/%d, want 17/0", bal.OnHand, bal.Reserved)
+	}
+	if bal := ti.Balances.get("SKU-2", "MAIN"); bal.OnHand != -5 || bal.Reserved != 0 {
+		t.Errorf("SKU-2 after ship %d/%d, want -5/0", bal.OnHand, bal.Reserved)
+	}
+	checkBalance(t, ti, "SKU-1", "MAIN")
+	checkBalance(t, ti, "SKU-2", "MAIN")
+}
+
+func TestReleaseOrderReplay(t *testing.T) {
+	ti := newTestInventory(t, 1000)
+	ctx := context.Background()
+	ti.receive(t, "SKU-1", "MAIN", 10)
+	order := OrderRef{Channel: dto.CHANNEL_LAZADA, ShopID: "1", OrderID: "B"}
+	if err := ti.Service.ReserveOrder(ctx, order, []OrderLine{{SKU: "SKU-1", Quantity: 4}}); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := ti.Service.ReleaseOrder(ctx, order); err != nil {
+				t.Errorf("release: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	// a cancelled order is not reserved again by a late READY_TO_SHIP push
+	if err := ti.Service.ReserveOrder(ctx, order, []OrderLine{{SKU: "SKU-1", Quantity: 4}}); err != nil {
+		t.Fatal(err)
+	}
+	if bal := ti.Balances.get("SKU-1", "MAIN"); bal.OnHand != 10 || bal.Reserved != 0 {
+		t.Errorf("after release %d/%d, want 10/0", bal.OnHand, bal.Reserved)
+	}
+	checkBalance(t, ti, "SKU-1", "MAIN")
+
+	// another channel's order with the same id is a different reservation
+	if err := ti.Service.ReserveOrder(ctx, OrderRef{Channel: dto.CHANNEL_SHOPEE, ShopID: "1", OrderID: "B"}, []OrderLine{{SKU: "SKU-1", Quantity: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if bal := ti.Balances.get("SKU-1", "MAIN"); bal.Reserved != 1 {
+		t.Errorf("reserved %d, want 1", bal.Reserved)
+	}
+}
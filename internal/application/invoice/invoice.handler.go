@@ -0,0 +1,250 @@
+package invoice
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	"ecommerce/internal/adapter"
+	"ecommerce/internal/adapter/dto"
+	"ecommerce/internal/application/marketplace"
+	"ecommerce/internal/delivery/http/response"
+)
+
+type IReqTaxProfile struct {
+	SellerName       string   `json:"seller_name" validate:"required,max=200"`
+	TaxID            string   `json:"tax_id" validate:"required,len=13,numeric"`
+	BranchCode       string   `json:"branch_code" validate:"omitempty,len=5,numeric"` // default 00000 (head office)
+	Address          string   `json:"address" validate:"required,max=500"`
+	Phone            string   `json:"phone" validate:"max=32"`
+	Email            string   `json:"email" validate:"omitempty,email"`
+	VATRate          *float64 `json:"vat_rate" validate:"required,gte=0,lte=100"`
+	PricesIncludeVAT bool     `json:"prices_include_vat"`
+}
+
+// overrides the order recipient ; tax id = full tax invoice for a VAT registered buyer
+type IReqDocumentBuyer struct {
+	Name       string `json:"name" validate:"max=200"`
+	TaxID      string `json:"tax_id" validate:"omitempty,len=13,numeric"`
+	BranchCode string `json:"branch_code" validate:"omitempty,len=5,numeric"` // default 00000 with a tax id
+	Address    string `json:"address" validate:"max=500"`
+	Phone      string `json:"phone" validate:"max=32"`
+}
+
+type IReqOrderDocument struct {
+	DocType DocumentTypeEnum   `json:"doc_type" validate:"required,oneof=TAX_INVOICE RECEIPT"`
+	Channel string             `json:"channel"` // default SHOPEE
+	ShopID  string             `json:"shop_id" validate:"required"`
+	OrderSN string             `json:"order_sn" validate:"required"` // channel order id
+	Buyer   *IReqDocumentBuyer `json:"buyer"`
+	// default : buyer paid shipping from the order (Shopee : escrow)
+	ShippingFee *float64 `json:"shipping_fee" validate:"omitempty,gte=0"`
+}
+
+type IReqCreditNote struct {
+	ShopID   string `json:"shop_id"`
+	ReturnSN string `json:"return_sn" validate:"required"`
+	Reason   string `json:"reason" validate:"max=500"` // default : the return reason
+}
+
+type IReqVoidDocument struct {
+	Reason string `json:"reason" validate:"required,max=500"`
+}
+
+type IReqTaxDocumentQuery struct {
+	DocType DocumentTypeEnum   `query:"doc_type"`
+	Status  DocumentStatusEnum `query:"status"`
+	ShopID  string             `query:"shop_id"`
+	OrderSN string             `query:"order_sn"`
+	Number  string             `query:"number"`
+	From    string             `query:"from"` // issue date YYYY-MM-DD, invoice timezone
+	To      string             `query:"to"`   // inclusive
+	Page    int                `query:"page"`
+	Size    int                `query:"size"`
+}
+
+type IInvoiceHandler interface {
+	GetTaxProfile(c *fiber.Ctx) error
+	PutTaxProfile(c *fiber.Ctx) error
+
+	GetDocuments(c *fiber.Ctx) error
+	GetDocumentByID(c *fiber.Ctx) error
+	PostOrderDocument(c *fiber.Ctx) error
+	PostCreditNote(c *fiber.Ctx) error
+	PostVoidDocument(c *fiber.Ctx) error
+	GetDocumentPDF(c *fiber.Ctx) error
+	GetDocumentXML(c *fiber.Ctx) error
+}
+
+type invoiceHandler struct {
+	Logger   *zap.Logger
+	Validate *validator.Validate
+	Service  IInvoiceService
+}
+
+func NewInvoiceHandler(log *zap.Logger, valid *validator.Validate, srv IInvoiceService) IInvoiceHandler {
+	return &invoiceHandler{
+		Logger:   log,
+		Validate: valid,
+		Service:  srv,
+	}
+}
+
+// actor : username set by the auth middleware
+func actor(c *fiber.Ctx) string {
+	if username, ok := c.Locals("username").(string); ok {
+		return username
+	}
+	return ""
+}
+
+func invoiceErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrTaxProfileNotFound), errors.Is(err, ErrDocumentNotFound), errors.Is(err, ErrReturnNotFound),
+		errors.Is(err, marketplace.ErrMarketplaceOrderNotFound):
+		return fiber.StatusNotFound
+	case errors.Is(err, ErrDuplicateDocument), errors.Is(err, ErrNumberingConflict), errors.Is(err, ErrDocumentHasCredit),
+		errors.Is(err, ErrCreditExceedsInvoice):
+		return fiber.StatusConflict
+	case errors.Is(err, ErrOrderNotInvoiceable), errors.Is(err, ErrReturnNotRefunded), errors.Is(err, ErrInvoiceNotIssued),
+		errors.Is(err, ErrNothingToInvoice):
+		return fiber.StatusUnprocessableEntity
+	}
+	return fiber.StatusBadRequest
+}
+
+// -- tax profile
+
+func (d *invoiceHandler) GetTaxProfile(c *fiber.Ctx) error {
+	res, err := d.Service.GetTaxProfile(c.Context())
+	if err != nil {
+		return response.ErrorResponse(c, invoiceErrorStatus(err), "handler.GetTaxProfile", err)
+	}
+	return response.SuccessResponse(c, "handler.GetTaxProfile", res)
+}
+
+func (d *invoiceHandler) PutTaxProfile(c *fiber.Ctx) error {
+	var reqBody IReqTaxProfile
+	if err := c.BodyParser(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PutTaxProfile", "invalid body")
+	}
+	if err := d.Validate.Struct(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PutTaxProfile", err)
+	}
+
+	res, err := d.Service.PutTaxProfile(c.Context(), actor(c), &reqBody)
+	if err != nil {
+		return response.ErrorResponse(c, fiber.StatusInternalServerError, "handler.PutTaxProfile", err)
+	}
+	return response.SuccessResponse(c, "handler.PutTaxProfile", res)
+}
+
+// -- documents
+
+func (d *invoiceHandler) GetDocuments(c *fiber.Ctx) error {
+	var query IReqTaxDocumentQuery
+	if err := c.QueryParser(&query); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.GetDocuments", "invalid query")
+	}
+
+	res, err := d.Service.GetDocuments(c.Context(), &query)
+	if err != nil {
+		return response.ErrorResponse(c, invoiceErrorStatus(err), "handler.GetDocuments", err)
+	}
+	return response.SuccessResponse(c, "handler.GetDocuments", res)
+}
+
+func (d *invoiceHandler) GetDocumentByID(c *fiber.Ctx) error {
+	res, err := d.Service.GetDocumentByID(c.Context(), c.Params("documentID"))
+	if err != nil {
+		return response.ErrorResponse(c, invoiceErrorStatus(err), "handler.GetDocumentByID", err)
+	}
+	return response.SuccessResponse(c, "handler.GetDocumentByID", res)
+}
+
+func (d *invoiceHandler) PostOrderDocument(c *fiber.Ctx) error {
+	var reqBody IReqOrderDocument
+	if err := c.BodyParser(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostOrderDocument", "invalid body")
+	}
+	if err := d.Validate.Struct(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostOrderDocument", err)
+	}
+	if reqBody.Channel == "" {
+		reqBody.Channel = string(dto.CHANNEL_SHOPEE)
+	}
+	channel, ok := adapter.ParseMarketplaceChannel(reqBody.Channel)
+	if !ok {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostOrderDocument", adapter.ErrMarketplaceUnsupported)
+	}
+	reqBody.Channel = string(channel)
+
+	res, err := d.Service.IssueOrderDocument(c.Context(), actor(c), &reqBody)
+	if err != nil {
+		return response.ErrorResponse(c, invoiceErrorStatus(err), "handler.PostOrderDocument", err)
+	}
+	return response.SuccessResponse(c, "handler.PostOrderDocument", res)
+}
+
+func (d *invoiceHandler) PostCreditNote(c *fiber.Ctx) error {
+	var reqBody IReqCreditNote
+	if err := c.BodyParser(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostCreditNote", "invalid body")
+	}
+	if err := d.Validate.Struct(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostCreditNote", err)
+	}
+
+	res, err := d.Service.IssueCreditNote(c.Context(), actor(c), &reqBody)
+	if err != nil {
+		return response.ErrorResponse(c, invoiceErrorStatus(err), "handler.PostCreditNote", err)
+	}
+	return response.SuccessResponse(c, "handler.PostCreditNote", res)
+}
+
+func (d *invoiceHandler) PostVoidDocument(c *fiber.Ctx) error {
+	var reqBody IReqVoidDocument
+	if err := c.BodyParser(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostVoidDocument", "invalid body")
+	}
+	if err := d.Validate.Struct(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostVoidDocument", err)
+	}
+
+	res, err := d.Service.VoidDocument(c.Context(), c.Params("documentID"), actor(c), &reqBody)
+	if err != nil {
+		return response.ErrorResponse(c, invoiceErrorStatus(err), "handler.PostVoidDocument", err)
+	}
+	return response.SuccessResponse(c, "handler.PostVoidDocument", res)
+}
+
+func (d *invoiceHandler) GetDocumentPDF(c *fiber.Ctx) error {
+	doc, data, err := d.Service.GetDocumentPDF(c.Context(), c.Params("documentID"))
+	if err != nil {
+		if errors.Is(err, ErrDocumentNotFound) {
+			return response.ErrorResponse(c, fiber.StatusNotFound, "handler.GetDocumentPDF", err)
+		}
+		d.Logger.Error("handler.GetDocumentPDF : GetDocumentPDF", zap.Error(err))
+		return response.ErrorResponse(c, fiber.StatusInternalServerError, "handler.GetDocumentPDF", err)
+	}
+	c.Set(fiber.HeaderContentType, "application/pdf")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`inline; filename="%s.pdf"`, doc.Number))
+	return c.Status(fiber.StatusOK).Send(data)
+}
+
+func (d *invoiceHandler) GetDocumentXML(c *fiber.Ctx) error {
+	doc, data, err := d.Service.GetDocumentXML(c.Context(), c.Params("documentID"))
+	if err != nil {
+		if errors.Is(err, ErrDocumentNotFound) {
+			return response.ErrorResponse(c, fiber.StatusNotFound, "handler.GetDocumentXML", err)
+		}
+		d.Logger.Error("handler.GetDocumentXML : GetDocumentXML", zap.Error(err))
+		return response.ErrorResponse(c, fiber.StatusInternalServerError, "handler.GetDocumentXML", err)
+	}
+	c.Set(fiber.HeaderContentType, "application/xml")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s.xml"`, doc.Number))
+	return c.Status(fiber.StatusOK).Send(data)
+}
@@ -0,0 +1,464 @@
+package invoice
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"ecommerce/internal/pkg"
+)
+
+const (
+	linesPerPage = 22
+	// A4 portrait content box (595 x 842 less 30pt margins), origin upper left
+	pageWidth  = 535
+	lineHeight = 18
+	maxNameLen = 60
+)
+
+type documentTitle struct {
+	Thai     string
+	English  string
+	TypeCode string // e-Tax document type code (ETDA)
+	Root     string // e-Tax root element
+}
+
+var documentTitles = map[DocumentTypeEnum]documentTitle{
+	DOC_TAX_INVOICE: {Thai: "ใบกำกับภาษี", English: "TAX INVOICE", TypeCode: "388", Root: "TaxInvoice"},
+	DOC_RECEIPT:     {Thai: "ใบเสร็จรับเงิน", English: "RECEIPT", TypeCode: "T01", Root: "Receipt"},
+	DOC_CREDIT_NOTE: {Thai: "ใบลดหนี้", English: "CREDIT NOTE", TypeCode: "81", Root: "CreditNote"},
+}
+
+// money : 1,234.50
+func money(v float64) string {
+	s := strconv.FormatFloat(v, 'f', 2, 64)
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	intPart, frac := s[:len(s)-3], s[len(s)-3:]
+	var b strings.Builder
+	for i, r := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(r)
+	}
+	return sign + b.String() + frac
+}
+
+// amount : e-Tax decimal, no grouping
+func amount(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
+
+func branchLabel(code string) string {
+	if code == "" || code == headOffice {
+		return "Head office"
+	}
+	return "Branch " + code
+}
+
+// wrap : lines of at most n runes, cut on spaces when possible (text boxes do not wrap)
+func wrap(s string, n int) []string {
+	out := []string{}
+	for _, para := range strings.Split(s, "\n") {
+		r := []rune(strings.TrimSpace(para))
+		for len(r) > n {
+			cut := n
+			for i := n; i > n/2; i-- {
+				if r[i] == ' ' {
+					cut = i
+					break
+				}
+			}
+			out = append(out, strings.TrimSpace(string(r[:cut])))
+			r = []rune(strings.TrimSpace(string(r[cut:])))
+		}
+		if len(r) > 0 {
+			out = append(out, string(r))
+		}
+	}
+	return out
+}
+
+func partyLines(p DocumentPartyModel) []string {
+	lines := []string{p.Name}
+	lines = append(lines, wrap(p.Address, maxNameLen)...)
+	if p.TaxID != "" {
+		lines = append(lines, fmt.Sprintf("Tax ID %s  %s", p.TaxID, branchLabel(p.BranchCode)))
+	}
+	if p.Phone != "" {
+		lines = append(lines, "Tel. "+p.Phone)
+	}
+	return lines
+}
+
+func (s *invoiceService) title(docType DocumentTypeEnum) string {
+	t := documentTitles[docType]
+	if s.ThaiText {
+		return t.Thai + " / " + t.English
+	}
+	return t.English
+}
+
+// textLines : one pdfcpu text box per line starting at top (from the upper left corner), returns the
+// y below the last line ; pdfcpu anchors text boxes and tables on their bottom edge
+func (s *invoiceService) textLines(texts *[]map[string]any, lines []string, x float64, top float64, size int, align string, col string) float64 {
+	leading := float64(size) * 1.4
+	y := top
+	for _, line := range lines {
+		y += leading
+		box := map[string]any{
+			"value": line,
+			"pos":   []float64{x, y},
+			"align": align,
+			"font":  map[string]any{"name": s.FontName, "size": size},
+		}
+		if col != "" {
+			box["font"].(map[string]any)["col"] = col
+		}
+		*texts = append(*texts, box)
+	}
+	return y
+}
+
+// renderPDF : pdfcpu JSON layout, lines split over pages, totals on the last one
+func (s *invoiceService) renderPDF(doc *TaxDocumentModel) ([]byte, error) {
+	header := []map[string]any{}
+	left := s.textLines(&header, partyLines(doc.Seller), 0, 0, 10, "left", "")
+
+	right := s.textLines(&header, []string{s.title(doc.DocType)}, pageWidth, 0, 16, "right", "")
+	info := []string{
+		"No. " + doc.Number,
+		"Date " + doc.IssueDate.In(s.Location).Format("02/01/2006"),
+		"Order " + doc.OrderSN,
+	}
+	right = s.textLines(&header, info, pageWidth, right+4, 10, "right", "")
+	if doc.Status == DOCUMENT_VOIDED {
+		right = s.textLines(&header, []string{"VOIDED " + truncate(doc.VoidReason, maxNameLen)}, pageWidth, right+4, 12, "right", "#C00000")
+	}
+
+	top := math.Max(left, right) + 16
+	left = s.textLines(&header, append([]string{"Customer"}, partyLines(doc.Buyer)...), 0, top, 10, "left", "")
+	right = top
+	if doc.DocType == DOC_CREDIT_NOTE {
+		ref := []string{
+			fmt.Sprintf("Tax invoice %s of %s", doc.RefNumber, doc.RefIssueDate.In(s.Location).Format("02/01/2006")),
+		}
+		ref = append(ref, wrap("Reason "+doc.Reason, maxNameLen/2)...)
+		right = s.textLines(&header, ref, pageWidth, top, 10, "right", "")
+	}
+	tableTop := math.Max(left, right) + 16
+
+	pages := map[string]any{}
+	pageCount := (len(doc.Lines) + linesPerPage - 1) / linesPerPage
+	if pageCount == 0 {
+		pageCount = 1
+	}
+	for p := 0; p < pageCount; p++ {
+		from, to := p*linesPerPage, (p+1)*linesPerPage
+		if to > len(doc.Lines) {
+			to = len(doc.Lines)
+		}
+		values := [][]string{}
+		for i := from; i < to; i++ {
+			l := doc.Lines[i]
+			values = append(values, []string{
+				strconv.Itoa(i + 1),
+				truncate(l.Name, maxNameLen),
+				strconv.FormatInt(l.Quantity, 10),
+				money(l.UnitPrice),
+				money(l.Amount),
+			})
+		}
+		tableBottom := tableTop + float64(len(values)+1)*lineHeight
+		table := map[string]any{
+			"values":     values,
+			"rows":       len(values),
+			"cols":       5,
+			"width":      pageWidth,
+			"pos":        []float64{0, tableBottom},
+			"lheight":    lineHeight,
+			"colWidths":  []int{6, 54, 8, 16, 16},
+			"colAnchors": []string{"Center", "Left", "Right", "Right", "Right"},
+			"font":       map[string]any{"name": s.FontName, "size": 9},
+			"grid":       true,
+			"header": map[string]any{
+				"values":     []string{"#", "Description", "Qty", "Unit price", "Amount"},
+				"colAnchors": []string{"Center", "Left", "Right", "Right", "Right"},
+				"bgCol":      "#E0E0E0",
+				"font":       map[string]any{"name": s.FontName, "size": 9},
+			},
+		}
+
+		texts := append([]map[string]any{}, header...)
+		if p == pageCount-1 {
+			s.textLines(&texts, totalLines(doc), pageWidth, tableBottom+8, 10, "right", "")
+		}
+		pages[strconv.Itoa(p+1)] = map[string]any{
+			"content": map[string]any{
+				"text":  texts,
+				"table": []map[string]any{table},
+			},
+		}
+	}
+
+	layout := map[string]any{
+		"paper":  "A4P",
+		"origin": "UpperLeft",
+		"margin": map[string]any{"width": 30},
+		"footer": map[string]any{
+			"font":   map[string]any{"name": s.FontName, "size": 8},
+			"left":   doc.Number,
+			"right":  "Page %p of %P",
+			"height": 20,
+		},
+		"pages": pages,
+	}
+	data, err := json.Marshal(layout)
+	if err != nil {
+		return nil, err
+	}
+	return pkg.CreatePDF(data)
+}
+
+func totalLines(doc *TaxDocumentModel) []string {
+	rows := []string{}
+	if doc.DocType == DOC_CREDIT_NOTE {
+		rows = append(rows,
+			fmt.Sprintf("Original value  %s", money(doc.RefTotalAmount)),
+			fmt.Sprintf("Correct value  %s", money(doc.RefTotalAmount-doc.TotalAmount)),
+			fmt.Sprintf("Difference  %s", money(doc.TotalAmount)),
+		)
+	}
+	vatLabel := fmt.Sprintf("VAT %s%%", strconv.FormatFloat(doc.VATRate, 'f', -1, 64))
+	if doc.PricesIncludeVAT {
+		vatLabel += " (included)"
+	}
+	return append(rows,
+		fmt.Sprintf("Amount before VAT  %s", money(doc.TaxableAmount)),
+		fmt.Sprintf("%s  %s", vatLabel, money(doc.VATAmount)),
+		fmt.Sprintf("Total %s  %s", doc.Currency, money(doc.TotalAmount)),
+	)
+}
+
+// -- e-Tax XML : ETDA cross industry invoice structure (ขมธอ. 3-2560), unsigned ;
+// the e-Tax service provider adds the XAdES signature before filing with the Revenue Department
+
+const (
+	etaxGuideline = "ER3-2560"
+	ramNamespace  = "urn:etda:uncefact:data:standard:%s_ReusableAggregateBusinessInformationEntity:2"
+	rsmNamespace  = "urn:etda:uncefact:data:standard:%s_CrossIndustryInvoice:2"
+)
+
+type etaxSchemeID struct {
+	SchemeID string `xml:"schemeID,attr,omitempty"`
+	Value    string `xml:",chardata"`
+}
+
+type etaxAmount struct {
+	CurrencyID string `xml:"currencyID,attr,omitempty"`
+	Value      string `xml:",chardata"`
+}
+
+type etaxDocument struct {
+	XMLName  xml.Name
+	XmlnsRsm string `xml:"xmlns:rsm,attr"`
+	XmlnsRam string `xml:"xmlns:ram,attr"`
+	Context  struct {
+		Guideline struct {
+			ID struct {
+				Agency  string `xml:"schemeAgencyID,attr"`
+				Version string `xml:"schemeVersionID,attr"`
+				Value   string `xml:",chardata"`
+			} `xml:"ram:ID"`
+		} `xml:"ram:GuidelineSpecifiedDocumentContextParameter"`
+	} `xml:"rsm:ExchangedDocumentContext"`
+	Document struct {
+		ID            string `xml:"ram:ID"`
+		Name          string `xml:"ram:Name"`
+		TypeCode      string `xml:"ram:TypeCode"`
+		IssueDateTime string `xml:"ram:IssueDateTime"`
+		Purpose       string `xml:"ram:Purpose,omitempty"`
+		CreationDate  string `xml:"ram:CreationDateTime"`
+	} `xml:"rsm:ExchangedDocument"`
+	Transaction etaxTransaction `xml:"rsm:SupplyChainTradeTransaction"`
+}
+
+type etaxParty struct {
+	Name         string       `xml:"ram:Name"`
+	Registration etaxSchemeID `xml:"ram:SpecifiedTaxRegistration>ram:ID"`
+	Address      struct {
+		LineOne   string       `xml:"ram:LineOne,omitempty"`
+		CountryID etaxSchemeID `xml:"ram:CountryID"`
+	} `xml:"ram:PostalTradeAddress"`
+}
+
+type etaxReference struct {
+	IssuerAssignedID  string `xml:"ram:IssuerAssignedID"`
+	IssueDateTime     string `xml:"ram:IssueDateTime"`
+	ReferenceTypeCode string `xml:"ram:ReferenceTypeCode"`
+}
+
+type etaxTax struct {
+	TypeCode         string     `xml:"ram:TypeCode"`
+	CalculatedRate   string     `xml:"ram:CalculatedRate"`
+	BasisAmount      etaxAmount `xml:"ram:BasisAmount"`
+	CalculatedAmount etaxAmount `xml:"ram:CalculatedAmount"`
+}
+
+// lines carry the rate only : VAT is rounded once on the document total
+type etaxLineTax struct {
+	TypeCode       string `xml:"ram:TypeCode"`
+	CalculatedRate string `xml:"ram:CalculatedRate"`
+}
+
+type etaxLine struct {
+	LineID  string `xml:"ram:AssociatedDocumentLineDocument>ram:LineID"`
+	Product struct {
+		ID   string `xml:"ram:ID,omitempty"`
+		Name string `xml:"ram:Name"`
+	} `xml:"ram:SpecifiedTradeProduct"`
+	Price    etaxAmount `xml:"ram:SpecifiedLineTradeAgreement>ram:GrossPriceProductTradePrice>ram:ChargeAmount"`
+	Quantity struct {
+		UnitCode string `xml:"unitCode,attr"`
+		Value    string `xml:",chardata"`
+	} `xml:"ram:SpecifiedLineTradeDelivery>ram:BilledQuantity"`
+	Settlement struct {
+		Tax     etaxLineTax `xml:"ram:ApplicableTradeTax"`
+		NetLine etaxAmount  `xml:"ram:SpecifiedTradeSettlementLineMonetarySummation>ram:NetLineTotalAmount"`
+	} `xml:"ram:SpecifiedLineTradeSettlement"`
+}
+
+type etaxTransaction struct {
+	Agreement struct {
+		Seller     etaxParty      `xml:"ram:SellerTradeParty"`
+		Buyer      etaxParty      `xml:"ram:BuyerTradeParty"`
+		BuyerOrder string         `xml:"ram:BuyerOrderReferencedDocument>ram:IssuerAssignedID,omitempty"`
+		Reference  *etaxReference `xml:"ram:AdditionalReferencedDocument,omitempty"`
+	} `xml:"ram:ApplicableHeaderTradeAgreement"`
+	Delivery   struct{} `xml:"ram:ApplicableHeaderTradeDelivery"`
+	Settlement struct {
+		Currency struct {
+			ListID string `xml:"listID,attr"`
+			Value  string `xml:",chardata"`
+		} `xml:"ram:InvoiceCurrencyCode"`
+		Tax     etaxTax `xml:"ram:ApplicableTradeTax"`
+		Summary struct {
+			Original   *etaxAmount `xml:"ram:OriginalInformationAmount,omitempty"`
+			LineTotal  etaxAmount  `xml:"ram:LineTotalAmount"`
+			Difference *etaxAmount `xml:"ram:DifferenceInformationAmount,omitempty"`
+			TaxBasis   etaxAmount  `xml:"ram:TaxBasisTotalAmount"`
+			TaxTotal   etaxAmount  `xml:"ram:TaxTotalAmount"`
+			GrandTotal etaxAmount  `xml:"ram:GrandTotalAmount"`
+		} `xml:"ram:SpecifiedTradeSettlementHeaderMonetarySummation"`
+	} `xml:"ram:ApplicableHeaderTradeSettlement"`
+	Lines []etaxLine `xml:"ram:IncludedSupplyChainTradeLineItem"`
+}
+
+func etaxPartyOf(p DocumentPartyModel) etaxParty {
+	party := etaxParty{Name: p.Name}
+	// TXID = tax id followed by the 5 digit branch ; buyers without a tax id : OTHR / N/A
+	if p.TaxID != "" {
+		branch := p.BranchCode
+		if branch == "" {
+			branch = headOffice
+		}
+		party.Registration = etaxSchemeID{SchemeID: "TXID", Value: p.TaxID + branch}
+	} else {
+		party.Registration = etaxSchemeID{SchemeID: "OTHR", Value: "N/A"}
+	}
+	party.Address.LineOne = p.Address
+	party.Address.CountryID = etaxSchemeID{SchemeID: "3166-1 alpha-2", Value: "TH"}
+	return party
+}
+
+// etaxDateTime : local time of the invoice timezone, no offset
+func (s *invoiceService) etaxDateTime(t time.Time) string {
+	return t.In(s.Location).Format("2006-01-02T15:04:05")
+}
+
+func (s *invoiceService) renderXML(doc *TaxDocumentModel) ([]byte, error) {
+	title := documentTitles[doc.DocType]
+	currency := doc.Currency
+	if currency == "" {
+		currency = "THB"
+	}
+	amt := func(v float64) etaxAmount { return etaxAmount{CurrencyID: currency, Value: amount(v)} }
+	rate := strconv.FormatFloat(doc.VATRate, 'f', 2, 64)
+
+	out := etaxDocument{
+		XMLName:  xml.Name{Local: "rsm:" + title.Root + "_CrossIndustryInvoice"},
+		XmlnsRsm: fmt.Sprintf(rsmNamespace, title.Root),
+		XmlnsRam: fmt.Sprintf(ramNamespace, title.Root),
+	}
+	out.Context.Guideline.ID.Agency = "ETDA"
+	out.Context.Guideline.ID.Version = "v2.0"
+	out.Context.Guideline.ID.Value = etaxGuideline
+	out.Document.ID = doc.Number
+	out.Document.Name = title.Thai
+	out.Document.TypeCode = title.TypeCode
+	out.Document.IssueDateTime = s.etaxDateTime(doc.IssueDate)
+	out.Document.CreationDate = s.etaxDateTime(doc.CreatedAt)
+	if doc.Status == DOCUMENT_VOIDED {
+		out.Document.Purpose = "VOIDED " + doc.VoidReason
+	} else if doc.DocType == DOC_CREDIT_NOTE {
+		out.Document.Purpose = doc.Reason
+	}
+
+	tr := &out.Transaction
+	tr.Agreement.Seller = etaxPartyOf(doc.Seller)
+	tr.Agreement.Buyer = etaxPartyOf(doc.Buyer)
+	tr.Agreement.BuyerOrder = doc.OrderSN
+	if doc.DocType == DOC_CREDIT_NOTE {
+		tr.Agreement.Reference = &etaxReference{
+			IssuerAssignedID:  doc.RefNumber,
+			IssueDateTime:     s.etaxDateTime(doc.RefIssueDate),
+			ReferenceTypeCode: documentTitles[DOC_TAX_INVOICE].TypeCode,
+		}
+	}
+
+	tr.Settlement.Currency.ListID = "ISO 4217 3A"
+	tr.Settlement.Currency.Value = currency
+	tr.Settlement.Tax = etaxTax{TypeCode: "VAT", CalculatedRate: rate, BasisAmount: amt(doc.TaxableAmount), CalculatedAmount: amt(doc.VATAmount)}
+	sum := &tr.Settlement.Summary
+	sum.LineTotal = amt(doc.TaxableAmount)
+	sum.TaxBasis = amt(doc.TaxableAmount)
+	sum.TaxTotal = amt(doc.VATAmount)
+	sum.GrandTotal = amt(doc.TotalAmount)
+	if doc.DocType == DOC_CREDIT_NOTE {
+		original, difference := amt(doc.RefTotalAmount), amt(doc.TotalAmount)
+		sum.Original, sum.Difference = &original, &difference
+	}
+
+	for i, l := range doc.Lines {
+		line := etaxLine{LineID: strconv.Itoa(i + 1)}
+		line.Product.ID = l.SKU
+		line.Product.Name = l.Name
+		line.Price = amt(l.UnitPrice)
+		line.Quantity.UnitCode = "EA"
+		line.Quantity.Value = strconv.FormatInt(l.Quantity, 10)
+		line.Settlement.Tax = etaxLineTax{TypeCode: "VAT", CalculatedRate: rate}
+		line.Settlement.NetLine = amt(l.Amount)
+		tr.Lines = append(tr.Lines, line)
+	}
+
+	data, err := xml.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
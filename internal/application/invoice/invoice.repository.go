@@ -0,0 +1,425 @@
+package invoice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"go.uber.org/zap"
+
+	"ecommerce/internal/adapter/dto"
+	"ecommerce/internal/pkg"
+)
+
+type DocumentTypeEnum string
+
+const (
+	DOC_TAX_INVOICE DocumentTypeEnum = "TAX_INVOICE"
+	DOC_RECEIPT     DocumentTypeEnum = "RECEIPT"
+	// reduces a tax invoice, issued for a refunded return
+	DOC_CREDIT_NOTE DocumentTypeEnum = "CREDIT_NOTE"
+)
+
+// number prefixes : INV-202610-00001
+var documentPrefix = map[DocumentTypeEnum]string{
+	DOC_TAX_INVOICE: "INV",
+	DOC_RECEIPT:     "RC",
+	DOC_CREDIT_NOTE: "CN",
+}
+
+type DocumentStatusEnum string
+
+const (
+	DOCUMENT_ISSUED DocumentStatusEnum = "ISSUED"
+	// cancelled : keeps its number (never reused), the source may be issued again
+	DOCUMENT_VOIDED DocumentStatusEnum = "VOIDED"
+)
+
+var (
+	ErrTaxProfileNotFound = errors.New("tax profile not set for this tenant")
+	ErrDocumentNotFound   = errors.New("tax document not found")
+	ErrDuplicateDocument  = errors.New("document already issued for this source, void it first")
+	ErrNumberingConflict  = errors.New("could not allocate a document number, retry")
+)
+
+// TaxProfileModel : the seller printed on every document of the tenant
+type TaxProfileModel struct {
+	ID         bson.ObjectID `bson:"_id"`
+	TenantID   string        `bson:"tenant_id,omitempty"` // missing = platform tenant
+	SellerName string        `bson:"seller_name"`
+	TaxID      string        `bson:"tax_id"`      // 13 digits
+	BranchCode string        `bson:"branch_code"` // 00000 = head office
+	Address    string        `bson:"address"`
+	Phone      string        `bson:"phone"`
+	Email      string        `bson:"email"`
+	VATRate    float64       `bson:"vat_rate"` // percent
+	// marketplace prices are what the buyer pays : VAT is extracted from them instead of added on top
+	PricesIncludeVAT bool      `bson:"prices_include_vat"`
+	UpdatedAt        time.Time `bson:"updated_at"`
+	UpdatedBy        string    `bson:"updated_by"`
+}
+
+type DocumentPartyModel struct {
+	Name       string `bson:"name"`
+	TaxID      string `bson:"tax_id"`
+	BranchCode string `bson:"branch_code"`
+	Address    string `bson:"address"`
+	Phone      string `bson:"phone"`
+}
+
+// amounts as charged : VAT included when the document is VAT inclusive
+type DocumentLineModel struct {
+	SKU       string  `bson:"sku"`
+	Name      string  `bson:"name"`
+	Quantity  int64   `bson:"quantity"`
+	UnitPrice float64 `bson:"unit_price"`
+	Amount    float64 `bson:"amount"`
+}
+
+// TaxDocumentModel : issued documents are never deleted nor renumbered, only voided
+type TaxDocumentModel struct {
+	ID       bson.ObjectID      `bson:"_id"`
+	TenantID string             `bson:"tenant_id,omitempty"` // missing = platform tenant
+	DocType  DocumentTypeEnum   `bson:"doc_type"`
+	Number   string             `bson:"number"`
+	Period   string             `bson:"period"` // YYYYMM of the issue date
+	Seq      int64              `bson:"seq"`
+	Status   DocumentStatusEnum `bson:"status"`
+	// what the document was issued for (ORDER:<shop>:<order_sn> for Shopee, ORDER:<channel>:<shop>:<order_id>,
+	// RETURN:<shop>:<return_sn>) : one ISSUED per source
+	SourceKey string                     `bson:"source_key"`
+	Channel   dto.MarketplaceChannelEnum `bson:"channel,omitempty"` // missing = SHOPEE
+	ShopID    string                     `bson:"shop_id"`
+	OrderSN   string                     `bson:"order_sn"`
+	ReturnSN  string                     `bson:"return_sn"`
+	IssueDate time.Time                  `bson:"issue_date"`
+
+	Seller           DocumentPartyModel  `bson:"seller"`
+	Buyer            DocumentPartyModel  `bson:"buyer"`
+	Currency         string              `bson:"currency"`
+	VATRate          float64             `bson:"vat_rate"`
+	PricesIncludeVAT bool                `bson:"prices_include_vat"`
+	Lines            []DocumentLineModel `bson:"lines"`
+	Subtotal         float64             `bson:"subtotal"`       // sum of the lines
+	TaxableAmount    float64             `bson:"taxable_amount"` // before VAT
+	VATAmount        float64             `bson:"vat_amount"`
+	TotalAmount      float64             `bson:"total_amount"`
+
+	// credit note : the tax invoice it corrects
+	RefDocumentID  bson.ObjectID `bson:"ref_document_id,omitempty"`
+	RefNumber      string        `bson:"ref_number,omitempty"`
+	RefIssueDate   time.Time     `bson:"ref_issue_date,omitempty"`
+	RefTotalAmount float64       `bson:"ref_total_amount,omitempty"`
+	Reason         string        `bson:"reason"`
+
+	// rendered files in the blob store, "" until rendered
+	PdfKey string `bson:"pdf_key"`
+	XmlKey string `bson:"xml_key"`
+
+	VoidReason string    `bson:"void_reason,omitempty"`
+	VoidedAt   time.Time `bson:"voided_at,omitempty"`
+	VoidedBy   string    `bson:"voided_by,omitempty"`
+	CreatedAt  time.Time `bson:"created_at"`
+	CreatedBy  string    `bson:"created_by"`
+}
+
+type TaxDocumentFilter struct {
+	DocType       DocumentTypeEnum
+	Status        DocumentStatusEnum
+	ShopID        string
+	OrderSN       string
+	Number        string
+	RefDocumentID bson.ObjectID // zero = any
+	From          time.Time     // issue date, zero = open
+	To            time.Time
+	Skip          int64
+	Limit         int64 // 0 = no limit
+}
+
+type TaxProfileRepository interface {
+	InitRepository() error
+	GetTaxProfile(ctx context.Context) (*TaxProfileModel, error)
+	UpsertTaxProfile(ctx context.Context, profile *TaxProfileModel) (*TaxProfileModel, error)
+}
+
+type TaxDocumentRepository interface {
+	InitRepository() error
+	// sets Seq / Number to the next free number of (tenant, type, period) and inserts :
+	// ErrDuplicateDocument when the source already has an ISSUED document
+	IssueDocument(ctx context.Context, doc *TaxDocumentModel, maxRetries int) (*TaxDocumentModel, error)
+	GetDocumentByID(ctx context.Context, id string) (*TaxDocumentModel, error)
+	GetIssuedDocumentBySource(ctx context.Context, docType DocumentTypeEnum, sourceKey string) (*TaxDocumentModel, error)
+	// newest issue date first
+	GetDocuments(ctx context.Context, filter *TaxDocumentFilter) ([]TaxDocumentModel, error)
+	SetDocumentFiles(ctx context.Context, id bson.ObjectID, pdfKey string, xmlKey string) error
+	// ISSUED -> VOIDED, ErrDocumentNotFound when not ISSUED anymore
+	VoidDocument(ctx context.Context, id bson.ObjectID, reason string, by string) (*TaxDocumentModel, error)
+}
+
+type taxProfileRepository struct {
+	Logger *zap.Logger
+	DB     *mongo.Collection
+}
+
+func NewTaxProfileRepository(db *mongo.Collection, log *zap.Logger) TaxProfileRepository {
+	return &taxProfileRepository{Logger: log, DB: db}
+}
+
+func (r *taxProfileRepository) InitRepository() error {
+	indexs := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+	}
+	if _, err := r.DB.Indexes().CreateMany(context.TODO(), indexs); err != nil {
+		return errors.New("TaxProfileRepository.InitRepository: failed create indexs")
+	}
+	// the platform tenant was first stored as "default" : moved to the missing tenant_id the filters expect
+	if _, err := r.DB.UpdateMany(context.TODO(), bson.M{"tenant_id": pkg.DEFAULT_TENANT}, bson.M{"$unset": bson.M{"tenant_id": ""}}); err != nil {
+		return errors.New("TaxProfileRepository.InitRepository: failed migrate platform tenant")
+	}
+	r.Logger.Info("TaxProfileRepository.InitRepository: index created")
+	return nil
+}
+
+func (r *taxProfileRepository) GetTaxProfile(ctx context.Context) (*TaxProfileModel, error) {
+	var model TaxProfileModel
+	if err := r.DB.FindOne(ctx, pkg.TenantFilter(ctx, bson.M{})).Decode(&model); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrTaxProfileNotFound
+		}
+		return nil, err
+	}
+	return &model, nil
+}
+
+func (r *taxProfileRepository) UpsertTaxProfile(ctx context.Context, profile *TaxProfileModel) (*TaxProfileModel, error) {
+	tenantID, err := pkg.TenantStamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	onInsert := bson.M{"_id": bson.NewObjectID()}
+	if tenantID != "" {
+		onInsert["tenant_id"] = tenantID
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"seller_name":        profile.SellerName,
+			"tax_id":             profile.TaxID,
+			"branch_code":        profile.BranchCode,
+			"address":            profile.Address,
+			"phone":              profile.Phone,
+			"email":              profile.Email,
+			"vat_rate":           profile.VATRate,
+			"prices_include_vat": profile.PricesIncludeVAT,
+			"updated_at":         profile.UpdatedAt,
+			"updated_by":         profile.UpdatedBy,
+		},
+		"$setOnInsert": onInsert,
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	var model TaxProfileModel
+	if err := r.DB.FindOneAndUpdate(ctx, pkg.TenantFilter(ctx, bson.M{}), update, opts).Decode(&model); err != nil {
+		return nil, err
+	}
+	return &model, nil
+}
+
+type taxDocumentRepository struct {
+	Logger *zap.Logger
+	DB     *mongo.Collection
+}
+
+func NewTaxDocumentRepository(db *mongo.Collection, log *zap.Logger) TaxDocumentRepository {
+	return &taxDocumentRepository{Logger: log, DB: db}
+}
+
+func (r *taxDocumentRepository) InitRepository() error {
+	indexs := []mongo.IndexModel{
+		// the running number itself : two writers picking the same seq -> one insert fails and retries
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "doc_type", Value: 1}, {Key: "period", Value: 1}, {Key: "seq", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{
+			Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "doc_type", Value: 1}, {Key: "source_key", Value: 1}},
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"status": DOCUMENT_ISSUED}).
+				SetName("issued_source_unique"),
+		},
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "number", Value: 1}}},
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "shop_id", Value: 1}, {Key: "order_sn", Value: 1}}},
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "ref_document_id", Value: 1}}},
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "issue_date", Value: -1}}},
+	}
+	if _, err := r.DB.Indexes().CreateMany(context.TODO(), indexs); err != nil {
+		return errors.New("TaxDocumentRepository.InitRepository: failed create indexs")
+	}
+	// the platform tenant was first stored as "default" : moved to the missing tenant_id the filters expect
+	if _, err := r.DB.UpdateMany(context.TODO(), bson.M{"tenant_id": pkg.DEFAULT_TENANT}, bson.M{"$unset": bson.M{"tenant_id": ""}}); err != nil {
+		return errors.New("TaxDocumentRepository.InitRepository: failed migrate platform tenant")
+	}
+	r.Logger.Info("TaxDocumentRepository.InitRepository: index created")
+	return nil
+}
+
+// Numbers are taken from the documents themselves (last seq + 1) rather than from a counter : a
+// number exists only once its document is stored, so a failed or abandoned issue leaves no gap.
+// Concurrent issuers computing the same seq collide on the unique index and try the next one.
+func (r *taxDocumentRepository) IssueDocument(ctx context.Context, doc *TaxDocumentModel, maxRetries int) (*TaxDocumentModel, error) {
+	return issueNumbered(ctx, r, r.Logger, doc, maxRetries)
+}
+
+// documentNumbering : what the numbering loop needs from the collection, scoped to the tenant of ctx ;
+// inserts fail with a duplicate key error on the (tenant, type, period, seq) and issued source unique indexes
+type documentNumbering interface {
+	lastSeq(ctx context.Context, docType DocumentTypeEnum, period string) (int64, error)
+	insertDocument(ctx context.Context, doc *TaxDocumentModel) error
+	GetIssuedDocumentBySource(ctx context.Context, docType DocumentTypeEnum, sourceKey string) (*TaxDocumentModel, error)
+}
+
+func issueNumbered(ctx context.Context, store documentNumbering, log *zap.Logger, doc *TaxDocumentModel, maxRetries int) (*TaxDocumentModel, error) {
+	if maxRetries <= 0 {
+		maxRetries = 1
+	}
+	tenantID, err := pkg.TenantStamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	doc.TenantID = tenantID
+	for attempt := 0; attempt < maxRetries; attempt++ {
+		last, err := store.lastSeq(ctx, doc.DocType, doc.Period)
+		if err != nil {
+			return nil, err
+		}
+		doc.ID = bson.NewObjectID()
+		doc.Seq = last + 1
+		doc.Number = fmt.Sprintf("%s-%s-%05d", documentPrefix[doc.DocType], doc.Period, doc.Seq)
+
+		err = store.insertDocument(ctx, doc)
+		if err == nil {
+			return doc, nil
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			return nil, err
+		}
+		if _, err := store.GetIssuedDocumentBySource(ctx, doc.DocType, doc.SourceKey); err == nil {
+			return nil, ErrDuplicateDocument
+		}
+		log.Warn("TaxDocumentRepository.IssueDocument: number taken, retry", zap.String("number", doc.Number), zap.Int("attempt", attempt+1))
+	}
+	return nil, ErrNumberingConflict
+}
+
+func (r *taxDocumentRepository) insertDocument(ctx context.Context, doc *TaxDocumentModel) error {
+	_, err := r.DB.InsertOne(ctx, doc)
+	return err
+}
+
+func (r *taxDocumentRepository) lastSeq(ctx context.Context, docType DocumentTypeEnum, period string) (int64, error) {
+	opts := options.FindOne().SetSort(bson.D{{Key: "seq", Value: -1}}).SetProjection(bson.M{"seq": 1})
+	var doc struct {
+		Seq int64 `bson:"seq"`
+	}
+	err := r.DB.FindOne(ctx, pkg.TenantFilter(ctx, bson.M{"doc_type": docType, "period": period}), opts).Decode(&doc)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return doc.Seq, nil
+}
+
+func (r *taxDocumentRepository) GetDocumentByID(ctx context.Context, id string) (*TaxDocumentModel, error) {
+	oid, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrDocumentNotFound
+	}
+	return r.findOne(ctx, pkg.TenantFilter(ctx, bson.M{"_id": oid}))
+}
+
+func (r *taxDocumentRepository) GetIssuedDocumentBySource(ctx context.Context, docType DocumentTypeEnum, sourceKey string) (*TaxDocumentModel, error) {
+	return r.findOne(ctx, pkg.TenantFilter(ctx, bson.M{"doc_type": docType, "source_key": sourceKey, "status": DOCUMENT_ISSUED}))
+}
+
+func (r *taxDocumentRepository) findOne(ctx context.Context, filter bson.M) (*TaxDocumentModel, error) {
+	var model TaxDocumentModel
+	if err := r.DB.FindOne(ctx, filter).Decode(&model); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrDocumentNotFound
+		}
+		return nil, err
+	}
+	return &model, nil
+}
+
+func (r *taxDocumentRepository) GetDocuments(ctx context.Context, filter *TaxDocumentFilter) ([]TaxDocumentModel, error) {
+	query := pkg.TenantFilter(ctx, bson.M{})
+	if filter.DocType != "" {
+		query["doc_type"] = filter.DocType
+	}
+	if filter.Status != "" {
+		query["status"] = filter.Status
+	}
+	if filter.ShopID != "" {
+		query["shop_id"] = filter.ShopID
+	}
+	if filter.OrderSN != "" {
+		query["order_sn"] = filter.OrderSN
+	}
+	if filter.Number != "" {
+		query["number"] = filter.Number
+	}
+	if !filter.RefDocumentID.IsZero() {
+		query["ref_document_id"] = filter.RefDocumentID
+	}
+	if !filter.From.IsZero() || !filter.To.IsZero() {
+		date := bson.M{}
+		if !filter.From.IsZero() {
+			date["$gte"] = filter.From
+		}
+		if !filter.To.IsZero() {
+			date["$lt"] = filter.To
+		}
+		query["issue_date"] = date
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "issue_date", Value: -1}, {Key: "_id", Value: -1}}).SetSkip(filter.Skip)
+	if filter.Limit > 0 {
+		opts.SetLimit(filter.Limit)
+	}
+	cursor, err := r.DB.Find(ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	docs := []TaxDocumentModel{}
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
+
+func (r *taxDocumentRepository) SetDocumentFiles(ctx context.Context, id bson.ObjectID, pdfKey string, xmlKey string) error {
+	_, err := r.DB.UpdateOne(ctx, pkg.TenantFilter(ctx, bson.M{"_id": id}), bson.M{"$set": bson.M{"pdf_key": pdfKey, "xml_key": xmlKey}})
+	return err
+}
+
+func (r *taxDocumentRepository) VoidDocument(ctx context.Context, id bson.ObjectID, reason string, by string) (*TaxDocumentModel, error) {
+	update := bson.M{"$set": bson.M{
+		"status":      DOCUMENT_VOIDED,
+		"void_reason": reason,
+		"voided_at":   time.Now(),
+		"voided_by":   by,
+	}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var model TaxDocumentModel
+	err := r.DB.FindOneAndUpdate(ctx, pkg.TenantFilter(ctx, bson.M{"_id": id, "status": DOCUMENT_ISSUED}), update, opts).Decode(&model)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrDocumentNotFound
+		}
+		return nil, err
+	}
+	return &model, nil
+}
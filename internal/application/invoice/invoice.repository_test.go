@@ -0,0 +1,249 @@
+package invoice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.uber.org/zap"
+
+	"ecommerce/internal/pkg"
+)
+
+// memoryDocuments : the tax_document collection with its two unique indexes, read in the tenant of ctx
+type memoryDocuments struct {
+	mu   sync.Mutex
+	docs []TaxDocumentModel
+}
+
+var errDuplicateKey = mongo.WriteException{WriteErrors: []mongo.WriteError{{Code: 11000, Message: "E11000 duplicate key error"}}}
+
+func tenantOf(ctx context.Context) string {
+	tenantID, _ := pkg.TenantStamp(ctx)
+	return tenantID
+}
+
+func (m *memoryDocuments) lastSeq(ctx context.Context, docType DocumentTypeEnum, period string) (int64, error) {
+	tenantID := tenantOf(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var last int64
+	for _, d := range m.docs {
+		if d.TenantID == tenantID && d.DocType == docType && d.Period == period && d.Seq > last {
+			last = d.Seq
+		}
+	}
+	return last, nil
+}
+
+func (m *memoryDocuments) insertDocument(ctx context.Context, doc *TaxDocumentModel) error {
+	// let the other issuers read the same last seq
+	runtime.Gosched()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, d := range m.docs {
+		if d.TenantID != doc.TenantID || d.DocType != doc.DocType {
+			continue
+		}
+		if d.Period == doc.Period && d.Seq == doc.Seq {
+			return errDuplicateKey
+		}
+		if d.Status == DOCUMENT_ISSUED && doc.Status == DOCUMENT_ISSUED && d.SourceKey == doc.SourceKey {
+			return errDuplicateKey
+		}
+	}
+	m.docs = append(m.docs, *doc)
+	return nil
+}
+
+func (m *memoryDocuments) GetIssuedDocumentBySource(ctx context.Context, docType DocumentTypeEnum, sourceKey string) (*TaxDocumentModel, error) {
+	tenantID := tenantOf(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, d := range m.docs {
+		if d.TenantID == tenantID && d.DocType == docType && d.SourceKey == sourceKey && d.Status == DOCUMENT_ISSUED {
+			c := d
+			return &c, nil
+		}
+	}
+	return nil, ErrDocumentNotFound
+}
+
+func testDocument(docType DocumentTypeEnum, period string, source string) *TaxDocumentModel {
+	return &TaxDocumentModel{DocType: docType, Period: period, Status: DOCUMENT_ISSUED, SourceKey: source}
+}
+
+func TestIssueDocumentConcurrentNumbering(t *testing.T) {
+	store := &memoryDocuments{}
+	ctx := context.Background()
+	const issuers = 50
+
+	type issued struct {
+		tenant string
+		number string
+		seq    int64
+	}
+	results := make(chan issued, 2*issuers)
+	var wg sync.WaitGroup
+	for _, tenantID := range []string{"t1", "t2"} {
+		for i := 0; i < issuers; i++ {
+			wg.Add(1)
+			go func(tenantID string, i int) {
+				defer wg.Done()
+				// each failed attempt means another issuer stored that seq : issuers retries always suffice
+				doc, err := issueNumbered(pkg.WithTenant(ctx, tenantID), store, zap.NewNop(), testDocument(DOC_TAX_INVOICE, "202610", fmt.Sprintf("ORDER:1:%d", i)), issuers)
+				if err != nil {
+					t.Errorf("issue %s %d: %v", tenantID, i, err)
+					return
+				}
+				results <- issued{tenantID, doc.Number, doc.Seq}
+			}(tenantID, i)
+		}
+	}
+	wg.Wait()
+	close(results)
+
+	seqs := map[string]map[int64]bool{}
+	numbers := map[string]bool{}
+	for r := range results {
+		if seqs[r.tenant] == nil {
+			seqs[r.tenant] = map[int64]bool{}
+		}
+		if seqs[r.tenant][r.seq] {
+			t.Errorf("%s: seq %d issued twice", r.tenant, r.seq)
+		}
+		seqs[r.tenant][r.seq] = true
+		if want := fmt.Sprintf("INV-202610-%05d", r.seq); r.number != want {
+			t.Errorf("number %q, want %q", r.number, want)
+		}
+		numbers[r.tenant+r.number] = true
+	}
+	for _, tenantID := range []string{"t1", "t2"} {
+		if len(seqs[tenantID]) != issuers {
+			t.Fatalf("%s: %d documents issued, want %d", tenantID, len(seqs[tenantID]), issuers)
+		}
+		// contiguous : 1..issuers with nothing skipped
+		for seq := int64(1); seq <= issuers; seq++ {
+			if !seqs[tenantID][seq] {
+				t.Errorf("%s: seq %d missing", tenantID, seq)
+			}
+		}
+	}
+}
+
+func TestIssueDocumentSequencePerTypeAndPeriod(t *testing.T) {
+	store := &memoryDocuments{}
+	ctx := pkg.WithTenant(context.Background(), "t1")
+	issue := func(docType DocumentTypeEnum, period string, source string) string {
+		t.Helper()
+		doc, err := issueNumbered(ctx, store, zap.NewNop(), testDocument(docType, period, source), 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return doc.Number
+	}
+	for _, c := range []struct {
+		docType        DocumentTypeEnum
+		period, source string
+		want           string
+	}{
+		{DOC_TAX_INVOICE, "202610", "ORDER:1:a", "INV-202610-00001"},
+		{DOC_TAX_INVOICE, "202610", "ORDER:1:b", "INV-202610-00002"},
+		{DOC_RECEIPT, "202610", "ORDER:1:a", "RC-202610-00001"},
+		{DOC_CREDIT_NOTE, "202610", "RETURN:1:a", "CN-202610-00001"},
+		{DOC_TAX_INVOICE, "202611", "ORDER:1:c", "INV-202611-00001"},
+	} {
+		if got := issue(c.docType, c.period, c.source); got != c.want {
+			t.Errorf("%s %s: %s, want %s", c.docType, c.period, got, c.want)
+		}
+	}
+}
+
+func TestIssueDocumentSameSourceOnce(t *testing.T) {
+	store := &memoryDocuments{}
+	ctx := pkg.WithTenant(context.Background(), "t1")
+	const issuers = 20
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	ok, dup := 0, 0
+	for i := 0; i < issuers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := issueNumbered(ctx, store, zap.NewNop(), testDocument(DOC_TAX_INVOICE, "202610", "ORDER:1:same"), issuers)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				ok++
+			case errors.Is(err, ErrDuplicateDocument):
+				dup++
+			default:
+				t.Errorf("issue: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if ok != 1 || dup != issuers-1 {
+		t.Fatalf("issued %d, duplicates %d : want 1 and %d", ok, dup, issuers-1)
+	}
+
+	// a voided document frees its source, the new one takes the next number
+	store.docs[0].Status = DOCUMENT_VOIDED
+	doc, err := issueNumbered(ctx, store, zap.NewNop(), testDocument(DOC_TAX_INVOICE, "202610", "ORDER:1:same"), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Number != "INV-202610-00002" {
+		t.Errorf("reissued as %s, want INV-202610-00002", doc.Number)
+	}
+}
+
+func TestIssueDocumentRetriesExhausted(t *testing.T) {
+	store := &memoryDocuments{docs: []TaxDocumentModel{{TenantID: "t1", DocType: DOC_TAX_INVOICE, Period: "202610", Seq: 1, Status: DOCUMENT_ISSUED}}}
+	// lastSeq that never moves forward : every insert collides
+	stale := &staleNumbering{memoryDocuments: store}
+	if _, err := issueNumbered(pkg.WithTenant(context.Background(), "t1"), stale, zap.NewNop(), testDocument(DOC_TAX_INVOICE, "202610", "ORDER:1:x"), 3); !errors.Is(err, ErrNumberingConflict) {
+		t.Fatalf("err = %v, want ErrNumberingConflict", err)
+	}
+	if stale.inserts != 3 {
+		t.Errorf("%d inserts, want 3", stale.inserts)
+	}
+}
+
+type staleNumbering struct {
+	*memoryDocuments
+	inserts int
+}
+
+func (s *staleNumbering) lastSeq(ctx context.Context, docType DocumentTypeEnum, period string) (int64, error) {
+	return 0, nil
+}
+
+func (s *staleNumbering) insertDocument(ctx context.Context, doc *TaxDocumentModel) error {
+	s.inserts++
+	return s.memoryDocuments.insertDocument(ctx, doc)
+}
+
+func TestIssueDocumentTenantFromContext(t *testing.T) {
+	store := &memoryDocuments{}
+	// the platform tenant is stored as a missing tenant_id
+	doc, err := issueNumbered(pkg.WithTenant(context.Background(), pkg.DEFAULT_TENANT), store, zap.NewNop(), testDocument(DOC_TAX_INVOICE, "202610", "ORDER:1:a"), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.TenantID != "" {
+		t.Errorf("platform document stamped %q", doc.TenantID)
+	}
+	if _, err := issueNumbered(context.Background(), store, zap.NewNop(), testDocument(DOC_TAX_INVOICE, "202610", "ORDER:1:b"), 3); !errors.Is(err, pkg.ErrNoTenant) {
+		t.Fatalf("err = %v, want ErrNoTenant", err)
+	}
+	if len(store.docs) != 1 {
+		t.Errorf("%d documents stored, want 1", len(store.docs))
+	}
+}
@@ -0,0 +1,748 @@
+package invoice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.uber.org/zap"
+
+	"ecommerce/internal/adapter/dto"
+	"ecommerce/internal/adapter/storage"
+	"ecommerce/internal/application/marketplace"
+	"ecommerce/internal/application/shopee/returns"
+	"ecommerce/internal/env"
+	"ecommerce/internal/pkg"
+)
+
+const (
+	pageSize    = 50
+	maxPageSize = 200
+
+	// used when no font file is configured : latin text only
+	defaultPDFFont = "Helvetica"
+	headOffice     = "00000"
+)
+
+var (
+	ErrOrderNotInvoiceable  = errors.New("order is unpaid or cancelled")
+	ErrNothingToInvoice     = errors.New("document total must be greater than zero")
+	ErrReturnNotFound       = errors.New("return not found")
+	ErrReturnNotRefunded    = errors.New("return is not accepted or has no refund")
+	ErrInvoiceNotIssued     = errors.New("no issued tax invoice for the order of this return")
+	ErrCreditExceedsInvoice = errors.New("credited amount would exceed the tax invoice total")
+	ErrDocumentHasCredit    = errors.New("tax invoice has issued credit notes, void them first")
+)
+
+type IInvoiceService interface {
+	GetTaxProfile(ctx context.Context) (*TaxProfileEntity, error)
+	PutTaxProfile(ctx context.Context, actor string, req *IReqTaxProfile) (*TaxProfileEntity, error)
+
+	// TAX_INVOICE or RECEIPT for a stored order, one issued document per order and type
+	IssueOrderDocument(ctx context.Context, actor string, req *IReqOrderDocument) (*TaxDocumentEntity, error)
+	// against the issued tax invoice of the returned order, one per return
+	IssueCreditNote(ctx context.Context, actor string, req *IReqCreditNote) (*TaxDocumentEntity, error)
+	GetDocuments(ctx context.Context, query *IReqTaxDocumentQuery) ([]TaxDocumentEntity, error)
+	GetDocumentByID(ctx context.Context, id string) (*TaxDocumentEntity, error)
+	// keeps the number, the source may be issued again
+	VoidDocument(ctx context.Context, id string, actor string, req *IReqVoidDocument) (*TaxDocumentEntity, error)
+	// rendered (and stored) on first access when issuing could not
+	GetDocumentPDF(ctx context.Context, id string) (*TaxDocumentEntity, []byte, error)
+	GetDocumentXML(ctx context.Context, id string) (*TaxDocumentEntity, []byte, error)
+}
+
+type TaxProfileEntity struct {
+	TenantID         string    `json:"tenant_id"`
+	SellerName       string    `json:"seller_name"`
+	TaxID            string    `json:"tax_id"`
+	BranchCode       string    `json:"branch_code"`
+	Address          string    `json:"address"`
+	Phone            string    `json:"phone"`
+	Email            string    `json:"email"`
+	VATRate          float64   `json:"vat_rate"`
+	PricesIncludeVAT bool      `json:"prices_include_vat"`
+	UpdatedAt        time.Time `json:"updated_at"`
+	UpdatedBy        string    `json:"updated_by"`
+}
+
+type DocumentPartyEntity struct {
+	Name       string `json:"name"`
+	TaxID      string `json:"tax_id"`
+	BranchCode string `json:"branch_code"`
+	Address    string `json:"address"`
+	Phone      string `json:"phone"`
+}
+
+type DocumentLineEntity struct {
+	SKU       string  `json:"sku"`
+	Name      string  `json:"name"`
+	Quantity  int64   `json:"quantity"`
+	UnitPrice float64 `json:"unit_price"`
+	Amount    float64 `json:"amount"`
+}
+
+type TaxDocumentEntity struct {
+	ID               string               `json:"id"`
+	DocType          DocumentTypeEnum     `json:"doc_type"`
+	Number           string               `json:"number"`
+	Status           DocumentStatusEnum   `json:"status"`
+	Channel          string               `json:"channel"`
+	ShopID           string               `json:"shop_id"`
+	OrderSN          string               `json:"order_sn"`
+	ReturnSN         string               `json:"return_sn,omitempty"`
+	IssueDate        time.Time            `json:"issue_date"`
+	Seller           DocumentPartyEntity  `json:"seller"`
+	Buyer            DocumentPartyEntity  `json:"buyer"`
+	Currency         string               `json:"currency"`
+	VATRate          float64              `json:"vat_rate"`
+	PricesIncludeVAT bool                 `json:"prices_include_vat"`
+	Lines            []DocumentLineEntity `json:"lines"`
+	Subtotal         float64              `json:"subtotal"`
+	TaxableAmount    float64              `json:"taxable_amount"`
+	VATAmount        float64              `json:"vat_amount"`
+	TotalAmount      float64              `json:"total_amount"`
+	RefDocumentID    string               `json:"ref_document_id,omitempty"`
+	RefNumber        string               `json:"ref_number,omitempty"`
+	RefIssueDate     *time.Time           `json:"ref_issue_date,omitempty"`
+	RefTotalAmount   float64              `json:"ref_total_amount,omitempty"`
+	Reason           string               `json:"reason,omitempty"`
+	Rendered         bool                 `json:"rendered"`
+	VoidReason       string               `json:"void_reason,omitempty"`
+	VoidedAt         *time.Time           `json:"voided_at,omitempty"`
+	VoidedBy         string               `json:"voided_by,omitempty"`
+	CreatedAt        time.Time            `json:"created_at"`
+	CreatedBy        string               `json:"created_by"`
+}
+
+func TaxProfileModelToEntity(model *TaxProfileModel) *TaxProfileEntity {
+	return &TaxProfileEntity{
+		TenantID:         model.TenantID,
+		SellerName:       model.SellerName,
+		TaxID:            model.TaxID,
+		BranchCode:       model.BranchCode,
+		Address:          model.Address,
+		Phone:            model.Phone,
+		Email:            model.Email,
+		VATRate:          model.VATRate,
+		PricesIncludeVAT: model.PricesIncludeVAT,
+		UpdatedAt:        model.UpdatedAt,
+		UpdatedBy:        model.UpdatedBy,
+	}
+}
+
+func documentPartyModelToEntity(model DocumentPartyModel) DocumentPartyEntity {
+	return DocumentPartyEntity(model)
+}
+
+func TaxDocumentModelToEntity(model *TaxDocumentModel) *TaxDocumentEntity {
+	lines := make([]DocumentLineEntity, 0, len(model.Lines))
+	for _, l := range model.Lines {
+		lines = append(lines, DocumentLineEntity(l))
+	}
+	enti := &TaxDocumentEntity{
+		ID:               model.ID.Hex(),
+		DocType:          model.DocType,
+		Number:           model.Number,
+		Status:           model.Status,
+		Channel:          string(documentChannel(model)),
+		ShopID:           model.ShopID,
+		OrderSN:          model.OrderSN,
+		ReturnSN:         model.ReturnSN,
+		IssueDate:        model.IssueDate,
+		Seller:           documentPartyModelToEntity(model.Seller),
+		Buyer:            documentPartyModelToEntity(model.Buyer),
+		Currency:         model.Currency,
+		VATRate:          model.VATRate,
+		PricesIncludeVAT: model.PricesIncludeVAT,
+		Lines:            lines,
+		Subtotal:         model.Subtotal,
+		TaxableAmount:    model.TaxableAmount,
+		VATAmount:        model.VATAmount,
+		TotalAmount:      model.TotalAmount,
+		RefNumber:        model.RefNumber,
+		RefTotalAmount:   model.RefTotalAmount,
+		Reason:           model.Reason,
+		Rendered:         model.PdfKey != "" && model.XmlKey != "",
+		VoidReason:       model.VoidReason,
+		VoidedBy:         model.VoidedBy,
+		CreatedAt:        model.CreatedAt,
+		CreatedBy:        model.CreatedBy,
+	}
+	if !model.RefDocumentID.IsZero() {
+		enti.RefDocumentID = model.RefDocumentID.Hex()
+	}
+	if !model.RefIssueDate.IsZero() {
+		t := model.RefIssueDate
+		enti.RefIssueDate = &t
+	}
+	if !model.VoidedAt.IsZero() {
+		t := model.VoidedAt
+		enti.VoidedAt = &t
+	}
+	return enti
+}
+
+type invoiceService struct {
+	Config             *env.Config
+	Logger             *zap.Logger
+	BlobStore          storage.IBlobStore
+	MarketplaceService marketplace.IMarketplaceService
+	ReturnRepository   returns.ShopeeReturnRepository
+	ProfileRepository  TaxProfileRepository
+	DocumentRepository TaxDocumentRepository
+	Location           *time.Location
+	FontName           string
+	ThaiText           bool // a font file is installed : Thai titles / text can be rendered
+}
+
+func NewInvoiceService(
+	cfg *env.Config,
+	logger *zap.Logger,
+	blob storage.IBlobStore,
+	marketplaceService marketplace.IMarketplaceService,
+	returnRepo returns.ShopeeReturnRepository,
+	profileRepo TaxProfileRepository,
+	documentRepo TaxDocumentRepository,
+) IInvoiceService {
+	loc, err := time.LoadLocation(cfg.Invoice.InvoiceTimezone)
+	if err != nil {
+		logger.Warn("usecase.NewInvoiceService : unknown timezone, using UTC+7", zap.String("timezone", cfg.Invoice.InvoiceTimezone), zap.Error(err))
+		loc = time.FixedZone("ICT", 7*60*60)
+	}
+
+	fontName, thai := defaultPDFFont, false
+	if cfg.Invoice.InvoiceFontFile != "" {
+		name, err := pkg.InstallPDFFont(cfg.Invoice.InvoiceFontDir, cfg.Invoice.InvoiceFontFile)
+		if err != nil {
+			logger.Error("usecase.NewInvoiceService : InstallPDFFont, Thai text will not render", zap.String("file", cfg.Invoice.InvoiceFontFile), zap.Error(err))
+		} else {
+			fontName, thai = name, true
+		}
+	}
+
+	return &invoiceService{
+		Config:             cfg,
+		Logger:             logger,
+		BlobStore:          blob,
+		MarketplaceService: marketplaceService,
+		ReturnRepository:   returnRepo,
+		ProfileRepository:  profileRepo,
+		DocumentRepository: documentRepo,
+		Location:           loc,
+		FontName:           fontName,
+		ThaiText:           thai,
+	}
+}
+
+func paging(page int, size int) (int64, int64) {
+	if page <= 0 {
+		page = 1
+	}
+	if size <= 0 {
+		size = pageSize
+	}
+	if size > maxPageSize {
+		size = maxPageSize
+	}
+	return int64((page - 1) * size), int64(size)
+}
+
+// orderSourceKey : Shopee keeps the key it had before other channels
+func orderSourceKey(channel dto.MarketplaceChannelEnum, shopID string, orderID string) string {
+	if channel == dto.CHANNEL_SHOPEE {
+		return fmt.Sprintf("ORDER:%s:%s", shopID, orderID)
+	}
+	return fmt.Sprintf("ORDER:%s:%s:%s", channel, shopID, orderID)
+}
+
+// documentChannel : documents from before other channels carry none
+func documentChannel(model *TaxDocumentModel) dto.MarketplaceChannelEnum {
+	if model.Channel == "" {
+		return dto.CHANNEL_SHOPEE
+	}
+	return model.Channel
+}
+
+func returnSourceKey(shopID string, returnSN string) string {
+	return fmt.Sprintf("RETURN:%s:%s", shopID, returnSN)
+}
+
+// -- money : computed in satang so totals add up to the printed lines
+
+func toSatang(v float64) int64 {
+	return int64(math.Round(v * 100))
+}
+
+func fromSatang(v int64) float64 {
+	return float64(v) / 100
+}
+
+// applyTotals : VAT extracted from the lines (inclusive) or added on top (exclusive), rounded once on the total
+func applyTotals(doc *TaxDocumentModel) {
+	var sum int64
+	for _, l := range doc.Lines {
+		sum += toSatang(l.Amount)
+	}
+	var vat, taxable, total int64
+	if doc.PricesIncludeVAT {
+		vat = int64(math.Round(float64(sum) * doc.VATRate / (100 + doc.VATRate)))
+		taxable, total = sum-vat, sum
+	} else {
+		vat = int64(math.Round(float64(sum) * doc.VATRate / 100))
+		taxable, total = sum, sum+vat
+	}
+	doc.Subtotal = fromSatang(sum)
+	doc.TaxableAmount = fromSatang(taxable)
+	doc.VATAmount = fromSatang(vat)
+	doc.TotalAmount = fromSatang(total)
+}
+
+func newLine(sku string, name string, quantity int64, unitPrice float64) DocumentLineModel {
+	return DocumentLineModel{
+		SKU:       sku,
+		Name:      name,
+		Quantity:  quantity,
+		UnitPrice: unitPrice,
+		Amount:    fromSatang(toSatang(unitPrice) * quantity),
+	}
+}
+
+// -- tax profile
+
+func (s *invoiceService) GetTaxProfile(ctx context.Context) (*TaxProfileEntity, error) {
+	profile, err := s.ProfileRepository.GetTaxProfile(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return TaxProfileModelToEntity(profile), nil
+}
+
+func (s *invoiceService) PutTaxProfile(ctx context.Context, actor string, req *IReqTaxProfile) (*TaxProfileEntity, error) {
+	branch := req.BranchCode
+	if branch == "" {
+		branch = headOffice
+	}
+	profile, err := s.ProfileRepository.UpsertTaxProfile(ctx, &TaxProfileModel{
+		SellerName:       strings.TrimSpace(req.SellerName),
+		TaxID:            req.TaxID,
+		BranchCode:       branch,
+		Address:          strings.TrimSpace(req.Address),
+		Phone:            req.Phone,
+		Email:            req.Email,
+		VATRate:          *req.VATRate,
+		PricesIncludeVAT: req.PricesIncludeVAT,
+		UpdatedAt:        time.Now(),
+		UpdatedBy:        actor,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return TaxProfileModelToEntity(profile), nil
+}
+
+func sellerParty(profile *TaxProfileModel) DocumentPartyModel {
+	return DocumentPartyModel{
+		Name:       profile.SellerName,
+		TaxID:      profile.TaxID,
+		BranchCode: profile.BranchCode,
+		Address:    profile.Address,
+		Phone:      profile.Phone,
+	}
+}
+
+// -- issuing
+
+// orderLines : items at the price paid, seller voucher as a discount line, buyer paid shipping ;
+// rows of the same item at the same price are one line (Lazada has a row per unit)
+func orderLines(order *dto.MarketplaceOrder, shippingFee *float64) []DocumentLineModel {
+	lines := []DocumentLineModel{}
+	index := map[string]int{}
+	for _, item := range order.Items {
+		price := item.PaidPrice
+		if price == 0 {
+			price = item.UnitPrice
+		}
+		name := item.Name
+		if item.VariantName != "" {
+			name = fmt.Sprintf("%s (%s)", item.Name, item.VariantName)
+		}
+		key := fmt.Sprintf("%s|%s|%d", item.SKU, name, toSatang(price))
+		if i, ok := index[key]; ok {
+			lines[i] = newLine(item.SKU, name, lines[i].Quantity+int64(item.Quantity), price)
+			continue
+		}
+		index[key] = len(lines)
+		lines = append(lines, newLine(item.SKU, name, int64(item.Quantity), price))
+	}
+
+	if order.SellerVoucher > 0 {
+		lines = append(lines, newLine("", "Seller voucher", 1, -order.SellerVoucher))
+	}
+	shipping := order.BuyerShippingFee
+	if shippingFee != nil {
+		shipping = *shippingFee
+	}
+	if shipping > 0 {
+		lines = append(lines, newLine("", "Shipping fee", 1, shipping))
+	}
+	return lines
+}
+
+func orderBuyer(order *dto.MarketplaceOrder, req *IReqDocumentBuyer) DocumentPartyModel {
+	buyer := DocumentPartyModel{
+		Name:    order.ShippingAddress.Name,
+		Address: order.ShippingAddress.FullAddress,
+		Phone:   order.ShippingAddress.Phone,
+	}
+	if buyer.Name == "" {
+		buyer.Name = order.BuyerName
+	}
+	if req == nil {
+		return buyer
+	}
+	if req.Name != "" {
+		buyer.Name = strings.TrimSpace(req.Name)
+	}
+	if req.Address != "" {
+		buyer.Address = strings.TrimSpace(req.Address)
+	}
+	if req.Phone != "" {
+		buyer.Phone = req.Phone
+	}
+	// a tax id makes it a full tax invoice for a VAT registered buyer : branch is mandatory then
+	if req.TaxID != "" {
+		buyer.TaxID = req.TaxID
+		buyer.BranchCode = req.BranchCode
+		if buyer.BranchCode == "" {
+			buyer.BranchCode = headOffice
+		}
+	}
+	return buyer
+}
+
+func (s *invoiceService) newDocument(docType DocumentTypeEnum, actor string, profile *TaxProfileModel) *TaxDocumentModel {
+	now := time.Now()
+	return &TaxDocumentModel{
+		DocType:          docType,
+		Period:           now.In(s.Location).Format("200601"),
+		Status:           DOCUMENT_ISSUED,
+		IssueDate:        now,
+		Seller:           sellerParty(profile),
+		VATRate:          profile.VATRate,
+		PricesIncludeVAT: profile.PricesIncludeVAT,
+		CreatedAt:        now,
+		CreatedBy:        actor,
+	}
+}
+
+func (s *invoiceService) IssueOrderDocument(ctx context.Context, actor string, req *IReqOrderDocument) (*TaxDocumentEntity, error) {
+	profile, err := s.ProfileRepository.GetTaxProfile(ctx)
+	if err != nil {
+		return nil, err
+	}
+	channel := dto.MarketplaceChannelEnum(req.Channel)
+	if channel == "" {
+		channel = dto.CHANNEL_SHOPEE
+	}
+	sourceKey := orderSourceKey(channel, req.ShopID, req.OrderSN)
+	if _, err := s.DocumentRepository.GetIssuedDocumentBySource(ctx, req.DocType, sourceKey); err == nil {
+		return nil, ErrDuplicateDocument
+	} else if !errors.Is(err, ErrDocumentNotFound) {
+		return nil, err
+	}
+
+	// not stored yet : read from the channel
+	order, err := s.MarketplaceService.GetStoredMarketplaceOrder(ctx, channel, req.ShopID, req.OrderSN)
+	if err != nil {
+		return nil, err
+	}
+	switch order.Status {
+	case dto.MP_ORDER_UNPAID, dto.MP_ORDER_IN_CANCEL, dto.MP_ORDER_CANCELLED:
+		return nil, ErrOrderNotInvoiceable
+	}
+
+	doc := s.newDocument(req.DocType, actor, profile)
+	doc.SourceKey = sourceKey
+	doc.Channel = channel
+	doc.ShopID = req.ShopID
+	doc.OrderSN = req.OrderSN
+	doc.Buyer = orderBuyer(order, req.Buyer)
+	doc.Currency = order.Currency
+	doc.Lines = orderLines(order, req.ShippingFee)
+	applyTotals(doc)
+	if toSatang(doc.TotalAmount) <= 0 {
+		return nil, ErrNothingToInvoice
+	}
+
+	issued, err := s.DocumentRepository.IssueDocument(ctx, doc, s.Config.Invoice.InvoiceMaxRetries)
+	if err != nil {
+		return nil, err
+	}
+	s.Logger.Info("usecase.IssueOrderDocument", zap.String("number", issued.Number), zap.String("order_sn", issued.OrderSN))
+	s.render(ctx, issued)
+	return TaxDocumentModelToEntity(issued), nil
+}
+
+// returnLines : returned items when they add up to the refund, the refund as one line otherwise
+// (partial refunds, shipping refunded)
+func returnLines(ret *returns.ShopeeReturnModel) []DocumentLineModel {
+	lines := []DocumentLineModel{}
+	var sum int64
+	for _, item := range ret.Items {
+		if item.Amount <= 0 {
+			continue
+		}
+		sku := item.VariationSKU
+		if sku == "" {
+			sku = item.ItemSKU
+		}
+		line := newLine(sku, item.Name, item.Amount, item.ItemPrice)
+		sum += toSatang(line.Amount)
+		lines = append(lines, line)
+	}
+	if len(lines) > 0 && sum == toSatang(ret.RefundAmount) {
+		return lines
+	}
+	return []DocumentLineModel{newLine("", fmt.Sprintf("Refund for return %s", ret.ReturnSN), 1, ret.RefundAmount)}
+}
+
+func (s *invoiceService) IssueCreditNote(ctx context.Context, actor string, req *IReqCreditNote) (*TaxDocumentEntity, error) {
+	profile, err := s.ProfileRepository.GetTaxProfile(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ret, err := s.ReturnRepository.GetShopeeReturnByReturnSN(ctx, req.ReturnSN)
+	if err != nil || (req.ShopID != "" && ret.ShopID != req.ShopID) {
+		return nil, ErrReturnNotFound
+	}
+	if (ret.Status != dto.RETURN_ACCEPTED && ret.Status != dto.RETURN_CLOSED) || ret.RefundAmount <= 0 {
+		return nil, ErrReturnNotRefunded
+	}
+
+	sourceKey := returnSourceKey(ret.ShopID, ret.ReturnSN)
+	if _, err := s.DocumentRepository.GetIssuedDocumentBySource(ctx, DOC_CREDIT_NOTE, sourceKey); err == nil {
+		return nil, ErrDuplicateDocument
+	} else if !errors.Is(err, ErrDocumentNotFound) {
+		return nil, err
+	}
+
+	invoice, err := s.DocumentRepository.GetIssuedDocumentBySource(ctx, DOC_TAX_INVOICE, orderSourceKey(dto.CHANNEL_SHOPEE, ret.ShopID, ret.OrderSN))
+	if err != nil {
+		if errors.Is(err, ErrDocumentNotFound) {
+			return nil, ErrInvoiceNotIssued
+		}
+		return nil, err
+	}
+	credited, err := s.creditedAmount(ctx, invoice.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := s.newDocument(DOC_CREDIT_NOTE, actor, profile)
+	// the credit note follows the VAT treatment of the invoice it corrects
+	doc.VATRate = invoice.VATRate
+	doc.PricesIncludeVAT = invoice.PricesIncludeVAT
+	doc.SourceKey = sourceKey
+	doc.ShopID = ret.ShopID
+	doc.OrderSN = ret.OrderSN
+	doc.ReturnSN = ret.ReturnSN
+	doc.Buyer = invoice.Buyer
+	doc.Currency = invoice.Currency
+	doc.Lines = returnLines(ret)
+	doc.RefDocumentID = invoice.ID
+	doc.RefNumber = invoice.Number
+	doc.RefIssueDate = invoice.IssueDate
+	// value of the invoice before this note : original total less earlier credit notes
+	doc.RefTotalAmount = fromSatang(toSatang(invoice.TotalAmount) - credited)
+	doc.Reason = strings.TrimSpace(req.Reason)
+	if doc.Reason == "" {
+		doc.Reason = strings.TrimSpace(strings.Join([]string{ret.Reason, ret.TextReason}, " "))
+	}
+	applyTotals(doc)
+	if toSatang(doc.TotalAmount) <= 0 {
+		return nil, ErrNothingToInvoice
+	}
+	// two returns of one order credited at the same instant may both pass : the unique source index
+	// only guards one note per return
+	if toSatang(doc.TotalAmount) > toSatang(doc.RefTotalAmount) {
+		return nil, ErrCreditExceedsInvoice
+	}
+
+	issued, err := s.DocumentRepository.IssueDocument(ctx, doc, s.Config.Invoice.InvoiceMaxRetries)
+	if err != nil {
+		return nil, err
+	}
+	s.Logger.Info("usecase.IssueCreditNote", zap.String("number", issued.Number), zap.String("ref_number", issued.RefNumber), zap.String("return_sn", issued.ReturnSN))
+	s.render(ctx, issued)
+	return TaxDocumentModelToEntity(issued), nil
+}
+
+// creditedAmount : total of the issued credit notes of an invoice, in satang
+func (s *invoiceService) creditedAmount(ctx context.Context, invoiceID bson.ObjectID) (int64, error) {
+	notes, err := s.DocumentRepository.GetDocuments(ctx, &TaxDocumentFilter{
+		DocType:       DOC_CREDIT_NOTE,
+		Status:        DOCUMENT_ISSUED,
+		RefDocumentID: invoiceID,
+	})
+	if err != nil {
+		return 0, err
+	}
+	var sum int64
+	for _, n := range notes {
+		sum += toSatang(n.TotalAmount)
+	}
+	return sum, nil
+}
+
+// -- read / void
+
+func (s *invoiceService) GetDocuments(ctx context.Context, query *IReqTaxDocumentQuery) ([]TaxDocumentEntity, error) {
+	skip, limit := paging(query.Page, query.Size)
+	filter := &TaxDocumentFilter{
+		DocType: query.DocType,
+		Status:  query.Status,
+		ShopID:  query.ShopID,
+		OrderSN: query.OrderSN,
+		Number:  query.Number,
+		Skip:    skip,
+		Limit:   limit,
+	}
+	if query.From != "" {
+		from, err := time.ParseInLocation(time.DateOnly, query.From, s.Location)
+		if err != nil {
+			return nil, errors.New("from must be YYYY-MM-DD")
+		}
+		filter.From = from
+	}
+	if query.To != "" {
+		to, err := time.ParseInLocation(time.DateOnly, query.To, s.Location)
+		if err != nil {
+			return nil, errors.New("to must be YYYY-MM-DD")
+		}
+		filter.To = to.AddDate(0, 0, 1)
+	}
+
+	models, err := s.DocumentRepository.GetDocuments(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]TaxDocumentEntity, 0, len(models))
+	for i := range models {
+		out = append(out, *TaxDocumentModelToEntity(&models[i]))
+	}
+	return out, nil
+}
+
+func (s *invoiceService) GetDocumentByID(ctx context.Context, id string) (*TaxDocumentEntity, error) {
+	doc, err := s.DocumentRepository.GetDocumentByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return TaxDocumentModelToEntity(doc), nil
+}
+
+func (s *invoiceService) VoidDocument(ctx context.Context, id string, actor string, req *IReqVoidDocument) (*TaxDocumentEntity, error) {
+	doc, err := s.DocumentRepository.GetDocumentByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if doc.Status != DOCUMENT_ISSUED {
+		return nil, ErrDocumentNotFound
+	}
+	if doc.DocType == DOC_TAX_INVOICE {
+		credited, err := s.creditedAmount(ctx, doc.ID)
+		if err != nil {
+			return nil, err
+		}
+		if credited > 0 {
+			return nil, ErrDocumentHasCredit
+		}
+	}
+
+	voided, err := s.DocumentRepository.VoidDocument(ctx, doc.ID, strings.TrimSpace(req.Reason), actor)
+	if err != nil {
+		return nil, err
+	}
+	s.Logger.Info("usecase.VoidDocument", zap.String("number", voided.Number), zap.String("by", actor))
+	// files rendered again with the void mark
+	s.render(ctx, voided)
+	return TaxDocumentModelToEntity(voided), nil
+}
+
+// -- files
+
+// documentKey : the platform tenant keeps its files under invoices/default
+func documentKey(doc *TaxDocumentModel, ext string) string {
+	tenantID := doc.TenantID
+	if tenantID == "" {
+		tenantID = pkg.DEFAULT_TENANT
+	}
+	return fmt.Sprintf("invoices/%s/%s.%s", tenantID, doc.Number, ext)
+}
+
+// render : PDF + XML into the blob store, failures are logged and retried on download
+func (s *invoiceService) render(ctx context.Context, doc *TaxDocumentModel) {
+	pdfKey, xmlKey := documentKey(doc, "pdf"), documentKey(doc, "xml")
+	pdfData, err := s.renderPDF(doc)
+	if err != nil {
+		s.Logger.Error("usecase.render : renderPDF", zap.String("number", doc.Number), zap.Error(err))
+		return
+	}
+	xmlData, err := s.renderXML(doc)
+	if err != nil {
+		s.Logger.Error("usecase.render : renderXML", zap.String("number", doc.Number), zap.Error(err))
+		return
+	}
+	if err := s.BlobStore.Put(ctx, pdfKey, pdfData, "application/pdf"); err != nil {
+		s.Logger.Error("usecase.render : BlobStore.Put", zap.String("key", pdfKey), zap.Error(err))
+		return
+	}
+	if err := s.BlobStore.Put(ctx, xmlKey, xmlData, "application/xml"); err != nil {
+		s.Logger.Error("usecase.render : BlobStore.Put", zap.String("key", xmlKey), zap.Error(err))
+		return
+	}
+	if err := s.DocumentRepository.SetDocumentFiles(ctx, doc.ID, pdfKey, xmlKey); err != nil {
+		s.Logger.Error("usecase.render : SetDocumentFiles", zap.String("number", doc.Number), zap.Error(err))
+		return
+	}
+	doc.PdfKey, doc.XmlKey = pdfKey, xmlKey
+}
+
+func (s *invoiceService) getFile(ctx context.Context, id string, ext string) (*TaxDocumentEntity, []byte, error) {
+	doc, err := s.DocumentRepository.GetDocumentByID(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	key := doc.PdfKey
+	if ext == "xml" {
+		key = doc.XmlKey
+	}
+	if key != "" {
+		if data, err := s.BlobStore.Get(ctx, key); err == nil {
+			return TaxDocumentModelToEntity(doc), data, nil
+		}
+	}
+
+	s.render(ctx, doc)
+	if doc.PdfKey == "" {
+		return nil, nil, errors.New("document could not be rendered")
+	}
+	data, err := s.BlobStore.Get(ctx, documentKey(doc, ext))
+	if err != nil {
+		return nil, nil, err
+	}
+	return TaxDocumentModelToEntity(doc), data, nil
+}
+
+func (s *invoiceService) GetDocumentPDF(ctx context.Context, id string) (*TaxDocumentEntity, []byte, error) {
+	return s.getFile(ctx, id, "pdf")
+}
+
+func (s *invoiceService) GetDocumentXML(ctx context.Context, id string) (*TaxDocumentEntity, []byte, error) {
+	return s.getFile(ctx, id, "xml")
+}
@@ -0,0 +1,49 @@
+package invoice
+
+import (
+	"reflect"
+	"testing"
+
+	"ecommerce/internal/adapter/dto"
+)
+
+func TestOrderLines(t *testing.T) {
+	// Lazada : one row per unit, the same item at another price stays its own line
+	order := &dto.MarketplaceOrder{
+		Channel: dto.CHANNEL_LAZADA,
+		Items: []dto.MarketplaceOrderItem{
+			{SKU: "SKU-1", Name: "Shirt", VariantName: "Red", Quantity: 1, UnitPrice: 300, PaidPrice: 250},
+			{SKU: "SKU-1", Name: "Shirt", VariantName: "Red", Quantity: 1, UnitPrice: 300, PaidPrice: 250},
+			{SKU: "SKU-1", Name: "Shirt", VariantName: "Red", Quantity: 1, UnitPrice: 300, PaidPrice: 200},
+			{SKU: "SKU-2", Name: "Cap", Quantity: 1, UnitPrice: 100},
+		},
+		BuyerShippingFee: 40,
+		SellerVoucher:    25,
+	}
+	want := []DocumentLineModel{
+		newLine("SKU-1", "Shirt (Red)", 2, 250),
+		newLine("SKU-1", "Shirt (Red)", 1, 200),
+		newLine("SKU-2", "Cap", 1, 100),
+		newLine("", "Seller voucher", 1, -25),
+		newLine("", "Shipping fee", 1, 40),
+	}
+	if got := orderLines(order, nil); !reflect.DeepEqual(got, want) {
+		t.Errorf("lines\n got %+v\nwant %+v", got, want)
+	}
+
+	// shipping given on the request wins, 0 drops the line
+	free := 0.0
+	if got := orderLines(order, &free); len(got) != 4 {
+		t.Errorf("%d lines with free shipping, want 4", len(got))
+	}
+}
+
+func TestOrderSourceKey(t *testing.T) {
+	// Shopee keeps its key : invoices issued before other channels still block a second one
+	if got := orderSourceKey(dto.CHANNEL_SHOPEE, "1", "2405ABC"); got != "ORDER:1:2405ABC" {
+		t.Errorf("shopee key %q", got)
+	}
+	if got := orderSourceKey(dto.CHANNEL_LAZADA, "1", "2405ABC"); got != "ORDER:LAZADA:1:2405ABC" {
+		t.Errorf("lazada key %q", got)
+	}
+}
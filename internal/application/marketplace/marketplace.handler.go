@@ -0,0 +1,344 @@
+package marketplace
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	"ecommerce/internal/adapter"
+	"ecommerce/internal/adapter/dto"
+	"ecommerce/internal/delivery/http/response"
+)
+
+// default order window when from / to are not given
+const marketplaceOrderWindow = 24 * time.Hour
+
+type IReqMarketplaceApp struct {
+	Name      string `json:"name" validate:"required,max=100"`
+	AppKey    string `json:"app_key" validate:"required"`
+	AppSecret string `json:"app_secret" validate:"required"`
+}
+
+// from / to : RFC3339, update time window
+type IReqMarketplaceOrderQuery struct {
+	From     string                         `query:"from" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	To       string                         `query:"to" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	Status   dto.MarketplaceOrderStatusEnum `query:"status"`
+	Cursor   string                         `query:"cursor"`
+	PageSize int                            `query:"page_size" validate:"gte=0,lte=100"`
+}
+
+type IReqMarketplaceItemQuery struct {
+	UpdatedFrom string `query:"updated_from" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	Cursor      string `query:"cursor"`
+	PageSize    int    `query:"page_size" validate:"gte=0,lte=100"`
+}
+
+type IReqMarketplaceStock struct {
+	Stocks []dto.MarketplaceStockUpdate `json:"stocks" validate:"required,min=1,max=50,dive"`
+}
+
+type IReqMarketplaceShip struct {
+	PackageID      string   `json:"package_id"`
+	LineIDs        []string `json:"line_ids"`
+	Carrier        string   `json:"carrier"`
+	TrackingNumber string   `json:"tracking_number"`
+}
+
+type IMarketplaceHandler interface {
+	GetMarketplaceChannels(c *fiber.Ctx) error
+	PostMarketplaceApp(c *fiber.Ctx) error
+	GetMarketplaceApps(c *fiber.Ctx) error
+	GetMarketplaceAuthLink(c *fiber.Ctx) error
+	// webhook : consent redirect of the channel, no JWT
+	GetMarketplaceAuthCallback(c *fiber.Ctx) error
+	GetMarketplaceShops(c *fiber.Ctx) error
+	GetMarketplaceShop(c *fiber.Ctx) error
+	GetMarketplaceOrders(c *fiber.Ctx) error
+	GetMarketplaceOrder(c *fiber.Ctx) error
+	GetMarketplaceItems(c *fiber.Ctx) error
+	PutMarketplaceStock(c *fiber.Ctx) error
+	PostMarketplaceShip(c *fiber.Ctx) error
+}
+
+type marketplaceHandler struct {
+	Logger   *zap.Logger
+	Validate *validator.Validate
+	Service  IMarketplaceService
+}
+
+func NewMarketplaceHandler(log *zap.Logger, valid *validator.Validate, srv IMarketplaceService) IMarketplaceHandler {
+	return &marketplaceHandler{
+		Logger:   log,
+		Validate: valid,
+		Service:  srv,
+	}
+}
+
+// actor : username set by the auth middleware
+func actor(c *fiber.Ctx) string {
+	if username, ok := c.Locals("username").(string); ok {
+		return username
+	}
+	return ""
+}
+
+func marketplaceErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrMarketplaceAppNotFound), errors.Is(err, ErrMarketplaceShopAuthNotFound),
+		errors.Is(err, ErrMarketplaceOrderNotFound):
+		return fiber.StatusNotFound
+	case errors.Is(err, ErrMarketplaceShopNeedReauth):
+		return fiber.StatusFailedDependency
+	case errors.Is(err, ErrMarketplaceShopOwned):
+		return fiber.StatusConflict
+	}
+	return fiber.StatusBadRequest
+}
+
+// channel : ":channel" route param, "lazada" / "LAZADA"
+func channel(c *fiber.Ctx) (dto.MarketplaceChannelEnum, error) {
+	ch, ok := adapter.ParseMarketplaceChannel(c.Params("channel"))
+	if !ok {
+		return "", adapter.ErrMarketplaceUnsupported
+	}
+	return ch, nil
+}
+
+func (d *marketplaceHandler) GetMarketplaceChannels(c *fiber.Ctx) error {
+	res, err := d.Service.GetMarketplaceChannels(c.Context())
+	if err != nil {
+		return response.ErrorResponse(c, fiber.StatusInternalServerError, "handler.GetMarketplaceChannels", err)
+	}
+	return response.SuccessResponse(c, "handler.GetMarketplaceChannels", res)
+}
+
+func (d *marketplaceHandler) PostMarketplaceApp(c *fiber.Ctx) error {
+	ch, err := channel(c)
+	if err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostMarketplaceApp", err)
+	}
+
+	var reqBody IReqMarketplaceApp
+	if err := c.BodyParser(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostMarketplaceApp", "invalid body")
+	}
+	if err := d.Validate.Struct(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostMarketplaceApp", err)
+	}
+
+	res, err := d.Service.CreateMarketplaceApp(c.Context(), ch, actor(c), &reqBody)
+	if err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostMarketplaceApp", err)
+	}
+	return response.SuccessResponse(c, "handler.PostMarketplaceApp", res)
+}
+
+func (d *marketplaceHandler) GetMarketplaceApps(c *fiber.Ctx) error {
+	ch, err := channel(c)
+	if err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.GetMarketplaceApps", err)
+	}
+
+	res, err := d.Service.GetMarketplaceApps(c.Context(), ch)
+	if err != nil {
+		return response.ErrorResponse(c, fiber.StatusInternalServerError, "handler.GetMarketplaceApps", err)
+	}
+	return response.SuccessResponse(c, "handler.GetMarketplaceApps", res)
+}
+
+func (d *marketplaceHandler) GetMarketplaceAuthLink(c *fiber.Ctx) error {
+	ch, err := channel(c)
+	if err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.GetMarketplaceAuthLink", err)
+	}
+	appKey := c.Params("appKey")
+	if appKey == "" {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.GetMarketplaceAuthLink", "appKey is required")
+	}
+
+	link, err := d.Service.GenerateMarketplaceAuthLink(c.Context(), ch, appKey)
+	if err != nil {
+		return response.ErrorResponse(c, marketplaceErrorStatus(err), "handler.GetMarketplaceAuthLink", err)
+	}
+	return response.SuccessResponse(c, "handler.GetMarketplaceAuthLink", link)
+}
+
+func (d *marketplaceHandler) GetMarketplaceAuthCallback(c *fiber.Ctx) error {
+	ch, err := channel(c)
+	if err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.GetMarketplaceAuthCallback", err)
+	}
+	code := c.Query("code")
+	state := c.Query("state")
+	if code == "" || state == "" {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.GetMarketplaceAuthCallback", "code and state are required")
+	}
+
+	res, err := d.Service.MarketplaceAuthCallback(c.Context(), ch, code, state)
+	if err != nil {
+		d.Logger.Error("handler.GetMarketplaceAuthCallback : MarketplaceAuthCallback", zap.String("channel", string(ch)), zap.Error(err))
+		return response.ErrorResponse(c, marketplaceErrorStatus(err), "handler.GetMarketplaceAuthCallback", err)
+	}
+	return response.SuccessResponse(c, "handler.GetMarketplaceAuthCallback", res)
+}
+
+func (d *marketplaceHandler) GetMarketplaceShops(c *fiber.Ctx) error {
+	ch, err := channel(c)
+	if err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.GetMarketplaceShops", err)
+	}
+
+	res, err := d.Service.GetMarketplaceShops(c.Context(), ch)
+	if err != nil {
+		return response.ErrorResponse(c, fiber.StatusInternalServerError, "handler.GetMarketplaceShops", err)
+	}
+	return response.SuccessResponse(c, "handler.GetMarketplaceShops", res)
+}
+
+func (d *marketplaceHandler) GetMarketplaceShop(c *fiber.Ctx) error {
+	ch, err := channel(c)
+	if err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.GetMarketplaceShop", err)
+	}
+	shopID := c.Params("shopID")
+
+	res, err := d.Service.GetMarketplaceShop(c.Context(), ch, shopID)
+	if err != nil {
+		return response.ErrorResponse(c, marketplaceErrorStatus(err), "handler.GetMarketplaceShop", err)
+	}
+	return response.SuccessResponse(c, "handler.GetMarketplaceShop", res)
+}
+
+func (d *marketplaceHandler) GetMarketplaceOrders(c *fiber.Ctx) error {
+	ch, err := channel(c)
+	if err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.GetMarketplaceOrders", err)
+	}
+	shopID := c.Params("shopID")
+
+	var query IReqMarketplaceOrderQuery
+	if err := c.QueryParser(&query); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.GetMarketplaceOrders", "invalid query")
+	}
+	if err := d.Validate.Struct(&query); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.GetMarketplaceOrders", "from and to are RFC3339, page_size is 0..100")
+	}
+
+	to := time.Now()
+	if query.To != "" {
+		to, _ = time.Parse(time.RFC3339, query.To)
+	}
+	from := to.Add(-marketplaceOrderWindow)
+	if query.From != "" {
+		from, _ = time.Parse(time.RFC3339, query.From)
+	}
+
+	res, err := d.Service.GetMarketplaceOrders(c.Context(), ch, shopID, &dto.MarketplaceOrderQuery{
+		UpdatedFrom: from,
+		UpdatedTo:   to,
+		Status:      query.Status,
+		Cursor:      query.Cursor,
+		PageSize:    query.PageSize,
+	})
+	if err != nil {
+		d.Logger.Error("handler.GetMarketplaceOrders : GetMarketplaceOrders", zap.String("channel", string(ch)), zap.String("shop_id", shopID), zap.Error(err))
+		return response.ErrorResponse(c, marketplaceErrorStatus(err), "handler.GetMarketplaceOrders", err)
+	}
+	return response.SuccessResponse(c, "handler.GetMarketplaceOrders", res)
+}
+
+func (d *marketplaceHandler) GetMarketplaceOrder(c *fiber.Ctx) error {
+	ch, err := channel(c)
+	if err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.GetMarketplaceOrder", err)
+	}
+	shopID := c.Params("shopID")
+	orderID := c.Params("orderID")
+
+	res, err := d.Service.GetMarketplaceOrder(c.Context(), ch, shopID, orderID)
+	if err != nil {
+		return response.ErrorResponse(c, marketplaceErrorStatus(err), "handler.GetMarketplaceOrder", err)
+	}
+	return response.SuccessResponse(c, "handler.GetMarketplaceOrder", res)
+}
+
+func (d *marketplaceHandler) GetMarketplaceItems(c *fiber.Ctx) error {
+	ch, err := channel(c)
+	if err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.GetMarketplaceItems", err)
+	}
+	shopID := c.Params("shopID")
+
+	var query IReqMarketplaceItemQuery
+	if err := c.QueryParser(&query); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.GetMarketplaceItems", "invalid query")
+	}
+	if err := d.Validate.Struct(&query); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.GetMarketplaceItems", "updated_from is RFC3339, page_size is 0..100")
+	}
+
+	q := &dto.MarketplaceItemQuery{Cursor: query.Cursor, PageSize: query.PageSize}
+	if query.UpdatedFrom != "" {
+		q.UpdatedFrom, _ = time.Parse(time.RFC3339, query.UpdatedFrom)
+	}
+
+	res, err := d.Service.GetMarketplaceItems(c.Context(), ch, shopID, q)
+	if err != nil {
+		return response.ErrorResponse(c, marketplaceErrorStatus(err), "handler.GetMarketplaceItems", err)
+	}
+	return response.SuccessResponse(c, "handler.GetMarketplaceItems", res)
+}
+
+func (d *marketplaceHandler) PutMarketplaceStock(c *fiber.Ctx) error {
+	ch, err := channel(c)
+	if err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PutMarketplaceStock", err)
+	}
+	shopID := c.Params("shopID")
+
+	var reqBody IReqMarketplaceStock
+	if err := c.BodyParser(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PutMarketplaceStock", "invalid body")
+	}
+	if err := d.Validate.Struct(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PutMarketplaceStock", err)
+	}
+
+	res, err := d.Service.UpdateMarketplaceStock(c.Context(), ch, shopID, reqBody.Stocks)
+	if err != nil {
+		d.Logger.Error("handler.PutMarketplaceStock : UpdateMarketplaceStock", zap.String("channel", string(ch)), zap.String("shop_id", shopID), zap.Error(err))
+		return response.ErrorResponse(c, marketplaceErrorStatus(err), "handler.PutMarketplaceStock", err)
+	}
+	return response.SuccessResponse(c, "handler.PutMarketplaceStock", res)
+}
+
+func (d *marketplaceHandler) PostMarketplaceShip(c *fiber.Ctx) error {
+	ch, err := channel(c)
+	if err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostMarketplaceShip", err)
+	}
+	shopID := c.Params("shopID")
+	orderID := c.Params("orderID")
+
+	var reqBody IReqMarketplaceShip
+	if err := c.BodyParser(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostMarketplaceShip", "invalid body")
+	}
+
+	res, err := d.Service.ShipMarketplaceOrder(c.Context(), ch, shopID, &dto.MarketplaceShipRequest{
+		OrderID:        orderID,
+		PackageID:      reqBody.PackageID,
+		LineIDs:        reqBody.LineIDs,
+		Carrier:        reqBody.Carrier,
+		TrackingNumber: reqBody.TrackingNumber,
+	})
+	if err != nil {
+		d.Logger.Error("handler.PostMarketplaceShip : ShipMarketplaceOrder", zap.String("channel", string(ch)), zap.String("order_id", orderID), zap.Error(err))
+		return response.ErrorResponse(c, marketplaceErrorStatus(err), "handler.PostMarketplaceShip", err)
+	}
+	return response.SuccessResponse(c, "handler.PostMarketplaceShip", res)
+}
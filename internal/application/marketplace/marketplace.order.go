@@ -0,0 +1,309 @@
+package marketplace
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"ecommerce/internal/adapter"
+	"ecommerce/internal/adapter/dto"
+	"ecommerce/internal/application/shopee"
+	"ecommerce/internal/env"
+	"ecommerce/internal/pkg"
+)
+
+const (
+	marketplaceOrderPageSize = 100
+	// re-read the tail of the last window : channel update times are second precision
+	marketplaceOrderSyncOverlap = time.Minute
+)
+
+// IMarketplaceOrderListener : reacts to a stored channel order (inventory reservations, fulfillment, ...) ;
+// called for every write, so it must be idempotent, and it cannot fail the save
+type IMarketplaceOrderListener interface {
+	OnMarketplaceOrderSaved(ctx context.Context, order *dto.MarketplaceOrder)
+}
+
+func (s *marketplaceService) AddMarketplaceOrderListener(listener IMarketplaceOrderListener) {
+	s.orderListeners = append(s.orderListeners, listener)
+}
+
+// SaveMarketplaceOrder : listeners only hear about a copy that was written, not an older one
+func (s *marketplaceService) SaveMarketplaceOrder(ctx context.Context, order *dto.MarketplaceOrder) (*dto.MarketplaceOrder, error) {
+	saved, written, err := s.MarketplaceOrderRepository.SaveMarketplaceOrder(ctx, order)
+	if err != nil {
+		return nil, err
+	}
+	if written {
+		for _, l := range s.orderListeners {
+			l.OnMarketplaceOrderSaved(ctx, saved)
+		}
+	}
+	return saved, nil
+}
+
+// store : orders read live from a channel ; a failed save is logged, the caller still gets its answer
+func (s *marketplaceService) store(ctx context.Context, orders []dto.MarketplaceOrder) {
+	for i := range orders {
+		if _, err := s.SaveMarketplaceOrder(ctx, &orders[i]); err != nil {
+			s.Logger.Error("usecase.SaveMarketplaceOrder", zap.String("channel", string(orders[i].Channel)),
+				zap.String("order_id", orders[i].OrderID), zap.Error(err))
+		}
+	}
+}
+
+func (s *marketplaceService) GetStoredMarketplaceOrder(ctx context.Context, channel dto.MarketplaceChannelEnum, shopID string, orderID string) (*dto.MarketplaceOrder, error) {
+	order, err := s.MarketplaceOrderRepository.GetMarketplaceOrder(ctx, channel, shopID, orderID)
+	if !errors.Is(err, ErrMarketplaceOrderNotFound) {
+		return order, err
+	}
+
+	// not seen yet : read it from the channel, which stores it
+	if channel == dto.CHANNEL_SHOPEE {
+		if _, err := s.ShopeeService.SyncShopeeOrderByOrderSN(ctx, shopID, []string{orderID}); err != nil {
+			return nil, err
+		}
+	} else if _, err := s.GetMarketplaceOrder(ctx, channel, shopID, orderID); err != nil {
+		return nil, err
+	}
+	return s.MarketplaceOrderRepository.GetMarketplaceOrder(ctx, channel, shopID, orderID)
+}
+
+// OnShopeeOrderSaved : Shopee orders are synced by the shopee package (push, order sync, escrow),
+// each stored Shopee order is mirrored here
+func (s *marketplaceService) OnShopeeOrderSaved(ctx context.Context, order *shopee.ShopeeOrderEntity) {
+	if _, err := s.SaveMarketplaceOrder(ctx, ShopeeOrderEntityToMarketplace(order)); err != nil {
+		s.Logger.Error("usecase.OnShopeeOrderSaved : SaveMarketplaceOrder", zap.String("order_sn", order.OrderSN), zap.Error(err))
+	}
+}
+
+// ShopeeOrderEntityToMarketplace : like adapter.ShopeeOrderToMarketplace, from the stored order
+// (escrow included when synced)
+func ShopeeOrderEntityToMarketplace(order *shopee.ShopeeOrderEntity) *dto.MarketplaceOrder {
+	shippingFee := order.EstimatedShippingFee
+	if order.ActualShippingFeeConfirmed {
+		shippingFee = order.ActualShippingFee
+	}
+	a := order.RecipientAddress
+	out := &dto.MarketplaceOrder{
+		Channel:            dto.CHANNEL_SHOPEE,
+		ShopID:             order.ShopID,
+		OrderID:            order.OrderSN,
+		OrderNumber:        order.OrderSN,
+		Status:             adapter.ShopeeOrderStatusToMarketplace(string(order.OrderStatus)),
+		ChannelStatus:      string(order.OrderStatus),
+		Currency:           order.Currency,
+		TotalAmount:        order.TotalAmount,
+		ShippingFee:        shippingFee,
+		PaymentMethod:      order.PaymentMethod,
+		COD:                order.Cod,
+		FulfilledByChannel: string(order.FulFillmentFlag) == shopee.FULFILBYSHOPEE,
+		BuyerName:          order.BuyerUsername,
+		BuyerNote:          order.MessageToSeller,
+		ShippingAddress: dto.MarketplaceAddress{
+			Name:        a.Name,
+			Phone:       a.Phone,
+			FullAddress: a.FullAddress,
+			District:    a.District,
+			City:        a.City,
+			State:       a.State,
+			PostCode:    a.ZipCode,
+			Country:     a.Region,
+		},
+		Items:     []dto.MarketplaceOrderItem{},
+		Packages:  []dto.MarketplacePackage{},
+		CreatedAt: order.CreateTime,
+		UpdatedAt: order.UpdateTime,
+		PaidAt:    order.PayTime,
+	}
+	if order.Escrow != nil {
+		out.BuyerShippingFee = order.Escrow.BuyerPaidShippingFee
+		out.SellerVoucher = order.Escrow.VoucherFromSeller
+	}
+
+	// order_item_id is shared by the lines of a bundle : item_id/model_id identifies the line
+	lineID := func(itemID string, modelID string) string {
+		if modelID == "" {
+			modelID = "0"
+		}
+		return itemID + ":" + modelID
+	}
+	packageOf := map[string]string{}
+	for _, p := range order.PackageList {
+		pack := dto.MarketplacePackage{
+			PackageID:      p.PackageNumber,
+			Carrier:        p.ShippingCarrier,
+			TrackingNumber: order.TrackingNumbers[p.PackageNumber],
+			Status:         p.LogisticsStatus,
+		}
+		for _, it := range p.ItemList {
+			id := lineID(it.ItemID, it.ModelID)
+			pack.LineIDs = append(pack.LineIDs, id)
+			packageOf[id] = p.PackageNumber
+		}
+		out.Packages = append(out.Packages, pack)
+	}
+	for _, it := range order.ItemList {
+		id := lineID(it.ItemID, it.ModelID)
+		sku := it.ModelSKU
+		if sku == "" {
+			sku = it.ItemSKU
+		}
+		variantID := it.ModelID
+		if variantID == "0" {
+			variantID = ""
+		}
+		out.Items = append(out.Items, dto.MarketplaceOrderItem{
+			LineID:      id,
+			ItemID:      it.ItemID,
+			VariantID:   variantID,
+			SKU:         sku,
+			Name:        it.ItemName,
+			VariantName: it.ModelName,
+			Quantity:    it.ModelQualityPurchased,
+			UnitPrice:   it.ModelOriginPrice,
+			PaidPrice:   it.ModelDiscountedPrice,
+			ImageURL:    it.ImageInfo.ImageURL,
+			PackageID:   packageOf[id],
+		})
+	}
+	return out
+}
+
+// SyncMarketplaceOrders : orders updated since the shop high-water mark, every page, stored one by one ;
+// the mark only moves once the whole window went through. Shopee has its own sync (shopee order sync).
+func (s *marketplaceService) SyncMarketplaceOrders(ctx context.Context, channel dto.MarketplaceChannelEnum, shopID string) (int, error) {
+	market, err := s.market(channel)
+	if err != nil {
+		return 0, err
+	}
+	lock, _ := s.syncLocks.LoadOrStore(string(channel)+":"+shopID, &sync.Mutex{})
+	mu := lock.(*sync.Mutex)
+	if !mu.TryLock() {
+		return 0, fmt.Errorf("usecase.SyncMarketplaceOrders : sync already running for shop %s", shopID)
+	}
+	defer mu.Unlock()
+
+	auth, err := s.MarketplaceShopAuthRepository.GetMarketplaceShopAuth(ctx, channel, shopID)
+	if err != nil {
+		return 0, err
+	}
+	creds, err := s.GetMarketplaceCredentials(ctx, channel, shopID)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	from := auth.OrdersSyncedTo.Add(-marketplaceOrderSyncOverlap)
+	if auth.OrdersSyncedTo.IsZero() {
+		from = now.AddDate(0, 0, -int(s.Config.Lazada.LazadaOrderSyncLookbackDays))
+	}
+
+	count := 0
+	q := &dto.MarketplaceOrderQuery{UpdatedFrom: from, UpdatedTo: now, PageSize: marketplaceOrderPageSize}
+	for {
+		page, err := market.ListOrders(ctx, creds, q)
+		if err != nil {
+			return count, err
+		}
+		for i := range page.Orders {
+			if _, err := s.SaveMarketplaceOrder(ctx, &page.Orders[i]); err != nil {
+				return count, err
+			}
+			count++
+		}
+		if !page.More || page.NextCursor == "" {
+			break
+		}
+		q.Cursor = page.NextCursor
+	}
+	return count, s.MarketplaceShopAuthRepository.SetMarketplaceShopOrdersSyncedTo(ctx, channel, shopID, now)
+}
+
+// MarketplaceOrderSyncWorker : runs SyncMarketplaceOrders for every authorized shop of the channels
+// without their own sync (Lazada, ...)
+type IMarketplaceOrderSyncWorker interface {
+	Start(ctx context.Context)
+	RunOnce(ctx context.Context) error
+}
+
+type marketplaceOrderSyncWorker struct {
+	Config *env.Config
+	Logger *zap.Logger
+
+	MarketplaceService            IMarketplaceService
+	MarketplaceShopAuthRepository MarketplaceShopAuthRepository
+}
+
+func NewMarketplaceOrderSyncWorker(cfg *env.Config, logger *zap.Logger, service IMarketplaceService, shopAuth MarketplaceShopAuthRepository) IMarketplaceOrderSyncWorker {
+	return &marketplaceOrderSyncWorker{
+		Config:                        cfg,
+		Logger:                        logger,
+		MarketplaceService:            service,
+		MarketplaceShopAuthRepository: shopAuth,
+	}
+}
+
+func (w *marketplaceOrderSyncWorker) Start(ctx context.Context) {
+	interval := time.Duration(w.Config.Lazada.LazadaOrderSyncInterval) * time.Minute
+	if interval <= 0 {
+		w.Logger.Info("worker.MarketplaceOrderSync : disabled")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := w.RunOnce(ctx); err != nil {
+				w.Logger.Error("worker.MarketplaceOrderSync : RunOnce error", zap.Error(err))
+			}
+
+			select {
+			case <-ctx.Done():
+				w.Logger.Info("worker.MarketplaceOrderSync : stopped")
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// RunOnce : ctx sees every tenant, each shop is synced in the tenant owning it
+func (w *marketplaceOrderSyncWorker) RunOnce(ctx context.Context) error {
+	channels, err := w.MarketplaceService.GetMarketplaceChannels(ctx)
+	if err != nil {
+		return err
+	}
+	for _, channel := range channels {
+		if channel == dto.CHANNEL_SHOPEE {
+			continue
+		}
+		shops, err := w.MarketplaceShopAuthRepository.GetMarketplaceShopAuths(ctx, channel)
+		if err != nil {
+			return err
+		}
+		for _, shop := range shops {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if shop.NeedReauth {
+				continue
+			}
+			count, err := w.MarketplaceService.SyncMarketplaceOrders(pkg.WithTenant(ctx, shop.TenantID), channel, shop.ShopID)
+			if err != nil {
+				w.Logger.Error("worker.MarketplaceOrderSync : sync failed", zap.String("channel", string(channel)),
+					zap.String("shop_id", shop.ShopID), zap.Error(err))
+				continue
+			}
+			w.Logger.Info("worker.MarketplaceOrderSync : done", zap.String("channel", string(channel)),
+				zap.String("shop_id", shop.ShopID), zap.Int("orders", count))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package marketplace
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"ecommerce/internal/adapter/dto"
+	"ecommerce/internal/application/shopee"
+)
+
+// fakeOrderRepository : keeps the newest copy per order, like the update_time guard of the mongo upsert
+type fakeOrderRepository struct {
+	MarketplaceOrderRepository
+	orders map[string]dto.MarketplaceOrder
+}
+
+func (r *fakeOrderRepository) SaveMarketplaceOrder(ctx context.Context, order *dto.MarketplaceOrder) (*dto.MarketplaceOrder, bool, error) {
+	key := string(order.Channel) + ":" + order.ShopID + ":" + order.OrderID
+	if stored, ok := r.orders[key]; ok && stored.UpdatedAt.After(order.UpdatedAt) {
+		return &stored, false, nil
+	}
+	r.orders[key] = *order
+	return order, true, nil
+}
+
+type recordingListener struct {
+	statuses []dto.MarketplaceOrderStatusEnum
+}
+
+func (l *recordingListener) OnMarketplaceOrderSaved(ctx context.Context, order *dto.MarketplaceOrder) {
+	l.statuses = append(l.statuses, order.Status)
+}
+
+func TestSaveMarketplaceOrderNotifiesWrittenCopies(t *testing.T) {
+	s := &marketplaceService{Logger: zap.NewNop(), MarketplaceOrderRepository: &fakeOrderRepository{orders: map[string]dto.MarketplaceOrder{}}}
+	l := &recordingListener{}
+	s.AddMarketplaceOrderListener(l)
+
+	t0 := time.Date(2026, 5, 1, 10, 0, 0, 0, time.UTC)
+	order := func(status dto.MarketplaceOrderStatusEnum, updated time.Time) *dto.MarketplaceOrder {
+		return &dto.MarketplaceOrder{Channel: dto.CHANNEL_LAZADA, ShopID: "TH1", OrderID: "900", Status: status, UpdatedAt: updated}
+	}
+	ctx := context.Background()
+	for _, o := range []*dto.MarketplaceOrder{
+		order(dto.MP_ORDER_READY_TO_SHIP, t0),
+		order(dto.MP_ORDER_SHIPPED, t0.Add(time.Hour)),
+		// a page read before the ship, stored after it : not written, not heard
+		order(dto.MP_ORDER_READY_TO_SHIP, t0),
+	} {
+		if _, err := s.SaveMarketplaceOrder(ctx, o); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []dto.MarketplaceOrderStatusEnum{dto.MP_ORDER_READY_TO_SHIP, dto.MP_ORDER_SHIPPED}
+	if len(l.statuses) != len(want) {
+		t.Fatalf("heard %v, want %v", l.statuses, want)
+	}
+	for i := range want {
+		if l.statuses[i] != want[i] {
+			t.Errorf("heard %v, want %v", l.statuses, want)
+		}
+	}
+}
+
+func TestShopeeOrderEntityToMarketplace(t *testing.T) {
+	order := &shopee.ShopeeOrderEntity{ShopID: "1", OrderSN: "2405ABC", OrderStatus: shopee.PROCESSED}
+	order.Currency = "THB"
+	order.RecipientAddress.Name = "Somchai"
+	order.ItemList = []shopee.ShopeeItemListEntity{
+		{ItemID: "10", ModelID: "11", ModelSKU: "SKU-1", ItemName: "Shirt", ModelName: "Red", ModelQualityPurchased: 2, ModelOriginPrice: 300, ModelDiscountedPrice: 250},
+		{ItemID: "20", ModelID: "0", ItemSKU: "SKU-2", ItemName: "Cap", ModelQualityPurchased: 1, ModelOriginPrice: 100},
+	}
+	order.PackageList = []shopee.ShopeePackageListEntity{
+		{PackageNumber: "P1", ItemList: []shopee.ShopeeItemListInPackageListEntity{{ItemID: "10", ModelID: "11"}}},
+		{PackageNumber: "P2", ItemList: []shopee.ShopeeItemListInPackageListEntity{{ItemID: "20", ModelID: "0"}}},
+	}
+	order.TrackingNumbers = map[string]string{"P1": "TH123"}
+	order.Escrow = &shopee.ShopeeOrderEscrowEntity{BuyerPaidShippingFee: 40, VoucherFromSeller: 25}
+
+	got := ShopeeOrderEntityToMarketplace(order)
+	if got.Channel != dto.CHANNEL_SHOPEE || got.OrderID != "2405ABC" || got.Status != dto.MP_ORDER_READY_TO_SHIP || got.ChannelStatus != "PROCESSED" {
+		t.Errorf("order %s %s %s/%s", got.Channel, got.OrderID, got.Status, got.ChannelStatus)
+	}
+	if got.BuyerShippingFee != 40 || got.SellerVoucher != 25 {
+		t.Errorf("shipping %v voucher %v, want 40 25", got.BuyerShippingFee, got.SellerVoucher)
+	}
+	if got.ShippingAddress.Name != "Somchai" {
+		t.Errorf("address name %q", got.ShippingAddress.Name)
+	}
+	if len(got.Items) != 2 {
+		t.Fatalf("%d items, want 2", len(got.Items))
+	}
+	if it := got.Items[0]; it.LineID != "10:11" || it.SKU != "SKU-1" || it.Quantity != 2 || it.PaidPrice != 250 || it.PackageID != "P1" {
+		t.Errorf("item 0 %+v", it)
+	}
+	// model 0 : the item has no variants
+	if it := got.Items[1]; it.LineID != "20:0" || it.VariantID != "" || it.SKU != "SKU-2" || it.PackageID != "P2" {
+		t.Errorf("item 1 %+v", it)
+	}
+	if len(got.Packages) != 2 || got.Packages[0].TrackingNumber != "TH123" || got.Packages[1].TrackingNumber != "" {
+		t.Errorf("packages %+v", got.Packages)
+	}
+}
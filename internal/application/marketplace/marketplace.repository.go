@@ -0,0 +1,774 @@
+package marketplace
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"go.uber.org/zap"
+
+	"ecommerce/internal/adapter/dto"
+	"ecommerce/internal/pkg"
+)
+
+// Shopee partners / shop tokens stay in shopee_partner / shopee_shop_auth :
+// these collections hold the channels without their own package (Lazada, ...).
+// marketplace_order is the exception : every channel's orders, in the unified form the ERP side reads.
+
+var (
+	ErrMarketplaceAppNotFound      = errors.New("marketplace app not found")
+	ErrMarketplaceShopAuthNotFound = errors.New("marketplace shop is not authorized")
+	ErrMarketplaceShopOwned        = errors.New("marketplace shop is authorized by another tenant")
+	ErrMarketplaceOrderNotFound    = errors.New("marketplace order not found")
+)
+
+// one seller-center application (Lazada app_key / app_secret) : app_secret is sealed.
+// app_key is unique across tenants, the consent callback finds the app (and its tenant) from it
+type MarketplaceAppModel struct {
+	ID        bson.ObjectID              `bson:"_id"`
+	TenantID  string                     `bson:"tenant_id,omitempty"` // missing for the platform tenant : see pkg.TenantFilter
+	Channel   dto.MarketplaceChannelEnum `bson:"channel"`
+	Name      string                     `bson:"name"`
+	AppKey    string                     `bson:"app_key"`
+	AppSecret string                     `bson:"app_secret"`
+	CreatedAt time.Time                  `bson:"created_at"`
+	CreatedBy string                     `bson:"created_by"`
+	UpdatedAt time.Time                  `bson:"updated_at"`
+	UpdatedBy string                     `bson:"updated_by"`
+}
+
+type MarketplaceAppEntity struct {
+	ID        string                     `json:"id"`
+	TenantID  string                     `json:"-"`
+	Channel   dto.MarketplaceChannelEnum `json:"channel"`
+	Name      string                     `json:"name"`
+	AppKey    string                     `json:"app_key"`
+	AppSecret string                     `json:"app_secret"`
+	CreatedAt time.Time                  `json:"created_at"`
+	CreatedBy string                     `json:"created_by"`
+	UpdatedAt time.Time                  `json:"updated_at"`
+	UpdatedBy string                     `json:"updated_by"`
+}
+
+// Redacted : API answer, the app secret is never sent back in clear
+func (e MarketplaceAppEntity) Redacted() MarketplaceAppEntity {
+	e.AppSecret = pkg.RedactSecret(e.AppSecret)
+	return e
+}
+
+// one authorized shop, owned by the tenant of its app : tokens are sealed
+type MarketplaceShopAuthModel struct {
+	ID               bson.ObjectID              `bson:"_id"`
+	TenantID         string                     `bson:"tenant_id,omitempty"`
+	Channel          dto.MarketplaceChannelEnum `bson:"channel"`
+	ShopID           string                     `bson:"shop_id"`
+	AppKey           string                     `bson:"app_key"`
+	ShopName         string                     `bson:"shop_name"`
+	Account          string                     `bson:"account"`
+	Region           string                     `bson:"region"`
+	AccessToken      string                     `bson:"access_token"`
+	RefreshToken     string                     `bson:"refresh_token"`
+	ExpiresAt        time.Time                  `bson:"expires_at"`
+	RefreshExpiresAt time.Time                  `bson:"refresh_expires_at"`
+	NeedReauth       bool                       `bson:"need_reauth"`
+	LastError        string                     `bson:"last_error"`
+	// order sync high-water mark : orders updated before it are stored
+	OrdersSyncedTo time.Time `bson:"orders_synced_to"`
+	CreatedAt      time.Time `bson:"created_at"`
+	UpdatedAt      time.Time `bson:"updated_at"`
+}
+
+type MarketplaceShopAuthEntity struct {
+	ID               string                     `json:"id"`
+	TenantID         string                     `json:"-"`
+	Channel          dto.MarketplaceChannelEnum `json:"channel"`
+	ShopID           string                     `json:"shop_id"`
+	AppKey           string                     `json:"app_key"`
+	ShopName         string                     `json:"shop_name"`
+	Account          string                     `json:"account"`
+	Region           string                     `json:"region"`
+	AccessToken      string                     `json:"access_token"`
+	RefreshToken     string                     `json:"refresh_token"`
+	ExpiresAt        time.Time                  `json:"expires_at"`
+	RefreshExpiresAt time.Time                  `json:"refresh_expires_at"`
+	NeedReauth       bool                       `json:"need_reauth"`
+	LastError        string                     `json:"last_error"`
+	OrdersSyncedTo   time.Time                  `json:"orders_synced_to"`
+	CreatedAt        time.Time                  `json:"created_at"`
+	UpdatedAt        time.Time                  `json:"updated_at"`
+}
+
+// Redacted : API answer, tokens are never sent back in clear
+func (e MarketplaceShopAuthEntity) Redacted() MarketplaceShopAuthEntity {
+	e.AccessToken = pkg.RedactSecret(e.AccessToken)
+	e.RefreshToken = pkg.RedactSecret(e.RefreshToken)
+	return e
+}
+
+func MarketplaceAppModelToEntity(model *MarketplaceAppModel) *MarketplaceAppEntity {
+	return &MarketplaceAppEntity{
+		ID:        model.ID.Hex(),
+		TenantID:  model.TenantID,
+		Channel:   model.Channel,
+		Name:      model.Name,
+		AppKey:    model.AppKey,
+		AppSecret: model.AppSecret,
+		CreatedAt: model.CreatedAt,
+		CreatedBy: model.CreatedBy,
+		UpdatedAt: model.UpdatedAt,
+		UpdatedBy: model.UpdatedBy,
+	}
+}
+
+func MarketplaceShopAuthModelToEntity(model *MarketplaceShopAuthModel) *MarketplaceShopAuthEntity {
+	return &MarketplaceShopAuthEntity{
+		ID:               model.ID.Hex(),
+		TenantID:         model.TenantID,
+		Channel:          model.Channel,
+		ShopID:           model.ShopID,
+		AppKey:           model.AppKey,
+		ShopName:         model.ShopName,
+		Account:          model.Account,
+		Region:           model.Region,
+		AccessToken:      model.AccessToken,
+		RefreshToken:     model.RefreshToken,
+		ExpiresAt:        model.ExpiresAt,
+		RefreshExpiresAt: model.RefreshExpiresAt,
+		NeedReauth:       model.NeedReauth,
+		LastError:        model.LastError,
+		OrdersSyncedTo:   model.OrdersSyncedTo,
+		CreatedAt:        model.CreatedAt,
+		UpdatedAt:        model.UpdatedAt,
+	}
+}
+
+type MarketplaceOrderItemModel struct {
+	LineID      string  `bson:"line_id"`
+	ItemID      string  `bson:"item_id"`
+	VariantID   string  `bson:"variant_id"`
+	SKU         string  `bson:"sku"`
+	Name        string  `bson:"name"`
+	VariantName string  `bson:"variant_name"`
+	Quantity    int     `bson:"quantity"`
+	UnitPrice   float64 `bson:"unit_price"`
+	PaidPrice   float64 `bson:"paid_price"`
+	ImageURL    string  `bson:"image_url"`
+	PackageID   string  `bson:"package_id"`
+}
+
+type MarketplacePackageModel struct {
+	PackageID      string   `bson:"package_id"`
+	Carrier        string   `bson:"carrier"`
+	TrackingNumber string   `bson:"tracking_number"`
+	Status         string   `bson:"status"`
+	LineIDs        []string `bson:"line_ids"`
+}
+
+type MarketplaceAddressModel struct {
+	Name        string `bson:"name"`
+	Phone       string `bson:"phone"`
+	FullAddress string `bson:"full_address"`
+	District    string `bson:"district"`
+	City        string `bson:"city"`
+	State       string `bson:"state"`
+	PostCode    string `bson:"post_code"`
+	Country     string `bson:"country"`
+}
+
+// one channel order as last seen on the channel, unique per (tenant, channel, shop_id, order_id)
+type MarketplaceOrderModel struct {
+	ID            bson.ObjectID                  `bson:"_id"`
+	TenantID      string                         `bson:"tenant_id,omitempty"`
+	Channel       dto.MarketplaceChannelEnum     `bson:"channel"`
+	ShopID        string                         `bson:"shop_id"`
+	OrderID       string                         `bson:"order_id"`
+	OrderNumber   string                         `bson:"order_number"`
+	Status        dto.MarketplaceOrderStatusEnum `bson:"status"`
+	ChannelStatus string                         `bson:"channel_status"`
+
+	Currency           string  `bson:"currency"`
+	TotalAmount        float64 `bson:"total_amount"`
+	ShippingFee        float64 `bson:"shipping_fee"`
+	BuyerShippingFee   float64 `bson:"buyer_shipping_fee"`
+	SellerVoucher      float64 `bson:"seller_voucher"`
+	PaymentMethod      string  `bson:"payment_method"`
+	COD                bool    `bson:"cod"`
+	FulfilledByChannel bool    `bson:"fulfilled_by_channel"`
+
+	BuyerName       string                  `bson:"buyer_name"`
+	BuyerNote       string                  `bson:"buyer_note"`
+	ShippingAddress MarketplaceAddressModel `bson:"shipping_address"`
+
+	Items    []MarketplaceOrderItemModel `bson:"items"`
+	Packages []MarketplacePackageModel   `bson:"packages"`
+
+	CreatedAt time.Time `bson:"create_time"`
+	UpdatedAt time.Time `bson:"update_time"`
+	PaidAt    time.Time `bson:"pay_time"`
+	ShipBy    time.Time `bson:"ship_by"`
+	SyncedAt  time.Time `bson:"synced_at"`
+}
+
+func MarketplaceOrderModelToDTO(model *MarketplaceOrderModel) *dto.MarketplaceOrder {
+	items := make([]dto.MarketplaceOrderItem, 0, len(model.Items))
+	for _, it := range model.Items {
+		items = append(items, dto.MarketplaceOrderItem(it))
+	}
+	packages := make([]dto.MarketplacePackage, 0, len(model.Packages))
+	for _, p := range model.Packages {
+		packages = append(packages, dto.MarketplacePackage(p))
+	}
+	return &dto.MarketplaceOrder{
+		Channel:            model.Channel,
+		ShopID:             model.ShopID,
+		OrderID:            model.OrderID,
+		OrderNumber:        model.OrderNumber,
+		Status:             model.Status,
+		ChannelStatus:      model.ChannelStatus,
+		Currency:           model.Currency,
+		TotalAmount:        model.TotalAmount,
+		ShippingFee:        model.ShippingFee,
+		PaymentMethod:      model.PaymentMethod,
+		COD:                model.COD,
+		BuyerShippingFee:   model.BuyerShippingFee,
+		SellerVoucher:      model.SellerVoucher,
+		FulfilledByChannel: model.FulfilledByChannel,
+		BuyerName:          model.BuyerName,
+		BuyerNote:          model.BuyerNote,
+		ShippingAddress:    dto.MarketplaceAddress(model.ShippingAddress),
+		Items:              items,
+		Packages:           packages,
+		CreatedAt:          model.CreatedAt,
+		UpdatedAt:          model.UpdatedAt,
+		PaidAt:             model.PaidAt,
+		ShipBy:             model.ShipBy,
+	}
+}
+
+func MarketplaceOrderDTOToModel(order *dto.MarketplaceOrder) *MarketplaceOrderModel {
+	items := make([]MarketplaceOrderItemModel, 0, len(order.Items))
+	for _, it := range order.Items {
+		items = append(items, MarketplaceOrderItemModel(it))
+	}
+	packages := make([]MarketplacePackageModel, 0, len(order.Packages))
+	for _, p := range order.Packages {
+		packages = append(packages, MarketplacePackageModel(p))
+	}
+	return &MarketplaceOrderModel{
+		Channel:            order.Channel,
+		ShopID:             order.ShopID,
+		OrderID:            order.OrderID,
+		OrderNumber:        order.OrderNumber,
+		Status:             order.Status,
+		ChannelStatus:      order.ChannelStatus,
+		Currency:           order.Currency,
+		TotalAmount:        order.TotalAmount,
+		ShippingFee:        order.ShippingFee,
+		BuyerShippingFee:   order.BuyerShippingFee,
+		SellerVoucher:      order.SellerVoucher,
+		PaymentMethod:      order.PaymentMethod,
+		COD:                order.COD,
+		FulfilledByChannel: order.FulfilledByChannel,
+		BuyerName:          order.BuyerName,
+		BuyerNote:          order.BuyerNote,
+		ShippingAddress:    MarketplaceAddressModel(order.ShippingAddress),
+		Items:              items,
+		Packages:           packages,
+		CreatedAt:          order.CreatedAt,
+		UpdatedAt:          order.UpdatedAt,
+		PaidAt:             order.PaidAt,
+		ShipBy:             order.ShipBy,
+	}
+}
+
+type MarketplaceAppRepository interface {
+	InitRepository() error
+	CreateMarketplaceApp(ctx context.Context, app *MarketplaceAppEntity) (*MarketplaceAppEntity, error)
+	GetMarketplaceApps(ctx context.Context, channel dto.MarketplaceChannelEnum) ([]MarketplaceAppEntity, error)
+	GetMarketplaceApp(ctx context.Context, channel dto.MarketplaceChannelEnum, appKey string) (*MarketplaceAppEntity, error)
+
+	// cmd/rotatekeys : re-encrypt app_secret with the active master key
+	RotateMarketplaceAppSecrets(ctx context.Context, dryRun bool) (*pkg.SecretRotationResult, error)
+}
+
+type MarketplaceShopAuthRepository interface {
+	InitRepository() error
+	// upsert on (channel, shop_id) : a new consent replaces the tokens and clears need_reauth ;
+	// ErrMarketplaceShopOwned when the shop belongs to another tenant
+	UpsertMarketplaceShopAuth(ctx context.Context, auth *MarketplaceShopAuthEntity) (*MarketplaceShopAuthEntity, error)
+	GetMarketplaceShopAuth(ctx context.Context, channel dto.MarketplaceChannelEnum, shopID string) (*MarketplaceShopAuthEntity, error)
+	GetMarketplaceShopAuths(ctx context.Context, channel dto.MarketplaceChannelEnum) ([]MarketplaceShopAuthEntity, error)
+	// refresh failed for good : flag the shop, tokens are kept for the record
+	SetMarketplaceShopNeedReauth(ctx context.Context, channel dto.MarketplaceChannelEnum, shopID string, reason string) error
+	SetMarketplaceShopOrdersSyncedTo(ctx context.Context, channel dto.MarketplaceChannelEnum, shopID string, syncedTo time.Time) error
+
+	// cmd/rotatekeys : re-encrypt access_token / refresh_token with the active master key
+	RotateMarketplaceShopAuthSecrets(ctx context.Context, dryRun bool) (*pkg.SecretRotationResult, error)
+}
+
+// filter of GetMarketplaceOrders : empty fields match everything
+type MarketplaceOrderFilter struct {
+	Channel dto.MarketplaceChannelEnum
+	ShopIDs []string
+	Status  dto.MarketplaceOrderStatusEnum
+	Skip    int64
+	Limit   int64
+}
+
+type MarketplaceOrderRepository interface {
+	InitRepository() error
+	// upsert on (channel, shop_id, order_id) in the tenant of ctx ; an older copy (update time before
+	// the stored one) is not written, the stored order is returned with false
+	SaveMarketplaceOrder(ctx context.Context, order *dto.MarketplaceOrder) (*dto.MarketplaceOrder, bool, error)
+	GetMarketplaceOrder(ctx context.Context, channel dto.MarketplaceChannelEnum, shopID string, orderID string) (*dto.MarketplaceOrder, error)
+	// oldest paid first
+	GetMarketplaceOrders(ctx context.Context, filter *MarketplaceOrderFilter) ([]dto.MarketplaceOrder, error)
+}
+
+type marketplaceAppRepository struct {
+	Logger *zap.Logger
+	DB     *mongo.Collection
+	Cipher pkg.ISecretCipher
+}
+
+func NewMarketplaceAppRepository(db *mongo.Collection, log *zap.Logger, cipher pkg.ISecretCipher) MarketplaceAppRepository {
+	return &marketplaceAppRepository{Logger: log, DB: db, Cipher: cipher}
+}
+
+func (r *marketplaceAppRepository) InitRepository() error {
+	indexs := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "channel", Value: 1}, {Key: "app_key", Value: 1}}, Options: options.Index().SetUnique(true)},
+	}
+	if _, err := r.DB.Indexes().CreateMany(context.TODO(), indexs); err != nil {
+		return errors.New("MarketplaceAppRepository.InitRepository: failed create indexs")
+	}
+	r.Logger.Info("MarketplaceAppRepository.InitRepository: index created")
+	return nil
+}
+
+func (r *marketplaceAppRepository) open(model *MarketplaceAppModel) error {
+	plain, err := r.Cipher.Decrypt(model.AppSecret)
+	if err != nil {
+		r.Logger.Error("MarketplaceAppRepository: failed to decrypt app_secret", zap.String("app_key", model.AppKey), zap.Error(err))
+		return errors.New("MarketplaceAppRepository: failed to decrypt app_secret")
+	}
+	model.AppSecret = plain
+	return nil
+}
+
+func (r *marketplaceAppRepository) CreateMarketplaceApp(ctx context.Context, app *MarketplaceAppEntity) (*MarketplaceAppEntity, error) {
+	tenantID, err := pkg.TenantStamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := r.Cipher.Encrypt(app.AppSecret)
+	if err != nil {
+		return nil, errors.New("MarketplaceAppRepository: failed to encrypt app_secret")
+	}
+	now := time.Now()
+	model := &MarketplaceAppModel{
+		ID:        bson.NewObjectID(),
+		TenantID:  tenantID,
+		Channel:   app.Channel,
+		Name:      app.Name,
+		AppKey:    app.AppKey,
+		AppSecret: sealed,
+		CreatedAt: now,
+		CreatedBy: app.CreatedBy,
+		UpdatedAt: now,
+		UpdatedBy: app.CreatedBy,
+	}
+	if _, err := r.DB.InsertOne(ctx, model); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, errors.New("duplicate app_key:" + app.AppKey)
+		}
+		return nil, err
+	}
+	model.AppSecret = app.AppSecret
+	return MarketplaceAppModelToEntity(model), nil
+}
+
+func (r *marketplaceAppRepository) GetMarketplaceApps(ctx context.Context, channel dto.MarketplaceChannelEnum) ([]MarketplaceAppEntity, error) {
+	cursor, err := r.DB.Find(ctx, pkg.TenantFilter(ctx, bson.M{"channel": channel}), options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var models []MarketplaceAppModel
+	if err := cursor.All(ctx, &models); err != nil {
+		return nil, err
+	}
+	out := make([]MarketplaceAppEntity, 0, len(models))
+	for i := range models {
+		if err := r.open(&models[i]); err != nil {
+			return nil, err
+		}
+		out = append(out, *MarketplaceAppModelToEntity(&models[i]))
+	}
+	return out, nil
+}
+
+func (r *marketplaceAppRepository) GetMarketplaceApp(ctx context.Context, channel dto.MarketplaceChannelEnum, appKey string) (*MarketplaceAppEntity, error) {
+	var model MarketplaceAppModel
+	if err := r.DB.FindOne(ctx, pkg.TenantFilter(ctx, bson.M{"channel": channel, "app_key": appKey})).Decode(&model); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrMarketplaceAppNotFound
+		}
+		return nil, err
+	}
+	if err := r.open(&model); err != nil {
+		return nil, err
+	}
+	return MarketplaceAppModelToEntity(&model), nil
+}
+
+func (r *marketplaceAppRepository) RotateMarketplaceAppSecrets(ctx context.Context, dryRun bool) (*pkg.SecretRotationResult, error) {
+	res := &pkg.SecretRotationResult{Collection: r.DB.Name()}
+
+	cursor, err := r.DB.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var model MarketplaceAppModel
+		if err := cursor.Decode(&model); err != nil {
+			return res, err
+		}
+		res.Scanned++
+		if !r.Cipher.NeedsRotation(model.AppSecret) {
+			continue
+		}
+		sealed, err := pkg.ReencryptSecret(r.Cipher, model.AppSecret)
+		if err != nil {
+			r.Logger.Error("MarketplaceAppRepository.RotateMarketplaceAppSecrets", zap.String("app_key", model.AppKey), zap.Error(err))
+			res.Failed++
+			continue
+		}
+		if dryRun {
+			res.Rotated++
+			continue
+		}
+
+		// only if nobody rewrote it meanwhile
+		filter := bson.M{"_id": model.ID, "app_secret": model.AppSecret}
+		upd, err := r.DB.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"app_secret": sealed}})
+		if err != nil {
+			return res, err
+		}
+		if upd.ModifiedCount == 1 {
+			res.Rotated++
+		} else {
+			res.Skipped++
+		}
+	}
+	return res, cursor.Err()
+}
+
+type marketplaceShopAuthRepository struct {
+	Logger *zap.Logger
+	DB     *mongo.Collection
+	Cipher pkg.ISecretCipher
+}
+
+func NewMarketplaceShopAuthRepository(db *mongo.Collection, log *zap.Logger, cipher pkg.ISecretCipher) MarketplaceShopAuthRepository {
+	return &marketplaceShopAuthRepository{Logger: log, DB: db, Cipher: cipher}
+}
+
+func (r *marketplaceShopAuthRepository) InitRepository() error {
+	indexs := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "channel", Value: 1}, {Key: "shop_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}},
+	}
+	if _, err := r.DB.Indexes().CreateMany(context.TODO(), indexs); err != nil {
+		return errors.New("MarketplaceShopAuthRepository.InitRepository: failed create indexs")
+	}
+	r.Logger.Info("MarketplaceShopAuthRepository.InitRepository: index created")
+	return nil
+}
+
+func (r *marketplaceShopAuthRepository) open(model *MarketplaceShopAuthModel) error {
+	access, err := r.Cipher.Decrypt(model.AccessToken)
+	if err == nil {
+		model.AccessToken = access
+		model.RefreshToken, err = r.Cipher.Decrypt(model.RefreshToken)
+	}
+	if err != nil {
+		r.Logger.Error("MarketplaceShopAuthRepository: failed to decrypt tokens", zap.String("shop_id", model.ShopID), zap.Error(err))
+		return errors.New("MarketplaceShopAuthRepository: failed to decrypt tokens")
+	}
+	return nil
+}
+
+func (r *marketplaceShopAuthRepository) UpsertMarketplaceShopAuth(ctx context.Context, auth *MarketplaceShopAuthEntity) (*MarketplaceShopAuthEntity, error) {
+	access, err := r.Cipher.Encrypt(auth.AccessToken)
+	if err != nil {
+		return nil, errors.New("MarketplaceShopAuthRepository: failed to encrypt access_token")
+	}
+	refresh, err := r.Cipher.Encrypt(auth.RefreshToken)
+	if err != nil {
+		return nil, errors.New("MarketplaceShopAuthRepository: failed to encrypt refresh_token")
+	}
+
+	now := time.Now()
+	set := bson.M{
+		"app_key":            auth.AppKey,
+		"account":            auth.Account,
+		"region":             auth.Region,
+		"access_token":       access,
+		"refresh_token":      refresh,
+		"expires_at":         auth.ExpiresAt,
+		"refresh_expires_at": auth.RefreshExpiresAt,
+		"need_reauth":        false,
+		"last_error":         "",
+		"updated_at":         now,
+	}
+	if auth.ShopName != "" {
+		set["shop_name"] = auth.ShopName
+	}
+	// the shop is unique across tenants : another tenant's shop fails the insert on the unique index
+	filter := pkg.TenantFilter(ctx, bson.M{"channel": auth.Channel, "shop_id": auth.ShopID})
+	onInsert := bson.M{"_id": bson.NewObjectID(), "created_at": now}
+	tenantID, err := pkg.TenantStamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if tenantID != "" {
+		onInsert["tenant_id"] = tenantID
+	}
+	update := bson.M{
+		"$set":         set,
+		"$setOnInsert": onInsert,
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var model MarketplaceShopAuthModel
+	if err := r.DB.FindOneAndUpdate(ctx, filter, update, opts).Decode(&model); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, ErrMarketplaceShopOwned
+		}
+		return nil, err
+	}
+	model.AccessToken, model.RefreshToken = auth.AccessToken, auth.RefreshToken
+	return MarketplaceShopAuthModelToEntity(&model), nil
+}
+
+func (r *marketplaceShopAuthRepository) GetMarketplaceShopAuth(ctx context.Context, channel dto.MarketplaceChannelEnum, shopID string) (*MarketplaceShopAuthEntity, error) {
+	var model MarketplaceShopAuthModel
+	if err := r.DB.FindOne(ctx, pkg.TenantFilter(ctx, bson.M{"channel": channel, "shop_id": shopID})).Decode(&model); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrMarketplaceShopAuthNotFound
+		}
+		return nil, err
+	}
+	if err := r.open(&model); err != nil {
+		return nil, err
+	}
+	return MarketplaceShopAuthModelToEntity(&model), nil
+}
+
+// GetMarketplaceShopAuths : a shop whose tokens can't be opened is skipped, not the whole list
+func (r *marketplaceShopAuthRepository) GetMarketplaceShopAuths(ctx context.Context, channel dto.MarketplaceChannelEnum) ([]MarketplaceShopAuthEntity, error) {
+	cursor, err := r.DB.Find(ctx, pkg.TenantFilter(ctx, bson.M{"channel": channel}), options.Find().SetSort(bson.D{{Key: "shop_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var models []MarketplaceShopAuthModel
+	if err := cursor.All(ctx, &models); err != nil {
+		return nil, err
+	}
+	out := make([]MarketplaceShopAuthEntity, 0, len(models))
+	for i := range models {
+		if err := r.open(&models[i]); err != nil {
+			continue
+		}
+		out = append(out, *MarketplaceShopAuthModelToEntity(&models[i]))
+	}
+	return out, nil
+}
+
+func (r *marketplaceShopAuthRepository) SetMarketplaceShopNeedReauth(ctx context.Context, channel dto.MarketplaceChannelEnum, shopID string, reason string) error {
+	_, err := r.DB.UpdateOne(ctx,
+		pkg.TenantFilter(ctx, bson.M{"channel": channel, "shop_id": shopID}),
+		bson.M{"$set": bson.M{"need_reauth": true, "last_error": reason, "updated_at": time.Now()}})
+	return err
+}
+
+func (r *marketplaceShopAuthRepository) SetMarketplaceShopOrdersSyncedTo(ctx context.Context, channel dto.MarketplaceChannelEnum, shopID string, syncedTo time.Time) error {
+	_, err := r.DB.UpdateOne(ctx,
+		pkg.TenantFilter(ctx, bson.M{"channel": channel, "shop_id": shopID}),
+		bson.M{"$set": bson.M{"orders_synced_to": syncedTo}})
+	return err
+}
+
+func (r *marketplaceShopAuthRepository) RotateMarketplaceShopAuthSecrets(ctx context.Context, dryRun bool) (*pkg.SecretRotationResult, error) {
+	res := &pkg.SecretRotationResult{Collection: r.DB.Name()}
+
+	cursor, err := r.DB.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var model MarketplaceShopAuthModel
+		if err := cursor.Decode(&model); err != nil {
+			return res, err
+		}
+		res.Scanned++
+		if !r.Cipher.NeedsRotation(model.AccessToken) && !r.Cipher.NeedsRotation(model.RefreshToken) {
+			continue
+		}
+		access, err := pkg.ReencryptSecret(r.Cipher, model.AccessToken)
+		if err == nil {
+			var refresh string
+			if refresh, err = pkg.ReencryptSecret(r.Cipher, model.RefreshToken); err == nil {
+				if dryRun {
+					res.Rotated++
+					continue
+				}
+				// only if nobody refreshed the tokens meanwhile
+				filter := bson.M{"_id": model.ID, "access_token": model.AccessToken, "refresh_token": model.RefreshToken}
+				upd, err := r.DB.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"access_token": access, "refresh_token": refresh}})
+				if err != nil {
+					return res, err
+				}
+				if upd.ModifiedCount == 1 {
+					res.Rotated++
+				} else {
+					res.Skipped++
+				}
+				continue
+			}
+		}
+		r.Logger.Error("MarketplaceShopAuthRepository.RotateMarketplaceShopAuthSecrets", zap.String("shop_id", model.ShopID), zap.Error(err))
+		res.Failed++
+	}
+	return res, cursor.Err()
+}
+
+type marketplaceOrderRepository struct {
+	Logger *zap.Logger
+	DB     *mongo.Collection
+}
+
+func NewMarketplaceOrderRepository(db *mongo.Collection, log *zap.Logger) MarketplaceOrderRepository {
+	return &marketplaceOrderRepository{Logger: log, DB: db}
+}
+
+func (r *marketplaceOrderRepository) InitRepository() error {
+	indexs := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "tenant_id", Value: 1}, {Key: "channel", Value: 1}, {Key: "shop_id", Value: 1}, {Key: "order_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "status", Value: 1}, {Key: "pay_time", Value: 1}}},
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "channel", Value: 1}, {Key: "shop_id", Value: 1}, {Key: "update_time", Value: 1}}},
+	}
+	if _, err := r.DB.Indexes().CreateMany(context.TODO(), indexs); err != nil {
+		return errors.New("MarketplaceOrderRepository.InitRepository: failed create indexs")
+	}
+	r.Logger.Info("MarketplaceOrderRepository.InitRepository: index created")
+	return nil
+}
+
+func (r *marketplaceOrderRepository) SaveMarketplaceOrder(ctx context.Context, order *dto.MarketplaceOrder) (*dto.MarketplaceOrder, bool, error) {
+	key := pkg.TenantFilter(ctx, bson.M{"channel": order.Channel, "shop_id": order.ShopID, "order_id": order.OrderID})
+
+	model := MarketplaceOrderDTOToModel(order)
+	model.SyncedAt = time.Now()
+	set, err := bson.Marshal(model)
+	if err != nil {
+		return nil, false, err
+	}
+	var fields bson.M
+	if err := bson.Unmarshal(set, &fields); err != nil {
+		return nil, false, err
+	}
+	delete(fields, "_id")
+	delete(fields, "tenant_id")
+
+	onInsert := bson.M{"_id": bson.NewObjectID()}
+	tenantID, err := pkg.TenantStamp(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	if tenantID != "" {
+		onInsert["tenant_id"] = tenantID
+	}
+
+	// only over an older (or same) copy : a stale page read after a push must not roll the status back
+	filter := bson.M{}
+	for k, v := range key {
+		filter[k] = v
+	}
+	filter["update_time"] = bson.M{"$lte": order.UpdatedAt}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var saved MarketplaceOrderModel
+	err = r.DB.FindOneAndUpdate(ctx, filter, bson.M{"$set": fields, "$setOnInsert": onInsert}, opts).Decode(&saved)
+	if err == nil {
+		return MarketplaceOrderModelToDTO(&saved), true, nil
+	}
+	if !mongo.IsDuplicateKeyError(err) {
+		return nil, false, err
+	}
+
+	// the stored copy is newer : the upsert collided with it on the unique key
+	if err := r.DB.FindOne(ctx, key).Decode(&saved); err != nil {
+		return nil, false, err
+	}
+	return MarketplaceOrderModelToDTO(&saved), false, nil
+}
+
+func (r *marketplaceOrderRepository) GetMarketplaceOrder(ctx context.Context, channel dto.MarketplaceChannelEnum, shopID string, orderID string) (*dto.MarketplaceOrder, error) {
+	var model MarketplaceOrderModel
+	filter := pkg.TenantFilter(ctx, bson.M{"channel": channel, "shop_id": shopID, "order_id": orderID})
+	if err := r.DB.FindOne(ctx, filter).Decode(&model); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrMarketplaceOrderNotFound
+		}
+		return nil, err
+	}
+	return MarketplaceOrderModelToDTO(&model), nil
+}
+
+func (r *marketplaceOrderRepository) GetMarketplaceOrders(ctx context.Context, filter *MarketplaceOrderFilter) ([]dto.MarketplaceOrder, error) {
+	query := pkg.TenantFilter(ctx, bson.M{})
+	if filter.Channel != "" {
+		query["channel"] = filter.Channel
+	}
+	if len(filter.ShopIDs) > 0 {
+		query["shop_id"] = bson.M{"$in": filter.ShopIDs}
+	}
+	if filter.Status != "" {
+		query["status"] = filter.Status
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "pay_time", Value: 1}, {Key: "create_time", Value: 1}}).SetSkip(filter.Skip)
+	if filter.Limit > 0 {
+		opts.SetLimit(filter.Limit)
+	}
+
+	cursor, err := r.DB.Find(ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var models []MarketplaceOrderModel
+	if err := cursor.All(ctx, &models); err != nil {
+		return nil, err
+	}
+	out := make([]dto.MarketplaceOrder, 0, len(models))
+	for i := range models {
+		out = append(out, *MarketplaceOrderModelToDTO(&models[i]))
+	}
+	return out, nil
+}
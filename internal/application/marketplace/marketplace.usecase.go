@@ -0,0 +1,425 @@
+package marketplace
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"ecommerce/internal/adapter"
+	"ecommerce/internal/adapter/dto"
+	"ecommerce/internal/application/shopee"
+	"ecommerce/internal/env"
+	"ecommerce/internal/pkg"
+)
+
+const (
+	// consent link lifetime : the state is refused after that
+	marketplaceAuthStateTTL = time.Hour
+	// tokens closer than that to expiry are refreshed before the call
+	marketplaceTokenRefreshBefore = 10 * time.Minute
+)
+
+var (
+	ErrMarketplaceInvalidState    = errors.New("invalid or expired marketplace auth state")
+	ErrMarketplaceShopNeedReauth  = errors.New("marketplace shop must be re-authorized")
+	errMarketplaceShopeeManagedBy = fmt.Errorf("%w : shopee partners and shops are managed under /shopee", adapter.ErrMarketplaceUnsupported)
+)
+
+type IMarketplaceService interface {
+	GetMarketplaceChannels(ctx context.Context) ([]dto.MarketplaceChannelEnum, error)
+
+	// seller-center apps (not Shopee : see /shopee/partner)
+	CreateMarketplaceApp(ctx context.Context, channel dto.MarketplaceChannelEnum, actor string, req *IReqMarketplaceApp) (*MarketplaceAppEntity, error)
+	GetMarketplaceApps(ctx context.Context, channel dto.MarketplaceChannelEnum) ([]MarketplaceAppEntity, error)
+
+	// consent link ; the redirect lands on MarketplaceAuthCallback with a signed state
+	GenerateMarketplaceAuthLink(ctx context.Context, channel dto.MarketplaceChannelEnum, appKey string) (string, error)
+	MarketplaceAuthCallback(ctx context.Context, channel dto.MarketplaceChannelEnum, code string, state string) (*MarketplaceShopAuthEntity, error)
+	GetMarketplaceShops(ctx context.Context, channel dto.MarketplaceChannelEnum) ([]MarketplaceShopAuthEntity, error)
+
+	// channel-neutral operations, Shopee included
+	GetMarketplaceShop(ctx context.Context, channel dto.MarketplaceChannelEnum, shopID string) (*dto.MarketplaceShop, error)
+	GetMarketplaceOrders(ctx context.Context, channel dto.MarketplaceChannelEnum, shopID string, q *dto.MarketplaceOrderQuery) (*dto.MarketplaceOrderPage, error)
+	GetMarketplaceOrder(ctx context.Context, channel dto.MarketplaceChannelEnum, shopID string, orderID string) (*dto.MarketplaceOrder, error)
+	GetMarketplaceItems(ctx context.Context, channel dto.MarketplaceChannelEnum, shopID string, q *dto.MarketplaceItemQuery) (*dto.MarketplaceItemPage, error)
+	UpdateMarketplaceStock(ctx context.Context, channel dto.MarketplaceChannelEnum, shopID string, updates []dto.MarketplaceStockUpdate) ([]dto.MarketplaceStockResult, error)
+	ShipMarketplaceOrder(ctx context.Context, channel dto.MarketplaceChannelEnum, shopID string, req *dto.MarketplaceShipRequest) (*dto.MarketplaceShipResult, error)
+
+	// credentials with a valid access token (refreshed when needed)
+	GetMarketplaceCredentials(ctx context.Context, channel dto.MarketplaceChannelEnum, shopID string) (*adapter.IReqMarketplaceAdapter, error)
+
+	// stored orders : what inventory, fulfillment and invoicing read, whatever the channel.
+	// Stored Shopee orders are mirrored (shopee order listener), other channels are stored by
+	// SyncMarketplaceOrders and by the live order reads above.
+	shopee.IShopeeOrderListener
+	AddMarketplaceOrderListener(listener IMarketplaceOrderListener)
+	SaveMarketplaceOrder(ctx context.Context, order *dto.MarketplaceOrder) (*dto.MarketplaceOrder, error)
+	// read from the channel (and stored) when not stored yet
+	GetStoredMarketplaceOrder(ctx context.Context, channel dto.MarketplaceChannelEnum, shopID string, orderID string) (*dto.MarketplaceOrder, error)
+	SyncMarketplaceOrders(ctx context.Context, channel dto.MarketplaceChannelEnum, shopID string) (int, error)
+}
+
+type marketplaceService struct {
+	Config *env.Config
+	Logger *zap.Logger
+
+	Registry                      *adapter.MarketplaceRegistry
+	ShopeeService                 shopee.IShopeeService
+	MarketplaceAppRepository      MarketplaceAppRepository
+	MarketplaceShopAuthRepository MarketplaceShopAuthRepository
+	MarketplaceOrderRepository    MarketplaceOrderRepository
+
+	// channel:shopID -> *sync.Mutex : one refresh_token exchange at a time per shop
+	refreshLocks sync.Map
+	// channel:shopID -> *sync.Mutex : one order sync at a time per shop
+	syncLocks      sync.Map
+	orderListeners []IMarketplaceOrderListener
+}
+
+func NewMarketplaceService(cfg *env.Config, logger *zap.Logger,
+	registry *adapter.MarketplaceRegistry,
+	shopeeService shopee.IShopeeService,
+	appRepo MarketplaceAppRepository,
+	shopAuthRepo MarketplaceShopAuthRepository,
+	orderRepo MarketplaceOrderRepository,
+) IMarketplaceService {
+	return &marketplaceService{
+		Config:                        cfg,
+		Logger:                        logger,
+		Registry:                      registry,
+		ShopeeService:                 shopeeService,
+		MarketplaceAppRepository:      appRepo,
+		MarketplaceShopAuthRepository: shopAuthRepo,
+		MarketplaceOrderRepository:    orderRepo,
+	}
+}
+
+func (s *marketplaceService) GetMarketplaceChannels(ctx context.Context) ([]dto.MarketplaceChannelEnum, error) {
+	return s.Registry.Channels(), nil
+}
+
+// market : implementation of a channel whose apps / shops live in this package
+func (s *marketplaceService) market(channel dto.MarketplaceChannelEnum) (adapter.IMarketplace, error) {
+	if channel == dto.CHANNEL_SHOPEE {
+		return nil, errMarketplaceShopeeManagedBy
+	}
+	return s.Registry.Get(channel)
+}
+
+func (s *marketplaceService) CreateMarketplaceApp(ctx context.Context, channel dto.MarketplaceChannelEnum, actor string, req *IReqMarketplaceApp) (*MarketplaceAppEntity, error) {
+	if _, err := s.market(channel); err != nil {
+		return nil, err
+	}
+	app, err := s.MarketplaceAppRepository.CreateMarketplaceApp(ctx, &MarketplaceAppEntity{
+		Channel:   channel,
+		Name:      req.Name,
+		AppKey:    req.AppKey,
+		AppSecret: req.AppSecret,
+		CreatedBy: actor,
+	})
+	if err != nil {
+		return nil, err
+	}
+	redacted := app.Redacted()
+	return &redacted, nil
+}
+
+func (s *marketplaceService) GetMarketplaceApps(ctx context.Context, channel dto.MarketplaceChannelEnum) ([]MarketplaceAppEntity, error) {
+	if _, err := s.market(channel); err != nil {
+		return nil, err
+	}
+	apps, err := s.MarketplaceAppRepository.GetMarketplaceApps(ctx, channel)
+	if err != nil {
+		return nil, err
+	}
+	for i := range apps {
+		apps[i] = apps[i].Redacted()
+	}
+	return apps, nil
+}
+
+// authStateSign : HMAC(app secret, channel|app key|ts), the callback has no session to compare with
+func authStateSign(appSecret string, channel dto.MarketplaceChannelEnum, appKey string, ts string) string {
+	h := hmac.New(sha256.New, []byte(appSecret))
+	h.Write([]byte(string(channel) + "|" + appKey + "|" + ts))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (s *marketplaceService) GenerateMarketplaceAuthLink(ctx context.Context, channel dto.MarketplaceChannelEnum, appKey string) (string, error) {
+	market, err := s.market(channel)
+	if err != nil {
+		return "", err
+	}
+	if s.Config.Lazada.LazadaCallbackURL == "" {
+		return "", errors.New("LAZADA_CALLBACK_URL is not configured")
+	}
+	app, err := s.MarketplaceAppRepository.GetMarketplaceApp(ctx, channel, appKey)
+	if err != nil {
+		return "", err
+	}
+
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	state := app.AppKey + "." + ts + "." + authStateSign(app.AppSecret, channel, app.AppKey, ts)
+	creds := &adapter.IReqMarketplaceAdapter{AppKey: app.AppKey, AppSecret: app.AppSecret}
+	return market.AuthURL(creds, s.Config.Lazada.LazadaCallbackURL, state)
+}
+
+// appFromState : state = app_key.ts.sig, checked against the stored app secret
+func (s *marketplaceService) appFromState(ctx context.Context, channel dto.MarketplaceChannelEnum, state string) (*MarketplaceAppEntity, error) {
+	parts := strings.Split(state, ".")
+	if len(parts) != 3 {
+		return nil, ErrMarketplaceInvalidState
+	}
+	appKey, ts, sig := parts[0], parts[1], parts[2]
+
+	issued, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || time.Since(time.Unix(issued, 0)) > marketplaceAuthStateTTL {
+		return nil, ErrMarketplaceInvalidState
+	}
+	app, err := s.MarketplaceAppRepository.GetMarketplaceApp(ctx, channel, appKey)
+	if err != nil {
+		if errors.Is(err, ErrMarketplaceAppNotFound) {
+			return nil, ErrMarketplaceInvalidState
+		}
+		return nil, err
+	}
+	if !hmac.Equal([]byte(sig), []byte(authStateSign(app.AppSecret, channel, appKey, ts))) {
+		return nil, ErrMarketplaceInvalidState
+	}
+	return app, nil
+}
+
+func (s *marketplaceService) MarketplaceAuthCallback(ctx context.Context, channel dto.MarketplaceChannelEnum, code string, state string) (*MarketplaceShopAuthEntity, error) {
+	market, err := s.market(channel)
+	if err != nil {
+		return nil, err
+	}
+	// public callback : the app is looked up across tenants, the shop lands in the app's tenant
+	app, err := s.appFromState(pkg.WithoutTenant(ctx), channel, state)
+	if err != nil {
+		return nil, err
+	}
+	ctx = pkg.WithTenant(ctx, app.TenantID)
+
+	creds := &adapter.IReqMarketplaceAdapter{AppKey: app.AppKey, AppSecret: app.AppSecret}
+	token, err := market.ExchangeCode(ctx, creds, code)
+	if err != nil {
+		s.Logger.Error("usecase.MarketplaceAuthCallback : ExchangeCode", zap.String("channel", string(channel)), zap.Error(err))
+		return nil, err
+	}
+	if token.ShopID == "" {
+		return nil, errors.New("marketplace token has no shop id")
+	}
+
+	auth := &MarketplaceShopAuthEntity{
+		Channel:          channel,
+		ShopID:           token.ShopID,
+		AppKey:           app.AppKey,
+		Account:          token.Account,
+		Region:           token.Region,
+		AccessToken:      token.AccessToken,
+		RefreshToken:     token.RefreshToken,
+		ExpiresAt:        token.ExpiresAt,
+		RefreshExpiresAt: token.RefreshExpiresAt,
+	}
+	// shop name is a nice to have : the tokens are stored anyway
+	creds.ShopID, creds.AccessToken, creds.Region = token.ShopID, token.AccessToken, token.Region
+	if shop, err := market.GetShop(ctx, creds); err != nil {
+		s.Logger.Warn("usecase.MarketplaceAuthCallback : GetShop", zap.String("shop_id", token.ShopID), zap.Error(err))
+	} else {
+		auth.ShopName = shop.Name
+	}
+
+	saved, err := s.MarketplaceShopAuthRepository.UpsertMarketplaceShopAuth(ctx, auth)
+	if err != nil {
+		return nil, err
+	}
+	redacted := saved.Redacted()
+	return &redacted, nil
+}
+
+func (s *marketplaceService) GetMarketplaceShops(ctx context.Context, channel dto.MarketplaceChannelEnum) ([]MarketplaceShopAuthEntity, error) {
+	if _, err := s.market(channel); err != nil {
+		return nil, err
+	}
+	shops, err := s.MarketplaceShopAuthRepository.GetMarketplaceShopAuths(ctx, channel)
+	if err != nil {
+		return nil, err
+	}
+	for i := range shops {
+		shops[i] = shops[i].Redacted()
+	}
+	return shops, nil
+}
+
+func (s *marketplaceService) GetMarketplaceCredentials(ctx context.Context, channel dto.MarketplaceChannelEnum, shopID string) (*adapter.IReqMarketplaceAdapter, error) {
+	if channel == dto.CHANNEL_SHOPEE {
+		params, err := s.ShopeeService.GetShopeeAdapterParamsByShopID(ctx, shopID)
+		if err != nil {
+			return nil, err
+		}
+		return &adapter.IReqMarketplaceAdapter{
+			AppKey:      params.PartnerID,
+			AppSecret:   params.SecretKey,
+			ShopID:      params.ShopID,
+			AccessToken: params.AccessToken,
+		}, nil
+	}
+
+	market, err := s.Registry.Get(channel)
+	if err != nil {
+		return nil, err
+	}
+	auth, err := s.MarketplaceShopAuthRepository.GetMarketplaceShopAuth(ctx, channel, shopID)
+	if err != nil {
+		return nil, err
+	}
+	if auth.NeedReauth {
+		return nil, ErrMarketplaceShopNeedReauth
+	}
+	if time.Until(auth.ExpiresAt) > marketplaceTokenRefreshBefore {
+		return s.credentials(ctx, auth)
+	}
+
+	lock, _ := s.refreshLocks.LoadOrStore(string(channel)+":"+shopID, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	// another request may have refreshed while we waited
+	auth, err = s.MarketplaceShopAuthRepository.GetMarketplaceShopAuth(ctx, channel, shopID)
+	if err != nil {
+		return nil, err
+	}
+	if time.Until(auth.ExpiresAt) > marketplaceTokenRefreshBefore {
+		return s.credentials(ctx, auth)
+	}
+
+	creds, err := s.credentials(ctx, auth)
+	if err != nil {
+		return nil, err
+	}
+	token, err := market.RefreshToken(ctx, creds)
+	if err != nil {
+		s.Logger.Error("usecase.GetMarketplaceCredentials : RefreshToken", zap.String("channel", string(channel)), zap.String("shop_id", shopID), zap.Error(err))
+		if errors.Is(err, adapter.ErrLazadaRefreshTokenExpired) {
+			if err := s.MarketplaceShopAuthRepository.SetMarketplaceShopNeedReauth(ctx, channel, shopID, err.Error()); err != nil {
+				s.Logger.Error("usecase.GetMarketplaceCredentials : SetMarketplaceShopNeedReauth", zap.Error(err))
+			}
+			return nil, ErrMarketplaceShopNeedReauth
+		}
+		return nil, err
+	}
+
+	auth.AccessToken, auth.RefreshToken = token.AccessToken, token.RefreshToken
+	auth.ExpiresAt, auth.RefreshExpiresAt = token.ExpiresAt, token.RefreshExpiresAt
+	if token.Region != "" {
+		auth.Region = token.Region
+	}
+	if _, err := s.MarketplaceShopAuthRepository.UpsertMarketplaceShopAuth(ctx, auth); err != nil {
+		return nil, err
+	}
+	return s.credentials(ctx, auth)
+}
+
+func (s *marketplaceService) credentials(ctx context.Context, auth *MarketplaceShopAuthEntity) (*adapter.IReqMarketplaceAdapter, error) {
+	app, err := s.MarketplaceAppRepository.GetMarketplaceApp(ctx, auth.Channel, auth.AppKey)
+	if err != nil {
+		return nil, err
+	}
+	return &adapter.IReqMarketplaceAdapter{
+		AppKey:       app.AppKey,
+		AppSecret:    app.AppSecret,
+		ShopID:       auth.ShopID,
+		AccessToken:  auth.AccessToken,
+		RefreshToken: auth.RefreshToken,
+		Region:       auth.Region,
+	}, nil
+}
+
+// shop : implementation + credentials of one shop
+func (s *marketplaceService) shop(ctx context.Context, channel dto.MarketplaceChannelEnum, shopID string) (adapter.IMarketplace, *adapter.IReqMarketplaceAdapter, error) {
+	market, err := s.Registry.Get(channel)
+	if err != nil {
+		return nil, nil, err
+	}
+	creds, err := s.GetMarketplaceCredentials(ctx, channel, shopID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return market, creds, nil
+}
+
+func (s *marketplaceService) GetMarketplaceShop(ctx context.Context, channel dto.MarketplaceChannelEnum, shopID string) (*dto.MarketplaceShop, error) {
+	market, creds, err := s.shop(ctx, channel, shopID)
+	if err != nil {
+		return nil, err
+	}
+	return market.GetShop(ctx, creds)
+}
+
+func (s *marketplaceService) GetMarketplaceOrders(ctx context.Context, channel dto.MarketplaceChannelEnum, shopID string, q *dto.MarketplaceOrderQuery) (*dto.MarketplaceOrderPage, error) {
+	market, creds, err := s.shop(ctx, channel, shopID)
+	if err != nil {
+		return nil, err
+	}
+	page, err := market.ListOrders(ctx, creds, q)
+	if err != nil {
+		return nil, err
+	}
+	// Shopee orders are stored by the shopee package, with what the live read lacks (escrow)
+	if channel != dto.CHANNEL_SHOPEE {
+		s.store(ctx, page.Orders)
+	}
+	return page, nil
+}
+
+func (s *marketplaceService) GetMarketplaceOrder(ctx context.Context, channel dto.MarketplaceChannelEnum, shopID string, orderID string) (*dto.MarketplaceOrder, error) {
+	market, creds, err := s.shop(ctx, channel, shopID)
+	if err != nil {
+		return nil, err
+	}
+	orders, err := market.GetOrders(ctx, creds, []string{orderID})
+	if err != nil {
+		return nil, err
+	}
+	if len(orders) == 0 {
+		return nil, ErrMarketplaceOrderNotFound
+	}
+	if channel != dto.CHANNEL_SHOPEE {
+		s.store(ctx, orders)
+	}
+	return &orders[0], nil
+}
+
+func (s *marketplaceService) GetMarketplaceItems(ctx context.Context, channel dto.MarketplaceChannelEnum, shopID string, q *dto.MarketplaceItemQuery) (*dto.MarketplaceItemPage, error) {
+	market, creds, err := s.shop(ctx, channel, shopID)
+	if err != nil {
+		return nil, err
+	}
+	return market.ListItems(ctx, creds, q)
+}
+
+func (s *marketplaceService) UpdateMarketplaceStock(ctx context.Context, channel dto.MarketplaceChannelEnum, shopID string, updates []dto.MarketplaceStockUpdate) ([]dto.MarketplaceStockResult, error) {
+	market, creds, err := s.shop(ctx, channel, shopID)
+	if err != nil {
+		return nil, err
+	}
+	return market.UpdateStock(ctx, creds, updates)
+}
+
+func (s *marketplaceService) ShipMarketplaceOrder(ctx context.Context, channel dto.MarketplaceChannelEnum, shopID string, req *dto.MarketplaceShipRequest) (*dto.MarketplaceShipResult, error) {
+	market, creds, err := s.shop(ctx, channel, shopID)
+	if err != nil {
+		return nil, err
+	}
+	return market.ShipOrder(ctx, creds, req)
+}
@@ -0,0 +1,273 @@
+package product
+
+import (
+	"errors"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	"ecommerce/internal/adapter"
+	"ecommerce/internal/delivery/http/response"
+)
+
+type IReqProductDimension struct {
+	WeightGram float64 `json:"weight_gram" validate:"gte=0"`
+	LengthCM   float64 `json:"length_cm" validate:"gte=0"`
+	WidthCM    float64 `json:"width_cm" validate:"gte=0"`
+	HeightCM   float64 `json:"height_cm" validate:"gte=0"`
+}
+
+type IReqProductVariant struct {
+	SKU        string               `json:"sku" validate:"required,max=64"`
+	Name       string               `json:"name" validate:"max=200"`
+	Options    map[string]string    `json:"options"`
+	Barcodes   []string             `json:"barcodes" validate:"dive,required,max=64"`
+	Cost       float64              `json:"cost" validate:"gte=0"`
+	Price      float64              `json:"price" validate:"gte=0"`
+	Dimensions IReqProductDimension `json:"dimensions"`
+	Image      string               `json:"image" validate:"omitempty,url"`
+	Status     ProductStatusEnum    `json:"status" validate:"omitempty,oneof=ACTIVE INACTIVE"`
+}
+
+// POST / PUT body : PUT replaces the whole product
+type IReqProduct struct {
+	Code        string               `json:"code" validate:"required,max=64"`
+	Name        string               `json:"name" validate:"required,max=300"`
+	Description string               `json:"description"`
+	Brand       string               `json:"brand"`
+	Category    string               `json:"category"`
+	Status      ProductStatusEnum    `json:"status" validate:"omitempty,oneof=ACTIVE INACTIVE"`
+	Images      []string             `json:"images" validate:"dive,url"`
+	Variants    []IReqProductVariant `json:"variants" validate:"required,min=1,dive"`
+}
+
+type IReqProductSearch struct {
+	Q        string            `query:"q"`
+	Status   ProductStatusEnum `query:"status"`
+	Brand    string            `query:"brand"`
+	Category string            `query:"category"`
+	Page     int               `query:"page"`
+	Size     int               `query:"size"`
+}
+
+type IReqSKUMapping struct {
+	SKU        string `json:"sku" validate:"required"`
+	Channel    string `json:"channel" validate:"required"`
+	ShopID     string `json:"shop_id" validate:"required"`
+	ItemID     string `json:"item_id" validate:"required"`
+	VariantID  string `json:"variant_id"`
+	ChannelSKU string `json:"channel_sku"`
+}
+
+type IReqSKUMappingQuery struct {
+	SKU     string `query:"sku"`
+	Channel string `query:"channel"`
+	ShopID  string `query:"shop_id"`
+}
+
+type IReqResolveSKU struct {
+	Channel string              `json:"channel" validate:"required"`
+	ShopID  string              `json:"shop_id" validate:"required"`
+	Lines   []ChannelLineEntity `json:"lines" validate:"required,min=1,max=200,dive"`
+}
+
+type IProductHandler interface {
+	PostProduct(c *fiber.Ctx) error
+	PutProduct(c *fiber.Ctx) error
+	DeleteProduct(c *fiber.Ctx) error
+	GetProductByID(c *fiber.Ctx) error
+	GetProductBySKU(c *fiber.Ctx) error
+	GetProductByBarcode(c *fiber.Ctx) error
+	GetProducts(c *fiber.Ctx) error
+
+	PostSKUMapping(c *fiber.Ctx) error
+	DeleteSKUMapping(c *fiber.Ctx) error
+	GetSKUMappings(c *fiber.Ctx) error
+
+	PostResolveSKU(c *fiber.Ctx) error
+	GetShopeeOrderSKUs(c *fiber.Ctx) error
+}
+
+type productHandler struct {
+	Logger   *zap.Logger
+	Validate *validator.Validate
+	Service  IProductService
+}
+
+func NewProductHandler(log *zap.Logger, valid *validator.Validate, srv IProductService) IProductHandler {
+	return &productHandler{
+		Logger:   log,
+		Validate: valid,
+		Service:  srv,
+	}
+}
+
+// actor : username set by the auth middleware
+func actor(c *fiber.Ctx) string {
+	if username, ok := c.Locals("username").(string); ok {
+		return username
+	}
+	return ""
+}
+
+func productErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrProductNotFound), errors.Is(err, ErrSKUNotFound), errors.Is(err, ErrSKUMappingNotFound):
+		return fiber.StatusNotFound
+	case errors.Is(err, ErrDuplicateSKU), errors.Is(err, ErrDuplicateBarcode),
+		errors.Is(err, ErrDuplicateMapping), errors.Is(err, ErrSKUInUse):
+		return fiber.StatusConflict
+	}
+	return fiber.StatusBadRequest
+}
+
+func (d *productHandler) PostProduct(c *fiber.Ctx) error {
+	var reqBody IReqProduct
+	if err := c.BodyParser(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostProduct", "invalid body")
+	}
+	if err := d.Validate.Struct(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostProduct", err)
+	}
+
+	res, err := d.Service.CreateProduct(c.Context(), actor(c), &reqBody)
+	if err != nil {
+		return response.ErrorResponse(c, productErrorStatus(err), "handler.PostProduct", err)
+	}
+	return response.SuccessResponse(c, "handler.PostProduct", res)
+}
+
+func (d *productHandler) PutProduct(c *fiber.Ctx) error {
+	productID := c.Params("productID")
+
+	var reqBody IReqProduct
+	if err := c.BodyParser(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PutProduct", "invalid body")
+	}
+	if err := d.Validate.Struct(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PutProduct", err)
+	}
+
+	res, err := d.Service.UpdateProduct(c.Context(), productID, actor(c), &reqBody)
+	if err != nil {
+		return response.ErrorResponse(c, productErrorStatus(err), "handler.PutProduct", err)
+	}
+	return response.SuccessResponse(c, "handler.PutProduct", res)
+}
+
+func (d *productHandler) DeleteProduct(c *fiber.Ctx) error {
+	res, err := d.Service.ArchiveProduct(c.Context(), c.Params("productID"), actor(c))
+	if err != nil {
+		return response.ErrorResponse(c, productErrorStatus(err), "handler.DeleteProduct", err)
+	}
+	return response.SuccessResponse(c, "handler.DeleteProduct", res)
+}
+
+func (d *productHandler) GetProductByID(c *fiber.Ctx) error {
+	res, err := d.Service.GetProductByID(c.Context(), c.Params("productID"))
+	if err != nil {
+		return response.ErrorResponse(c, productErrorStatus(err), "handler.GetProductByID", err)
+	}
+	return response.SuccessResponse(c, "handler.GetProductByID", res)
+}
+
+func (d *productHandler) GetProductBySKU(c *fiber.Ctx) error {
+	res, err := d.Service.GetProductBySKU(c.Context(), c.Params("sku"))
+	if err != nil {
+		return response.ErrorResponse(c, productErrorStatus(err), "handler.GetProductBySKU", err)
+	}
+	return response.SuccessResponse(c, "handler.GetProductBySKU", res)
+}
+
+func (d *productHandler) GetProductByBarcode(c *fiber.Ctx) error {
+	res, err := d.Service.GetProductByBarcode(c.Context(), c.Params("barcode"))
+	if err != nil {
+		return response.ErrorResponse(c, productErrorStatus(err), "handler.GetProductByBarcode", err)
+	}
+	return response.SuccessResponse(c, "handler.GetProductByBarcode", res)
+}
+
+func (d *productHandler) GetProducts(c *fiber.Ctx) error {
+	var query IReqProductSearch
+	if err := c.QueryParser(&query); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.GetProducts", "invalid query")
+	}
+
+	res, err := d.Service.SearchProducts(c.Context(), &query)
+	if err != nil {
+		return response.ErrorResponse(c, fiber.StatusInternalServerError, "handler.GetProducts", err)
+	}
+	return response.SuccessResponse(c, "handler.GetProducts", res)
+}
+
+func (d *productHandler) PostSKUMapping(c *fiber.Ctx) error {
+	var reqBody IReqSKUMapping
+	if err := c.BodyParser(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostSKUMapping", "invalid body")
+	}
+	if err := d.Validate.Struct(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostSKUMapping", err)
+	}
+
+	res, err := d.Service.CreateSKUMapping(c.Context(), actor(c), &reqBody)
+	if err != nil {
+		return response.ErrorResponse(c, productErrorStatus(err), "handler.PostSKUMapping", err)
+	}
+	return response.SuccessResponse(c, "handler.PostSKUMapping", res)
+}
+
+func (d *productHandler) DeleteSKUMapping(c *fiber.Ctx) error {
+	res, err := d.Service.DeleteSKUMapping(c.Context(), c.Params("mappingID"))
+	if err != nil {
+		return response.ErrorResponse(c, productErrorStatus(err), "handler.DeleteSKUMapping", err)
+	}
+	return response.SuccessResponse(c, "handler.DeleteSKUMapping", res)
+}
+
+func (d *productHandler) GetSKUMappings(c *fiber.Ctx) error {
+	var query IReqSKUMappingQuery
+	if err := c.QueryParser(&query); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.GetSKUMappings", "invalid query")
+	}
+
+	res, err := d.Service.GetSKUMappings(c.Context(), &query)
+	if err != nil {
+		return response.ErrorResponse(c, productErrorStatus(err), "handler.GetSKUMappings", err)
+	}
+	return response.SuccessResponse(c, "handler.GetSKUMappings", res)
+}
+
+func (d *productHandler) PostResolveSKU(c *fiber.Ctx) error {
+	var reqBody IReqResolveSKU
+	if err := c.BodyParser(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostResolveSKU", "invalid body")
+	}
+	if err := d.Validate.Struct(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostResolveSKU", err)
+	}
+	channel, ok := adapter.ParseMarketplaceChannel(reqBody.Channel)
+	if !ok {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostResolveSKU", adapter.ErrMarketplaceUnsupported)
+	}
+
+	res, err := d.Service.ResolveChannelSKUs(c.Context(), channel, reqBody.ShopID, reqBody.Lines)
+	if err != nil {
+		return response.ErrorResponse(c, fiber.StatusInternalServerError, "handler.PostResolveSKU", err)
+	}
+	return response.SuccessResponse(c, "handler.PostResolveSKU", res)
+}
+
+func (d *productHandler) GetShopeeOrderSKUs(c *fiber.Ctx) error {
+	shopID := c.Params("shopeeShopID")
+	orderSN := c.Params("orderSN")
+	if shopID == "" || orderSN == "" {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.GetShopeeOrderSKUs", "shopeeShopID and orderSN are required")
+	}
+
+	res, err := d.Service.ResolveShopeeOrderSKUs(c.Context(), shopID, orderSN)
+	if err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.GetShopeeOrderSKUs", err)
+	}
+	return response.SuccessResponse(c, "handler.GetShopeeOrderSKUs", res)
+}
@@ -0,0 +1,376 @@
+package product
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"go.uber.org/zap"
+
+	"ecommerce/internal/adapter/dto"
+	"ecommerce/internal/pkg"
+)
+
+type ProductStatusEnum string
+
+const (
+	PRODUCT_ACTIVE   ProductStatusEnum = "ACTIVE"
+	PRODUCT_INACTIVE ProductStatusEnum = "INACTIVE"
+	// DELETE /products/:id : kept for order history and sku mappings
+	PRODUCT_ARCHIVED ProductStatusEnum = "ARCHIVED"
+)
+
+var (
+	ErrProductNotFound    = errors.New("product not found")
+	ErrSKUNotFound        = errors.New("sku not found")
+	ErrSKUMappingNotFound = errors.New("sku mapping not found")
+	ErrDuplicateSKU       = errors.New("sku already exists")
+	ErrDuplicateMapping   = errors.New("channel listing is already mapped")
+)
+
+// weight in grams, dimensions in centimetres (what the carriers ask for)
+type ProductDimensionModel struct {
+	WeightGram float64 `bson:"weight_gram"`
+	LengthCM   float64 `bson:"length_cm"`
+	WidthCM    float64 `bson:"width_cm"`
+	HeightCM   float64 `bson:"height_cm"`
+}
+
+// one sellable unit : SKU is our internal code, unique across the products of a tenant
+type ProductVariantModel struct {
+	SKU        string                `bson:"sku"`
+	Name       string                `bson:"name"`
+	Options    map[string]string     `bson:"options"` // color: red, size: M
+	Barcodes   []string              `bson:"barcodes"`
+	Cost       float64               `bson:"cost"`
+	Price      float64               `bson:"price"`
+	Dimensions ProductDimensionModel `bson:"dimensions"`
+	Image      string                `bson:"image"`
+	Status     ProductStatusEnum     `bson:"status"`
+}
+
+type ProductModel struct {
+	ID          bson.ObjectID         `bson:"_id"`
+	TenantID    string                `bson:"tenant_id,omitempty"` // missing for the platform tenant : see pkg.TenantFilter
+	Code        string                `bson:"code"`                // parent code, unique in the tenant
+	Name        string                `bson:"name"`
+	Description string                `bson:"description"`
+	Brand       string                `bson:"brand"`
+	Category    string                `bson:"category"`
+	Status      ProductStatusEnum     `bson:"status"`
+	Images      []string              `bson:"images"`
+	Variants    []ProductVariantModel `bson:"variants"`
+	CreatedAt   time.Time             `bson:"created_at"`
+	CreatedBy   string                `bson:"created_by"`
+	UpdatedAt   time.Time             `bson:"updated_at"`
+	UpdatedBy   string                `bson:"updated_by"`
+}
+
+// one channel listing (Shopee item_id / model_id, Lazada product / sku_id, ...) -> one master sku
+type SKUMappingModel struct {
+	ID         bson.ObjectID              `bson:"_id"`
+	TenantID   string                     `bson:"tenant_id,omitempty"`
+	SKU        string                     `bson:"sku"`
+	Channel    dto.MarketplaceChannelEnum `bson:"channel"`
+	ShopID     string                     `bson:"shop_id"`
+	ItemID     string                     `bson:"item_id"`
+	VariantID  string                     `bson:"variant_id"` // Shopee model_id, "" = item without model
+	ChannelSKU string                     `bson:"channel_sku"`
+	CreatedAt  time.Time                  `bson:"created_at"`
+	CreatedBy  string                     `bson:"created_by"`
+}
+
+type ProductFilter struct {
+	Query    string // name, code, sku or barcode (prefix, case insensitive)
+	Status   ProductStatusEnum
+	Brand    string
+	Category string
+	Skip     int64
+	Limit    int64
+}
+
+type SKUMappingFilter struct {
+	SKU     string
+	Channel dto.MarketplaceChannelEnum
+	ShopID  string
+}
+
+type ProductRepository interface {
+	InitRepository() error
+	CreateProduct(ctx context.Context, product *ProductModel) (*ProductModel, error)
+	// replace everything but _id / created_*
+	UpdateProduct(ctx context.Context, product *ProductModel) (*ProductModel, error)
+	GetProductByID(ctx context.Context, id string) (*ProductModel, error)
+	GetProductBySKU(ctx context.Context, sku string) (*ProductModel, error)
+	GetProductsBySKU(ctx context.Context, skus []string) ([]ProductModel, error)
+	GetProductByBarcode(ctx context.Context, barcode string) (*ProductModel, error)
+	SearchProducts(ctx context.Context, filter *ProductFilter) ([]ProductModel, int64, error)
+}
+
+type SKUMappingRepository interface {
+	InitRepository() error
+	CreateSKUMapping(ctx context.Context, mapping *SKUMappingModel) (*SKUMappingModel, error)
+	DeleteSKUMapping(ctx context.Context, id string) (*SKUMappingModel, error)
+	GetSKUMappings(ctx context.Context, filter *SKUMappingFilter) ([]SKUMappingModel, error)
+	// listings of one shop, keyed by item_id / variant_id
+	GetSKUMappingsByListings(ctx context.Context, channel dto.MarketplaceChannelEnum, shopID string, itemIDs []string) ([]SKUMappingModel, error)
+	CountSKUMappingsBySKU(ctx context.Context, skus []string) (int64, error)
+}
+
+type productRepository struct {
+	Logger *zap.Logger
+	DB     *mongo.Collection
+}
+
+func NewProductRepository(db *mongo.Collection, log *zap.Logger) ProductRepository {
+	return &productRepository{Logger: log, DB: db}
+}
+
+func (r *productRepository) InitRepository() error {
+	indexs := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "code", Value: 1}}, Options: options.Index().SetUnique(true)},
+		// multikey : unique across products, duplicates inside one product are checked by the usecase
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "variants.sku", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "variants.barcodes", Value: 1}}},
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "status", Value: 1}, {Key: "name", Value: 1}}},
+	}
+	if _, err := r.DB.Indexes().CreateMany(context.TODO(), indexs); err != nil {
+		return errors.New("ProductRepository.InitRepository: failed create indexs")
+	}
+	r.Logger.Info("ProductRepository.InitRepository: index created")
+	return nil
+}
+
+func (r *productRepository) CreateProduct(ctx context.Context, product *ProductModel) (*ProductModel, error) {
+	product.ID = bson.NewObjectID()
+	tenantID, err := pkg.TenantStamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	product.TenantID = tenantID
+	if _, err := r.DB.InsertOne(ctx, product); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, ErrDuplicateSKU
+		}
+		return nil, err
+	}
+	return product, nil
+}
+
+func (r *productRepository) UpdateProduct(ctx context.Context, product *ProductModel) (*ProductModel, error) {
+	update := bson.M{"$set": bson.M{
+		"code":        product.Code,
+		"name":        product.Name,
+		"description": product.Description,
+		"brand":       product.Brand,
+		"category":    product.Category,
+		"status":      product.Status,
+		"images":      product.Images,
+		"variants":    product.Variants,
+		"updated_at":  product.UpdatedAt,
+		"updated_by":  product.UpdatedBy,
+	}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var model ProductModel
+	if err := r.DB.FindOneAndUpdate(ctx, pkg.TenantFilter(ctx, bson.M{"_id": product.ID}), update, opts).Decode(&model); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrProductNotFound
+		}
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, ErrDuplicateSKU
+		}
+		return nil, err
+	}
+	return &model, nil
+}
+
+func (r *productRepository) findOne(ctx context.Context, filter bson.M) (*ProductModel, error) {
+	var model ProductModel
+	if err := r.DB.FindOne(ctx, pkg.TenantFilter(ctx, filter)).Decode(&model); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrProductNotFound
+		}
+		return nil, err
+	}
+	return &model, nil
+}
+
+func (r *productRepository) GetProductByID(ctx context.Context, id string) (*ProductModel, error) {
+	oID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrProductNotFound
+	}
+	return r.findOne(ctx, bson.M{"_id": oID})
+}
+
+func (r *productRepository) GetProductBySKU(ctx context.Context, sku string) (*ProductModel, error) {
+	return r.findOne(ctx, bson.M{"variants.sku": sku})
+}
+
+func (r *productRepository) GetProductsBySKU(ctx context.Context, skus []string) ([]ProductModel, error) {
+	if len(skus) == 0 {
+		return []ProductModel{}, nil
+	}
+	cursor, err := r.DB.Find(ctx, pkg.TenantFilter(ctx, bson.M{"variants.sku": bson.M{"$in": skus}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	products := []ProductModel{}
+	if err := cursor.All(ctx, &products); err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+func (r *productRepository) GetProductByBarcode(ctx context.Context, barcode string) (*ProductModel, error) {
+	return r.findOne(ctx, bson.M{"variants.barcodes": barcode})
+}
+
+func (r *productRepository) SearchProducts(ctx context.Context, filter *ProductFilter) ([]ProductModel, int64, error) {
+	query := pkg.TenantFilter(ctx, bson.M{})
+	if filter.Status != "" {
+		query["status"] = filter.Status
+	} else {
+		query["status"] = bson.M{"$ne": PRODUCT_ARCHIVED}
+	}
+	if filter.Brand != "" {
+		query["brand"] = filter.Brand
+	}
+	if filter.Category != "" {
+		query["category"] = filter.Category
+	}
+	if filter.Query != "" {
+		prefix := bson.Regex{Pattern: "^" + regexp.QuoteMeta(filter.Query), Options: "i"}
+		query["$or"] = bson.A{
+			bson.M{"name": bson.Regex{Pattern: regexp.QuoteMeta(filter.Query), Options: "i"}},
+			bson.M{"code": prefix},
+			bson.M{"variants.sku": prefix},
+			bson.M{"variants.barcodes": filter.Query},
+		}
+	}
+
+	total, err := r.DB.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "name", Value: 1}, {Key: "_id", Value: 1}}).
+		SetSkip(filter.Skip).
+		SetLimit(filter.Limit)
+	cursor, err := r.DB.Find(ctx, query, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	products := []ProductModel{}
+	if err := cursor.All(ctx, &products); err != nil {
+		return nil, 0, err
+	}
+	return products, total, nil
+}
+
+type skuMappingRepository struct {
+	Logger *zap.Logger
+	DB     *mongo.Collection
+}
+
+func NewSKUMappingRepository(db *mongo.Collection, log *zap.Logger) SKUMappingRepository {
+	return &skuMappingRepository{Logger: log, DB: db}
+}
+
+func (r *skuMappingRepository) InitRepository() error {
+	indexs := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "tenant_id", Value: 1}, {Key: "channel", Value: 1}, {Key: "shop_id", Value: 1}, {Key: "item_id", Value: 1}, {Key: "variant_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "sku", Value: 1}}},
+	}
+	if _, err := r.DB.Indexes().CreateMany(context.TODO(), indexs); err != nil {
+		return errors.New("SKUMappingRepository.InitRepository: failed create indexs")
+	}
+	r.Logger.Info("SKUMappingRepository.InitRepository: index created")
+	return nil
+}
+
+func (r *skuMappingRepository) CreateSKUMapping(ctx context.Context, mapping *SKUMappingModel) (*SKUMappingModel, error) {
+	mapping.ID = bson.NewObjectID()
+	tenantID, err := pkg.TenantStamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	mapping.TenantID = tenantID
+	if _, err := r.DB.InsertOne(ctx, mapping); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, ErrDuplicateMapping
+		}
+		return nil, err
+	}
+	return mapping, nil
+}
+
+func (r *skuMappingRepository) DeleteSKUMapping(ctx context.Context, id string) (*SKUMappingModel, error) {
+	oID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrSKUMappingNotFound
+	}
+	var model SKUMappingModel
+	if err := r.DB.FindOneAndDelete(ctx, pkg.TenantFilter(ctx, bson.M{"_id": oID})).Decode(&model); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrSKUMappingNotFound
+		}
+		return nil, err
+	}
+	return &model, nil
+}
+
+func (r *skuMappingRepository) find(ctx context.Context, query bson.M) ([]SKUMappingModel, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "channel", Value: 1}, {Key: "shop_id", Value: 1}, {Key: "item_id", Value: 1}})
+	cursor, err := r.DB.Find(ctx, pkg.TenantFilter(ctx, query), opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	mappings := []SKUMappingModel{}
+	if err := cursor.All(ctx, &mappings); err != nil {
+		return nil, err
+	}
+	return mappings, nil
+}
+
+func (r *skuMappingRepository) GetSKUMappings(ctx context.Context, filter *SKUMappingFilter) ([]SKUMappingModel, error) {
+	query := bson.M{}
+	if filter.SKU != "" {
+		query["sku"] = filter.SKU
+	}
+	if filter.Channel != "" {
+		query["channel"] = filter.Channel
+	}
+	if filter.ShopID != "" {
+		query["shop_id"] = filter.ShopID
+	}
+	return r.find(ctx, query)
+}
+
+func (r *skuMappingRepository) GetSKUMappingsByListings(ctx context.Context, channel dto.MarketplaceChannelEnum, shopID string, itemIDs []string) ([]SKUMappingModel, error) {
+	if len(itemIDs) == 0 {
+		return []SKUMappingModel{}, nil
+	}
+	return r.find(ctx, bson.M{"channel": channel, "shop_id": shopID, "item_id": bson.M{"$in": itemIDs}})
+}
+
+func (r *skuMappingRepository) CountSKUMappingsBySKU(ctx context.Context, skus []string) (int64, error) {
+	if len(skus) == 0 {
+		return 0, nil
+	}
+	return r.DB.CountDocuments(ctx, pkg.TenantFilter(ctx, bson.M{"sku": bson.M{"$in": skus}}))
+}
@@ -0,0 +1,595 @@
+package product
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"ecommerce/internal/adapter"
+	"ecommerce/internal/adapter/dto"
+	"ecommerce/internal/application/shopee"
+	"ecommerce/internal/env"
+)
+
+const (
+	productPageSize    = 20
+	productMaxPageSize = 100
+)
+
+var (
+	ErrDuplicateBarcode = errors.New("barcode already exists")
+	// a sku removed from a product still has channel listings mapped to it
+	ErrSKUInUse = errors.New("sku is mapped to channel listings")
+)
+
+// how a channel line found its master sku
+type SKUResolveSourceEnum string
+
+const (
+	RESOLVE_MAPPING   SKUResolveSourceEnum = "MAPPING"   // sku mapping table
+	RESOLVE_SKU_MATCH SKUResolveSourceEnum = "SKU_MATCH" // seller sku on the channel == master sku
+	RESOLVE_NONE      SKUResolveSourceEnum = "NONE"
+)
+
+type IProductService interface {
+	CreateProduct(ctx context.Context, actor string, req *IReqProduct) (*ProductEntity, error)
+	UpdateProduct(ctx context.Context, id string, actor string, req *IReqProduct) (*ProductEntity, error)
+	// soft delete : status ARCHIVED, skus keep resolving for old orders
+	ArchiveProduct(ctx context.Context, id string, actor string) (*ProductEntity, error)
+	GetProductByID(ctx context.Context, id string) (*ProductEntity, error)
+	GetProductBySKU(ctx context.Context, sku string) (*ProductEntity, error)
+	GetProductByBarcode(ctx context.Context, barcode string) (*ProductEntity, error)
+	SearchProducts(ctx context.Context, query *IReqProductSearch) (*ProductPageEntity, error)
+
+	CreateSKUMapping(ctx context.Context, actor string, req *IReqSKUMapping) (*SKUMappingEntity, error)
+	DeleteSKUMapping(ctx context.Context, id string) (*SKUMappingEntity, error)
+	GetSKUMappings(ctx context.Context, query *IReqSKUMappingQuery) ([]SKUMappingEntity, error)
+
+	// channel lines -> master skus, one result per line in the same order
+	ResolveChannelSKUs(ctx context.Context, channel dto.MarketplaceChannelEnum, shopID string, lines []ChannelLineEntity) ([]ResolvedSKUEntity, error)
+	ResolveShopeeItems(ctx context.Context, shopID string, items []shopee.ShopeeItemListEntity) ([]ResolvedSKUEntity, error)
+	// lines of a stored marketplace order, any channel, one result per item / variant
+	ResolveMarketplaceItems(ctx context.Context, order *dto.MarketplaceOrder) ([]ResolvedSKUEntity, error)
+	// lines of a stored shopee_order
+	ResolveShopeeOrderSKUs(ctx context.Context, shopID string, orderSN string) ([]ResolvedSKUEntity, error)
+}
+
+type ProductDimensionEntity struct {
+	WeightGram float64 `json:"weight_gram"`
+	LengthCM   float64 `json:"length_cm"`
+	WidthCM    float64 `json:"width_cm"`
+	HeightCM   float64 `json:"height_cm"`
+}
+
+type ProductVariantEntity struct {
+	SKU        string                 `json:"sku"`
+	Name       string                 `json:"name"`
+	Options    map[string]string      `json:"options"`
+	Barcodes   []string               `json:"barcodes"`
+	Cost       float64                `json:"cost"`
+	Price      float64                `json:"price"`
+	Dimensions ProductDimensionEntity `json:"dimensions"`
+	Image      string                 `json:"image"`
+	Status     ProductStatusEnum      `json:"status"`
+}
+
+type ProductEntity struct {
+	ID          string                 `json:"id"`
+	Code        string                 `json:"code"`
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Brand       string                 `json:"brand"`
+	Category    string                 `json:"category"`
+	Status      ProductStatusEnum      `json:"status"`
+	Images      []string               `json:"images"`
+	Variants    []ProductVariantEntity `json:"variants"`
+	CreatedAt   time.Time              `json:"created_at"`
+	CreatedBy   string                 `json:"created_by"`
+	UpdatedAt   time.Time              `json:"updated_at"`
+	UpdatedBy   string                 `json:"updated_by"`
+}
+
+type ProductPageEntity struct {
+	Products []ProductEntity `json:"products"`
+	Total    int64           `json:"total"`
+	Page     int             `json:"page"`
+	Size     int             `json:"size"`
+}
+
+type SKUMappingEntity struct {
+	ID         string                     `json:"id"`
+	SKU        string                     `json:"sku"`
+	Channel    dto.MarketplaceChannelEnum `json:"channel"`
+	ShopID     string                     `json:"shop_id"`
+	ItemID     string                     `json:"item_id"`
+	VariantID  string                     `json:"variant_id"`
+	ChannelSKU string                     `json:"channel_sku"`
+	CreatedAt  time.Time                  `json:"created_at"`
+	CreatedBy  string                     `json:"created_by"`
+}
+
+// ChannelLineEntity : one order / listing line as the channel knows it
+type ChannelLineEntity struct {
+	ItemID     string `json:"item_id" validate:"required"`
+	VariantID  string `json:"variant_id"`
+	ChannelSKU string `json:"channel_sku"`
+	Name       string `json:"name"`
+	Quantity   int64  `json:"quantity"`
+}
+
+type ResolvedSKUEntity struct {
+	ChannelLineEntity
+	Resolved    bool                 `json:"resolved"`
+	Source      SKUResolveSourceEnum `json:"source"`
+	SKU         string               `json:"sku"`
+	ProductID   string               `json:"product_id"`
+	ProductName string               `json:"product_name"`
+	VariantName string               `json:"variant_name"`
+}
+
+func ProductModelToEntity(model *ProductModel) *ProductEntity {
+	variants := make([]ProductVariantEntity, 0, len(model.Variants))
+	for _, v := range model.Variants {
+		variants = append(variants, ProductVariantEntity{
+			SKU:        v.SKU,
+			Name:       v.Name,
+			Options:    v.Options,
+			Barcodes:   v.Barcodes,
+			Cost:       v.Cost,
+			Price:      v.Price,
+			Dimensions: ProductDimensionEntity(v.Dimensions),
+			Image:      v.Image,
+			Status:     v.Status,
+		})
+	}
+	return &ProductEntity{
+		ID:          model.ID.Hex(),
+		Code:        model.Code,
+		Name:        model.Name,
+		Description: model.Description,
+		Brand:       model.Brand,
+		Category:    model.Category,
+		Status:      model.Status,
+		Images:      model.Images,
+		Variants:    variants,
+		CreatedAt:   model.CreatedAt,
+		CreatedBy:   model.CreatedBy,
+		UpdatedAt:   model.UpdatedAt,
+		UpdatedBy:   model.UpdatedBy,
+	}
+}
+
+func SKUMappingModelToEntity(model *SKUMappingModel) *SKUMappingEntity {
+	return &SKUMappingEntity{
+		ID:         model.ID.Hex(),
+		SKU:        model.SKU,
+		Channel:    model.Channel,
+		ShopID:     model.ShopID,
+		ItemID:     model.ItemID,
+		VariantID:  model.VariantID,
+		ChannelSKU: model.ChannelSKU,
+		CreatedAt:  model.CreatedAt,
+		CreatedBy:  model.CreatedBy,
+	}
+}
+
+type productService struct {
+	Config *env.Config
+	Logger *zap.Logger
+
+	ProductRepository     ProductRepository
+	SKUMappingRepository  SKUMappingRepository
+	ShopeeOrderRepository shopee.ShopeeOrderRepository
+}
+
+func NewProductService(cfg *env.Config, logger *zap.Logger,
+	productRepo ProductRepository,
+	mappingRepo SKUMappingRepository,
+	shopeeOrder shopee.ShopeeOrderRepository,
+) IProductService {
+	return &productService{
+		Config:                cfg,
+		Logger:                logger,
+		ProductRepository:     productRepo,
+		SKUMappingRepository:  mappingRepo,
+		ShopeeOrderRepository: shopeeOrder,
+	}
+}
+
+// variantID : Shopee sends model_id 0 for an item without models
+func variantID(id string) string {
+	if id == "0" {
+		return ""
+	}
+	return strings.TrimSpace(id)
+}
+
+// productModel : request -> model, checks sku / barcode duplicates inside the product
+func productModel(req *IReqProduct) (*ProductModel, error) {
+	status := req.Status
+	if status == "" {
+		status = PRODUCT_ACTIVE
+	}
+	model := &ProductModel{
+		Code:        strings.TrimSpace(req.Code),
+		Name:        req.Name,
+		Description: req.Description,
+		Brand:       req.Brand,
+		Category:    req.Category,
+		Status:      status,
+		Images:      req.Images,
+		Variants:    make([]ProductVariantModel, 0, len(req.Variants)),
+	}
+	if model.Images == nil {
+		model.Images = []string{}
+	}
+
+	skus := map[string]bool{}
+	barcodes := map[string]bool{}
+	for _, v := range req.Variants {
+		sku := strings.TrimSpace(v.SKU)
+		if skus[sku] {
+			return nil, fmt.Errorf("%w : %s", ErrDuplicateSKU, sku)
+		}
+		skus[sku] = true
+		for _, b := range v.Barcodes {
+			if barcodes[b] {
+				return nil, fmt.Errorf("%w : %s", ErrDuplicateBarcode, b)
+			}
+			barcodes[b] = true
+		}
+
+		vStatus := v.Status
+		if vStatus == "" {
+			vStatus = PRODUCT_ACTIVE
+		}
+		variant := ProductVariantModel{
+			SKU:        sku,
+			Name:       v.Name,
+			Options:    v.Options,
+			Barcodes:   v.Barcodes,
+			Cost:       v.Cost,
+			Price:      v.Price,
+			Dimensions: ProductDimensionModel(v.Dimensions),
+			Image:      v.Image,
+			Status:     vStatus,
+		}
+		if variant.Options == nil {
+			variant.Options = map[string]string{}
+		}
+		if variant.Barcodes == nil {
+			variant.Barcodes = []string{}
+		}
+		model.Variants = append(model.Variants, variant)
+	}
+	return model, nil
+}
+
+// checkBarcodes : a barcode scanned at the packing desk must lead to one product only
+func (s *productService) checkBarcodes(ctx context.Context, model *ProductModel) error {
+	for _, v := range model.Variants {
+		for _, b := range v.Barcodes {
+			owner, err := s.ProductRepository.GetProductByBarcode(ctx, b)
+			if errors.Is(err, ErrProductNotFound) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			if owner.ID != model.ID {
+				return fmt.Errorf("%w : %s (%s)", ErrDuplicateBarcode, b, owner.Code)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *productService) CreateProduct(ctx context.Context, actor string, req *IReqProduct) (*ProductEntity, error) {
+	model, err := productModel(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkBarcodes(ctx, model); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	model.CreatedAt, model.CreatedBy = now, actor
+	model.UpdatedAt, model.UpdatedBy = now, actor
+	created, err := s.ProductRepository.CreateProduct(ctx, model)
+	if err != nil {
+		return nil, err
+	}
+	return ProductModelToEntity(created), nil
+}
+
+func (s *productService) UpdateProduct(ctx context.Context, id string, actor string, req *IReqProduct) (*ProductEntity, error) {
+	current, err := s.ProductRepository.GetProductByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	model, err := productModel(req)
+	if err != nil {
+		return nil, err
+	}
+	model.ID = current.ID
+	if err := s.checkBarcodes(ctx, model); err != nil {
+		return nil, err
+	}
+
+	// removed skus : refuse while a channel listing still points at them
+	kept := map[string]bool{}
+	for _, v := range model.Variants {
+		kept[v.SKU] = true
+	}
+	removed := []string{}
+	for _, v := range current.Variants {
+		if !kept[v.SKU] {
+			removed = append(removed, v.SKU)
+		}
+	}
+	if n, err := s.SKUMappingRepository.CountSKUMappingsBySKU(ctx, removed); err != nil {
+		return nil, err
+	} else if n > 0 {
+		return nil, fmt.Errorf("%w : %s", ErrSKUInUse, strings.Join(removed, ","))
+	}
+
+	model.UpdatedAt, model.UpdatedBy = time.Now(), actor
+	updated, err := s.ProductRepository.UpdateProduct(ctx, model)
+	if err != nil {
+		return nil, err
+	}
+	return ProductModelToEntity(updated), nil
+}
+
+func (s *productService) ArchiveProduct(ctx context.Context, id string, actor string) (*ProductEntity, error) {
+	current, err := s.ProductRepository.GetProductByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	current.Status = PRODUCT_ARCHIVED
+	current.UpdatedAt, current.UpdatedBy = time.Now(), actor
+	updated, err := s.ProductRepository.UpdateProduct(ctx, current)
+	if err != nil {
+		return nil, err
+	}
+	return ProductModelToEntity(updated), nil
+}
+
+func (s *productService) GetProductByID(ctx context.Context, id string) (*ProductEntity, error) {
+	model, err := s.ProductRepository.GetProductByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return ProductModelToEntity(model), nil
+}
+
+func (s *productService) GetProductBySKU(ctx context.Context, sku string) (*ProductEntity, error) {
+	model, err := s.ProductRepository.GetProductBySKU(ctx, sku)
+	if err != nil {
+		if errors.Is(err, ErrProductNotFound) {
+			return nil, ErrSKUNotFound
+		}
+		return nil, err
+	}
+	return ProductModelToEntity(model), nil
+}
+
+func (s *productService) GetProductByBarcode(ctx context.Context, barcode string) (*ProductEntity, error) {
+	model, err := s.ProductRepository.GetProductByBarcode(ctx, barcode)
+	if err != nil {
+		return nil, err
+	}
+	return ProductModelToEntity(model), nil
+}
+
+func (s *productService) SearchProducts(ctx context.Context, query *IReqProductSearch) (*ProductPageEntity, error) {
+	page, size := query.Page, query.Size
+	if page <= 0 {
+		page = 1
+	}
+	if size <= 0 {
+		size = productPageSize
+	}
+	if size > productMaxPageSize {
+		size = productMaxPageSize
+	}
+
+	models, total, err := s.ProductRepository.SearchProducts(ctx, &ProductFilter{
+		Query:    strings.TrimSpace(query.Q),
+		Status:   query.Status,
+		Brand:    query.Brand,
+		Category: query.Category,
+		Skip:     int64((page - 1) * size),
+		Limit:    int64(size),
+	})
+	if err != nil {
+		return nil, err
+	}
+	products := make([]ProductEntity, 0, len(models))
+	for i := range models {
+		products = append(products, *ProductModelToEntity(&models[i]))
+	}
+	return &ProductPageEntity{Products: products, Total: total, Page: page, Size: size}, nil
+}
+
+func (s *productService) CreateSKUMapping(ctx context.Context, actor string, req *IReqSKUMapping) (*SKUMappingEntity, error) {
+	channel, ok := adapter.ParseMarketplaceChannel(req.Channel)
+	if !ok {
+		return nil, adapter.ErrMarketplaceUnsupported
+	}
+	if _, err := s.GetProductBySKU(ctx, req.SKU); err != nil {
+		return nil, err
+	}
+
+	created, err := s.SKUMappingRepository.CreateSKUMapping(ctx, &SKUMappingModel{
+		SKU:        req.SKU,
+		Channel:    channel,
+		ShopID:     req.ShopID,
+		ItemID:     strings.TrimSpace(req.ItemID),
+		VariantID:  variantID(req.VariantID),
+		ChannelSKU: req.ChannelSKU,
+		CreatedAt:  time.Now(),
+		CreatedBy:  actor,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return SKUMappingModelToEntity(created), nil
+}
+
+func (s *productService) DeleteSKUMapping(ctx context.Context, id string) (*SKUMappingEntity, error) {
+	deleted, err := s.SKUMappingRepository.DeleteSKUMapping(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return SKUMappingModelToEntity(deleted), nil
+}
+
+func (s *productService) GetSKUMappings(ctx context.Context, query *IReqSKUMappingQuery) ([]SKUMappingEntity, error) {
+	filter := &SKUMappingFilter{SKU: query.SKU, ShopID: query.ShopID}
+	if query.Channel != "" {
+		channel, ok := adapter.ParseMarketplaceChannel(query.Channel)
+		if !ok {
+			return nil, adapter.ErrMarketplaceUnsupported
+		}
+		filter.Channel = channel
+	}
+	models, err := s.SKUMappingRepository.GetSKUMappings(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]SKUMappingEntity, 0, len(models))
+	for i := range models {
+		out = append(out, *SKUMappingModelToEntity(&models[i]))
+	}
+	return out, nil
+}
+
+// ResolveChannelSKUs : mapping table first, then the seller sku typed on the channel
+func (s *productService) ResolveChannelSKUs(ctx context.Context, channel dto.MarketplaceChannelEnum, shopID string, lines []ChannelLineEntity) ([]ResolvedSKUEntity, error) {
+	itemIDs := make([]string, 0, len(lines))
+	for _, l := range lines {
+		itemIDs = append(itemIDs, l.ItemID)
+	}
+	mappings, err := s.SKUMappingRepository.GetSKUMappingsByListings(ctx, channel, shopID, itemIDs)
+	if err != nil {
+		return nil, err
+	}
+	mapped := map[string]string{}
+	for _, m := range mappings {
+		mapped[m.ItemID+":"+m.VariantID] = m.SKU
+	}
+
+	out := make([]ResolvedSKUEntity, 0, len(lines))
+	skus := []string{}
+	for _, l := range lines {
+		l.VariantID = variantID(l.VariantID)
+		res := ResolvedSKUEntity{ChannelLineEntity: l, Source: RESOLVE_NONE}
+		if sku, ok := mapped[l.ItemID+":"+l.VariantID]; ok {
+			res.SKU, res.Source = sku, RESOLVE_MAPPING
+		} else if l.ChannelSKU != "" {
+			res.SKU, res.Source = strings.TrimSpace(l.ChannelSKU), RESOLVE_SKU_MATCH
+		}
+		if res.SKU != "" {
+			skus = append(skus, res.SKU)
+		}
+		out = append(out, res)
+	}
+
+	products, err := s.ProductRepository.GetProductsBySKU(ctx, skus)
+	if err != nil {
+		return nil, err
+	}
+	type owner struct {
+		product *ProductModel
+		variant *ProductVariantModel
+	}
+	bySKU := map[string]owner{}
+	for i := range products {
+		for j := range products[i].Variants {
+			bySKU[products[i].Variants[j].SKU] = owner{&products[i], &products[i].Variants[j]}
+		}
+	}
+
+	for i := range out {
+		o, ok := bySKU[out[i].SKU]
+		if !ok {
+			// a seller sku that is not ours, or a mapping to a sku since removed
+			out[i].SKU, out[i].Source = "", RESOLVE_NONE
+			continue
+		}
+		out[i].Resolved = true
+		out[i].ProductID = o.product.ID.Hex()
+		out[i].ProductName = o.product.Name
+		out[i].VariantName = o.variant.Name
+	}
+	return out, nil
+}
+
+func (s *productService) ResolveShopeeItems(ctx context.Context, shopID string, items []shopee.ShopeeItemListEntity) ([]ResolvedSKUEntity, error) {
+	lines := make([]ChannelLineEntity, 0, len(items))
+	for _, it := range items {
+		channelSKU := it.ModelSKU
+		if channelSKU == "" {
+			channelSKU = it.ItemSKU
+		}
+		name := it.ItemName
+		if it.ModelName != "" {
+			name += " - " + it.ModelName
+		}
+		lines = append(lines, ChannelLineEntity{
+			ItemID:     it.ItemID,
+			VariantID:  it.ModelID,
+			ChannelSKU: channelSKU,
+			Name:       name,
+			Quantity:   int64(it.ModelQualityPurchased),
+		})
+	}
+	return s.ResolveChannelSKUs(ctx, dto.CHANNEL_SHOPEE, shopID, lines)
+}
+
+func (s *productService) ResolveMarketplaceItems(ctx context.Context, order *dto.MarketplaceOrder) ([]ResolvedSKUEntity, error) {
+	return s.ResolveChannelSKUs(ctx, order.Channel, order.ShopID, MarketplaceOrderLines(order))
+}
+
+// MarketplaceOrderLines : one line per item / variant, quantities summed (Lazada has a row per unit)
+func MarketplaceOrderLines(order *dto.MarketplaceOrder) []ChannelLineEntity {
+	lines := []ChannelLineEntity{}
+	index := map[string]int{}
+	for _, it := range order.Items {
+		key := it.ItemID + ":" + variantID(it.VariantID)
+		if i, ok := index[key]; ok {
+			lines[i].Quantity += int64(it.Quantity)
+			continue
+		}
+		name := it.Name
+		if it.VariantName != "" {
+			name += " - " + it.VariantName
+		}
+		index[key] = len(lines)
+		lines = append(lines, ChannelLineEntity{
+			ItemID:     it.ItemID,
+			VariantID:  it.VariantID,
+			ChannelSKU: it.SKU,
+			Name:       name,
+			Quantity:   int64(it.Quantity),
+		})
+	}
+	return lines
+}
+
+func (s *productService) ResolveShopeeOrderSKUs(ctx context.Context, shopID string, orderSN string) ([]ResolvedSKUEntity, error) {
+	order, err := s.ShopeeOrderRepository.GetShopeeOrderByOrderSN(ctx, orderSN)
+	if err != nil {
+		return nil, err
+	}
+	if order.ShopID != shopID {
+		return nil, errors.New("order does not belong to this shop")
+	}
+	return s.ResolveShopeeItems(ctx, shopID, order.ItemList)
+}
@@ -0,0 +1,319 @@
+package purchase
+
+import (
+	"errors"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	"ecommerce/internal/application/inventory"
+	"ecommerce/internal/application/product"
+	"ecommerce/internal/delivery/http/response"
+)
+
+type IReqSupplier struct {
+	Code            string `json:"code" validate:"required,max=32"`
+	Name            string `json:"name" validate:"required,max=300"`
+	TaxID           string `json:"tax_id" validate:"max=32"`
+	ContactName     string `json:"contact_name"`
+	Email           string `json:"email" validate:"omitempty,email"`
+	Phone           string `json:"phone"`
+	Address         string `json:"address"`
+	Currency        string `json:"currency" validate:"omitempty,len=3"` // default THB
+	PaymentTermDays int    `json:"payment_term_days" validate:"gte=0"`
+	LeadTimeDays    int    `json:"lead_time_days" validate:"gte=0"`
+	Note            string `json:"note"`
+}
+
+type IReqSupplierQuery struct {
+	Q          string `query:"q"`
+	ActiveOnly bool   `query:"active_only"`
+	Page       int    `query:"page"`
+	Size       int    `query:"size"`
+}
+
+type IReqPurchaseOrderLine struct {
+	SKU         string  `json:"sku" validate:"required"`
+	Description string  `json:"description"` // default product name
+	Quantity    int64   `json:"quantity" validate:"gt=0"`
+	UnitCost    float64 `json:"unit_cost" validate:"gte=0"`
+}
+
+// POST / PUT body : PUT (draft only) replaces the whole order
+type IReqPurchaseOrder struct {
+	SupplierID string                  `json:"supplier_id" validate:"required"`
+	Warehouse  string                  `json:"warehouse"`                           // default INVENTORY_DEFAULT_WAREHOUSE
+	Currency   string                  `json:"currency" validate:"omitempty,len=3"` // default supplier currency
+	ExpectedAt string                  `json:"expected_at" validate:"omitempty,datetime=2006-01-02"`
+	Note       string                  `json:"note"`
+	Lines      []IReqPurchaseOrderLine `json:"lines" validate:"required,min=1,max=500,dive"`
+}
+
+type IReqPurchaseOrderQuery struct {
+	Q          string                  `query:"q"`
+	Status     PurchaseOrderStatusEnum `query:"status"`
+	SupplierID string                  `query:"supplier_id"`
+	Page       int                     `query:"page"`
+	Size       int                     `query:"size"`
+}
+
+type IReqGoodsReceiptLine struct {
+	LineNo   int   `json:"line_no" validate:"required,gt=0"`
+	Quantity int64 `json:"quantity" validate:"gt=0"`
+}
+
+type IReqLandedCost struct {
+	Type   string  `json:"type" validate:"required,oneof=FREIGHT DUTY INSURANCE OTHER"`
+	Amount float64 `json:"amount" validate:"gt=0"`
+	Note   string  `json:"note"`
+}
+
+type IReqGoodsReceipt struct {
+	Lines       []IReqGoodsReceiptLine   `json:"lines" validate:"required,min=1,dive"`
+	LandedCosts []IReqLandedCost         `json:"landed_costs" validate:"dive"`
+	Allocation  LandedCostAllocationEnum `json:"allocation" validate:"omitempty,oneof=VALUE QUANTITY"`
+	ReceivedAt  string                   `json:"received_at" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	Note        string                   `json:"note"`
+}
+
+type IPurchaseHandler interface {
+	GetSuppliers(c *fiber.Ctx) error
+	GetSupplierByID(c *fiber.Ctx) error
+	PostSupplier(c *fiber.Ctx) error
+	PutSupplier(c *fiber.Ctx) error
+	DeleteSupplier(c *fiber.Ctx) error
+
+	GetPurchaseOrders(c *fiber.Ctx) error
+	GetPurchaseOrderByID(c *fiber.Ctx) error
+	PostPurchaseOrder(c *fiber.Ctx) error
+	PutPurchaseOrder(c *fiber.Ctx) error
+	PostApprovePurchaseOrder(c *fiber.Ctx) error
+	PostCancelPurchaseOrder(c *fiber.Ctx) error
+	PostClosePurchaseOrder(c *fiber.Ctx) error
+
+	PostGoodsReceipt(c *fiber.Ctx) error
+	GetGoodsReceipts(c *fiber.Ctx) error
+	GetGoodsReceiptByID(c *fiber.Ctx) error
+	PostRetryGoodsReceipt(c *fiber.Ctx) error
+}
+
+type purchaseHandler struct {
+	Logger   *zap.Logger
+	Validate *validator.Validate
+	Service  IPurchaseService
+}
+
+func NewPurchaseHandler(log *zap.Logger, valid *validator.Validate, srv IPurchaseService) IPurchaseHandler {
+	return &purchaseHandler{
+		Logger:   log,
+		Validate: valid,
+		Service:  srv,
+	}
+}
+
+// actor : username set by the auth middleware
+func actor(c *fiber.Ctx) string {
+	if username, ok := c.Locals("username").(string); ok {
+		return username
+	}
+	return ""
+}
+
+func purchaseErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrSupplierNotFound), errors.Is(err, ErrPurchaseOrderNotFound), errors.Is(err, ErrGoodsReceiptNotFound),
+		errors.Is(err, product.ErrSKUNotFound), errors.Is(err, inventory.ErrWarehouseNotFound):
+		return fiber.StatusNotFound
+	case errors.Is(err, ErrDuplicateSupplier), errors.Is(err, ErrPurchaseOrderConflict), errors.Is(err, ErrPurchaseOrderStatus),
+		errors.Is(err, ErrOverReceipt), errors.Is(err, ErrSupplierInactive):
+		return fiber.StatusConflict
+	case errors.Is(err, ErrGoodsReceiptPending):
+		return fiber.StatusFailedDependency
+	}
+	return fiber.StatusBadRequest
+}
+
+// -- suppliers
+
+func (d *purchaseHandler) GetSuppliers(c *fiber.Ctx) error {
+	var query IReqSupplierQuery
+	if err := c.QueryParser(&query); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.GetSuppliers", "invalid query")
+	}
+
+	res, err := d.Service.GetSuppliers(c.Context(), &query)
+	if err != nil {
+		return response.ErrorResponse(c, fiber.StatusInternalServerError, "handler.GetSuppliers", err)
+	}
+	return response.SuccessResponse(c, "handler.GetSuppliers", res)
+}
+
+func (d *purchaseHandler) GetSupplierByID(c *fiber.Ctx) error {
+	res, err := d.Service.GetSupplierByID(c.Context(), c.Params("supplierID"))
+	if err != nil {
+		return response.ErrorResponse(c, purchaseErrorStatus(err), "handler.GetSupplierByID", err)
+	}
+	return response.SuccessResponse(c, "handler.GetSupplierByID", res)
+}
+
+func (d *purchaseHandler) PostSupplier(c *fiber.Ctx) error {
+	var reqBody IReqSupplier
+	if err := c.BodyParser(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostSupplier", "invalid body")
+	}
+	if err := d.Validate.Struct(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostSupplier", err)
+	}
+
+	res, err := d.Service.CreateSupplier(c.Context(), actor(c), &reqBody)
+	if err != nil {
+		return response.ErrorResponse(c, purchaseErrorStatus(err), "handler.PostSupplier", err)
+	}
+	return response.SuccessResponse(c, "handler.PostSupplier", res)
+}
+
+func (d *purchaseHandler) PutSupplier(c *fiber.Ctx) error {
+	var reqBody IReqSupplier
+	if err := c.BodyParser(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PutSupplier", "invalid body")
+	}
+	if err := d.Validate.Struct(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PutSupplier", err)
+	}
+
+	res, err := d.Service.UpdateSupplier(c.Context(), c.Params("supplierID"), actor(c), &reqBody)
+	if err != nil {
+		return response.ErrorResponse(c, purchaseErrorStatus(err), "handler.PutSupplier", err)
+	}
+	return response.SuccessResponse(c, "handler.PutSupplier", res)
+}
+
+func (d *purchaseHandler) DeleteSupplier(c *fiber.Ctx) error {
+	res, err := d.Service.DeactivateSupplier(c.Context(), c.Params("supplierID"), actor(c))
+	if err != nil {
+		return response.ErrorResponse(c, purchaseErrorStatus(err), "handler.DeleteSupplier", err)
+	}
+	return response.SuccessResponse(c, "handler.DeleteSupplier", res)
+}
+
+// -- purchase orders
+
+func (d *purchaseHandler) GetPurchaseOrders(c *fiber.Ctx) error {
+	var query IReqPurchaseOrderQuery
+	if err := c.QueryParser(&query); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.GetPurchaseOrders", "invalid query")
+	}
+
+	res, err := d.Service.GetPurchaseOrders(c.Context(), &query)
+	if err != nil {
+		return response.ErrorResponse(c, purchaseErrorStatus(err), "handler.GetPurchaseOrders", err)
+	}
+	return response.SuccessResponse(c, "handler.GetPurchaseOrders", res)
+}
+
+func (d *purchaseHandler) GetPurchaseOrderByID(c *fiber.Ctx) error {
+	res, err := d.Service.GetPurchaseOrderByID(c.Context(), c.Params("poID"))
+	if err != nil {
+		return response.ErrorResponse(c, purchaseErrorStatus(err), "handler.GetPurchaseOrderByID", err)
+	}
+	return response.SuccessResponse(c, "handler.GetPurchaseOrderByID", res)
+}
+
+func (d *purchaseHandler) PostPurchaseOrder(c *fiber.Ctx) error {
+	var reqBody IReqPurchaseOrder
+	if err := c.BodyParser(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostPurchaseOrder", "invalid body")
+	}
+	if err := d.Validate.Struct(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostPurchaseOrder", err)
+	}
+
+	res, err := d.Service.CreatePurchaseOrder(c.Context(), actor(c), &reqBody)
+	if err != nil {
+		return response.ErrorResponse(c, purchaseErrorStatus(err), "handler.PostPurchaseOrder", err)
+	}
+	return response.SuccessResponse(c, "handler.PostPurchaseOrder", res)
+}
+
+func (d *purchaseHandler) PutPurchaseOrder(c *fiber.Ctx) error {
+	var reqBody IReqPurchaseOrder
+	if err := c.BodyParser(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PutPurchaseOrder", "invalid body")
+	}
+	if err := d.Validate.Struct(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PutPurchaseOrder", err)
+	}
+
+	res, err := d.Service.UpdatePurchaseOrder(c.Context(), c.Params("poID"), actor(c), &reqBody)
+	if err != nil {
+		return response.ErrorResponse(c, purchaseErrorStatus(err), "handler.PutPurchaseOrder", err)
+	}
+	return response.SuccessResponse(c, "handler.PutPurchaseOrder", res)
+}
+
+func (d *purchaseHandler) PostApprovePurchaseOrder(c *fiber.Ctx) error {
+	res, err := d.Service.ApprovePurchaseOrder(c.Context(), c.Params("poID"), actor(c))
+	if err != nil {
+		return response.ErrorResponse(c, purchaseErrorStatus(err), "handler.PostApprovePurchaseOrder", err)
+	}
+	return response.SuccessResponse(c, "handler.PostApprovePurchaseOrder", res)
+}
+
+func (d *purchaseHandler) PostCancelPurchaseOrder(c *fiber.Ctx) error {
+	res, err := d.Service.CancelPurchaseOrder(c.Context(), c.Params("poID"), actor(c))
+	if err != nil {
+		return response.ErrorResponse(c, purchaseErrorStatus(err), "handler.PostCancelPurchaseOrder", err)
+	}
+	return response.SuccessResponse(c, "handler.PostCancelPurchaseOrder", res)
+}
+
+func (d *purchaseHandler) PostClosePurchaseOrder(c *fiber.Ctx) error {
+	res, err := d.Service.ClosePurchaseOrder(c.Context(), c.Params("poID"), actor(c))
+	if err != nil {
+		return response.ErrorResponse(c, purchaseErrorStatus(err), "handler.PostClosePurchaseOrder", err)
+	}
+	return response.SuccessResponse(c, "handler.PostClosePurchaseOrder", res)
+}
+
+// -- goods receipts
+
+func (d *purchaseHandler) PostGoodsReceipt(c *fiber.Ctx) error {
+	var reqBody IReqGoodsReceipt
+	if err := c.BodyParser(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostGoodsReceipt", "invalid body")
+	}
+	if err := d.Validate.Struct(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostGoodsReceipt", err)
+	}
+
+	res, err := d.Service.ReceivePurchaseOrder(c.Context(), c.Params("poID"), actor(c), &reqBody)
+	if err != nil {
+		return response.ErrorResponse(c, purchaseErrorStatus(err), "handler.PostGoodsReceipt", err)
+	}
+	return response.SuccessResponse(c, "handler.PostGoodsReceipt", res)
+}
+
+func (d *purchaseHandler) GetGoodsReceipts(c *fiber.Ctx) error {
+	res, err := d.Service.GetGoodsReceipts(c.Context(), c.Params("poID"))
+	if err != nil {
+		return response.ErrorResponse(c, purchaseErrorStatus(err), "handler.GetGoodsReceipts", err)
+	}
+	return response.SuccessResponse(c, "handler.GetGoodsReceipts", res)
+}
+
+func (d *purchaseHandler) GetGoodsReceiptByID(c *fiber.Ctx) error {
+	res, err := d.Service.GetGoodsReceiptByID(c.Context(), c.Params("grnID"))
+	if err != nil {
+		return response.ErrorResponse(c, purchaseErrorStatus(err), "handler.GetGoodsReceiptByID", err)
+	}
+	return response.SuccessResponse(c, "handler.GetGoodsReceiptByID", res)
+}
+
+func (d *purchaseHandler) PostRetryGoodsReceipt(c *fiber.Ctx) error {
+	res, err := d.Service.PostGoodsReceipt(c.Context(), c.Params("grnID"), actor(c))
+	if err != nil {
+		return response.ErrorResponse(c, purchaseErrorStatus(err), "handler.PostRetryGoodsReceipt", err)
+	}
+	return response.SuccessResponse(c, "handler.PostRetryGoodsReceipt", res)
+}
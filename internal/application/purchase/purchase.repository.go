@@ -0,0 +1,488 @@
+package purchase
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"go.uber.org/zap"
+
+	"ecommerce/internal/pkg"
+)
+
+type PurchaseOrderStatusEnum string
+
+const (
+	PO_DRAFT              PurchaseOrderStatusEnum = "DRAFT"
+	PO_APPROVED           PurchaseOrderStatusEnum = "APPROVED"
+	PO_PARTIALLY_RECEIVED PurchaseOrderStatusEnum = "PARTIALLY_RECEIVED"
+	PO_CLOSED             PurchaseOrderStatusEnum = "CLOSED"
+	// draft / approved order given up before anything arrived
+	PO_CANCELLED PurchaseOrderStatusEnum = "CANCELLED"
+)
+
+type GoodsReceiptStatusEnum string
+
+const (
+	// order updated, movements not all posted yet : POST /goods-receipts/:id/post finishes it
+	GRN_PENDING GoodsReceiptStatusEnum = "PENDING"
+	GRN_POSTED  GoodsReceiptStatusEnum = "POSTED"
+)
+
+type LandedCostAllocationEnum string
+
+const (
+	// share of the receipt value (quantity x unit cost)
+	ALLOCATE_BY_VALUE    LandedCostAllocationEnum = "VALUE"
+	ALLOCATE_BY_QUANTITY LandedCostAllocationEnum = "QUANTITY"
+)
+
+var (
+	ErrSupplierNotFound      = errors.New("supplier not found")
+	ErrDuplicateSupplier     = errors.New("supplier code already exists")
+	ErrPurchaseOrderNotFound = errors.New("purchase order not found")
+	// optimistic concurrency : the order changed since it was read
+	ErrPurchaseOrderConflict = errors.New("purchase order changed concurrently, retry")
+	ErrGoodsReceiptNotFound  = errors.New("goods receipt not found")
+)
+
+// suppliers, purchase orders and goods receipts carry the owner tenant, missing for the platform
+// tenant : see pkg.TenantFilter
+type SupplierModel struct {
+	ID              bson.ObjectID `bson:"_id"`
+	TenantID        string        `bson:"tenant_id,omitempty"`
+	Code            string        `bson:"code"`
+	Name            string        `bson:"name"`
+	TaxID           string        `bson:"tax_id"`
+	ContactName     string        `bson:"contact_name"`
+	Email           string        `bson:"email"`
+	Phone           string        `bson:"phone"`
+	Address         string        `bson:"address"`
+	Currency        string        `bson:"currency"`
+	PaymentTermDays int           `bson:"payment_term_days"`
+	LeadTimeDays    int           `bson:"lead_time_days"`
+	Note            string        `bson:"note"`
+	Active          bool          `bson:"active"`
+	CreatedAt       time.Time     `bson:"created_at"`
+	CreatedBy       string        `bson:"created_by"`
+	UpdatedAt       time.Time     `bson:"updated_at"`
+	UpdatedBy       string        `bson:"updated_by"`
+}
+
+type PurchaseOrderLineModel struct {
+	LineNo           int     `bson:"line_no"`
+	SKU              string  `bson:"sku"`
+	Description      string  `bson:"description"`
+	Quantity         int64   `bson:"quantity"`
+	UnitCost         float64 `bson:"unit_cost"`
+	ReceivedQuantity int64   `bson:"received_quantity"`
+}
+
+type PurchaseOrderModel struct {
+	ID           bson.ObjectID            `bson:"_id"`
+	TenantID     string                   `bson:"tenant_id,omitempty"`
+	Number       string                   `bson:"number"`
+	SupplierID   bson.ObjectID            `bson:"supplier_id"`
+	SupplierCode string                   `bson:"supplier_code"`
+	SupplierName string                   `bson:"supplier_name"`
+	Warehouse    string                   `bson:"warehouse"`
+	Currency     string                   `bson:"currency"`
+	ExpectedAt   time.Time                `bson:"expected_at"`
+	Note         string                   `bson:"note"`
+	Status       PurchaseOrderStatusEnum  `bson:"status"`
+	Lines        []PurchaseOrderLineModel `bson:"lines"`
+	Subtotal     float64                  `bson:"subtotal"`
+	// goods receipts already counted in ReceivedQuantity : a receipt is applied once, in the same write
+	ReceiptIDs []bson.ObjectID `bson:"receipt_ids"`
+	// bumped on every write, writes are compare-and-swap on it
+	Version    int64     `bson:"version"`
+	CreatedAt  time.Time `bson:"created_at"`
+	CreatedBy  string    `bson:"created_by"`
+	UpdatedAt  time.Time `bson:"updated_at"`
+	UpdatedBy  string    `bson:"updated_by"`
+	ApprovedAt time.Time `bson:"approved_at,omitempty"`
+	ApprovedBy string    `bson:"approved_by,omitempty"`
+	ClosedAt   time.Time `bson:"closed_at,omitempty"`
+	ClosedBy   string    `bson:"closed_by,omitempty"`
+}
+
+type GoodsReceiptLineModel struct {
+	LineNo   int     `bson:"line_no"`
+	SKU      string  `bson:"sku"`
+	Quantity int64   `bson:"quantity"`
+	UnitCost float64 `bson:"unit_cost"`
+	// landed cost share of the line (total) and the resulting cost per unit
+	AllocatedCost  float64 `bson:"allocated_cost"`
+	UnitLandedCost float64 `bson:"unit_landed_cost"`
+	MovementID     string  `bson:"movement_id"`
+}
+
+type LandedCostModel struct {
+	Type   string  `bson:"type"` // FREIGHT, DUTY, INSURANCE, OTHER
+	Amount float64 `bson:"amount"`
+	Note   string  `bson:"note"`
+}
+
+type GoodsReceiptModel struct {
+	ID              bson.ObjectID            `bson:"_id"`
+	TenantID        string                   `bson:"tenant_id,omitempty"`
+	Number          string                   `bson:"number"`
+	PurchaseOrderID bson.ObjectID            `bson:"purchase_order_id"`
+	PONumber        string                   `bson:"po_number"`
+	Warehouse       string                   `bson:"warehouse"`
+	Status          GoodsReceiptStatusEnum   `bson:"status"`
+	Lines           []GoodsReceiptLineModel  `bson:"lines"`
+	LandedCosts     []LandedCostModel        `bson:"landed_costs"`
+	Allocation      LandedCostAllocationEnum `bson:"allocation"`
+	TotalLandedCost float64                  `bson:"total_landed_cost"`
+	Note            string                   `bson:"note"`
+	LastError       string                   `bson:"last_error"`
+	ReceivedAt      time.Time                `bson:"received_at"`
+	CreatedAt       time.Time                `bson:"created_at"`
+	CreatedBy       string                   `bson:"created_by"`
+	PostedAt        time.Time                `bson:"posted_at,omitempty"`
+}
+
+type SupplierFilter struct {
+	Q          string
+	ActiveOnly bool
+	Skip       int64
+	Limit      int64
+}
+
+type PurchaseOrderFilter struct {
+	Q          string // number prefix
+	Status     PurchaseOrderStatusEnum
+	SupplierID bson.ObjectID
+	Skip       int64
+	Limit      int64
+}
+
+type SupplierRepository interface {
+	InitRepository() error
+	CreateSupplier(ctx context.Context, supplier *SupplierModel) (*SupplierModel, error)
+	UpdateSupplier(ctx context.Context, supplier *SupplierModel) (*SupplierModel, error)
+	GetSupplierByID(ctx context.Context, id string) (*SupplierModel, error)
+	GetSuppliers(ctx context.Context, filter *SupplierFilter) ([]SupplierModel, error)
+}
+
+type PurchaseOrderRepository interface {
+	InitRepository() error
+	CreatePurchaseOrder(ctx context.Context, order *PurchaseOrderModel) (*PurchaseOrderModel, error)
+	// replaces the order when still at order.Version, stores Version+1 ; ErrPurchaseOrderConflict otherwise
+	UpdatePurchaseOrder(ctx context.Context, order *PurchaseOrderModel) (*PurchaseOrderModel, error)
+	GetPurchaseOrderByID(ctx context.Context, id string) (*PurchaseOrderModel, error)
+	GetPurchaseOrders(ctx context.Context, filter *PurchaseOrderFilter) ([]PurchaseOrderModel, error)
+}
+
+type GoodsReceiptRepository interface {
+	InitRepository() error
+	CreateGoodsReceipt(ctx context.Context, receipt *GoodsReceiptModel) (*GoodsReceiptModel, error)
+	UpdateGoodsReceipt(ctx context.Context, receipt *GoodsReceiptModel) error
+	DeleteGoodsReceipt(ctx context.Context, id bson.ObjectID) error
+	GetGoodsReceiptByID(ctx context.Context, id string) (*GoodsReceiptModel, error)
+	GetGoodsReceiptsByPurchaseOrder(ctx context.Context, purchaseOrderID bson.ObjectID) ([]GoodsReceiptModel, error)
+}
+
+// SequenceRepository : per key counters for document numbers (PO-202610-00001)
+type SequenceRepository interface {
+	NextSequence(ctx context.Context, key string) (int64, error)
+}
+
+type supplierRepository struct {
+	Logger *zap.Logger
+	DB     *mongo.Collection
+}
+
+func NewSupplierRepository(db *mongo.Collection, log *zap.Logger) SupplierRepository {
+	return &supplierRepository{Logger: log, DB: db}
+}
+
+func (r *supplierRepository) InitRepository() error {
+	indexs := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "code", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "active", Value: 1}, {Key: "name", Value: 1}}},
+	}
+	if _, err := r.DB.Indexes().CreateMany(context.TODO(), indexs); err != nil {
+		return errors.New("SupplierRepository.InitRepository: failed create indexs")
+	}
+	r.Logger.Info("SupplierRepository.InitRepository: index created")
+	return nil
+}
+
+func (r *supplierRepository) CreateSupplier(ctx context.Context, supplier *SupplierModel) (*SupplierModel, error) {
+	supplier.ID = bson.NewObjectID()
+	tenantID, err := pkg.TenantStamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	supplier.TenantID = tenantID
+	if _, err := r.DB.InsertOne(ctx, supplier); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, ErrDuplicateSupplier
+		}
+		return nil, err
+	}
+	return supplier, nil
+}
+
+func (r *supplierRepository) UpdateSupplier(ctx context.Context, supplier *SupplierModel) (*SupplierModel, error) {
+	res, err := r.DB.ReplaceOne(ctx, pkg.TenantFilter(ctx, bson.M{"_id": supplier.ID}), supplier)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, ErrDuplicateSupplier
+		}
+		return nil, err
+	}
+	if res.MatchedCount == 0 {
+		return nil, ErrSupplierNotFound
+	}
+	return supplier, nil
+}
+
+func (r *supplierRepository) GetSupplierByID(ctx context.Context, id string) (*SupplierModel, error) {
+	objectID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrSupplierNotFound
+	}
+	var model SupplierModel
+	if err := r.DB.FindOne(ctx, pkg.TenantFilter(ctx, bson.M{"_id": objectID})).Decode(&model); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrSupplierNotFound
+		}
+		return nil, err
+	}
+	return &model, nil
+}
+
+func (r *supplierRepository) GetSuppliers(ctx context.Context, filter *SupplierFilter) ([]SupplierModel, error) {
+	query := pkg.TenantFilter(ctx, bson.M{})
+	if filter.ActiveOnly {
+		query["active"] = true
+	}
+	if filter.Q != "" {
+		pattern := bson.Regex{Pattern: regexp.QuoteMeta(filter.Q), Options: "i"}
+		query["$or"] = bson.A{bson.M{"code": pattern}, bson.M{"name": pattern}, bson.M{"tax_id": pattern}}
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}}).SetSkip(filter.Skip).SetLimit(filter.Limit)
+	cursor, err := r.DB.Find(ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	suppliers := []SupplierModel{}
+	if err := cursor.All(ctx, &suppliers); err != nil {
+		return nil, err
+	}
+	return suppliers, nil
+}
+
+type purchaseOrderRepository struct {
+	Logger *zap.Logger
+	DB     *mongo.Collection
+}
+
+func NewPurchaseOrderRepository(db *mongo.Collection, log *zap.Logger) PurchaseOrderRepository {
+	return &purchaseOrderRepository{Logger: log, DB: db}
+}
+
+func (r *purchaseOrderRepository) InitRepository() error {
+	indexs := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "number", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "status", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "supplier_id", Value: 1}, {Key: "created_at", Value: -1}}},
+	}
+	if _, err := r.DB.Indexes().CreateMany(context.TODO(), indexs); err != nil {
+		return errors.New("PurchaseOrderRepository.InitRepository: failed create indexs")
+	}
+	r.Logger.Info("PurchaseOrderRepository.InitRepository: index created")
+	return nil
+}
+
+func (r *purchaseOrderRepository) CreatePurchaseOrder(ctx context.Context, order *PurchaseOrderModel) (*PurchaseOrderModel, error) {
+	order.ID = bson.NewObjectID()
+	tenantID, err := pkg.TenantStamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	order.TenantID = tenantID
+	order.Version = 1
+	if _, err := r.DB.InsertOne(ctx, order); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+func (r *purchaseOrderRepository) UpdatePurchaseOrder(ctx context.Context, order *PurchaseOrderModel) (*PurchaseOrderModel, error) {
+	version := order.Version
+	order.Version = version + 1
+	res, err := r.DB.ReplaceOne(ctx, pkg.TenantFilter(ctx, bson.M{"_id": order.ID, "version": version}), order)
+	if err != nil {
+		order.Version = version
+		return nil, err
+	}
+	if res.MatchedCount == 0 {
+		order.Version = version
+		return nil, ErrPurchaseOrderConflict
+	}
+	return order, nil
+}
+
+func (r *purchaseOrderRepository) GetPurchaseOrderByID(ctx context.Context, id string) (*PurchaseOrderModel, error) {
+	objectID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrPurchaseOrderNotFound
+	}
+	var model PurchaseOrderModel
+	if err := r.DB.FindOne(ctx, pkg.TenantFilter(ctx, bson.M{"_id": objectID})).Decode(&model); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrPurchaseOrderNotFound
+		}
+		return nil, err
+	}
+	return &model, nil
+}
+
+func (r *purchaseOrderRepository) GetPurchaseOrders(ctx context.Context, filter *PurchaseOrderFilter) ([]PurchaseOrderModel, error) {
+	query := pkg.TenantFilter(ctx, bson.M{})
+	if filter.Status != "" {
+		query["status"] = filter.Status
+	}
+	if !filter.SupplierID.IsZero() {
+		query["supplier_id"] = filter.SupplierID
+	}
+	if filter.Q != "" {
+		query["number"] = bson.Regex{Pattern: "^" + regexp.QuoteMeta(filter.Q), Options: "i"}
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(filter.Skip).
+		SetLimit(filter.Limit)
+	cursor, err := r.DB.Find(ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	orders := []PurchaseOrderModel{}
+	if err := cursor.All(ctx, &orders); err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+type goodsReceiptRepository struct {
+	Logger *zap.Logger
+	DB     *mongo.Collection
+}
+
+func NewGoodsReceiptRepository(db *mongo.Collection, log *zap.Logger) GoodsReceiptRepository {
+	return &goodsReceiptRepository{Logger: log, DB: db}
+}
+
+func (r *goodsReceiptRepository) InitRepository() error {
+	indexs := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "number", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "purchase_order_id", Value: 1}, {Key: "created_at", Value: 1}}},
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "status", Value: 1}}},
+	}
+	if _, err := r.DB.Indexes().CreateMany(context.TODO(), indexs); err != nil {
+		return errors.New("GoodsReceiptRepository.InitRepository: failed create indexs")
+	}
+	r.Logger.Info("GoodsReceiptRepository.InitRepository: index created")
+	return nil
+}
+
+func (r *goodsReceiptRepository) CreateGoodsReceipt(ctx context.Context, receipt *GoodsReceiptModel) (*GoodsReceiptModel, error) {
+	receipt.ID = bson.NewObjectID()
+	tenantID, err := pkg.TenantStamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	receipt.TenantID = tenantID
+	if _, err := r.DB.InsertOne(ctx, receipt); err != nil {
+		return nil, err
+	}
+	return receipt, nil
+}
+
+func (r *goodsReceiptRepository) UpdateGoodsReceipt(ctx context.Context, receipt *GoodsReceiptModel) error {
+	res, err := r.DB.ReplaceOne(ctx, pkg.TenantFilter(ctx, bson.M{"_id": receipt.ID}), receipt)
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return ErrGoodsReceiptNotFound
+	}
+	return nil
+}
+
+func (r *goodsReceiptRepository) DeleteGoodsReceipt(ctx context.Context, id bson.ObjectID) error {
+	_, err := r.DB.DeleteOne(ctx, pkg.TenantFilter(ctx, bson.M{"_id": id}))
+	return err
+}
+
+func (r *goodsReceiptRepository) GetGoodsReceiptByID(ctx context.Context, id string) (*GoodsReceiptModel, error) {
+	objectID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrGoodsReceiptNotFound
+	}
+	var model GoodsReceiptModel
+	if err := r.DB.FindOne(ctx, pkg.TenantFilter(ctx, bson.M{"_id": objectID})).Decode(&model); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrGoodsReceiptNotFound
+		}
+		return nil, err
+	}
+	return &model, nil
+}
+
+func (r *goodsReceiptRepository) GetGoodsReceiptsByPurchaseOrder(ctx context.Context, purchaseOrderID bson.ObjectID) ([]GoodsReceiptModel, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	cursor, err := r.DB.Find(ctx, pkg.TenantFilter(ctx, bson.M{"purchase_order_id": purchaseOrderID}), opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	receipts := []GoodsReceiptModel{}
+	if err := cursor.All(ctx, &receipts); err != nil {
+		return nil, err
+	}
+	return receipts, nil
+}
+
+type sequenceRepository struct {
+	Logger *zap.Logger
+	DB     *mongo.Collection
+}
+
+func NewSequenceRepository(db *mongo.Collection, log *zap.Logger) SequenceRepository {
+	return &sequenceRepository{Logger: log, DB: db}
+}
+
+// NextSequence : counters are per tenant, the platform tenant keeps the bare key
+func (r *sequenceRepository) NextSequence(ctx context.Context, key string) (int64, error) {
+	tenantID, err := pkg.TenantStamp(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if tenantID != "" {
+		key = tenantID + ":" + key
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	var doc struct {
+		Seq int64 `bson:"seq"`
+	}
+	if err := r.DB.FindOneAndUpdate(ctx, bson.M{"_id": key}, bson.M{"$inc": bson.M{"seq": 1}}, opts).Decode(&doc); err != nil {
+		return 0, err
+	}
+	return doc.Seq, nil
+}
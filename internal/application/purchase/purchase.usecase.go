@@ -0,0 +1,840 @@
+package purchase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.uber.org/zap"
+
+	"ecommerce/internal/application/inventory"
+	"ecommerce/internal/application/product"
+	"ecommerce/internal/env"
+)
+
+const (
+	pageSize    = 50
+	maxPageSize = 200
+
+	defaultCurrency = "THB"
+)
+
+var (
+	ErrSupplierInactive = errors.New("supplier is inactive")
+	// the workflow does not allow this action in the current status
+	ErrPurchaseOrderStatus = errors.New("action not allowed in the purchase order status")
+	ErrUnknownLine         = errors.New("purchase order line not found")
+	ErrOverReceipt         = errors.New("received quantity exceeds the ordered quantity")
+	// order updated, some receipt movements failed : POST /goods-receipts/:id/post again
+	ErrGoodsReceiptPending = errors.New("goods receipt not fully posted to inventory")
+)
+
+type IPurchaseService interface {
+	CreateSupplier(ctx context.Context, actor string, req *IReqSupplier) (*SupplierEntity, error)
+	UpdateSupplier(ctx context.Context, id string, actor string, req *IReqSupplier) (*SupplierEntity, error)
+	// suppliers stay referenced by orders : deactivated, never deleted
+	DeactivateSupplier(ctx context.Context, id string, actor string) (*SupplierEntity, error)
+	GetSupplierByID(ctx context.Context, id string) (*SupplierEntity, error)
+	GetSuppliers(ctx context.Context, query *IReqSupplierQuery) ([]SupplierEntity, error)
+
+	CreatePurchaseOrder(ctx context.Context, actor string, req *IReqPurchaseOrder) (*PurchaseOrderEntity, error)
+	// DRAFT only : lines are replaced as a whole
+	UpdatePurchaseOrder(ctx context.Context, id string, actor string, req *IReqPurchaseOrder) (*PurchaseOrderEntity, error)
+	ApprovePurchaseOrder(ctx context.Context, id string, actor string) (*PurchaseOrderEntity, error)
+	CancelPurchaseOrder(ctx context.Context, id string, actor string) (*PurchaseOrderEntity, error)
+	// short close : what is still open will not come
+	ClosePurchaseOrder(ctx context.Context, id string, actor string) (*PurchaseOrderEntity, error)
+	GetPurchaseOrderByID(ctx context.Context, id string) (*PurchaseOrderEntity, error)
+	GetPurchaseOrders(ctx context.Context, query *IReqPurchaseOrderQuery) ([]PurchaseOrderEntity, error)
+
+	// goods receipt note : updates the order, posts RECEIPT movements, allocates landed cost over its lines
+	ReceivePurchaseOrder(ctx context.Context, id string, actor string, req *IReqGoodsReceipt) (*GoodsReceiptEntity, error)
+	// finishes a PENDING receipt (idempotent per line)
+	PostGoodsReceipt(ctx context.Context, id string, actor string) (*GoodsReceiptEntity, error)
+	GetGoodsReceiptByID(ctx context.Context, id string) (*GoodsReceiptEntity, error)
+	GetGoodsReceipts(ctx context.Context, purchaseOrderID string) ([]GoodsReceiptEntity, error)
+}
+
+type SupplierEntity struct {
+	ID              string    `json:"id"`
+	Code            string    `json:"code"`
+	Name            string    `json:"name"`
+	TaxID           string    `json:"tax_id"`
+	ContactName     string    `json:"contact_name"`
+	Email           string    `json:"email"`
+	Phone           string    `json:"phone"`
+	Address         string    `json:"address"`
+	Currency        string    `json:"currency"`
+	PaymentTermDays int       `json:"payment_term_days"`
+	LeadTimeDays    int       `json:"lead_time_days"`
+	Note            string    `json:"note"`
+	Active          bool      `json:"active"`
+	CreatedAt       time.Time `json:"created_at"`
+	CreatedBy       string    `json:"created_by"`
+	UpdatedAt       time.Time `json:"updated_at"`
+	UpdatedBy       string    `json:"updated_by"`
+}
+
+type PurchaseOrderLineEntity struct {
+	LineNo            int     `json:"line_no"`
+	SKU               string  `json:"sku"`
+	Description       string  `json:"description"`
+	Quantity          int64   `json:"quantity"`
+	UnitCost          float64 `json:"unit_cost"`
+	Amount            float64 `json:"amount"`
+	ReceivedQuantity  int64   `json:"received_quantity"`
+	RemainingQuantity int64   `json:"remaining_quantity"`
+}
+
+type PurchaseOrderEntity struct {
+	ID           string                    `json:"id"`
+	Number       string                    `json:"number"`
+	SupplierID   string                    `json:"supplier_id"`
+	SupplierCode string                    `json:"supplier_code"`
+	SupplierName string                    `json:"supplier_name"`
+	Warehouse    string                    `json:"warehouse"`
+	Currency     string                    `json:"currency"`
+	ExpectedAt   *time.Time                `json:"expected_at"`
+	Note         string                    `json:"note"`
+	Status       PurchaseOrderStatusEnum   `json:"status"`
+	Lines        []PurchaseOrderLineEntity `json:"lines"`
+	Subtotal     float64                   `json:"subtotal"`
+	Version      int64                     `json:"version"`
+	CreatedAt    time.Time                 `json:"created_at"`
+	CreatedBy    string                    `json:"created_by"`
+	UpdatedAt    time.Time                 `json:"updated_at"`
+	UpdatedBy    string                    `json:"updated_by"`
+	ApprovedAt   *time.Time                `json:"approved_at"`
+	ApprovedBy   string                    `json:"approved_by"`
+	ClosedAt     *time.Time                `json:"closed_at"`
+	ClosedBy     string                    `json:"closed_by"`
+}
+
+type GoodsReceiptLineEntity struct {
+	LineNo         int     `json:"line_no"`
+	SKU            string  `json:"sku"`
+	Quantity       int64   `json:"quantity"`
+	UnitCost       float64 `json:"unit_cost"`
+	AllocatedCost  float64 `json:"allocated_cost"`
+	UnitLandedCost float64 `json:"unit_landed_cost"`
+	MovementID     string  `json:"movement_id"`
+}
+
+type LandedCostEntity struct {
+	Type   string  `json:"type"`
+	Amount float64 `json:"amount"`
+	Note   string  `json:"note"`
+}
+
+type GoodsReceiptEntity struct {
+	ID              string                   `json:"id"`
+	Number          string                   `json:"number"`
+	PurchaseOrderID string                   `json:"purchase_order_id"`
+	PONumber        string                   `json:"po_number"`
+	Warehouse       string                   `json:"warehouse"`
+	Status          GoodsReceiptStatusEnum   `json:"status"`
+	Lines           []GoodsReceiptLineEntity `json:"lines"`
+	LandedCosts     []LandedCostEntity       `json:"landed_costs"`
+	Allocation      LandedCostAllocationEnum `json:"allocation"`
+	TotalLandedCost float64                  `json:"total_landed_cost"`
+	Note            string                   `json:"note"`
+	LastError       string                   `json:"last_error,omitempty"`
+	ReceivedAt      time.Time                `json:"received_at"`
+	CreatedAt       time.Time                `json:"created_at"`
+	CreatedBy       string                   `json:"created_by"`
+	PostedAt        *time.Time               `json:"posted_at"`
+}
+
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func SupplierModelToEntity(model *SupplierModel) *SupplierEntity {
+	return &SupplierEntity{
+		ID:              model.ID.Hex(),
+		Code:            model.Code,
+		Name:            model.Name,
+		TaxID:           model.TaxID,
+		ContactName:     model.ContactName,
+		Email:           model.Email,
+		Phone:           model.Phone,
+		Address:         model.Address,
+		Currency:        model.Currency,
+		PaymentTermDays: model.PaymentTermDays,
+		LeadTimeDays:    model.LeadTimeDays,
+		Note:            model.Note,
+		Active:          model.Active,
+		CreatedAt:       model.CreatedAt,
+		CreatedBy:       model.CreatedBy,
+		UpdatedAt:       model.UpdatedAt,
+		UpdatedBy:       model.UpdatedBy,
+	}
+}
+
+func PurchaseOrderModelToEntity(model *PurchaseOrderModel) *PurchaseOrderEntity {
+	lines := make([]PurchaseOrderLineEntity, 0, len(model.Lines))
+	for _, l := range model.Lines {
+		lines = append(lines, PurchaseOrderLineEntity{
+			LineNo:            l.LineNo,
+			SKU:               l.SKU,
+			Description:       l.Description,
+			Quantity:          l.Quantity,
+			UnitCost:          l.UnitCost,
+			Amount:            round2(float64(l.Quantity) * l.UnitCost),
+			ReceivedQuantity:  l.ReceivedQuantity,
+			RemainingQuantity: l.Quantity - l.ReceivedQuantity,
+		})
+	}
+	return &PurchaseOrderEntity{
+		ID:           model.ID.Hex(),
+		Number:       model.Number,
+		SupplierID:   model.SupplierID.Hex(),
+		SupplierCode: model.SupplierCode,
+		SupplierName: model.SupplierName,
+		Warehouse:    model.Warehouse,
+		Currency:     model.Currency,
+		ExpectedAt:   optionalTime(model.ExpectedAt),
+		Note:         model.Note,
+		Status:       model.Status,
+		Lines:        lines,
+		Subtotal:     model.Subtotal,
+		Version:      model.Version,
+		CreatedAt:    model.CreatedAt,
+		CreatedBy:    model.CreatedBy,
+		UpdatedAt:    model.UpdatedAt,
+		UpdatedBy:    model.UpdatedBy,
+		ApprovedAt:   optionalTime(model.ApprovedAt),
+		ApprovedBy:   model.ApprovedBy,
+		ClosedAt:     optionalTime(model.ClosedAt),
+		ClosedBy:     model.ClosedBy,
+	}
+}
+
+func GoodsReceiptModelToEntity(model *GoodsReceiptModel) *GoodsReceiptEntity {
+	lines := make([]GoodsReceiptLineEntity, 0, len(model.Lines))
+	for _, l := range model.Lines {
+		lines = append(lines, GoodsReceiptLineEntity(l))
+	}
+	costs := make([]LandedCostEntity, 0, len(model.LandedCosts))
+	for _, c := range model.LandedCosts {
+		costs = append(costs, LandedCostEntity(c))
+	}
+	return &GoodsReceiptEntity{
+		ID:              model.ID.Hex(),
+		Number:          model.Number,
+		PurchaseOrderID: model.PurchaseOrderID.Hex(),
+		PONumber:        model.PONumber,
+		Warehouse:       model.Warehouse,
+		Status:          model.Status,
+		Lines:           lines,
+		LandedCosts:     costs,
+		Allocation:      model.Allocation,
+		TotalLandedCost: model.TotalLandedCost,
+		Note:            model.Note,
+		LastError:       model.LastError,
+		ReceivedAt:      model.ReceivedAt,
+		CreatedAt:       model.CreatedAt,
+		CreatedBy:       model.CreatedBy,
+		PostedAt:        optionalTime(model.PostedAt),
+	}
+}
+
+type purchaseService struct {
+	Config *env.Config
+	Logger *zap.Logger
+
+	InventoryService inventory.IInventoryService
+	ProductService   product.IProductService
+
+	SupplierRepository      SupplierRepository
+	PurchaseOrderRepository PurchaseOrderRepository
+	GoodsReceiptRepository  GoodsReceiptRepository
+	SequenceRepository      SequenceRepository
+}
+
+func NewPurchaseService(cfg *env.Config, logger *zap.Logger,
+	inventoryService inventory.IInventoryService,
+	productService product.IProductService,
+	supplier SupplierRepository,
+	order PurchaseOrderRepository,
+	receipt GoodsReceiptRepository,
+	sequence SequenceRepository,
+) IPurchaseService {
+	return &purchaseService{
+		Config:                  cfg,
+		Logger:                  logger,
+		InventoryService:        inventoryService,
+		ProductService:          productService,
+		SupplierRepository:      supplier,
+		PurchaseOrderRepository: order,
+		GoodsReceiptRepository:  receipt,
+		SequenceRepository:      sequence,
+	}
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+func paging(page int, size int) (int64, int64) {
+	if page <= 0 {
+		page = 1
+	}
+	if size <= 0 {
+		size = pageSize
+	}
+	if size > maxPageSize {
+		size = maxPageSize
+	}
+	return int64((page - 1) * size), int64(size)
+}
+
+// number : PREFIX-YYYYMM-00001, the counter restarts every month
+func (s *purchaseService) number(ctx context.Context, prefix string) (string, error) {
+	period := time.Now().Format("200601")
+	seq, err := s.SequenceRepository.NextSequence(ctx, prefix+"-"+period)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s-%s-%05d", prefix, period, seq), nil
+}
+
+// -- suppliers
+
+func (s *purchaseService) CreateSupplier(ctx context.Context, actor string, req *IReqSupplier) (*SupplierEntity, error) {
+	now := time.Now()
+	model := &SupplierModel{Active: true, CreatedAt: now, CreatedBy: actor}
+	applySupplier(model, req, actor, now)
+
+	created, err := s.SupplierRepository.CreateSupplier(ctx, model)
+	if err != nil {
+		return nil, err
+	}
+	return SupplierModelToEntity(created), nil
+}
+
+func (s *purchaseService) UpdateSupplier(ctx context.Context, id string, actor string, req *IReqSupplier) (*SupplierEntity, error) {
+	model, err := s.SupplierRepository.GetSupplierByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	applySupplier(model, req, actor, time.Now())
+
+	updated, err := s.SupplierRepository.UpdateSupplier(ctx, model)
+	if err != nil {
+		return nil, err
+	}
+	return SupplierModelToEntity(updated), nil
+}
+
+func applySupplier(model *SupplierModel, req *IReqSupplier, actor string, now time.Time) {
+	model.Code = strings.ToUpper(strings.TrimSpace(req.Code))
+	model.Name = req.Name
+	model.TaxID = req.TaxID
+	model.ContactName = req.ContactName
+	model.Email = req.Email
+	model.Phone = req.Phone
+	model.Address = req.Address
+	model.Currency = strings.ToUpper(req.Currency)
+	if model.Currency == "" {
+		model.Currency = defaultCurrency
+	}
+	model.PaymentTermDays = req.PaymentTermDays
+	model.LeadTimeDays = req.LeadTimeDays
+	model.Note = req.Note
+	model.UpdatedAt = now
+	model.UpdatedBy = actor
+}
+
+func (s *purchaseService) DeactivateSupplier(ctx context.Context, id string, actor string) (*SupplierEntity, error) {
+	model, err := s.SupplierRepository.GetSupplierByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	model.Active = false
+	model.UpdatedAt = time.Now()
+	model.UpdatedBy = actor
+
+	updated, err := s.SupplierRepository.UpdateSupplier(ctx, model)
+	if err != nil {
+		return nil, err
+	}
+	return SupplierModelToEntity(updated), nil
+}
+
+func (s *purchaseService) GetSupplierByID(ctx context.Context, id string) (*SupplierEntity, error) {
+	model, err := s.SupplierRepository.GetSupplierByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return SupplierModelToEntity(model), nil
+}
+
+func (s *purchaseService) GetSuppliers(ctx context.Context, query *IReqSupplierQuery) ([]SupplierEntity, error) {
+	skip, limit := paging(query.Page, query.Size)
+	models, err := s.SupplierRepository.GetSuppliers(ctx, &SupplierFilter{Q: query.Q, ActiveOnly: query.ActiveOnly, Skip: skip, Limit: limit})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]SupplierEntity, 0, len(models))
+	for i := range models {
+		out = append(out, *SupplierModelToEntity(&models[i]))
+	}
+	return out, nil
+}
+
+// -- purchase orders
+
+// applyOrder : supplier active, warehouse known, every sku in the catalog ; lines renumbered from 1
+func (s *purchaseService) applyOrder(ctx context.Context, model *PurchaseOrderModel, req *IReqPurchaseOrder) error {
+	supplier, err := s.SupplierRepository.GetSupplierByID(ctx, req.SupplierID)
+	if err != nil {
+		return err
+	}
+	if !supplier.Active {
+		return ErrSupplierInactive
+	}
+
+	warehouse := req.Warehouse
+	if warehouse == "" {
+		warehouse = s.Config.Inventory.InventoryDefaultWarehouse
+	}
+	if err := s.checkWarehouse(ctx, warehouse); err != nil {
+		return err
+	}
+
+	lines := make([]PurchaseOrderLineModel, 0, len(req.Lines))
+	var subtotal float64
+	for i, l := range req.Lines {
+		p, err := s.ProductService.GetProductBySKU(ctx, l.SKU)
+		if err != nil {
+			return fmt.Errorf("line %d : %w", i+1, err)
+		}
+		description := l.Description
+		if description == "" {
+			description = p.Name
+		}
+		lines = append(lines, PurchaseOrderLineModel{
+			LineNo:      i + 1,
+			SKU:         l.SKU,
+			Description: description,
+			Quantity:    l.Quantity,
+			UnitCost:    l.UnitCost,
+		})
+		subtotal += float64(l.Quantity) * l.UnitCost
+	}
+
+	model.SupplierID = supplier.ID
+	model.SupplierCode = supplier.Code
+	model.SupplierName = supplier.Name
+	model.Warehouse = warehouse
+	model.Currency = strings.ToUpper(req.Currency)
+	if model.Currency == "" {
+		model.Currency = supplier.Currency
+	}
+	model.ExpectedAt = time.Time{}
+	if req.ExpectedAt != "" {
+		model.ExpectedAt, _ = time.Parse("2006-01-02", req.ExpectedAt)
+	}
+	model.Note = req.Note
+	model.Lines = lines
+	model.Subtotal = round2(subtotal)
+	return nil
+}
+
+func (s *purchaseService) checkWarehouse(ctx context.Context, code string) error {
+	warehouses, err := s.InventoryService.GetWarehouses(ctx)
+	if err != nil {
+		return err
+	}
+	for _, w := range warehouses {
+		if w.Code == code {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w : %s", inventory.ErrWarehouseNotFound, code)
+}
+
+func (s *purchaseService) CreatePurchaseOrder(ctx context.Context, actor string, req *IReqPurchaseOrder) (*PurchaseOrderEntity, error) {
+	now := time.Now()
+	model := &PurchaseOrderModel{Status: PO_DRAFT, ReceiptIDs: []bson.ObjectID{}, CreatedAt: now, CreatedBy: actor, UpdatedAt: now, UpdatedBy: actor}
+	if err := s.applyOrder(ctx, model, req); err != nil {
+		return nil, err
+	}
+	number, err := s.number(ctx, "PO")
+	if err != nil {
+		return nil, err
+	}
+	model.Number = number
+
+	created, err := s.PurchaseOrderRepository.CreatePurchaseOrder(ctx, model)
+	if err != nil {
+		return nil, err
+	}
+	return PurchaseOrderModelToEntity(created), nil
+}
+
+func (s *purchaseService) UpdatePurchaseOrder(ctx context.Context, id string, actor string, req *IReqPurchaseOrder) (*PurchaseOrderEntity, error) {
+	model, err := s.PurchaseOrderRepository.GetPurchaseOrderByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if model.Status != PO_DRAFT {
+		return nil, fmt.Errorf("%w : %s", ErrPurchaseOrderStatus, model.Status)
+	}
+	if err := s.applyOrder(ctx, model, req); err != nil {
+		return nil, err
+	}
+	model.UpdatedAt = time.Now()
+	model.UpdatedBy = actor
+
+	updated, err := s.PurchaseOrderRepository.UpdatePurchaseOrder(ctx, model)
+	if err != nil {
+		return nil, err
+	}
+	return PurchaseOrderModelToEntity(updated), nil
+}
+
+// transition : from one of the allowed statuses, fn sets the rest
+func (s *purchaseService) transition(ctx context.Context, id string, actor string, to PurchaseOrderStatusEnum, from []PurchaseOrderStatusEnum, fn func(model *PurchaseOrderModel) error) (*PurchaseOrderEntity, error) {
+	model, err := s.PurchaseOrderRepository.GetPurchaseOrderByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	allowed := false
+	for _, f := range from {
+		allowed = allowed || model.Status == f
+	}
+	if !allowed {
+		return nil, fmt.Errorf("%w : %s -> %s", ErrPurchaseOrderStatus, model.Status, to)
+	}
+
+	now := time.Now()
+	model.Status = to
+	model.UpdatedAt = now
+	model.UpdatedBy = actor
+	if fn != nil {
+		if err := fn(model); err != nil {
+			return nil, err
+		}
+	}
+
+	updated, err := s.PurchaseOrderRepository.UpdatePurchaseOrder(ctx, model)
+	if err != nil {
+		return nil, err
+	}
+	return PurchaseOrderModelToEntity(updated), nil
+}
+
+func (s *purchaseService) ApprovePurchaseOrder(ctx context.Context, id string, actor string) (*PurchaseOrderEntity, error) {
+	return s.transition(ctx, id, actor, PO_APPROVED, []PurchaseOrderStatusEnum{PO_DRAFT}, func(model *PurchaseOrderModel) error {
+		model.ApprovedAt = model.UpdatedAt
+		model.ApprovedBy = actor
+		return nil
+	})
+}
+
+func (s *purchaseService) CancelPurchaseOrder(ctx context.Context, id string, actor string) (*PurchaseOrderEntity, error) {
+	return s.transition(ctx, id, actor, PO_CANCELLED, []PurchaseOrderStatusEnum{PO_DRAFT, PO_APPROVED}, func(model *PurchaseOrderModel) error {
+		if len(model.ReceiptIDs) > 0 {
+			return fmt.Errorf("%w : goods already received, close it instead", ErrPurchaseOrderStatus)
+		}
+		model.ClosedAt = model.UpdatedAt
+		model.ClosedBy = actor
+		return nil
+	})
+}
+
+func (s *purchaseService) ClosePurchaseOrder(ctx context.Context, id string, actor string) (*PurchaseOrderEntity, error) {
+	return s.transition(ctx, id, actor, PO_CLOSED, []PurchaseOrderStatusEnum{PO_APPROVED, PO_PARTIALLY_RECEIVED}, func(model *PurchaseOrderModel) error {
+		model.ClosedAt = model.UpdatedAt
+		model.ClosedBy = actor
+		return nil
+	})
+}
+
+func (s *purchaseService) GetPurchaseOrderByID(ctx context.Context, id string) (*PurchaseOrderEntity, error) {
+	model, err := s.PurchaseOrderRepository.GetPurchaseOrderByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return PurchaseOrderModelToEntity(model), nil
+}
+
+func (s *purchaseService) GetPurchaseOrders(ctx context.Context, query *IReqPurchaseOrderQuery) ([]PurchaseOrderEntity, error) {
+	skip, limit := paging(query.Page, query.Size)
+	filter := &PurchaseOrderFilter{Q: query.Q, Status: query.Status, Skip: skip, Limit: limit}
+	if query.SupplierID != "" {
+		supplierID, err := bson.ObjectIDFromHex(query.SupplierID)
+		if err != nil {
+			return nil, ErrSupplierNotFound
+		}
+		filter.SupplierID = supplierID
+	}
+
+	models, err := s.PurchaseOrderRepository.GetPurchaseOrders(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]PurchaseOrderEntity, 0, len(models))
+	for i := range models {
+		out = append(out, *PurchaseOrderModelToEntity(&models[i]))
+	}
+	return out, nil
+}
+
+// -- goods receipts
+
+// allocateLandedCost : total spread over the lines by value (quantity when every line is free),
+// rounded to the satang, the rounding rest goes to the last line
+func allocateLandedCost(lines []GoodsReceiptLineModel, total float64, method LandedCostAllocationEnum) {
+	weights := make([]float64, len(lines))
+	var sum float64
+	for i, l := range lines {
+		weights[i] = float64(l.Quantity)
+		if method == ALLOCATE_BY_VALUE {
+			weights[i] = float64(l.Quantity) * l.UnitCost
+		}
+		sum += weights[i]
+	}
+	if sum == 0 {
+		for i, l := range lines {
+			weights[i] = float64(l.Quantity)
+			sum += weights[i]
+		}
+	}
+
+	var allocated float64
+	for i := range lines {
+		share := round2(total * weights[i] / sum)
+		if i == len(lines)-1 {
+			share = round2(total - allocated)
+		}
+		allocated += share
+		lines[i].AllocatedCost = share
+		lines[i].UnitLandedCost = math.Round((lines[i].UnitCost+share/float64(lines[i].Quantity))*10000) / 10000
+	}
+}
+
+func (s *purchaseService) ReceivePurchaseOrder(ctx context.Context, id string, actor string, req *IReqGoodsReceipt) (*GoodsReceiptEntity, error) {
+	order, err := s.PurchaseOrderRepository.GetPurchaseOrderByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if order.Status != PO_APPROVED && order.Status != PO_PARTIALLY_RECEIVED {
+		return nil, fmt.Errorf("%w : %s", ErrPurchaseOrderStatus, order.Status)
+	}
+
+	// one receipt line per order line, repeated line numbers are summed
+	qty := map[int]int64{}
+	seq := []int{}
+	for _, l := range req.Lines {
+		if _, ok := qty[l.LineNo]; !ok {
+			seq = append(seq, l.LineNo)
+		}
+		qty[l.LineNo] += l.Quantity
+	}
+	byLine := map[int]PurchaseOrderLineModel{}
+	for _, l := range order.Lines {
+		byLine[l.LineNo] = l
+	}
+	lines := make([]GoodsReceiptLineModel, 0, len(seq))
+	for _, no := range seq {
+		l, ok := byLine[no]
+		if !ok {
+			return nil, fmt.Errorf("%w : %d", ErrUnknownLine, no)
+		}
+		if l.ReceivedQuantity+qty[no] > l.Quantity {
+			return nil, fmt.Errorf("%w : line %d open %d, received %d", ErrOverReceipt, no, l.Quantity-l.ReceivedQuantity, qty[no])
+		}
+		lines = append(lines, GoodsReceiptLineModel{LineNo: no, SKU: l.SKU, Quantity: qty[no], UnitCost: l.UnitCost})
+	}
+
+	costs := make([]LandedCostModel, 0, len(req.LandedCosts))
+	var total float64
+	for _, c := range req.LandedCosts {
+		costs = append(costs, LandedCostModel{Type: c.Type, Amount: round2(c.Amount), Note: c.Note})
+		total += round2(c.Amount)
+	}
+	allocation := req.Allocation
+	if allocation == "" {
+		allocation = ALLOCATE_BY_VALUE
+	}
+	allocateLandedCost(lines, total, allocation)
+
+	number, err := s.number(ctx, "GRN")
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	receivedAt := now
+	if req.ReceivedAt != "" {
+		receivedAt, _ = time.Parse(time.RFC3339, req.ReceivedAt)
+	}
+	receipt, err := s.GoodsReceiptRepository.CreateGoodsReceipt(ctx, &GoodsReceiptModel{
+		Number:          number,
+		PurchaseOrderID: order.ID,
+		PONumber:        order.Number,
+		Warehouse:       order.Warehouse,
+		Status:          GRN_PENDING,
+		Lines:           lines,
+		LandedCosts:     costs,
+		Allocation:      allocation,
+		TotalLandedCost: round2(total),
+		Note:            req.Note,
+		ReceivedAt:      receivedAt,
+		CreatedAt:       now,
+		CreatedBy:       actor,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.applyReceipt(ctx, order, receipt, actor); err != nil {
+		// order never saw it : the receipt does not exist
+		if delErr := s.GoodsReceiptRepository.DeleteGoodsReceipt(ctx, receipt.ID); delErr != nil {
+			s.Logger.Error("usecase.ReceivePurchaseOrder : DeleteGoodsReceipt", zap.String("grn", receipt.Number), zap.Error(delErr))
+		}
+		return nil, err
+	}
+	return s.post(ctx, receipt, actor)
+}
+
+// applyReceipt : received quantities, status and the receipt marker in one compare-and-swap write
+func (s *purchaseService) applyReceipt(ctx context.Context, order *PurchaseOrderModel, receipt *GoodsReceiptModel, actor string) error {
+	for _, id := range order.ReceiptIDs {
+		if id == receipt.ID {
+			return nil
+		}
+	}
+	if order.Status != PO_APPROVED && order.Status != PO_PARTIALLY_RECEIVED {
+		return fmt.Errorf("%w : %s", ErrPurchaseOrderStatus, order.Status)
+	}
+
+	index := map[int]int{}
+	for i, l := range order.Lines {
+		index[l.LineNo] = i
+	}
+	for _, l := range receipt.Lines {
+		i, ok := index[l.LineNo]
+		if !ok {
+			return fmt.Errorf("%w : %d", ErrUnknownLine, l.LineNo)
+		}
+		if order.Lines[i].ReceivedQuantity+l.Quantity > order.Lines[i].Quantity {
+			return fmt.Errorf("%w : line %d", ErrOverReceipt, l.LineNo)
+		}
+		order.Lines[i].ReceivedQuantity += l.Quantity
+	}
+
+	now := time.Now()
+	order.ReceiptIDs = append(order.ReceiptIDs, receipt.ID)
+	order.Status = PO_CLOSED
+	for _, l := range order.Lines {
+		if l.ReceivedQuantity < l.Quantity {
+			order.Status = PO_PARTIALLY_RECEIVED
+			break
+		}
+	}
+	if order.Status == PO_CLOSED {
+		order.ClosedAt = now
+		order.ClosedBy = actor
+	}
+	order.UpdatedAt = now
+	order.UpdatedBy = actor
+
+	_, err := s.PurchaseOrderRepository.UpdatePurchaseOrder(ctx, order)
+	return err
+}
+
+// post : one RECEIPT movement per line, keyed by receipt number + line so a retry never doubles stock
+func (s *purchaseService) post(ctx context.Context, receipt *GoodsReceiptModel, actor string) (*GoodsReceiptEntity, error) {
+	var errs []error
+	for i, l := range receipt.Lines {
+		if l.MovementID != "" {
+			continue
+		}
+		mv, err := s.InventoryService.PostMovement(ctx, actor, &inventory.MovementModel{
+			Type:           inventory.MOVEMENT_RECEIPT,
+			SKU:            l.SKU,
+			Warehouse:      receipt.Warehouse,
+			OnHandDelta:    l.Quantity,
+			RefType:        inventory.REF_PURCHASE_ORDER,
+			RefID:          receipt.PONumber,
+			IdempotencyKey: "GRN:" + receipt.Number + ":" + strconv.Itoa(l.LineNo),
+			Note:           receipt.Number,
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("line %d : %w", l.LineNo, err))
+			continue
+		}
+		receipt.Lines[i].MovementID = mv.ID
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		receipt.LastError = err.Error()
+	} else {
+		receipt.Status = GRN_POSTED
+		receipt.PostedAt = time.Now()
+		receipt.LastError = ""
+	}
+	if err := s.GoodsReceiptRepository.UpdateGoodsReceipt(ctx, receipt); err != nil {
+		return nil, err
+	}
+	if receipt.Status != GRN_POSTED {
+		s.Logger.Error("usecase.PostGoodsReceipt : movements failed", zap.String("grn", receipt.Number), zap.String("error", receipt.LastError))
+		return nil, fmt.Errorf("%w : %s : %s", ErrGoodsReceiptPending, receipt.Number, receipt.LastError)
+	}
+	return GoodsReceiptModelToEntity(receipt), nil
+}
+
+func (s *purchaseService) PostGoodsReceipt(ctx context.Context, id string, actor string) (*GoodsReceiptEntity, error) {
+	receipt, err := s.GoodsReceiptRepository.GetGoodsReceiptByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if receipt.Status == GRN_POSTED {
+		return GoodsReceiptModelToEntity(receipt), nil
+	}
+
+	// a crash between receipt insert and order write leaves the order untouched : apply it now
+	order, err := s.PurchaseOrderRepository.GetPurchaseOrderByID(ctx, receipt.PurchaseOrderID.Hex())
+	if err != nil {
+		return nil, err
+	}
+	if err := s.applyReceipt(ctx, order, receipt, actor); err != nil {
+		return nil, err
+	}
+	return s.post(ctx, receipt, actor)
+}
+
+func (s *purchaseService) GetGoodsReceiptByID(ctx context.Context, id string) (*GoodsReceiptEntity, error) {
+	model, err := s.GoodsReceiptRepository.GetGoodsReceiptByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return GoodsReceiptModelToEntity(model), nil
+}
+
+func (s *purchaseService) GetGoodsReceipts(ctx context.Context, purchaseOrderID string) ([]GoodsReceiptEntity, error) {
+	order, err := s.PurchaseOrderRepository.GetPurchaseOrderByID(ctx, purchaseOrderID)
+	if err != nil {
+		return nil, err
+	}
+	models, err := s.GoodsReceiptRepository.GetGoodsReceiptsByPurchaseOrder(ctx, order.ID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]GoodsReceiptEntity, 0, len(models))
+	for i := range models {
+		out = append(out, *GoodsReceiptModelToEntity(&models[i]))
+	}
+	return out, nil
+}
@@ -0,0 +1,209 @@
+package item
+
+import (
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	"ecommerce/internal/adapter/dto"
+	"ecommerce/internal/delivery/http/response"
+)
+
+type IReqShopeeItemListQuery struct {
+	Offset         int32  `query:"offset"`
+	PageSize       int32  `query:"page_size"`
+	ItemStatus     string `query:"item_status"` // comma separated, default NORMAL
+	UpdateTimeFrom int64  `query:"update_time_from"`
+	UpdateTimeTo   int64  `query:"update_time_to"`
+}
+
+type IReqShopeeItemPrice struct {
+	PriceList []dto.IBItemPrice `json:"price_list" validate:"required,min=1"`
+}
+
+type IReqShopeeItemStock struct {
+	StockList []dto.IBItemStock `json:"stock_list" validate:"required,min=1"`
+}
+
+type IReqShopeeItemUnlist struct {
+	Unlist *bool `json:"unlist" validate:"required"`
+}
+
+type IShopeeItemHandler interface {
+	GetShopeeItemListByShopID(c *fiber.Ctx) error
+	GetShopeeItemByItemID(c *fiber.Ctx) error
+	GetShopeeItemModelList(c *fiber.Ctx) error
+	CreateShopeeItem(c *fiber.Ctx) error
+	UpdateShopeeItem(c *fiber.Ctx) error
+	UpdateShopeeItemPrice(c *fiber.Ctx) error
+	UpdateShopeeItemStock(c *fiber.Ctx) error
+	UnlistShopeeItem(c *fiber.Ctx) error
+}
+
+type shopeeItemHandler struct {
+	Logger   *zap.Logger
+	Validate *validator.Validate
+	Service  IShopeeItemService
+}
+
+func NewShopeeItemHandler(log *zap.Logger, valid *validator.Validate, srv IShopeeItemService) IShopeeItemHandler {
+	return &shopeeItemHandler{
+		Logger:   log,
+		Validate: valid,
+		Service:  srv,
+	}
+}
+
+func parseShopeeItemParams(c *fiber.Ctx) (string, int64, bool) {
+	shopID := c.Params("shopeeShopID")
+	itemID, err := strconv.ParseInt(c.Params("itemID"), 10, 64)
+	if shopID == "" || err != nil || itemID <= 0 {
+		return "", 0, false
+	}
+	return shopID, itemID, true
+}
+
+func (d *shopeeItemHandler) GetShopeeItemListByShopID(c *fiber.Ctx) error {
+	shopID := c.Params("shopeeShopID")
+	if shopID == "" {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.GetShopeeItemListByShopID", "shopeeShopID is required")
+	}
+
+	var query IReqShopeeItemListQuery
+	if err := c.QueryParser(&query); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.GetShopeeItemListByShopID", "invalid query")
+	}
+
+	res, err := d.Service.GetShopeeItemListByShopID(c.Context(), shopID, &query)
+	if err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.GetShopeeItemListByShopID", err)
+	}
+	return response.SuccessResponse(c, "handler.GetShopeeItemListByShopID", res)
+}
+
+func (d *shopeeItemHandler) GetShopeeItemByItemID(c *fiber.Ctx) error {
+	shopID, itemID, ok := parseShopeeItemParams(c)
+	if !ok {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.GetShopeeItemByItemID", "shopeeShopID and itemID are required")
+	}
+
+	res, err := d.Service.GetShopeeItemByItemID(c.Context(), shopID, itemID)
+	if err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.GetShopeeItemByItemID", err)
+	}
+	return response.SuccessResponse(c, "handler.GetShopeeItemByItemID", res)
+}
+
+func (d *shopeeItemHandler) GetShopeeItemModelList(c *fiber.Ctx) error {
+	shopID, itemID, ok := parseShopeeItemParams(c)
+	if !ok {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.GetShopeeItemModelList", "shopeeShopID and itemID are required")
+	}
+
+	res, err := d.Service.GetShopeeItemModelList(c.Context(), shopID, itemID)
+	if err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.GetShopeeItemModelList", err)
+	}
+	return response.SuccessResponse(c, "handler.GetShopeeItemModelList", res)
+}
+
+func (d *shopeeItemHandler) CreateShopeeItem(c *fiber.Ctx) error {
+	shopID := c.Params("shopeeShopID")
+	if shopID == "" {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.CreateShopeeItem", "shopeeShopID is required")
+	}
+
+	var reqBody dto.IBAddItem
+	if err := c.BodyParser(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.CreateShopeeItem", "invalid body")
+	}
+
+	res, err := d.Service.CreateShopeeItem(c.Context(), shopID, &reqBody)
+	if err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.CreateShopeeItem", err)
+	}
+	return response.SuccessResponse(c, "handler.CreateShopeeItem", res)
+}
+
+func (d *shopeeItemHandler) UpdateShopeeItem(c *fiber.Ctx) error {
+	shopID, itemID, ok := parseShopeeItemParams(c)
+	if !ok {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.UpdateShopeeItem", "shopeeShopID and itemID are required")
+	}
+
+	var reqBody dto.IBUpdateItem
+	if err := c.BodyParser(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.UpdateShopeeItem", "invalid body")
+	}
+
+	res, err := d.Service.UpdateShopeeItem(c.Context(), shopID, itemID, &reqBody)
+	if err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.UpdateShopeeItem", err)
+	}
+	return response.SuccessResponse(c, "handler.UpdateShopeeItem", res)
+}
+
+func (d *shopeeItemHandler) UpdateShopeeItemPrice(c *fiber.Ctx) error {
+	shopID, itemID, ok := parseShopeeItemParams(c)
+	if !ok {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.UpdateShopeeItemPrice", "shopeeShopID and itemID are required")
+	}
+
+	var reqBody IReqShopeeItemPrice
+	if err := c.BodyParser(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.UpdateShopeeItemPrice", "invalid body")
+	}
+	if err := d.Validate.Struct(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.UpdateShopeeItemPrice", "invalid body")
+	}
+
+	res, err := d.Service.UpdateShopeeItemPrice(c.Context(), shopID, itemID, &reqBody)
+	if err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.UpdateShopeeItemPrice", err)
+	}
+	return response.SuccessResponse(c, "handler.UpdateShopeeItemPrice", res)
+}
+
+func (d *shopeeItemHandler) UpdateShopeeItemStock(c *fiber.Ctx) error {
+	shopID, itemID, ok := parseShopeeItemParams(c)
+	if !ok {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.UpdateShopeeItemStock", "shopeeShopID and itemID are required")
+	}
+
+	var reqBody IReqShopeeItemStock
+	if err := c.BodyParser(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.UpdateShopeeItemStock", "invalid body")
+	}
+	if err := d.Validate.Struct(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.UpdateShopeeItemStock", "invalid body")
+	}
+
+	res, err := d.Service.UpdateShopeeItemStock(c.Context(), shopID, itemID, &reqBody)
+	if err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.UpdateShopeeItemStock", err)
+	}
+	return response.SuccessResponse(c, "handler.UpdateShopeeItemStock", res)
+}
+
+func (d *shopeeItemHandler) UnlistShopeeItem(c *fiber.Ctx) error {
+	shopID, itemID, ok := parseShopeeItemParams(c)
+	if !ok {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.UnlistShopeeItem", "shopeeShopID and itemID are required")
+	}
+
+	var reqBody IReqShopeeItemUnlist
+	if err := c.BodyParser(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.UnlistShopeeItem", "invalid body")
+	}
+	if err := d.Validate.Struct(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.UnlistShopeeItem", "unlist is required")
+	}
+
+	res, err := d.Service.UnlistShopeeItem(c.Context(), shopID, itemID, *reqBody.Unlist)
+	if err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.UnlistShopeeItem", err)
+	}
+	return response.SuccessResponse(c, "handler.UnlistShopeeItem", res)
+}
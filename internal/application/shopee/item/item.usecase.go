@@ -0,0 +1,262 @@
+package item
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"ecommerce/internal/adapter"
+	"ecommerce/internal/adapter/dto"
+	"ecommerce/internal/application/shopee"
+	"ecommerce/internal/env"
+)
+
+type IShopeeItemService interface {
+	// one page of get_item_list, enriched with get_item_base_info
+	GetShopeeItemListByShopID(ctx context.Context, shopID string, query *IReqShopeeItemListQuery) (*ShopeeItemListEntity, error)
+	// base info + models
+	GetShopeeItemByItemID(ctx context.Context, shopID string, itemID int64) (*ShopeeItemDetailEntity, error)
+	GetShopeeItemModelList(ctx context.Context, shopID string, itemID int64) (*dto.IResGetModelListWrapper, error)
+
+	CreateShopeeItem(ctx context.Context, shopID string, body *dto.IBAddItem) (*dto.IResAddItemWrapper, error)
+	UpdateShopeeItem(ctx context.Context, shopID string, itemID int64, body *dto.IBUpdateItem) (*dto.IResAddItemWrapper, error)
+	// partial failures are returned in FailureList, not as error
+	UpdateShopeeItemPrice(ctx context.Context, shopID string, itemID int64, req *IReqShopeeItemPrice) (*dto.IResUpdatePriceWrapper, error)
+	UpdateShopeeItemStock(ctx context.Context, shopID string, itemID int64, req *IReqShopeeItemStock) (*dto.IResUpdateStockWrapper, error)
+	UnlistShopeeItem(ctx context.Context, shopID string, itemID int64, unlist bool) (*dto.IResUnlistItemWrapper, error)
+}
+
+type ShopeeItemListEntity struct {
+	Items       []dto.IResItemBaseInfo `json:"items"`
+	TotalCount  int64                  `json:"total_count"`
+	HasNextPage bool                   `json:"has_next_page"`
+	NextOffset  int32                  `json:"next_offset"`
+}
+
+type ShopeeItemDetailEntity struct {
+	Item          dto.IResItemBaseInfo        `json:"item"`
+	TierVariation []dto.IResItemTierVariation `json:"tier_variation"`
+	Models        []dto.IResItemModel         `json:"models"`
+}
+
+type shopeeItemService struct {
+	Config *env.Config
+	Logger *zap.Logger
+
+	ShopeeAdapter adapter.IShopeeService
+	ShopeeService shopee.IShopeeService
+}
+
+func NewShopeeItemService(cfg *env.Config, logger *zap.Logger, shopeeAdapter adapter.IShopeeService, shopeeService shopee.IShopeeService) IShopeeItemService {
+	return &shopeeItemService{
+		Config:        cfg,
+		Logger:        logger,
+		ShopeeAdapter: shopeeAdapter,
+		ShopeeService: shopeeService,
+	}
+}
+
+func (s *shopeeItemService) GetShopeeItemListByShopID(ctx context.Context, shopID string, query *IReqShopeeItemListQuery) (*ShopeeItemListEntity, error) {
+	params, err := s.ShopeeService.GetShopeeAdapterParamsByShopID(ctx, shopID)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &dto.IOptionShopeeItemListQuery{
+		Offset:         query.Offset,
+		PageSize:       query.PageSize,
+		UpdateTimeFrom: query.UpdateTimeFrom,
+		UpdateTimeTo:   query.UpdateTimeTo,
+	}
+	for _, st := range strings.Split(query.ItemStatus, ",") {
+		if st = strings.TrimSpace(st); st != "" {
+			opts.ItemStatus = append(opts.ItemStatus, dto.IEnumShopeeItemStatus(strings.ToUpper(st)))
+		}
+	}
+
+	page, err := s.ShopeeAdapter.GetItemListByShopID(ctx, params, opts)
+	if err != nil {
+		s.Logger.Error("usecase.GetShopeeItemListByShopID : GetItemListByShopID", zap.String("shop_id", shopID), zap.Error(err))
+		return nil, err
+	}
+
+	itemID := make([]int64, 0, len(page.Item))
+	for _, it := range page.Item {
+		itemID = append(itemID, it.ItemID)
+	}
+
+	res := &ShopeeItemListEntity{
+		Items:       make([]dto.IResItemBaseInfo, 0, len(itemID)),
+		TotalCount:  page.TotalCount,
+		HasNextPage: page.HasNextPage,
+		NextOffset:  page.NextOffset,
+	}
+	for start := 0; start < len(itemID); start += adapter.ShopeeItemBaseInfoMaxItem {
+		end := min(start+adapter.ShopeeItemBaseInfoMaxItem, len(itemID))
+		info, err := s.ShopeeAdapter.GetItemBaseInfo(ctx, params, itemID[start:end])
+		if err != nil {
+			s.Logger.Error("usecase.GetShopeeItemListByShopID : GetItemBaseInfo", zap.String("shop_id", shopID), zap.Error(err))
+			return nil, err
+		}
+		res.Items = append(res.Items, info...)
+	}
+	return res, nil
+}
+
+func (s *shopeeItemService) GetShopeeItemByItemID(ctx context.Context, shopID string, itemID int64) (*ShopeeItemDetailEntity, error) {
+	params, err := s.ShopeeService.GetShopeeAdapterParamsByShopID(ctx, shopID)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := s.ShopeeAdapter.GetItemBaseInfo(ctx, params, []int64{itemID})
+	if err != nil {
+		return nil, err
+	}
+	if len(info) == 0 {
+		return nil, errors.New("item not found")
+	}
+
+	res := &ShopeeItemDetailEntity{Item: info[0]}
+	if !info[0].HasModel {
+		return res, nil
+	}
+
+	models, err := s.ShopeeAdapter.GetModelList(ctx, params, itemID)
+	if err != nil {
+		return nil, err
+	}
+	res.TierVariation = models.TierVariation
+	res.Models = models.Model
+	return res, nil
+}
+
+func (s *shopeeItemService) GetShopeeItemModelList(ctx context.Context, shopID string, itemID int64) (*dto.IResGetModelListWrapper, error) {
+	params, err := s.ShopeeService.GetShopeeAdapterParamsByShopID(ctx, shopID)
+	if err != nil {
+		return nil, err
+	}
+	return s.ShopeeAdapter.GetModelList(ctx, params, itemID)
+}
+
+func (s *shopeeItemService) CreateShopeeItem(ctx context.Context, shopID string, body *dto.IBAddItem) (*dto.IResAddItemWrapper, error) {
+	if err := validateShopeeAddItem(body); err != nil {
+		return nil, err
+	}
+
+	params, err := s.ShopeeService.GetShopeeAdapterParamsByShopID(ctx, shopID)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.ShopeeAdapter.AddItem(ctx, params, body)
+	if err != nil {
+		s.Logger.Error("usecase.CreateShopeeItem : AddItem", zap.String("shop_id", shopID), zap.Error(err))
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s *shopeeItemService) UpdateShopeeItem(ctx context.Context, shopID string, itemID int64, body *dto.IBUpdateItem) (*dto.IResAddItemWrapper, error) {
+	params, err := s.ShopeeService.GetShopeeAdapterParamsByShopID(ctx, shopID)
+	if err != nil {
+		return nil, err
+	}
+
+	body.ItemID = itemID
+	res, err := s.ShopeeAdapter.UpdateItem(ctx, params, body)
+	if err != nil {
+		s.Logger.Error("usecase.UpdateShopeeItem : UpdateItem", zap.String("shop_id", shopID), zap.Int64("item_id", itemID), zap.Error(err))
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s *shopeeItemService) UpdateShopeeItemPrice(ctx context.Context, shopID string, itemID int64, req *IReqShopeeItemPrice) (*dto.IResUpdatePriceWrapper, error) {
+	for _, p := range req.PriceList {
+		if p.OriginalPrice <= 0 {
+			return nil, errors.New("original_price must be greater than 0")
+		}
+	}
+
+	params, err := s.ShopeeService.GetShopeeAdapterParamsByShopID(ctx, shopID)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.ShopeeAdapter.UpdatePrice(ctx, params, &dto.IBUpdatePrice{ItemID: itemID, PriceList: req.PriceList})
+	if err != nil {
+		s.Logger.Error("usecase.UpdateShopeeItemPrice : UpdatePrice", zap.String("shop_id", shopID), zap.Int64("item_id", itemID), zap.Error(err))
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s *shopeeItemService) UpdateShopeeItemStock(ctx context.Context, shopID string, itemID int64, req *IReqShopeeItemStock) (*dto.IResUpdateStockWrapper, error) {
+	for _, st := range req.StockList {
+		for _, ss := range st.SellerStock {
+			if ss.Stock < 0 {
+				return nil, errors.New("stock must not be negative")
+			}
+		}
+	}
+
+	params, err := s.ShopeeService.GetShopeeAdapterParamsByShopID(ctx, shopID)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.ShopeeAdapter.UpdateStock(ctx, params, &dto.IBUpdateStock{ItemID: itemID, StockList: req.StockList})
+	if err != nil {
+		s.Logger.Error("usecase.UpdateShopeeItemStock : UpdateStock", zap.String("shop_id", shopID), zap.Int64("item_id", itemID), zap.Error(err))
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s *shopeeItemService) UnlistShopeeItem(ctx context.Context, shopID string, itemID int64, unlist bool) (*dto.IResUnlistItemWrapper, error) {
+	params, err := s.ShopeeService.GetShopeeAdapterParamsByShopID(ctx, shopID)
+	if err != nil {
+		return nil, err
+	}
+
+	body := &dto.IBUnlistItem{ItemList: []dto.IBUnlistItemEntry{{ItemID: itemID, Unlist: unlist}}}
+	res, err := s.ShopeeAdapter.UnlistItem(ctx, params, body)
+	if err != nil {
+		s.Logger.Error("usecase.UnlistShopeeItem : UnlistItem", zap.String("shop_id", shopID), zap.Int64("item_id", itemID), zap.Error(err))
+		return nil, err
+	}
+	return res, nil
+}
+
+// required by /product/add_item : checked here to save a round trip
+func validateShopeeAddItem(body *dto.IBAddItem) error {
+	var missing []string
+	if body.ItemName == "" {
+		missing = append(missing, "item_name")
+	}
+	if body.Description == "" {
+		missing = append(missing, "description")
+	}
+	if body.CategoryID == 0 {
+		missing = append(missing, "category_id")
+	}
+	if body.OriginalPrice <= 0 {
+		missing = append(missing, "original_price")
+	}
+	if body.Weight <= 0 {
+		missing = append(missing, "weight")
+	}
+	if len(body.Image.ImageIDList) == 0 {
+		missing = append(missing, "image.image_id_list")
+	}
+	if len(body.LogisticInfo) == 0 {
+		missing = append(missing, "logistic_info")
+	}
+	if len(missing) > 0 {
+		return errors.New("missing required fields: " + strings.Join(missing, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package label
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	"ecommerce/internal/adapter/dto"
+	"ecommerce/internal/application/shopee"
+	"ecommerce/internal/delivery/http/response"
+)
+
+type IReqShopeeLabel struct {
+	PackageNumber string                        `json:"package_number" query:"package_number"`
+	DocumentType  dto.IEnumShippingDocumentType `json:"document_type" query:"document_type" validate:"omitempty,oneof=NORMAL_AIR_WAYBILL THERMAL_AIR_WAYBILL NORMAL_JOB_AIR_WAYBILL THERMAL_JOB_AIR_WAYBILL"`
+	// regenerate even when a stored label exists
+	Force bool `json:"force"`
+}
+
+type IReqShopeeLabelPackage struct {
+	OrderSN       string `json:"order_sn" validate:"required"`
+	PackageNumber string `json:"package_number"`
+}
+
+type IReqShopeeLabelBatch struct {
+	Packages     []IReqShopeeLabelPackage      `json:"packages" validate:"required,min=1,max=200,dive"`
+	DocumentType dto.IEnumShippingDocumentType `json:"document_type" validate:"omitempty,oneof=NORMAL_AIR_WAYBILL THERMAL_AIR_WAYBILL NORMAL_JOB_AIR_WAYBILL THERMAL_JOB_AIR_WAYBILL"`
+}
+
+type IShopeeLabelHandler interface {
+	PostShippingLabel(c *fiber.Ctx) error
+	// pdf when READY, 202 + label while PROCESSING
+	GetShippingLabel(c *fiber.Ctx) error
+	// merged pdf when every label is READY, 202 + labels otherwise
+	PostShippingLabelBatch(c *fiber.Ctx) error
+}
+
+type shopeeLabelHandler struct {
+	Logger   *zap.Logger
+	Validate *validator.Validate
+	Service  IShopeeLabelService
+}
+
+func NewShopeeLabelHandler(log *zap.Logger, valid *validator.Validate, srv IShopeeLabelService) IShopeeLabelHandler {
+	return &shopeeLabelHandler{
+		Logger:   log,
+		Validate: valid,
+		Service:  srv,
+	}
+}
+
+func sendPDF(c *fiber.Ctx, filename string, data []byte) error {
+	c.Set(fiber.HeaderContentType, "application/pdf")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`inline; filename="%s"`, filename))
+	return c.Status(fiber.StatusOK).Send(data)
+}
+
+func (d *shopeeLabelHandler) PostShippingLabel(c *fiber.Ctx) error {
+	shopID := c.Params("shopeeShopID")
+	orderSN := c.Params("orderSN")
+	if shopID == "" || orderSN == "" {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostShippingLabel", "shopeeShopID and orderSN are required")
+	}
+
+	var reqBody IReqShopeeLabel
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&reqBody); err != nil {
+			return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostShippingLabel", "invalid body")
+		}
+	}
+	if err := d.Validate.Struct(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostShippingLabel", "invalid document_type")
+	}
+
+	res, err := d.Service.CreateShippingLabel(c.Context(), shopID, orderSN, &reqBody)
+	if err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostShippingLabel", err)
+	}
+	return response.SuccessResponse(c, "handler.PostShippingLabel", res)
+}
+
+func (d *shopeeLabelHandler) GetShippingLabel(c *fiber.Ctx) error {
+	shopID := c.Params("shopeeShopID")
+	orderSN := c.Params("orderSN")
+	if shopID == "" || orderSN == "" {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.GetShippingLabel", "shopeeShopID and orderSN are required")
+	}
+
+	var query IReqShopeeLabel
+	if err := c.QueryParser(&query); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.GetShippingLabel", "invalid query")
+	}
+	if err := d.Validate.Struct(&query); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.GetShippingLabel", "invalid document_type")
+	}
+
+	label, err := d.Service.GetShippingLabel(c.Context(), shopID, orderSN, &query)
+	if err != nil {
+		return response.ErrorResponse(c, shopee.ShopeeErrorStatus(err), "handler.GetShippingLabel", err)
+	}
+
+	switch label.Status {
+	case LABEL_PROCESSING:
+		return response.AcceptedResponse(c, "handler.GetShippingLabel", label)
+	case LABEL_FAILED:
+		return response.ErrorResponse(c, fiber.StatusUnprocessableEntity, "handler.GetShippingLabel", label)
+	}
+
+	data, err := d.Service.GetShippingLabelFile(c.Context(), label)
+	if err != nil {
+		d.Logger.Error("handler.GetShippingLabel : GetShippingLabelFile", zap.String("key", label.BlobKey), zap.Error(err))
+		return response.ErrorResponse(c, fiber.StatusInternalServerError, "handler.GetShippingLabel", err)
+	}
+	return sendPDF(c, fmt.Sprintf("%s_%s.pdf", label.OrderSN, label.PackageNumber), data)
+}
+
+func (d *shopeeLabelHandler) PostShippingLabelBatch(c *fiber.Ctx) error {
+	shopID := c.Params("shopeeShopID")
+	if shopID == "" {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostShippingLabelBatch", "shopeeShopID is required")
+	}
+
+	var reqBody IReqShopeeLabelBatch
+	if err := c.BodyParser(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostShippingLabelBatch", "invalid body")
+	}
+	if err := d.Validate.Struct(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostShippingLabelBatch", "invalid body")
+	}
+
+	labels, merged, err := d.Service.GetShippingLabelBatch(c.Context(), shopID, &reqBody)
+	if err != nil {
+		if errors.Is(err, ErrLabelNotReady) {
+			return response.AcceptedResponse(c, "handler.PostShippingLabelBatch", labels)
+		}
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostShippingLabelBatch", err)
+	}
+	return sendPDF(c, fmt.Sprintf("labels_%s_%d.pdf", shopID, len(labels)), merged)
+}
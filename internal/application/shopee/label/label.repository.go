@@ -0,0 +1,162 @@
+package label
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"go.uber.org/zap"
+
+	"ecommerce/internal/adapter/dto"
+	"ecommerce/internal/pkg"
+)
+
+type ShopeeLabelStatusEnum string
+
+const (
+	LABEL_PROCESSING ShopeeLabelStatusEnum = "PROCESSING" // created on Shopee, result pending
+	LABEL_READY      ShopeeLabelStatusEnum = "READY"      // file stored in blob store
+	LABEL_FAILED     ShopeeLabelStatusEnum = "FAILED"
+)
+
+// one document per (order_sn, package_number, document_type)
+type ShopeeLabelModel struct {
+	ID            bson.ObjectID                 `bson:"_id"`
+	TenantID      string                        `bson:"tenant_id,omitempty"` // tenant of the shop : see pkg.TenantFilter
+	ShopID        string                        `bson:"shop_id"`
+	OrderSN       string                        `bson:"order_sn"`
+	PackageNumber string                        `bson:"package_number"`
+	DocumentType  dto.IEnumShippingDocumentType `bson:"document_type"`
+	Status        ShopeeLabelStatusEnum         `bson:"status"`
+	BlobKey       string                        `bson:"blob_key"`
+	Error         string                        `bson:"error"`
+	Polls         int                           `bson:"polls"`
+
+	ReadyAt   time.Time `bson:"ready_at"`
+	CreatedAt time.Time `bson:"created_at"`
+	UpdatedAt time.Time `bson:"updated_at"`
+}
+
+type ShopeeLabelEntity struct {
+	ID            string                        `json:"id"`
+	ShopID        string                        `json:"shop_id"`
+	OrderSN       string                        `json:"order_sn"`
+	PackageNumber string                        `json:"package_number"`
+	DocumentType  dto.IEnumShippingDocumentType `json:"document_type"`
+	Status        ShopeeLabelStatusEnum         `json:"status"`
+	BlobKey       string                        `json:"blob_key"`
+	Error         string                        `json:"error"`
+	Polls         int                           `json:"polls"`
+
+	ReadyAt   time.Time `json:"ready_at"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func ShopeeLabelModelToEntity(model *ShopeeLabelModel) *ShopeeLabelEntity {
+	return &ShopeeLabelEntity{
+		ID:            model.ID.Hex(),
+		ShopID:        model.ShopID,
+		OrderSN:       model.OrderSN,
+		PackageNumber: model.PackageNumber,
+		DocumentType:  model.DocumentType,
+		Status:        model.Status,
+		BlobKey:       model.BlobKey,
+		Error:         model.Error,
+		Polls:         model.Polls,
+		ReadyAt:       model.ReadyAt,
+		CreatedAt:     model.CreatedAt,
+		UpdatedAt:     model.UpdatedAt,
+	}
+}
+
+type ShopeeLabelRepository interface {
+	InitRepository() error
+	GetShopeeLabel(ctx context.Context, orderSN string, packageNumber string, docType dto.IEnumShippingDocumentType) (*ShopeeLabelModel, error)
+	// upsert by (order_sn, package_number, document_type)
+	SaveShopeeLabel(ctx context.Context, label *ShopeeLabelModel) (*ShopeeLabelModel, error)
+}
+
+type shopeeLabelRepository struct {
+	Logger *zap.Logger
+	DB     *mongo.Collection
+}
+
+func NewShopeeLabelRepository(db *mongo.Collection, log *zap.Logger) ShopeeLabelRepository {
+	return &shopeeLabelRepository{Logger: log, DB: db}
+}
+
+func (r *shopeeLabelRepository) InitRepository() error {
+	indexs := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "order_sn", Value: 1}, {Key: "package_number", Value: 1}, {Key: "document_type", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "shop_id", Value: 1}, {Key: "status", Value: 1}}},
+	}
+	if _, err := r.DB.Indexes().CreateMany(context.TODO(), indexs); err != nil {
+		r.Logger.Error("error creating index", zap.Error(err))
+		return errors.New("ShopeeLabelRepository.InitRepository: failed create indexs")
+	}
+	r.Logger.Info("ShopeeLabelRepository.InitRepository: index created")
+	return nil
+}
+
+func (r *shopeeLabelRepository) GetShopeeLabel(ctx context.Context, orderSN string, packageNumber string, docType dto.IEnumShippingDocumentType) (*ShopeeLabelModel, error) {
+	filter := pkg.TenantFilter(ctx, bson.M{"order_sn": orderSN, "package_number": packageNumber, "document_type": docType})
+
+	var label ShopeeLabelModel
+	if err := r.DB.FindOne(ctx, filter).Decode(&label); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, errors.New("label not found")
+		}
+		return nil, err
+	}
+	return &label, nil
+}
+
+func (r *shopeeLabelRepository) SaveShopeeLabel(ctx context.Context, label *ShopeeLabelModel) (*ShopeeLabelModel, error) {
+	now := time.Now()
+	if label.ID.IsZero() {
+		label.ID = bson.NewObjectID()
+	}
+	if label.CreatedAt.IsZero() {
+		label.CreatedAt = now
+	}
+	label.UpdatedAt = now
+
+	filter := pkg.TenantFilter(ctx, bson.M{"order_sn": label.OrderSN, "package_number": label.PackageNumber, "document_type": label.DocumentType})
+	onInsert := bson.M{
+		"_id":        label.ID,
+		"created_at": label.CreatedAt,
+	}
+	tenantID, err := pkg.TenantStamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if tenantID != "" {
+		onInsert["tenant_id"] = tenantID
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"shop_id":    label.ShopID,
+			"status":     label.Status,
+			"blob_key":   label.BlobKey,
+			"error":      label.Error,
+			"polls":      label.Polls,
+			"ready_at":   label.ReadyAt,
+			"updated_at": label.UpdatedAt,
+		},
+		"$setOnInsert": onInsert,
+	}
+
+	opt := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	var saved ShopeeLabelModel
+	if err := r.DB.FindOneAndUpdate(ctx, filter, update, opt).Decode(&saved); err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
@@ -0,0 +1,375 @@
+package label
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"ecommerce/internal/adapter"
+	"ecommerce/internal/adapter/dto"
+	"ecommerce/internal/adapter/storage"
+	"ecommerce/internal/application/shopee"
+	"ecommerce/internal/application/shopee/logistics"
+	"ecommerce/internal/env"
+	"ecommerce/internal/pkg"
+)
+
+var ErrLabelNotReady = errors.New("shipping label is not ready")
+
+type IShopeeLabelService interface {
+	// create_shipping_document, poll the result a few times, store the file when ready
+	CreateShippingLabel(ctx context.Context, shopID string, orderSN string, req *IReqShopeeLabel) (*ShopeeLabelEntity, error)
+	// stored label : a PROCESSING label is polled once more
+	GetShippingLabel(ctx context.Context, shopID string, orderSN string, req *IReqShopeeLabel) (*ShopeeLabelEntity, error)
+	GetShippingLabelFile(ctx context.Context, label *ShopeeLabelEntity) ([]byte, error)
+	// picking wave : one merged pdf, ErrLabelNotReady while any label is pending or failed
+	GetShippingLabelBatch(ctx context.Context, shopID string, req *IReqShopeeLabelBatch) ([]ShopeeLabelEntity, []byte, error)
+}
+
+type shopeeLabelService struct {
+	Config *env.Config
+	Logger *zap.Logger
+
+	ShopeeAdapter         adapter.IShopeeService
+	ShopeeService         shopee.IShopeeService
+	ShopeeOrderRepository shopee.ShopeeOrderRepository
+	ShopeeLabelRepository ShopeeLabelRepository
+	BlobStore             storage.IBlobStore
+}
+
+func NewShopeeLabelService(cfg *env.Config, logger *zap.Logger,
+	shopeeAdapter adapter.IShopeeService,
+	shopeeService shopee.IShopeeService,
+	shopeeOrder shopee.ShopeeOrderRepository,
+	shopeeLabel ShopeeLabelRepository,
+	blob storage.IBlobStore,
+) IShopeeLabelService {
+	return &shopeeLabelService{
+		Config:                cfg,
+		Logger:                logger,
+		ShopeeAdapter:         shopeeAdapter,
+		ShopeeService:         shopeeService,
+		ShopeeOrderRepository: shopeeOrder,
+		ShopeeLabelRepository: shopeeLabel,
+		BlobStore:             blob,
+	}
+}
+
+func ShopeeLabelBlobKey(orderSN string, packageNumber string, docType dto.IEnumShippingDocumentType) string {
+	if packageNumber == "" {
+		packageNumber = "-"
+	}
+	return fmt.Sprintf("shopee/label/%s/%s/%s.pdf", orderSN, packageNumber, docType)
+}
+
+func documentTypeOrDefault(docType dto.IEnumShippingDocumentType) dto.IEnumShippingDocumentType {
+	if docType == "" {
+		return dto.THERMAL_AIR_WAYBILL
+	}
+	return docType
+}
+
+// labelTarget : one package to print
+type labelTarget struct {
+	OrderSN        string
+	PackageNumber  string
+	TrackingNumber string
+}
+
+func (s *shopeeLabelService) CreateShippingLabel(ctx context.Context, shopID string, orderSN string, req *IReqShopeeLabel) (*ShopeeLabelEntity, error) {
+	params, err := s.ShopeeService.GetShopeeAdapterParamsByShopID(ctx, shopID)
+	if err != nil {
+		return nil, err
+	}
+	target, err := s.resolveLabelTarget(ctx, shopID, orderSN, req.PackageNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	labels, err := s.ensureShippingLabels(ctx, params, []labelTarget{*target}, documentTypeOrDefault(req.DocumentType), req.Force)
+	if err != nil {
+		return nil, err
+	}
+	return ShopeeLabelModelToEntity(labels[0]), nil
+}
+
+func (s *shopeeLabelService) GetShippingLabel(ctx context.Context, shopID string, orderSN string, req *IReqShopeeLabel) (*ShopeeLabelEntity, error) {
+	docType := documentTypeOrDefault(req.DocumentType)
+	target, err := s.resolveLabelTarget(ctx, shopID, orderSN, req.PackageNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	label, err := s.ShopeeLabelRepository.GetShopeeLabel(ctx, orderSN, target.PackageNumber, docType)
+	if err != nil {
+		return nil, err
+	}
+	if label.ShopID != shopID {
+		return nil, errors.New("label not found")
+	}
+	if label.Status != LABEL_PROCESSING {
+		return ShopeeLabelModelToEntity(label), nil
+	}
+
+	params, err := s.ShopeeService.GetShopeeAdapterParamsByShopID(ctx, shopID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.pollShippingLabels(ctx, params, []*ShopeeLabelModel{label}, 1); err != nil {
+		return nil, err
+	}
+	return ShopeeLabelModelToEntity(label), nil
+}
+
+func (s *shopeeLabelService) GetShippingLabelFile(ctx context.Context, label *ShopeeLabelEntity) ([]byte, error) {
+	if label.Status != LABEL_READY {
+		return nil, ErrLabelNotReady
+	}
+	return s.BlobStore.Get(ctx, label.BlobKey)
+}
+
+func (s *shopeeLabelService) GetShippingLabelBatch(ctx context.Context, shopID string, req *IReqShopeeLabelBatch) ([]ShopeeLabelEntity, []byte, error) {
+	params, err := s.ShopeeService.GetShopeeAdapterParamsByShopID(ctx, shopID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	targets := make([]labelTarget, 0, len(req.Packages))
+	seen := map[string]bool{}
+	for _, p := range req.Packages {
+		target, err := s.resolveLabelTarget(ctx, shopID, p.OrderSN, p.PackageNumber)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", p.OrderSN, err)
+		}
+		key := target.OrderSN + "/" + target.PackageNumber
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		targets = append(targets, *target)
+	}
+
+	labels, err := s.ensureShippingLabels(ctx, params, targets, documentTypeOrDefault(req.DocumentType), false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	res := make([]ShopeeLabelEntity, len(labels))
+	ready := true
+	for i, l := range labels {
+		res[i] = *ShopeeLabelModelToEntity(l)
+		if l.Status != LABEL_READY {
+			ready = false
+		}
+	}
+	if !ready {
+		return res, nil, ErrLabelNotReady
+	}
+
+	// request order = picking order
+	files := make([][]byte, 0, len(labels))
+	for _, l := range labels {
+		data, err := s.BlobStore.Get(ctx, l.BlobKey)
+		if err != nil {
+			return res, nil, fmt.Errorf("%s: %w", l.OrderSN, err)
+		}
+		files = append(files, data)
+	}
+	merged, err := pkg.MergePDF(files)
+	if err != nil {
+		s.Logger.Error("usecase.GetShippingLabelBatch : MergePDF", zap.String("shop_id", shopID), zap.Error(err))
+		return res, nil, err
+	}
+	return res, merged, nil
+}
+
+// ensureShippingLabels : READY labels are reused unless force, the rest are (re)created then polled
+func (s *shopeeLabelService) ensureShippingLabels(ctx context.Context, params *adapter.IReqShopeeAdapter, targets []labelTarget, docType dto.IEnumShippingDocumentType, force bool) ([]*ShopeeLabelModel, error) {
+	labels := make([]*ShopeeLabelModel, len(targets))
+	var toCreate []int
+	var pending []*ShopeeLabelModel
+
+	for i, t := range targets {
+		label, err := s.ShopeeLabelRepository.GetShopeeLabel(ctx, t.OrderSN, t.PackageNumber, docType)
+		if err != nil {
+			label = &ShopeeLabelModel{
+				ShopID:        params.ShopID,
+				OrderSN:       t.OrderSN,
+				PackageNumber: t.PackageNumber,
+				DocumentType:  docType,
+			}
+		}
+		labels[i] = label
+
+		switch {
+		case label.Status == LABEL_READY && !force:
+			if ok, _ := s.BlobStore.Exists(ctx, label.BlobKey); ok {
+				continue
+			}
+			toCreate = append(toCreate, i)
+		case label.Status == LABEL_PROCESSING && !force:
+			pending = append(pending, label)
+		default:
+			toCreate = append(toCreate, i)
+		}
+	}
+
+	for start := 0; start < len(toCreate); start += adapter.ShopeeShippingDocumentMaxOrder {
+		chunk := toCreate[start:min(start+adapter.ShopeeShippingDocumentMaxOrder, len(toCreate))]
+
+		body := &dto.IBCreateShippingDocument{}
+		for _, i := range chunk {
+			body.OrderList = append(body.OrderList, dto.IBShippingDocumentOrder{
+				OrderSN:              targets[i].OrderSN,
+				PackageNumber:        targets[i].PackageNumber,
+				TrackingNumber:       targets[i].TrackingNumber,
+				ShippingDocumentType: docType,
+			})
+		}
+		results, err := s.ShopeeAdapter.CreateShippingDocument(ctx, params, body)
+		if err != nil {
+			s.Logger.Error("usecase.ensureShippingLabels : CreateShippingDocument", zap.String("shop_id", params.ShopID), zap.Error(err))
+			return nil, err
+		}
+
+		failed := map[string]string{}
+		for _, r := range results {
+			if r.FailError != "" {
+				failed[r.OrderSN+"/"+r.PackageNumber] = r.FailError + ": " + r.FailMessage
+			}
+		}
+		for _, i := range chunk {
+			label := labels[i]
+			label.Status = LABEL_PROCESSING
+			label.Error = ""
+			label.Polls = 0
+			if reason, ok := failed[label.OrderSN+"/"+label.PackageNumber]; ok {
+				label.Status = LABEL_FAILED
+				label.Error = reason
+			} else {
+				pending = append(pending, label)
+			}
+			if _, err := s.ShopeeLabelRepository.SaveShopeeLabel(ctx, label); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := s.pollShippingLabels(ctx, params, pending, s.Config.Shopee.ShopeeLabelPollAttempts); err != nil {
+		return nil, err
+	}
+	return labels, nil
+}
+
+// pollShippingLabels : get_shipping_document_result until nothing is PROCESSING or attempts run out
+func (s *shopeeLabelService) pollShippingLabels(ctx context.Context, params *adapter.IReqShopeeAdapter, pending []*ShopeeLabelModel, attempts int) error {
+	interval := time.Duration(s.Config.Shopee.ShopeeLabelPollInterval) * time.Second
+
+	for attempt := 0; attempt < max(attempts, 1) && len(pending) > 0; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(interval):
+			}
+		}
+
+		var still []*ShopeeLabelModel
+		for start := 0; start < len(pending); start += adapter.ShopeeShippingDocumentMaxOrder {
+			chunk := pending[start:min(start+adapter.ShopeeShippingDocumentMaxOrder, len(pending))]
+
+			body := &dto.IBGetShippingDocumentResult{}
+			for _, l := range chunk {
+				body.OrderList = append(body.OrderList, dto.IBShippingDocumentOrder{
+					OrderSN:              l.OrderSN,
+					PackageNumber:        l.PackageNumber,
+					ShippingDocumentType: l.DocumentType,
+				})
+			}
+			results, err := s.ShopeeAdapter.GetShippingDocumentResult(ctx, params, body)
+			if err != nil {
+				return err
+			}
+
+			byKey := map[string]dto.IResShippingDocumentResult{}
+			for _, r := range results {
+				byKey[r.OrderSN+"/"+r.PackageNumber] = r
+			}
+			for _, l := range chunk {
+				l.Polls++
+				r, ok := byKey[l.OrderSN+"/"+l.PackageNumber]
+				switch {
+				case ok && r.FailError != "":
+					l.Status = LABEL_FAILED
+					l.Error = r.FailError + ": " + r.FailMessage
+				case ok && r.Status == dto.DOCUMENT_READY:
+					if err := s.downloadShippingLabel(ctx, params, l); err != nil {
+						l.Status = LABEL_FAILED
+						l.Error = err.Error()
+					}
+				case ok && r.Status == dto.DOCUMENT_FAILED:
+					l.Status = LABEL_FAILED
+					l.Error = "shopee failed to generate the document"
+				default:
+					still = append(still, l)
+				}
+				if _, err := s.ShopeeLabelRepository.SaveShopeeLabel(ctx, l); err != nil {
+					return err
+				}
+			}
+		}
+		pending = still
+	}
+	return nil
+}
+
+func (s *shopeeLabelService) downloadShippingLabel(ctx context.Context, params *adapter.IReqShopeeAdapter, label *ShopeeLabelModel) error {
+	data, err := s.ShopeeAdapter.DownloadShippingDocument(ctx, params, &dto.IBDownloadShippingDocument{
+		ShippingDocumentType: label.DocumentType,
+		OrderList:            []dto.IBShippingDocumentOrder{{OrderSN: label.OrderSN, PackageNumber: label.PackageNumber}},
+	})
+	if err != nil {
+		return err
+	}
+
+	key := ShopeeLabelBlobKey(label.OrderSN, label.PackageNumber, label.DocumentType)
+	if err := s.BlobStore.Put(ctx, key, data, "application/pdf"); err != nil {
+		s.Logger.Error("usecase.downloadShippingLabel : BlobStore.Put", zap.String("key", key), zap.Error(err))
+		return err
+	}
+	label.Status = LABEL_READY
+	label.BlobKey = key
+	label.Error = ""
+	label.ReadyAt = time.Now()
+	return nil
+}
+
+// resolveLabelTarget : package + tracking number from the stored order (synced first when missing)
+func (s *shopeeLabelService) resolveLabelTarget(ctx context.Context, shopID string, orderSN string, packageNumber string) (*labelTarget, error) {
+	order, err := s.ShopeeOrderRepository.GetShopeeOrderByOrderSN(ctx, orderSN)
+	if err != nil {
+		if _, syncErr := s.ShopeeService.SyncShopeeOrderByOrderSN(ctx, shopID, []string{orderSN}); syncErr != nil {
+			return nil, syncErr
+		}
+		if order, err = s.ShopeeOrderRepository.GetShopeeOrderByOrderSN(ctx, orderSN); err != nil {
+			return nil, err
+		}
+	}
+	if order.ShopID != "" && order.ShopID != shopID {
+		return nil, shopee.ErrShopeeOrderNotFound
+	}
+
+	pkgNumber, err := logistics.ResolvePackageNumber(order, packageNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	tracking := order.TrackingNumbers[pkgNumber]
+	if tracking == "" {
+		tracking = order.TrackingNumbers[orderSN]
+	}
+	return &labelTarget{OrderSN: orderSN, PackageNumber: pkgNumber, TrackingNumber: tracking}, nil
+}
@@ -0,0 +1,128 @@
+package logistics
+
+import (
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	"ecommerce/internal/adapter/dto"
+	"ecommerce/internal/delivery/http/response"
+)
+
+type IReqShopeeShipOrder struct {
+	PackageNumber string                   `json:"package_number"`
+	Pickup        *dto.IBShipPickup        `json:"pickup"`
+	Dropoff       *dto.IBShipDropoff       `json:"dropoff"`
+	NonIntegrated *dto.IBShipNonIntegrated `json:"non_integrated"`
+}
+
+type IReqShopeeBatchShipOrder struct {
+	OrderList     []dto.IBBatchShipOrderItem `json:"order_list" validate:"required,min=1,max=50"`
+	Pickup        *dto.IBShipPickup          `json:"pickup"`
+	Dropoff       *dto.IBShipDropoff         `json:"dropoff"`
+	NonIntegrated *dto.IBShipNonIntegrated   `json:"non_integrated"`
+}
+
+type IShopeeLogisticsHandler interface {
+	GetShippingParameter(c *fiber.Ctx) error
+	PostShipOrder(c *fiber.Ctx) error
+	PostBatchShipOrder(c *fiber.Ctx) error
+	GetTrackingNumber(c *fiber.Ctx) error
+	GetTrackingInfo(c *fiber.Ctx) error
+}
+
+type shopeeLogisticsHandler struct {
+	Logger   *zap.Logger
+	Validate *validator.Validate
+	Service  IShopeeLogisticsService
+}
+
+func NewShopeeLogisticsHandler(log *zap.Logger, valid *validator.Validate, srv IShopeeLogisticsService) IShopeeLogisticsHandler {
+	return &shopeeLogisticsHandler{
+		Logger:   log,
+		Validate: valid,
+		Service:  srv,
+	}
+}
+
+func (d *shopeeLogisticsHandler) GetShippingParameter(c *fiber.Ctx) error {
+	shopID := c.Params("shopeeShopID")
+	orderSN := c.Params("orderSN")
+	if shopID == "" || orderSN == "" {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.GetShippingParameter", "shopeeShopID and orderSN are required")
+	}
+
+	res, err := d.Service.GetShippingParameter(c.Context(), shopID, orderSN, c.Query("package_number"))
+	if err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.GetShippingParameter", err)
+	}
+	return response.SuccessResponse(c, "handler.GetShippingParameter", res)
+}
+
+func (d *shopeeLogisticsHandler) PostShipOrder(c *fiber.Ctx) error {
+	shopID := c.Params("shopeeShopID")
+	orderSN := c.Params("orderSN")
+	if shopID == "" || orderSN == "" {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostShipOrder", "shopeeShopID and orderSN are required")
+	}
+
+	var reqBody IReqShopeeShipOrder
+	if err := c.BodyParser(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostShipOrder", "invalid body")
+	}
+
+	res, err := d.Service.ShipOrder(c.Context(), shopID, orderSN, &reqBody)
+	if err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostShipOrder", err)
+	}
+	return response.SuccessResponse(c, "handler.PostShipOrder", res)
+}
+
+func (d *shopeeLogisticsHandler) PostBatchShipOrder(c *fiber.Ctx) error {
+	shopID := c.Params("shopeeShopID")
+	if shopID == "" {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostBatchShipOrder", "shopeeShopID is required")
+	}
+
+	var reqBody IReqShopeeBatchShipOrder
+	if err := c.BodyParser(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostBatchShipOrder", "invalid body")
+	}
+	if err := d.Validate.Struct(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostBatchShipOrder", "order_list must have 1 to 50 orders")
+	}
+
+	res, err := d.Service.BatchShipOrder(c.Context(), shopID, &reqBody)
+	if err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostBatchShipOrder", err)
+	}
+	return response.SuccessResponse(c, "handler.PostBatchShipOrder", res)
+}
+
+func (d *shopeeLogisticsHandler) GetTrackingNumber(c *fiber.Ctx) error {
+	shopID := c.Params("shopeeShopID")
+	orderSN := c.Params("orderSN")
+	if shopID == "" || orderSN == "" {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.GetTrackingNumber", "shopeeShopID and orderSN are required")
+	}
+
+	res, err := d.Service.GetTrackingNumber(c.Context(), shopID, orderSN, c.Query("package_number"))
+	if err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.GetTrackingNumber", err)
+	}
+	return response.SuccessResponse(c, "handler.GetTrackingNumber", res)
+}
+
+func (d *shopeeLogisticsHandler) GetTrackingInfo(c *fiber.Ctx) error {
+	shopID := c.Params("shopeeShopID")
+	orderSN := c.Params("orderSN")
+	if shopID == "" || orderSN == "" {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.GetTrackingInfo", "shopeeShopID and orderSN are required")
+	}
+
+	res, err := d.Service.GetTrackingInfo(c.Context(), shopID, orderSN, c.Query("package_number"))
+	if err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.GetTrackingInfo", err)
+	}
+	return response.SuccessResponse(c, "handler.GetTrackingInfo", res)
+}
@@ -0,0 +1,259 @@
+package logistics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"ecommerce/internal/adapter"
+	"ecommerce/internal/adapter/dto"
+	"ecommerce/internal/application/shopee"
+	"ecommerce/internal/env"
+)
+
+// package logistics_status right after ship_order succeeds
+const LOGISTICS_REQUEST_CREATED = "LOGISTICS_REQUEST_CREATED"
+
+type IShopeeLogisticsService interface {
+	GetShippingParameter(ctx context.Context, shopID string, orderSN string, packageNumber string) (*dto.IResShippingParameterWrapper, error)
+	// arrange shipment of one package, then store logistics status + tracking number
+	ShipOrder(ctx context.Context, shopID string, orderSN string, req *IReqShopeeShipOrder) (*ShopeeShipResultEntity, error)
+	// per order result : failed entries carry FailError, not an error
+	BatchShipOrder(ctx context.Context, shopID string, req *IReqShopeeBatchShipOrder) ([]dto.IResBatchShipOrderResult, error)
+	GetTrackingNumber(ctx context.Context, shopID string, orderSN string, packageNumber string) (*dto.IResTrackingNumberWrapper, error)
+	GetTrackingInfo(ctx context.Context, shopID string, orderSN string, packageNumber string) (*dto.IResTrackingInfoWrapper, error)
+}
+
+type ShopeeShipResultEntity struct {
+	OrderSN        string                    `json:"order_sn"`
+	PackageNumber  string                    `json:"package_number"`
+	TrackingNumber string                    `json:"tracking_number"`
+	Order          *shopee.ShopeeOrderEntity `json:"order"`
+}
+
+type shopeeLogisticsService struct {
+	Config *env.Config
+	Logger *zap.Logger
+
+	ShopeeAdapter         adapter.IShopeeService
+	ShopeeService         shopee.IShopeeService
+	ShopeeOrderRepository shopee.ShopeeOrderRepository
+}
+
+func NewShopeeLogisticsService(cfg *env.Config, logger *zap.Logger,
+	shopeeAdapter adapter.IShopeeService,
+	shopeeService shopee.IShopeeService,
+	shopeeOrder shopee.ShopeeOrderRepository,
+) IShopeeLogisticsService {
+	return &shopeeLogisticsService{
+		Config:                cfg,
+		Logger:                logger,
+		ShopeeAdapter:         shopeeAdapter,
+		ShopeeService:         shopeeService,
+		ShopeeOrderRepository: shopeeOrder,
+	}
+}
+
+func (s *shopeeLogisticsService) GetShippingParameter(ctx context.Context, shopID string, orderSN string, packageNumber string) (*dto.IResShippingParameterWrapper, error) {
+	params, err := s.ShopeeService.GetShopeeAdapterParamsByShopID(ctx, shopID)
+	if err != nil {
+		return nil, err
+	}
+	return s.ShopeeAdapter.GetShippingParameter(ctx, params, orderSN, packageNumber)
+}
+
+func (s *shopeeLogisticsService) ShipOrder(ctx context.Context, shopID string, orderSN string, req *IReqShopeeShipOrder) (*ShopeeShipResultEntity, error) {
+	if err := validateShipMethod(req.Pickup, req.Dropoff, req.NonIntegrated); err != nil {
+		return nil, err
+	}
+
+	params, err := s.ShopeeService.GetShopeeAdapterParamsByShopID(ctx, shopID)
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := s.getShopeeOrder(ctx, shopID, orderSN)
+	if err != nil {
+		return nil, err
+	}
+	switch order.OrderStatus {
+	case shopee.READYTOSHIP, shopee.PROCESSED, shopee.RETRYSHIP:
+	default:
+		return nil, fmt.Errorf("order %s can not be shipped in status %s", orderSN, order.OrderStatus)
+	}
+
+	packageNumber, err := ResolvePackageNumber(order, req.PackageNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.ShopeeAdapter.ShipOrder(ctx, params, &dto.IBShipOrder{
+		OrderSN:       orderSN,
+		PackageNumber: packageNumber,
+		Pickup:        req.Pickup,
+		Dropoff:       req.Dropoff,
+		NonIntegrated: req.NonIntegrated,
+	}); err != nil {
+		s.Logger.Error("usecase.ShipOrder : ShipOrder", zap.String("order_sn", orderSN), zap.String("package_number", packageNumber), zap.Error(err))
+		return nil, err
+	}
+
+	res := &ShopeeShipResultEntity{OrderSN: orderSN, PackageNumber: packageNumber}
+	res.Order, err = s.ShopeeOrderRepository.UpdateShopeeOrderPackageLogisticsStatus(ctx, orderSN, packageNumber, LOGISTICS_REQUEST_CREATED)
+	if err != nil {
+		// shipment is arranged on Shopee : report success, the next order sync fixes the stored status
+		s.Logger.Error("usecase.ShipOrder : UpdateShopeeOrderPackageLogisticsStatus", zap.String("order_sn", orderSN), zap.Error(err))
+	}
+
+	// tracking number may not be ready yet : push code 4 fills it later
+	tracking, err := s.ShopeeAdapter.GetTrackingNumber(ctx, params, orderSN, packageNumber)
+	if err != nil {
+		s.Logger.Info("usecase.ShipOrder : GetTrackingNumber not ready", zap.String("order_sn", orderSN), zap.Error(err))
+		return res, nil
+	}
+	if tracking.TrackingNumber != "" {
+		res.TrackingNumber = tracking.TrackingNumber
+		if order, err := s.ShopeeOrderRepository.UpdateShopeeOrderTrackingNumber(ctx, orderSN, packageNumber, tracking.TrackingNumber); err == nil {
+			res.Order = order
+		}
+	}
+	return res, nil
+}
+
+func (s *shopeeLogisticsService) BatchShipOrder(ctx context.Context, shopID string, req *IReqShopeeBatchShipOrder) ([]dto.IResBatchShipOrderResult, error) {
+	if err := validateShipMethod(req.Pickup, req.Dropoff, req.NonIntegrated); err != nil {
+		return nil, err
+	}
+	if len(req.OrderList) > adapter.ShopeeBatchShipMaxOrder {
+		return nil, fmt.Errorf("max %d orders per batch", adapter.ShopeeBatchShipMaxOrder)
+	}
+
+	params, err := s.ShopeeService.GetShopeeAdapterParamsByShopID(ctx, shopID)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.ShopeeAdapter.BatchShipOrder(ctx, params, &dto.IBBatchShipOrder{
+		OrderList:     req.OrderList,
+		Pickup:        req.Pickup,
+		Dropoff:       req.Dropoff,
+		NonIntegrated: req.NonIntegrated,
+	})
+	if err != nil {
+		s.Logger.Error("usecase.BatchShipOrder : BatchShipOrder", zap.String("shop_id", shopID), zap.Error(err))
+		return nil, err
+	}
+
+	for _, r := range res.ResultList {
+		if r.FailError != "" {
+			continue
+		}
+		if _, err := s.ShopeeOrderRepository.UpdateShopeeOrderPackageLogisticsStatus(ctx, r.OrderSN, r.PackageNumber, LOGISTICS_REQUEST_CREATED); err != nil {
+			s.Logger.Error("usecase.BatchShipOrder : UpdateShopeeOrderPackageLogisticsStatus", zap.String("order_sn", r.OrderSN), zap.Error(err))
+		}
+	}
+	return res.ResultList, nil
+}
+
+func (s *shopeeLogisticsService) GetTrackingNumber(ctx context.Context, shopID string, orderSN string, packageNumber string) (*dto.IResTrackingNumberWrapper, error) {
+	params, err := s.ShopeeService.GetShopeeAdapterParamsByShopID(ctx, shopID)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.ShopeeAdapter.GetTrackingNumber(ctx, params, orderSN, packageNumber)
+	if err != nil {
+		return nil, err
+	}
+	if res.TrackingNumber != "" {
+		if _, err := s.ShopeeOrderRepository.UpdateShopeeOrderTrackingNumber(ctx, orderSN, packageNumber, res.TrackingNumber); err != nil {
+			s.Logger.Error("usecase.GetTrackingNumber : UpdateShopeeOrderTrackingNumber", zap.String("order_sn", orderSN), zap.Error(err))
+		}
+	}
+	return res, nil
+}
+
+func (s *shopeeLogisticsService) GetTrackingInfo(ctx context.Context, shopID string, orderSN string, packageNumber string) (*dto.IResTrackingInfoWrapper, error) {
+	params, err := s.ShopeeService.GetShopeeAdapterParamsByShopID(ctx, shopID)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.ShopeeAdapter.GetTrackingInfo(ctx, params, orderSN, packageNumber)
+	if err != nil {
+		return nil, err
+	}
+	if res.LogisticsStatus != "" {
+		pkg := packageNumber
+		if pkg == "" {
+			pkg = res.PackageNumber
+		}
+		if _, err := s.ShopeeOrderRepository.UpdateShopeeOrderPackageLogisticsStatus(ctx, orderSN, pkg, res.LogisticsStatus); err != nil {
+			s.Logger.Error("usecase.GetTrackingInfo : UpdateShopeeOrderPackageLogisticsStatus", zap.String("order_sn", orderSN), zap.Error(err))
+		}
+	}
+	return res, nil
+}
+
+// stored order, pulled from Shopee first when not synced yet
+func (s *shopeeLogisticsService) getShopeeOrder(ctx context.Context, shopID string, orderSN string) (*shopee.ShopeeOrderEntity, error) {
+	order, err := s.ShopeeOrderRepository.GetShopeeOrderByOrderSN(ctx, orderSN)
+	if err != nil {
+		if _, syncErr := s.ShopeeService.SyncShopeeOrderByOrderSN(ctx, shopID, []string{orderSN}); syncErr != nil {
+			return nil, syncErr
+		}
+		if order, err = s.ShopeeOrderRepository.GetShopeeOrderByOrderSN(ctx, orderSN); err != nil {
+			return nil, err
+		}
+	}
+	if order.ShopID != "" && order.ShopID != shopID {
+		return nil, errors.New("OrderSN not found")
+	}
+	return order, nil
+}
+
+// ResolvePackageNumber : the only package of the order when packageNumber is empty
+func ResolvePackageNumber(order *shopee.ShopeeOrderEntity, packageNumber string) (string, error) {
+	if packageNumber != "" {
+		for _, p := range order.PackageList {
+			if p.PackageNumber == packageNumber {
+				return packageNumber, nil
+			}
+		}
+		return "", fmt.Errorf("package %s not found in order %s", packageNumber, order.OrderSN)
+	}
+
+	switch len(order.PackageList) {
+	case 0:
+		return "", nil
+	case 1:
+		return order.PackageList[0].PackageNumber, nil
+	default:
+		return "", errors.New("package_number is required for split orders")
+	}
+}
+
+func validateShipMethod(pickup *dto.IBShipPickup, dropoff *dto.IBShipDropoff, nonIntegrated *dto.IBShipNonIntegrated) error {
+	n := 0
+	if pickup != nil {
+		n++
+	}
+	if dropoff != nil {
+		n++
+	}
+	if nonIntegrated != nil {
+		n++
+		if nonIntegrated.TrackingNumber == "" {
+			return errors.New("non_integrated.tracking_number is required")
+		}
+	}
+	if n != 1 {
+		return errors.New("exactly one of pickup, dropoff, non_integrated is required")
+	}
+	if pickup != nil && pickup.AddressID == 0 {
+		return errors.New("pickup.address_id is required")
+	}
+	return nil
+}
@@ -72,7 +72,7 @@ func (d *shopeePartnerHandle)GetShopeePartnerByID(c *fiber.Ctx) error {
 
   res,err := d.Service.GetShopeePartnerByID(c.Context(),partnerID)
   if err != nil {
-    return response.ErrorResponse(c,fiber.StatusBadRequest, "handler.GetShopeePartnerByID", err.Error())
+    return response.ErrorResponse(c,fiber.StatusBadRequest, "handler.GetShopeePartnerByID", err)
   }
   return response.SuccessResponse(c,"handler.GetShopeePartnerByID",res)
 }
@@ -81,7 +81,7 @@ func (d *shopeePartnerHandle)GetAllShopeePartner(c *fiber.Ctx) error {
 
   res,err := d.Service.GetAllShopeePartner(c.Context())
   if err != nil {
-    return response.ErrorResponse(c,fiber.StatusBadGateway, "handler.GetAllShopeePartner", err.Error())
+    return response.ErrorResponse(c,fiber.StatusBadGateway, "handler.GetAllShopeePartner", err)
   }
 
   return response.SuccessResponse(c,"handler.GetAllShopeePartner", res)
@@ -121,7 +121,7 @@ func(d *shopeePartnerHandle)DeleteShopeePartnerByID(c *fiber.Ctx) error {
 
   res, err  := d.Service.DeleteShopeePartnerByID(c.Context(), partnerID )
   if err != nil {
-    return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.DeleteShopeePartnerByID", err.Error())
+    return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.DeleteShopeePartnerByID", err)
   }  
 
   return response.SuccessResponse(c, "handler.DeleteShopeePartnerByID", res) 
@@ -2,6 +2,7 @@ package partner
 
 import (
 	"context"
+	"ecommerce/internal/pkg"
 	"errors"
 	"time"
 
@@ -17,6 +18,8 @@ type ShopeePartnerModel struct {
   PartnerID   string        `bson:"partner_id"`
   SecretKey   string        `bson:"secret_key"`
   Validate    bool          `bson:"validate"`
+  // owner tenant, missing for the platform tenant : see pkg.TenantFilter
+  TenantID    string        `bson:"tenant_id,omitempty"`
   CreatedAt   time.Time     `bson:"created_at"`
   CreatedBy   string        `bson:"created_by"`
   UpdatedAt   time.Time     `bson:"updated_at"`
@@ -61,12 +64,13 @@ func ShopeePartnerModelToEntity(model ShopeePartnerModel) *ShopeePartnerEntity {
   }
 }
 
+// API answer : the secret key is never sent back in clear
 func ShopeePartnerEntityToDTO(enti ShopeePartnerEntity) *ShopeePartnerDTO {
   return &ShopeePartnerDTO{
     ID: enti.ID,
     PartnerID: enti.PartnerID,
     PartnerName: enti.PartnerName,
-    SecretKey: enti.SecretKey,
+    SecretKey: pkg.RedactSecret(enti.SecretKey),
     Validate:  enti.Validate,
     CreatedAt: enti.CreatedAt,
     CreatedBy: enti.CreatedBy,
@@ -111,6 +115,8 @@ func ShopeePartnerDTOToEntity(dto ShopeePartnerDTO) *ShopeePartnerEntity {
 }
 
 
+var ErrShopeePartnerNotFound = errors.New("partnerID not found")
+
 type ShopeePartnerRepository interface {
   InitRepository() (error)
   CreateShopeePartner (ctx context.Context,partner *ShopeePartnerEntity) (*ShopeePartnerEntity,error)
@@ -118,15 +124,37 @@ type ShopeePartnerRepository interface {
   GetShopeePartnerByID(ctx context.Context,partner string)  (*ShopeePartnerEntity,error)
   UpdateShopeePartner (ctx context.Context,partner *ShopeePartnerEntity)   (*ShopeePartnerEntity,error)
   DeleteShopeePartner (ctx context.Context,partner string) (*ShopeePartnerEntity, error)
+
+  // cmd/rotatekeys : re-encrypt secret_key with the active master key
+  RotateShopeePartnerSecrets(ctx context.Context, dryRun bool) (*pkg.SecretRotationResult, error)
 }
 
+// secret_key is sealed by Cipher on write and opened on read : callers only see plaintext
 type shopeePartner struct {
   Logger *zap.Logger
   DB *mongo.Collection
+  Cipher pkg.ISecretCipher
+}
+
+func NewShopeePartnerRepository(db *mongo.Collection, log *zap.Logger, cipher pkg.ISecretCipher) ShopeePartnerRepository {
+  return &shopeePartner{ Logger: log, DB: db, Cipher: cipher, } }
+
+func (r *shopeePartner) sealModel(model *ShopeePartnerModel) error {
+  sealed, err := r.Cipher.Encrypt(model.SecretKey)
+  if err != nil { return errors.New("ShopeePartnerRepository: failed to encrypt secret_key") }
+  model.SecretKey = sealed
+  return nil
 }
 
-func NewShopeePartnerRepository(db *mongo.Collection, log *zap.Logger) ShopeePartnerRepository {
-  return &shopeePartner{ Logger: log, DB: db, } }
+func (r *shopeePartner) openModel(model *ShopeePartnerModel) error {
+  plain, err := r.Cipher.Decrypt(model.SecretKey)
+  if err != nil {
+    r.Logger.Error("ShopeePartnerRepository: failed to decrypt secret_key", zap.String("partner_id", model.PartnerID), zap.Error(err))
+    return errors.New("ShopeePartnerRepository: failed to decrypt secret_key")
+  }
+  model.SecretKey = plain
+  return nil
+}
 
 func (r *shopeePartner)InitRepository() error {
   indexs := []mongo.IndexModel{
@@ -144,6 +172,10 @@ func (r *shopeePartner)CreateShopeePartner (ctx context.Context,partner *ShopeeP
 
   // object := ShopeePartnerEntity{ PartnerID: partner.PartnerID, PartnerName: partner.PartnerName, SecretKey: partner.SecretKey}
   object := ShopeePartnerEntityToModel(*partner) 
+  tenantID, err := pkg.TenantStamp(ctx)
+  if err != nil { return nil, err }
+  object.TenantID = tenantID
+  if err := r.sealModel(object); err != nil { return nil, err }
 
   // partnerCreate := ShopeePartnerEntityToModel(object)
   res,err := r.DB.InsertOne(ctx, object)
@@ -157,6 +189,7 @@ func (r *shopeePartner)CreateShopeePartner (ctx context.Context,partner *ShopeeP
   if oid, ok := res.InsertedID.(bson.ObjectID) ; !ok {
   object.ID = oid     }
 
+  object.SecretKey = partner.SecretKey
   partnerParse := ShopeePartnerModelToEntity(*object)
   
   return partnerParse,nil
@@ -165,7 +198,7 @@ func (r *shopeePartner)CreateShopeePartner (ctx context.Context,partner *ShopeeP
 func (r *shopeePartner)GetAllShopeePartner (ctx context.Context) ([]ShopeePartnerEntity, error) {
   var res []ShopeePartnerModel
 
-  cursor ,err := r.DB.Find(ctx,bson.M{})
+  cursor ,err := r.DB.Find(ctx,pkg.TenantFilter(ctx, bson.M{}))
   if err != nil {return nil ,err}
   defer cursor.Close(ctx)
 
@@ -173,6 +206,7 @@ func (r *shopeePartner)GetAllShopeePartner (ctx context.Context) ([]ShopeePartne
 
   resEntity := make([]ShopeePartnerEntity, len(res))
   for i, u := range res {
+    if err := r.openModel(&u); err != nil { return nil, err }
     resEntity[i] = *ShopeePartnerModelToEntity(u)
   }
 
@@ -181,14 +215,15 @@ func (r *shopeePartner)GetAllShopeePartner (ctx context.Context) ([]ShopeePartne
 
 func (r *shopeePartner)GetShopeePartnerByID(ctx context.Context,partner string)  (*ShopeePartnerEntity,error){
   var model ShopeePartnerModel
-  filter := bson.M{"partner_id":partner }
+  filter := pkg.TenantFilter(ctx, bson.M{"partner_id":partner })
   err := r.DB.FindOne(ctx, filter).Decode(&model)
   if err != nil {
     if errors.Is(err, mongo.ErrNoDocuments) { 
-      return nil, errors.New("partnerID not found")  
+      return nil, ErrShopeePartnerNotFound
     }
     return nil, err
   }
+  if err := r.openModel(&model); err != nil { return nil, err }
   resParse := ShopeePartnerModelToEntity(model)
   return resParse, nil
 }
@@ -197,8 +232,10 @@ func (r *shopeePartner)UpdateShopeePartner (ctx context.Context,partner *ShopeeP
   var updated ShopeePartnerModel
   update := ShopeePartnerEntityToModel(*partner)
   update.UpdatedAt = time.Now()
+  if err := r.sealModel(update); err != nil { return nil, err }
 
-  filter := bson.M{"partner_id": update.PartnerID}
+  // tenant_id is left out of the $set (omitempty) : an update never moves a partner
+  filter := pkg.TenantFilter(ctx, bson.M{"partner_id": update.PartnerID})
   opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
 
   err := r.DB.FindOneAndUpdate(ctx, filter,bson.M{"$set":update},opts) .Decode(&updated) 
@@ -208,6 +245,7 @@ func (r *shopeePartner)UpdateShopeePartner (ctx context.Context,partner *ShopeeP
     }
     return nil,err
   }
+  if err := r.openModel(&updated); err != nil { return nil, err }
   
   updatedParse := ShopeePartnerModelToEntity(updated)
   return updatedParse,nil
@@ -217,18 +255,47 @@ func (r *shopeePartner)UpdateShopeePartner (ctx context.Context,partner *ShopeeP
 func (r *shopeePartner)DeleteShopeePartner (ctx context.Context,partner string) (*ShopeePartnerEntity, error) {
   var deleted ShopeePartnerModel
   
-  filter := bson.M{"partner_id" : partner}
+  filter := pkg.TenantFilter(ctx, bson.M{"partner_id" : partner})
   err := r.DB.FindOneAndDelete(ctx,filter).Decode(&deleted)
   if err != nil {
     if errors.Is(err, mongo.ErrNoDocuments) {
-      return nil, errors.New("ShopeePartner not found")
+      return nil, ErrShopeePartnerNotFound
     }
     return nil, err
   }
 
+  // gone anyway : never hand the sealed value out
+  if err := r.openModel(&deleted); err != nil { deleted.SecretKey = "" }
   deletedParse := ShopeePartnerModelToEntity(deleted)
   return deletedParse, nil
 }
 
+func (r *shopeePartner)RotateShopeePartnerSecrets(ctx context.Context, dryRun bool) (*pkg.SecretRotationResult, error) {
+  res := &pkg.SecretRotationResult{Collection: r.DB.Name()}
+
+  cursor, err := r.DB.Find(ctx, bson.M{})
+  if err != nil { return nil, err }
+  defer cursor.Close(ctx)
 
+  for cursor.Next(ctx) {
+    var model ShopeePartnerModel
+    if err := cursor.Decode(&model); err != nil { return res, err }
+    res.Scanned++
+    if !r.Cipher.NeedsRotation(model.SecretKey) { continue }
+
+    sealed, err := pkg.ReencryptSecret(r.Cipher, model.SecretKey)
+    if err != nil {
+      r.Logger.Error("ShopeePartnerRepository.RotateShopeePartnerSecrets", zap.String("partner_id", model.PartnerID), zap.Error(err))
+      res.Failed++
+      continue
+    }
+    if dryRun { res.Rotated++; continue }
 
+    // only if nobody rewrote it meanwhile
+    filter := bson.M{"_id": model.ID, "secret_key": model.SecretKey}
+    upd, err := r.DB.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"secret_key": sealed}})
+    if err != nil { return res, err }
+    if upd.ModifiedCount == 1 { res.Rotated++ } else { res.Skipped++ }
+  }
+  return res, cursor.Err()
+}
@@ -0,0 +1,135 @@
+package payment
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	"ecommerce/internal/delivery/http/response"
+)
+
+// dates are YYYY-MM-DD, to is inclusive
+const periodLayout = "2006-01-02"
+
+type IReqShopeePeriod struct {
+	From string `json:"from" query:"from" validate:"required,datetime=2006-01-02"`
+	To   string `json:"to" query:"to" validate:"required,datetime=2006-01-02"`
+}
+
+type IShopeePaymentHandler interface {
+	GetShopeeOrderEscrow(c *fiber.Ctx) error
+	PostShopeeEscrowSync(c *fiber.Ctx) error
+	GetShopeePayouts(c *fiber.Ctx) error
+	GetShopeeReconciliation(c *fiber.Ctx) error
+}
+
+type shopeePaymentHandler struct {
+	Logger   *zap.Logger
+	Validate *validator.Validate
+	Service  IShopeePaymentService
+}
+
+func NewShopeePaymentHandler(log *zap.Logger, valid *validator.Validate, srv IShopeePaymentService) IShopeePaymentHandler {
+	return &shopeePaymentHandler{
+		Logger:   log,
+		Validate: valid,
+		Service:  srv,
+	}
+}
+
+func (d *shopeePaymentHandler) GetShopeeOrderEscrow(c *fiber.Ctx) error {
+	shopID := c.Params("shopeeShopID")
+	orderSN := c.Params("orderSN")
+	if shopID == "" || orderSN == "" {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.GetShopeeOrderEscrow", "shopeeShopID and orderSN are required")
+	}
+
+	res, err := d.Service.SyncShopeeEscrowByOrderSN(c.Context(), shopID, orderSN)
+	if err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.GetShopeeOrderEscrow", err)
+	}
+	return response.SuccessResponse(c, "handler.GetShopeeOrderEscrow", res)
+}
+
+func (d *shopeePaymentHandler) PostShopeeEscrowSync(c *fiber.Ctx) error {
+	shopID := c.Params("shopeeShopID")
+	if shopID == "" {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostShopeeEscrowSync", "shopeeShopID is required")
+	}
+
+	var reqBody IReqShopeePeriod
+	if err := c.BodyParser(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostShopeeEscrowSync", "invalid body")
+	}
+	from, to, err := d.parsePeriod(&reqBody)
+	if err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostShopeeEscrowSync", err)
+	}
+
+	res, err := d.Service.SyncShopeeEscrowByShopID(c.Context(), shopID, from, to)
+	if err != nil {
+		d.Logger.Error("handler.PostShopeeEscrowSync : SyncShopeeEscrowByShopID", zap.String("shop_id", shopID), zap.Error(err))
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostShopeeEscrowSync", err)
+	}
+	return response.SuccessResponse(c, "handler.PostShopeeEscrowSync", res)
+}
+
+func (d *shopeePaymentHandler) GetShopeePayouts(c *fiber.Ctx) error {
+	shopID := c.Params("shopeeShopID")
+	if shopID == "" {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.GetShopeePayouts", "shopeeShopID is required")
+	}
+
+	var query IReqShopeePeriod
+	if err := c.QueryParser(&query); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.GetShopeePayouts", "invalid query")
+	}
+	from, to, err := d.parsePeriod(&query)
+	if err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.GetShopeePayouts", err)
+	}
+
+	res, err := d.Service.GetShopeePayoutDetail(c.Context(), shopID, from, to)
+	if err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.GetShopeePayouts", err)
+	}
+	return response.SuccessResponse(c, "handler.GetShopeePayouts", res)
+}
+
+func (d *shopeePaymentHandler) GetShopeeReconciliation(c *fiber.Ctx) error {
+	shopID := c.Params("shopeeShopID")
+	if shopID == "" {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.GetShopeeReconciliation", "shopeeShopID is required")
+	}
+
+	var query IReqShopeePeriod
+	if err := c.QueryParser(&query); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.GetShopeeReconciliation", "invalid query")
+	}
+	from, to, err := d.parsePeriod(&query)
+	if err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.GetShopeeReconciliation", err)
+	}
+
+	res, err := d.Service.GetShopeeReconciliationReport(c.Context(), shopID, from, to)
+	if err != nil {
+		return response.ErrorResponse(c, fiber.StatusInternalServerError, "handler.GetShopeeReconciliation", err)
+	}
+	return response.SuccessResponse(c, "handler.GetShopeeReconciliation", res)
+}
+
+// parsePeriod : [from 00:00, to+1 day 00:00) in UTC
+func (d *shopeePaymentHandler) parsePeriod(p *IReqShopeePeriod) (time.Time, time.Time, error) {
+	if err := d.Validate.Struct(p); err != nil {
+		return time.Time{}, time.Time{}, errors.New("from and to are required as YYYY-MM-DD")
+	}
+	from, _ := time.Parse(periodLayout, p.From)
+	to, _ := time.Parse(periodLayout, p.To)
+	if to.Before(from) {
+		return time.Time{}, time.Time{}, errors.New("to must not be before from")
+	}
+	return from, to.AddDate(0, 0, 1), nil
+}
@@ -0,0 +1,353 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"go.uber.org/zap"
+
+	"ecommerce/internal/adapter"
+	"ecommerce/internal/adapter/dto"
+	"ecommerce/internal/application/shopee"
+	"ecommerce/internal/env"
+)
+
+// reconciliation flags : one order may carry several
+const (
+	RECON_MISSING_ESCROW    = "MISSING_ESCROW"    // COMPLETED but no escrow detail stored
+	RECON_AMOUNT_MISMATCH   = "AMOUNT_MISMATCH"   // order total_amount != escrow buyer_total_amount
+	RECON_PAYOUT_MISMATCH   = "PAYOUT_MISMATCH"   // released payout != escrow_amount
+	RECON_FEES_EXCEED_TOTAL = "FEES_EXCEED_TOTAL" // fees larger than what the buyer paid
+)
+
+// amounts are rounded to 2 decimals by Shopee
+const reconTolerance = 0.01
+
+type IShopeePaymentService interface {
+	// get_escrow_detail for one order, stored on the order
+	SyncShopeeEscrowByOrderSN(ctx context.Context, shopID string, orderSN string) (*shopee.ShopeeOrderEscrowEntity, error)
+	// get_escrow_list over [from, to) by release time, then detail per order
+	SyncShopeeEscrowByShopID(ctx context.Context, shopID string, from time.Time, to time.Time) (*ShopeeEscrowSyncEntity, error)
+	GetShopeePayoutDetail(ctx context.Context, shopID string, from time.Time, to time.Time) ([]dto.IResPayout, error)
+	// orders by create_time in [from, to)
+	GetShopeeReconciliationReport(ctx context.Context, shopID string, from time.Time, to time.Time) (*ShopeeReconciliationReportEntity, error)
+}
+
+type ShopeeEscrowSyncEntity struct {
+	ShopID   string    `json:"shop_id"`
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+	Released int       `json:"released"`
+	Synced   int       `json:"synced"`
+	Failed   []string  `json:"failed"`
+}
+
+type ShopeeReconciliationTotalEntity struct {
+	TotalAmount        float64 `json:"total_amount"`
+	BuyerTotalAmount   float64 `json:"buyer_total_amount"`
+	EscrowAmount       float64 `json:"escrow_amount"`
+	PayoutAmount       float64 `json:"payout_amount"`
+	CommissionFee      float64 `json:"commission_fee"`
+	ServiceFee         float64 `json:"service_fee"`
+	TransactionFee     float64 `json:"transaction_fee"`
+	ShippingSubsidy    float64 `json:"shipping_subsidy"`
+	VoucherFromSeller  float64 `json:"voucher_from_seller"`
+	VoucherFromShopee  float64 `json:"voucher_from_shopee"`
+	SellerReturnRefund float64 `json:"seller_return_refund"`
+}
+
+type ShopeeReconciliationOrderEntity struct {
+	OrderSN     string                          `json:"order_sn"`
+	OrderStatus shopee.ShopeeOrderStatusEnum    `json:"order_status"`
+	CreateTime  time.Time                       `json:"create_time"`
+	Currency    string                          `json:"currency"`
+	TotalAmount float64                         `json:"total_amount"`
+	Escrow      *shopee.ShopeeOrderEscrowEntity `json:"escrow"`
+	Difference  float64                         `json:"difference"` // total_amount - escrow_amount
+	Flags       []string                        `json:"flags"`
+}
+
+type ShopeeReconciliationReportEntity struct {
+	ShopID        string                            `json:"shop_id"`
+	From          time.Time                         `json:"from"`
+	To            time.Time                         `json:"to"`
+	OrderCount    int                               `json:"order_count"`
+	EscrowCount   int                               `json:"escrow_count"`
+	ReleasedCount int                               `json:"released_count"`
+	FlaggedCount  int                               `json:"flagged_count"`
+	Total         ShopeeReconciliationTotalEntity   `json:"total"`
+	Orders        []ShopeeReconciliationOrderEntity `json:"orders"`
+}
+
+type shopeePaymentService struct {
+	Config *env.Config
+	Logger *zap.Logger
+
+	ShopeeAdapter         adapter.IShopeeService
+	ShopeeService         shopee.IShopeeService
+	ShopeeOrderRepository shopee.ShopeeOrderRepository
+}
+
+func NewShopeePaymentService(cfg *env.Config, logger *zap.Logger,
+	shopeeAdapter adapter.IShopeeService,
+	shopeeService shopee.IShopeeService,
+	shopeeOrder shopee.ShopeeOrderRepository,
+) IShopeePaymentService {
+	return &shopeePaymentService{
+		Config:                cfg,
+		Logger:                logger,
+		ShopeeAdapter:         shopeeAdapter,
+		ShopeeService:         shopeeService,
+		ShopeeOrderRepository: shopeeOrder,
+	}
+}
+
+func (s *shopeePaymentService) SyncShopeeEscrowByOrderSN(ctx context.Context, shopID string, orderSN string) (*shopee.ShopeeOrderEscrowEntity, error) {
+	params, err := s.ShopeeService.GetShopeeAdapterParamsByShopID(ctx, shopID)
+	if err != nil {
+		return nil, err
+	}
+	order, err := s.getShopeeOrder(ctx, shopID, orderSN)
+	if err != nil {
+		return nil, err
+	}
+	return s.syncEscrow(ctx, params, order, nil)
+}
+
+func (s *shopeePaymentService) SyncShopeeEscrowByShopID(ctx context.Context, shopID string, from time.Time, to time.Time) (*ShopeeEscrowSyncEntity, error) {
+	if !from.Before(to) {
+		return nil, errors.New("from must be before to")
+	}
+	params, err := s.ShopeeService.GetShopeeAdapterParamsByShopID(ctx, shopID)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &ShopeeEscrowSyncEntity{ShopID: shopID, From: from, To: to, Failed: []string{}}
+	for winFrom := from; winFrom.Before(to); winFrom = winFrom.Add(adapter.ShopeePaymentMaxRange) {
+		winTo := winFrom.Add(adapter.ShopeePaymentMaxRange)
+		if winTo.After(to) {
+			winTo = to
+		}
+
+		for page := int32(1); ; page++ {
+			list, err := s.ShopeeAdapter.GetEscrowList(ctx, params, &dto.IOptionShopeePageQuery{
+				TimeFrom: winFrom.Unix(),
+				TimeTo:   winTo.Unix(),
+				PageNo:   page,
+			})
+			if err != nil {
+				s.Logger.Error("usecase.SyncShopeeEscrowByShopID : GetEscrowList", zap.String("shop_id", shopID), zap.Error(err))
+				return res, err
+			}
+
+			for i := range list.EscrowList {
+				released := list.EscrowList[i]
+				res.Released++
+
+				order, err := s.getShopeeOrder(ctx, shopID, released.OrderSN)
+				if err == nil {
+					_, err = s.syncEscrow(ctx, params, order, &released)
+				}
+				if err != nil {
+					s.Logger.Error("usecase.SyncShopeeEscrowByShopID : syncEscrow", zap.String("order_sn", released.OrderSN), zap.Error(err))
+					res.Failed = append(res.Failed, released.OrderSN)
+					continue
+				}
+				res.Synced++
+			}
+
+			if !list.More {
+				break
+			}
+		}
+	}
+	return res, nil
+}
+
+func (s *shopeePaymentService) GetShopeePayoutDetail(ctx context.Context, shopID string, from time.Time, to time.Time) ([]dto.IResPayout, error) {
+	if !from.Before(to) {
+		return nil, errors.New("from must be before to")
+	}
+	if to.Sub(from) > adapter.ShopeePaymentMaxRange {
+		return nil, errors.New("payout range must not exceed 14 days")
+	}
+	params, err := s.ShopeeService.GetShopeeAdapterParamsByShopID(ctx, shopID)
+	if err != nil {
+		return nil, err
+	}
+
+	res := []dto.IResPayout{}
+	for page := int32(1); ; page++ {
+		list, err := s.ShopeeAdapter.GetPayoutDetail(ctx, params, &dto.IOptionShopeePageQuery{
+			TimeFrom: from.Unix(),
+			TimeTo:   to.Unix(),
+			PageNo:   page,
+		})
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, list.PayoutList...)
+		if !list.More {
+			break
+		}
+	}
+	return res, nil
+}
+
+func (s *shopeePaymentService) GetShopeeReconciliationReport(ctx context.Context, shopID string, from time.Time, to time.Time) (*ShopeeReconciliationReportEntity, error) {
+	if !from.Before(to) {
+		return nil, errors.New("from must be before to")
+	}
+	orders, err := s.ShopeeOrderRepository.GetShopeeOrdersByShopIDAndCreateTime(ctx, shopID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ShopeeReconciliationReportEntity{
+		ShopID:     shopID,
+		From:       from,
+		To:         to,
+		OrderCount: len(orders),
+		Orders:     make([]ShopeeReconciliationOrderEntity, 0, len(orders)),
+	}
+
+	for i := range orders {
+		o := &orders[i]
+		row := ShopeeReconciliationOrderEntity{
+			OrderSN:     o.OrderSN,
+			OrderStatus: o.OrderStatus,
+			CreateTime:  o.CreateTime,
+			Currency:    o.Currency,
+			TotalAmount: o.TotalAmount,
+			Escrow:      o.Escrow,
+			Flags:       reconcileOrder(o),
+		}
+		report.Total.TotalAmount += o.TotalAmount
+
+		if e := o.Escrow; e != nil {
+			report.EscrowCount++
+			row.Difference = round2(o.TotalAmount - e.EscrowAmount)
+
+			t := &report.Total
+			t.BuyerTotalAmount += e.BuyerTotalAmount
+			t.EscrowAmount += e.EscrowAmount
+			t.PayoutAmount += e.PayoutAmount
+			t.CommissionFee += e.CommissionFee
+			t.ServiceFee += e.ServiceFee
+			t.TransactionFee += e.TransactionFee
+			t.ShippingSubsidy += e.ShippingSubsidy
+			t.VoucherFromSeller += e.VoucherFromSeller
+			t.VoucherFromShopee += e.VoucherFromShopee
+			t.SellerReturnRefund += e.SellerReturnRefund
+			if !e.ReleasedAt.IsZero() {
+				report.ReleasedCount++
+			}
+		}
+		if len(row.Flags) > 0 {
+			report.FlaggedCount++
+		}
+		report.Orders = append(report.Orders, row)
+	}
+
+	t := &report.Total
+	for _, v := range []*float64{&t.TotalAmount, &t.BuyerTotalAmount, &t.EscrowAmount, &t.PayoutAmount,
+		&t.CommissionFee, &t.ServiceFee, &t.TransactionFee, &t.ShippingSubsidy,
+		&t.VoucherFromSeller, &t.VoucherFromShopee, &t.SellerReturnRefund} {
+		*v = round2(*v)
+	}
+	return report, nil
+}
+
+// reconcileOrder : flags for one order, empty when it matches
+func reconcileOrder(o *shopee.ShopeeOrderEntity) []string {
+	flags := []string{}
+	e := o.Escrow
+	if e == nil {
+		if o.OrderStatus == shopee.COMPLETED {
+			flags = append(flags, RECON_MISSING_ESCROW)
+		}
+		return flags
+	}
+
+	if math.Abs(o.TotalAmount-e.BuyerTotalAmount) > reconTolerance {
+		flags = append(flags, RECON_AMOUNT_MISMATCH)
+	}
+	if !e.ReleasedAt.IsZero() && math.Abs(e.PayoutAmount-e.EscrowAmount) > reconTolerance {
+		flags = append(flags, RECON_PAYOUT_MISMATCH)
+	}
+	fees := e.CommissionFee + e.ServiceFee + e.TransactionFee
+	if e.BuyerTotalAmount > 0 && fees-e.BuyerTotalAmount > reconTolerance {
+		flags = append(flags, RECON_FEES_EXCEED_TOTAL)
+	}
+	return flags
+}
+
+// syncEscrow : released is nil when the order is not released yet (or unknown)
+func (s *shopeePaymentService) syncEscrow(ctx context.Context, params *adapter.IReqShopeeAdapter, order *shopee.ShopeeOrderEntity, released *dto.IResEscrowListItem) (*shopee.ShopeeOrderEscrowEntity, error) {
+	detail, err := s.ShopeeAdapter.GetEscrowDetail(ctx, params, order.OrderSN)
+	if err != nil {
+		return nil, err
+	}
+
+	escrow := escrowDetailToEntity(&detail.OrderIncome)
+	switch {
+	case released != nil:
+		escrow.PayoutAmount = released.PayoutAmount
+		escrow.ReleasedAt = time.Unix(released.EscrowReleaseTime, 0)
+	case order.Escrow != nil:
+		// keep release info from a previous get_escrow_list sync
+		escrow.PayoutAmount = order.Escrow.PayoutAmount
+		escrow.ReleasedAt = order.Escrow.ReleasedAt
+	}
+
+	if _, err := s.ShopeeOrderRepository.UpdateShopeeOrderEscrow(ctx, order.OrderSN, escrow); err != nil {
+		return nil, err
+	}
+	return escrow, nil
+}
+
+func (s *shopeePaymentService) getShopeeOrder(ctx context.Context, shopID string, orderSN string) (*shopee.ShopeeOrderEntity, error) {
+	order, err := s.ShopeeOrderRepository.GetShopeeOrderByOrderSN(ctx, orderSN)
+	if err != nil {
+		if _, syncErr := s.ShopeeService.SyncShopeeOrderByOrderSN(ctx, shopID, []string{orderSN}); syncErr != nil {
+			return nil, syncErr
+		}
+		if order, err = s.ShopeeOrderRepository.GetShopeeOrderByOrderSN(ctx, orderSN); err != nil {
+			return nil, err
+		}
+	}
+	if order.ShopID != "" && order.ShopID != shopID {
+		return nil, errors.New("OrderSN not found")
+	}
+	return order, nil
+}
+
+func escrowDetailToEntity(in *dto.IResEscrowOrderIncome) *shopee.ShopeeOrderEscrowEntity {
+	return &shopee.ShopeeOrderEscrowEntity{
+		EscrowAmount:         in.EscrowAmount,
+		BuyerTotalAmount:     in.BuyerTotalAmount,
+		OriginalPrice:        in.OriginalPrice,
+		SellerDiscount:       in.SellerDiscount,
+		ShopeeDiscount:       in.ShopeeDiscount,
+		VoucherFromSeller:    in.VoucherFromSeller,
+		VoucherFromShopee:    in.VoucherFromShopee,
+		Coins:                in.Coins,
+		CommissionFee:        in.CommissionFee,
+		ServiceFee:           in.ServiceFee,
+		TransactionFee:       in.SellerTransactionFee + in.CreditCardTransactionFee,
+		BuyerPaidShippingFee: in.BuyerPaidShippingFee,
+		ActualShippingFee:    in.ActualShippingFee,
+		ShippingSubsidy:      in.ShopeeShippingRebate,
+		ReverseShippingFee:   in.ReverseShippingFee,
+		EscrowTax:            in.EscrowTax,
+		SellerReturnRefund:   in.SellerReturnRefund,
+		SyncedAt:             time.Now(),
+	}
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
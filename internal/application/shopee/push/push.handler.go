@@ -0,0 +1,105 @@
+package push
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	"ecommerce/internal/delivery/http/response"
+	"ecommerce/internal/pkg"
+)
+
+// an event left unprocessed by the timeout stays FAILED / RECEIVED and can be replayed
+const pushProcessTimeout = 2 * time.Minute
+
+type IShopeePushHandler interface {
+	// public : called by Shopee, authenticated by signature
+	PostShopeePush(c *fiber.Ctx) error
+
+	GetShopeePushEvents(c *fiber.Ctx) error
+	PostShopeePushEventReplay(c *fiber.Ctx) error
+}
+
+type shopeePushHandler struct {
+	Logger   *zap.Logger
+	Validate *validator.Validate
+	Service  IShopeePushService
+}
+
+func NewShopeePushHandler(log *zap.Logger, valid *validator.Validate, srv IShopeePushService) IShopeePushHandler {
+	return &shopeePushHandler{
+		Logger:   log,
+		Validate: valid,
+		Service:  srv,
+	}
+}
+
+func (d *shopeePushHandler) PostShopeePush(c *fiber.Ctx) error {
+	partnerID := c.Params("partnerID")
+	if partnerID == "" {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostShopeePush", "partnerID is required")
+	}
+
+	// copy : fasthttp reuses the request buffer after the handler returns
+	body := append([]byte(nil), c.Body()...)
+	url := d.Service.GetShopeePushCallbackURL(partnerID, c.BaseURL()+c.OriginalURL())
+
+	event, err := d.Service.ReceiveShopeePush(c.Context(), partnerID, url, body, c.Get(fiber.HeaderAuthorization))
+	if errors.Is(err, ErrPushDuplicate) {
+		// a retry : acked, the first copy is processed (or replayable) on its own
+		return response.SuccessResponse(c, "handler.PostShopeePush", map[string]string{"id": event.ID})
+	}
+	if err != nil {
+		if errors.Is(err, ErrInvalidPushSignature) {
+			return response.ErrorResponse(c, fiber.StatusUnauthorized, "handler.PostShopeePush", err)
+		}
+		d.Logger.Error("handler.PostShopeePush : d.Service.ReceiveShopeePush", zap.Error(err))
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostShopeePush", err)
+	}
+
+	// ack first : Shopee retries pushes that are not answered quickly.
+	// The request ctx dies with the response ; the usecase scopes the work to the tenant of the
+	// pushed shop once it checked the shop belongs to this partner.
+	go func(id string) {
+		ctx, cancel := context.WithTimeout(pkg.WithoutTenant(context.Background()), pushProcessTimeout)
+		defer cancel()
+		if _, err := d.Service.ProcessShopeePushEvent(ctx, id); err != nil {
+			d.Logger.Error("handler.PostShopeePush : ProcessShopeePushEvent", zap.String("event_id", id), zap.Error(err))
+		}
+	}(event.ID)
+
+	return response.SuccessResponse(c, "handler.PostShopeePush", map[string]string{"id": event.ID})
+}
+
+func (d *shopeePushHandler) GetShopeePushEvents(c *fiber.Ctx) error {
+	var filter IReqShopeePushEventFilter
+	if err := c.QueryParser(&filter); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.GetShopeePushEvents", "invalid query")
+	}
+
+	res, err := d.Service.GetShopeePushEvents(c.Context(), &filter)
+	if err != nil {
+		return response.ErrorResponse(c, fiber.StatusInternalServerError, "handler.GetShopeePushEvents", err)
+	}
+	return response.SuccessResponse(c, "handler.GetShopeePushEvents", res)
+}
+
+func (d *shopeePushHandler) PostShopeePushEventReplay(c *fiber.Ctx) error {
+	eventID := c.Params("eventID")
+	if eventID == "" {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostShopeePushEventReplay", "eventID is required")
+	}
+
+	res, err := d.Service.ProcessShopeePushEvent(c.Context(), eventID)
+	if err != nil {
+		if res == nil {
+			return response.ErrorResponse(c, fiber.StatusNotFound, "handler.PostShopeePushEventReplay", err)
+		}
+		return response.ErrorResponse(c, fiber.StatusConflict, "handler.PostShopeePushEventReplay", res)
+	}
+	return response.SuccessResponse(c, "handler.PostShopeePushEventReplay", res)
+}
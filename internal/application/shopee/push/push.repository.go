@@ -0,0 +1,214 @@
+package push
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"go.uber.org/zap"
+)
+
+// Shopee Push Mechanism : https://open.shopee.com/push-mechanism
+type ShopeePushCodeEnum int
+
+const (
+	PUSH_SHOP_AUTHORIZATION          ShopeePushCodeEnum = 1
+	PUSH_SHOP_AUTHORIZATION_CANCELED ShopeePushCodeEnum = 2
+	PUSH_ORDER_STATUS                ShopeePushCodeEnum = 3
+	PUSH_ORDER_TRACKING_NO           ShopeePushCodeEnum = 4
+	PUSH_AUTHORIZATION_EXPIRY        ShopeePushCodeEnum = 12
+)
+
+type ShopeePushStatusEnum string
+
+const (
+	PUSH_RECEIVED  ShopeePushStatusEnum = "RECEIVED"
+	PUSH_PROCESSED ShopeePushStatusEnum = "PROCESSED"
+	PUSH_IGNORED   ShopeePushStatusEnum = "IGNORED" // no handler for code
+	PUSH_FAILED    ShopeePushStatusEnum = "FAILED"
+	// signed by a partner the shop is not authorized under : never processed
+	PUSH_REJECTED ShopeePushStatusEnum = "REJECTED"
+)
+
+// raw event : kept as received so it can be replayed
+type ShopeePushEventModel struct {
+	ID        bson.ObjectID        `bson:"_id"`
+	PartnerID string               `bson:"partner_id"`
+	ShopID    string               `bson:"shop_id"`
+	Code      ShopeePushCodeEnum   `bson:"code"`
+	Timestamp int64                `bson:"timestamp"`
+	Data      string               `bson:"data"`      // raw json of "data"
+	RawBody   string               `bson:"raw_body"`  // signed payload
+	BodyHash  string               `bson:"body_hash"` // sha256 of raw_body : a retried push is the same event
+	Status    ShopeePushStatusEnum `bson:"status"`
+	Error     string               `bson:"error"`
+	Attempts  int                  `bson:"attempts"`
+
+	ReceivedAt  time.Time `bson:"received_at"`
+	ProcessedAt time.Time `bson:"processed_at"`
+}
+
+type ShopeePushEventEntity struct {
+	ID        string               `json:"id"`
+	PartnerID string               `json:"partner_id"`
+	ShopID    string               `json:"shop_id"`
+	Code      ShopeePushCodeEnum   `json:"code"`
+	Timestamp int64                `json:"timestamp"`
+	Data      string               `json:"data"`
+	Status    ShopeePushStatusEnum `json:"status"`
+	Error     string               `json:"error"`
+	Attempts  int                  `json:"attempts"`
+
+	ReceivedAt  time.Time `json:"received_at"`
+	ProcessedAt time.Time `json:"processed_at"`
+}
+
+func ShopeePushEventModelToEntity(model *ShopeePushEventModel) *ShopeePushEventEntity {
+	return &ShopeePushEventEntity{
+		ID:          model.ID.Hex(),
+		PartnerID:   model.PartnerID,
+		ShopID:      model.ShopID,
+		Code:        model.Code,
+		Timestamp:   model.Timestamp,
+		Data:        model.Data,
+		Status:      model.Status,
+		Error:       model.Error,
+		Attempts:    model.Attempts,
+		ReceivedAt:  model.ReceivedAt,
+		ProcessedAt: model.ProcessedAt,
+	}
+}
+
+type IReqShopeePushEventFilter struct {
+	ShopID string `query:"shop_id"`
+	Code   int    `query:"code"`
+	Status string `query:"status"`
+	Limit  int64  `query:"limit"`
+}
+
+type ShopeePushEventRepository interface {
+	InitRepository() error
+	// created false : the same push (partner, shop, code, timestamp, body) is already stored, that one is returned
+	CreateShopeePushEvent(ctx context.Context, event *ShopeePushEventModel) (*ShopeePushEventModel, bool, error)
+	GetShopeePushEventByID(ctx context.Context, id string) (*ShopeePushEventModel, error)
+	GetShopeePushEvents(ctx context.Context, filter *IReqShopeePushEventFilter) ([]ShopeePushEventModel, error)
+	UpdateShopeePushEventResult(ctx context.Context, id bson.ObjectID, status ShopeePushStatusEnum, reason string) (*ShopeePushEventModel, error)
+}
+
+type shopeePushEventRepository struct {
+	Logger *zap.Logger
+	DB     *mongo.Collection
+}
+
+func NewShopeePushEventRepository(db *mongo.Collection, log *zap.Logger) ShopeePushEventRepository {
+	return &shopeePushEventRepository{Logger: log, DB: db}
+}
+
+func (r *shopeePushEventRepository) InitRepository() error {
+	indexs := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "shop_id", Value: 1}, {Key: "received_at", Value: -1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}}},
+		// events stored before body_hash are left out
+		{
+			Keys:    bson.D{{Key: "partner_id", Value: 1}, {Key: "shop_id", Value: 1}, {Key: "code", Value: 1}, {Key: "timestamp", Value: 1}, {Key: "body_hash", Value: 1}},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"body_hash": bson.M{"$type": "string"}}),
+		},
+	}
+	if _, err := r.DB.Indexes().CreateMany(context.TODO(), indexs); err != nil {
+		r.Logger.Error("error creating index", zap.Error(err))
+		return errors.New("ShopeePushEventRepository.InitRepository: failed create indexs")
+	}
+	r.Logger.Info("ShopeePushEventRepository.InitRepository: index created")
+	return nil
+}
+
+func (r *shopeePushEventRepository) CreateShopeePushEvent(ctx context.Context, event *ShopeePushEventModel) (*ShopeePushEventModel, bool, error) {
+	if event.ID.IsZero() {
+		event.ID = bson.NewObjectID()
+	}
+	_, err := r.DB.InsertOne(ctx, event)
+	if err == nil {
+		return event, true, nil
+	}
+	if !mongo.IsDuplicateKeyError(err) {
+		return nil, false, err
+	}
+
+	var stored ShopeePushEventModel
+	filter := bson.M{"partner_id": event.PartnerID, "shop_id": event.ShopID, "code": event.Code, "timestamp": event.Timestamp, "body_hash": event.BodyHash}
+	if err := r.DB.FindOne(ctx, filter).Decode(&stored); err != nil {
+		return nil, false, err
+	}
+	return &stored, false, nil
+}
+
+func (r *shopeePushEventRepository) GetShopeePushEventByID(ctx context.Context, id string) (*ShopeePushEventModel, error) {
+	oid, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, errors.New("invalid push event id")
+	}
+
+	var event ShopeePushEventModel
+	if err := r.DB.FindOne(ctx, bson.M{"_id": oid}).Decode(&event); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, errors.New("push event not found")
+		}
+		return nil, err
+	}
+	return &event, nil
+}
+
+func (r *shopeePushEventRepository) GetShopeePushEvents(ctx context.Context, filter *IReqShopeePushEventFilter) ([]ShopeePushEventModel, error) {
+	query := bson.M{}
+	if filter.ShopID != "" {
+		query["shop_id"] = filter.ShopID
+	}
+	if filter.Code != 0 {
+		query["code"] = filter.Code
+	}
+	if filter.Status != "" {
+		query["status"] = filter.Status
+	}
+
+	limit := filter.Limit
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "received_at", Value: -1}}).SetLimit(limit)
+
+	cursor, err := r.DB.Find(ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	res := []ShopeePushEventModel{}
+	if err := cursor.All(ctx, &res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (r *shopeePushEventRepository) UpdateShopeePushEventResult(ctx context.Context, id bson.ObjectID, status ShopeePushStatusEnum, reason string) (*ShopeePushEventModel, error) {
+	update := bson.M{
+		"$set": bson.M{
+			"status":       status,
+			"error":        reason,
+			"processed_at": time.Now(),
+		},
+		"$inc": bson.M{"attempts": 1},
+	}
+
+	opt := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var updated ShopeePushEventModel
+	if err := r.DB.FindOneAndUpdate(ctx, bson.M{"_id": id}, update, opt).Decode(&updated); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, errors.New("push event not found")
+		}
+		return nil, err
+	}
+	return &updated, nil
+}
@@ -0,0 +1,351 @@
+package push
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"ecommerce/internal/application/shopee"
+	"ecommerce/internal/application/shopee/partner"
+	"ecommerce/internal/env"
+	"ecommerce/internal/pkg"
+)
+
+var (
+	ErrInvalidPushSignature = errors.New("invalid shopee push signature")
+	// the signature only proves the partner : a shop of another partner is not its to change
+	ErrPushShopNotOwned = errors.New("shop is not authorized under the pushing partner")
+	// Shopee retried a push we already stored : returned with the stored event, not processed again
+	ErrPushDuplicate = errors.New("shopee push already received")
+)
+
+type IShopeePushService interface {
+	// verify + store : processing is done separately (ProcessShopeePushEvent) ;
+	// ErrPushDuplicate with the stored event when Shopee sends the same push again
+	ReceiveShopeePush(ctx context.Context, partnerID string, url string, body []byte, authorization string) (*ShopeePushEventEntity, error)
+	ProcessShopeePushEvent(ctx context.Context, eventID string) (*ShopeePushEventEntity, error)
+
+	GetShopeePushEvents(ctx context.Context, filter *IReqShopeePushEventFilter) ([]ShopeePushEventEntity, error)
+	// callback url the partner registered in Shopee console : what Shopee signs
+	GetShopeePushCallbackURL(partnerID string, fallback string) string
+}
+
+type pushHandlerFunc func(ctx context.Context, event *ShopeePushEventModel) error
+
+type shopeePushService struct {
+	Config *env.Config
+	Logger *zap.Logger
+
+	ShopeePushEventRepository ShopeePushEventRepository
+	ShopeePartnerRepository   partner.ShopeePartnerRepository
+	ShopeeAuthRepository      shopee.ShopeeAuthRepository
+	ShopeeOrderRepository     shopee.ShopeeOrderRepository
+	ShopeeService             shopee.IShopeeService
+
+	handlers map[ShopeePushCodeEnum]pushHandlerFunc
+}
+
+func NewShopeePushService(cfg *env.Config, logger *zap.Logger,
+	pushEvent ShopeePushEventRepository,
+	shopeePartner partner.ShopeePartnerRepository,
+	shopeeAuth shopee.ShopeeAuthRepository,
+	shopeeOrder shopee.ShopeeOrderRepository,
+	shopeeService shopee.IShopeeService,
+) IShopeePushService {
+	s := &shopeePushService{
+		Config:                    cfg,
+		Logger:                    logger,
+		ShopeePushEventRepository: pushEvent,
+		ShopeePartnerRepository:   shopeePartner,
+		ShopeeAuthRepository:      shopeeAuth,
+		ShopeeOrderRepository:     shopeeOrder,
+		ShopeeService:             shopeeService,
+	}
+	s.handlers = map[ShopeePushCodeEnum]pushHandlerFunc{
+		PUSH_SHOP_AUTHORIZATION_CANCELED: s.handleShopAuthorizationCanceled,
+		PUSH_ORDER_STATUS:                s.handleOrderStatus,
+		PUSH_ORDER_TRACKING_NO:           s.handleOrderTrackingNo,
+		PUSH_AUTHORIZATION_EXPIRY:        s.handleAuthorizationExpiry,
+	}
+	return s
+}
+
+// Authorization = hex(HMAC-SHA256(partner_key, url + "|" + body))
+func VerifyShopeePushSignature(url string, body []byte, partnerKey string, authorization string) bool {
+	h := hmac.New(sha256.New, []byte(partnerKey))
+	h.Write([]byte(url + "|"))
+	h.Write(body)
+	expected := hex.EncodeToString(h.Sum(nil))
+	return hmac.Equal([]byte(expected), []byte(strings.ToLower(strings.TrimSpace(authorization))))
+}
+
+func (s *shopeePushService) GetShopeePushCallbackURL(partnerID string, fallback string) string {
+	if s.Config.Shopee.ShopeePushCallbackURL == "" {
+		return fallback
+	}
+	return strings.ReplaceAll(s.Config.Shopee.ShopeePushCallbackURL, "{partner_id}", partnerID)
+}
+
+type iReqShopeePushBody struct {
+	ShopID    json.Number     `json:"shop_id"`
+	Code      int             `json:"code"`
+	Timestamp int64           `json:"timestamp"`
+	Data      json.RawMessage `json:"data"`
+}
+
+func (s *shopeePushService) ReceiveShopeePush(ctx context.Context, partnerID string, url string, body []byte, authorization string) (*ShopeePushEventEntity, error) {
+	if authorization == "" {
+		return nil, ErrInvalidPushSignature
+	}
+
+	partnerData, err := s.ShopeePartnerRepository.GetShopeePartnerByID(ctx, partnerID)
+	if err != nil {
+		return nil, err
+	}
+	if !VerifyShopeePushSignature(url, body, partnerData.SecretKey, authorization) {
+		s.Logger.Info("usecase.ReceiveShopeePush : signature mismatch", zap.String("partner_id", partnerID), zap.String("url", url))
+		return nil, ErrInvalidPushSignature
+	}
+
+	var req iReqShopeePushBody
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, fmt.Errorf("usecase.ReceiveShopeePush : invalid body : %w", err)
+	}
+
+	sum := sha256.Sum256(body)
+	event, created, err := s.ShopeePushEventRepository.CreateShopeePushEvent(ctx, &ShopeePushEventModel{
+		PartnerID:  partnerID,
+		ShopID:     req.ShopID.String(),
+		Code:       ShopeePushCodeEnum(req.Code),
+		Timestamp:  req.Timestamp,
+		Data:       string(req.Data),
+		RawBody:    string(body),
+		BodyHash:   hex.EncodeToString(sum[:]),
+		Status:     PUSH_RECEIVED,
+		ReceivedAt: time.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !created {
+		return ShopeePushEventModelToEntity(event), ErrPushDuplicate
+	}
+	return ShopeePushEventModelToEntity(event), nil
+}
+
+// ProcessShopeePushEvent : dispatch by code, also used to replay a stored event
+func (s *shopeePushService) ProcessShopeePushEvent(ctx context.Context, eventID string) (*ShopeePushEventEntity, error) {
+	event, err := s.ShopeePushEventRepository.GetShopeePushEventByID(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+
+	status := PUSH_PROCESSED
+	reason := ""
+	handler, ok := s.handlers[event.Code]
+	if !ok {
+		status = PUSH_IGNORED
+	} else if err := handler(ctx, event); errors.Is(err, ErrPushShopNotOwned) {
+		s.Logger.Warn("usecase.ProcessShopeePushEvent : shop not owned by partner",
+			zap.String("event_id", eventID),
+			zap.String("partner_id", event.PartnerID),
+			zap.String("shop_id", event.ShopID))
+		status = PUSH_REJECTED
+		reason = err.Error()
+	} else if err != nil {
+		s.Logger.Error("usecase.ProcessShopeePushEvent : handler failed",
+			zap.String("event_id", eventID),
+			zap.Int("code", int(event.Code)),
+			zap.Error(err))
+		status = PUSH_FAILED
+		reason = err.Error()
+	}
+
+	updated, err := s.ShopeePushEventRepository.UpdateShopeePushEventResult(ctx, event.ID, status, reason)
+	if err != nil {
+		return nil, err
+	}
+	if status == PUSH_FAILED || status == PUSH_REJECTED {
+		return ShopeePushEventModelToEntity(updated), errors.New(reason)
+	}
+	return ShopeePushEventModelToEntity(updated), nil
+}
+
+func (s *shopeePushService) GetShopeePushEvents(ctx context.Context, filter *IReqShopeePushEventFilter) ([]ShopeePushEventEntity, error) {
+	events, err := s.ShopeePushEventRepository.GetShopeePushEvents(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]ShopeePushEventEntity, len(events))
+	for i := range events {
+		res[i] = *ShopeePushEventModelToEntity(&events[i])
+	}
+	return res, nil
+}
+
+// ownedShop : ctx scoped to the tenant of shopID, only when the shop was authorized under
+// partnerID ; handlers work under it, never under the unscoped ctx of the callback
+func (s *shopeePushService) ownedShop(ctx context.Context, partnerID string, shopID string) (context.Context, error) {
+	if shopID == "" || shopID == "0" {
+		return nil, errors.New("shop_id is required")
+	}
+	shop, err := s.ShopeeAuthRepository.GetShopeeShopAuthByShopId(pkg.WithoutTenant(ctx), shopID)
+	if err != nil {
+		return nil, fmt.Errorf("%w : %s", ErrPushShopNotOwned, shopID)
+	}
+	if shop.PartnerID != partnerID {
+		return nil, fmt.Errorf("%w : %s", ErrPushShopNotOwned, shopID)
+	}
+	return pkg.WithTenant(ctx, shop.TenantID), nil
+}
+
+// -- typed handlers
+
+type ShopeePushOrderStatusData struct {
+	OrderSN           string `json:"ordersn"`
+	Status            string `json:"status"`
+	CompletedScenario string `json:"completed_scenario"`
+	UpdateTime        int64  `json:"update_time"`
+}
+
+func (s *shopeePushService) handleOrderStatus(ctx context.Context, event *ShopeePushEventModel) error {
+	var data ShopeePushOrderStatusData
+	if err := json.Unmarshal([]byte(event.Data), &data); err != nil {
+		return err
+	}
+	if data.OrderSN == "" {
+		return errors.New("ordersn is required")
+	}
+	ctx, err := s.ownedShop(ctx, event.PartnerID, event.ShopID)
+	if err != nil {
+		return err
+	}
+
+	updateTime := time.Unix(data.UpdateTime, 0)
+	if data.UpdateTime == 0 {
+		updateTime = time.Unix(event.Timestamp, 0)
+	}
+
+	order, err := s.ShopeeOrderRepository.UpdateShopeeOrderStatusByOrderSN(ctx, data.OrderSN, shopee.ShopeeOrderStatusEnum(data.Status), updateTime)
+	if err == nil {
+		// nil : stale push, nothing changed
+		s.ShopeeService.NotifyShopeeOrderSaved(ctx, order)
+		return nil
+	}
+
+	// not stored yet : pull full details
+	if _, getErr := s.ShopeeOrderRepository.GetShopeeOrderByOrderSN(ctx, data.OrderSN); getErr == nil {
+		return err
+	}
+	_, err = s.ShopeeService.SyncShopeeOrderByOrderSN(ctx, event.ShopID, []string{data.OrderSN})
+	return err
+}
+
+type ShopeePushTrackingNoData struct {
+	OrderSN       string `json:"ordersn"`
+	ForderID      string `json:"forder_id"`
+	PackageNumber string `json:"package_number"`
+	TrackingNo    string `json:"tracking_no"`
+}
+
+func (s *shopeePushService) handleOrderTrackingNo(ctx context.Context, event *ShopeePushEventModel) error {
+	var data ShopeePushTrackingNoData
+	if err := json.Unmarshal([]byte(event.Data), &data); err != nil {
+		return err
+	}
+	if data.OrderSN == "" || data.TrackingNo == "" {
+		return errors.New("ordersn and tracking_no are required")
+	}
+	ctx, err := s.ownedShop(ctx, event.PartnerID, event.ShopID)
+	if err != nil {
+		return err
+	}
+
+	packageNumber := data.PackageNumber
+	if packageNumber == "" {
+		packageNumber = data.ForderID
+	}
+
+	_, err = s.ShopeeOrderRepository.UpdateShopeeOrderTrackingNumber(ctx, data.OrderSN, packageNumber, data.TrackingNo)
+	if err == nil {
+		return nil
+	}
+
+	// not stored yet : pull details then retry once
+	if _, syncErr := s.ShopeeService.SyncShopeeOrderByOrderSN(ctx, event.ShopID, []string{data.OrderSN}); syncErr != nil {
+		return syncErr
+	}
+	_, err = s.ShopeeOrderRepository.UpdateShopeeOrderTrackingNumber(ctx, data.OrderSN, packageNumber, data.TrackingNo)
+	return err
+}
+
+type ShopeePushAuthorizationCanceledData struct {
+	ShopID  json.Number `json:"shop_id"`
+	Success int         `json:"success"`
+	Extra   string      `json:"extra"`
+}
+
+func (s *shopeePushService) handleShopAuthorizationCanceled(ctx context.Context, event *ShopeePushEventModel) error {
+	var data ShopeePushAuthorizationCanceledData
+	if err := json.Unmarshal([]byte(event.Data), &data); err != nil {
+		return err
+	}
+
+	shopID := event.ShopID
+	if shopID == "" || shopID == "0" {
+		shopID = data.ShopID.String()
+	}
+	ctx, err := s.ownedShop(ctx, event.PartnerID, shopID)
+	if err != nil {
+		return err
+	}
+	_, err = s.ShopeeAuthRepository.UpdateShopeeShopAuthNeedReauth(ctx, shopID, "shop authorization canceled by seller")
+	return err
+}
+
+type ShopeePushAuthorizationExpiryData struct {
+	ExpireBefore       int64         `json:"expire_before"`
+	ShopExpireSoon     []json.Number `json:"shop_expire_soon"`
+	MerchantExpireSoon []json.Number `json:"merchant_expire_soon"`
+}
+
+func (s *shopeePushService) handleAuthorizationExpiry(ctx context.Context, event *ShopeePushEventModel) error {
+	var data ShopeePushAuthorizationExpiryData
+	if err := json.Unmarshal([]byte(event.Data), &data); err != nil {
+		return err
+	}
+
+	// one push for every shop of the partner : shops of other partners are skipped, not applied
+	expireAt := time.Unix(data.ExpireBefore, 0)
+	var failed, foreign []string
+	for _, shopID := range data.ShopExpireSoon {
+		shopCtx, err := s.ownedShop(ctx, event.PartnerID, shopID.String())
+		if err != nil {
+			foreign = append(foreign, shopID.String())
+			continue
+		}
+		if _, err := s.ShopeeAuthRepository.UpdateShopeeShopAuthExpireAt(shopCtx, shopID.String(), expireAt); err != nil {
+			failed = append(failed, shopID.String()+": "+err.Error())
+		}
+	}
+	if len(foreign) > 0 {
+		s.Logger.Warn("usecase.handleAuthorizationExpiry : shops not owned by partner skipped",
+			zap.String("partner_id", event.PartnerID), zap.Strings("shop_ids", foreign))
+	}
+	if len(failed) > 0 {
+		return errors.New(strings.Join(failed, ", "))
+	}
+	if len(foreign) > 0 && len(foreign) == len(data.ShopExpireSoon) {
+		return fmt.Errorf("%w : %s", ErrPushShopNotOwned, strings.Join(foreign, ", "))
+	}
+	return nil
+}
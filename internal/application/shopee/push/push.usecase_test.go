@@ -0,0 +1,231 @@
+package push
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.uber.org/zap"
+
+	"ecommerce/internal/application/shopee"
+	"ecommerce/internal/application/shopee/partner"
+	"ecommerce/internal/env"
+	"ecommerce/internal/pkg"
+)
+
+// calls records what the handlers changed and under which tenant
+type calls struct {
+	mu      sync.Mutex
+	changes []string // kind:shop_or_order@tenant
+}
+
+func (c *calls) add(ctx context.Context, kind string, key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tenantID, _ := pkg.TenantFromContext(ctx)
+	c.changes = append(c.changes, kind+":"+key+"@"+tenantID)
+}
+
+type fakePushEventRepository struct {
+	ShopeePushEventRepository
+	events map[string]*ShopeePushEventModel
+}
+
+func (r *fakePushEventRepository) GetShopeePushEventByID(ctx context.Context, id string) (*ShopeePushEventModel, error) {
+	event, ok := r.events[id]
+	if !ok {
+		return nil, errors.New("push event not found")
+	}
+	return event, nil
+}
+
+// CreateShopeePushEvent : the unique (partner, shop, code, timestamp, body_hash) index of the mongo repository
+func (r *fakePushEventRepository) CreateShopeePushEvent(ctx context.Context, event *ShopeePushEventModel) (*ShopeePushEventModel, bool, error) {
+	for _, e := range r.events {
+		if e.PartnerID == event.PartnerID && e.ShopID == event.ShopID && e.Code == event.Code && e.Timestamp == event.Timestamp && e.BodyHash == event.BodyHash {
+			return e, false, nil
+		}
+	}
+	event.ID = bson.NewObjectID()
+	r.events[event.ID.Hex()] = event
+	return event, true, nil
+}
+
+func (r *fakePushEventRepository) UpdateShopeePushEventResult(ctx context.Context, id bson.ObjectID, status ShopeePushStatusEnum, reason string) (*ShopeePushEventModel, error) {
+	event := r.events[id.Hex()]
+	event.Status, event.Error = status, reason
+	event.Attempts++
+	return event, nil
+}
+
+type fakePartnerRepository struct {
+	partner.ShopeePartnerRepository
+}
+
+func (fakePartnerRepository) GetShopeePartnerByID(ctx context.Context, partnerID string) (*partner.ShopeePartnerEntity, error) {
+	return &partner.ShopeePartnerEntity{PartnerID: partnerID, SecretKey: "key-" + partnerID}, nil
+}
+
+type fakeAuthRepository struct {
+	shopee.ShopeeAuthRepository
+	shops map[string]shopee.ShopeeAuthModel
+	calls *calls
+}
+
+func (r *fakeAuthRepository) GetShopeeShopAuthByShopId(ctx context.Context, shopID string) (*shopee.ShopeeAuthModel, error) {
+	shop, ok := r.shops[shopID]
+	// scoped like the mongo repository
+	if tenantID, err := pkg.TenantStamp(ctx); !ok || err != nil || (!pkg.Unscoped(ctx) && tenantID != shop.TenantID) {
+		return nil, errors.New("shop not found")
+	}
+	return &shop, nil
+}
+
+func (r *fakeAuthRepository) UpdateShopeeShopAuthNeedReauth(ctx context.Context, shopID string, reason string) (*shopee.ShopeeAuthModel, error) {
+	r.calls.add(ctx, "reauth", shopID)
+	return r.GetShopeeShopAuthByShopId(ctx, shopID)
+}
+
+func (r *fakeAuthRepository) UpdateShopeeShopAuthExpireAt(ctx context.Context, shopID string, expireAt time.Time) (*shopee.ShopeeAuthModel, error) {
+	r.calls.add(ctx, "expire", shopID)
+	return r.GetShopeeShopAuthByShopId(ctx, shopID)
+}
+
+type fakeOrderRepository struct {
+	shopee.ShopeeOrderRepository
+	calls *calls
+}
+
+func (r *fakeOrderRepository) UpdateShopeeOrderStatusByOrderSN(ctx context.Context, orderSN string, status shopee.ShopeeOrderStatusEnum, updateTime time.Time) (*shopee.ShopeeOrderEntity, error) {
+	r.calls.add(ctx, "status", orderSN)
+	return &shopee.ShopeeOrderEntity{OrderSN: orderSN}, nil
+}
+
+type fakeShopeeService struct{ shopee.IShopeeService }
+
+func (fakeShopeeService) NotifyShopeeOrderSaved(ctx context.Context, order *shopee.ShopeeOrderEntity) {
+}
+
+const tenantA = "6650f0c2a1b2c3d4e5f60718"
+
+// partner 1 : shop 10 (tenant A), shop 11 (platform) ; partner 2 : shop 20 (tenant A too)
+func newTestPush(t *testing.T) (*shopeePushService, *fakePushEventRepository, *calls) {
+	t.Helper()
+	c := &calls{}
+	events := &fakePushEventRepository{events: map[string]*ShopeePushEventModel{}}
+	auth := &fakeAuthRepository{calls: c, shops: map[string]shopee.ShopeeAuthModel{
+		"10": {PartnerID: "1", ShopID: "10", TenantID: tenantA},
+		"11": {PartnerID: "1", ShopID: "11"},
+		"20": {PartnerID: "2", ShopID: "20", TenantID: tenantA},
+	}}
+	s := NewShopeePushService(&env.Config{}, zap.NewNop(), events, fakePartnerRepository{}, auth, &fakeOrderRepository{calls: c}, fakeShopeeService{}).(*shopeePushService)
+	return s, events, c
+}
+
+func (r *fakePushEventRepository) push(partnerID string, shopID string, code ShopeePushCodeEnum, data string) string {
+	event := &ShopeePushEventModel{ID: bson.NewObjectID(), PartnerID: partnerID, ShopID: shopID, Code: code, Data: data, Timestamp: time.Now().Unix(), Status: PUSH_RECEIVED}
+	r.events[event.ID.Hex()] = event
+	return event.ID.Hex()
+}
+
+func TestPushProcessedUnderShopTenant(t *testing.T) {
+	s, events, c := newTestPush(t)
+	id := events.push("1", "10", PUSH_ORDER_STATUS, `{"ordersn":"SN1","status":"READY_TO_SHIP","update_time":1700000000}`)
+	res, err := s.ProcessShopeePushEvent(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Status != PUSH_PROCESSED {
+		t.Fatalf("status %s", res.Status)
+	}
+	// platform shop : processed as the platform tenant, not unscoped
+	id = events.push("1", "11", PUSH_SHOP_AUTHORIZATION_CANCELED, `{"shop_id":11,"success":1}`)
+	if _, err := s.ProcessShopeePushEvent(context.Background(), id); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"status:SN1@" + tenantA, "reauth:11@" + pkg.DEFAULT_TENANT}
+	if len(c.changes) != len(want) || c.changes[0] != want[0] || c.changes[1] != want[1] {
+		t.Fatalf("changes %v, want %v", c.changes, want)
+	}
+}
+
+func TestPushForeignShopRejected(t *testing.T) {
+	s, events, c := newTestPush(t)
+	for _, e := range []struct {
+		partner, shop string
+		code          ShopeePushCodeEnum
+		data          string
+	}{
+		// partner 2 signs a push about partner 1's shop
+		{"2", "10", PUSH_ORDER_STATUS, `{"ordersn":"SN1","status":"CANCELLED"}`},
+		{"2", "10", PUSH_ORDER_TRACKING_NO, `{"ordersn":"SN1","tracking_no":"TH1"}`},
+		{"2", "0", PUSH_SHOP_AUTHORIZATION_CANCELED, `{"shop_id":11,"success":1}`},
+		// shop nobody authorized
+		{"1", "99", PUSH_ORDER_STATUS, `{"ordersn":"SN9","status":"CANCELLED"}`},
+		{"1", "0", PUSH_AUTHORIZATION_EXPIRY, `{"expire_before":1700000000,"shop_expire_soon":[20]}`},
+	} {
+		id := events.push(e.partner, e.shop, e.code, e.data)
+		res, err := s.ProcessShopeePushEvent(context.Background(), id)
+		if !errors.Is(err, ErrPushShopNotOwned) && (res == nil || res.Status != PUSH_REJECTED) {
+			t.Errorf("partner %s shop %s code %d : err = %v", e.partner, e.shop, e.code, err)
+		}
+		if res != nil && res.Status != PUSH_REJECTED {
+			t.Errorf("partner %s shop %s code %d : status %s, want REJECTED", e.partner, e.shop, e.code, res.Status)
+		}
+	}
+	if len(c.changes) != 0 {
+		t.Fatalf("foreign pushes changed %v", c.changes)
+	}
+}
+
+func TestPushAuthorizationExpirySkipsForeignShops(t *testing.T) {
+	s, events, c := newTestPush(t)
+	id := events.push("1", "0", PUSH_AUTHORIZATION_EXPIRY, `{"expire_before":1700000000,"shop_expire_soon":[10,20,11]}`)
+	res, err := s.ProcessShopeePushEvent(context.Background(), id)
+	if err != nil || res.Status != PUSH_PROCESSED {
+		t.Fatalf("status %v, err %v", res, err)
+	}
+	want := []string{"expire:10@" + tenantA, "expire:11@" + pkg.DEFAULT_TENANT}
+	if len(c.changes) != 2 || c.changes[0] != want[0] || c.changes[1] != want[1] {
+		t.Fatalf("changes %v, want %v", c.changes, want)
+	}
+}
+
+func TestReceivePushDuplicate(t *testing.T) {
+	s, events, _ := newTestPush(t)
+	const url = "https://shop.example/webhook/shopee/push/1"
+	receive := func(body string) (*ShopeePushEventEntity, error) {
+		h := hmac.New(sha256.New, []byte("key-1"))
+		h.Write([]byte(url + "|" + body))
+		return s.ReceiveShopeePush(context.Background(), "1", url, []byte(body), hex.EncodeToString(h.Sum(nil)))
+	}
+	body := `{"shop_id":10,"code":3,"timestamp":1700000000,"data":{"ordersn":"SN1","status":"READY_TO_SHIP"}}`
+
+	first, err := receive(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Shopee retries a push it got no answer for : same body
+	again, err := receive(body)
+	if !errors.Is(err, ErrPushDuplicate) {
+		t.Fatalf("retry: err = %v, want ErrPushDuplicate", err)
+	}
+	if again == nil || again.ID != first.ID {
+		t.Fatalf("retry answered %+v, want the stored event %s", again, first.ID)
+	}
+
+	// same shop, code and second, another change : a new event
+	other, err := receive(`{"shop_id":10,"code":3,"timestamp":1700000000,"data":{"ordersn":"SN2","status":"READY_TO_SHIP"}}`)
+	if err != nil || other.ID == first.ID {
+		t.Fatalf("other push: %v, id %v", err, other)
+	}
+	if len(events.events) != 2 {
+		t.Errorf("%d events stored, want 2", len(events.events))
+	}
+}
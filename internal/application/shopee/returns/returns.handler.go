@@ -0,0 +1,245 @@
+package returns
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	"ecommerce/internal/adapter/dto"
+	"ecommerce/internal/delivery/http/response"
+)
+
+// dates are YYYY-MM-DD, to is inclusive
+const periodLayout = "2006-01-02"
+
+type IReqShopeeReturnSync struct {
+	From string `json:"from" validate:"required,datetime=2006-01-02"`
+	To   string `json:"to" validate:"required,datetime=2006-01-02"`
+}
+
+type IReqShopeeReturnFilter struct {
+	OrderSN string                      `query:"order_sn"`
+	Status  dto.IEnumShopeeReturnStatus `query:"status"`
+}
+
+type IReqShopeeReturnOffer struct {
+	ProposedSolution             dto.IEnumShopeeReturnSolution `json:"proposed_solution" validate:"required,oneof=RETURN_REFUND REFUND"`
+	ProposedAdjustedRefundAmount float64                       `json:"proposed_adjusted_refund_amount" validate:"gte=0"`
+}
+
+type IReqShopeeReturnDispute struct {
+	Email             string `json:"email" validate:"required,email"`
+	DisputeReason     int64  `json:"dispute_reason" validate:"required"`
+	DisputeTextReason string `json:"dispute_text_reason" validate:"required,max=1000"`
+}
+
+type IShopeeReturnHandler interface {
+	PostShopeeReturnSync(c *fiber.Ctx) error
+	GetShopeeReturns(c *fiber.Ctx) error
+	GetShopeeReturnsByOrderSN(c *fiber.Ctx) error
+	GetShopeeReturnByReturnSN(c *fiber.Ctx) error
+	PostShopeeReturnAccept(c *fiber.Ctx) error
+	PostShopeeReturnOffer(c *fiber.Ctx) error
+	PostShopeeReturnEvidence(c *fiber.Ctx) error
+	PostShopeeReturnDispute(c *fiber.Ctx) error
+}
+
+type shopeeReturnHandler struct {
+	Logger   *zap.Logger
+	Validate *validator.Validate
+	Service  IShopeeReturnService
+}
+
+func NewShopeeReturnHandler(log *zap.Logger, valid *validator.Validate, srv IShopeeReturnService) IShopeeReturnHandler {
+	return &shopeeReturnHandler{
+		Logger:   log,
+		Validate: valid,
+		Service:  srv,
+	}
+}
+
+// actor : username set by the auth middleware
+func actor(c *fiber.Ctx) string {
+	if username, ok := c.Locals("username").(string); ok {
+		return username
+	}
+	return ""
+}
+
+func returnErrorStatus(err error) int {
+	if errors.Is(err, ErrReturnClosed) {
+		return fiber.StatusConflict
+	}
+	return fiber.StatusBadRequest
+}
+
+func (d *shopeeReturnHandler) PostShopeeReturnSync(c *fiber.Ctx) error {
+	shopID := c.Params("shopeeShopID")
+	if shopID == "" {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostShopeeReturnSync", "shopeeShopID is required")
+	}
+
+	var reqBody IReqShopeeReturnSync
+	if err := c.BodyParser(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostShopeeReturnSync", "invalid body")
+	}
+	if err := d.Validate.Struct(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostShopeeReturnSync", "from and to are required as YYYY-MM-DD")
+	}
+	from, _ := time.Parse(periodLayout, reqBody.From)
+	to, _ := time.Parse(periodLayout, reqBody.To)
+
+	res, err := d.Service.SyncShopeeReturnsByShopID(c.Context(), shopID, from, to.AddDate(0, 0, 1))
+	if err != nil {
+		d.Logger.Error("handler.PostShopeeReturnSync : SyncShopeeReturnsByShopID", zap.String("shop_id", shopID), zap.Error(err))
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostShopeeReturnSync", err)
+	}
+	return response.SuccessResponse(c, "handler.PostShopeeReturnSync", res)
+}
+
+func (d *shopeeReturnHandler) GetShopeeReturns(c *fiber.Ctx) error {
+	shopID := c.Params("shopeeShopID")
+	if shopID == "" {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.GetShopeeReturns", "shopeeShopID is required")
+	}
+
+	var filter IReqShopeeReturnFilter
+	if err := c.QueryParser(&filter); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.GetShopeeReturns", "invalid query")
+	}
+
+	res, err := d.Service.GetShopeeReturns(c.Context(), shopID, &filter)
+	if err != nil {
+		return response.ErrorResponse(c, fiber.StatusInternalServerError, "handler.GetShopeeReturns", err)
+	}
+	return response.SuccessResponse(c, "handler.GetShopeeReturns", res)
+}
+
+func (d *shopeeReturnHandler) GetShopeeReturnsByOrderSN(c *fiber.Ctx) error {
+	shopID := c.Params("shopeeShopID")
+	orderSN := c.Params("orderSN")
+	if shopID == "" || orderSN == "" {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.GetShopeeReturnsByOrderSN", "shopeeShopID and orderSN are required")
+	}
+
+	res, err := d.Service.GetShopeeReturns(c.Context(), shopID, &IReqShopeeReturnFilter{OrderSN: orderSN})
+	if err != nil {
+		return response.ErrorResponse(c, fiber.StatusInternalServerError, "handler.GetShopeeReturnsByOrderSN", err)
+	}
+	return response.SuccessResponse(c, "handler.GetShopeeReturnsByOrderSN", res)
+}
+
+func (d *shopeeReturnHandler) GetShopeeReturnByReturnSN(c *fiber.Ctx) error {
+	shopID := c.Params("shopeeShopID")
+	returnSN := c.Params("returnSN")
+	if shopID == "" || returnSN == "" {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.GetShopeeReturnByReturnSN", "shopeeShopID and returnSN are required")
+	}
+
+	res, err := d.Service.GetShopeeReturnByReturnSN(c.Context(), shopID, returnSN)
+	if err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.GetShopeeReturnByReturnSN", err)
+	}
+	return response.SuccessResponse(c, "handler.GetShopeeReturnByReturnSN", res)
+}
+
+func (d *shopeeReturnHandler) PostShopeeReturnAccept(c *fiber.Ctx) error {
+	shopID := c.Params("shopeeShopID")
+	returnSN := c.Params("returnSN")
+	if shopID == "" || returnSN == "" {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostShopeeReturnAccept", "shopeeShopID and returnSN are required")
+	}
+
+	res, err := d.Service.AcceptShopeeReturn(c.Context(), shopID, returnSN, actor(c))
+	if err != nil {
+		return response.ErrorResponse(c, returnErrorStatus(err), "handler.PostShopeeReturnAccept", err)
+	}
+	return response.SuccessResponse(c, "handler.PostShopeeReturnAccept", res)
+}
+
+func (d *shopeeReturnHandler) PostShopeeReturnOffer(c *fiber.Ctx) error {
+	shopID := c.Params("shopeeShopID")
+	returnSN := c.Params("returnSN")
+	if shopID == "" || returnSN == "" {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostShopeeReturnOffer", "shopeeShopID and returnSN are required")
+	}
+
+	var reqBody IReqShopeeReturnOffer
+	if err := c.BodyParser(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostShopeeReturnOffer", "invalid body")
+	}
+	if err := d.Validate.Struct(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostShopeeReturnOffer", err)
+	}
+
+	res, err := d.Service.OfferShopeeReturn(c.Context(), shopID, returnSN, actor(c), &reqBody)
+	if err != nil {
+		return response.ErrorResponse(c, returnErrorStatus(err), "handler.PostShopeeReturnOffer", err)
+	}
+	return response.SuccessResponse(c, "handler.PostShopeeReturnOffer", res)
+}
+
+// multipart : field "images", jpeg / png only
+func (d *shopeeReturnHandler) PostShopeeReturnEvidence(c *fiber.Ctx) error {
+	shopID := c.Params("shopeeShopID")
+	returnSN := c.Params("returnSN")
+	if shopID == "" || returnSN == "" {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostShopeeReturnEvidence", "shopeeShopID and returnSN are required")
+	}
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostShopeeReturnEvidence", "multipart form with images is required")
+	}
+
+	files := []ShopeeReturnFile{}
+	for _, fh := range form.File["images"] {
+		contentType := fh.Header.Get(fiber.HeaderContentType)
+		if contentType != "image/jpeg" && contentType != "image/png" {
+			return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostShopeeReturnEvidence", "only jpeg and png images are accepted")
+		}
+
+		f, err := fh.Open()
+		if err != nil {
+			return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostShopeeReturnEvidence", err)
+		}
+		data, err := io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostShopeeReturnEvidence", err)
+		}
+		files = append(files, ShopeeReturnFile{Filename: fh.Filename, ContentType: contentType, Data: data})
+	}
+
+	res, err := d.Service.UploadShopeeReturnEvidence(c.Context(), shopID, returnSN, actor(c), files)
+	if err != nil {
+		return response.ErrorResponse(c, returnErrorStatus(err), "handler.PostShopeeReturnEvidence", err)
+	}
+	return response.SuccessResponse(c, "handler.PostShopeeReturnEvidence", res)
+}
+
+func (d *shopeeReturnHandler) PostShopeeReturnDispute(c *fiber.Ctx) error {
+	shopID := c.Params("shopeeShopID")
+	returnSN := c.Params("returnSN")
+	if shopID == "" || returnSN == "" {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostShopeeReturnDispute", "shopeeShopID and returnSN are required")
+	}
+
+	var reqBody IReqShopeeReturnDispute
+	if err := c.BodyParser(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostShopeeReturnDispute", "invalid body")
+	}
+	if err := d.Validate.Struct(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostShopeeReturnDispute", err)
+	}
+
+	res, err := d.Service.DisputeShopeeReturn(c.Context(), shopID, returnSN, actor(c), &reqBody)
+	if err != nil {
+		return response.ErrorResponse(c, returnErrorStatus(err), "handler.PostShopeeReturnDispute", err)
+	}
+	return response.SuccessResponse(c, "handler.PostShopeeReturnDispute", res)
+}
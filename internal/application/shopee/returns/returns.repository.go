@@ -0,0 +1,398 @@
+package returns
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"go.uber.org/zap"
+
+	"ecommerce/internal/adapter/dto"
+	"ecommerce/internal/pkg"
+)
+
+type ShopeeReturnActionEnum string
+
+const (
+	RETURN_ACTION_ACCEPT   ShopeeReturnActionEnum = "ACCEPT"
+	RETURN_ACTION_OFFER    ShopeeReturnActionEnum = "OFFER"
+	RETURN_ACTION_DISPUTE  ShopeeReturnActionEnum = "DISPUTE"
+	RETURN_ACTION_EVIDENCE ShopeeReturnActionEnum = "EVIDENCE"
+)
+
+type ShopeeReturnItemModel struct {
+	ItemID       int64   `bson:"item_id"`
+	ModelID      int64   `bson:"model_id"`
+	Name         string  `bson:"name"`
+	ItemSKU      string  `bson:"item_sku"`
+	VariationSKU string  `bson:"variation_sku"`
+	Amount       int64   `bson:"amount"`
+	ItemPrice    float64 `bson:"item_price"`
+	RefundAmount float64 `bson:"refund_amount"`
+}
+
+// file kept in blob store, URL is the Shopee copy used by /returns/dispute
+type ShopeeReturnEvidenceModel struct {
+	BlobKey     string    `bson:"blob_key"`
+	Filename    string    `bson:"filename"`
+	ContentType string    `bson:"content_type"`
+	URL         string    `bson:"url"`
+	Thumbnail   string    `bson:"thumbnail"`
+	UploadedBy  string    `bson:"uploaded_by"`
+	UploadedAt  time.Time `bson:"uploaded_at"`
+}
+
+// operator actions, in order : Error is set when Shopee rejected it
+type ShopeeReturnActionModel struct {
+	Action ShopeeReturnActionEnum `bson:"action"`
+	By     string                 `bson:"by"`
+	At     time.Time              `bson:"at"`
+	Detail string                 `bson:"detail"`
+	Error  string                 `bson:"error"`
+}
+
+// one document per return_sn, linked to shopee_order by order_sn
+type ShopeeReturnModel struct {
+	ID                     bson.ObjectID               `bson:"_id"`
+	TenantID               string                      `bson:"tenant_id,omitempty"` // tenant of the shop : see pkg.TenantFilter
+	ShopID                 string                      `bson:"shop_id"`
+	ReturnSN               string                      `bson:"return_sn"`
+	OrderSN                string                      `bson:"order_sn"`
+	Status                 dto.IEnumShopeeReturnStatus `bson:"status"`
+	Reason                 string                      `bson:"reason"`
+	TextReason             string                      `bson:"text_reason"`
+	RefundAmount           float64                     `bson:"refund_amount"`
+	AmountBeforeDiscount   float64                     `bson:"amount_before_discount"`
+	Currency               string                      `bson:"currency"`
+	NeedsLogistics         bool                        `bson:"needs_logistics"`
+	TrackingNumber         string                      `bson:"tracking_number"`
+	BuyerUsername          string                      `bson:"buyer_username"`
+	BuyerImages            []string                    `bson:"buyer_images"`
+	Items                  []ShopeeReturnItemModel     `bson:"items"`
+	NegotiationStatus      string                      `bson:"negotiation_status"`
+	SellerProofStatus      string                      `bson:"seller_proof_status"`
+	SellerEvidenceDeadline time.Time                   `bson:"seller_evidence_deadline"`
+	DueDate                time.Time                   `bson:"due_date"`
+	CreateTime             time.Time                   `bson:"create_time"`
+	UpdateTime             time.Time                   `bson:"update_time"`
+
+	// owned by this service, never overwritten by sync
+	Evidence []ShopeeReturnEvidenceModel `bson:"evidence"`
+	Actions  []ShopeeReturnActionModel   `bson:"actions"`
+
+	SyncedAt  time.Time `bson:"synced_at"`
+	CreatedAt time.Time `bson:"created_at"`
+	UpdatedAt time.Time `bson:"updated_at"`
+}
+
+type ShopeeReturnItemEntity struct {
+	ItemID       int64   `json:"item_id"`
+	ModelID      int64   `json:"model_id"`
+	Name         string  `json:"name"`
+	ItemSKU      string  `json:"item_sku"`
+	VariationSKU string  `json:"variation_sku"`
+	Amount       int64   `json:"amount"`
+	ItemPrice    float64 `json:"item_price"`
+	RefundAmount float64 `json:"refund_amount"`
+}
+
+type ShopeeReturnEvidenceEntity struct {
+	BlobKey     string    `json:"blob_key"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type"`
+	URL         string    `json:"url"`
+	Thumbnail   string    `json:"thumbnail"`
+	UploadedBy  string    `json:"uploaded_by"`
+	UploadedAt  time.Time `json:"uploaded_at"`
+}
+
+type ShopeeReturnActionEntity struct {
+	Action ShopeeReturnActionEnum `json:"action"`
+	By     string                 `json:"by"`
+	At     time.Time              `json:"at"`
+	Detail string                 `json:"detail"`
+	Error  string                 `json:"error"`
+}
+
+type ShopeeReturnEntity struct {
+	ID                     string                       `json:"id"`
+	ShopID                 string                       `json:"shop_id"`
+	ReturnSN               string                       `json:"return_sn"`
+	OrderSN                string                       `json:"order_sn"`
+	Status                 dto.IEnumShopeeReturnStatus  `json:"status"`
+	Reason                 string                       `json:"reason"`
+	TextReason             string                       `json:"text_reason"`
+	RefundAmount           float64                      `json:"refund_amount"`
+	AmountBeforeDiscount   float64                      `json:"amount_before_discount"`
+	Currency               string                       `json:"currency"`
+	NeedsLogistics         bool                         `json:"needs_logistics"`
+	TrackingNumber         string                       `json:"tracking_number"`
+	BuyerUsername          string                       `json:"buyer_username"`
+	BuyerImages            []string                     `json:"buyer_images"`
+	Items                  []ShopeeReturnItemEntity     `json:"items"`
+	NegotiationStatus      string                       `json:"negotiation_status"`
+	SellerProofStatus      string                       `json:"seller_proof_status"`
+	SellerEvidenceDeadline time.Time                    `json:"seller_evidence_deadline"`
+	DueDate                time.Time                    `json:"due_date"`
+	CreateTime             time.Time                    `json:"create_time"`
+	UpdateTime             time.Time                    `json:"update_time"`
+	Evidence               []ShopeeReturnEvidenceEntity `json:"evidence"`
+	Actions                []ShopeeReturnActionEntity   `json:"actions"`
+
+	SyncedAt  time.Time `json:"synced_at"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func ShopeeReturnModelToEntity(model *ShopeeReturnModel) *ShopeeReturnEntity {
+	items := make([]ShopeeReturnItemEntity, len(model.Items))
+	for i, it := range model.Items {
+		items[i] = ShopeeReturnItemEntity(it)
+	}
+	evidence := make([]ShopeeReturnEvidenceEntity, len(model.Evidence))
+	for i, ev := range model.Evidence {
+		evidence[i] = ShopeeReturnEvidenceEntity(ev)
+	}
+	actions := make([]ShopeeReturnActionEntity, len(model.Actions))
+	for i, ac := range model.Actions {
+		actions[i] = ShopeeReturnActionEntity(ac)
+	}
+
+	return &ShopeeReturnEntity{
+		ID:                     model.ID.Hex(),
+		ShopID:                 model.ShopID,
+		ReturnSN:               model.ReturnSN,
+		OrderSN:                model.OrderSN,
+		Status:                 model.Status,
+		Reason:                 model.Reason,
+		TextReason:             model.TextReason,
+		RefundAmount:           model.RefundAmount,
+		AmountBeforeDiscount:   model.AmountBeforeDiscount,
+		Currency:               model.Currency,
+		NeedsLogistics:         model.NeedsLogistics,
+		TrackingNumber:         model.TrackingNumber,
+		BuyerUsername:          model.BuyerUsername,
+		BuyerImages:            model.BuyerImages,
+		Items:                  items,
+		NegotiationStatus:      model.NegotiationStatus,
+		SellerProofStatus:      model.SellerProofStatus,
+		SellerEvidenceDeadline: model.SellerEvidenceDeadline,
+		DueDate:                model.DueDate,
+		CreateTime:             model.CreateTime,
+		UpdateTime:             model.UpdateTime,
+		Evidence:               evidence,
+		Actions:                actions,
+		SyncedAt:               model.SyncedAt,
+		CreatedAt:              model.CreatedAt,
+		UpdatedAt:              model.UpdatedAt,
+	}
+}
+
+// ShopeeReturnDetailToModel : Shopee fields only, Evidence / Actions stay empty
+func ShopeeReturnDetailToModel(shopID string, d *dto.IResReturnDetail) *ShopeeReturnModel {
+	items := make([]ShopeeReturnItemModel, len(d.Item))
+	for i, it := range d.Item {
+		items[i] = ShopeeReturnItemModel{
+			ItemID:       it.ItemID,
+			ModelID:      it.ModelID,
+			Name:         it.Name,
+			ItemSKU:      it.ItemSKU,
+			VariationSKU: it.VariationSKU,
+			Amount:       it.Amount,
+			ItemPrice:    it.ItemPrice,
+			RefundAmount: it.RefundAmount,
+		}
+	}
+
+	return &ShopeeReturnModel{
+		ShopID:                 shopID,
+		ReturnSN:               d.ReturnSN,
+		OrderSN:                d.OrderSN,
+		Status:                 d.Status,
+		Reason:                 d.Reason,
+		TextReason:             d.TextReason,
+		RefundAmount:           d.RefundAmount,
+		AmountBeforeDiscount:   d.AmountBeforeDiscount,
+		Currency:               d.Currency,
+		NeedsLogistics:         d.NeedsLogistics,
+		TrackingNumber:         d.TrackingNumber,
+		BuyerUsername:          d.User.Username,
+		BuyerImages:            d.Image,
+		Items:                  items,
+		NegotiationStatus:      d.Negotiation.NegotiationStatus,
+		SellerProofStatus:      d.SellerProof.SellerProofStatus,
+		SellerEvidenceDeadline: unixOrZero(d.SellerProof.SellerEvidenceDeadline),
+		DueDate:                unixOrZero(d.DueDate),
+		CreateTime:             unixOrZero(d.CreateTime),
+		UpdateTime:             unixOrZero(d.UpdateTime),
+	}
+}
+
+func unixOrZero(sec int64) time.Time {
+	if sec <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0)
+}
+
+// empty fields are not filtered
+type ShopeeReturnFilter struct {
+	ShopID  string
+	OrderSN string
+	Status  dto.IEnumShopeeReturnStatus
+}
+
+type ShopeeReturnRepository interface {
+	InitRepository() error
+	GetShopeeReturnByReturnSN(ctx context.Context, returnSN string) (*ShopeeReturnModel, error)
+	// newest update_time first
+	GetShopeeReturns(ctx context.Context, filter *ShopeeReturnFilter) ([]ShopeeReturnModel, error)
+	// upsert by return_sn : Shopee fields only, keeps evidence / actions / created_at
+	UpsertShopeeReturn(ctx context.Context, ret *ShopeeReturnModel) (*ShopeeReturnModel, error)
+	AddShopeeReturnEvidence(ctx context.Context, returnSN string, evidence []ShopeeReturnEvidenceModel) (*ShopeeReturnModel, error)
+	AddShopeeReturnAction(ctx context.Context, returnSN string, action *ShopeeReturnActionModel) (*ShopeeReturnModel, error)
+}
+
+type shopeeReturnRepository struct {
+	Logger *zap.Logger
+	DB     *mongo.Collection
+}
+
+func NewShopeeReturnRepository(db *mongo.Collection, log *zap.Logger) ShopeeReturnRepository {
+	return &shopeeReturnRepository{Logger: log, DB: db}
+}
+
+func (r *shopeeReturnRepository) InitRepository() error {
+	indexs := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "return_sn", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "order_sn", Value: 1}}},
+		{Keys: bson.D{{Key: "shop_id", Value: 1}, {Key: "status", Value: 1}, {Key: "update_time", Value: -1}}},
+	}
+	if _, err := r.DB.Indexes().CreateMany(context.TODO(), indexs); err != nil {
+		r.Logger.Error("error creating index", zap.Error(err))
+		return errors.New("ShopeeReturnRepository.InitRepository: failed create indexs")
+	}
+	r.Logger.Info("ShopeeReturnRepository.InitRepository: index created")
+	return nil
+}
+
+func (r *shopeeReturnRepository) GetShopeeReturnByReturnSN(ctx context.Context, returnSN string) (*ShopeeReturnModel, error) {
+	var ret ShopeeReturnModel
+	if err := r.DB.FindOne(ctx, pkg.TenantFilter(ctx, bson.M{"return_sn": returnSN})).Decode(&ret); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, errors.New("return not found")
+		}
+		return nil, err
+	}
+	return &ret, nil
+}
+
+func (r *shopeeReturnRepository) GetShopeeReturns(ctx context.Context, filter *ShopeeReturnFilter) ([]ShopeeReturnModel, error) {
+	query := pkg.TenantFilter(ctx, bson.M{})
+	if filter.ShopID != "" {
+		query["shop_id"] = filter.ShopID
+	}
+	if filter.OrderSN != "" {
+		query["order_sn"] = filter.OrderSN
+	}
+	if filter.Status != "" {
+		query["status"] = filter.Status
+	}
+	opt := options.Find().SetSort(bson.D{{Key: "update_time", Value: -1}})
+
+	cursor, err := r.DB.Find(ctx, query, opt)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	res := []ShopeeReturnModel{}
+	if err := cursor.All(ctx, &res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (r *shopeeReturnRepository) UpsertShopeeReturn(ctx context.Context, ret *ShopeeReturnModel) (*ShopeeReturnModel, error) {
+	now := time.Now()
+	onInsert := bson.M{
+		"_id":        bson.NewObjectID(),
+		"evidence":   []ShopeeReturnEvidenceModel{},
+		"actions":    []ShopeeReturnActionModel{},
+		"created_at": now,
+	}
+	tenantID, err := pkg.TenantStamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if tenantID != "" {
+		onInsert["tenant_id"] = tenantID
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"shop_id":                  ret.ShopID,
+			"order_sn":                 ret.OrderSN,
+			"status":                   ret.Status,
+			"reason":                   ret.Reason,
+			"text_reason":              ret.TextReason,
+			"refund_amount":            ret.RefundAmount,
+			"amount_before_discount":   ret.AmountBeforeDiscount,
+			"currency":                 ret.Currency,
+			"needs_logistics":          ret.NeedsLogistics,
+			"tracking_number":          ret.TrackingNumber,
+			"buyer_username":           ret.BuyerUsername,
+			"buyer_images":             ret.BuyerImages,
+			"items":                    ret.Items,
+			"negotiation_status":       ret.NegotiationStatus,
+			"seller_proof_status":      ret.SellerProofStatus,
+			"seller_evidence_deadline": ret.SellerEvidenceDeadline,
+			"due_date":                 ret.DueDate,
+			"create_time":              ret.CreateTime,
+			"update_time":              ret.UpdateTime,
+			"synced_at":                now,
+			"updated_at":               now,
+		},
+		"$setOnInsert": onInsert,
+	}
+
+	opt := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	var saved ShopeeReturnModel
+	if err := r.DB.FindOneAndUpdate(ctx, pkg.TenantFilter(ctx, bson.M{"return_sn": ret.ReturnSN}), update, opt).Decode(&saved); err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+func (r *shopeeReturnRepository) AddShopeeReturnEvidence(ctx context.Context, returnSN string, evidence []ShopeeReturnEvidenceModel) (*ShopeeReturnModel, error) {
+	update := bson.M{
+		"$push": bson.M{"evidence": bson.M{"$each": evidence}},
+		"$set":  bson.M{"updated_at": time.Now()},
+	}
+	return r.findOneAndUpdate(ctx, returnSN, update)
+}
+
+func (r *shopeeReturnRepository) AddShopeeReturnAction(ctx context.Context, returnSN string, action *ShopeeReturnActionModel) (*ShopeeReturnModel, error) {
+	update := bson.M{
+		"$push": bson.M{"actions": action},
+		"$set":  bson.M{"updated_at": time.Now()},
+	}
+	return r.findOneAndUpdate(ctx, returnSN, update)
+}
+
+func (r *shopeeReturnRepository) findOneAndUpdate(ctx context.Context, returnSN string, update bson.M) (*ShopeeReturnModel, error) {
+	opt := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var saved ShopeeReturnModel
+	if err := r.DB.FindOneAndUpdate(ctx, pkg.TenantFilter(ctx, bson.M{"return_sn": returnSN}), update, opt).Decode(&saved); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, errors.New("return not found")
+		}
+		return nil, err
+	}
+	return &saved, nil
+}
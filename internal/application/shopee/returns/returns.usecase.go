@@ -0,0 +1,345 @@
+package returns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"ecommerce/internal/adapter"
+	"ecommerce/internal/adapter/dto"
+	"ecommerce/internal/adapter/storage"
+	"ecommerce/internal/application/shopee"
+	"ecommerce/internal/env"
+)
+
+var ErrReturnClosed = errors.New("return is already closed")
+
+type IShopeeReturnService interface {
+	// get_return_list over [from, to) by update_time, each return upserted and linked to its order
+	SyncShopeeReturnsByShopID(ctx context.Context, shopID string, from time.Time, to time.Time) (*ShopeeReturnSyncEntity, error)
+	GetShopeeReturns(ctx context.Context, shopID string, filter *IReqShopeeReturnFilter) ([]ShopeeReturnEntity, error)
+	// refreshed from get_return_detail, with the original order
+	GetShopeeReturnByReturnSN(ctx context.Context, shopID string, returnSN string) (*ShopeeReturnDetailEntity, error)
+
+	AcceptShopeeReturn(ctx context.Context, shopID string, returnSN string, actor string) (*ShopeeReturnDetailEntity, error)
+	OfferShopeeReturn(ctx context.Context, shopID string, returnSN string, actor string, req *IReqShopeeReturnOffer) (*ShopeeReturnDetailEntity, error)
+	// store files in blob store + convert_image, used by the next dispute
+	UploadShopeeReturnEvidence(ctx context.Context, shopID string, returnSN string, actor string, files []ShopeeReturnFile) (*ShopeeReturnEntity, error)
+	DisputeShopeeReturn(ctx context.Context, shopID string, returnSN string, actor string, req *IReqShopeeReturnDispute) (*ShopeeReturnDetailEntity, error)
+}
+
+type ShopeeReturnFile struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+type ShopeeReturnSyncEntity struct {
+	ShopID   string    `json:"shop_id"`
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+	Synced   int       `json:"synced"`
+	Unlinked []string  `json:"unlinked"` // return_sn whose order could not be synced
+}
+
+type ShopeeReturnDetailEntity struct {
+	Return *ShopeeReturnEntity       `json:"return"`
+	Order  *shopee.ShopeeOrderEntity `json:"order"`
+}
+
+type shopeeReturnService struct {
+	Config *env.Config
+	Logger *zap.Logger
+
+	ShopeeAdapter          adapter.IShopeeService
+	ShopeeService          shopee.IShopeeService
+	ShopeeOrderRepository  shopee.ShopeeOrderRepository
+	ShopeeReturnRepository ShopeeReturnRepository
+	BlobStore              storage.IBlobStore
+}
+
+func NewShopeeReturnService(cfg *env.Config, logger *zap.Logger,
+	shopeeAdapter adapter.IShopeeService,
+	shopeeService shopee.IShopeeService,
+	shopeeOrder shopee.ShopeeOrderRepository,
+	shopeeReturn ShopeeReturnRepository,
+	blob storage.IBlobStore,
+) IShopeeReturnService {
+	return &shopeeReturnService{
+		Config:                 cfg,
+		Logger:                 logger,
+		ShopeeAdapter:          shopeeAdapter,
+		ShopeeService:          shopeeService,
+		ShopeeOrderRepository:  shopeeOrder,
+		ShopeeReturnRepository: shopeeReturn,
+		BlobStore:              blob,
+	}
+}
+
+func (s *shopeeReturnService) SyncShopeeReturnsByShopID(ctx context.Context, shopID string, from time.Time, to time.Time) (*ShopeeReturnSyncEntity, error) {
+	if !from.Before(to) {
+		return nil, errors.New("from must be before to")
+	}
+	params, err := s.ShopeeService.GetShopeeAdapterParamsByShopID(ctx, shopID)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &ShopeeReturnSyncEntity{ShopID: shopID, From: from, To: to, Unlinked: []string{}}
+	for winFrom := from; winFrom.Before(to); winFrom = winFrom.Add(adapter.ShopeeReturnListMaxRange) {
+		winTo := winFrom.Add(adapter.ShopeeReturnListMaxRange)
+		if winTo.After(to) {
+			winTo = to
+		}
+
+		for page := int32(0); ; page++ {
+			list, err := s.ShopeeAdapter.GetReturnList(ctx, params, &dto.IOptionShopeeReturnListQuery{
+				PageNo:         page,
+				UpdateTimeFrom: winFrom.Unix(),
+				UpdateTimeTo:   winTo.Unix(),
+			})
+			if err != nil {
+				s.Logger.Error("usecase.SyncShopeeReturnsByShopID : GetReturnList", zap.String("shop_id", shopID), zap.Error(err))
+				return res, err
+			}
+
+			for i := range list.Return {
+				ret, err := s.ShopeeReturnRepository.UpsertShopeeReturn(ctx, ShopeeReturnDetailToModel(shopID, &list.Return[i]))
+				if err != nil {
+					return res, err
+				}
+				res.Synced++
+				if _, err := s.getShopeeOrder(ctx, shopID, ret.OrderSN); err != nil {
+					s.Logger.Warn("usecase.SyncShopeeReturnsByShopID : order not linked", zap.String("return_sn", ret.ReturnSN), zap.String("order_sn", ret.OrderSN), zap.Error(err))
+					res.Unlinked = append(res.Unlinked, ret.ReturnSN)
+				}
+			}
+
+			if !list.More {
+				break
+			}
+		}
+	}
+	return res, nil
+}
+
+func (s *shopeeReturnService) GetShopeeReturns(ctx context.Context, shopID string, filter *IReqShopeeReturnFilter) ([]ShopeeReturnEntity, error) {
+	list, err := s.ShopeeReturnRepository.GetShopeeReturns(ctx, &ShopeeReturnFilter{
+		ShopID:  shopID,
+		OrderSN: filter.OrderSN,
+		Status:  filter.Status,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]ShopeeReturnEntity, len(list))
+	for i := range list {
+		res[i] = *ShopeeReturnModelToEntity(&list[i])
+	}
+	return res, nil
+}
+
+func (s *shopeeReturnService) GetShopeeReturnByReturnSN(ctx context.Context, shopID string, returnSN string) (*ShopeeReturnDetailEntity, error) {
+	params, err := s.ShopeeService.GetShopeeAdapterParamsByShopID(ctx, shopID)
+	if err != nil {
+		return nil, err
+	}
+	return s.refreshReturn(ctx, params, shopID, returnSN)
+}
+
+func (s *shopeeReturnService) AcceptShopeeReturn(ctx context.Context, shopID string, returnSN string, actor string) (*ShopeeReturnDetailEntity, error) {
+	params, ret, err := s.getOpenReturn(ctx, shopID, returnSN)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.ShopeeAdapter.ConfirmReturn(ctx, params, &dto.IBConfirmReturn{ReturnSN: ret.ReturnSN})
+	s.recordAction(ctx, returnSN, RETURN_ACTION_ACCEPT, actor, fmt.Sprintf("refund %.2f %s", ret.RefundAmount, ret.Currency), err)
+	if err != nil {
+		return nil, err
+	}
+	return s.refreshReturn(ctx, params, shopID, returnSN)
+}
+
+func (s *shopeeReturnService) OfferShopeeReturn(ctx context.Context, shopID string, returnSN string, actor string, req *IReqShopeeReturnOffer) (*ShopeeReturnDetailEntity, error) {
+	params, ret, err := s.getOpenReturn(ctx, shopID, returnSN)
+	if err != nil {
+		return nil, err
+	}
+	if req.ProposedAdjustedRefundAmount > ret.RefundAmount {
+		return nil, fmt.Errorf("proposed refund %.2f is more than the requested %.2f", req.ProposedAdjustedRefundAmount, ret.RefundAmount)
+	}
+
+	_, err = s.ShopeeAdapter.OfferReturn(ctx, params, &dto.IBOfferReturn{
+		ReturnSN:                     ret.ReturnSN,
+		ProposedSolution:             req.ProposedSolution,
+		ProposedAdjustedRefundAmount: req.ProposedAdjustedRefundAmount,
+	})
+	s.recordAction(ctx, returnSN, RETURN_ACTION_OFFER, actor, fmt.Sprintf("%s %.2f", req.ProposedSolution, req.ProposedAdjustedRefundAmount), err)
+	if err != nil {
+		return nil, err
+	}
+	return s.refreshReturn(ctx, params, shopID, returnSN)
+}
+
+func (s *shopeeReturnService) UploadShopeeReturnEvidence(ctx context.Context, shopID string, returnSN string, actor string, files []ShopeeReturnFile) (*ShopeeReturnEntity, error) {
+	if len(files) == 0 {
+		return nil, errors.New("no evidence file")
+	}
+	if len(files) > adapter.ShopeeReturnMaxImage {
+		return nil, fmt.Errorf("max %d images per upload", adapter.ShopeeReturnMaxImage)
+	}
+	params, ret, err := s.getOpenReturn(ctx, shopID, returnSN)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	uploads := make([]dto.IFileUpload, len(files))
+	evidence := make([]ShopeeReturnEvidenceModel, len(files))
+	for i, f := range files {
+		key := evidenceBlobKey(ret.ReturnSN, now, i, f.Filename)
+		if err := s.BlobStore.Put(ctx, key, f.Data, f.ContentType); err != nil {
+			return nil, err
+		}
+		uploads[i] = dto.IFileUpload{Filename: f.Filename, Data: f.Data}
+		evidence[i] = ShopeeReturnEvidenceModel{
+			BlobKey:     key,
+			Filename:    f.Filename,
+			ContentType: f.ContentType,
+			UploadedBy:  actor,
+			UploadedAt:  now,
+		}
+	}
+
+	images, err := s.ShopeeAdapter.ConvertReturnImage(ctx, params, uploads)
+	if err != nil {
+		// files stay in blob store : the evidence is kept even if Shopee refused it
+		s.recordAction(ctx, returnSN, RETURN_ACTION_EVIDENCE, actor, fmt.Sprintf("%d file(s)", len(files)), err)
+		return nil, err
+	}
+	for i := range evidence {
+		if i < len(images) {
+			evidence[i].URL = images[i].URL
+			evidence[i].Thumbnail = images[i].Thumbnail
+		}
+	}
+
+	saved, err := s.ShopeeReturnRepository.AddShopeeReturnEvidence(ctx, returnSN, evidence)
+	if err != nil {
+		return nil, err
+	}
+	return ShopeeReturnModelToEntity(saved), nil
+}
+
+func (s *shopeeReturnService) DisputeShopeeReturn(ctx context.Context, shopID string, returnSN string, actor string, req *IReqShopeeReturnDispute) (*ShopeeReturnDetailEntity, error) {
+	params, ret, err := s.getOpenReturn(ctx, shopID, returnSN)
+	if err != nil {
+		return nil, err
+	}
+
+	images := []string{}
+	for _, ev := range ret.Evidence {
+		if ev.URL != "" {
+			images = append(images, ev.URL)
+		}
+	}
+	if len(images) == 0 {
+		return nil, errors.New("upload evidence before disputing the return")
+	}
+
+	_, err = s.ShopeeAdapter.DisputeReturn(ctx, params, &dto.IBDisputeReturn{
+		ReturnSN:          ret.ReturnSN,
+		Email:             req.Email,
+		DisputeReason:     req.DisputeReason,
+		DisputeTextReason: req.DisputeTextReason,
+		Images:            images,
+	})
+	s.recordAction(ctx, returnSN, RETURN_ACTION_DISPUTE, actor, req.DisputeTextReason, err)
+	if err != nil {
+		return nil, err
+	}
+	return s.refreshReturn(ctx, params, shopID, returnSN)
+}
+
+// getOpenReturn : fresh copy of the return, ErrReturnClosed when no action is possible anymore
+func (s *shopeeReturnService) getOpenReturn(ctx context.Context, shopID string, returnSN string) (*adapter.IReqShopeeAdapter, *ShopeeReturnModel, error) {
+	params, err := s.ShopeeService.GetShopeeAdapterParamsByShopID(ctx, shopID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	detail, err := s.ShopeeAdapter.GetReturnDetail(ctx, params, returnSN)
+	if err != nil {
+		return nil, nil, err
+	}
+	ret, err := s.ShopeeReturnRepository.UpsertShopeeReturn(ctx, ShopeeReturnDetailToModel(shopID, detail))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch ret.Status {
+	case dto.RETURN_ACCEPTED, dto.RETURN_CANCELLED, dto.RETURN_CLOSED:
+		return nil, nil, ErrReturnClosed
+	}
+	return params, ret, nil
+}
+
+func (s *shopeeReturnService) refreshReturn(ctx context.Context, params *adapter.IReqShopeeAdapter, shopID string, returnSN string) (*ShopeeReturnDetailEntity, error) {
+	detail, err := s.ShopeeAdapter.GetReturnDetail(ctx, params, returnSN)
+	if err != nil {
+		return nil, err
+	}
+	ret, err := s.ShopeeReturnRepository.UpsertShopeeReturn(ctx, ShopeeReturnDetailToModel(shopID, detail))
+	if err != nil {
+		return nil, err
+	}
+
+	res := &ShopeeReturnDetailEntity{Return: ShopeeReturnModelToEntity(ret)}
+	if res.Order, err = s.getShopeeOrder(ctx, shopID, ret.OrderSN); err != nil {
+		s.Logger.Warn("usecase.refreshReturn : order not linked", zap.String("return_sn", returnSN), zap.String("order_sn", ret.OrderSN), zap.Error(err))
+	}
+	return res, nil
+}
+
+// recordAction : audit trail, a failed write is only logged
+func (s *shopeeReturnService) recordAction(ctx context.Context, returnSN string, action ShopeeReturnActionEnum, actor string, detail string, actionErr error) {
+	rec := &ShopeeReturnActionModel{Action: action, By: actor, At: time.Now(), Detail: detail}
+	if actionErr != nil {
+		rec.Error = actionErr.Error()
+	}
+	if _, err := s.ShopeeReturnRepository.AddShopeeReturnAction(ctx, returnSN, rec); err != nil {
+		s.Logger.Error("usecase.recordAction : AddShopeeReturnAction", zap.String("return_sn", returnSN), zap.Error(err))
+	}
+}
+
+func (s *shopeeReturnService) getShopeeOrder(ctx context.Context, shopID string, orderSN string) (*shopee.ShopeeOrderEntity, error) {
+	order, err := s.ShopeeOrderRepository.GetShopeeOrderByOrderSN(ctx, orderSN)
+	if err != nil {
+		if _, syncErr := s.ShopeeService.SyncShopeeOrderByOrderSN(ctx, shopID, []string{orderSN}); syncErr != nil {
+			return nil, syncErr
+		}
+		if order, err = s.ShopeeOrderRepository.GetShopeeOrderByOrderSN(ctx, orderSN); err != nil {
+			return nil, err
+		}
+	}
+	if order.ShopID != "" && order.ShopID != shopID {
+		return nil, errors.New("OrderSN not found")
+	}
+	return order, nil
+}
+
+// shopee/return/<returnSN>/<unix>-<n>-<name>
+func evidenceBlobKey(returnSN string, at time.Time, n int, filename string) string {
+	name := strings.ReplaceAll(path.Base(filename), " ", "_")
+	if name == "." || name == "/" {
+		name = "evidence"
+	}
+	return fmt.Sprintf("shopee/return/%s/%d-%d-%s", returnSN, at.Unix(), n, name)
+}
@@ -2,6 +2,8 @@ package shopee
 
 import (
 	"ecommerce/internal/adapter/dto"
+	"ecommerce/internal/pkg"
+	"strconv"
 	"time"
 
 	"go.mongodb.org/mongo-driver/v2/bson"
@@ -14,6 +16,13 @@ type ShopeeAuthEntity struct {
 	RefreshToken string
 	ExpiredAt    time.Time
 
+	RefreshTokenExpiredAt time.Time
+	LastRefreshAt         time.Time
+	RefreshFailCount      int
+	LastRefreshError      string
+	NeedReauth            bool
+	AuthExpireAt          time.Time
+
 	// CreatedAt     time.Time
 	// CreatedBy     string
 
@@ -21,6 +30,30 @@ type ShopeeAuthEntity struct {
 	// ModifiedBy    string
 }
 
+func ShopeeAuthModelToEntity(model *ShopeeAuthModel) *ShopeeAuthEntity {
+	return &ShopeeAuthEntity{
+		PartnerID:    model.PartnerID,
+		ShopID:       model.ShopID,
+		AccessToken:  model.AccessToken,
+		RefreshToken: model.RefreshToken,
+		ExpiredAt:    model.ExpiredAt,
+
+		RefreshTokenExpiredAt: model.RefreshTokenExpiredAt,
+		LastRefreshAt:         model.LastRefreshAt,
+		RefreshFailCount:      model.RefreshFailCount,
+		LastRefreshError:      model.LastRefreshError,
+		NeedReauth:            model.NeedReauth,
+		AuthExpireAt:          model.AuthExpireAt,
+	}
+}
+
+// Redacted : copy safe for API answers, tokens masked
+func (e ShopeeAuthEntity) Redacted() *ShopeeAuthEntity {
+	e.AccessToken = pkg.RedactSecret(e.AccessToken)
+	e.RefreshToken = pkg.RedactSecret(e.RefreshToken)
+	return &e
+}
+
 type ShopeeShopListEntity struct {
 	ShopList []dto.IResAuthedShopList
 }
@@ -313,6 +346,8 @@ type ShopeeOrderDetailsEntity struct {
   BookingSN string
   AdvancePackage bool
   ReturnRequestDueDate time.Time
+  TrackingNumbers map[string]string
+  Escrow *ShopeeOrderEscrowEntity
   // payment_info : []object [only for BR]
   CreatedAt   time.Time
   CreatedBy   string
@@ -322,6 +357,38 @@ type ShopeeOrderDetailsEntity struct {
 }
 // [Component Struct.End]
 
+// same fields as ShopeeOrderEscrowModel
+type ShopeeOrderEscrowEntity struct {
+  EscrowAmount         float64   `json:"escrow_amount"`
+  BuyerTotalAmount     float64   `json:"buyer_total_amount"`
+  OriginalPrice        float64   `json:"original_price"`
+  SellerDiscount       float64   `json:"seller_discount"`
+  ShopeeDiscount       float64   `json:"shopee_discount"`
+  VoucherFromSeller    float64   `json:"voucher_from_seller"`
+  VoucherFromShopee    float64   `json:"voucher_from_shopee"`
+  Coins                float64   `json:"coins"`
+  CommissionFee        float64   `json:"commission_fee"`
+  ServiceFee           float64   `json:"service_fee"`
+  TransactionFee       float64   `json:"transaction_fee"`
+  BuyerPaidShippingFee float64   `json:"buyer_paid_shipping_fee"`
+  ActualShippingFee    float64   `json:"actual_shipping_fee"`
+  ShippingSubsidy      float64   `json:"shipping_subsidy"`
+  ReverseShippingFee   float64   `json:"reverse_shipping_fee"`
+  EscrowTax            float64   `json:"escrow_tax"`
+  SellerReturnRefund   float64   `json:"seller_return_refund"`
+  PayoutAmount         float64   `json:"payout_amount"`
+  ReleasedAt           time.Time `json:"released_at"`
+  SyncedAt             time.Time `json:"synced_at"`
+}
+
+func ShopeeOrderEscrowEntityToModel(enti *ShopeeOrderEscrowEntity) *ShopeeOrderEscrowModel {
+  if enti == nil {
+    return nil
+  }
+  m := ShopeeOrderEscrowModel(*enti)
+  return &m
+}
+
 // [Core Struct.Start]
 type ShopeeOrderEntity struct {
   ID          string `json:"id"`
@@ -466,6 +533,8 @@ func ShopeeOrderEntityToModel(enti *ShopeeOrderEntity) *ShopeeOrderModel{
     PrescriptionCheckStatus: enti.PrescriptionCheckStatus,
     AdvancePackage: enti.AdvancePackage,
     ReturnRequestDueDate: enti.ReturnRequestDueDate ,
+    TrackingNumbers: enti.TrackingNumbers,
+    Escrow: ShopeeOrderEscrowEntityToModel(enti.Escrow),
 
     CreatedAt: enti.CreatedAt,
     CreatedBy: enti.CreatedBy,
@@ -484,3 +553,166 @@ func ShopeeOrderEntityToModel(enti *ShopeeOrderEntity) *ShopeeOrderModel{
 // [Core Struct.Start]
 // [Core Struct.Emd]
 // ----------------- [DTO/Entity/Model] - End.Collection("Shop_rder")   ----------------
+
+// ShopeeOrderDetailDTOToEntity : /order/get_order_detail item -> Entity (ShopID is set by the caller)
+func ShopeeOrderDetailDTOToEntity(orderSN string, details *dto.IResOrderListWithDetails) *ShopeeOrderEntity {
+  // RecipientAddress 
+  recipient := ShopeeRecipientAddressEntity{
+        Name: details.RecipientAddress.Name,
+        Phone: details.RecipientAddress.Phone,
+        Town: details.RecipientAddress.Town,
+        District: details.RecipientAddress.District,
+        City: details.RecipientAddress.City,
+        State: details.RecipientAddress.State,
+        Region: details.RecipientAddress.Region,
+        ZipCode: details.RecipientAddress.Zipcode,
+        FullAddress: details.RecipientAddress.FullAddress,
+  }
+  // check item list
+  
+  var items []ShopeeItemListEntity
+  for _,i := range details.ItemList {
+    items = append(items,ShopeeItemListEntity{
+      ItemID: strconv.FormatInt(i.ItemID, 10),
+      ItemName: i.ItemName,
+      ItemSKU: i.ItemSKU,
+      ModelID: strconv.FormatInt(i.ModelID, 10),
+      ModelName: i.ModelName,
+      ModelSKU: i.ModelSKU,
+      ModelQualityPurchased: i.ModelQtyPurchased,
+      ModelOriginPrice: i.ModelOriginalPrice,
+      ModelDiscountedPrice: i.ModelDiscountedPrice,
+      WholeSale: i.Wholesale,
+      Weight: i.Weight,
+      AddOnDeal: i.AddOnDeal,
+      PromotionType: ShopeePromotionTypeEnum(i.PromotionType),
+      PromotionID: strconv.FormatInt(i.PromotionID,10),
+      OrderItemID: strconv.FormatInt(i.OrderItemID,10),
+      PromotionGroupID: strconv.FormatInt(int64(i.PromotionGroupID), 10),
+      ImageInfo: ShopeeImageInfoEntity{ImageURL: i.ImageInfo.ImageURL},
+      ProductLocationID: i.ProductLocationID,
+      IsPrescriptionItem: i.IsPrescriptionItem,
+      IsB2COwnedItem: i.IsB2COwnedItem,
+    } )
+  } 
+
+  var packages []ShopeePackageListEntity
+  for _,p :=  range details.PackageList {
+
+    var itemInPackage []ShopeeItemListInPackageListEntity 
+    for _, iip := range p.ItemList {
+      itemInPackage = append(itemInPackage, ShopeeItemListInPackageListEntity{
+        ItemID: strconv.FormatInt(iip.ItemID,10),
+        ModelID:strconv.FormatInt(iip.ModelID,10) ,
+        ModelQuantity: iip.ModelQuantity,
+        OrderItemID: strconv.FormatInt(iip.OrderItemID,10),
+        PromotionGroupID: strconv.FormatInt(int64(iip.PromotionGroupID),10) ,
+        ProductLocationID: iip.ProductLocationID,
+      })
+    }
+
+    packages = append(packages, ShopeePackageListEntity{
+      PackageNumber: p.PackageNumber,
+      LogisticsStatus: p.LogisticsStatus,
+      LogisticsChannelID: strconv.FormatInt(p.LogisticsChannelID, 10),
+      ShippingCarrier: p.ShippingCarrier,
+      AllowSelfDesignAWB: p.AllowSelfDesignAWB,
+      ItemList: itemInPackage,
+      ParcelChargeableWeight: p.ParcelChargeableWeight,
+      GroupShipmentID: p.SortingGroup,
+    })
+  }
+
+
+  // check before  create entity
+  return &ShopeeOrderEntity{
+    OrderSN: orderSN,
+    OrderStatus: ShopeeOrderStatusEnum(details.OrderStatus),
+    BookingSN: details.BookingSN,
+    ShopeeOrderDetailsEntity: ShopeeOrderDetailsEntity{
+      Region: details.Region,
+      Currency: details.Currency,
+      Cod: details.COD,
+      TotalAmount: details.TotalAmount,
+      PendingTerms: details.PendingTerms,
+
+      ShippingCarrier: details.ShippingCarrier,
+      PaymentMethod: details.PaymentMethod,
+      EstimatedShippingFee: details.EstimatedShippingFee,
+      MessageToSeller: details.MessageToSeller,
+      CreateTime: time.Unix(details.CreateTime, 0),
+      UpdateTime: time.Unix(details.UpdateTime, 0),
+      DaysToShip: details.DaysToShip,
+      ShipByDate: int(details.ShipByDate),
+      BuyerUserId: strconv.FormatInt(int64(details.BuyerUserID),10),
+      BuyerUsername: details.BuyerUsername,
+      RecipientAddress: recipient,
+      ActualShippingFee: details.ActualShippingFee,
+      GoodsToDeclare: details.GoodsToDeclare,
+      Note: details.Note,
+      NoteUpdateTime: time.Unix(details.NoteUpdateTime,0),
+
+      ItemList: items,
+
+      PayTime: time.Unix(details.PayTime,0),
+      DropShipper: details.Dropshipper,
+      DropShipperPhone: details.DropshipperPhone,
+      SplitUp: details.SplitUp,
+      BuyerCancelReason: details.BuyerCancelReason,
+      CancelBy: details.CancelBy,
+      CancelReason: details.CancelReason,
+      ActualShippingFeeConfirmed: details.ActualShippingFeeConfirmed,
+
+      BuyerCPFID: details.BuyerCPFID,
+      FulFillmentFlag: ShopeeFulfillmentFlagEnum(details.FulfillmentFlag),
+      PickupDoneTime: time.Unix(details.PickupDoneTime,0),
+      PackageList:packages ,
+      InvoiceData: ShopeeInvoiceDataEntity{
+        Number: details.InvoiceData.Number,
+        SeriesNumber: details.InvoiceData.SeriesNumber,
+        AccessKey: details.InvoiceData.AccessKey,
+        IssueDate: time.Unix(details.InvoiceData.IssueDate,0),
+        TotalValue: details.InvoiceData.TotalValue,
+        ProductTotalValue: details.InvoiceData.ProductsTotalValue,
+        TaxCode: details.InvoiceData.TaxCode,
+      },
+
+      CheckoutShippingCarrier: details.CheckoutShippingCarrier,
+      ReverseShippingFee: details.ReverseShippingFee,
+      OrderChargeableWeightGram: details.OrderChargeableWeight,
+      PrescriptionImages: details.PrescriptionImages,
+      PrescriptionCheckStatus: ShopeePrescriptionCheckStatusEnum(details.PrescriptionStatus),
+      BookingSN: details.BookingSN,
+      AdvancePackage: details.AdvancePackage,
+      ReturnRequestDueDate: time.Unix (details.ReturnRequestDueDate, 0),
+    },
+
+  }
+}
+
+// ----------------- [Entity] - Start.Collection("shopee_order_sync") ----------------
+type ShopeeOrderSyncEntity struct {
+  ShopID         string    `json:"shop_id"`
+  LastUpdateTime time.Time `json:"last_update_time"`
+  Status         ShopeeOrderSyncStatusEnum `json:"status"`
+
+  LastSyncStartedAt  time.Time `json:"last_sync_started_at"`
+  LastSyncFinishedAt time.Time `json:"last_sync_finished_at"`
+  LastSyncError      string    `json:"last_sync_error"`
+  LastSyncOrders     int       `json:"last_sync_orders"`
+  TotalSyncOrders    int64     `json:"total_sync_orders"`
+}
+
+func ShopeeOrderSyncModelToEntity(model *ShopeeOrderSyncModel) *ShopeeOrderSyncEntity {
+  return &ShopeeOrderSyncEntity{
+    ShopID:             model.ShopID,
+    LastUpdateTime:     model.LastUpdateTime,
+    Status:             model.Status,
+    LastSyncStartedAt:  model.LastSyncStartedAt,
+    LastSyncFinishedAt: model.LastSyncFinishedAt,
+    LastSyncError:      model.LastSyncError,
+    LastSyncOrders:     model.LastSyncOrders,
+    TotalSyncOrders:    model.TotalSyncOrders,
+  }
+}
+// ----------------- [Entity] - End.Collection("shopee_order_sync")   ----------------
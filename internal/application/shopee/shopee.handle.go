@@ -1,8 +1,11 @@
 package shopee
 
 import (
+	"context"
 	"ecommerce/internal/delivery/http/response"
   "ecommerce/internal/application/shopee/partner"
+	"ecommerce/internal/pkg"
+	"errors"
 	"fmt"
 
 	"github.com/go-playground/validator/v10"
@@ -16,6 +19,9 @@ type IShopeeHandler interface {
 	GetWebHookAuthPartner(c *fiber.Ctx) error
 
 	GetShopeeTokenAuthPartnerByShopId(c *fiber.Ctx) error
+  // refresh state (ShopeeTokenRefreshWorker)
+  GetShopeeShopAuthNeedReauth(c *fiber.Ctx) error
+  PostShopeeRefreshTokenByShopId(c *fiber.Ctx) error
 
 	PostShopAuthPartner(c *fiber.Ctx) error
 	PostShopeeTokenAuthPartnerWithCode(c *fiber.Ctx) error
@@ -30,6 +36,12 @@ type IShopeeHandler interface {
 	// Order
 	GetShopeeOrderListByShopID(c *fiber.Ctx) error
 	GetShopeeOrderDetailsByShopIDAndOrderSN(c *fiber.Ctx) error
+  PostShopeeOrderSyncByShopID(c *fiber.Ctx) error
+  GetShopeeOrderSyncByShopID(c *fiber.Ctx) error
+
+  // admin : outbound client circuit breakers
+  GetShopeeCircuitBreakers(c *fiber.Ctx) error
+  PostShopeeCircuitBreakerReset(c *fiber.Ctx) error
 }
 
 type shopeeHandler struct {
@@ -100,7 +112,7 @@ func (d *shopeeHandler) PostShopAuthPartner(c *fiber.Ctx) error {
 		return response.ErrorResponse(c, fiber.StatusBadRequest, "Invalid request body : PostShopAuthPartner", err)
 	}
 
-	params := map[string]string{"partner_id": reqBody.PartnerID, "partner_key": reqBody.SecretKey, "partner_name": reqBody.PartnerName, "link": dataLink}
+	params := map[string]string{"partner_id": reqBody.PartnerID, "partner_key": pkg.RedactSecret(reqBody.SecretKey), "partner_name": reqBody.PartnerName, "link": dataLink}
 
 	data := map[string]any{"Status": "POST", "param": params}
 
@@ -114,7 +126,7 @@ func (d *shopeeHandler) GetWebHookAuthPartner(c *fiber.Ctx) error {
 	shopId := c.Query("shop_id")
 
   data, err := d.ShopeeService.WebhookAuthentication(c.Context() , partnerId, code, shopId)
-  if err != nil { return response.ErrorResponse(c, fiber.StatusConflict, "shopee.handler.GetWebHookAuthPartner", err.Error())}
+  if err != nil { return response.ErrorResponse(c, ShopeeErrorStatus(err), "shopee.handler.GetWebHookAuthPartner", err)}
 
 	return response.SuccessResponse(c, "GetWebHookAuthPartner", data)
 }
@@ -147,7 +159,7 @@ func (d *shopeeHandler) PostShopeeTokenAuthPartnerWithCode(c *fiber.Ctx) error {
 	}
 
 	// ShopeeService
-	return response.SuccessResponse(c, "PostShopeeTokenAuthPartner", dataGen)
+	return response.SuccessResponse(c, "PostShopeeTokenAuthPartner", dataGen.Redacted())
 }
 
 func (d *shopeeHandler) GetShopeeTokenAuthPartnerByShopId(c *fiber.Ctx) error {
@@ -163,10 +175,37 @@ func (d *shopeeHandler) GetShopeeTokenAuthPartnerByShopId(c *fiber.Ctx) error {
 	data, err := d.ShopeeService.GetAccessTokenByShopID(c.Context(),shopID)
 	if err != nil {
 		d.Logger.Error("handle.GetShopeeTokenAuthPartnerByShopId : d.service.GetAccessToken :", zap.Error(err))
-		return response.ErrorResponse(c, fiber.StatusNotFound, "ShopId no found", err.Error())
+		return response.ErrorResponse(c, ShopeeErrorStatus(err), "ShopId no found", err)
 	}
 
-	return response.SuccessResponse(c, "shopee router", data)
+	return response.SuccessResponse(c, "shopee router", data.Redacted())
+}
+
+// shops flagged by the refresh worker : must go through auth_partner again
+func (d *shopeeHandler) GetShopeeShopAuthNeedReauth(c *fiber.Ctx) error {
+  data, err := d.ShopeeService.GetShopeeShopAuthNeedReauth(c.Context())
+  if err != nil {
+    d.Logger.Error("handle.GetShopeeShopAuthNeedReauth : d.service.GetShopeeShopAuthNeedReauth :", zap.Error(err))
+    return response.ErrorResponse(c, fiber.StatusInternalServerError, "handle.GetShopeeShopAuthNeedReauth", err)
+  }
+
+  return response.SuccessResponse(c, "handle.GetShopeeShopAuthNeedReauth", data)
+}
+
+func (d *shopeeHandler) PostShopeeRefreshTokenByShopId(c *fiber.Ctx) error {
+  shopID := c.Params("shopeeShopID")
+  if shopID == "" {
+    return response.ErrorResponse(c, fiber.StatusBadRequest, "handle.PostShopeeRefreshTokenByShopId", "shopId is required")
+  }
+
+  data, err := d.ShopeeService.RefreshAccessTokenByShopID(c.Context(), shopID)
+  if err != nil {
+    d.Logger.Error("handle.PostShopeeRefreshTokenByShopId : d.service.RefreshAccessTokenByShopID :", zap.Error(err))
+    return response.ErrorResponse(c, ShopeeErrorStatus(err), "handle.PostShopeeRefreshTokenByShopId", err)
+  }
+
+  res := map[string]any{"shop_id": data.ShopID, "expired_at": data.ExpiredAt, "refresh_token_expired_at": data.RefreshTokenExpiredAt}
+  return response.SuccessResponse(c, "handle.PostShopeeRefreshTokenByShopId", res)
 }
 
 func (d *shopeeHandler) GetShopeeShopListByPartnerID(c *fiber.Ctx) error {
@@ -175,7 +214,7 @@ func (d *shopeeHandler) GetShopeeShopListByPartnerID(c *fiber.Ctx) error {
 	data, err := d.ShopeeService.GetShopeeShopListByPartnerID(c.Context(),partnerID)
 	if err != nil {
 		d.Logger.Error("handle.GetShopeeShopListByPartnerID : d.service.GetShopeeShopListByPartnerID :", zap.Error(err))
-		return response.ErrorResponse(c, fiber.StatusNotFound, "ShopId no found", err.Error())
+		return response.ErrorResponse(c, ShopeeErrorStatus(err), "ShopId no found", err)
 	}
 
   // 
@@ -215,7 +254,7 @@ func (d *shopeeHandler) GetShopeeOrderListByShopID(c *fiber.Ctx) error {
 	// _, err := d.ShopeeService.GetAccessTokenByShopID(c.Context(),shopID)
 	// if err != nil {
 	// 	d.Logger.Error("handle.GetShopeeOrderListByShopID : d.service.GetAccessToken :", zap.Error(err))
-	// 	return response.ErrorResponse(c, fiber.StatusNotFound, "ShopId no found", err.Error())
+	// 	return response.ErrorResponse(c, fiber.StatusNotFound, "ShopId no found", err)
 	// }
 
 	// Valid section
@@ -232,7 +271,7 @@ func (d *shopeeHandler) GetShopeeOrderListByShopID(c *fiber.Ctx) error {
 	data, err := d.ShopeeService.GetShopeeOrderListByShopID(c.Context(),shopID, typeQuery, timeFromQuery, timeToQuery, statusQuery, nextQuery, sizeQuery)
 	if err != nil {
 		d.Logger.Error("handle.GetShopeeOrderListByShopID : d.service.GetShopeeOrderListByShopID :", zap.Error(err))
-		return response.ErrorResponse(c, fiber.StatusNotFound, "usecase.GetShopeeOrderListByShopID :", err.Error())
+		return response.ErrorResponse(c, ShopeeErrorStatus(err), "usecase.GetShopeeOrderListByShopID :", err)
 	}
 	// d.Logger.Debug("shopeeHandle.GetShopeeOrderListByShopID", zap.Any("data", data))
 
@@ -240,6 +279,70 @@ func (d *shopeeHandler) GetShopeeOrderListByShopID(c *fiber.Ctx) error {
 	return response.SuccessResponse(c, "shopeeHandle.GetShopeeOrderListByShopID", data.OrderList)
 }
 
+// start an incremental sync in background : progress through GetShopeeOrderSyncByShopID
+func (d *shopeeHandler) PostShopeeOrderSyncByShopID(c *fiber.Ctx) error {
+  shopID := c.Params("shopeeShopID")
+  if shopID == "" {
+    return response.ErrorResponse(c, fiber.StatusBadRequest, "handle.PostShopeeOrderSyncByShopID", "shopId is required")
+  }
+
+  // fail fast before going async
+  if _, err := d.ShopeeService.GetShopeeAdapterParamsByShopID(c.Context(), shopID); err != nil {
+    return response.ErrorResponse(c, ShopeeErrorStatus(err), "handle.PostShopeeOrderSyncByShopID", err)
+  }
+
+  // c.Context() is recycled once we answer : carry the tenant over to the background run
+  ctx := context.Background()
+  if tenantID, ok := pkg.TenantFromContext(c.Context()); ok { ctx = pkg.WithTenant(ctx, tenantID) }
+  go func() {
+    if _, err := d.ShopeeService.SyncShopeeOrderByShopID(ctx, shopID); err != nil {
+      d.Logger.Error("handle.PostShopeeOrderSyncByShopID : d.service.SyncShopeeOrderByShopID :", zap.String("shop_id", shopID), zap.Error(err))
+    }
+  }()
+
+  return response.SuccessResponse(c, "handle.PostShopeeOrderSyncByShopID", map[string]string{"shop_id": shopID, "status": string(ORDER_SYNC_RUNNING)})
+}
+
+func (d *shopeeHandler) GetShopeeOrderSyncByShopID(c *fiber.Ctx) error {
+  shopID := c.Params("shopeeShopID")
+
+  res, err := d.ShopeeService.GetShopeeOrderSyncByShopID(c.Context(), shopID)
+  if err != nil {
+    return response.ErrorResponse(c, ShopeeErrorStatus(err), "handle.GetShopeeOrderSyncByShopID", err)
+  }
+
+  return response.SuccessResponse(c, "handle.GetShopeeOrderSyncByShopID", res)
+}
+
+func (d *shopeeHandler) GetShopeeCircuitBreakers(c *fiber.Ctx) error {
+  data, err := d.ShopeeService.GetShopeeCircuitBreakers(c.Context())
+  if err != nil {
+    return response.ErrorResponse(c, fiber.StatusInternalServerError, "handle.GetShopeeCircuitBreakers", err)
+  }
+  return response.SuccessResponse(c, "handle.GetShopeeCircuitBreakers", data)
+}
+
+// empty path : reset every endpoint
+type IReqShopeeCircuitBreakerReset struct {
+  Path string `json:"path"`
+}
+
+func (d *shopeeHandler) PostShopeeCircuitBreakerReset(c *fiber.Ctx) error {
+  var reqBody IReqShopeeCircuitBreakerReset
+  if len(c.Body()) > 0 {
+    if err := c.BodyParser(&reqBody); err != nil {
+      return response.ErrorResponse(c, fiber.StatusBadRequest, "handle.PostShopeeCircuitBreakerReset", err)
+    }
+  }
+
+  if err := d.ShopeeService.ResetShopeeCircuitBreaker(c.Context(), reqBody.Path); err != nil {
+    return response.ErrorResponse(c, ShopeeErrorStatus(err), "handle.PostShopeeCircuitBreakerReset", err)
+  }
+
+  data, _ := d.ShopeeService.GetShopeeCircuitBreakers(c.Context())
+  return response.SuccessResponse(c, "handle.PostShopeeCircuitBreakerReset", data)
+}
+
 func (d *shopeeHandler)GetShopeeShopDetails(c *fiber.Ctx) error {
   shopID := c.Params("shopeeShopID")
   userName,ok := c.Locals("username").(string)
@@ -250,7 +353,7 @@ func (d *shopeeHandler)GetShopeeShopDetails(c *fiber.Ctx) error {
   // d.Logger.Debug("handler.GetShopeeShopDetailsByShopID", zap.String("userName", userName))
 
   res,err := d.ShopeeService.GetShopeeShopDetailsByShopID(c.Context(), userName ,shopID)
-  if err != nil { return response.ErrorResponse(c, fiber.StatusConflict,"handler.GetShopeeShopDetails" ,err) }
+  if err != nil { return response.ErrorResponse(c, ShopeeErrorStatus(err), "handler.GetShopeeShopDetails", err) }
 
   return response.SuccessResponse(c, "handle.GetShopeeShopDetails", res)
 }
@@ -274,7 +377,7 @@ func (d *shopeeHandler) GetShopeeOrderDetailsByShopIDAndOrderSN(c *fiber.Ctx) er
 	data, err := d.ShopeeService.GetShopeeOrderDetailByOrderSN(c.Context(),shopIDParam, orderSNParam, pendingQuery, optionQuery)
 	if err != nil {
 		d.Logger.Error("handle.GetShopeeOrderListByShopSN : d.service.GetShopeeOrderDetailByShopID :", zap.Error(err))
-		return response.ErrorResponse(c, fiber.StatusNotFound, "usecase.GetShopeeOrderDetailByShopID :", err.Error())
+		return response.ErrorResponse(c, ShopeeErrorStatus(err), "usecase.GetShopeeOrderDetailByShopID :", err)
 	}
 
 	// d.Logger.Debug("shopeeHandle.GetShopeeOrderListByShopSN", zap.Any("data", data.OrderList))
@@ -319,3 +422,16 @@ func (d *shopeeHandler) PostShopeeDemoTemplate(c *fiber.Ctx) error {
 //   data := map[string]string{"Status": "POST", "param": reqBody.ShopID}
 //   return response.SuccessResponse(c, "PostShopAuthPartner", &data)
 // }
+
+// ShopeeErrorStatus : status of an error that carries no code of its own (a Shopee error answers
+// with the status of its kind, see response.ErrorResponse) : a record we don't hold is 404,
+// anything else is on our side : 500
+func ShopeeErrorStatus(err error) int {
+  switch {
+  case errors.Is(err, ErrShopeeShopNotFound), errors.Is(err, ErrShopeeOrderNotFound),
+    errors.Is(err, ErrShopeeOrderSyncNotFound), errors.Is(err, ErrShopeeCircuitBreakerNotFound),
+    errors.Is(err, partner.ErrShopeePartnerNotFound):
+    return fiber.StatusNotFound
+  }
+  return fiber.StatusInternalServerError
+}
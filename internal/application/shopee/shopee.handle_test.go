@@ -0,0 +1,30 @@
+package shopee
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"ecommerce/internal/application/shopee/partner"
+)
+
+func TestShopeeErrorStatus(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"shop", ErrShopeeShopNotFound, fiber.StatusNotFound},
+		{"wrapped order", fmt.Errorf("usecase : %w", ErrShopeeOrderNotFound), fiber.StatusNotFound},
+		{"partner", partner.ErrShopeePartnerNotFound, fiber.StatusNotFound},
+		{"circuit breaker", fmt.Errorf("%w %s", ErrShopeeCircuitBreakerNotFound, "/api/v2/x"), fiber.StatusNotFound},
+		{"database down", errors.New("server selection timeout"), fiber.StatusInternalServerError},
+	}
+	for _, c := range cases {
+		if got := ShopeeErrorStatus(c.err); got != c.want {
+			t.Errorf("%s: status %d, want %d", c.name, got, c.want)
+		}
+	}
+}
@@ -6,6 +6,9 @@ import (
 	"go.mongodb.org/mongo-driver/v2/bson"
 )
 
+// Shopee refresh_token is valid for 30 days and is replaced on every exchange
+const ShopeeRefreshTokenLifetime = time.Hour * 24 * 30
+
 type ShopeeAuthModel struct {
   PartnerID    string    `bson:"partner_id"`
 	ShopID       string    `bson:"shop_id"`
@@ -14,6 +17,17 @@ type ShopeeAuthModel struct {
 	RefreshToken string    `bson:"refresh_token"`
 	ExpiredAt    time.Time `bson:"expired_at"`
 
+  // refresh state : maintained by ShopeeTokenRefreshWorker
+  RefreshTokenExpiredAt time.Time `bson:"refresh_token_expired_at"`
+  LastRefreshAt         time.Time `bson:"last_refresh_at"`
+  RefreshFailCount      int       `bson:"refresh_fail_count"`
+  LastRefreshError      string    `bson:"last_refresh_error"`
+  NeedReauth            bool      `bson:"need_reauth"` // refresh token is dead, shop must be re-authorized
+  AuthExpireAt          time.Time `bson:"auth_expire_at"` // seller authorization end, from push (code 12)
+
+  // owner tenant, missing for the platform tenant : see pkg.TenantFilter
+  TenantID string `bson:"tenant_id,omitempty"`
+
 	CreatedAt   time.Time `bson:"created_at"`
 	CreatedBy   string    `bson:"created_by"`
 
@@ -22,6 +36,8 @@ type ShopeeAuthModel struct {
 }
 
 type ShopeeAuthRequestModel struct {
+	ID          bson.ObjectID `bson:"_id,omitempty"`
+	TenantID    string    `bson:"tenant_id,omitempty"`
 	PartnerID   string    `bson:"partner_id"`
 	PartnerKey  string    `bson:"partner_key"`
 	PartnerName string    `bson:"partner_name"`
@@ -111,6 +127,7 @@ type ShopeeShopDetailsModel struct {
   MartShopID string `bson:"mart_shop_id"`
   OutletShopInfoList []OutletShopInfoList_Struct `bson:"outlet_shop_info_list"`
 
+  TenantID  string `bson:"tenant_id,omitempty"`
   CreatedAt time.Time `bson:"created_at"`
   CreatedBy string `bson:"created_by"`
   UpdatedAt time.Time `bson:"updated_at"`
@@ -189,6 +206,32 @@ type ShopeeInvoiceDataModel struct {
 	TaxCode           string
 }
 
+// per order income, amounts in order currency
+type ShopeeOrderEscrowModel struct {
+  EscrowAmount         float64 `bson:"escrow_amount"`      // released to seller
+  BuyerTotalAmount     float64 `bson:"buyer_total_amount"`
+  OriginalPrice        float64 `bson:"original_price"`
+  SellerDiscount       float64 `bson:"seller_discount"`
+  ShopeeDiscount       float64 `bson:"shopee_discount"`
+  VoucherFromSeller    float64 `bson:"voucher_from_seller"`
+  VoucherFromShopee    float64 `bson:"voucher_from_shopee"`
+  Coins                float64 `bson:"coins"`
+  CommissionFee        float64 `bson:"commission_fee"`
+  ServiceFee           float64 `bson:"service_fee"`
+  TransactionFee       float64 `bson:"transaction_fee"`
+  BuyerPaidShippingFee float64 `bson:"buyer_paid_shipping_fee"`
+  ActualShippingFee    float64 `bson:"actual_shipping_fee"`
+  ShippingSubsidy      float64 `bson:"shipping_subsidy"`   // shopee_shipping_rebate
+  ReverseShippingFee   float64 `bson:"reverse_shipping_fee"`
+  EscrowTax            float64 `bson:"escrow_tax"`
+  SellerReturnRefund   float64 `bson:"seller_return_refund"`
+
+  // from /payment/get_escrow_list : zero until released
+  PayoutAmount float64   `bson:"payout_amount"`
+  ReleasedAt   time.Time `bson:"released_at"`
+  SyncedAt     time.Time `bson:"synced_at"`
+}
+
 type ShopeeOrderModel struct {
   ID bson.ObjectID    `bson:"_id"`
   ShopID    string    `bson:"shop_id"` 
@@ -238,7 +281,13 @@ type ShopeeOrderModel struct {
   PrescriptionCheckStatus ShopeePrescriptionCheckStatusEnum `bson:"prescription_check_status"` 
   AdvancePackage bool             `bson:"advance_package"`
   ReturnRequestDueDate     time.Time  `bson:"return_request_due_date"`
+  // package_number -> tracking_no : written by push (code 4), not part of get_order_detail
+  TrackingNumbers map[string]string `bson:"tracking_numbers,omitempty"`
+  // fee breakdown from /payment/get_escrow_detail : written by escrow sync, not part of get_order_detail
+  Escrow *ShopeeOrderEscrowModel `bson:"escrow,omitempty"`
 
+  // stamped from the request / shop scope on insert, never changed by an update
+  TenantID    string      `bson:"tenant_id,omitempty"`
   CreatedAt   time.Time   `bson:"created_at"`
   CreatedBy   string      `bson:"created_by"`
   UpdatedAt   time.Time   `bson:"updated_at"`
@@ -378,6 +427,8 @@ func ShopeeOrderModelToEntity(model *ShopeeOrderModel) *ShopeeOrderEntity {
     BookingSN: model.BookingSN,
     AdvancePackage: model.AdvancePackage,
     ReturnRequestDueDate: model.ReturnRequestDueDate,
+    TrackingNumbers: model.TrackingNumbers,
+    Escrow: ShopeeOrderEscrowModelToEntity(model.Escrow),
   }
 
   return &ShopeeOrderEntity{
@@ -412,3 +463,40 @@ func ShopeeOrderModelToEntity(model *ShopeeOrderModel) *ShopeeOrderEntity {
 // [Method Start]
 // [Method End]
 // ----------------- [DTO/Entity/Model] - End.Collection("Shop_rder")   ----------------
+
+// ----------------- [Model] - Start.Collection("shopee_order_sync") ----------------
+// [Concept] : one document per shop, high-water mark of the incremental order sync
+// -- LastUpdateTime : every order with update_time <= LastUpdateTime is stored in shopee_order
+type ShopeeOrderSyncStatusEnum string
+
+const (
+  ORDER_SYNC_IDLE    ShopeeOrderSyncStatusEnum = "IDLE"
+  ORDER_SYNC_RUNNING ShopeeOrderSyncStatusEnum = "RUNNING"
+  ORDER_SYNC_FAILED  ShopeeOrderSyncStatusEnum = "FAILED"
+)
+
+type ShopeeOrderSyncModel struct {
+  // tenant of the shop, missing for the platform tenant : see pkg.TenantFilter
+  TenantID       string    `bson:"tenant_id,omitempty"`
+  ShopID         string    `bson:"shop_id"`
+  LastUpdateTime time.Time `bson:"last_update_time"`
+  Status         ShopeeOrderSyncStatusEnum `bson:"status"`
+
+  LastSyncStartedAt  time.Time `bson:"last_sync_started_at"`
+  LastSyncFinishedAt time.Time `bson:"last_sync_finished_at"`
+  LastSyncError      string    `bson:"last_sync_error"`
+  LastSyncOrders     int       `bson:"last_sync_orders"`
+  TotalSyncOrders    int64     `bson:"total_sync_orders"`
+
+  CreatedAt time.Time `bson:"created_at"`
+  UpdatedAt time.Time `bson:"updated_at"`
+}
+// ----------------- [Model] - End.Collection("shopee_order_sync")   ----------------
+
+func ShopeeOrderEscrowModelToEntity(model *ShopeeOrderEscrowModel) *ShopeeOrderEscrowEntity {
+  if model == nil {
+    return nil
+  }
+  e := ShopeeOrderEscrowEntity(*model)
+  return &e
+}
@@ -0,0 +1,327 @@
+package shopee
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"ecommerce/internal/adapter"
+	"ecommerce/internal/adapter/dto"
+	"ecommerce/internal/env"
+	"ecommerce/internal/pkg"
+)
+
+// Shopee limits for /order/get_order_list and /order/get_order_detail
+const (
+	ShopeeOrderListMaxRange   = time.Hour * 24 * 15
+	ShopeeOrderListPageSize   = 100
+	ShopeeOrderDetailMaxBatch = 50
+
+	// re-read the tail of the last window : update_time is second precision
+	shopeeOrderSyncOverlap = time.Minute
+)
+
+// shopScope : workers and push run without a tenant, scope them to the tenant owning the shop
+// so partner lookups and order inserts land in the right tenant ; a request ctx is kept as is
+func (s *shopeeService) shopScope(ctx context.Context, shopID string) (context.Context, error) {
+	if _, ok := pkg.TenantFromContext(ctx); ok {
+		return ctx, nil
+	}
+	shop, err := s.ShopeeAuthRepository.GetShopeeShopAuthByShopId(ctx, shopID)
+	if err != nil {
+		return nil, err
+	}
+	return pkg.WithTenant(ctx, shop.TenantID), nil
+}
+
+func (s *shopeeService) GetShopeeAdapterParamsByShopID(ctx context.Context, shopID string) (*adapter.IReqShopeeAdapter, error) {
+	ctx, err := s.shopScope(ctx, shopID)
+	if err != nil {
+		return nil, err
+	}
+	shopData, err := s.GetAccessTokenByShopID(ctx, shopID)
+	if err != nil {
+		return nil, err
+	}
+	partnerData, err := s.ShopeePartnerRepository.GetShopeePartnerByID(ctx, shopData.PartnerID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &adapter.IReqShopeeAdapter{
+		PartnerID:   partnerData.PartnerID,
+		AccessToken: shopData.AccessToken,
+		ShopID:      shopData.ShopID,
+		SecretKey:   partnerData.SecretKey,
+	}, nil
+}
+
+func (s *shopeeService) GetShopeeOrderSyncByShopID(ctx context.Context, shopID string) (*ShopeeOrderSyncEntity, error) {
+	// sync state is keyed by shop only : the shop has to be visible to the caller
+	if _, err := s.ShopeeAuthRepository.GetShopeeShopAuthByShopId(ctx, shopID); err != nil {
+		return nil, err
+	}
+	state, err := s.ShopeeOrderSyncRepository.GetShopeeOrderSyncByShopID(ctx, shopID)
+	if err != nil {
+		return nil, err
+	}
+	return ShopeeOrderSyncModelToEntity(state), nil
+}
+
+// SyncShopeeOrderByShopID : walk update_time from the high-water mark to now
+// -- windows of at most 15 days, every cursor page, details in batches of 50
+// -- high-water mark is saved after each completed window, a restart resumes from there
+func (s *shopeeService) SyncShopeeOrderByShopID(ctx context.Context, shopID string) (*ShopeeOrderSyncEntity, error) {
+	lock, _ := s.syncLocks.LoadOrStore(shopID, &sync.Mutex{})
+	mu := lock.(*sync.Mutex)
+	if !mu.TryLock() {
+		return nil, fmt.Errorf("usecase.SyncShopeeOrderByShopID : sync already running for shop %s", shopID)
+	}
+	defer mu.Unlock()
+
+	ctx, err := s.shopScope(ctx, shopID)
+	if err != nil {
+		return nil, err
+	}
+
+	// only a shop never synced starts over from the lookback : a read error must not reset the mark
+	state, err := s.ShopeeOrderSyncRepository.GetShopeeOrderSyncByShopID(ctx, shopID)
+	if errors.Is(err, ErrShopeeOrderSyncNotFound) {
+		state = &ShopeeOrderSyncModel{ShopID: shopID}
+	} else if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	from := state.LastUpdateTime.Add(-shopeeOrderSyncOverlap)
+	if state.LastUpdateTime.IsZero() {
+		from = now.AddDate(0, 0, -int(s.Config.Shopee.ShopeeOrderSyncLookbackDays))
+	}
+
+	state.Status = ORDER_SYNC_RUNNING
+	state.LastSyncStartedAt = now
+	state.LastSyncError = ""
+	state.LastSyncOrders = 0
+	if _, err := s.ShopeeOrderSyncRepository.SaveShopeeOrderSync(ctx, state); err != nil {
+		return nil, err
+	}
+
+	for windowFrom := from; windowFrom.Before(now); {
+		windowTo := windowFrom.Add(ShopeeOrderListMaxRange)
+		if windowTo.After(now) {
+			windowTo = now
+		}
+
+		count, err := s.syncShopeeOrderWindow(ctx, shopID, windowFrom, windowTo)
+		state.LastSyncOrders += count
+		state.TotalSyncOrders += int64(count)
+		if err != nil {
+			s.Logger.Error("usecase.SyncShopeeOrderByShopID : window failed",
+				zap.String("shop_id", shopID),
+				zap.Time("from", windowFrom),
+				zap.Time("to", windowTo),
+				zap.Error(err))
+
+			state.Status = ORDER_SYNC_FAILED
+			state.LastSyncError = err.Error()
+			state.LastSyncFinishedAt = time.Now()
+			// persist the failure even when ctx was canceled, in the same tenant
+			if _, saveErr := s.ShopeeOrderSyncRepository.SaveShopeeOrderSync(context.WithoutCancel(ctx), state); saveErr != nil {
+				s.Logger.Error("usecase.SyncShopeeOrderByShopID : SaveShopeeOrderSync error", zap.Error(saveErr))
+			}
+			return ShopeeOrderSyncModelToEntity(state), err
+		}
+
+		// checkpoint
+		state.LastUpdateTime = windowTo
+		if _, err := s.ShopeeOrderSyncRepository.SaveShopeeOrderSync(ctx, state); err != nil {
+			return nil, err
+		}
+		windowFrom = windowTo
+	}
+
+	state.Status = ORDER_SYNC_IDLE
+	state.LastSyncFinishedAt = time.Now()
+	saved, err := s.ShopeeOrderSyncRepository.SaveShopeeOrderSync(ctx, state)
+	if err != nil {
+		return nil, err
+	}
+	return ShopeeOrderSyncModelToEntity(saved), nil
+}
+
+func (s *shopeeService) SyncShopeeOrderByOrderSN(ctx context.Context, shopID string, orderSN []string) (int, error) {
+	ctx, err := s.shopScope(ctx, shopID)
+	if err != nil {
+		return 0, err
+	}
+	params, err := s.GetShopeeAdapterParamsByShopID(ctx, shopID)
+	if err != nil {
+		return 0, err
+	}
+	return s.saveShopeeOrderDetails(ctx, params, orderSN)
+}
+
+func (s *shopeeService) syncShopeeOrderWindow(ctx context.Context, shopID string, from time.Time, to time.Time) (int, error) {
+	// per window : picks up a token rotated by the refresh worker meanwhile
+	params, err := s.GetShopeeAdapterParamsByShopID(ctx, shopID)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	cursor := ""
+	for {
+		if err := ctx.Err(); err != nil {
+			return count, err
+		}
+
+		page, err := s.ShopeeAdapter.GetOrderListPageByShopID(ctx, params, &dto.IOptionShopeeQuery{
+			TimeRange:  dto.UPDATE_TIME,
+			TimeFrom:   from.Unix(),
+			TimeTo:     to.Unix(),
+			PageSize:   ShopeeOrderListPageSize,
+			CursorPage: cursor,
+		})
+		if err != nil {
+			return count, err
+		}
+
+		orderSN := make([]string, 0, len(page.OrderList))
+		for _, o := range page.OrderList {
+			orderSN = append(orderSN, o.OrderSN)
+		}
+
+		n, err := s.saveShopeeOrderDetails(ctx, params, orderSN)
+		count += n
+		if err != nil {
+			return count, err
+		}
+
+		if !page.More || page.NextCursor == "" {
+			return count, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+func (s *shopeeService) saveShopeeOrderDetails(ctx context.Context, params *adapter.IReqShopeeAdapter, orderSN []string) (int, error) {
+	count := 0
+	for start := 0; start < len(orderSN); start += ShopeeOrderDetailMaxBatch {
+		end := min(start+ShopeeOrderDetailMaxBatch, len(orderSN))
+
+		batch := *params
+		batch.OrderSN = orderSN[start:end]
+		details, err := s.ShopeeAdapter.GetOrderDetailListByOrderSN(ctx, &batch)
+		if err != nil {
+			return count, err
+		}
+
+		for i := range details {
+			order := ShopeeOrderDetailDTOToEntity(details[i].OrderSN, &details[i])
+			order.ShopID = params.ShopID
+			saved, err := s.ShopeeOrderRepository.UpsertShopeeOrderWithDetails(ctx, order)
+			if err != nil {
+				return count, err
+			}
+			s.NotifyShopeeOrderSaved(ctx, saved)
+			count++
+		}
+	}
+	return count, nil
+}
+
+// IShopeeOrderListener : reacts to a stored order (inventory reservations, ...) ;
+// called for every save, so it must be idempotent, and it cannot fail the sync
+type IShopeeOrderListener interface {
+	OnShopeeOrderSaved(ctx context.Context, order *ShopeeOrderEntity)
+}
+
+func (s *shopeeService) AddShopeeOrderListener(listener IShopeeOrderListener) {
+	s.orderListeners = append(s.orderListeners, listener)
+}
+
+func (s *shopeeService) NotifyShopeeOrderSaved(ctx context.Context, order *ShopeeOrderEntity) {
+	if order == nil {
+		return
+	}
+	for _, l := range s.orderListeners {
+		l.OnShopeeOrderSaved(ctx, order)
+	}
+}
+
+// ShopeeOrderSyncWorker : runs SyncShopeeOrderByShopID for every shop with a usable token
+type IShopeeOrderSyncWorker interface {
+	Start(ctx context.Context)
+	RunOnce(ctx context.Context) error
+}
+
+type shopeeOrderSyncWorker struct {
+	Config *env.Config
+	Logger *zap.Logger
+
+	ShopeeService        IShopeeService
+	ShopeeAuthRepository ShopeeAuthRepository
+}
+
+func NewShopeeOrderSyncWorker(cfg *env.Config, logger *zap.Logger, service IShopeeService, auth ShopeeAuthRepository) IShopeeOrderSyncWorker {
+	return &shopeeOrderSyncWorker{
+		Config:               cfg,
+		Logger:               logger,
+		ShopeeService:        service,
+		ShopeeAuthRepository: auth,
+	}
+}
+
+func (w *shopeeOrderSyncWorker) Start(ctx context.Context) {
+	interval := time.Duration(w.Config.Shopee.ShopeeOrderSyncInterval) * time.Minute
+	if interval <= 0 {
+		w.Logger.Info("worker.ShopeeOrderSync : disabled")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := w.RunOnce(ctx); err != nil {
+				w.Logger.Error("worker.ShopeeOrderSync : RunOnce error", zap.Error(err))
+			}
+
+			select {
+			case <-ctx.Done():
+				w.Logger.Info("worker.ShopeeOrderSync : stopped")
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (w *shopeeOrderSyncWorker) RunOnce(ctx context.Context) error {
+	shops, err := w.ShopeeAuthRepository.GetShopeeShopAuthActive(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, shop := range shops {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		res, err := w.ShopeeService.SyncShopeeOrderByShopID(ctx, shop.ShopID)
+		if err != nil {
+			w.Logger.Error("worker.ShopeeOrderSync : sync failed", zap.String("shop_id", shop.ShopID), zap.Error(err))
+			continue
+		}
+		w.Logger.Info("worker.ShopeeOrderSync : done",
+			zap.String("shop_id", shop.ShopID),
+			zap.Int("orders", res.LastSyncOrders),
+			zap.Time("last_update_time", res.LastUpdateTime))
+	}
+	return nil
+}
@@ -0,0 +1,289 @@
+package shopee
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"ecommerce/internal/adapter"
+	"ecommerce/internal/adapter/dto"
+	"ecommerce/internal/adapter/fakeshopee"
+	"ecommerce/internal/application/shopee/partner"
+	"ecommerce/internal/env"
+	"ecommerce/internal/pkg"
+)
+
+// The order sync against the in-memory Shopee, with in-memory stores.
+
+const (
+	syncPartnerID  = "1000001"
+	syncPartnerKey = "fake-partner-key"
+	syncShopID     = "2000001"
+	// 10 orders a day over 30 days : a 15 day window holds more than one list page
+	syncOrders       = 300
+	syncLookbackDays = 35
+)
+
+type fakeSyncAuthRepo struct {
+	ShopeeAuthRepository
+	shop ShopeeAuthModel
+}
+
+func (r *fakeSyncAuthRepo) GetShopeeShopAuthByShopId(ctx context.Context, shopID string) (*ShopeeAuthModel, error) {
+	if shopID != r.shop.ShopID {
+		return nil, ErrShopeeShopNotFound
+	}
+	shop := r.shop
+	return &shop, nil
+}
+
+type fakeSyncPartnerRepo struct {
+	partner.ShopeePartnerRepository
+}
+
+func (fakeSyncPartnerRepo) GetShopeePartnerByID(ctx context.Context, partnerID string) (*partner.ShopeePartnerEntity, error) {
+	return &partner.ShopeePartnerEntity{PartnerID: partnerID, SecretKey: syncPartnerKey}, nil
+}
+
+type fakeSyncOrderRepo struct {
+	ShopeeOrderRepository
+	mu     sync.Mutex
+	orders map[string]ShopeeOrderEntity
+}
+
+func (r *fakeSyncOrderRepo) UpsertShopeeOrderWithDetails(ctx context.Context, order *ShopeeOrderEntity) (*ShopeeOrderEntity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if stored, ok := r.orders[order.OrderSN]; ok && stored.UpdateTime.After(order.UpdateTime) {
+		return &stored, nil
+	}
+	r.orders[order.OrderSN] = *order
+	return order, nil
+}
+
+type fakeSyncStateRepo struct {
+	ShopeeOrderSyncRepository
+	state  *ShopeeOrderSyncModel
+	getErr error
+	saves  int
+}
+
+func (r *fakeSyncStateRepo) GetShopeeOrderSyncByShopID(ctx context.Context, shopID string) (*ShopeeOrderSyncModel, error) {
+	if r.getErr != nil {
+		return nil, r.getErr
+	}
+	if r.state == nil {
+		return nil, ErrShopeeOrderSyncNotFound
+	}
+	state := *r.state
+	return &state, nil
+}
+
+func (r *fakeSyncStateRepo) SaveShopeeOrderSync(ctx context.Context, sync *ShopeeOrderSyncModel) (*ShopeeOrderSyncModel, error) {
+	r.saves++
+	state := *sync
+	r.state = &state
+	return sync, nil
+}
+
+// recordingShopeeAdapter : the real adapter, with the order list queries and detail batches it was asked for
+type recordingShopeeAdapter struct {
+	adapter.IShopeeService
+	mu      sync.Mutex
+	queries []dto.IOptionShopeeQuery
+	batches []int
+}
+
+func (a *recordingShopeeAdapter) GetOrderListPageByShopID(ctx context.Context, params *adapter.IReqShopeeAdapter, opts *dto.IOptionShopeeQuery) (*dto.IResGetOrderListByShopIDShopWrapper, error) {
+	a.mu.Lock()
+	a.queries = append(a.queries, *opts)
+	a.mu.Unlock()
+	return a.IShopeeService.GetOrderListPageByShopID(ctx, params, opts)
+}
+
+func (a *recordingShopeeAdapter) GetOrderDetailListByOrderSN(ctx context.Context, params *adapter.IReqShopeeAdapter) ([]dto.IResOrderListWithDetails, error) {
+	a.mu.Lock()
+	a.batches = append(a.batches, len(params.OrderSN))
+	a.mu.Unlock()
+	return a.IShopeeService.GetOrderDetailListByOrderSN(ctx, params)
+}
+
+type syncEnv struct {
+	Service *shopeeService
+	Adapter *recordingShopeeAdapter
+	Orders  *fakeSyncOrderRepo
+	State   *fakeSyncStateRepo
+	Now     time.Time
+}
+
+func newSyncEnv(t *testing.T) *syncEnv {
+	t.Helper()
+	now := time.Now()
+	partnerID, _ := strconv.ParseInt(syncPartnerID, 10, 64)
+	fake := fakeshopee.New(fakeshopee.DefaultSeed(partnerID, syncPartnerKey, 1, syncOrders, now), nil)
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	cfg := &env.Config{Shopee: &env.ShopeeConfig{
+		ShopeeHttpTimeout:           5,
+		ShopeeRetryBaseMs:           1,
+		ShopeeRetryMaxMs:            5,
+		ShopeeBreakerThreshold:      100,
+		ShopeeBreakerCooldown:       1,
+		ShopeeOrderSyncLookbackDays: syncLookbackDays,
+	}}
+	api := adapter.NewShopeeAPI(cfg, srv.URL, zap.NewNop())
+
+	code, err := fake.IssueAuthCode(syncPartnerID, syncShopID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := api.GetAccessToken(context.Background(), &adapter.IReqShopeeAdapter{
+		PartnerID: syncPartnerID, SecretKey: syncPartnerKey, ShopID: syncShopID, Code: &code,
+	})
+	if err != nil {
+		t.Fatalf("GetAccessToken: %v", err)
+	}
+
+	e := &syncEnv{
+		Adapter: &recordingShopeeAdapter{IShopeeService: api},
+		Orders:  &fakeSyncOrderRepo{orders: map[string]ShopeeOrderEntity{}},
+		State:   &fakeSyncStateRepo{},
+		Now:     now,
+	}
+	auth := &fakeSyncAuthRepo{shop: ShopeeAuthModel{
+		PartnerID: syncPartnerID, ShopID: syncShopID, AccessToken: token.AccessToken, ExpiredAt: now.Add(time.Hour),
+	}}
+	e.Service = NewShopeeService(cfg, zap.NewNop(), e.Adapter, auth, nil, fakeSyncPartnerRepo{}, nil, e.Orders, e.State).(*shopeeService)
+	return e
+}
+
+// sync : like the worker, unscoped
+func (e *syncEnv) sync() (*ShopeeOrderSyncEntity, error) {
+	return e.Service.SyncShopeeOrderByShopID(pkg.WithoutTenant(context.Background()), syncShopID)
+}
+
+func TestSyncShopeeOrderWalksWindows(t *testing.T) {
+	e := newSyncEnv(t)
+	res, err := e.sync()
+	if err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if len(e.Orders.orders) != syncOrders {
+		t.Fatalf("%d orders stored, want %d", len(e.Orders.orders), syncOrders)
+	}
+	if res.Status != ORDER_SYNC_IDLE || res.LastSyncOrders < syncOrders {
+		t.Errorf("status %s, %d orders synced", res.Status, res.LastSyncOrders)
+	}
+
+	// windows of at most 15 days, back to back from the lookback to now
+	from := e.Now.AddDate(0, 0, -syncLookbackDays).Unix()
+	windows := 0
+	for _, q := range e.Adapter.queries {
+		if q.CursorPage != "" {
+			continue
+		}
+		windows++
+		if q.TimeRange != dto.UPDATE_TIME {
+			t.Errorf("window on %s, want update_time", q.TimeRange)
+		}
+		if q.TimeFrom < from-1 || q.TimeFrom > from+1 {
+			t.Errorf("window %d starts at %d, want %d", windows, q.TimeFrom, from)
+		}
+		if span := time.Duration(q.TimeTo-q.TimeFrom) * time.Second; span > ShopeeOrderListMaxRange {
+			t.Errorf("window %d spans %s", windows, span)
+		}
+		from = q.TimeTo
+	}
+	if windows != 3 {
+		t.Errorf("%d windows for %d days, want 3", windows, syncLookbackDays)
+	}
+	if to := time.Unix(from, 0); to.Before(e.Now.Add(-time.Second)) {
+		t.Errorf("last window ends at %v, want now", to)
+	}
+	if res.LastUpdateTime.Unix() != from {
+		t.Errorf("high-water mark %v, want the end of the last window %v", res.LastUpdateTime, time.Unix(from, 0))
+	}
+}
+
+func TestSyncShopeeOrderFollowsCursorAndBatches(t *testing.T) {
+	e := newSyncEnv(t)
+	if _, err := e.sync(); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+
+	// a page after the first one carries the cursor of the page before it
+	pages := 0
+	for i, q := range e.Adapter.queries {
+		if q.PageSize != ShopeeOrderListPageSize {
+			t.Errorf("page size %d, want %d", q.PageSize, ShopeeOrderListPageSize)
+		}
+		if q.CursorPage == "" {
+			continue
+		}
+		pages++
+		prev := e.Adapter.queries[i-1]
+		if q.TimeFrom != prev.TimeFrom || q.TimeTo != prev.TimeTo {
+			t.Errorf("cursor %s left its window", q.CursorPage)
+		}
+	}
+	if pages == 0 {
+		t.Error("no window was read past its first page")
+	}
+
+	total := 0
+	for _, n := range e.Adapter.batches {
+		if n > ShopeeOrderDetailMaxBatch {
+			t.Errorf("detail batch of %d, max %d", n, ShopeeOrderDetailMaxBatch)
+		}
+		total += n
+	}
+	if total < syncOrders {
+		t.Errorf("%d order details read, want at least %d", total, syncOrders)
+	}
+	if len(e.Adapter.batches) < syncOrders/ShopeeOrderDetailMaxBatch {
+		t.Errorf("%d detail batches, want at least %d", len(e.Adapter.batches), syncOrders/ShopeeOrderDetailMaxBatch)
+	}
+}
+
+func TestSyncShopeeOrderResumesFromHighWaterMark(t *testing.T) {
+	e := newSyncEnv(t)
+	mark := e.Now.Add(-24 * time.Hour).Truncate(time.Second)
+	e.State.state = &ShopeeOrderSyncModel{ShopID: syncShopID, LastUpdateTime: mark, Status: ORDER_SYNC_IDLE, TotalSyncOrders: 500}
+
+	if _, err := e.sync(); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if len(e.Adapter.queries) == 0 {
+		t.Fatal("no order list query")
+	}
+	if got, want := e.Adapter.queries[0].TimeFrom, mark.Add(-shopeeOrderSyncOverlap).Unix(); got != want {
+		t.Errorf("resumed from %d, want %d (mark minus the overlap)", got, want)
+	}
+	if e.State.state.TotalSyncOrders < 500 {
+		t.Errorf("total %d, the previous runs were dropped", e.State.state.TotalSyncOrders)
+	}
+}
+
+func TestSyncShopeeOrderStateReadError(t *testing.T) {
+	e := newSyncEnv(t)
+	mark := e.Now.Add(-time.Hour)
+	e.State.state = &ShopeeOrderSyncModel{ShopID: syncShopID, LastUpdateTime: mark}
+	e.State.getErr = errors.New("server selection timeout")
+
+	if _, err := e.sync(); err == nil {
+		t.Fatal("sync went on without its state")
+	}
+	if e.State.saves != 0 || !e.State.state.LastUpdateTime.Equal(mark) {
+		t.Errorf("state overwritten after a read error : %d saves, mark %v", e.State.saves, e.State.state.LastUpdateTime)
+	}
+	if len(e.Adapter.queries) != 0 {
+		t.Errorf("%d order list queries, want none", len(e.Adapter.queries))
+	}
+}
@@ -2,6 +2,7 @@ package shopee
 
 import (
 	"context"
+	"ecommerce/internal/pkg"
 	"errors"
 	"strings"
 	"time"
@@ -14,20 +15,71 @@ import (
 
 // -- ShopeeAuthRepository
 // -- ShopeeAuthResponseRepository
+var ErrShopeeShopNotFound = errors.New("ShopID not found")
+
 type ShopeeAuthRepository interface {
 	InitRepository() error
-	CreateShopeeAuth(partnerID string, shopId string, codeID string, accessToken string, refreshToken string) (*ShopeeAuthModel, error)
-	GetShopeeShopAuthByShopId(shopId string) (*ShopeeAuthModel, error)
-  UpdateShopeeShopAuth(partnerID string , code string,shopID string ,accessToken string, refreshToken string) (*ShopeeAuthModel, error)
+	// every query is scoped to the tenant of ctx (pkg.TenantFilter), unscoped for workers / push
+	CreateShopeeAuth(ctx context.Context, partnerID string, shopId string, codeID string, accessToken string, refreshToken string) (*ShopeeAuthModel, error)
+	GetShopeeShopAuthByShopId(ctx context.Context, shopId string) (*ShopeeAuthModel, error)
+  UpdateShopeeShopAuth(ctx context.Context, partnerID string , code string,shopID string ,accessToken string, refreshToken string) (*ShopeeAuthModel, error)
+
+  // refresh worker
+  GetShopeeShopAuthExpireBefore(ctx context.Context, before time.Time) ([]ShopeeAuthModel, error)
+  GetShopeeShopAuthNeedReauth(ctx context.Context) ([]ShopeeAuthModel, error)
+  // order sync worker : shops holding a usable token
+  GetShopeeShopAuthActive(ctx context.Context) ([]ShopeeAuthModel, error)
+  UpdateShopeeShopAuthRefreshFailed(ctx context.Context, shopID string, reason string, needReauth bool) (*ShopeeAuthModel, error)
+
+  // push : authorization canceled / expiring
+  UpdateShopeeShopAuthNeedReauth(ctx context.Context, shopID string, reason string) (*ShopeeAuthModel, error)
+  UpdateShopeeShopAuthExpireAt(ctx context.Context, shopID string, expireAt time.Time) (*ShopeeAuthModel, error)
+
+  // cmd/rotatekeys : re-encrypt access / refresh tokens with the active master key
+  RotateShopeeAuthSecrets(ctx context.Context, dryRun bool) (*pkg.SecretRotationResult, error)
 }
 
+// access_token / refresh_token are sealed by cipher on write and opened on read
 type shopeeAuthRepo struct {
 	logger *zap.Logger
 	db     *mongo.Collection
+	cipher pkg.ISecretCipher
+}
+
+func NewShopeeAuthRepository(db *mongo.Collection, log *zap.Logger, cipher pkg.ISecretCipher) ShopeeAuthRepository {
+	return &shopeeAuthRepo{db: db, logger: log, cipher: cipher}
 }
 
-func NewShopeeAuthRepository(db *mongo.Collection, log *zap.Logger) ShopeeAuthRepository {
-	return &shopeeAuthRepo{db: db, logger: log}
+func (r *shopeeAuthRepo) sealTokens(accessToken string, refreshToken string) (string, string, error) {
+  access, err := r.cipher.Encrypt(accessToken)
+  if err != nil { return "", "", errors.New("ShopeeAuthRepository: failed to encrypt access_token") }
+  refresh, err := r.cipher.Encrypt(refreshToken)
+  if err != nil { return "", "", errors.New("ShopeeAuthRepository: failed to encrypt refresh_token") }
+  return access, refresh, nil
+}
+
+func (r *shopeeAuthRepo) openModel(model *ShopeeAuthModel) error {
+  access, err := r.cipher.Decrypt(model.AccessToken)
+  if err == nil {
+    model.RefreshToken, err = r.cipher.Decrypt(model.RefreshToken)
+  }
+  if err != nil {
+    r.logger.Error("ShopeeAuthRepository: failed to decrypt tokens", zap.String("shop_id", model.ShopID), zap.Error(err))
+    return errors.New("ShopeeAuthRepository: failed to decrypt tokens")
+  }
+  model.AccessToken = access
+  return nil
+}
+
+// openModels : a shop whose tokens can't be opened (kid dropped too early) is left out of
+// worker listings instead of being flagged need_reauth for a config mistake
+func (r *shopeeAuthRepo) openModels(models []ShopeeAuthModel) []ShopeeAuthModel {
+  res := make([]ShopeeAuthModel, 0, len(models))
+  for i := range models {
+    if err := r.openModel(&models[i]); err != nil { continue }
+    res = append(res, models[i])
+  }
+  return res
 }
 
 func (r *shopeeAuthRepo) InitRepository() error {
@@ -49,7 +101,11 @@ func (r *shopeeAuthRepo) InitRepository() error {
 	return nil
 }
 
-func (r *shopeeAuthRepo) CreateShopeeAuth(partnerID string,shopId string, codeID string, accessToken string, refreshToken string) (*ShopeeAuthModel, error) {
+func (r *shopeeAuthRepo) CreateShopeeAuth(ctx context.Context, partnerID string,shopId string, codeID string, accessToken string, refreshToken string) (*ShopeeAuthModel, error) {
+	tenantID, err := pkg.TenantStamp(ctx)
+	if err != nil {
+		return nil, err
+	}
 	data := &ShopeeAuthModel{
     PartnerID: partnerID,
 		ShopID:       shopId,
@@ -59,21 +115,32 @@ func (r *shopeeAuthRepo) CreateShopeeAuth(partnerID string,shopId string, codeID
 		ExpiredAt:    time.Now().Add(time.Hour * 4),
 		CreatedBy:    "admin",
 		CreatedAt:    time.Now(),
+		TenantID:     tenantID,
 	}
-	if _, err := r.db.InsertOne(context.TODO(), data); err != nil {
+	if refreshToken != "" {
+		data.RefreshTokenExpiredAt = time.Now().Add(ShopeeRefreshTokenLifetime)
+	}
+	sealed := *data
+	if sealed.AccessToken, sealed.RefreshToken, err = r.sealTokens(accessToken, refreshToken); err != nil {
+		return nil, err
+	}
+	if _, err := r.db.InsertOne(ctx, sealed); err != nil {
 		return nil, errors.New("failed to insert shopee auth repository")
 	}
 	return data, nil
 }
 
-func (r *shopeeAuthRepo) GetShopeeShopAuthByShopId(shopId string) (*ShopeeAuthModel, error) {
+func (r *shopeeAuthRepo) GetShopeeShopAuthByShopId(ctx context.Context, shopId string) (*ShopeeAuthModel, error) {
 
 	if shopId == "" {
 		return nil, errors.New("shopId is required")
 	}
 
-	res := r.db.FindOne(context.TODO(), bson.M{"shop_id": shopId})
+	res := r.db.FindOne(ctx, pkg.TenantFilter(ctx, bson.M{"shop_id": shopId}))
 
+	if errors.Is(res.Err(), mongo.ErrNoDocuments) {
+		return nil, ErrShopeeShopNotFound
+	}
 	if res.Err() != nil {
     errorLog := res.Err().Error()
     parseError := strings.SplitN(errorLog, ":", 2)
@@ -91,38 +158,51 @@ func (r *shopeeAuthRepo) GetShopeeShopAuthByShopId(shopId string) (*ShopeeAuthMo
 	if err := res.Decode(&data); err != nil {
 		return nil, err
 	}
+	if err := r.openModel(&data); err != nil {
+		return nil, err
+	}
 
   // r.logger.Debug("GetShopeeShopAuthByShopId", zap.Any("data:", data))
 
 	return &data, nil
 }
 
-func (r *shopeeAuthRepo) UpdateShopeeShopAuth(partnerID string , code string,shopID string ,accessToken string, refreshToken string) (*ShopeeAuthModel, error) {
+func (r *shopeeAuthRepo) UpdateShopeeShopAuth(ctx context.Context, partnerID string , code string,shopID string ,accessToken string, refreshToken string) (*ShopeeAuthModel, error) {
 
   if shopID == "" || accessToken == "" || refreshToken == "" || partnerID == ""{
     return nil, errors.New("shopId is required")
   }
 
-  filter := bson.M{"partner_id": partnerID, "shop_id": shopID}
-
+  filter := pkg.TenantFilter(ctx, bson.M{"partner_id": partnerID, "shop_id": shopID})
 
+  sealedAccess, sealedRefresh, err := r.sealTokens(accessToken, refreshToken)
+  if err != nil { return nil, err }
 
   update := bson.M{
-      "access_token" : accessToken,
-      "refresh_token": refreshToken,
+      "access_token" : sealedAccess,
+      "refresh_token": sealedRefresh,
       "expired_at"   : time.Now().Add(time.Hour * 4),
       "modified_at"  : time.Now(),
       "modified_by"  : "admin",
+
+      // successful exchange : reset refresh state
+      "refresh_token_expired_at": time.Now().Add(ShopeeRefreshTokenLifetime),
+      "last_refresh_at"   : time.Now(),
+      "refresh_fail_count": 0,
+      "last_refresh_error": "",
+      "need_reauth"       : false,
   }
 
   if code != "" {
     update["code"] = code
+    // new seller authorization
+    update["auth_expire_at"] = time.Time{}
   }
 
   opt := options.FindOneAndUpdate().SetReturnDocument(options.After)
   var updateShopeeAuth ShopeeAuthModel
 
-  err := r.db.FindOneAndUpdate(context.TODO(), filter,bson.M{"$set": update} , opt).Decode(&updateShopeeAuth)
+  err = r.db.FindOneAndUpdate(ctx, filter,bson.M{"$set": update} , opt).Decode(&updateShopeeAuth)
   if err != nil {
     errorLog := err.Error()
     parseError := strings.SplitN(errorLog, ":", 2)
@@ -133,22 +213,172 @@ func (r *shopeeAuthRepo) UpdateShopeeShopAuth(partnerID string , code string,sho
     }
   return nil, errors.New("repository.ShopeeAuthRepository.UpdateShopeeShopAuth: Failed to update accesses&refresh token")
   }
+  // just written : hand back what the caller gave rather than decrypting it again
+  updateShopeeAuth.AccessToken = accessToken
+  updateShopeeAuth.RefreshToken = refreshToken
   return &updateShopeeAuth, nil
 }
 
+func (r *shopeeAuthRepo) GetShopeeShopAuthExpireBefore(ctx context.Context, before time.Time) ([]ShopeeAuthModel, error) {
+  filter := bson.M{
+    "expired_at"   : bson.M{"$lt": before},
+    "refresh_token": bson.M{"$ne": ""},
+    "need_reauth"  : bson.M{"$ne": true},
+  }
+
+  cursor, err := r.db.Find(ctx, pkg.TenantFilter(ctx, filter))
+  if err != nil { return nil, err }
+  defer cursor.Close(ctx)
+
+  var res []ShopeeAuthModel
+  if err := cursor.All(ctx, &res); err != nil { return nil, err }
+  return r.openModels(res), nil
+}
+
+func (r *shopeeAuthRepo) GetShopeeShopAuthNeedReauth(ctx context.Context) ([]ShopeeAuthModel, error) {
+  // flagged, or seller authorization ends within a week
+  filter := bson.M{"$or": []bson.M{
+    {"need_reauth": true},
+    {"auth_expire_at": bson.M{"$gt": time.Time{}, "$lt": time.Now().Add(time.Hour * 24 * 7)}},
+  }}
+  cursor, err := r.db.Find(ctx, pkg.TenantFilter(ctx, filter))
+  if err != nil { return nil, err }
+  defer cursor.Close(ctx)
+
+  res := []ShopeeAuthModel{}
+  if err := cursor.All(ctx, &res); err != nil { return nil, err }
+  return r.openModels(res), nil
+}
+
+func (r *shopeeAuthRepo) GetShopeeShopAuthActive(ctx context.Context) ([]ShopeeAuthModel, error) {
+  filter := bson.M{
+    "refresh_token": bson.M{"$ne": ""},
+    "need_reauth"  : bson.M{"$ne": true},
+  }
+
+  cursor, err := r.db.Find(ctx, pkg.TenantFilter(ctx, filter))
+  if err != nil { return nil, err }
+  defer cursor.Close(ctx)
+
+  res := []ShopeeAuthModel{}
+  if err := cursor.All(ctx, &res); err != nil { return nil, err }
+  return r.openModels(res), nil
+}
+
+func (r *shopeeAuthRepo) UpdateShopeeShopAuthRefreshFailed(ctx context.Context, shopID string, reason string, needReauth bool) (*ShopeeAuthModel, error) {
+  if shopID == "" {
+    return nil, errors.New("shopId is required")
+  }
+
+  set := bson.M{
+    "last_refresh_at"   : time.Now(),
+    "last_refresh_error": reason,
+    "modified_at"       : time.Now(),
+    "modified_by"       : "system",
+  }
+  // never clear the flag here : only a successful exchange does that
+  if needReauth {
+    set["need_reauth"] = true
+  }
+
+  update := bson.M{
+    "$set": set,
+    "$inc": bson.M{"refresh_fail_count": 1},
+  }
+
+  opt := options.FindOneAndUpdate().SetReturnDocument(options.After)
+  var updated ShopeeAuthModel
+  if err := r.db.FindOneAndUpdate(ctx, pkg.TenantFilter(ctx, bson.M{"shop_id": shopID}), update, opt).Decode(&updated); err != nil {
+    if errors.Is(err, mongo.ErrNoDocuments) {
+      return nil, ErrShopeeShopNotFound
+    }
+    return nil, err
+  }
+  if err := r.openModel(&updated); err != nil { return nil, err }
+  return &updated, nil
+}
+
+func (r *shopeeAuthRepo) UpdateShopeeShopAuthNeedReauth(ctx context.Context, shopID string, reason string) (*ShopeeAuthModel, error) {
+  return r.updateShopeeShopAuthFields(ctx, shopID, bson.M{
+    "need_reauth"       : true,
+    "last_refresh_error": reason,
+  })
+}
+
+func (r *shopeeAuthRepo) UpdateShopeeShopAuthExpireAt(ctx context.Context, shopID string, expireAt time.Time) (*ShopeeAuthModel, error) {
+  return r.updateShopeeShopAuthFields(ctx, shopID, bson.M{"auth_expire_at": expireAt})
+}
+
+func (r *shopeeAuthRepo) updateShopeeShopAuthFields(ctx context.Context, shopID string, set bson.M) (*ShopeeAuthModel, error) {
+  if shopID == "" {
+    return nil, errors.New("shopId is required")
+  }
+  set["modified_at"] = time.Now()
+  set["modified_by"] = "system"
+
+  opt := options.FindOneAndUpdate().SetReturnDocument(options.After)
+  var updated ShopeeAuthModel
+  if err := r.db.FindOneAndUpdate(ctx, pkg.TenantFilter(ctx, bson.M{"shop_id": shopID}), bson.M{"$set": set}, opt).Decode(&updated); err != nil {
+    if errors.Is(err, mongo.ErrNoDocuments) {
+      return nil, ErrShopeeShopNotFound
+    }
+    return nil, err
+  }
+  if err := r.openModel(&updated); err != nil { return nil, err }
+  return &updated, nil
+}
+
+func (r *shopeeAuthRepo) RotateShopeeAuthSecrets(ctx context.Context, dryRun bool) (*pkg.SecretRotationResult, error) {
+  res := &pkg.SecretRotationResult{Collection: r.db.Name()}
+
+  cursor, err := r.db.Find(ctx, bson.M{})
+  if err != nil { return nil, err }
+  defer cursor.Close(ctx)
+
+  for cursor.Next(ctx) {
+    var model ShopeeAuthModel
+    if err := cursor.Decode(&model); err != nil { return res, err }
+    res.Scanned++
+    if !r.cipher.NeedsRotation(model.AccessToken) && !r.cipher.NeedsRotation(model.RefreshToken) { continue }
+
+    access, err := pkg.ReencryptSecret(r.cipher, model.AccessToken)
+    if err == nil {
+      var refresh string
+      if refresh, err = pkg.ReencryptSecret(r.cipher, model.RefreshToken); err == nil {
+        if dryRun { res.Rotated++; continue }
+
+        // the refresh worker may rotate the tokens meanwhile : only swap what we read
+        filter := bson.M{"shop_id": model.ShopID, "access_token": model.AccessToken, "refresh_token": model.RefreshToken}
+        upd, err := r.db.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"access_token": access, "refresh_token": refresh}})
+        if err != nil { return res, err }
+        if upd.ModifiedCount == 1 { res.Rotated++ } else { res.Skipped++ }
+        continue
+      }
+    }
+    r.logger.Error("ShopeeAuthRepository.RotateShopeeAuthSecrets", zap.String("shop_id", model.ShopID), zap.Error(err))
+    res.Failed++
+  }
+  return res, cursor.Err()
+}
+
 // -- ShopeeAuthRequestRepository
 type ShopeeAuthRequestRepository interface {
 	InitRepository() error
-	SaveShopeeAuthRequestWithName(partnerId string, partnerKey string, partnerName string, generatedUrl string) (*ShopeeAuthRequestModel, error)
+	SaveShopeeAuthRequestWithName(ctx context.Context, partnerId string, partnerKey string, partnerName string, generatedUrl string) (*ShopeeAuthRequestModel, error)
+
+	// cmd/rotatekeys : re-encrypt partner_key with the active master key
+	RotateShopeeAuthRequestSecrets(ctx context.Context, dryRun bool) (*pkg.SecretRotationResult, error)
 }
 
+// partner_key is sealed by cipher on write
 type shopeeAuthRequestRepo struct {
 	logger *zap.Logger
 	db     *mongo.Collection
+	cipher pkg.ISecretCipher
 }
 
-func NewShopeeAuthRequestRepository(db *mongo.Collection, log *zap.Logger) ShopeeAuthRequestRepository {
-	return &shopeeAuthRequestRepo{db: db, logger: log}
+func NewShopeeAuthRequestRepository(db *mongo.Collection, log *zap.Logger, cipher pkg.ISecretCipher) ShopeeAuthRequestRepository {
+	return &shopeeAuthRequestRepo{db: db, logger: log, cipher: cipher}
 }
 
 func (r *shopeeAuthRequestRepo) InitRepository() error {
@@ -169,8 +399,13 @@ func (r *shopeeAuthRequestRepo) InitRepository() error {
 	return nil
 }
 
-func (r *shopeeAuthRequestRepo) SaveShopeeAuthRequestWithName(partnerId string, partnerKey string, partnerName string, generatedUrl string) (*ShopeeAuthRequestModel, error) {
+func (r *shopeeAuthRequestRepo) SaveShopeeAuthRequestWithName(ctx context.Context, partnerId string, partnerKey string, partnerName string, generatedUrl string) (*ShopeeAuthRequestModel, error) {
+	tenantID, err := pkg.TenantStamp(ctx)
+	if err != nil {
+		return nil, err
+	}
 	data := &ShopeeAuthRequestModel{
+		TenantID:     tenantID,
 		PartnerID:    partnerId,
 		PartnerKey:   partnerKey,
 		PartnerName:  partnerName,
@@ -178,12 +413,64 @@ func (r *shopeeAuthRequestRepo) SaveShopeeAuthRequestWithName(partnerId string,
 		CreatedBy:    "admin",
 		CreatedAt:    time.Now(),
 	}
-	if _, err := r.db.InsertOne(context.TODO(), data); err != nil {
+	sealed := *data
+	if sealed.PartnerKey, err = r.cipher.Encrypt(partnerKey); err != nil {
+		return nil, errors.New("failed to encrypt shopee auth request partner_key")
+	}
+	res, err := r.db.InsertOne(ctx, sealed)
+	if err != nil {
 		return nil, errors.New("failed to insert shopee auth request")
 	}
+	if oid, ok := res.InsertedID.(bson.ObjectID); ok {
+		data.ID = oid
+	}
 	return data, nil
 }
 
+func (r *shopeeAuthRequestRepo) RotateShopeeAuthRequestSecrets(ctx context.Context, dryRun bool) (*pkg.SecretRotationResult, error) {
+	res := &pkg.SecretRotationResult{Collection: r.db.Name()}
+
+	cursor, err := r.db.Find(ctx, pkg.TenantFilter(ctx, bson.M{}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var model ShopeeAuthRequestModel
+		if err := cursor.Decode(&model); err != nil {
+			return res, err
+		}
+		res.Scanned++
+		if !r.cipher.NeedsRotation(model.PartnerKey) {
+			continue
+		}
+
+		sealed, err := pkg.ReencryptSecret(r.cipher, model.PartnerKey)
+		if err != nil {
+			r.logger.Error("ShopeeAuthRequestRepository.RotateShopeeAuthRequestSecrets", zap.String("partner_id", model.PartnerID), zap.Error(err))
+			res.Failed++
+			continue
+		}
+		if dryRun {
+			res.Rotated++
+			continue
+		}
+
+		filter := pkg.TenantFilter(ctx, bson.M{"_id": model.ID, "partner_key": model.PartnerKey})
+		upd, err := r.db.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"partner_key": sealed}})
+		if err != nil {
+			return res, err
+		}
+		if upd.ModifiedCount == 1 {
+			res.Rotated++
+		} else {
+			res.Skipped++
+		}
+	}
+	return res, cursor.Err()
+}
+
 
 // -- ShopeeShopDetailsRepository
 // -- user : ShopeeShopDetailsModel, ShopeeShopProfileModel 
@@ -223,6 +510,9 @@ func (r *shopeeShopDetailsRepo) InitRepository() error {
 func (r *shopeeShopDetailsRepo) CreateShopeeShopDetails(ctx context.Context, shop *ShopeeShopDetailsEntityDTO) (*ShopeeShopDetailsEntityDTO,error){
   // 0 convert to model
   object := ShopeeShopEntityToModel(*shop)
+  tenantID, err := pkg.TenantStamp(ctx)
+  if err != nil { return nil, err }
+  object.TenantID = tenantID
   // 1. insert to db
   res,err := r.DB.InsertOne(ctx, object)
   if err != nil {
@@ -241,7 +531,7 @@ func (r *shopeeShopDetailsRepo)GetAllShopeeShopDetails(ctx context.Context) ([]S
 
   var models []ShopeeShopDetailsModel
 
-  cursor,err := r.DB.Find(ctx, bson.M{})
+  cursor,err := r.DB.Find(ctx, pkg.TenantFilter(ctx, bson.M{}))
   if err != nil { return nil, err}
   defer cursor.Close(ctx)
 
@@ -257,12 +547,12 @@ func (r *shopeeShopDetailsRepo)GetAllShopeeShopDetails(ctx context.Context) ([]S
 
 func (r *shopeeShopDetailsRepo)GetShopeeShopDetailsByShopID(ctx context.Context, shopID string)(*ShopeeShopDetailsEntityDTO, error) {
   var model ShopeeShopDetailsModel
-  filter := bson.M{ "shop_id": shopID}
+  filter := pkg.TenantFilter(ctx, bson.M{ "shop_id": shopID})
 
   err := r.DB.FindOne(ctx, filter).Decode(&model)
   if err != nil {
     if errors.Is(err, mongo.ErrNoDocuments) {
-      return nil, errors.New("ShopID not found")
+      return nil, ErrShopeeShopNotFound
     }
     return nil, err
   }
@@ -274,11 +564,25 @@ func (r *shopeeShopDetailsRepo)GetShopeeShopDetailsByShopID(ctx context.Context,
 
 
 // ----------------- [Repository] - Start.Collection("shop_order") ----------------
+var ErrShopeeOrderNotFound = errors.New("OrderSN not found")
 
 type ShopeeOrderRepository interface {
   InitRepository() error
   CrateShopeeOrderWithDetails(ctx context.Context, order *ShopeeOrderEntity) (*ShopeeOrderEntity,error)
   GetShopeeOrderByOrderSN(ctx context.Context, orderSN string) (*ShopeeOrderEntity,error)
+  // insert or replace by order_sn : keeps _id and created_at of an existing order ;
+  // a stored order with a newer update_time is left as is and returned
+  UpsertShopeeOrderWithDetails(ctx context.Context, order *ShopeeOrderEntity) (*ShopeeOrderEntity,error)
+  // push : only moves forward, stale update_time is ignored (nil, nil)
+  UpdateShopeeOrderStatusByOrderSN(ctx context.Context, orderSN string, status ShopeeOrderStatusEnum, updateTime time.Time) (*ShopeeOrderEntity,error)
+  UpdateShopeeOrderTrackingNumber(ctx context.Context, orderSN string, packageNumber string, trackingNo string) (*ShopeeOrderEntity,error)
+  // packageNumber "" : every package of the order
+  UpdateShopeeOrderPackageLogisticsStatus(ctx context.Context, orderSN string, packageNumber string, logisticsStatus string) (*ShopeeOrderEntity,error)
+  UpdateShopeeOrderEscrow(ctx context.Context, orderSN string, escrow *ShopeeOrderEscrowEntity) (*ShopeeOrderEntity,error)
+  // create_time in [from, to)
+  GetShopeeOrdersByShopIDAndCreateTime(ctx context.Context, shopID string, from time.Time, to time.Time) ([]ShopeeOrderEntity,error)
+  // oldest paid first ; shopIDs empty = every shop
+  GetShopeeOrdersByStatus(ctx context.Context, shopIDs []string, status ShopeeOrderStatusEnum, skip int64, limit int64) ([]ShopeeOrderEntity,error)
 }
 type shopeeOrderRepository struct {
   Logger *zap.Logger
@@ -294,6 +598,15 @@ func (r *shopeeOrderRepository)InitRepository() error {
       Keys: bson.D{{ Key: "order_sn", Value: 1}},
       Options: options.Index().SetUnique(true),
     },
+    {
+      Keys: bson.D{{ Key: "shop_id", Value: 1}, { Key: "create_time", Value: 1}},
+    },
+    {
+      Keys: bson.D{{ Key: "order_status", Value: 1}, { Key: "pay_time", Value: 1}},
+    },
+    {
+      Keys: bson.D{{ Key: "tenant_id", Value: 1}, { Key: "shop_id", Value: 1}},
+    },
   }
 
   _,err := r.DB.Indexes().CreateMany(context.TODO(), indexs)
@@ -310,6 +623,9 @@ func (r *shopeeOrderRepository)CrateShopeeOrderWithDetails(ctx context.Context,
   orderModel := ShopeeOrderEntityToModel(order)
   orderModel.CreatedAt = time.Now()
   orderModel.UpdatedAt = time.Now()
+  tenantID, err := pkg.TenantStamp(ctx)
+  if err != nil { return nil, err }
+  orderModel.TenantID = tenantID
 
   res,err := r.DB.InsertOne(ctx,orderModel)
   if err != nil { 
@@ -328,11 +644,11 @@ func (r *shopeeOrderRepository)CrateShopeeOrderWithDetails(ctx context.Context,
 func (r *shopeeOrderRepository)GetShopeeOrderByOrderSN(ctx context.Context, orderSN string) (*ShopeeOrderEntity,error) {
 
   var order ShopeeOrderModel
-  filter := bson.M{"order_sn": orderSN}
+  filter := pkg.TenantFilter(ctx, bson.M{"order_sn": orderSN})
   err := r.DB.FindOne(ctx,filter).Decode(&order)
   if err != nil {
     if errors.Is(err, mongo.ErrNoDocuments){
-      return nil, errors.New("OrderSN not found")
+      return nil, ErrShopeeOrderNotFound
     }
     return nil, err
   }
@@ -341,8 +657,272 @@ func (r *shopeeOrderRepository)GetShopeeOrderByOrderSN(ctx context.Context, orde
   return entity,nil 
 }
 
+func (r *shopeeOrderRepository)UpsertShopeeOrderWithDetails(ctx context.Context, order *ShopeeOrderEntity) (*ShopeeOrderEntity,error) {
+  if order.OrderSN == "" {
+    return nil, errors.New("OrderSN is required")
+  }
+
+  orderModel := ShopeeOrderEntityToModel(order)
+  orderModel.UpdatedAt = time.Now()
+  // order_sn of another tenant : the upsert hits the unique index instead of taking it over
+  tenantID, err := pkg.TenantStamp(ctx)
+  if err != nil { return nil, err }
+  orderModel.TenantID = tenantID
+
+  raw, err := bson.Marshal(orderModel)
+  if err != nil { return nil, err }
+  set := bson.M{}
+  if err := bson.Unmarshal(raw, &set); err != nil { return nil, err }
+  delete(set, "_id")
+  delete(set, "created_at")
+  delete(set, "created_by")
+  delete(set, "tracking_numbers") // owned by push
+  delete(set, "escrow")           // owned by escrow sync
+
+  update := bson.M{
+    "$set": set,
+    "$setOnInsert": bson.M{
+      "_id"       : orderModel.ID,
+      "created_at": orderModel.UpdatedAt,
+      "created_by": orderModel.UpdatedBy,
+    },
+  }
+
+  // a detail read before a push or a later sync must not roll the order back
+  filter := pkg.TenantFilter(ctx, bson.M{
+    "order_sn"   : order.OrderSN,
+    "update_time": bson.M{"$lte": orderModel.UpdateTime},
+  })
+  opt := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+  var updated ShopeeOrderModel
+  err = r.DB.FindOneAndUpdate(ctx, filter, update, opt).Decode(&updated)
+  if mongo.IsDuplicateKeyError(err) {
+    // the order is stored with a newer update_time (or belongs to another tenant : not found)
+    return r.GetShopeeOrderByOrderSN(ctx, order.OrderSN)
+  }
+  if err != nil {
+    r.Logger.Debug("repo.ShopeeOrder.UpsertShopeeOrderWithDetails", zap.String("order_sn", order.OrderSN), zap.Error(err))
+    return nil, err
+  }
+
+  return ShopeeOrderModelToEntity(&updated), nil
+}
+
+func (r *shopeeOrderRepository)UpdateShopeeOrderStatusByOrderSN(ctx context.Context, orderSN string, status ShopeeOrderStatusEnum, updateTime time.Time) (*ShopeeOrderEntity,error) {
+  filter := pkg.TenantFilter(ctx, bson.M{
+    "order_sn"   : orderSN,
+    "update_time": bson.M{"$lte": updateTime},
+  })
+  update := bson.M{"$set": bson.M{
+    "order_status": status,
+    "update_time" : updateTime,
+    "updated_at"  : time.Now(),
+    "updated_by"  : "shopee_push",
+  }}
+
+  opt := options.FindOneAndUpdate().SetReturnDocument(options.After)
+  var updated ShopeeOrderModel
+  err := r.DB.FindOneAndUpdate(ctx, filter, update, opt).Decode(&updated)
+  if err == nil {
+    return ShopeeOrderModelToEntity(&updated), nil
+  }
+  if !errors.Is(err, mongo.ErrNoDocuments) {
+    return nil, err
+  }
+
+  // stale push or unknown order
+  if _, err := r.GetShopeeOrderByOrderSN(ctx, orderSN); err != nil {
+    return nil, err
+  }
+  return nil, nil
+}
+
+func (r *shopeeOrderRepository)UpdateShopeeOrderTrackingNumber(ctx context.Context, orderSN string, packageNumber string, trackingNo string) (*ShopeeOrderEntity,error) {
+  if packageNumber == "" {
+    packageNumber = orderSN // single package orders may omit package_number
+  }
+
+  update := bson.M{"$set": bson.M{
+    "tracking_numbers." + packageNumber: trackingNo,
+    "updated_at": time.Now(),
+    "updated_by": "shopee_push",
+  }}
+
+  opt := options.FindOneAndUpdate().SetReturnDocument(options.After)
+  var updated ShopeeOrderModel
+  if err := r.DB.FindOneAndUpdate(ctx, pkg.TenantFilter(ctx, bson.M{"order_sn": orderSN}), update, opt).Decode(&updated); err != nil {
+    if errors.Is(err, mongo.ErrNoDocuments) {
+      return nil, ErrShopeeOrderNotFound
+    }
+    return nil, err
+  }
+  return ShopeeOrderModelToEntity(&updated), nil
+}
+
+func (r *shopeeOrderRepository)UpdateShopeeOrderPackageLogisticsStatus(ctx context.Context, orderSN string, packageNumber string, logisticsStatus string) (*ShopeeOrderEntity,error) {
+  // package_list has no bson tags : fields are stored lowercased
+  set := bson.M{
+    "updated_at": time.Now(),
+    "updated_by": "shopee_logistics",
+  }
+  opt := options.FindOneAndUpdate().SetReturnDocument(options.After)
+  if packageNumber == "" {
+    set["package_list.$[].logisticsstatus"] = logisticsStatus
+  } else {
+    set["package_list.$[pkg].logisticsstatus"] = logisticsStatus
+    opt.SetArrayFilters([]any{bson.M{"pkg.packagenumber": packageNumber}})
+  }
+
+  var updated ShopeeOrderModel
+  if err := r.DB.FindOneAndUpdate(ctx, pkg.TenantFilter(ctx, bson.M{"order_sn": orderSN}), bson.M{"$set": set}, opt).Decode(&updated); err != nil {
+    if errors.Is(err, mongo.ErrNoDocuments) {
+      return nil, ErrShopeeOrderNotFound
+    }
+    return nil, err
+  }
+  return ShopeeOrderModelToEntity(&updated), nil
+}
+
+func (r *shopeeOrderRepository)UpdateShopeeOrderEscrow(ctx context.Context, orderSN string, escrow *ShopeeOrderEscrowEntity) (*ShopeeOrderEntity,error) {
+  update := bson.M{"$set": bson.M{
+    "escrow"    : ShopeeOrderEscrowEntityToModel(escrow),
+    "updated_at": time.Now(),
+    "updated_by": "shopee_escrow",
+  }}
+
+  opt := options.FindOneAndUpdate().SetReturnDocument(options.After)
+  var updated ShopeeOrderModel
+  if err := r.DB.FindOneAndUpdate(ctx, pkg.TenantFilter(ctx, bson.M{"order_sn": orderSN}), update, opt).Decode(&updated); err != nil {
+    if errors.Is(err, mongo.ErrNoDocuments) {
+      return nil, ErrShopeeOrderNotFound
+    }
+    return nil, err
+  }
+  return ShopeeOrderModelToEntity(&updated), nil
+}
+
+func (r *shopeeOrderRepository)GetShopeeOrdersByShopIDAndCreateTime(ctx context.Context, shopID string, from time.Time, to time.Time) ([]ShopeeOrderEntity,error) {
+  filter := pkg.TenantFilter(ctx, bson.M{
+    "shop_id"    : shopID,
+    "create_time": bson.M{"$gte": from, "$lt": to},
+  })
+  opt := options.Find().SetSort(bson.D{{Key: "create_time", Value: 1}})
+
+  cursor, err := r.DB.Find(ctx, filter, opt)
+  if err != nil { return nil, err }
+  defer cursor.Close(ctx)
+
+  var orders []ShopeeOrderModel
+  if err := cursor.All(ctx, &orders); err != nil { return nil, err }
+
+  res := make([]ShopeeOrderEntity, len(orders))
+  for i := range orders {
+    res[i] = *ShopeeOrderModelToEntity(&orders[i])
+  }
+  return res, nil
+}
+
+func (r *shopeeOrderRepository)GetShopeeOrdersByStatus(ctx context.Context, shopIDs []string, status ShopeeOrderStatusEnum, skip int64, limit int64) ([]ShopeeOrderEntity,error) {
+  filter := pkg.TenantFilter(ctx, bson.M{"order_status": status})
+  if len(shopIDs) > 0 {
+    filter["shop_id"] = bson.M{"$in": shopIDs}
+  }
+  opt := options.Find().SetSort(bson.D{{Key: "pay_time", Value: 1}, {Key: "create_time", Value: 1}}).SetSkip(skip).SetLimit(limit)
+
+  cursor, err := r.DB.Find(ctx, filter, opt)
+  if err != nil { return nil, err }
+  defer cursor.Close(ctx)
+
+  var orders []ShopeeOrderModel
+  if err := cursor.All(ctx, &orders); err != nil { return nil, err }
+
+  res := make([]ShopeeOrderEntity, len(orders))
+  for i := range orders {
+    res[i] = *ShopeeOrderModelToEntity(&orders[i])
+  }
+  return res, nil
+}
+
 // ----------------- [Repository] - End.Collection("shop_order") ----------------
 
+// ----------------- [Repository] - Start.Collection("shopee_order_sync") ----------------
+var ErrShopeeOrderSyncNotFound = errors.New("ShopID not found")
+
+type ShopeeOrderSyncRepository interface {
+  InitRepository() error
+  // ErrShopeeOrderSyncNotFound : the shop was never synced
+  GetShopeeOrderSyncByShopID(ctx context.Context, shopID string) (*ShopeeOrderSyncModel, error)
+  SaveShopeeOrderSync(ctx context.Context, sync *ShopeeOrderSyncModel) (*ShopeeOrderSyncModel, error)
+}
+
+type shopeeOrderSyncRepository struct {
+  Logger *zap.Logger
+  DB *mongo.Collection
+}
+
+func NewShopeeOrderSyncRepository(db *mongo.Collection, log *zap.Logger) ShopeeOrderSyncRepository {
+  return &shopeeOrderSyncRepository{ Logger: log, DB: db, }
+}
+
+func (r *shopeeOrderSyncRepository)InitRepository() error {
+  indexs := []mongo.IndexModel{
+    {
+      Keys: bson.D{{ Key: "shop_id", Value: 1}},
+      Options: options.Index().SetUnique(true),
+    },
+  }
+
+  _,err := r.DB.Indexes().CreateMany(context.TODO(), indexs)
+  if err != nil {
+    r.Logger.Error("error creating index", zap.Error(err))
+    return errors.New("ShopeeOrderSyncRepository.InitRepository: failed creating index InitRepository")
+  }
+
+  r.Logger.Info("ShopeeOrderSyncRepository.InitRepository: index created");return nil
+}
+
+func (r *shopeeOrderSyncRepository)GetShopeeOrderSyncByShopID(ctx context.Context, shopID string) (*ShopeeOrderSyncModel, error) {
+  var sync ShopeeOrderSyncModel
+  if err := r.DB.FindOne(ctx, pkg.TenantFilter(ctx, bson.M{"shop_id": shopID})).Decode(&sync); err != nil {
+    if errors.Is(err, mongo.ErrNoDocuments) {
+      return nil, ErrShopeeOrderSyncNotFound
+    }
+    return nil, err
+  }
+  return &sync, nil
+}
+
+func (r *shopeeOrderSyncRepository)SaveShopeeOrderSync(ctx context.Context, sync *ShopeeOrderSyncModel) (*ShopeeOrderSyncModel, error) {
+  if sync.ShopID == "" {
+    return nil, errors.New("shopId is required")
+  }
+
+  update := bson.M{
+    "$set": bson.M{
+      "last_update_time"     : sync.LastUpdateTime,
+      "status"               : sync.Status,
+      "last_sync_started_at" : sync.LastSyncStartedAt,
+      "last_sync_finished_at": sync.LastSyncFinishedAt,
+      "last_sync_error"      : sync.LastSyncError,
+      "last_sync_orders"     : sync.LastSyncOrders,
+      "total_sync_orders"    : sync.TotalSyncOrders,
+      "updated_at"           : time.Now(),
+    },
+    "$setOnInsert": bson.M{"created_at": time.Now()},
+  }
+  tenantID, err := pkg.TenantStamp(ctx)
+  if err != nil { return nil, err }
+  if tenantID != "" { update["$setOnInsert"].(bson.M)["tenant_id"] = tenantID }
+
+  opt := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+  var updated ShopeeOrderSyncModel
+  if err := r.DB.FindOneAndUpdate(ctx, pkg.TenantFilter(ctx, bson.M{"shop_id": sync.ShopID}), update, opt).Decode(&updated); err != nil {
+    return nil, err
+  }
+  return &updated, nil
+}
+// ----------------- [Repository] - End.Collection("shopee_order_sync") ----------------
+
 
 // -- ShopeePartnerRepository
 // type ShopeePartnerRepository interface k
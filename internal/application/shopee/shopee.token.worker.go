@@ -0,0 +1,155 @@
+package shopee
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+
+	"ecommerce/internal/adapter"
+	"ecommerce/internal/env"
+)
+
+// ShopeeTokenRefreshWorker : keeps every shop in shopee_shop_auth supplied with a valid access_token
+// -- scan shops whose access_token expires within ShopeeTokenRefreshBefore
+// -- refresh each through the adapter and persist with UpdateShopeeShopAuth
+// -- record failures, flag need_reauth when the refresh_token is dead
+// -- back off a shop that keeps failing : interval, then doubling up to shopeeTokenRefreshMaxBackoff
+type IShopeeTokenRefreshWorker interface {
+	Start(ctx context.Context)
+	RunOnce(ctx context.Context) (*ShopeeTokenRefreshResult, error)
+}
+
+type ShopeeTokenRefreshResult struct {
+	Scanned    int       `json:"scanned"`
+	Refreshed  int       `json:"refreshed"`
+	Failed     int       `json:"failed"`
+	Skipped    int       `json:"skipped"` // still backing off from earlier failures
+	NeedReauth []string  `json:"need_reauth"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+}
+
+const shopeeTokenRefreshMaxBackoff = time.Hour
+
+type shopeeTokenRefreshWorker struct {
+	Config *env.Config
+	Logger *zap.Logger
+
+	ShopeeService        IShopeeService
+	ShopeeAuthRepository ShopeeAuthRepository
+}
+
+func NewShopeeTokenRefreshWorker(cfg *env.Config, logger *zap.Logger, service IShopeeService, auth ShopeeAuthRepository) IShopeeTokenRefreshWorker {
+	return &shopeeTokenRefreshWorker{
+		Config:               cfg,
+		Logger:               logger,
+		ShopeeService:        service,
+		ShopeeAuthRepository: auth,
+	}
+}
+
+func (w *shopeeTokenRefreshWorker) Start(ctx context.Context) {
+	interval := time.Duration(w.Config.Shopee.ShopeeTokenRefreshInterval) * time.Minute
+	if interval <= 0 {
+		w.Logger.Info("worker.ShopeeTokenRefresh : disabled")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if _, err := w.RunOnce(ctx); err != nil {
+				w.Logger.Error("worker.ShopeeTokenRefresh : RunOnce error", zap.Error(err))
+			}
+
+			select {
+			case <-ctx.Done():
+				w.Logger.Info("worker.ShopeeTokenRefresh : stopped")
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (w *shopeeTokenRefreshWorker) RunOnce(ctx context.Context) (*ShopeeTokenRefreshResult, error) {
+	res := &ShopeeTokenRefreshResult{StartedAt: time.Now(), NeedReauth: []string{}}
+
+	before := time.Now().Add(time.Duration(w.Config.Shopee.ShopeeTokenRefreshBefore) * time.Minute)
+	shops, err := w.ShopeeAuthRepository.GetShopeeShopAuthExpireBefore(ctx, before)
+	if err != nil {
+		return nil, err
+	}
+	res.Scanned = len(shops)
+
+	for _, shop := range shops {
+		if ctx.Err() != nil {
+			break
+		}
+		if wait := w.refreshBackoff(shop.RefreshFailCount); wait > 0 && time.Since(shop.LastRefreshAt) < wait {
+			res.Skipped++
+			continue
+		}
+
+		_, err := w.ShopeeService.RefreshAccessTokenByShopID(ctx, shop.ShopID)
+		if err == nil {
+			res.Refreshed++
+			continue
+		}
+
+		res.Failed++
+		needReauth := w.isRefreshTokenDead(&shop, err)
+		if needReauth {
+			res.NeedReauth = append(res.NeedReauth, shop.ShopID)
+		}
+
+		w.Logger.Error("worker.ShopeeTokenRefresh : refresh failed",
+			zap.String("shop_id", shop.ShopID),
+			zap.Bool("need_reauth", needReauth),
+			zap.Error(err))
+
+		if _, err := w.ShopeeAuthRepository.UpdateShopeeShopAuthRefreshFailed(ctx, shop.ShopID, err.Error(), needReauth); err != nil {
+			w.Logger.Error("worker.ShopeeTokenRefresh : UpdateShopeeShopAuthRefreshFailed error", zap.String("shop_id", shop.ShopID), zap.Error(err))
+		}
+	}
+
+	res.FinishedAt = time.Now()
+	w.Logger.Info("worker.ShopeeTokenRefresh : done",
+		zap.Int("scanned", res.Scanned),
+		zap.Int("refreshed", res.Refreshed),
+		zap.Int("failed", res.Failed),
+		zap.Int("skipped", res.Skipped))
+
+	return res, nil
+}
+
+// refreshBackoff : wait after failCount failures in a row ; the first one is retried on the next tick
+func (w *shopeeTokenRefreshWorker) refreshBackoff(failCount int) time.Duration {
+	if failCount < 2 {
+		return 0
+	}
+	wait := time.Duration(w.Config.Shopee.ShopeeTokenRefreshInterval) * time.Minute
+	for i := 1; i < failCount && wait < shopeeTokenRefreshMaxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, shopeeTokenRefreshMaxBackoff)
+}
+
+// refresh_token can not be recovered without the seller re-authorizing the shop
+func (w *shopeeTokenRefreshWorker) isRefreshTokenDead(shop *ShopeeAuthModel, err error) bool {
+	if !shop.RefreshTokenExpiredAt.IsZero() && time.Now().After(shop.RefreshTokenExpiredAt) {
+		return true
+	}
+
+	if errors.Is(err, adapter.ErrShopeeRefreshTokenExpired) || errors.Is(err, adapter.ErrShopeeShopNotAuthorized) {
+		return true
+	}
+
+	// +1 : the failure being recorded now
+	maxFail := w.Config.Shopee.ShopeeTokenRefreshMaxFail
+	return maxFail > 0 && shop.RefreshFailCount+1 >= maxFail
+}
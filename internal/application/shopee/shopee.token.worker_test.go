@@ -0,0 +1,293 @@
+package shopee
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"ecommerce/internal/adapter"
+	"ecommerce/internal/adapter/dto"
+	"ecommerce/internal/env"
+	"ecommerce/internal/pkg"
+)
+
+// fakeTokenAuthRepo : shopee_shop_auth with the refresh state updates of the mongo repository
+type fakeTokenAuthRepo struct {
+	ShopeeAuthRepository
+	mu    sync.Mutex
+	shops map[string]*ShopeeAuthModel
+}
+
+func (r *fakeTokenAuthRepo) GetShopeeShopAuthByShopId(ctx context.Context, shopID string) (*ShopeeAuthModel, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	shop, ok := r.shops[shopID]
+	if !ok {
+		return nil, ErrShopeeShopNotFound
+	}
+	c := *shop
+	return &c, nil
+}
+
+func (r *fakeTokenAuthRepo) GetShopeeShopAuthExpireBefore(ctx context.Context, before time.Time) ([]ShopeeAuthModel, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	res := []ShopeeAuthModel{}
+	for _, shop := range r.shops {
+		if shop.ExpiredAt.Before(before) && shop.RefreshToken != "" && !shop.NeedReauth {
+			res = append(res, *shop)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].ShopID < res[j].ShopID })
+	return res, nil
+}
+
+func (r *fakeTokenAuthRepo) UpdateShopeeShopAuth(ctx context.Context, partnerID string, code string, shopID string, accessToken string, refreshToken string) (*ShopeeAuthModel, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	shop, ok := r.shops[shopID]
+	if !ok {
+		return nil, ErrShopeeShopNotFound
+	}
+	shop.AccessToken, shop.RefreshToken = accessToken, refreshToken
+	shop.ExpiredAt = time.Now().Add(4 * time.Hour)
+	shop.LastRefreshAt = time.Now()
+	shop.RefreshFailCount = 0
+	shop.NeedReauth = false
+	c := *shop
+	return &c, nil
+}
+
+func (r *fakeTokenAuthRepo) UpdateShopeeShopAuthRefreshFailed(ctx context.Context, shopID string, reason string, needReauth bool) (*ShopeeAuthModel, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	shop, ok := r.shops[shopID]
+	if !ok {
+		return nil, ErrShopeeShopNotFound
+	}
+	shop.LastRefreshAt = time.Now()
+	shop.LastRefreshError = reason
+	shop.RefreshFailCount++
+	shop.NeedReauth = shop.NeedReauth || needReauth
+	c := *shop
+	return &c, nil
+}
+
+// fakeRefreshAdapter : counts token exchanges per shop ; a shop in hold waits until its channel is closed
+type fakeRefreshAdapter struct {
+	adapter.IShopeeService
+	mu      sync.Mutex
+	calls   map[string]int
+	err     error
+	hold    map[string]chan struct{}
+	entered chan string
+}
+
+func (a *fakeRefreshAdapter) GetRefreshToken(ctx context.Context, params *adapter.IReqShopeeAdapter, refreshToken string) (*dto.IResShopeeAuthRefreshResponse, error) {
+	a.mu.Lock()
+	a.calls[params.ShopID]++
+	n := a.calls[params.ShopID]
+	hold, err := a.hold[params.ShopID], a.err
+	a.mu.Unlock()
+
+	if hold != nil {
+		a.entered <- params.ShopID
+		<-hold
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &dto.IResShopeeAuthRefreshResponse{AccessToken: "access-" + strconv.Itoa(n), RefreshToken: "refresh-" + strconv.Itoa(n)}, nil
+}
+
+func (a *fakeRefreshAdapter) callsOf(shopID string) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.calls[shopID]
+}
+
+type tokenEnv struct {
+	Service *shopeeService
+	Worker  *shopeeTokenRefreshWorker
+	Auth    *fakeTokenAuthRepo
+	Adapter *fakeRefreshAdapter
+}
+
+// newTokenEnv : every shop expires within the refresh window
+func newTokenEnv(shops ...ShopeeAuthModel) *tokenEnv {
+	e := &tokenEnv{
+		Auth:    &fakeTokenAuthRepo{shops: map[string]*ShopeeAuthModel{}},
+		Adapter: &fakeRefreshAdapter{calls: map[string]int{}, hold: map[string]chan struct{}{}, entered: make(chan string, 4)},
+	}
+	for i := range shops {
+		shop := shops[i]
+		shop.PartnerID = syncPartnerID
+		shop.RefreshToken = "refresh-0"
+		shop.ExpiredAt = time.Now().Add(10 * time.Minute)
+		e.Auth.shops[shop.ShopID] = &shop
+	}
+	cfg := &env.Config{Shopee: &env.ShopeeConfig{
+		ShopeeTokenRefreshInterval: 5,
+		ShopeeTokenRefreshBefore:   30,
+		ShopeeTokenRefreshMaxFail:  5,
+	}}
+	e.Service = NewShopeeService(cfg, zap.NewNop(), e.Adapter, e.Auth, nil, fakeSyncPartnerRepo{}, nil, nil, nil).(*shopeeService)
+	e.Worker = NewShopeeTokenRefreshWorker(cfg, zap.NewNop(), e.Service, e.Auth).(*shopeeTokenRefreshWorker)
+	return e
+}
+
+func TestShopeeTokenRefreshWorkerBackoff(t *testing.T) {
+	now := time.Now()
+	cases := []struct {
+		shop      string
+		fails     int
+		lastTry   time.Duration // ago
+		wantTried bool
+	}{
+		{"never-failed", 0, 0, true},
+		{"failed-once", 1, time.Minute, true},
+		{"failed-twice-recently", 2, 5 * time.Minute, false}, // waits 10 minutes
+		{"failed-twice-long-ago", 2, 11 * time.Minute, true},
+		{"failed-four-times", 4, 30 * time.Minute, false}, // waits 40 minutes
+		{"failed-ten-times", 10, 61 * time.Minute, true},  // capped at an hour
+	}
+	shops := []ShopeeAuthModel{}
+	for _, c := range cases {
+		shop := ShopeeAuthModel{ShopID: c.shop, RefreshFailCount: c.fails}
+		if c.lastTry > 0 {
+			shop.LastRefreshAt = now.Add(-c.lastTry)
+		}
+		shops = append(shops, shop)
+	}
+	e := newTokenEnv(shops...)
+
+	res, err := e.Worker.RunOnce(pkg.WithoutTenant(context.Background()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	skipped := 0
+	for _, c := range cases {
+		if tried := e.Adapter.callsOf(c.shop) > 0; tried != c.wantTried {
+			t.Errorf("%s: tried %v, want %v", c.shop, tried, c.wantTried)
+		}
+		if !c.wantTried {
+			skipped++
+		}
+	}
+	if res.Skipped != skipped || res.Refreshed != len(cases)-skipped {
+		t.Errorf("skipped %d refreshed %d, want %d %d", res.Skipped, res.Refreshed, skipped, len(cases)-skipped)
+	}
+}
+
+func TestShopeeTokenRefreshWorkerNeedReauth(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"refresh token expired", fmt.Errorf("adapter : %w", adapter.ErrShopeeRefreshTokenExpired), true},
+		{"shop not authorized", adapter.ErrShopeeShopNotAuthorized, true},
+		{"rate limited", adapter.ErrShopeeRateLimited, false},
+		// only the kind counts, not the wording
+		{"untyped", errors.New("error_auth: Invalid refresh_token"), false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			e := newTokenEnv(ShopeeAuthModel{ShopID: "1"})
+			e.Adapter.err = c.err
+
+			res, err := e.Worker.RunOnce(pkg.WithoutTenant(context.Background()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Failed != 1 {
+				t.Fatalf("failed %d, want 1", res.Failed)
+			}
+			if got := e.Auth.shops["1"].NeedReauth; got != c.want || (len(res.NeedReauth) == 1) != c.want {
+				t.Errorf("need_reauth %v (listed %v), want %v", got, res.NeedReauth, c.want)
+			}
+			if e.Auth.shops["1"].RefreshFailCount != 1 {
+				t.Errorf("fail count %d, want 1", e.Auth.shops["1"].RefreshFailCount)
+			}
+		})
+	}
+}
+
+func TestRefreshAccessTokenSkipsRecentRefresh(t *testing.T) {
+	recent := time.Now().Add(-10 * time.Second)
+	e := newTokenEnv(
+		ShopeeAuthModel{ShopID: "fresh", LastRefreshAt: recent},
+		ShopeeAuthModel{ShopID: "failed", LastRefreshAt: recent, RefreshFailCount: 1},
+	)
+	ctx := pkg.WithoutTenant(context.Background())
+
+	got, err := e.Service.RefreshAccessTokenByShopID(ctx, "fresh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := e.Adapter.callsOf("fresh"); n != 0 || got.RefreshToken != "refresh-0" {
+		t.Errorf("%d exchanges, refresh token %s : want the stored one", n, got.RefreshToken)
+	}
+
+	// a recent failure is no reason to skip
+	if _, err := e.Service.RefreshAccessTokenByShopID(ctx, "failed"); err != nil {
+		t.Fatal(err)
+	}
+	if n := e.Adapter.callsOf("failed"); n != 1 {
+		t.Errorf("%d exchanges after a failure, want 1", n)
+	}
+}
+
+func TestRefreshAccessTokenLocksPerShop(t *testing.T) {
+	e := newTokenEnv(ShopeeAuthModel{ShopID: "A"}, ShopeeAuthModel{ShopID: "B"})
+	release := make(chan struct{})
+	e.Adapter.hold["A"] = release
+	ctx := pkg.WithoutTenant(context.Background())
+
+	var wg sync.WaitGroup
+	tokens := make([]string, 2)
+	for i := range tokens {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got, err := e.Service.RefreshAccessTokenByShopID(ctx, "A"); err == nil {
+				tokens[i] = got.RefreshToken
+			}
+		}()
+	}
+	<-e.Adapter.entered
+
+	// A is mid exchange : B does not wait on it
+	done := make(chan error, 1)
+	go func() {
+		_, err := e.Service.RefreshAccessTokenByShopID(ctx, "B")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("shop B waited on the refresh of shop A")
+	}
+
+	// let the second A caller reach the lock before the first one finishes
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := e.Adapter.callsOf("A"); n != 1 {
+		t.Fatalf("%d exchanges for A, want 1 : the refresh_token was used twice", n)
+	}
+	if tokens[0] != "refresh-1" || tokens[1] != "refresh-1" {
+		t.Errorf("refresh tokens %v, want both callers on the rotated one", tokens)
+	}
+}
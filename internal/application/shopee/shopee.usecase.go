@@ -8,11 +8,13 @@ import (
 	"ecommerce/internal/adapter/dto"
 	"ecommerce/internal/application/shopee/partner"
 	"ecommerce/internal/env"
+	"ecommerce/internal/pkg"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"go.uber.org/zap"
@@ -24,6 +26,9 @@ import (
 type IShopeeService interface {
 	GetAccessTokenByShopID(ctx context.Context,shopID string) (*ShopeeAuthEntity, error)
 	GetRefreshTokenOnAdapter(ctx context.Context,partnerID string, shopID string, refreshToken string) (*ShopeeAuthEntity, error)
+  // exchange refresh_token for a new access_token : serialized per shop
+  RefreshAccessTokenByShopID(ctx context.Context, shopID string) (*ShopeeAuthEntity, error)
+  GetShopeeShopAuthNeedReauth(ctx context.Context) ([]ShopeeAuthEntity, error)
 	CreateAccessAndRefreshTokenByCodeOnAdapter(ctx context.Context,partnerID string, shopID string, code string) (*IResAccessAndRefreshToken, error)
 
 	GenerateAuthLink(ctx context.Context,partnerName string, partnerId string, partnerKey string) (string, error)
@@ -39,6 +44,23 @@ type IShopeeService interface {
 	// order
 	GetShopeeOrderListByShopID(ctx context.Context,shopID string, timeType string, timeFrom string, timeTo string, status string, page string, size string) (*ShopeeOrderListEntity, error)
   GetShopeeOrderDetailByOrderSN(ctx context.Context,shopID string,orderSN string, pending string, option string) (*ShopeeOrderListWithDetailEntity, error)
+  // incremental sync : update_time windows from the shop high-water mark
+  SyncShopeeOrderByShopID(ctx context.Context, shopID string) (*ShopeeOrderSyncEntity, error)
+  GetShopeeOrderSyncByShopID(ctx context.Context, shopID string) (*ShopeeOrderSyncEntity, error)
+
+  // fetch get_order_detail and upsert : push for an order not stored yet
+  SyncShopeeOrderByOrderSN(ctx context.Context, shopID string, orderSN []string) (int, error)
+
+  // stored order created / changed (sync, order list, push) : listeners run in registration order
+  AddShopeeOrderListener(listener IShopeeOrderListener)
+  NotifyShopeeOrderSaved(ctx context.Context, order *ShopeeOrderEntity)
+
+  // adapter params with a valid access_token (refreshed when needed)
+  GetShopeeAdapterParamsByShopID(ctx context.Context, shopID string) (*adapter.IReqShopeeAdapter, error)
+
+  // outbound client health : circuit breaker per Shopee endpoint
+  GetShopeeCircuitBreakers(ctx context.Context) ([]adapter.ShopeeCircuitBreakerState, error)
+  ResetShopeeCircuitBreaker(ctx context.Context, path string) error
 
 }
 
@@ -53,6 +75,15 @@ type shopeeService struct {
 	ShopeePartnerRepository     partner.ShopeePartnerRepository
   ShopeeShopDetailsRepository ShopeeShopDetailsRepository
   ShopeeOrderRepository       ShopeeOrderRepository
+  ShopeeOrderSyncRepository   ShopeeOrderSyncRepository
+
+  // shopID -> *sync.Mutex : worker and request path must not exchange the same refresh_token twice
+  refreshLocks sync.Map
+  // shopID -> *sync.Mutex : one order sync per shop
+  syncLocks sync.Map
+
+  // set once in the container before the workers start
+  orderListeners []IShopeeOrderListener
 }
 
 func NewShopeeService(cfg *env.Config, logger *zap.Logger, adapter adapter.IShopeeService,
@@ -61,6 +92,7 @@ func NewShopeeService(cfg *env.Config, logger *zap.Logger, adapter adapter.IShop
 	shopeePartner partner.ShopeePartnerRepository,
   shopeeShop  ShopeeShopDetailsRepository,
   shopeeOrder ShopeeOrderRepository,
+  shopeeOrderSync ShopeeOrderSyncRepository,
 ) IShopeeService {
 	return &shopeeService{
 		Config:                      cfg,
@@ -71,15 +103,19 @@ func NewShopeeService(cfg *env.Config, logger *zap.Logger, adapter adapter.IShop
 		ShopeePartnerRepository:     shopeePartner,
     ShopeeShopDetailsRepository: shopeeShop,
     ShopeeOrderRepository:       shopeeOrder,
+    ShopeeOrderSyncRepository:   shopeeOrderSync,
 	}
 }
 
 func (s *shopeeService) GetAccessTokenByShopID(ctx context.Context,shopID string) (*ShopeeAuthEntity, error) {
-	data, err := s.ShopeeAuthRepository.GetShopeeShopAuthByShopId(shopID)
+	data, err := s.ShopeeAuthRepository.GetShopeeShopAuthByShopId(ctx, shopID)
 	if err != nil {
 
 		return nil, err
 	}
+  if data.NeedReauth {
+    return nil, fmt.Errorf("usecase.GetAccessTokenByShopID : shop %s must be re-authorized (%s) : %w", shopID, data.LastRefreshError, adapter.ErrShopeeRefreshTokenExpired)
+  }
   // s.Logger.Debug("usecase.GetAccessTokenByShopID", zap.Any("data", data))
 	// s.Logger.Debug("GetAccessTokenByShopID", zap.Any("data", data))
 
@@ -94,22 +130,54 @@ func (s *shopeeService) GetAccessTokenByShopID(ctx context.Context,shopID string
 	if diffTime.Minutes() < 2 {
 		// GetNew Accessstoken with adapter
     // s.Logger.Debug("diffTime", zap.Any("diffTime", diffTime.Minutes()))
-		accessToken, err := s.GetRefreshTokenOnAdapter(ctx,data.PartnerID, data.ShopID, data.RefreshToken)
+		accessToken, err := s.RefreshAccessTokenByShopID(ctx, data.ShopID)
 		if err != nil {
 			return nil, err
 		}
-    s.Logger.Debug("accessToken", zap.Any("accessToken", accessToken))
 		// s.Logger.Debug("diffTime", zap.Any("diffTime", diffTime.Minutes()))
 		return accessToken, nil
 	}
 
-	return &ShopeeAuthEntity{
-		PartnerID:    data.PartnerID,
-		ShopID:       data.ShopID,
-		AccessToken:  data.AccessToken,
-		RefreshToken: data.RefreshToken,
-		ExpiredAt:    data.ExpiredAt,
-	}, nil
+	return ShopeeAuthModelToEntity(data), nil
+}
+
+func (s *shopeeService) RefreshAccessTokenByShopID(ctx context.Context, shopID string) (*ShopeeAuthEntity, error) {
+  lock, _ := s.refreshLocks.LoadOrStore(shopID, &sync.Mutex{})
+  mu := lock.(*sync.Mutex)
+  mu.Lock()
+  defer mu.Unlock()
+
+  // refresh worker : partner lookup and token write inside the shop's tenant
+  ctx, err := s.shopScope(ctx, shopID)
+  if err != nil { return nil, err }
+
+  // reload under lock : another caller may already have rotated the refresh_token
+  data, err := s.ShopeeAuthRepository.GetShopeeShopAuthByShopId(ctx, shopID)
+  if err != nil { return nil, err }
+  if data.RefreshToken == "" {
+    return nil, fmt.Errorf("usecase.RefreshAccessTokenByShopID : shop %s has no refresh_token : %w", shopID, adapter.ErrShopeeShopNotAuthorized)
+  }
+  // rotated by a concurrent caller while we waited on the lock
+  if data.RefreshFailCount == 0 && !data.LastRefreshAt.IsZero() && time.Since(data.LastRefreshAt) < time.Minute {
+    return ShopeeAuthModelToEntity(data), nil
+  }
+
+  return s.GetRefreshTokenOnAdapter(ctx, data.PartnerID, data.ShopID, data.RefreshToken)
+}
+
+func (s *shopeeService) GetShopeeShopAuthNeedReauth(ctx context.Context) ([]ShopeeAuthEntity, error) {
+  data, err := s.ShopeeAuthRepository.GetShopeeShopAuthNeedReauth(ctx)
+  if err != nil { return nil, err }
+
+  res := []ShopeeAuthEntity{}
+  for i := range data {
+    e := ShopeeAuthModelToEntity(&data[i])
+    // never hand tokens out on the listing
+    e.AccessToken = ""
+    e.RefreshToken = ""
+    res = append(res, *e)
+  }
+  return res, nil
 }
 
 func (s *shopeeService) GetRefreshTokenOnAdapter(ctx context.Context,partnerID string, shopID string, refreshToken string) (*ShopeeAuthEntity, error) {
@@ -120,22 +188,20 @@ func (s *shopeeService) GetRefreshTokenOnAdapter(ctx context.Context,partnerID s
 		return nil, errors.New("usecase.GetRefreshTokenOnAdapter : Partner_ID not found")
 	}
 
-	dataGen, err := s.GenerateSignWithPathURL(ctx,"PUBLIC", "/auth/access_token/get", partnerData.PartnerID, partnerData.SecretKey, shopID, "", "")
-	if err != nil {
-		s.Logger.Error("usecase.GetAccessAndRefreshToken : s.GenerateSignWithPathURL error", zap.Error(err))
-		return nil, errors.New(err.Error())
+	params := &adapter.IReqShopeeAdapter{
+		PartnerID: partnerData.PartnerID,
+		SecretKey: partnerData.SecretKey,
+		ShopID:    shopID,
 	}
-  // s.Logger.Debug("dataGen", zap.Any("dataGen", dataGen))
 
-	res, err := s.ShopeeAdapter.GetRefreshToken(partnerID, shopID, refreshToken, dataGen.Sign)
+	res, err := s.ShopeeAdapter.GetRefreshToken(ctx, params, refreshToken)
 	if err != nil {
 		return nil, err
 	}
-  s.Logger.Debug("res", zap.Any("res", res))
 
 	// Create log_refresh_token
 
-	updated, err := s.ShopeeAuthRepository.UpdateShopeeShopAuth(partnerID, "",shopID, res.AccessToken, res.RefreshToken)
+	updated, err := s.ShopeeAuthRepository.UpdateShopeeShopAuth(ctx, partnerID, "",shopID, res.AccessToken, res.RefreshToken)
 	if err != nil {
 		return nil, err
 	}
@@ -143,13 +209,7 @@ func (s *shopeeService) GetRefreshTokenOnAdapter(ctx context.Context,partnerID s
 	// req to access token
 // update access token
 	// updateShopeeShopAuth.AccessToken, nil
-	return &ShopeeAuthEntity{
-		PartnerID:    updated.PartnerID,
-		ShopID:       updated.ShopID,
-		AccessToken:  updated.AccessToken,
-		RefreshToken: updated.RefreshToken,
-		ExpiredAt:    updated.ExpiredAt,
-	}, nil
+	return ShopeeAuthModelToEntity(updated), nil
 }
 
 func (s *shopeeService) GenerateAuthLink(ctx context.Context,partnerName string, partnerId string, partnerKey string) (string, error) {
@@ -257,29 +317,32 @@ func (s *shopeeService) WebhookAuthentication(ctx  context.Context,partnerId str
   partner, err := s.ShopeePartnerRepository.GetShopeePartnerByID(ctx, partnerId)
   if err != nil { return nil , err}
 
-  // 1. get sign code
-  // "/api/v2/auth/token/get"
-  sign,err := s.ShopeeAdapter.GenerateSignWithPathURL("PUBLIC", "/auth/token/get", partner.PartnerID ,partner.SecretKey,shopId,code, "" )
-  if err != nil { return nil, err}
   s.Logger.Info("usecase.WebhookAuthentication", zap.String("val" , partner.PartnerID))
 
-  // 2. get access token 
-  adapter, err := s.ShopeeAdapter.GetAccessToken(partner.PartnerID,shopId, code, sign.Sign)
+  // 1. get access token : "/api/v2/auth/token/get"
+  params := &adapter.IReqShopeeAdapter{
+    PartnerID: partner.PartnerID,
+    SecretKey: partner.SecretKey,
+    ShopID: shopId,
+    Code: &code,
+  }
+  token, err := s.ShopeeAdapter.GetAccessToken(ctx, params)
   if err != nil { return nil ,err }
   // s.Logger.Info("shopee.usecase.WebhookAuthentication", zap.String("val","xxxxxxxxxxxxxxxxxxx" ))
 
 
-  // 3. save to db --> ShopeeShopAuthRepositoryo
-  _,err = s.ShopeeAuthRepository.UpdateShopeeShopAuth(partner.PartnerID, code,shopId,adapter.AccessToken,adapter.RefreshToken  ) 
+  // 2. save to db --> ShopeeShopAuthRepositoryo
+  _,err = s.ShopeeAuthRepository.UpdateShopeeShopAuth(ctx, partner.PartnerID, code,shopId,token.AccessToken,token.RefreshToken  ) 
   if err != nil { return nil, err } 
 
   
 
-  return map[string]string{"status": "ok", "partner_id": partnerId, "code": code, "shopId": shopId, "access_token": adapter.AccessToken, "refresh_token": adapter.RefreshToken}, nil
+  // tokens stay server side : this answer lands in the seller's browser
+  return map[string]string{"status": "ok", "partner_id": partnerId, "code": code, "shopId": shopId}, nil
 }
 
 func (s *shopeeService) AddShopeeAuthRequest(ctx context.Context,partnerId string, partnerKey string, partnerName string, url string) (*ShopeeAuthRequestModel, error) {
-	data, err := s.ShopeeAuthRequestRepository.SaveShopeeAuthRequestWithName(partnerId, partnerKey, partnerName, url)
+	data, err := s.ShopeeAuthRequestRepository.SaveShopeeAuthRequestWithName(ctx, partnerId, partnerKey, partnerName, url)
 	if err != nil {
 		return nil, errors.New("failed to insert shopee auth request")
 	}
@@ -300,29 +363,35 @@ type IResAccessAndRefreshToken struct {
 	ExpiredAt    time.Time
 }
 
+// Redacted : copy safe for API answers, tokens masked
+func (r IResAccessAndRefreshToken) Redacted() *IResAccessAndRefreshToken {
+	r.AccessToken = pkg.RedactSecret(r.AccessToken)
+	r.RefreshToken = pkg.RedactSecret(r.RefreshToken)
+	return &r
+}
+
 func (s *shopeeService) CreateAccessAndRefreshTokenByCodeOnAdapter(ctx context.Context,partnerID string, shopID string, code string) (*IResAccessAndRefreshToken, error) {
 
 	// Get partner key
 	partner, err := s.ShopeePartnerRepository.GetShopeePartnerByID(ctx ,partnerID)
 	if err != nil {
-		return nil, errors.New(err.Error())
+		return nil, err
 	}
 
-	dataGen, err := s.GenerateSignWithPathURL(ctx,"PUBLIC", "/auth/token/get", partner.PartnerID, partner.SecretKey, shopID, code, "")
-	if err != nil {
-		s.Logger.Error("usecase.GetAccessAndRefreshToken : s.GenerateSignWithPathURL error", zap.Error(err))
-		return nil, errors.New(err.Error())
+	params := &adapter.IReqShopeeAdapter{
+		PartnerID: partner.PartnerID,
+		SecretKey: partner.SecretKey,
+		ShopID:    shopID,
+		Code:      &code,
 	}
 
-	// s.Logger.Debug("usecase.GetAccessAndRefreshToken : dataGen", zap.Any("dataGen", dataGen))
-
-	resApi, err := s.ShopeeAdapter.GetAccessToken(partnerID, shopID, dataGen.Code, dataGen.Sign)
+	resApi, err := s.ShopeeAdapter.GetAccessToken(ctx, params)
 	if err != nil {
 		s.Logger.Error("usecase.GetAccessAndRefreshToken : s.ShopeeAdapter.GetAccessToken error", zap.Error(err))
-		return nil, errors.New(err.Error())
+		return nil, err
 	}
 
-	resDB, error := s.ShopeeAuthRepository.CreateShopeeAuth(partnerID, shopID, code, resApi.AccessToken, resApi.RefreshToken)
+	resDB, error := s.ShopeeAuthRepository.CreateShopeeAuth(ctx, partnerID, shopID, code, resApi.AccessToken, resApi.RefreshToken)
 	if error != nil {
 		s.Logger.Error("usecase.GetAccessAndRefreshToken : s.ShopeeAuthRepository.CreateShopeeAuth error", zap.Error(error))
 		return nil, errors.New(error.Error())
@@ -361,7 +430,7 @@ func (s *shopeeService) CreateAccessAndRefreshTokenByCodeOnAdapter(ctx context.C
 
 func (s *shopeeService)GetShopeeShopDetailsByShopID(ctx context.Context, user string,shopID string) ( *ShopeeShopDetailsEntityDTO,error) {
   // 0. check in db
-  shop,err := s.ShopeeAuthRepository.GetShopeeShopAuthByShopId(shopID)
+  shop,err := s.ShopeeAuthRepository.GetShopeeShopAuthByShopId(ctx, shopID)
   if err != nil { return nil ,err}
   partner,err := s.ShopeePartnerRepository.GetShopeePartnerByID(ctx,shop.PartnerID)
   if err != nil { return nil,err}
@@ -469,14 +538,10 @@ func (s *shopeeService) GetShopeeShopListByPartnerID(ctx context.Context,partner
 		return nil, err
 	}
 
-	genData, err := s.GenerateSignWithPathURL(ctx,"PUBLIC", "/public/get_shops_by_partner", partnerData.PartnerID, partnerData.SecretKey, "", "", "")
-
-	if err != nil {
-		s.Logger.Error("usecase.GetShopeeShopListByPartnerID : s.GenerateSignWithPathURL error", zap.Error(err))
-		return nil, err
-	}
-
-	shopListData, err := s.ShopeeAdapter.GetShopByPartnerPublic(partnerData.PartnerID, genData.Sign)
+	shopListData, err := s.ShopeeAdapter.GetShopByPartnerPublic(ctx, &adapter.IReqShopeeAdapter{
+		PartnerID: partnerData.PartnerID,
+		SecretKey: partnerData.SecretKey,
+	})
 	if err != nil {
 		s.Logger.Error("usecase.GetShopeeShopListByPartnerID : s.ShopeeAdapter.GetShopByPartnerPublic error", zap.Error(err))
 		return nil, err
@@ -503,7 +568,7 @@ func (s *shopeeService) GetShopeeShopListByPartnerID(ctx context.Context,partner
 		})
 	
   // add to --> DB (stored)
-    _,err = s.ShopeeAuthRepository.CreateShopeeAuth(partnerID, string(v.ShopID), "", "", "")
+    _,err = s.ShopeeAuthRepository.CreateShopeeAuth(ctx, partnerID, string(v.ShopID), "", "", "")
     if err != nil { 
       s.Logger.Info("usecase.GetShopeeShopListByPartnerID", zap.String("info", "failed create ShopeeShopAuth "))
     }
@@ -523,15 +588,13 @@ func (s *shopeeService) GetShopeeOrderListByShopID(ctx context.Context,shopID st
 
   // accessToken if expired then send refresh_token to update access token
   // !!dont delete marktime : 4/09/2025,10:24 !!
-  // shopData,err := s.GetAccessTokenByShopID(ctx,shopID)
-  // if err != nil {
-  //   s.Logger.Error("usecase.GetShopeeOrderListByShopID : s.GetAccessTokenByShopID error", zap.Error(err))
-  //   return nil, err
-  // }
+  shopData,err := s.GetAccessTokenByShopID(ctx,shopID)
+  if err != nil {
+    s.Logger.Error("usecase.GetShopeeOrderListByShopID : s.GetAccessTokenByShopID error", zap.Error(err))
+    return nil, err
+  }
 
   // 0. check in db
-  shopData,err := s.ShopeeAuthRepository.GetShopeeShopAuthByShopId(shopID)
-  if err != nil { return nil ,err}
   partnerData,err := s.ShopeePartnerRepository.GetShopeePartnerByID(ctx,shopData.PartnerID)
   if err != nil { 
 		s.Logger.Error("usecase.GetShopeeOrderListByShopID : s.ShopeePartnerRepository.GetShopeePartnerByPartnerId error", zap.Error(err))
@@ -539,13 +602,6 @@ func (s *shopeeService) GetShopeeOrderListByShopID(ctx context.Context,shopID st
   }
 
 
-	//  ----------- set concurrent
-	genData, err := s.GenerateSignWithPathURL(ctx,"SHOP", "/order/get_order_list", partnerData.PartnerID, partnerData.SecretKey, shopData.ShopID, "", shopData.AccessToken)
-	if err != nil {
-		s.Logger.Error("usecase.GetShopeeOrderListByShopID : s.GenerateSignWithPathURL error", zap.Error(err))
-		return nil, err
-	}
-
 	// Paesr to string
 	var optsQuery dto.IOptionShopeeQuery
 	if timeType == string(dto.UPDATE_TIME) {
@@ -568,6 +624,8 @@ func (s *shopeeService) GetShopeeOrderListByShopID(ctx context.Context,shopID st
 		return nil, err
 	}
 
+	optsQuery.CursorPage = page
+
 	sizeParam, err := strconv.ParseInt(size, 10, 32)
 	optsQuery.PageSize = int32(sizeParam)
 	if err != nil {
@@ -575,7 +633,14 @@ func (s *shopeeService) GetShopeeOrderListByShopID(ctx context.Context,shopID st
 		return nil, err
 	}
 
-	orderData, err := s.ShopeeAdapter.GetOrderListByShopID(shopData.PartnerID, shopData.AccessToken, shopData.ShopID, genData.Sign, &optsQuery)
+	params := &adapter.IReqShopeeAdapter{
+		PartnerID:   partnerData.PartnerID,
+		AccessToken: shopData.AccessToken,
+		ShopID:      shopData.ShopID,
+		SecretKey:   partnerData.SecretKey,
+	}
+
+	orderData, err := s.ShopeeAdapter.GetOrderListByShopID(ctx, params, &optsQuery)
 	if err != nil { return nil, err }
   s.Logger.Debug("orderData", zap.Any("orderData", orderData))
 
@@ -589,13 +654,7 @@ func (s *shopeeService) GetShopeeOrderListByShopID(ctx context.Context,shopID st
     }
   }
 
-  params := &adapter.IReqShopeeAdapter{
-    PartnerID: partnerData.PartnerID,
-    AccessToken: shopData.AccessToken,
-    ShopID: shopData.ShopID,
-    SecretKey: partnerData.SecretKey,
-    OrderSN:orderSN,
-  }
+  params.OrderSN = orderSN
 
   // GetOrderDetails
   orderDetails,err := s.ShopeeAdapter.GetOrderDetailListByOrderSN(ctx, params)
@@ -616,139 +675,8 @@ func (s *shopeeService) GetShopeeOrderListByShopID(ctx context.Context,shopID st
       // declared 
       details := orderDetailsMap[o]
 
-      // RecipientAddress 
-      recipient := ShopeeRecipientAddressEntity{
-            Name: details.RecipientAddress.Name,
-            Phone: details.RecipientAddress.Phone,
-            Town: details.RecipientAddress.Town,
-            District: details.RecipientAddress.District,
-            City: details.RecipientAddress.City,
-            State: details.RecipientAddress.State,
-            Region: details.RecipientAddress.Region,
-            ZipCode: details.RecipientAddress.Zipcode,
-            FullAddress: details.RecipientAddress.FullAddress,
-      }
-      // check item list
-  
-      var items []ShopeeItemListEntity
-      for _,i := range details.ItemList {
-        items = append(items,ShopeeItemListEntity{
-          ItemID: strconv.FormatInt(i.ItemID, 10),
-          ItemName: i.ItemName,
-          ItemSKU: i.ItemSKU,
-          ModelID: strconv.FormatInt(i.ModelID, 10),
-          ModelName: i.ModelName,
-          ModelSKU: i.ModelSKU,
-          ModelQualityPurchased: i.ModelQtyPurchased,
-          ModelOriginPrice: i.ModelOriginalPrice,
-          ModelDiscountedPrice: i.ModelDiscountedPrice,
-          WholeSale: i.Wholesale,
-          Weight: i.Weight,
-          AddOnDeal: i.AddOnDeal,
-          PromotionType: ShopeePromotionTypeEnum(i.PromotionType),
-          PromotionID: strconv.FormatInt(i.PromotionID,10),
-          OrderItemID: strconv.FormatInt(i.OrderItemID,10),
-          PromotionGroupID: strconv.FormatInt(int64(i.PromotionGroupID), 10),
-          ImageInfo: ShopeeImageInfoEntity{ImageURL: i.ImageInfo.ImageURL},
-          ProductLocationID: i.ProductLocationID,
-          IsPrescriptionItem: i.IsPrescriptionItem,
-          IsB2COwnedItem: i.IsB2COwnedItem,
-        } )
-      } 
-
-      var packages []ShopeePackageListEntity
-      for _,p :=  range details.PackageList {
-
-        var itemInPackage []ShopeeItemListInPackageListEntity 
-        for _, iip := range p.ItemList {
-          itemInPackage = append(itemInPackage, ShopeeItemListInPackageListEntity{
-            ItemID: strconv.FormatInt(iip.ItemID,10),
-            ModelID:strconv.FormatInt(iip.ModelID,10) ,
-            ModelQuantity: iip.ModelQuantity,
-            OrderItemID: strconv.FormatInt(iip.OrderItemID,10),
-            PromotionGroupID: strconv.FormatInt(int64(iip.PromotionGroupID),10) ,
-            ProductLocationID: iip.ProductLocationID,
-          })
-        }
-
-        packages = append(packages, ShopeePackageListEntity{
-          PackageNumber: p.PackageNumber,
-          LogisticsStatus: p.LogisticsStatus,
-          LogisticsChannelID: strconv.FormatInt(p.LogisticsChannelID, 10),
-          ShippingCarrier: p.ShippingCarrier,
-          AllowSelfDesignAWB: p.AllowSelfDesignAWB,
-          ItemList: itemInPackage,
-          ParcelChargeableWeight: p.ParcelChargeableWeight,
-          GroupShipmentID: p.SortingGroup,
-        })
-      }
-
-
-      // check before  create entity
-      order := ShopeeOrderEntity{
-        OrderSN: o,
-        OrderStatus: ShopeeOrderStatusEnum(details.OrderStatus),
-        BookingSN: details.BookingSN,
-        ShopeeOrderDetailsEntity: ShopeeOrderDetailsEntity{
-          Region: details.Region,
-          Currency: details.Currency,
-          Cod: details.COD,
-          TotalAmount: details.TotalAmount,
-          PendingTerms: details.PendingTerms,
-
-          ShippingCarrier: details.ShippingCarrier,
-          PaymentMethod: details.PaymentMethod,
-          EstimatedShippingFee: details.EstimatedShippingFee,
-          MessageToSeller: details.MessageToSeller,
-          CreateTime: time.Unix(details.CreateTime, 0),
-          UpdateTime: time.Unix(details.UpdateTime, 0),
-          DaysToShip: details.DaysToShip,
-          ShipByDate: int(details.ShipByDate),
-          BuyerUserId: strconv.FormatInt(int64(details.BuyerUserID),10),
-          BuyerUsername: details.BuyerUsername,
-          RecipientAddress: recipient,
-          ActualShippingFee: details.ActualShippingFee,
-          GoodsToDeclare: details.GoodsToDeclare,
-          Note: details.Note,
-          NoteUpdateTime: time.Unix(details.NoteUpdateTime,0),
-
-          ItemList: items,
-
-          PayTime: time.Unix(details.PayTime,0),
-          DropShipper: details.Dropshipper,
-          DropShipperPhone: details.DropshipperPhone,
-          SplitUp: details.SplitUp,
-          BuyerCancelReason: details.BuyerCancelReason,
-          CancelBy: details.CancelBy,
-          CancelReason: details.CancelReason,
-          ActualShippingFeeConfirmed: details.ActualShippingFeeConfirmed,
-
-          BuyerCPFID: details.BuyerCPFID,
-          FulFillmentFlag: ShopeeFulfillmentFlagEnum(details.FulfillmentFlag),
-          PickupDoneTime: time.Unix(details.PickupDoneTime,0),
-          PackageList:packages ,
-          InvoiceData: ShopeeInvoiceDataEntity{
-            Number: details.InvoiceData.Number,
-            SeriesNumber: details.InvoiceData.SeriesNumber,
-            AccessKey: details.InvoiceData.AccessKey,
-            IssueDate: time.Unix(details.InvoiceData.IssueDate,0),
-            TotalValue: details.InvoiceData.TotalValue,
-            ProductTotalValue: details.InvoiceData.ProductsTotalValue,
-            TaxCode: details.InvoiceData.TaxCode,
-          },
-
-          CheckoutShippingCarrier: details.CheckoutShippingCarrier,
-          ReverseShippingFee: details.ReverseShippingFee,
-          OrderChargeableWeightGram: details.OrderChargeableWeight,
-          PrescriptionImages: details.PrescriptionImages,
-          PrescriptionCheckStatus: ShopeePrescriptionCheckStatusEnum(details.PrescriptionStatus),
-          BookingSN: details.BookingSN,
-          AdvancePackage: details.AdvancePackage,
-          ReturnRequestDueDate: time.Unix (details.ReturnRequestDueDate, 0),
-        },
-
-      }
-      orderComps = append(orderComps, order)
+      order := ShopeeOrderDetailDTOToEntity(o, &details)
+      orderComps = append(orderComps, *order)
     }
   }
 
@@ -762,16 +690,13 @@ func (s *shopeeService) GetShopeeOrderListByShopID(ctx context.Context,shopID st
       if shopID != "" {
       order.ShopID = shopID
       }
-      // check before save to db
-      res,err := s.ShopeeOrderRepository.GetShopeeOrderByOrderSN(ctx, order.OrderSN)
+      // save to DB : refresh status/details of orders already stored
+      res,err := s.ShopeeOrderRepository.UpsertShopeeOrderWithDetails(ctx, &order)
       if err != nil {
-      // save to DB with loop
-        res,err = s.ShopeeOrderRepository.CrateShopeeOrderWithDetails(ctx, &order) 
-        if err != nil {
-          s.Logger.Info("usecase.GetShopeeOrderListByShopID", zap.String("saveOrder", err.Error() )) 
-          continue
-        }
+        s.Logger.Info("usecase.GetShopeeOrderListByShopID", zap.String("saveOrder", err.Error() )) 
+        continue
       }
+      s.NotifyShopeeOrderSaved(ctx, res)
       newOrders = append(newOrders, *res )
     }
 
@@ -810,7 +735,7 @@ func (s *shopeeService) GetShopeeOrderDetailByOrderSN(ctx context.Context,shopID
 
 
   // 0. check in db
-  shopData,err := s.ShopeeAuthRepository.GetShopeeShopAuthByShopId(shopID)
+  shopData,err := s.GetAccessTokenByShopID(ctx,shopID)
   if err != nil { return nil ,err}
   partnerData,err := s.ShopeePartnerRepository.GetShopeePartnerByID(ctx,shopData.PartnerID)
   if err != nil { 
@@ -833,8 +758,14 @@ func (s *shopeeService) GetShopeeOrderDetailByOrderSN(ctx context.Context,shopID
   if err != nil { optionParse = false }
   optionOpts = optionParse
 
-  orderDetailData, err := s.ShopeeAdapter.GetOrderDetailByOrderSN(
-    partnerData.PartnerID, partnerData.SecretKey, shopData.AccessToken , shopData.ShopID, orderSNList, pendingOpts, optionOpts)
+  params := &adapter.IReqShopeeAdapter{
+    PartnerID: partnerData.PartnerID,
+    AccessToken: shopData.AccessToken,
+    ShopID: shopData.ShopID,
+    SecretKey: partnerData.SecretKey,
+    OrderSN: orderSNList,
+  }
+  orderDetailData, err := s.ShopeeAdapter.GetOrderDetailByOrderSN(ctx, params, pendingOpts, optionOpts)
   if err != nil { return nil, err }
 
   // s.Logger.Debug("orderDetailData", zap.Any("orderDetailData", orderDetailData))
@@ -843,3 +774,18 @@ func (s *shopeeService) GetShopeeOrderDetailByOrderSN(ctx context.Context,shopID
 
   return orderListWithDetail, nil
 }
+
+func (s *shopeeService) GetShopeeCircuitBreakers(ctx context.Context) ([]adapter.ShopeeCircuitBreakerState, error) {
+  return s.ShopeeAdapter.GetCircuitBreakers(), nil
+}
+
+var ErrShopeeCircuitBreakerNotFound = errors.New("no circuit breaker for path")
+
+// ResetShopeeCircuitBreaker : path "" closes every breaker
+func (s *shopeeService) ResetShopeeCircuitBreaker(ctx context.Context, path string) error {
+  if !s.ShopeeAdapter.ResetCircuitBreaker(path) {
+    return fmt.Errorf("%w %s", ErrShopeeCircuitBreakerNotFound, path)
+  }
+  s.Logger.Info("usecase.ResetShopeeCircuitBreaker", zap.String("path", path))
+  return nil
+}
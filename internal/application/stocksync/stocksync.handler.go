@@ -0,0 +1,152 @@
+package stocksync
+
+import (
+	"errors"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	"ecommerce/internal/adapter"
+	"ecommerce/internal/delivery/http/response"
+)
+
+// PUT body : percent omitted = 100
+type IReqStockSyncRule struct {
+	Channel     string   `json:"channel" validate:"required"`
+	ShopID      string   `json:"shop_id"` // "" = every shop of the channel
+	Percent     *float64 `json:"percent" validate:"omitempty,gte=0,lte=100"`
+	Buffer      int64    `json:"buffer" validate:"gte=0"`
+	MaxQuantity int64    `json:"max_quantity" validate:"gte=0"`
+	Warehouses  []string `json:"warehouses" validate:"dive,required"`
+	Disabled    bool     `json:"disabled"`
+}
+
+// no skus = every mapped sku (of the channel / shop when given)
+type IReqStockPush struct {
+	SKUs    []string `json:"skus" validate:"max=500,dive,required"`
+	Channel string   `json:"channel"`
+	ShopID  string   `json:"shop_id"`
+	DryRun  bool     `json:"dry_run"`
+}
+
+type IReqStockPushLogQuery struct {
+	SKU     string `query:"sku"`
+	Channel string `query:"channel"`
+	ShopID  string `query:"shop_id"`
+	Failed  bool   `query:"failed"`
+	From    string `query:"from" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	To      string `query:"to" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	Page    int    `query:"page"`
+	Size    int    `query:"size"`
+}
+
+type IStockSyncHandler interface {
+	GetStockSyncStatus(c *fiber.Ctx) error
+	PostStockPush(c *fiber.Ctx) error
+	GetStockPushLogs(c *fiber.Ctx) error
+
+	GetStockSyncRules(c *fiber.Ctx) error
+	PutStockSyncRule(c *fiber.Ctx) error
+	DeleteStockSyncRule(c *fiber.Ctx) error
+}
+
+type stockSyncHandler struct {
+	Logger   *zap.Logger
+	Validate *validator.Validate
+	Service  IStockSyncService
+}
+
+func NewStockSyncHandler(log *zap.Logger, valid *validator.Validate, srv IStockSyncService) IStockSyncHandler {
+	return &stockSyncHandler{
+		Logger:   log,
+		Validate: valid,
+		Service:  srv,
+	}
+}
+
+// actor : username set by the auth middleware
+func actor(c *fiber.Ctx) string {
+	if username, ok := c.Locals("username").(string); ok {
+		return username
+	}
+	return ""
+}
+
+func stockSyncErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrStockSyncRuleNotFound):
+		return fiber.StatusNotFound
+	case errors.Is(err, adapter.ErrMarketplaceUnsupported):
+		return fiber.StatusBadRequest
+	}
+	return fiber.StatusInternalServerError
+}
+
+func (d *stockSyncHandler) GetStockSyncStatus(c *fiber.Ctx) error {
+	return response.SuccessResponse(c, "handler.GetStockSyncStatus", d.Service.GetStockSyncStatus(c.Context()))
+}
+
+func (d *stockSyncHandler) PostStockPush(c *fiber.Ctx) error {
+	var reqBody IReqStockPush
+	if err := c.BodyParser(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostStockPush", "invalid body")
+	}
+	if err := d.Validate.Struct(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostStockPush", err)
+	}
+
+	res, err := d.Service.PushStock(c.Context(), actor(c), &reqBody)
+	if err != nil {
+		d.Logger.Error("handler.PostStockPush : PushStock", zap.Error(err))
+		return response.ErrorResponse(c, stockSyncErrorStatus(err), "handler.PostStockPush", err)
+	}
+	return response.SuccessResponse(c, "handler.PostStockPush", res)
+}
+
+func (d *stockSyncHandler) GetStockPushLogs(c *fiber.Ctx) error {
+	var query IReqStockPushLogQuery
+	if err := c.QueryParser(&query); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.GetStockPushLogs", "invalid query")
+	}
+	if err := d.Validate.Struct(&query); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.GetStockPushLogs", "from and to are RFC3339")
+	}
+
+	res, err := d.Service.GetStockPushLogs(c.Context(), &query)
+	if err != nil {
+		return response.ErrorResponse(c, stockSyncErrorStatus(err), "handler.GetStockPushLogs", err)
+	}
+	return response.SuccessResponse(c, "handler.GetStockPushLogs", res)
+}
+
+func (d *stockSyncHandler) GetStockSyncRules(c *fiber.Ctx) error {
+	res, err := d.Service.GetStockSyncRules(c.Context())
+	if err != nil {
+		return response.ErrorResponse(c, fiber.StatusInternalServerError, "handler.GetStockSyncRules", err)
+	}
+	return response.SuccessResponse(c, "handler.GetStockSyncRules", res)
+}
+
+func (d *stockSyncHandler) PutStockSyncRule(c *fiber.Ctx) error {
+	var reqBody IReqStockSyncRule
+	if err := c.BodyParser(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PutStockSyncRule", "invalid body")
+	}
+	if err := d.Validate.Struct(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PutStockSyncRule", err)
+	}
+
+	res, err := d.Service.UpsertStockSyncRule(c.Context(), actor(c), &reqBody)
+	if err != nil {
+		return response.ErrorResponse(c, stockSyncErrorStatus(err), "handler.PutStockSyncRule", err)
+	}
+	return response.SuccessResponse(c, "handler.PutStockSyncRule", res)
+}
+
+func (d *stockSyncHandler) DeleteStockSyncRule(c *fiber.Ctx) error {
+	if err := d.Service.DeleteStockSyncRule(c.Context(), c.Params("ruleID")); err != nil {
+		return response.ErrorResponse(c, stockSyncErrorStatus(err), "handler.DeleteStockSyncRule", err)
+	}
+	return response.SuccessResponse(c, "handler.DeleteStockSyncRule", fiber.Map{"deleted": c.Params("ruleID")})
+}
@@ -0,0 +1,259 @@
+package stocksync
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"go.uber.org/zap"
+
+	"ecommerce/internal/adapter/dto"
+	"ecommerce/internal/pkg"
+)
+
+type PushTriggerEnum string
+
+const (
+	// available stock moved, pushed by the worker after the debounce window
+	TRIGGER_CHANGE PushTriggerEnum = "CHANGE"
+	// POST /stock-sync/push
+	TRIGGER_MANUAL PushTriggerEnum = "MANUAL"
+)
+
+var (
+	ErrStockSyncRuleNotFound = errors.New("stock sync rule not found")
+)
+
+// StockSyncRuleModel : how much of the available stock one shop (ShopID "" = every shop of the
+// channel without its own rule) gets : floor((available - buffer) * percent / 100), capped at max
+type StockSyncRuleModel struct {
+	ID          bson.ObjectID              `bson:"_id"`
+	TenantID    string                     `bson:"tenant_id,omitempty"` // missing for the platform tenant : see pkg.TenantFilter
+	Channel     dto.MarketplaceChannelEnum `bson:"channel"`
+	ShopID      string                     `bson:"shop_id"`
+	Percent     float64                    `bson:"percent"`
+	Buffer      int64                      `bson:"buffer"`
+	MaxQuantity int64                      `bson:"max_quantity"` // 0 = no cap
+	Warehouses  []string                   `bson:"warehouses"`   // empty = every warehouse
+	Disabled    bool                       `bson:"disabled"`     // nothing is pushed to the shop
+	UpdatedAt   time.Time                  `bson:"updated_at"`
+	UpdatedBy   string                     `bson:"updated_by"`
+}
+
+// StockPushLogModel : one listing of one push, dry runs included
+type StockPushLogModel struct {
+	ID        bson.ObjectID              `bson:"_id"`
+	TenantID  string                     `bson:"tenant_id,omitempty"`
+	Trigger   PushTriggerEnum            `bson:"trigger"`
+	Channel   dto.MarketplaceChannelEnum `bson:"channel"`
+	ShopID    string                     `bson:"shop_id"`
+	SKU       string                     `bson:"sku"`
+	ItemID    string                     `bson:"item_id"`
+	VariantID string                     `bson:"variant_id"`
+	Available int64                      `bson:"available"`
+	Quantity  int64                      `bson:"quantity"`
+	RuleID    string                     `bson:"rule_id"` // "" = default allocation
+	DryRun    bool                       `bson:"dry_run"`
+	OK        bool                       `bson:"ok"`
+	Error     string                     `bson:"error"`
+	Attempt   int                        `bson:"attempt"`
+	CreatedAt time.Time                  `bson:"created_at"`
+	CreatedBy string                     `bson:"created_by"`
+}
+
+type StockPushLogFilter struct {
+	SKU     string
+	Channel dto.MarketplaceChannelEnum
+	ShopID  string
+	Failed  bool
+	From    time.Time
+	To      time.Time
+	Skip    int64
+	Limit   int64
+}
+
+type StockSyncRuleRepository interface {
+	InitRepository() error
+	// one rule per (tenant, channel, shop_id) : replaced when it exists
+	UpsertStockSyncRule(ctx context.Context, rule *StockSyncRuleModel) (*StockSyncRuleModel, error)
+	DeleteStockSyncRule(ctx context.Context, id string) error
+	GetStockSyncRules(ctx context.Context) ([]StockSyncRuleModel, error)
+}
+
+type StockPushLogRepository interface {
+	InitRepository() error
+	InsertStockPushLogs(ctx context.Context, logs []StockPushLogModel) error
+	GetStockPushLogs(ctx context.Context, filter *StockPushLogFilter) ([]StockPushLogModel, error)
+}
+
+type stockSyncRuleRepository struct {
+	Logger *zap.Logger
+	DB     *mongo.Collection
+}
+
+func NewStockSyncRuleRepository(db *mongo.Collection, log *zap.Logger) StockSyncRuleRepository {
+	return &stockSyncRuleRepository{Logger: log, DB: db}
+}
+
+func (r *stockSyncRuleRepository) InitRepository() error {
+	indexs := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "channel", Value: 1}, {Key: "shop_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+	}
+	if _, err := r.DB.Indexes().CreateMany(context.TODO(), indexs); err != nil {
+		return errors.New("StockSyncRuleRepository.InitRepository: failed create indexs")
+	}
+	r.Logger.Info("StockSyncRuleRepository.InitRepository: index created")
+	return nil
+}
+
+func (r *stockSyncRuleRepository) UpsertStockSyncRule(ctx context.Context, rule *StockSyncRuleModel) (*StockSyncRuleModel, error) {
+	filter := pkg.TenantFilter(ctx, bson.M{"channel": rule.Channel, "shop_id": rule.ShopID})
+	onInsert := bson.M{"_id": bson.NewObjectID()}
+	tenantID, err := pkg.TenantStamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if tenantID != "" {
+		onInsert["tenant_id"] = tenantID
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"percent":      rule.Percent,
+			"buffer":       rule.Buffer,
+			"max_quantity": rule.MaxQuantity,
+			"warehouses":   rule.Warehouses,
+			"disabled":     rule.Disabled,
+			"updated_at":   rule.UpdatedAt,
+			"updated_by":   rule.UpdatedBy,
+		},
+		"$setOnInsert": onInsert,
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var model StockSyncRuleModel
+	if err := r.DB.FindOneAndUpdate(ctx, filter, update, opts).Decode(&model); err != nil {
+		return nil, err
+	}
+	return &model, nil
+}
+
+func (r *stockSyncRuleRepository) DeleteStockSyncRule(ctx context.Context, id string) error {
+	objectID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrStockSyncRuleNotFound
+	}
+	res, err := r.DB.DeleteOne(ctx, pkg.TenantFilter(ctx, bson.M{"_id": objectID}))
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrStockSyncRuleNotFound
+	}
+	return nil
+}
+
+func (r *stockSyncRuleRepository) GetStockSyncRules(ctx context.Context) ([]StockSyncRuleModel, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "channel", Value: 1}, {Key: "shop_id", Value: 1}})
+	cursor, err := r.DB.Find(ctx, pkg.TenantFilter(ctx, bson.M{}), opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	rules := []StockSyncRuleModel{}
+	if err := cursor.All(ctx, &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+type stockPushLogRepository struct {
+	Logger    *zap.Logger
+	DB        *mongo.Collection
+	Retention time.Duration
+}
+
+// retention 0 : no TTL index, the log is kept forever
+func NewStockPushLogRepository(db *mongo.Collection, log *zap.Logger, retention time.Duration) StockPushLogRepository {
+	return &stockPushLogRepository{Logger: log, DB: db, Retention: retention}
+}
+
+func (r *stockPushLogRepository) InitRepository() error {
+	created := options.Index()
+	if r.Retention > 0 {
+		created.SetExpireAfterSeconds(int32(r.Retention / time.Second))
+	}
+	indexs := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "created_at", Value: -1}}, Options: created},
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "sku", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "channel", Value: 1}, {Key: "shop_id", Value: 1}, {Key: "created_at", Value: -1}}},
+	}
+	if _, err := r.DB.Indexes().CreateMany(context.TODO(), indexs); err != nil {
+		return errors.New("StockPushLogRepository.InitRepository: failed create indexs")
+	}
+	r.Logger.Info("StockPushLogRepository.InitRepository: index created")
+	return nil
+}
+
+func (r *stockPushLogRepository) InsertStockPushLogs(ctx context.Context, logs []StockPushLogModel) error {
+	if len(logs) == 0 {
+		return nil
+	}
+	tenantID, err := pkg.TenantStamp(ctx)
+	if err != nil {
+		return err
+	}
+	docs := make([]interface{}, 0, len(logs))
+	for i := range logs {
+		logs[i].ID = bson.NewObjectID()
+		logs[i].TenantID = tenantID
+		docs = append(docs, logs[i])
+	}
+	_, err = r.DB.InsertMany(ctx, docs)
+	return err
+}
+
+func (r *stockPushLogRepository) GetStockPushLogs(ctx context.Context, filter *StockPushLogFilter) ([]StockPushLogModel, error) {
+	query := pkg.TenantFilter(ctx, bson.M{})
+	if filter.SKU != "" {
+		query["sku"] = filter.SKU
+	}
+	if filter.Channel != "" {
+		query["channel"] = filter.Channel
+	}
+	if filter.ShopID != "" {
+		query["shop_id"] = filter.ShopID
+	}
+	if filter.Failed {
+		query["ok"] = false
+	}
+	created := bson.M{}
+	if !filter.From.IsZero() {
+		created["$gte"] = filter.From
+	}
+	if !filter.To.IsZero() {
+		created["$lt"] = filter.To
+	}
+	if len(created) > 0 {
+		query["created_at"] = created
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(filter.Skip).
+		SetLimit(filter.Limit)
+	cursor, err := r.DB.Find(ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	logs := []StockPushLogModel{}
+	if err := cursor.All(ctx, &logs); err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
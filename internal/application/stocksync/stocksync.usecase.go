@@ -0,0 +1,618 @@
+package stocksync
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"ecommerce/internal/adapter"
+	"ecommerce/internal/adapter/dto"
+	"ecommerce/internal/application/inventory"
+	"ecommerce/internal/application/marketplace"
+	"ecommerce/internal/application/product"
+	"ecommerce/internal/env"
+	"ecommerce/internal/pkg"
+)
+
+const (
+	logPageSize    = 50
+	logMaxPageSize = 200
+)
+
+// IStockSyncService : the ERP is the source of truth for marketplace stock.
+// -- inventory tells it which skus changed (OnStockChanged), they wait in memory for the debounce window
+// -- Flush pushes the due skus to every mapped listing, quantity allocated per shop by the rules
+// -- every listing pushed (or computed in dry run) lands in the push log
+// Pending skus do not survive a restart : POST /stock-sync/push resyncs.
+// Skus are pending per tenant : each tenant's skus are pushed under its own scope.
+type IStockSyncService interface {
+	inventory.IStockListener
+
+	Flush(ctx context.Context, now time.Time) (*StockSyncResult, error)
+	PushStock(ctx context.Context, actor string, req *IReqStockPush) ([]StockPushLogEntity, error)
+	GetStockSyncStatus(ctx context.Context) *StockSyncStatusEntity
+
+	UpsertStockSyncRule(ctx context.Context, actor string, req *IReqStockSyncRule) (*StockSyncRuleEntity, error)
+	DeleteStockSyncRule(ctx context.Context, id string) error
+	GetStockSyncRules(ctx context.Context) ([]StockSyncRuleEntity, error)
+	GetStockPushLogs(ctx context.Context, query *IReqStockPushLogQuery) ([]StockPushLogEntity, error)
+}
+
+type StockSyncResult struct {
+	SKUs       int       `json:"skus"`
+	Listings   int       `json:"listings"`
+	Failed     int       `json:"failed"`
+	Requeued   int       `json:"requeued"`
+	DryRun     bool      `json:"dry_run"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+}
+
+type StockSyncStatusEntity struct {
+	Enabled       bool       `json:"enabled"`
+	DryRun        bool       `json:"dry_run"`
+	Debounce      int64      `json:"debounce_seconds"`
+	MaxWait       int64      `json:"max_wait_seconds"`
+	Pending       []string   `json:"pending"`
+	OldestPending *time.Time `json:"oldest_pending"`
+}
+
+type StockSyncRuleEntity struct {
+	ID          string                     `json:"id"`
+	Channel     dto.MarketplaceChannelEnum `json:"channel"`
+	ShopID      string                     `json:"shop_id"`
+	Percent     float64                    `json:"percent"`
+	Buffer      int64                      `json:"buffer"`
+	MaxQuantity int64                      `json:"max_quantity"`
+	Warehouses  []string                   `json:"warehouses"`
+	Disabled    bool                       `json:"disabled"`
+	UpdatedAt   time.Time                  `json:"updated_at"`
+	UpdatedBy   string                     `json:"updated_by"`
+}
+
+type StockPushLogEntity struct {
+	ID        string                     `json:"id"`
+	Trigger   PushTriggerEnum            `json:"trigger"`
+	Channel   dto.MarketplaceChannelEnum `json:"channel"`
+	ShopID    string                     `json:"shop_id"`
+	SKU       string                     `json:"sku"`
+	ItemID    string                     `json:"item_id"`
+	VariantID string                     `json:"variant_id"`
+	Available int64                      `json:"available"`
+	Quantity  int64                      `json:"quantity"`
+	RuleID    string                     `json:"rule_id"`
+	DryRun    bool                       `json:"dry_run"`
+	OK        bool                       `json:"ok"`
+	Error     string                     `json:"error,omitempty"`
+	Attempt   int                        `json:"attempt"`
+	CreatedAt time.Time                  `json:"created_at"`
+	CreatedBy string                     `json:"created_by"`
+}
+
+func StockSyncRuleModelToEntity(model *StockSyncRuleModel) *StockSyncRuleEntity {
+	warehouses := model.Warehouses
+	if warehouses == nil {
+		warehouses = []string{}
+	}
+	return &StockSyncRuleEntity{
+		ID:          model.ID.Hex(),
+		Channel:     model.Channel,
+		ShopID:      model.ShopID,
+		Percent:     model.Percent,
+		Buffer:      model.Buffer,
+		MaxQuantity: model.MaxQuantity,
+		Warehouses:  warehouses,
+		Disabled:    model.Disabled,
+		UpdatedAt:   model.UpdatedAt,
+		UpdatedBy:   model.UpdatedBy,
+	}
+}
+
+func StockPushLogModelToEntity(model *StockPushLogModel) *StockPushLogEntity {
+	return &StockPushLogEntity{
+		ID:        model.ID.Hex(),
+		Trigger:   model.Trigger,
+		Channel:   model.Channel,
+		ShopID:    model.ShopID,
+		SKU:       model.SKU,
+		ItemID:    model.ItemID,
+		VariantID: model.VariantID,
+		Available: model.Available,
+		Quantity:  model.Quantity,
+		RuleID:    model.RuleID,
+		DryRun:    model.DryRun,
+		OK:        model.OK,
+		Error:     model.Error,
+		Attempt:   model.Attempt,
+		CreatedAt: model.CreatedAt,
+		CreatedBy: model.CreatedBy,
+	}
+}
+
+// pendingKey : sku of one tenant, tenant "" = platform (pkg.TenantStamp)
+type pendingKey struct {
+	tenant string
+	sku    string
+}
+
+// pendingSKU : first / last change seen since the last push, attempt = failed batches so far
+type pendingSKU struct {
+	first   time.Time
+	last    time.Time
+	attempt int
+}
+
+type stockSyncService struct {
+	Config *env.Config
+	Logger *zap.Logger
+
+	InventoryService   inventory.IInventoryService
+	ProductService     product.IProductService
+	MarketplaceService marketplace.IMarketplaceService
+
+	StockSyncRuleRepository StockSyncRuleRepository
+	StockPushLogRepository  StockPushLogRepository
+
+	mu      sync.Mutex
+	pending map[pendingKey]*pendingSKU
+}
+
+func NewStockSyncService(cfg *env.Config, logger *zap.Logger,
+	inventoryService inventory.IInventoryService,
+	productService product.IProductService,
+	marketplaceService marketplace.IMarketplaceService,
+	rule StockSyncRuleRepository,
+	pushLog StockPushLogRepository,
+) IStockSyncService {
+	return &stockSyncService{
+		Config:                  cfg,
+		Logger:                  logger,
+		InventoryService:        inventoryService,
+		ProductService:          productService,
+		MarketplaceService:      marketplaceService,
+		StockSyncRuleRepository: rule,
+		StockPushLogRepository:  pushLog,
+		pending:                 map[pendingKey]*pendingSKU{},
+	}
+}
+
+func (s *stockSyncService) OnStockChanged(ctx context.Context, sku string, warehouse string) {
+	if s.Config.StockSync.StockSyncDebounce <= 0 {
+		return
+	}
+	tenant, err := pkg.TenantStamp(ctx)
+	if err != nil {
+		s.Logger.Error("usecase.stocksync.OnStockChanged", zap.String("sku", sku), zap.Error(err))
+		return
+	}
+	now := time.Now()
+	key := pendingKey{tenant: tenant, sku: sku}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if p, ok := s.pending[key]; ok {
+		p.last = now
+		return
+	}
+	s.pending[key] = &pendingSKU{first: now, last: now}
+}
+
+// due : removes and returns the skus quiet for the debounce window or waiting longer than max wait,
+// grouped by tenant
+func (s *stockSyncService) due(now time.Time) map[string]map[string]int {
+	debounce := time.Duration(s.Config.StockSync.StockSyncDebounce) * time.Second
+	maxWait := time.Duration(s.Config.StockSync.StockSyncMaxWait) * time.Second
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	due := map[string]map[string]int{}
+	for key, p := range s.pending {
+		if now.Sub(p.last) >= debounce || (maxWait > 0 && now.Sub(p.first) >= maxWait) {
+			if due[key.tenant] == nil {
+				due[key.tenant] = map[string]int{}
+			}
+			due[key.tenant][key.sku] = p.attempt
+			delete(s.pending, key)
+		}
+	}
+	return due
+}
+
+// requeue : a sku changed again meanwhile is already pending, it keeps the newer entry
+func (s *stockSyncService) requeue(key pendingKey, attempt int, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.pending[key]; ok {
+		return
+	}
+	s.pending[key] = &pendingSKU{first: now, last: now, attempt: attempt}
+}
+
+// Flush : one push per tenant, under that tenant's scope ; the first error is returned once every
+// tenant had its turn
+func (s *stockSyncService) Flush(ctx context.Context, now time.Time) (*StockSyncResult, error) {
+	res := &StockSyncResult{StartedAt: time.Now(), DryRun: s.Config.StockSync.StockSyncDryRun}
+
+	var firstErr error
+	for tenant, due := range s.due(now) {
+		res.SKUs += len(due)
+		logs, failed, err := s.push(pkg.WithTenant(ctx, tenant), &pushRun{
+			SKUs:      due,
+			Trigger:   TRIGGER_CHANGE,
+			DryRun:    res.DryRun,
+			CreatedBy: "system",
+		})
+		if err != nil {
+			// nothing was pushed : every sku goes back
+			for sku, attempt := range due {
+				failed[sku] = attempt
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+
+		res.Listings += len(logs)
+		for _, l := range logs {
+			if !l.OK {
+				res.Failed++
+			}
+		}
+		for sku, attempt := range failed {
+			if attempt+1 >= s.Config.StockSync.StockSyncMaxAttempts {
+				s.Logger.Error("usecase.stocksync.Flush : giving up", zap.String("tenant_id", tenant), zap.String("sku", sku), zap.Int("attempts", attempt+1))
+				continue
+			}
+			s.requeue(pendingKey{tenant: tenant, sku: sku}, attempt+1, now)
+			res.Requeued++
+		}
+	}
+	res.FinishedAt = time.Now()
+	return res, firstErr
+}
+
+func (s *stockSyncService) PushStock(ctx context.Context, actor string, req *IReqStockPush) ([]StockPushLogEntity, error) {
+	run := &pushRun{
+		SKUs:      map[string]int{},
+		Trigger:   TRIGGER_MANUAL,
+		DryRun:    req.DryRun || s.Config.StockSync.StockSyncDryRun,
+		ShopID:    req.ShopID,
+		CreatedBy: actor,
+	}
+	if req.Channel != "" {
+		channel, ok := adapter.ParseMarketplaceChannel(req.Channel)
+		if !ok {
+			return nil, adapter.ErrMarketplaceUnsupported
+		}
+		run.Channel = channel
+	}
+
+	for _, sku := range req.SKUs {
+		run.SKUs[sku] = 0
+	}
+	if len(run.SKUs) == 0 {
+		// full resync of the mapped catalog (scoped to the channel / shop when given)
+		mappings, err := s.ProductService.GetSKUMappings(ctx, &product.IReqSKUMappingQuery{Channel: req.Channel, ShopID: req.ShopID})
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range mappings {
+			run.SKUs[m.SKU] = 0
+		}
+	}
+
+	logs, _, err := s.push(ctx, run)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]StockPushLogEntity, 0, len(logs))
+	for i := range logs {
+		out = append(out, *StockPushLogModelToEntity(&logs[i]))
+	}
+	return out, nil
+}
+
+// pushRun : skus -> attempt so far ; Channel / ShopID narrow the listings pushed
+type pushRun struct {
+	SKUs      map[string]int
+	Trigger   PushTriggerEnum
+	DryRun    bool
+	Channel   dto.MarketplaceChannelEnum
+	ShopID    string
+	CreatedBy string
+}
+
+// pushBatch : one UpdateMarketplaceStock call, logs[i] describes updates[i]
+type pushBatch struct {
+	channel dto.MarketplaceChannelEnum
+	shopID  string
+	updates []dto.MarketplaceStockUpdate
+	logs    []int
+}
+
+// push : one call per shop with every listing of the run ; returns the logs written and the skus of
+// failed batches / lookups (with their attempt) so the caller can queue them again
+func (s *stockSyncService) push(ctx context.Context, run *pushRun) ([]StockPushLogModel, map[string]int, error) {
+	failed := map[string]int{}
+	rules, err := s.rules(ctx)
+	if err != nil {
+		return nil, failed, err
+	}
+
+	skus := make([]string, 0, len(run.SKUs))
+	for sku := range run.SKUs {
+		skus = append(skus, sku)
+	}
+	sort.Strings(skus)
+
+	now := time.Now()
+	logs := []StockPushLogModel{}
+	batches := map[string]*pushBatch{}
+	order := []string{}
+	for _, sku := range skus {
+		mappings, err := s.ProductService.GetSKUMappings(ctx, &product.IReqSKUMappingQuery{SKU: sku, Channel: string(run.Channel), ShopID: run.ShopID})
+		if err != nil {
+			s.Logger.Error("usecase.stocksync.push : GetSKUMappings", zap.String("sku", sku), zap.Error(err))
+			failed[sku] = run.SKUs[sku]
+			continue
+		}
+		if len(mappings) == 0 {
+			continue
+		}
+		stock, err := s.InventoryService.GetStockBySKU(ctx, sku)
+		if err != nil {
+			s.Logger.Error("usecase.stocksync.push : GetStockBySKU", zap.String("sku", sku), zap.Error(err))
+			failed[sku] = run.SKUs[sku]
+			continue
+		}
+
+		for _, m := range mappings {
+			rule := rules.match(m.Channel, m.ShopID)
+			if rule.Disabled {
+				continue
+			}
+			available, quantity := allocate(rule, stock)
+
+			key := string(m.Channel) + ":" + m.ShopID
+			batch, ok := batches[key]
+			if !ok {
+				batch = &pushBatch{channel: m.Channel, shopID: m.ShopID}
+				batches[key] = batch
+				order = append(order, key)
+			}
+			batch.updates = append(batch.updates, dto.MarketplaceStockUpdate{ItemID: m.ItemID, VariantID: m.VariantID, SKU: sku, Quantity: quantity})
+			batch.logs = append(batch.logs, len(logs))
+
+			ruleID := ""
+			if !rule.ID.IsZero() {
+				ruleID = rule.ID.Hex()
+			}
+			logs = append(logs, StockPushLogModel{
+				Trigger:   run.Trigger,
+				Channel:   m.Channel,
+				ShopID:    m.ShopID,
+				SKU:       sku,
+				ItemID:    m.ItemID,
+				VariantID: m.VariantID,
+				Available: available,
+				Quantity:  quantity,
+				RuleID:    ruleID,
+				DryRun:    run.DryRun,
+				Attempt:   run.SKUs[sku] + 1,
+				CreatedAt: now,
+				CreatedBy: run.CreatedBy,
+			})
+		}
+	}
+
+	for _, key := range order {
+		batch := batches[key]
+		if run.DryRun {
+			for _, i := range batch.logs {
+				logs[i].OK = true
+			}
+			continue
+		}
+
+		results, err := s.MarketplaceService.UpdateMarketplaceStock(ctx, batch.channel, batch.shopID, batch.updates)
+		if err != nil {
+			s.Logger.Error("usecase.stocksync.push : UpdateMarketplaceStock", zap.String("channel", string(batch.channel)),
+				zap.String("shop_id", batch.shopID), zap.Error(err))
+			for _, i := range batch.logs {
+				logs[i].Error = err.Error()
+				failed[logs[i].SKU] = run.SKUs[logs[i].SKU]
+			}
+			continue
+		}
+		for j, r := range results {
+			if j >= len(batch.logs) {
+				break
+			}
+			logs[batch.logs[j]].OK = r.OK
+			logs[batch.logs[j]].Error = r.Error
+		}
+	}
+
+	if err := s.StockPushLogRepository.InsertStockPushLogs(ctx, logs); err != nil {
+		s.Logger.Error("usecase.stocksync.push : InsertStockPushLogs", zap.Int("logs", len(logs)), zap.Error(err))
+	}
+	return logs, failed, nil
+}
+
+// ruleSet : shop rule, else the channel wide rule, else everything available
+type ruleSet map[string]*StockSyncRuleModel
+
+var defaultRule = &StockSyncRuleModel{Percent: 100}
+
+func (r ruleSet) match(channel dto.MarketplaceChannelEnum, shopID string) *StockSyncRuleModel {
+	if rule, ok := r[string(channel)+":"+shopID]; ok {
+		return rule
+	}
+	if rule, ok := r[string(channel)+":"]; ok {
+		return rule
+	}
+	return defaultRule
+}
+
+func (s *stockSyncService) rules(ctx context.Context) (ruleSet, error) {
+	models, err := s.StockSyncRuleRepository.GetStockSyncRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	set := ruleSet{}
+	for i := range models {
+		set[string(models[i].Channel)+":"+models[i].ShopID] = &models[i]
+	}
+	return set, nil
+}
+
+// allocate : available over the rule's warehouses, then buffer, percent (rounded down) and cap ; never negative
+func allocate(rule *StockSyncRuleModel, stock *inventory.StockSummaryEntity) (int64, int64) {
+	var available int64
+	for _, w := range stock.Warehouses {
+		if len(rule.Warehouses) == 0 || contains(rule.Warehouses, w.Warehouse) {
+			available += w.Available
+		}
+	}
+	if available < 0 {
+		available = 0
+	}
+
+	quantity := available - rule.Buffer
+	if quantity < 0 {
+		quantity = 0
+	}
+	quantity = int64(math.Floor(float64(quantity) * rule.Percent / 100))
+	if rule.MaxQuantity > 0 && quantity > rule.MaxQuantity {
+		quantity = rule.MaxQuantity
+	}
+	return available, quantity
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *stockSyncService) GetStockSyncStatus(ctx context.Context) *StockSyncStatusEntity {
+	res := &StockSyncStatusEntity{
+		Enabled:  s.Config.StockSync.StockSyncDebounce > 0,
+		DryRun:   s.Config.StockSync.StockSyncDryRun,
+		Debounce: s.Config.StockSync.StockSyncDebounce,
+		MaxWait:  s.Config.StockSync.StockSyncMaxWait,
+		Pending:  []string{},
+	}
+
+	tenant, err := pkg.TenantStamp(ctx)
+	if err != nil {
+		return res
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, p := range s.pending {
+		if key.tenant != tenant {
+			continue
+		}
+		res.Pending = append(res.Pending, key.sku)
+		if res.OldestPending == nil || p.first.Before(*res.OldestPending) {
+			first := p.first
+			res.OldestPending = &first
+		}
+	}
+	sort.Strings(res.Pending)
+	return res
+}
+
+func (s *stockSyncService) UpsertStockSyncRule(ctx context.Context, actor string, req *IReqStockSyncRule) (*StockSyncRuleEntity, error) {
+	channel, ok := adapter.ParseMarketplaceChannel(req.Channel)
+	if !ok {
+		return nil, adapter.ErrMarketplaceUnsupported
+	}
+	percent := 100.0
+	if req.Percent != nil {
+		percent = *req.Percent
+	}
+
+	saved, err := s.StockSyncRuleRepository.UpsertStockSyncRule(ctx, &StockSyncRuleModel{
+		Channel:     channel,
+		ShopID:      req.ShopID,
+		Percent:     percent,
+		Buffer:      req.Buffer,
+		MaxQuantity: req.MaxQuantity,
+		Warehouses:  req.Warehouses,
+		Disabled:    req.Disabled,
+		UpdatedAt:   time.Now(),
+		UpdatedBy:   actor,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return StockSyncRuleModelToEntity(saved), nil
+}
+
+func (s *stockSyncService) DeleteStockSyncRule(ctx context.Context, id string) error {
+	return s.StockSyncRuleRepository.DeleteStockSyncRule(ctx, id)
+}
+
+func (s *stockSyncService) GetStockSyncRules(ctx context.Context) ([]StockSyncRuleEntity, error) {
+	models, err := s.StockSyncRuleRepository.GetStockSyncRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]StockSyncRuleEntity, 0, len(models))
+	for i := range models {
+		out = append(out, *StockSyncRuleModelToEntity(&models[i]))
+	}
+	return out, nil
+}
+
+func (s *stockSyncService) GetStockPushLogs(ctx context.Context, query *IReqStockPushLogQuery) ([]StockPushLogEntity, error) {
+	page, size := query.Page, query.Size
+	if page <= 0 {
+		page = 1
+	}
+	if size <= 0 {
+		size = logPageSize
+	}
+	if size > logMaxPageSize {
+		size = logMaxPageSize
+	}
+	filter := &StockPushLogFilter{
+		SKU:    query.SKU,
+		ShopID: query.ShopID,
+		Failed: query.Failed,
+		Skip:   int64((page - 1) * size),
+		Limit:  int64(size),
+	}
+	if query.Channel != "" {
+		channel, ok := adapter.ParseMarketplaceChannel(query.Channel)
+		if !ok {
+			return nil, adapter.ErrMarketplaceUnsupported
+		}
+		filter.Channel = channel
+	}
+	if query.From != "" {
+		filter.From, _ = time.Parse(time.RFC3339, query.From)
+	}
+	if query.To != "" {
+		filter.To, _ = time.Parse(time.RFC3339, query.To)
+	}
+
+	models, err := s.StockPushLogRepository.GetStockPushLogs(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]StockPushLogEntity, 0, len(models))
+	for i := range models {
+		out = append(out, *StockPushLogModelToEntity(&models[i]))
+	}
+	return out, nil
+}
@@ -0,0 +1,79 @@
+package stocksync
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+
+	"ecommerce/internal/env"
+)
+
+// StockSyncWorker : flushes the pending skus of the stock sync service
+// -- ticks at half the debounce window (at least every second)
+// -- a tick with nothing due costs nothing
+type IStockSyncWorker interface {
+	Start(ctx context.Context)
+	RunOnce(ctx context.Context) (*StockSyncResult, error)
+}
+
+type stockSyncWorker struct {
+	Config *env.Config
+	Logger *zap.Logger
+
+	StockSyncService IStockSyncService
+}
+
+func NewStockSyncWorker(cfg *env.Config, logger *zap.Logger, service IStockSyncService) IStockSyncWorker {
+	return &stockSyncWorker{
+		Config:           cfg,
+		Logger:           logger,
+		StockSyncService: service,
+	}
+}
+
+func (w *stockSyncWorker) Start(ctx context.Context) {
+	debounce := time.Duration(w.Config.StockSync.StockSyncDebounce) * time.Second
+	if debounce <= 0 {
+		w.Logger.Info("worker.StockSync : disabled")
+		return
+	}
+	interval := debounce / 2
+	if interval < time.Second {
+		interval = time.Second
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				w.Logger.Info("worker.StockSync : stopped")
+				return
+			case <-ticker.C:
+			}
+
+			if _, err := w.RunOnce(ctx); err != nil {
+				w.Logger.Error("worker.StockSync : RunOnce error", zap.Error(err))
+			}
+		}
+	}()
+}
+
+func (w *stockSyncWorker) RunOnce(ctx context.Context) (*StockSyncResult, error) {
+	res, err := w.StockSyncService.Flush(ctx, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if res.SKUs > 0 {
+		w.Logger.Info("worker.StockSync : done",
+			zap.Int("skus", res.SKUs),
+			zap.Int("listings", res.Listings),
+			zap.Int("failed", res.Failed),
+			zap.Int("requeued", res.Requeued),
+			zap.Bool("dry_run", res.DryRun))
+	}
+	return res, nil
+}
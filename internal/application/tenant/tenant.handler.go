@@ -0,0 +1,126 @@
+package tenant
+
+import (
+	"errors"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	"ecommerce/internal/application/users"
+	"ecommerce/internal/delivery/http/response"
+)
+
+// POST / PUT body : PUT replaces code and name, status only when given
+type IReqTenant struct {
+	Code   string           `json:"code" validate:"required,max=32"`
+	Name   string           `json:"name" validate:"required,max=200"`
+	Status TenantStatusEnum `json:"status" validate:"omitempty,oneof=ACTIVE SUSPENDED"`
+}
+
+type ITenantHandler interface {
+	GetTenants(c *fiber.Ctx) error
+	GetTenantByID(c *fiber.Ctx) error
+	PostTenant(c *fiber.Ctx) error
+	PutTenant(c *fiber.Ctx) error
+	PostTenantOwner(c *fiber.Ctx) error
+}
+
+type tenantHandler struct {
+	Logger   *zap.Logger
+	Validate *validator.Validate
+	Service  ITenantService
+}
+
+func NewTenantHandler(log *zap.Logger, valid *validator.Validate, srv ITenantService) ITenantHandler {
+	return &tenantHandler{
+		Logger:   log,
+		Validate: valid,
+		Service:  srv,
+	}
+}
+
+func actor(c *fiber.Ctx) string {
+	if username, ok := c.Locals("username").(string); ok {
+		return username
+	}
+	return ""
+}
+
+func tenantErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrTenantNotFound):
+		return fiber.StatusNotFound
+	case errors.Is(err, ErrDuplicateTenant):
+		return fiber.StatusConflict
+	case errors.Is(err, ErrTenantForbidden):
+		return fiber.StatusForbidden
+	case errors.Is(err, ErrAdminPermission):
+		return fiber.StatusInternalServerError
+	}
+	return fiber.StatusBadRequest
+}
+
+func (d *tenantHandler) GetTenants(c *fiber.Ctx) error {
+	res, err := d.Service.GetTenants(c.Context())
+	if err != nil {
+		return response.ErrorResponse(c, tenantErrorStatus(err), "handler.GetTenants", err)
+	}
+	return response.SuccessResponse(c, "handler.GetTenants", res)
+}
+
+func (d *tenantHandler) GetTenantByID(c *fiber.Ctx) error {
+	res, err := d.Service.GetTenantByID(c.Context(), c.Params("tenantID"))
+	if err != nil {
+		return response.ErrorResponse(c, tenantErrorStatus(err), "handler.GetTenantByID", err)
+	}
+	return response.SuccessResponse(c, "handler.GetTenantByID", res)
+}
+
+func (d *tenantHandler) PostTenant(c *fiber.Ctx) error {
+	var reqBody IReqTenant
+	if err := c.BodyParser(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostTenant", "invalid body")
+	}
+	if err := d.Validate.Struct(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostTenant", err)
+	}
+
+	res, err := d.Service.CreateTenant(c.Context(), actor(c), &reqBody)
+	if err != nil {
+		return response.ErrorResponse(c, tenantErrorStatus(err), "handler.PostTenant", err)
+	}
+	return response.SuccessResponse(c, "handler.PostTenant", res)
+}
+
+func (d *tenantHandler) PutTenant(c *fiber.Ctx) error {
+	var reqBody IReqTenant
+	if err := c.BodyParser(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PutTenant", "invalid body")
+	}
+	if err := d.Validate.Struct(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PutTenant", err)
+	}
+
+	res, err := d.Service.UpdateTenant(c.Context(), c.Params("tenantID"), actor(c), &reqBody)
+	if err != nil {
+		return response.ErrorResponse(c, tenantErrorStatus(err), "handler.PutTenant", err)
+	}
+	return response.SuccessResponse(c, "handler.PutTenant", res)
+}
+
+func (d *tenantHandler) PostTenantOwner(c *fiber.Ctx) error {
+	var reqBody users.IReqCreateUserDTO
+	if err := c.BodyParser(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostTenantOwner", "invalid body")
+	}
+	if err := d.Validate.Struct(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostTenantOwner", err)
+	}
+
+	res, err := d.Service.CreateTenantOwner(c.Context(), c.Params("tenantID"), &reqBody)
+	if err != nil {
+		return response.ErrorResponse(c, tenantErrorStatus(err), "handler.PostTenantOwner", err)
+	}
+	return response.SuccessResponse(c, "handler.PostTenantOwner", res)
+}
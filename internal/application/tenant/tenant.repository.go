@@ -0,0 +1,120 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"go.uber.org/zap"
+)
+
+type TenantStatusEnum string
+
+const (
+	TENANT_ACTIVE TenantStatusEnum = "ACTIVE"
+	// members can't log in or refresh, data is kept
+	TENANT_SUSPENDED TenantStatusEnum = "SUSPENDED"
+)
+
+var (
+	ErrTenantNotFound  = errors.New("tenant not found")
+	ErrDuplicateTenant = errors.New("tenant code already exists")
+)
+
+// TenantModel : the _id hex is the tenant id carried by tokens and stored as tenant_id on scoped records ;
+// the platform tenant (pkg.DEFAULT_TENANT) has no document
+type TenantModel struct {
+	ID        bson.ObjectID    `bson:"_id"`
+	Code      string           `bson:"code"`
+	Name      string           `bson:"name"`
+	Status    TenantStatusEnum `bson:"status"`
+	CreatedAt time.Time        `bson:"created_at"`
+	CreatedBy string           `bson:"created_by"`
+	UpdatedAt time.Time        `bson:"updated_at"`
+	UpdatedBy string           `bson:"updated_by"`
+}
+
+type TenantRepository interface {
+	InitRepository() error
+	CreateTenant(ctx context.Context, tenant *TenantModel) (*TenantModel, error)
+	UpdateTenant(ctx context.Context, tenant *TenantModel) (*TenantModel, error)
+	GetTenantByID(ctx context.Context, id string) (*TenantModel, error)
+	GetTenants(ctx context.Context) ([]TenantModel, error)
+}
+
+type tenantRepository struct {
+	Logger *zap.Logger
+	DB     *mongo.Collection
+}
+
+func NewTenantRepository(db *mongo.Collection, log *zap.Logger) TenantRepository {
+	return &tenantRepository{Logger: log, DB: db}
+}
+
+func (r *tenantRepository) InitRepository() error {
+	indexs := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "code", Value: 1}}, Options: options.Index().SetUnique(true)},
+	}
+	if _, err := r.DB.Indexes().CreateMany(context.TODO(), indexs); err != nil {
+		return errors.New("TenantRepository.InitRepository: failed create indexs")
+	}
+	r.Logger.Info("TenantRepository.InitRepository: index created")
+	return nil
+}
+
+func (r *tenantRepository) CreateTenant(ctx context.Context, tenant *TenantModel) (*TenantModel, error) {
+	tenant.ID = bson.NewObjectID()
+	if _, err := r.DB.InsertOne(ctx, tenant); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, ErrDuplicateTenant
+		}
+		return nil, err
+	}
+	return tenant, nil
+}
+
+func (r *tenantRepository) UpdateTenant(ctx context.Context, tenant *TenantModel) (*TenantModel, error) {
+	res, err := r.DB.ReplaceOne(ctx, bson.M{"_id": tenant.ID}, tenant)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, ErrDuplicateTenant
+		}
+		return nil, err
+	}
+	if res.MatchedCount == 0 {
+		return nil, ErrTenantNotFound
+	}
+	return tenant, nil
+}
+
+func (r *tenantRepository) GetTenantByID(ctx context.Context, id string) (*TenantModel, error) {
+	objectID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrTenantNotFound
+	}
+	var model TenantModel
+	if err := r.DB.FindOne(ctx, bson.M{"_id": objectID}).Decode(&model); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrTenantNotFound
+		}
+		return nil, err
+	}
+	return &model, nil
+}
+
+func (r *tenantRepository) GetTenants(ctx context.Context) ([]TenantModel, error) {
+	cursor, err := r.DB.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "code", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	tenants := []TenantModel{}
+	if err := cursor.All(ctx, &tenants); err != nil {
+		return nil, err
+	}
+	return tenants, nil
+}
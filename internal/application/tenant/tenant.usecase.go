@@ -0,0 +1,216 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"ecommerce/internal/application/users"
+	"ecommerce/internal/env"
+	"ecommerce/internal/pkg"
+)
+
+var (
+	// tenants are managed by platform tenant accounts only
+	ErrTenantForbidden = errors.New("tenants are managed from the platform tenant")
+	ErrTenantSuspended = errors.New("tenant is suspended")
+	ErrAdminPermission = errors.New("admin permission is not seeded")
+)
+
+type ITenantService interface {
+	CreateTenant(ctx context.Context, actor string, req *IReqTenant) (*TenantEntity, error)
+	UpdateTenant(ctx context.Context, id string, actor string, req *IReqTenant) (*TenantEntity, error)
+	GetTenantByID(ctx context.Context, id string) (*TenantEntity, error)
+	GetTenants(ctx context.Context) ([]TenantEntity, error)
+	// CreateTenantOwner : first account of a tenant, granted the admin permission ("*:*") inside it
+	CreateTenantOwner(ctx context.Context, id string, req *users.IReqCreateUserDTO) (*users.UserAccessDTO, error)
+
+	// CheckTenantActive : login / refresh gate, the platform tenant is always active
+	CheckTenantActive(ctx context.Context, tenantID string) error
+}
+
+type TenantEntity struct {
+	ID        string           `json:"id"`
+	Code      string           `json:"code"`
+	Name      string           `json:"name"`
+	Status    TenantStatusEnum `json:"status"`
+	CreatedAt time.Time        `json:"created_at"`
+	CreatedBy string           `json:"created_by"`
+	UpdatedAt time.Time        `json:"updated_at"`
+	UpdatedBy string           `json:"updated_by"`
+}
+
+func TenantModelToEntity(model *TenantModel) *TenantEntity {
+	return &TenantEntity{
+		ID:        model.ID.Hex(),
+		Code:      model.Code,
+		Name:      model.Name,
+		Status:    model.Status,
+		CreatedAt: model.CreatedAt,
+		CreatedBy: model.CreatedBy,
+		UpdatedAt: model.UpdatedAt,
+		UpdatedBy: model.UpdatedBy,
+	}
+}
+
+type tenantService struct {
+	Config *env.Config
+	Logger *zap.Logger
+
+	UserService   users.IUserService
+	AccessService users.IAccessService
+
+	TenantRepository TenantRepository
+}
+
+func NewTenantService(cfg *env.Config, logger *zap.Logger,
+	userService users.IUserService,
+	accessService users.IAccessService,
+	tenant TenantRepository,
+) ITenantService {
+	return &tenantService{
+		Config:           cfg,
+		Logger:           logger,
+		UserService:      userService,
+		AccessService:    accessService,
+		TenantRepository: tenant,
+	}
+}
+
+// platform : a caller scoped to another tenant can't see or change tenants
+func platform(ctx context.Context) error {
+	if !pkg.PlatformScope(ctx) {
+		return ErrTenantForbidden
+	}
+	return nil
+}
+
+func (s *tenantService) CreateTenant(ctx context.Context, actor string, req *IReqTenant) (*TenantEntity, error) {
+	if err := platform(ctx); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	model := &TenantModel{
+		Code:      strings.ToLower(strings.TrimSpace(req.Code)),
+		Name:      req.Name,
+		Status:    TENANT_ACTIVE,
+		CreatedAt: now,
+		CreatedBy: actor,
+		UpdatedAt: now,
+		UpdatedBy: actor,
+	}
+	if req.Status != "" {
+		model.Status = req.Status
+	}
+
+	created, err := s.TenantRepository.CreateTenant(ctx, model)
+	if err != nil {
+		return nil, err
+	}
+	return TenantModelToEntity(created), nil
+}
+
+func (s *tenantService) UpdateTenant(ctx context.Context, id string, actor string, req *IReqTenant) (*TenantEntity, error) {
+	if err := platform(ctx); err != nil {
+		return nil, err
+	}
+	model, err := s.TenantRepository.GetTenantByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	model.Code = strings.ToLower(strings.TrimSpace(req.Code))
+	model.Name = req.Name
+	if req.Status != "" {
+		model.Status = req.Status
+	}
+	model.UpdatedAt = time.Now()
+	model.UpdatedBy = actor
+
+	updated, err := s.TenantRepository.UpdateTenant(ctx, model)
+	if err != nil {
+		return nil, err
+	}
+	return TenantModelToEntity(updated), nil
+}
+
+func (s *tenantService) GetTenantByID(ctx context.Context, id string) (*TenantEntity, error) {
+	if err := platform(ctx); err != nil {
+		return nil, err
+	}
+	model, err := s.TenantRepository.GetTenantByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return TenantModelToEntity(model), nil
+}
+
+func (s *tenantService) GetTenants(ctx context.Context) ([]TenantEntity, error) {
+	if err := platform(ctx); err != nil {
+		return nil, err
+	}
+	models, err := s.TenantRepository.GetTenants(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]TenantEntity, len(models))
+	for i := range models {
+		res[i] = *TenantModelToEntity(&models[i])
+	}
+	return res, nil
+}
+
+func (s *tenantService) CreateTenantOwner(ctx context.Context, id string, req *users.IReqCreateUserDTO) (*users.UserAccessDTO, error) {
+	if err := platform(ctx); err != nil {
+		return nil, err
+	}
+	model, err := s.TenantRepository.GetTenantByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// from here on everything is written inside the tenant
+	tenantCtx := pkg.WithTenant(ctx, model.ID.Hex())
+
+	// global catalog entries are visible to every tenant
+	permissions, err := s.AccessService.GetPermissions(tenantCtx)
+	if err != nil {
+		return nil, err
+	}
+	adminKey := users.PermissionKey(users.PERMISSION_WILDCARD, users.PERMISSION_WILDCARD)
+	adminID := ""
+	for _, p := range permissions {
+		if p.Key == adminKey {
+			adminID = p.ID
+			break
+		}
+	}
+	if adminID == "" {
+		return nil, ErrAdminPermission
+	}
+
+	owner, err := s.UserService.CreateUser(tenantCtx, *req)
+	if err != nil {
+		return nil, err
+	}
+	return s.AccessService.PutUserAccess(tenantCtx, owner.Username, &users.IReqUserAccessDTO{
+		RoleIDs:       []string{},
+		PermissionIDs: []string{adminID},
+	})
+}
+
+func (s *tenantService) CheckTenantActive(ctx context.Context, tenantID string) error {
+	if tenantID == "" || tenantID == pkg.DEFAULT_TENANT {
+		return nil
+	}
+	model, err := s.TenantRepository.GetTenantByID(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+	if model.Status != TENANT_ACTIVE {
+		return ErrTenantSuspended
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"go.uber.org/zap"
+)
+
+type AccountTokenPurposeEnum string
+
+const (
+	TOKEN_RESET_PASSWORD AccountTokenPurposeEnum = "RESET_PASSWORD"
+	TOKEN_VERIFY_EMAIL   AccountTokenPurposeEnum = "VERIFY_EMAIL"
+)
+
+// unknown, spent and expired tokens all look the same to the caller
+var ErrAccountTokenInvalid = errors.New("invalid or expired token")
+
+// AccountTokenModel : only the sha256 of the mailed token is stored, a database read gives nothing usable
+type AccountTokenModel struct {
+	ID        bson.ObjectID           `bson:"_id"`
+	Purpose   AccountTokenPurposeEnum `bson:"purpose"`
+	TokenHash string                  `bson:"token_hash"`
+	UserID    bson.ObjectID           `bson:"user_id"`
+	// address the token was mailed to : verification fails once the user's email changed
+	Email     string     `bson:"email"`
+	CreatedAt time.Time  `bson:"created_at"`
+	ExpiresAt time.Time  `bson:"expires_at"`
+	UsedAt    *time.Time `bson:"used_at,omitempty"`
+}
+
+type AccountTokenRepository interface {
+	InitRepository() error
+	CreateToken(ctx context.Context, token *AccountTokenModel) (*AccountTokenModel, error)
+	// ConsumeToken : marks a live token used and returns it, a token is consumed once
+	ConsumeToken(ctx context.Context, purpose AccountTokenPurposeEnum, tokenHash string) (*AccountTokenModel, error)
+	// DiscardTokens : spends every live token of the user for purpose
+	DiscardTokens(ctx context.Context, userID bson.ObjectID, purpose AccountTokenPurposeEnum) error
+}
+
+type accountTokenRepository struct {
+	Logger *zap.Logger
+	DB     *mongo.Collection
+}
+
+func NewAccountTokenRepository(db *mongo.Collection, log *zap.Logger) AccountTokenRepository {
+	return &accountTokenRepository{Logger: log, DB: db}
+}
+
+func (r *accountTokenRepository) InitRepository() error {
+	indexs := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "token_hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "purpose", Value: 1}}},
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	}
+	if _, err := r.DB.Indexes().CreateMany(context.TODO(), indexs); err != nil {
+		return errors.New("AccountTokenRepository.InitRepository: failed create indexs")
+	}
+	r.Logger.Info("AccountTokenRepository.InitRepository: index created")
+	return nil
+}
+
+func (r *accountTokenRepository) CreateToken(ctx context.Context, token *AccountTokenModel) (*AccountTokenModel, error) {
+	token.ID = bson.NewObjectID()
+	if _, err := r.DB.InsertOne(ctx, token); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+func (r *accountTokenRepository) ConsumeToken(ctx context.Context, purpose AccountTokenPurposeEnum, tokenHash string) (*AccountTokenModel, error) {
+	now := time.Now()
+	filter := bson.M{
+		"token_hash": tokenHash,
+		"purpose":    purpose,
+		"used_at":    nil,
+		"expires_at": bson.M{"$gt": now},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var model AccountTokenModel
+	if err := r.DB.FindOneAndUpdate(ctx, filter, bson.M{"$set": bson.M{"used_at": now}}, opts).Decode(&model); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrAccountTokenInvalid
+		}
+		return nil, err
+	}
+	return &model, nil
+}
+
+func (r *accountTokenRepository) DiscardTokens(ctx context.Context, userID bson.ObjectID, purpose AccountTokenPurposeEnum) error {
+	_, err := r.DB.UpdateMany(ctx,
+		bson.M{"user_id": userID, "purpose": purpose, "used_at": nil},
+		bson.M{"$set": bson.M{"used_at": time.Now()}},
+	)
+	return err
+}
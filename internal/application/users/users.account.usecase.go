@@ -0,0 +1,211 @@
+package users
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+
+	"ecommerce/internal/adapter/mail"
+	"ecommerce/internal/env"
+	"ecommerce/internal/pkg"
+)
+
+// Password reset and email verification.
+//
+// Both mail a random token (a link when ACCOUNT_RESET_URL / ACCOUNT_VERIFY_URL is set) and keep
+// its sha256 ; a token works once, until it expires, and a new one spends the previous ones.
+// Forgot-password answers the same whether the email exists or not.
+
+var ErrEmailVerified = errors.New("email is already verified")
+
+type IAccountService interface {
+	// SendEmailVerification : mails a verification link to the user's current address
+	SendEmailVerification(ctx context.Context, username string) error
+	VerifyEmail(ctx context.Context, token string) (*UserDTO, error)
+	// ForgotPassword : never tells whether the address is known
+	ForgotPassword(ctx context.Context, email string) error
+	// ResetPassword : returns the account whose password changed
+	ResetPassword(ctx context.Context, token string, password string) (*UserEntity, error)
+}
+
+type accountService struct {
+	Config *env.Config
+	Logger *zap.Logger
+
+	UserRepository  UserRepository
+	TokenRepository AccountTokenRepository
+	Mail            mail.IMailSender
+}
+
+func NewAccountService(cfg *env.Config, logger *zap.Logger,
+	userRepo UserRepository,
+	tokenRepo AccountTokenRepository,
+	mailer mail.IMailSender,
+) IAccountService {
+	return &accountService{
+		Config:          cfg,
+		Logger:          logger,
+		UserRepository:  userRepo,
+		TokenRepository: tokenRepo,
+		Mail:            mailer,
+	}
+}
+
+func hashAccountToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func accountLink(base string, token string) string {
+	if base == "" {
+		return token
+	}
+	sep := "?"
+	if strings.Contains(base, "?") {
+		sep = "&"
+	}
+	return base + sep + "token=" + url.QueryEscape(token)
+}
+
+// issueToken : spends the user's previous tokens for purpose and stores a new one, returns the plain token
+func (s *accountService) issueToken(ctx context.Context, user *UserEntity, purpose AccountTokenPurposeEnum, ttl time.Duration) (string, error) {
+	userID, err := bson.ObjectIDFromHex(user.ID)
+	if err != nil {
+		return "", ErrUserNotFound
+	}
+	if err := s.TokenRepository.DiscardTokens(ctx, userID, purpose); err != nil {
+		return "", err
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	now := time.Now()
+	_, err = s.TokenRepository.CreateToken(ctx, &AccountTokenModel{
+		Purpose:   purpose,
+		TokenHash: hashAccountToken(token),
+		UserID:    userID,
+		Email:     user.Email,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (s *accountService) SendEmailVerification(ctx context.Context, username string) error {
+	user, err := s.UserRepository.GetUserDetailByUsername(ctx, username)
+	if err != nil {
+		return ErrUserNotFound
+	}
+	if user.EmailVerifiedAt != nil {
+		return ErrEmailVerified
+	}
+
+	ttl := time.Minute * time.Duration(s.Config.Account.AccountVerifyTokenIN)
+	token, err := s.issueToken(ctx, user, TOKEN_VERIFY_EMAIL, ttl)
+	if err != nil {
+		return err
+	}
+	return s.Mail.Send(ctx, &mail.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Text: fmt.Sprintf("Hello %s,\n\nConfirm this address for your account :\n\n%s\n\nThe link expires in %s.\n",
+			user.Username, accountLink(s.Config.Account.AccountVerifyURL, token), ttl),
+	})
+}
+
+func (s *accountService) VerifyEmail(ctx context.Context, token string) (*UserDTO, error) {
+	model, err := s.TokenRepository.ConsumeToken(ctx, TOKEN_VERIFY_EMAIL, hashAccountToken(token))
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.UserRepository.SetEmailVerified(ctx, model.UserID, model.Email)
+	if errors.Is(err, ErrUserNotFound) {
+		// account gone or address changed since the mail went out
+		return nil, ErrAccountTokenInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := pkg.MapStruct[UserEntity, UserDTO](*user)
+	if err != nil {
+		return nil, errors.New("Error usecase.VerifyEmail: parse to DTO")
+	}
+	return &res, nil
+}
+
+func (s *accountService) ForgotPassword(ctx context.Context, email string) error {
+	user, err := s.UserRepository.GetUserByEmail(ctx, strings.TrimSpace(email))
+	if errors.Is(err, ErrUserNotFound) {
+		s.Logger.Info("usecase.ForgotPassword: unknown email")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if user.IsDeleted || user.Status != StatusActive {
+		s.Logger.Info("usecase.ForgotPassword: account not active", zap.String("username", user.Username))
+		return nil
+	}
+
+	ttl := time.Minute * time.Duration(s.Config.Account.AccountResetTokenIN)
+	token, err := s.issueToken(ctx, user, TOKEN_RESET_PASSWORD, ttl)
+	if err != nil {
+		return err
+	}
+	err = s.Mail.Send(ctx, &mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Text: fmt.Sprintf("Hello %s,\n\nA password reset was requested for your account :\n\n%s\n\n"+
+			"The link expires in %s. If you did not ask for it, ignore this mail, your password stays the same.\n",
+			user.Username, accountLink(s.Config.Account.AccountResetURL, token), ttl),
+	})
+	if err != nil {
+		// same answer as an unknown address, the failure is only logged
+		s.Logger.Error("usecase.ForgotPassword.Send:", zap.String("username", user.Username), zap.Error(err))
+	}
+	return nil
+}
+
+func (s *accountService) ResetPassword(ctx context.Context, token string, password string) (*UserEntity, error) {
+	model, err := s.TokenRepository.ConsumeToken(ctx, TOKEN_RESET_PASSWORD, hashAccountToken(token))
+	if err != nil {
+		return nil, err
+	}
+
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.UserRepository.SetPasswordHash(ctx, model.UserID, string(passwordHash))
+	if errors.Is(err, ErrUserNotFound) {
+		return nil, ErrAccountTokenInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// links mailed before this one are dead too
+	if err := s.TokenRepository.DiscardTokens(ctx, model.UserID, TOKEN_RESET_PASSWORD); err != nil {
+		s.Logger.Warn("usecase.ResetPassword.DiscardTokens:", zap.Error(err))
+	}
+	return user, nil
+}
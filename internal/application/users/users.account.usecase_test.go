@@ -0,0 +1,268 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+
+	"ecommerce/internal/adapter/mail"
+	"ecommerce/internal/env"
+)
+
+// fakeAccountTokens : the account_token collection, expiry read from a settable clock
+type fakeAccountTokens struct {
+	AccountTokenRepository
+	mu     sync.Mutex
+	tokens []*AccountTokenModel
+	now    time.Time
+}
+
+func (r *fakeAccountTokens) CreateToken(ctx context.Context, token *AccountTokenModel) (*AccountTokenModel, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token.ID = bson.NewObjectID()
+	c := *token
+	r.tokens = append(r.tokens, &c)
+	return token, nil
+}
+
+func (r *fakeAccountTokens) ConsumeToken(ctx context.Context, purpose AccountTokenPurposeEnum, tokenHash string) (*AccountTokenModel, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, t := range r.tokens {
+		if t.TokenHash == tokenHash && t.Purpose == purpose && t.UsedAt == nil && t.ExpiresAt.After(r.now) {
+			used := r.now
+			t.UsedAt = &used
+			c := *t
+			return &c, nil
+		}
+	}
+	return nil, ErrAccountTokenInvalid
+}
+
+func (r *fakeAccountTokens) DiscardTokens(ctx context.Context, userID bson.ObjectID, purpose AccountTokenPurposeEnum) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, t := range r.tokens {
+		if t.UserID == userID && t.Purpose == purpose && t.UsedAt == nil {
+			used := r.now
+			t.UsedAt = &used
+		}
+	}
+	return nil
+}
+
+type fakeAccountUsers struct {
+	UserRepository
+	mu   sync.Mutex
+	user *UserEntity
+}
+
+func (r *fakeAccountUsers) get() *UserEntity {
+	c := *r.user
+	return &c
+}
+
+func (r *fakeAccountUsers) GetUserDetailByUsername(ctx context.Context, username string) (*UserEntity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.user.Username != username {
+		return nil, ErrUserNotFound
+	}
+	return r.get(), nil
+}
+
+func (r *fakeAccountUsers) GetUserByEmail(ctx context.Context, email string) (*UserEntity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.user.Email != email {
+		return nil, ErrUserNotFound
+	}
+	return r.get(), nil
+}
+
+func (r *fakeAccountUsers) SetPasswordHash(ctx context.Context, userID bson.ObjectID, passwordHash string) (*UserEntity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.user.ID != userID.Hex() {
+		return nil, ErrUserNotFound
+	}
+	r.user.PasswordHash = passwordHash
+	return r.get(), nil
+}
+
+// SetEmailVerified : like the mongo update, matches only while the address is unchanged
+func (r *fakeAccountUsers) SetEmailVerified(ctx context.Context, userID bson.ObjectID, email string) (*UserEntity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.user.ID != userID.Hex() || r.user.Email != email {
+		return nil, ErrUserNotFound
+	}
+	now := time.Now()
+	r.user.EmailVerifiedAt = &now
+	return r.get(), nil
+}
+
+type fakeMailer struct {
+	sent []mail.Message
+}
+
+func (m *fakeMailer) Send(ctx context.Context, msg *mail.Message) error {
+	m.sent = append(m.sent, *msg)
+	return nil
+}
+
+// token : the token of the last link mailed
+func (m *fakeMailer) token(t *testing.T) string {
+	t.Helper()
+	if len(m.sent) == 0 {
+		t.Fatal("no mail sent")
+	}
+	text := m.sent[len(m.sent)-1].Text
+	i := strings.Index(text, "token=")
+	if i < 0 {
+		t.Fatalf("no link in %q", text)
+	}
+	raw := strings.Fields(text[i+len("token="):])[0]
+	token, err := url.QueryUnescape(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+type testAccounts struct {
+	Service *accountService
+	Users   *fakeAccountUsers
+	Tokens  *fakeAccountTokens
+	Mail    *fakeMailer
+}
+
+func newTestAccounts() *testAccounts {
+	ta := &testAccounts{
+		Users: &fakeAccountUsers{user: &UserEntity{
+			ID: bson.NewObjectID().Hex(), Username: "alice", Email: "alice@example.com", Status: StatusActive,
+		}},
+		Tokens: &fakeAccountTokens{now: time.Now()},
+		Mail:   &fakeMailer{},
+	}
+	cfg := &env.Config{Account: &env.AccountConfig{
+		AccountResetTokenIN:  30,
+		AccountVerifyTokenIN: 60,
+		AccountResetURL:      "https://shop.example/reset",
+		AccountVerifyURL:     "https://shop.example/verify?lang=th",
+	}}
+	ta.Service = NewAccountService(cfg, zap.NewNop(), ta.Users, ta.Tokens, ta.Mail).(*accountService)
+	return ta
+}
+
+func (ta *testAccounts) forgot(t *testing.T) string {
+	t.Helper()
+	if err := ta.Service.ForgotPassword(context.Background(), ta.Users.user.Email); err != nil {
+		t.Fatalf("ForgotPassword: %v", err)
+	}
+	return ta.Mail.token(t)
+}
+
+func TestResetPasswordTokenSingleUse(t *testing.T) {
+	ta := newTestAccounts()
+	token := ta.forgot(t)
+
+	user, err := ta.Service.ResetPassword(context.Background(), token, "new-password")
+	if err != nil {
+		t.Fatalf("ResetPassword: %v", err)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte("new-password")) != nil {
+		t.Error("password not changed")
+	}
+	if _, err := ta.Service.ResetPassword(context.Background(), token, "other-password"); !errors.Is(err, ErrAccountTokenInvalid) {
+		t.Errorf("second use: err = %v, want ErrAccountTokenInvalid", err)
+	}
+}
+
+func TestResetPasswordTokenExpired(t *testing.T) {
+	ta := newTestAccounts()
+	token := ta.forgot(t)
+
+	ta.Tokens.now = ta.Tokens.now.Add(31 * time.Minute)
+	if _, err := ta.Service.ResetPassword(context.Background(), token, "new-password"); !errors.Is(err, ErrAccountTokenInvalid) {
+		t.Fatalf("err = %v, want ErrAccountTokenInvalid", err)
+	}
+	if ta.Users.user.PasswordHash != "" {
+		t.Error("expired token changed the password")
+	}
+}
+
+func TestResetPasswordReissueDiscardsOldToken(t *testing.T) {
+	ta := newTestAccounts()
+	first := ta.forgot(t)
+	second := ta.forgot(t)
+
+	if _, err := ta.Service.ResetPassword(context.Background(), first, "new-password"); !errors.Is(err, ErrAccountTokenInvalid) {
+		t.Errorf("first token: err = %v, want ErrAccountTokenInvalid", err)
+	}
+	if _, err := ta.Service.ResetPassword(context.Background(), second, "new-password"); err != nil {
+		t.Errorf("second token: %v", err)
+	}
+}
+
+func TestForgotPasswordUnknownEmail(t *testing.T) {
+	ta := newTestAccounts()
+	known := ta.Service.ForgotPassword(context.Background(), ta.Users.user.Email)
+	unknown := ta.Service.ForgotPassword(context.Background(), "nobody@example.com")
+	if known != nil || unknown != nil {
+		t.Fatalf("known %v, unknown %v : want the same nil answer", known, unknown)
+	}
+	if len(ta.Mail.sent) != 1 || ta.Mail.sent[0].To != ta.Users.user.Email {
+		t.Errorf("mails %+v, want one to the known address", ta.Mail.sent)
+	}
+}
+
+func TestVerifyEmail(t *testing.T) {
+	ctx := context.Background()
+	ta := newTestAccounts()
+	if err := ta.Service.SendEmailVerification(ctx, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	token := ta.Mail.token(t)
+
+	res, err := ta.Service.VerifyEmail(ctx, token)
+	if err != nil {
+		t.Fatalf("VerifyEmail: %v", err)
+	}
+	if ta.Users.user.EmailVerifiedAt == nil || res.Username != "alice" {
+		t.Errorf("not verified : %+v", res)
+	}
+	if _, err := ta.Service.VerifyEmail(ctx, token); !errors.Is(err, ErrAccountTokenInvalid) {
+		t.Errorf("second use: err = %v, want ErrAccountTokenInvalid", err)
+	}
+	if err := ta.Service.SendEmailVerification(ctx, "alice"); !errors.Is(err, ErrEmailVerified) {
+		t.Errorf("resend after verification: err = %v, want ErrEmailVerified", err)
+	}
+}
+
+func TestVerifyEmailAddressChanged(t *testing.T) {
+	ctx := context.Background()
+	ta := newTestAccounts()
+	if err := ta.Service.SendEmailVerification(ctx, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	token := ta.Mail.token(t)
+
+	// the link went to the old address
+	ta.Users.user.Email = "alice@other.example"
+	if _, err := ta.Service.VerifyEmail(ctx, token); !errors.Is(err, ErrAccountTokenInvalid) {
+		t.Fatalf("err = %v, want ErrAccountTokenInvalid", err)
+	}
+	if ta.Users.user.EmailVerifiedAt != nil {
+		t.Error("changed address marked verified")
+	}
+}
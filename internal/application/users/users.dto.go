@@ -31,4 +31,62 @@ type UserDTO struct {
     CreatedAt time.Time `json:"created_at"`
     UpdatedAt time.Time `json:"updated_at"`
     LastLogin *time.Time `json:"last_login_at,omitempty"`
+    EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+}
+
+
+// RBAC : permissions are "resource:action" (e.g. shopee.order:read), "*" matches any resource / action
+// and is left to the platform tenant : tenants create exact pairs only
+type IReqPermissionDTO struct {
+    Name        string `json:"name" validate:"max=100"` // default : resource:action
+    Description string `json:"description" validate:"max=500"`
+    Resource    string `json:"resource" validate:"required,max=64,excludes=:"`
+    Action      string `json:"action" validate:"required,max=32,excludes=:"`
+}
+
+type IReqRoleDTO struct {
+    Name          string   `json:"name" validate:"required,max=100"`
+    Description   string   `json:"description" validate:"max=500"`
+    PermissionIDs []string `json:"permission_ids" validate:"dive,required"`
+    IsDefault     bool     `json:"is_default"` // granted to every new user
+    RequireMFA    bool     `json:"require_mfa"` // members enroll 2fa on their next login
+}
+
+// replaces the user's grants ; direct permissions come on top of the roles
+type IReqUserAccessDTO struct {
+    RoleIDs       []string `json:"role_ids" validate:"dive,required"`
+    PermissionIDs []string `json:"permission_ids" validate:"dive,required"`
+}
+
+type PermissionDTO struct {
+    ID          string    `json:"id"`
+    Name        string    `json:"name"`
+    Description string    `json:"description,omitempty"`
+    Resource    string    `json:"resource"`
+    Action      string    `json:"action"`
+    Key         string    `json:"key"`
+    CreatedAt   time.Time `json:"created_at"`
+    UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type RoleDTO struct {
+    ID          string          `json:"id"`
+    Name        string          `json:"name"`
+    Description string          `json:"description,omitempty"`
+    IsDefault   bool            `json:"is_default"`
+    RequireMFA  bool            `json:"require_mfa"`
+    Permissions []PermissionDTO `json:"permissions"`
+    CreatedAt   time.Time       `json:"created_at"`
+    UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+type UserAccessDTO struct {
+    Username    string          `json:"username"`
+    Roles       []RoleDTO       `json:"roles"`
+    Permissions []PermissionDTO `json:"permissions"` // direct grants
+    // resolved set carried by the access token : roles + direct grants (+ "*:*" for RBAC_ADMIN_USERS)
+    RoleNames   []string        `json:"role_names"`
+    Effective   []string        `json:"effective"`
+    // one of the roles requires 2fa
+    MFARequired bool            `json:"mfa_required"`
 }
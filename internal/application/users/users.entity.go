@@ -33,6 +33,7 @@ type UserEntity struct {
     CreatedAt     time.Time
     UpdatedAt     time.Time
     LastLoginAt   *time.Time
+    EmailVerifiedAt *time.Time
 }
 
 type RoleEntity struct {
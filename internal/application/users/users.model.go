@@ -20,6 +20,7 @@ type UserModel struct {
     CreatedAt     time.Time            `bson:"created_at"`
     UpdatedAt     time.Time            `bson:"updated_at"`
     LastLoginAt   *time.Time           `bson:"last_login_at,omitempty"`
+    EmailVerifiedAt *time.Time         `bson:"email_verified_at,omitempty"` // set by the mailed verification link
     TenantID      *bson.ObjectID  `bson:"tenant_id,omitempty"`  // multi-tenant
 }
 
@@ -29,6 +30,7 @@ type RoleModel struct {
     Description   string               `bson:"description,omitempty"`
     PermissionIDs []bson.ObjectID `bson:"permission_ids,omitempty"`
     IsDefault     bool                 `bson:"is_default"`           // default role for new users
+    RequireMFA    bool                 `bson:"require_mfa"`          // members can't log in without 2fa
     TenantID      *bson.ObjectID  `bson:"tenant_id,omitempty"`  // multi-tenant
     CreatedAt     time.Time            `bson:"created_at"`
     UpdatedAt     time.Time            `bson:"updated_at"`
@@ -68,6 +70,7 @@ func UserModelToEntity(e UserModel) UserEntity {
     CreatedAt: e.CreatedAt,
     UpdatedAt: e.UpdatedAt,
     LastLoginAt: e.LastLoginAt,
+    EmailVerifiedAt: e.EmailVerifiedAt,
     TenantID: &tenant_id,
   }
 }
@@ -0,0 +1,200 @@
+package users
+
+import (
+	"errors"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	"ecommerce/internal/delivery/http/response"
+)
+
+type IAccessHandler interface {
+	GetPermissions(c *fiber.Ctx) error
+	PostPermission(c *fiber.Ctx) error
+	PutPermission(c *fiber.Ctx) error
+	DeletePermission(c *fiber.Ctx) error
+
+	GetRoles(c *fiber.Ctx) error
+	GetRoleByID(c *fiber.Ctx) error
+	PostRole(c *fiber.Ctx) error
+	PutRole(c *fiber.Ctx) error
+	DeleteRole(c *fiber.Ctx) error
+
+	GetUserAccess(c *fiber.Ctx) error
+	PutUserAccess(c *fiber.Ctx) error
+	GetUserMeAccess(c *fiber.Ctx) error
+}
+
+type accessHandler struct {
+	Logger   *zap.Logger
+	Validate *validator.Validate
+	Service  IAccessService
+}
+
+func NewAccessHandler(log *zap.Logger, valid *validator.Validate, srv IAccessService) IAccessHandler {
+	return &accessHandler{
+		Logger:   log,
+		Validate: valid,
+		Service:  srv,
+	}
+}
+
+func accessErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrRoleNotFound), errors.Is(err, ErrPermissionNotFound), errors.Is(err, ErrUserNotFound):
+		return fiber.StatusNotFound
+	case errors.Is(err, ErrDuplicateRole), errors.Is(err, ErrDuplicatePermission):
+		return fiber.StatusConflict
+	case errors.Is(err, ErrUnknownRole), errors.Is(err, ErrUnknownPermission):
+		return fiber.StatusUnprocessableEntity
+	case errors.Is(err, ErrWildcardPermission):
+		return fiber.StatusForbidden
+	}
+	return fiber.StatusInternalServerError
+}
+
+// -- permissions
+
+func (d *accessHandler) GetPermissions(c *fiber.Ctx) error {
+	res, err := d.Service.GetPermissions(c.Context())
+	if err != nil {
+		return response.ErrorResponse(c, accessErrorStatus(err), "handler.GetPermissions", err)
+	}
+	return response.SuccessResponse(c, "handler.GetPermissions", res)
+}
+
+func (d *accessHandler) PostPermission(c *fiber.Ctx) error {
+	var reqBody IReqPermissionDTO
+	if err := c.BodyParser(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostPermission", "invalid body")
+	}
+	if err := d.Validate.Struct(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostPermission", err)
+	}
+
+	res, err := d.Service.CreatePermission(c.Context(), &reqBody)
+	if err != nil {
+		return response.ErrorResponse(c, accessErrorStatus(err), "handler.PostPermission", err)
+	}
+	return response.SuccessResponse(c, "handler.PostPermission", res)
+}
+
+func (d *accessHandler) PutPermission(c *fiber.Ctx) error {
+	var reqBody IReqPermissionDTO
+	if err := c.BodyParser(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PutPermission", "invalid body")
+	}
+	if err := d.Validate.Struct(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PutPermission", err)
+	}
+
+	res, err := d.Service.UpdatePermission(c.Context(), c.Params("permissionID"), &reqBody)
+	if err != nil {
+		return response.ErrorResponse(c, accessErrorStatus(err), "handler.PutPermission", err)
+	}
+	return response.SuccessResponse(c, "handler.PutPermission", res)
+}
+
+func (d *accessHandler) DeletePermission(c *fiber.Ctx) error {
+	res, err := d.Service.DeletePermission(c.Context(), c.Params("permissionID"))
+	if err != nil {
+		return response.ErrorResponse(c, accessErrorStatus(err), "handler.DeletePermission", err)
+	}
+	return response.SuccessResponse(c, "handler.DeletePermission", res)
+}
+
+// -- roles
+
+func (d *accessHandler) GetRoles(c *fiber.Ctx) error {
+	res, err := d.Service.GetRoles(c.Context())
+	if err != nil {
+		return response.ErrorResponse(c, accessErrorStatus(err), "handler.GetRoles", err)
+	}
+	return response.SuccessResponse(c, "handler.GetRoles", res)
+}
+
+func (d *accessHandler) GetRoleByID(c *fiber.Ctx) error {
+	res, err := d.Service.GetRoleByID(c.Context(), c.Params("roleID"))
+	if err != nil {
+		return response.ErrorResponse(c, accessErrorStatus(err), "handler.GetRoleByID", err)
+	}
+	return response.SuccessResponse(c, "handler.GetRoleByID", res)
+}
+
+func (d *accessHandler) PostRole(c *fiber.Ctx) error {
+	var reqBody IReqRoleDTO
+	if err := c.BodyParser(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostRole", "invalid body")
+	}
+	if err := d.Validate.Struct(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PostRole", err)
+	}
+
+	res, err := d.Service.CreateRole(c.Context(), &reqBody)
+	if err != nil {
+		return response.ErrorResponse(c, accessErrorStatus(err), "handler.PostRole", err)
+	}
+	return response.SuccessResponse(c, "handler.PostRole", res)
+}
+
+func (d *accessHandler) PutRole(c *fiber.Ctx) error {
+	var reqBody IReqRoleDTO
+	if err := c.BodyParser(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PutRole", "invalid body")
+	}
+	if err := d.Validate.Struct(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PutRole", err)
+	}
+
+	res, err := d.Service.UpdateRole(c.Context(), c.Params("roleID"), &reqBody)
+	if err != nil {
+		return response.ErrorResponse(c, accessErrorStatus(err), "handler.PutRole", err)
+	}
+	return response.SuccessResponse(c, "handler.PutRole", res)
+}
+
+func (d *accessHandler) DeleteRole(c *fiber.Ctx) error {
+	res, err := d.Service.DeleteRole(c.Context(), c.Params("roleID"))
+	if err != nil {
+		return response.ErrorResponse(c, accessErrorStatus(err), "handler.DeleteRole", err)
+	}
+	return response.SuccessResponse(c, "handler.DeleteRole", res)
+}
+
+// -- user grants
+
+func (d *accessHandler) GetUserAccess(c *fiber.Ctx) error {
+	res, err := d.Service.ResolveUserAccess(c.Context(), c.Params("userId"))
+	if err != nil {
+		return response.ErrorResponse(c, accessErrorStatus(err), "handler.GetUserAccess", err)
+	}
+	return response.SuccessResponse(c, "handler.GetUserAccess", res)
+}
+
+func (d *accessHandler) PutUserAccess(c *fiber.Ctx) error {
+	var reqBody IReqUserAccessDTO
+	if err := c.BodyParser(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PutUserAccess", "invalid body")
+	}
+	if err := d.Validate.Struct(&reqBody); err != nil {
+		return response.ErrorResponse(c, fiber.StatusBadRequest, "handler.PutUserAccess", err)
+	}
+
+	res, err := d.Service.PutUserAccess(c.Context(), c.Params("userId"), &reqBody)
+	if err != nil {
+		return response.ErrorResponse(c, accessErrorStatus(err), "handler.PutUserAccess", err)
+	}
+	return response.SuccessResponse(c, "handler.PutUserAccess", res)
+}
+
+// GetUserMeAccess : current grants from the database, the token only catches up on refresh
+func (d *accessHandler) GetUserMeAccess(c *fiber.Ctx) error {
+	username, _ := c.Locals("username").(string)
+	res, err := d.Service.ResolveUserAccess(c.Context(), username)
+	if err != nil {
+		return response.ErrorResponse(c, accessErrorStatus(err), "handler.GetUserMeAccess", err)
+	}
+	return response.SuccessResponse(c, "handler.GetUserMeAccess", res)
+}
@@ -0,0 +1,347 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"go.uber.org/zap"
+)
+
+const (
+	ACTION_READ  = "read"
+	ACTION_WRITE = "write"
+	// "*" as resource and / or action : every resource / action ("shopee.*" covers every shopee.x resource)
+	PERMISSION_WILDCARD = "*"
+)
+
+var (
+	ErrRoleNotFound        = errors.New("role not found")
+	ErrDuplicateRole       = errors.New("role name already exists")
+	ErrPermissionNotFound  = errors.New("permission not found")
+	ErrDuplicatePermission = errors.New("permission name or resource / action already exists")
+	ErrUnknownPermission   = errors.New("unknown permission id")
+	ErrUnknownRole         = errors.New("unknown role id")
+	ErrUserNotFound        = errors.New("username not found")
+	ErrInvalidTenant       = errors.New("invalid tenant id")
+	// a wildcard reaches platform routes too : only the seeded catalog holds them
+	ErrWildcardPermission = errors.New("wildcard resources and actions are reserved to the platform tenant")
+)
+
+// PermissionKey : "resource:action", the form carried by the access token
+func PermissionKey(resource, action string) string {
+	return fmt.Sprintf("%s:%s", resource, action)
+}
+
+// PermissionCatalog : one resource per route group, seeded with read (GET) and write (everything else)
+var PermissionCatalog = []struct {
+	Resource    string
+	Description string
+}{
+	{"user", "user accounts"},
+	{"rbac", "roles, permissions and user grants"},
+	{"tenant", "tenants, platform tenant only"},
+	{"marketplace", "marketplace apps, shops and orders"},
+	{"product", "master catalog and sku mappings"},
+	{"inventory", "warehouses, stock and ledger postings"},
+	{"stock_sync", "marketplace stock push rules and logs"},
+	{"purchase", "suppliers, purchase orders and goods receipts"},
+	{"fulfillment", "waves, pick, pack and ship"},
+	{"invoice", "tax profile and tax documents"},
+	{"shopee.shop", "shopee shop authorization and details"},
+	{"shopee.partner", "shopee partner apps"},
+	{"shopee.order", "shopee orders and order sync"},
+	{"shopee.logistics", "shopee shipping and tracking"},
+	{"shopee.label", "shopee shipping labels"},
+	{"shopee.payment", "shopee escrow, payouts and reconciliation"},
+	{"shopee.return", "shopee returns and refunds"},
+	{"shopee.item", "shopee listings, price and stock"},
+	{"shopee.push", "shopee push events, platform tenant only"},
+	{"shopee.admin", "shopee client circuit breakers, platform tenant only"},
+}
+
+type RoleRepository interface {
+	InitRepository() error
+	CreateRole(ctx context.Context, role *RoleModel) (*RoleModel, error)
+	UpdateRole(ctx context.Context, role *RoleModel) (*RoleModel, error)
+	DeleteRole(ctx context.Context, id string) (*RoleModel, error)
+	GetRoleByID(ctx context.Context, id string) (*RoleModel, error)
+	GetRoles(ctx context.Context) ([]RoleModel, error)
+	GetRolesByIDs(ctx context.Context, ids []bson.ObjectID) ([]RoleModel, error)
+	GetDefaultRoles(ctx context.Context) ([]RoleModel, error)
+	// PullPermission : drop a deleted permission from every role
+	PullPermission(ctx context.Context, permissionID bson.ObjectID) error
+}
+
+type PermissionRepository interface {
+	InitRepository() error
+	CreatePermission(ctx context.Context, permission *PermissionModel) (*PermissionModel, error)
+	UpdatePermission(ctx context.Context, permission *PermissionModel) (*PermissionModel, error)
+	DeletePermission(ctx context.Context, id string) (*PermissionModel, error)
+	GetPermissionByID(ctx context.Context, id string) (*PermissionModel, error)
+	GetPermissions(ctx context.Context) ([]PermissionModel, error)
+	GetPermissionsByIDs(ctx context.Context, ids []bson.ObjectID) ([]PermissionModel, error)
+}
+
+type roleRepository struct {
+	Logger *zap.Logger
+	DB     *mongo.Collection
+}
+
+func NewRoleRepository(db *mongo.Collection, log *zap.Logger) RoleRepository {
+	return &roleRepository{Logger: log, DB: db}
+}
+
+func (r *roleRepository) InitRepository() error {
+	indexs := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "name", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "permission_ids", Value: 1}}},
+		{Keys: bson.D{{Key: "is_default", Value: 1}}},
+	}
+	if _, err := r.DB.Indexes().CreateMany(context.TODO(), indexs); err != nil {
+		return errors.New("RoleRepository.InitRepository: failed create indexs")
+	}
+	r.Logger.Info("RoleRepository.InitRepository: index created")
+	return nil
+}
+
+func (r *roleRepository) CreateRole(ctx context.Context, role *RoleModel) (*RoleModel, error) {
+	tenantID, err := tenantObjectID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	role.ID = bson.NewObjectID()
+	role.TenantID = tenantID
+	if _, err := r.DB.InsertOne(ctx, role); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, ErrDuplicateRole
+		}
+		return nil, err
+	}
+	return role, nil
+}
+
+func (r *roleRepository) UpdateRole(ctx context.Context, role *RoleModel) (*RoleModel, error) {
+	res, err := r.DB.ReplaceOne(ctx, tenantFilter(ctx, bson.M{"_id": role.ID}), role)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, ErrDuplicateRole
+		}
+		return nil, err
+	}
+	if res.MatchedCount == 0 {
+		return nil, ErrRoleNotFound
+	}
+	return role, nil
+}
+
+func (r *roleRepository) DeleteRole(ctx context.Context, id string) (*RoleModel, error) {
+	objectID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrRoleNotFound
+	}
+	var model RoleModel
+	if err := r.DB.FindOneAndDelete(ctx, tenantFilter(ctx, bson.M{"_id": objectID})).Decode(&model); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrRoleNotFound
+		}
+		return nil, err
+	}
+	return &model, nil
+}
+
+func (r *roleRepository) GetRoleByID(ctx context.Context, id string) (*RoleModel, error) {
+	objectID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrRoleNotFound
+	}
+	var model RoleModel
+	if err := r.DB.FindOne(ctx, tenantFilter(ctx, bson.M{"_id": objectID})).Decode(&model); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrRoleNotFound
+		}
+		return nil, err
+	}
+	return &model, nil
+}
+
+// find : roles are strictly per tenant
+func (r *roleRepository) find(ctx context.Context, query bson.M) ([]RoleModel, error) {
+	cursor, err := r.DB.Find(ctx, tenantFilter(ctx, query), options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	roles := []RoleModel{}
+	if err := cursor.All(ctx, &roles); err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+func (r *roleRepository) GetRoles(ctx context.Context) ([]RoleModel, error) {
+	return r.find(ctx, bson.M{})
+}
+
+func (r *roleRepository) GetRolesByIDs(ctx context.Context, ids []bson.ObjectID) ([]RoleModel, error) {
+	if len(ids) == 0 {
+		return []RoleModel{}, nil
+	}
+	return r.find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+}
+
+func (r *roleRepository) GetDefaultRoles(ctx context.Context) ([]RoleModel, error) {
+	return r.find(ctx, bson.M{"is_default": true})
+}
+
+func (r *roleRepository) PullPermission(ctx context.Context, permissionID bson.ObjectID) error {
+	_, err := r.DB.UpdateMany(ctx,
+		bson.M{"permission_ids": permissionID},
+		bson.M{"$pull": bson.M{"permission_ids": permissionID}, "$set": bson.M{"updated_at": time.Now()}},
+	)
+	return err
+}
+
+type permissionRepository struct {
+	Logger *zap.Logger
+	DB     *mongo.Collection
+}
+
+func NewPermissionRepository(db *mongo.Collection, log *zap.Logger) PermissionRepository {
+	return &permissionRepository{Logger: log, DB: db}
+}
+
+// InitRepository : indexes + the catalog (read / write per resource, "*:*" as admin) ;
+// seeded entries are only inserted, descriptions edited through the API stay
+func (r *permissionRepository) InitRepository() error {
+	indexs := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "name", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "resource", Value: 1}, {Key: "action", Value: 1}}, Options: options.Index().SetUnique(true)},
+	}
+	if _, err := r.DB.Indexes().CreateMany(context.TODO(), indexs); err != nil {
+		return errors.New("PermissionRepository.InitRepository: failed create indexs")
+	}
+	r.Logger.Info("PermissionRepository.InitRepository: index created")
+
+	seed := func(resource, action, name, description string) {
+		now := time.Now()
+		_, err := r.DB.UpdateOne(context.TODO(),
+			bson.M{"tenant_id": nil, "resource": resource, "action": action},
+			bson.M{"$setOnInsert": bson.M{
+				"name": name, "description": description, "resource": resource, "action": action,
+				"created_at": now, "updated_at": now,
+			}},
+			options.UpdateOne().SetUpsert(true),
+		)
+		if err != nil {
+			r.Logger.Warn("PermissionRepository.InitRepository: seed", zap.String("permission", name), zap.Error(err))
+		}
+	}
+	seed(PERMISSION_WILDCARD, PERMISSION_WILDCARD, "admin", "every action on every resource")
+	for _, entry := range PermissionCatalog {
+		seed(entry.Resource, ACTION_READ, PermissionKey(entry.Resource, ACTION_READ), "read "+entry.Description)
+		seed(entry.Resource, ACTION_WRITE, PermissionKey(entry.Resource, ACTION_WRITE), "manage "+entry.Description)
+	}
+	return nil
+}
+
+func (r *permissionRepository) CreatePermission(ctx context.Context, permission *PermissionModel) (*PermissionModel, error) {
+	tenantID, err := tenantObjectID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	permission.ID = bson.NewObjectID()
+	permission.TenantID = tenantID
+	if _, err := r.DB.InsertOne(ctx, permission); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, ErrDuplicatePermission
+		}
+		return nil, err
+	}
+	return permission, nil
+}
+
+func (r *permissionRepository) UpdatePermission(ctx context.Context, permission *PermissionModel) (*PermissionModel, error) {
+	// the seeded catalog (no tenant) only changes from the platform tenant
+	res, err := r.DB.ReplaceOne(ctx, tenantFilter(ctx, bson.M{"_id": permission.ID}), permission)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, ErrDuplicatePermission
+		}
+		return nil, err
+	}
+	if res.MatchedCount == 0 {
+		return nil, ErrPermissionNotFound
+	}
+	return permission, nil
+}
+
+func (r *permissionRepository) DeletePermission(ctx context.Context, id string) (*PermissionModel, error) {
+	objectID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrPermissionNotFound
+	}
+	var model PermissionModel
+	if err := r.DB.FindOneAndDelete(ctx, tenantFilter(ctx, bson.M{"_id": objectID})).Decode(&model); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrPermissionNotFound
+		}
+		return nil, err
+	}
+	return &model, nil
+}
+
+func (r *permissionRepository) GetPermissionByID(ctx context.Context, id string) (*PermissionModel, error) {
+	objectID, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrPermissionNotFound
+	}
+	var model PermissionModel
+	if err := r.DB.FindOne(ctx, visibleFilter(ctx, bson.M{"_id": objectID})).Decode(&model); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrPermissionNotFound
+		}
+		return nil, err
+	}
+	return &model, nil
+}
+
+// visibleFilter : a tenant reads its own permissions and the global catalog
+func visibleFilter(ctx context.Context, query bson.M) bson.M {
+	tenantFilter(ctx, query)
+	if tenantID, ok := query["tenant_id"]; ok && tenantID != nil {
+		query["tenant_id"] = bson.M{"$in": bson.A{nil, tenantID}}
+	}
+	return query
+}
+
+func (r *permissionRepository) find(ctx context.Context, query bson.M) ([]PermissionModel, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "resource", Value: 1}, {Key: "action", Value: 1}})
+	cursor, err := r.DB.Find(ctx, visibleFilter(ctx, query), opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	permissions := []PermissionModel{}
+	if err := cursor.All(ctx, &permissions); err != nil {
+		return nil, err
+	}
+	return permissions, nil
+}
+
+func (r *permissionRepository) GetPermissions(ctx context.Context) ([]PermissionModel, error) {
+	return r.find(ctx, bson.M{})
+}
+
+func (r *permissionRepository) GetPermissionsByIDs(ctx context.Context, ids []bson.ObjectID) ([]PermissionModel, error) {
+	if len(ids) == 0 {
+		return []PermissionModel{}, nil
+	}
+	return r.find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+}
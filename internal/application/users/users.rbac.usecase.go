@@ -0,0 +1,419 @@
+package users
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.uber.org/zap"
+
+	"ecommerce/internal/env"
+	"ecommerce/internal/pkg"
+)
+
+type IAccessService interface {
+	GetPermissions(ctx context.Context) ([]PermissionDTO, error)
+	CreatePermission(ctx context.Context, req *IReqPermissionDTO) (*PermissionDTO, error)
+	UpdatePermission(ctx context.Context, permissionID string, req *IReqPermissionDTO) (*PermissionDTO, error)
+	DeletePermission(ctx context.Context, permissionID string) (*PermissionDTO, error)
+
+	GetRoles(ctx context.Context) ([]RoleDTO, error)
+	GetRoleByID(ctx context.Context, roleID string) (*RoleDTO, error)
+	CreateRole(ctx context.Context, req *IReqRoleDTO) (*RoleDTO, error)
+	UpdateRole(ctx context.Context, roleID string, req *IReqRoleDTO) (*RoleDTO, error)
+	DeleteRole(ctx context.Context, roleID string) (*RoleDTO, error)
+
+	// ResolveUserAccess : grants + effective "resource:action" set, what login / refresh put in the token
+	ResolveUserAccess(ctx context.Context, username string) (*UserAccessDTO, error)
+	PutUserAccess(ctx context.Context, username string, req *IReqUserAccessDTO) (*UserAccessDTO, error)
+	AssignDefaultRoles(ctx context.Context, username string) error
+}
+
+type accessService struct {
+	Config *env.Config
+	Logger *zap.Logger
+
+	UserRepository       UserRepository
+	RoleRepository       RoleRepository
+	PermissionRepository PermissionRepository
+}
+
+func NewAccessService(cfg *env.Config, log *zap.Logger,
+	userRepo UserRepository,
+	roleRepo RoleRepository,
+	permissionRepo PermissionRepository,
+) IAccessService {
+	return &accessService{
+		Config:               cfg,
+		Logger:               log,
+		UserRepository:       userRepo,
+		RoleRepository:       roleRepo,
+		PermissionRepository: permissionRepo,
+	}
+}
+
+func PermissionModelToDTO(m PermissionModel) PermissionDTO {
+	return PermissionDTO{
+		ID:          m.ID.Hex(),
+		Name:        m.Name,
+		Description: m.Description,
+		Resource:    m.Resource,
+		Action:      m.Action,
+		Key:         PermissionKey(m.Resource, m.Action),
+		CreatedAt:   m.CreatedAt,
+		UpdatedAt:   m.UpdatedAt,
+	}
+}
+
+// RoleModelToDTO : permissions looked up by id, ids no longer in the map are left out
+func RoleModelToDTO(m RoleModel, permissions map[bson.ObjectID]PermissionModel) RoleDTO {
+	res := RoleDTO{
+		ID:          m.ID.Hex(),
+		Name:        m.Name,
+		Description: m.Description,
+		IsDefault:   m.IsDefault,
+		RequireMFA:  m.RequireMFA,
+		Permissions: []PermissionDTO{},
+		CreatedAt:   m.CreatedAt,
+		UpdatedAt:   m.UpdatedAt,
+	}
+	for _, id := range m.PermissionIDs {
+		if p, ok := permissions[id]; ok {
+			res.Permissions = append(res.Permissions, PermissionModelToDTO(p))
+		}
+	}
+	return res
+}
+
+// objectIDs : hex ids -> ObjectIDs, duplicates dropped ; invalid = unknown
+func objectIDs(ids []string, unknown error) ([]bson.ObjectID, error) {
+	res := []bson.ObjectID{}
+	for _, id := range ids {
+		objectID, err := bson.ObjectIDFromHex(id)
+		if err != nil {
+			return nil, unknown
+		}
+		if !slices.Contains(res, objectID) {
+			res = append(res, objectID)
+		}
+	}
+	return res, nil
+}
+
+func (s *accessService) permissionsByID(ctx context.Context, ids []bson.ObjectID) (map[bson.ObjectID]PermissionModel, error) {
+	permissions, err := s.PermissionRepository.GetPermissionsByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[bson.ObjectID]PermissionModel, len(permissions))
+	for _, p := range permissions {
+		res[p.ID] = p
+	}
+	return res, nil
+}
+
+// checkPermissionIDs : every id has to exist, a role / user never holds a dangling grant
+func (s *accessService) checkPermissionIDs(ctx context.Context, ids []string) ([]bson.ObjectID, error) {
+	permissionIDs, err := objectIDs(ids, ErrUnknownPermission)
+	if err != nil {
+		return nil, err
+	}
+	found, err := s.PermissionRepository.GetPermissionsByIDs(ctx, permissionIDs)
+	if err != nil {
+		return nil, err
+	}
+	if len(found) != len(permissionIDs) {
+		return nil, ErrUnknownPermission
+	}
+	return permissionIDs, nil
+}
+
+// -- permissions
+
+// checkWildcard : tenants define exact "resource:action" pairs, no "*" nor "shopee.*"
+func checkWildcard(ctx context.Context, req *IReqPermissionDTO) error {
+	if pkg.PlatformScope(ctx) {
+		return nil
+	}
+	if strings.Contains(req.Resource, PERMISSION_WILDCARD) || strings.Contains(req.Action, PERMISSION_WILDCARD) {
+		return ErrWildcardPermission
+	}
+	return nil
+}
+
+func (s *accessService) GetPermissions(ctx context.Context) ([]PermissionDTO, error) {
+	permissions, err := s.PermissionRepository.GetPermissions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]PermissionDTO, len(permissions))
+	for i, p := range permissions {
+		res[i] = PermissionModelToDTO(p)
+	}
+	return res, nil
+}
+
+func (s *accessService) CreatePermission(ctx context.Context, req *IReqPermissionDTO) (*PermissionDTO, error) {
+	if err := checkWildcard(ctx, req); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	model := &PermissionModel{
+		Name:        req.Name,
+		Description: req.Description,
+		Resource:    req.Resource,
+		Action:      req.Action,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if model.Name == "" {
+		model.Name = PermissionKey(req.Resource, req.Action)
+	}
+
+	created, err := s.PermissionRepository.CreatePermission(ctx, model)
+	if err != nil {
+		return nil, err
+	}
+	res := PermissionModelToDTO(*created)
+	return &res, nil
+}
+
+// UpdatePermission : tokens already issued keep the old key until their next refresh
+func (s *accessService) UpdatePermission(ctx context.Context, permissionID string, req *IReqPermissionDTO) (*PermissionDTO, error) {
+	if err := checkWildcard(ctx, req); err != nil {
+		return nil, err
+	}
+	model, err := s.PermissionRepository.GetPermissionByID(ctx, permissionID)
+	if err != nil {
+		return nil, err
+	}
+	model.Name = req.Name
+	if model.Name == "" {
+		model.Name = PermissionKey(req.Resource, req.Action)
+	}
+	model.Description = req.Description
+	model.Resource = req.Resource
+	model.Action = req.Action
+	model.UpdatedAt = time.Now()
+
+	updated, err := s.PermissionRepository.UpdatePermission(ctx, model)
+	if err != nil {
+		return nil, err
+	}
+	res := PermissionModelToDTO(*updated)
+	return &res, nil
+}
+
+func (s *accessService) DeletePermission(ctx context.Context, permissionID string) (*PermissionDTO, error) {
+	deleted, err := s.PermissionRepository.DeletePermission(ctx, permissionID)
+	if err != nil {
+		return nil, err
+	}
+	// grants referencing it : best effort, resolving skips ids that no longer exist anyway
+	if err := s.RoleRepository.PullPermission(ctx, deleted.ID); err != nil {
+		s.Logger.Warn("usecase.DeletePermission : RoleRepository.PullPermission", zap.Error(err))
+	}
+	if err := s.UserRepository.PullPermission(ctx, deleted.ID); err != nil {
+		s.Logger.Warn("usecase.DeletePermission : UserRepository.PullPermission", zap.Error(err))
+	}
+	res := PermissionModelToDTO(*deleted)
+	return &res, nil
+}
+
+// -- roles
+
+func (s *accessService) rolesToDTO(ctx context.Context, roles []RoleModel) ([]RoleDTO, error) {
+	ids := []bson.ObjectID{}
+	for _, role := range roles {
+		ids = append(ids, role.PermissionIDs...)
+	}
+	permissions, err := s.permissionsByID(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]RoleDTO, len(roles))
+	for i, role := range roles {
+		res[i] = RoleModelToDTO(role, permissions)
+	}
+	return res, nil
+}
+
+func (s *accessService) GetRoles(ctx context.Context) ([]RoleDTO, error) {
+	roles, err := s.RoleRepository.GetRoles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.rolesToDTO(ctx, roles)
+}
+
+func (s *accessService) GetRoleByID(ctx context.Context, roleID string) (*RoleDTO, error) {
+	role, err := s.RoleRepository.GetRoleByID(ctx, roleID)
+	if err != nil {
+		return nil, err
+	}
+	res, err := s.rolesToDTO(ctx, []RoleModel{*role})
+	if err != nil {
+		return nil, err
+	}
+	return &res[0], nil
+}
+
+func (s *accessService) CreateRole(ctx context.Context, req *IReqRoleDTO) (*RoleDTO, error) {
+	permissionIDs, err := s.checkPermissionIDs(ctx, req.PermissionIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	created, err := s.RoleRepository.CreateRole(ctx, &RoleModel{
+		Name:          req.Name,
+		Description:   req.Description,
+		PermissionIDs: permissionIDs,
+		IsDefault:     req.IsDefault,
+		RequireMFA:    req.RequireMFA,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	})
+	if err != nil {
+		return nil, err
+	}
+	res, err := s.rolesToDTO(ctx, []RoleModel{*created})
+	if err != nil {
+		return nil, err
+	}
+	return &res[0], nil
+}
+
+func (s *accessService) UpdateRole(ctx context.Context, roleID string, req *IReqRoleDTO) (*RoleDTO, error) {
+	role, err := s.RoleRepository.GetRoleByID(ctx, roleID)
+	if err != nil {
+		return nil, err
+	}
+	permissionIDs, err := s.checkPermissionIDs(ctx, req.PermissionIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	role.Name = req.Name
+	role.Description = req.Description
+	role.PermissionIDs = permissionIDs
+	role.IsDefault = req.IsDefault
+	role.RequireMFA = req.RequireMFA
+	role.UpdatedAt = time.Now()
+
+	updated, err := s.RoleRepository.UpdateRole(ctx, role)
+	if err != nil {
+		return nil, err
+	}
+	res, err := s.rolesToDTO(ctx, []RoleModel{*updated})
+	if err != nil {
+		return nil, err
+	}
+	return &res[0], nil
+}
+
+func (s *accessService) DeleteRole(ctx context.Context, roleID string) (*RoleDTO, error) {
+	deleted, err := s.RoleRepository.DeleteRole(ctx, roleID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.UserRepository.PullRole(ctx, deleted.ID); err != nil {
+		s.Logger.Warn("usecase.DeleteRole : UserRepository.PullRole", zap.Error(err))
+	}
+	res, err := s.rolesToDTO(ctx, []RoleModel{*deleted})
+	if err != nil {
+		return nil, err
+	}
+	return &res[0], nil
+}
+
+// -- user grants
+
+func (s *accessService) ResolveUserAccess(ctx context.Context, username string) (*UserAccessDTO, error) {
+	roleIDs, permissionIDs, err := s.UserRepository.GetUserGrants(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	roleModels, err := s.RoleRepository.GetRolesByIDs(ctx, roleIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := slices.Clone(permissionIDs)
+	for _, role := range roleModels {
+		ids = append(ids, role.PermissionIDs...)
+	}
+	permissions, err := s.permissionsByID(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &UserAccessDTO{
+		Username:    username,
+		Roles:       make([]RoleDTO, len(roleModels)),
+		Permissions: []PermissionDTO{},
+		RoleNames:   make([]string, len(roleModels)),
+		Effective:   []string{},
+	}
+	for i, role := range roleModels {
+		res.Roles[i] = RoleModelToDTO(role, permissions)
+		res.RoleNames[i] = role.Name
+		res.MFARequired = res.MFARequired || role.RequireMFA
+	}
+	for _, id := range permissionIDs {
+		if p, ok := permissions[id]; ok {
+			res.Permissions = append(res.Permissions, PermissionModelToDTO(p))
+		}
+	}
+
+	for _, p := range permissions {
+		res.Effective = append(res.Effective, PermissionKey(p.Resource, p.Action))
+	}
+	if s.Config.RBAC != nil && slices.Contains(s.Config.RBAC.RBACAdminUsers, username) {
+		res.Effective = append(res.Effective, PermissionKey(PERMISSION_WILDCARD, PERMISSION_WILDCARD))
+	}
+	sort.Strings(res.Effective)
+	res.Effective = slices.Compact(res.Effective)
+	return res, nil
+}
+
+func (s *accessService) PutUserAccess(ctx context.Context, username string, req *IReqUserAccessDTO) (*UserAccessDTO, error) {
+	roleIDs, err := objectIDs(req.RoleIDs, ErrUnknownRole)
+	if err != nil {
+		return nil, err
+	}
+	roles, err := s.RoleRepository.GetRolesByIDs(ctx, roleIDs)
+	if err != nil {
+		return nil, err
+	}
+	if len(roles) != len(roleIDs) {
+		return nil, ErrUnknownRole
+	}
+	permissionIDs, err := s.checkPermissionIDs(ctx, req.PermissionIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.UserRepository.SetUserGrants(ctx, username, roleIDs, permissionIDs); err != nil {
+		return nil, err
+	}
+	return s.ResolveUserAccess(ctx, username)
+}
+
+func (s *accessService) AssignDefaultRoles(ctx context.Context, username string) error {
+	roles, err := s.RoleRepository.GetDefaultRoles(ctx)
+	if err != nil {
+		return err
+	}
+	if len(roles) == 0 {
+		return nil
+	}
+	roleIDs := make([]bson.ObjectID, len(roles))
+	for i, role := range roles {
+		roleIDs[i] = role.ID
+	}
+	return s.UserRepository.SetUserGrants(ctx, username, roleIDs, []bson.ObjectID{})
+}
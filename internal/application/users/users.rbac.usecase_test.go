@@ -0,0 +1,63 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.uber.org/zap"
+
+	"ecommerce/internal/env"
+	"ecommerce/internal/pkg"
+)
+
+type fakePermissionRepository struct {
+	PermissionRepository
+	created []PermissionModel
+}
+
+func (r *fakePermissionRepository) CreatePermission(ctx context.Context, permission *PermissionModel) (*PermissionModel, error) {
+	permission.ID = bson.NewObjectID()
+	r.created = append(r.created, *permission)
+	return permission, nil
+}
+
+func (r *fakePermissionRepository) GetPermissionByID(ctx context.Context, id string) (*PermissionModel, error) {
+	return &PermissionModel{Resource: "report", Action: "read"}, nil
+}
+
+func (r *fakePermissionRepository) UpdatePermission(ctx context.Context, permission *PermissionModel) (*PermissionModel, error) {
+	return permission, nil
+}
+
+func TestTenantPermissionWildcard(t *testing.T) {
+	repo := &fakePermissionRepository{}
+	s := NewAccessService(&env.Config{}, zap.NewNop(), nil, nil, repo)
+	tenantCtx := pkg.WithTenant(context.Background(), bson.NewObjectID().Hex())
+
+	for _, req := range []IReqPermissionDTO{
+		{Resource: "*", Action: "*"},
+		{Resource: "*", Action: "read"},
+		{Resource: "shopee.order", Action: "*"},
+		{Resource: "shopee.*", Action: "read"},
+	} {
+		if _, err := s.CreatePermission(tenantCtx, &req); !errors.Is(err, ErrWildcardPermission) {
+			t.Errorf("tenant create %s:%s : err = %v, want ErrWildcardPermission", req.Resource, req.Action, err)
+		}
+		if _, err := s.UpdatePermission(tenantCtx, bson.NewObjectID().Hex(), &req); !errors.Is(err, ErrWildcardPermission) {
+			t.Errorf("tenant update to %s:%s : err = %v, want ErrWildcardPermission", req.Resource, req.Action, err)
+		}
+	}
+	if len(repo.created) != 0 {
+		t.Fatalf("%d wildcard permissions stored", len(repo.created))
+	}
+
+	if _, err := s.CreatePermission(tenantCtx, &IReqPermissionDTO{Resource: "shopee.order", Action: "read"}); err != nil {
+		t.Errorf("tenant exact permission: %v", err)
+	}
+	platformCtx := pkg.WithTenant(context.Background(), pkg.DEFAULT_TENANT)
+	if _, err := s.CreatePermission(platformCtx, &IReqPermissionDTO{Resource: "shopee.*", Action: "read"}); err != nil {
+		t.Errorf("platform wildcard permission: %v", err)
+	}
+}
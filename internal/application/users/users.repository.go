@@ -19,6 +19,18 @@ type UserRepository interface {
   GetUserDetailByUsername(ctx context.Context,id string) (*UserEntity,error)
   UpdateUserDetail(ctx context.Context, user UserEntity) (*UserEntity, error)
   DeleteUser(ctx context.Context, user string) (*UserEntity, error)
+
+  // account : reset / verification flows, by id or email (both unique across tenants)
+  GetUserByEmail(ctx context.Context, email string) (*UserEntity, error)
+  SetPasswordHash(ctx context.Context, userID bson.ObjectID, passwordHash string) (*UserEntity, error)
+  SetEmailVerified(ctx context.Context, userID bson.ObjectID, email string) (*UserEntity, error)
+
+  // rbac : role / permission ids granted to the user
+  GetUserGrants(ctx context.Context, username string) ([]bson.ObjectID, []bson.ObjectID, error)
+  SetUserGrants(ctx context.Context, username string, roleIDs []bson.ObjectID, permissionIDs []bson.ObjectID) error
+  // ids are unique across tenants : cleanup runs unscoped
+  PullRole(ctx context.Context, roleID bson.ObjectID) error
+  PullPermission(ctx context.Context, permissionID bson.ObjectID) error
 }
 
 type userRepo struct {
@@ -41,6 +53,12 @@ func (r *userRepo) InitRepository() error {
       Keys: bson.D{{Key: "email", Value: 1}},
       Options: options.Index().SetUnique(true),
     },
+    {
+      Keys: bson.D{{Key: "role_ids", Value: 1}},
+    },
+    {
+      Keys: bson.D{{Key: "tenant_id", Value: 1}, {Key: "username", Value: 1}},
+    },
   }
 
   _, err := r.db.Indexes().CreateMany(context.TODO(), requiredIndexs)
@@ -65,6 +83,10 @@ func (r *userRepo) CreateUser(ctx context.Context,userEntityParam UserEntity) (*
     return nil ,errors.New("failed to convert Entity_Model")
   }
 
+  // tenant comes from the caller, never from the entity
+  user.TenantID, err = tenantObjectID(ctx)
+  if err != nil { return nil, err }
+
   var userTarget UserModel
   filter := bson.M{"username": user.Username}
   err = r.db.FindOne(ctx, filter).Decode(&userTarget)
@@ -106,7 +128,7 @@ func (r *userRepo) GetAllUserDetail(ctx context.Context) ([]UserEntity, error) {
   // r.logger.Info("resEntity", zap.String("string" , resOne.ID.Hex() ) )
 
 
-  cursor,err := r.db.Find(ctx,bson.M{})
+  cursor,err := r.db.Find(ctx,tenantFilter(ctx, bson.M{}))
   if err != nil { return nil,err }
   defer cursor.Close(ctx)
 
@@ -129,7 +151,7 @@ func (r *userRepo) GetAllUserDetail(ctx context.Context) ([]UserEntity, error) {
 func (r *userRepo) GetUserDetailByUsername(ctx context.Context,id string) (*UserEntity,error) {
 
   var res UserModel
-  filter := bson.M{"username": id}
+  filter := tenantFilter(ctx, bson.M{"username": id})
   err := r.db.FindOne(ctx, filter).Decode(&res)
 
   if err != nil {
@@ -146,7 +168,7 @@ func (r *userRepo) GetUserDetailByUsername(ctx context.Context,id string) (*User
 
 func (r *userRepo) UpdateUserDetail(ctx context.Context, user UserEntity) (*UserEntity, error) {
 
-  filter := bson.M{"username" : user.Username}
+  filter := tenantFilter(ctx, bson.M{"username" : user.Username})
 
   updateFields := bson.M{}
   if user.FullName != "" { updateFields["full_name"] = &user.FullName }
@@ -180,7 +202,7 @@ func (r *userRepo) UpdateUserDetail(ctx context.Context, user UserEntity) (*User
 func (r *userRepo) DeleteUser(ctx context.Context, user string) (*UserEntity, error) {
 
 
-  filter := bson.M{"username" : user}
+  filter := tenantFilter(ctx, bson.M{"username" : user})
   opts := options.FindOneAndDelete()
   var deleteUser UserModel
   err := r.db.FindOneAndDelete(ctx, filter, opts).Decode(&deleteUser)
@@ -198,3 +220,114 @@ func (r *userRepo) DeleteUser(ctx context.Context, user string) (*UserEntity, er
   return &deleteUserParse,nil
 
 }
+
+
+func (r *userRepo) GetUserByEmail(ctx context.Context, email string) (*UserEntity, error) {
+
+  var res UserModel
+  err := r.db.FindOne(ctx, tenantFilter(ctx, bson.M{"email": email})).Decode(&res)
+  if err != nil {
+    if errors.Is(err, mongo.ErrNoDocuments) { return nil, ErrUserNotFound }
+    return nil, err
+  }
+
+  resParse := UserModelToEntity(res)
+  return &resParse, nil
+}
+
+// SetPasswordHash : deleted accounts stay locked out
+func (r *userRepo) SetPasswordHash(ctx context.Context, userID bson.ObjectID, passwordHash string) (*UserEntity, error) {
+
+  filter := tenantFilter(ctx, bson.M{"_id": userID, "is_deleted": false})
+  update := bson.M{"$set": bson.M{"password_hash": passwordHash, "updated_at": time.Now()}}
+
+  opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+  var updatedUser UserModel
+  err := r.db.FindOneAndUpdate(ctx, filter, update, opts).Decode(&updatedUser)
+  if err != nil {
+    if errors.Is(err, mongo.ErrNoDocuments) { return nil, ErrUserNotFound }
+    return nil, err
+  }
+
+  resParse := UserModelToEntity(updatedUser)
+  return &resParse, nil
+}
+
+// SetEmailVerified : only while the account still has the verified address
+func (r *userRepo) SetEmailVerified(ctx context.Context, userID bson.ObjectID, email string) (*UserEntity, error) {
+
+  filter := tenantFilter(ctx, bson.M{"_id": userID, "email": email})
+  now := time.Now()
+  update := bson.M{"$set": bson.M{"email_verified_at": now, "updated_at": now}}
+
+  opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+  var updatedUser UserModel
+  err := r.db.FindOneAndUpdate(ctx, filter, update, opts).Decode(&updatedUser)
+  if err != nil {
+    if errors.Is(err, mongo.ErrNoDocuments) { return nil, ErrUserNotFound }
+    return nil, err
+  }
+
+  resParse := UserModelToEntity(updatedUser)
+  return &resParse, nil
+}
+
+func (r *userRepo) GetUserGrants(ctx context.Context, username string) ([]bson.ObjectID, []bson.ObjectID, error) {
+
+  var res UserModel
+  opts := options.FindOne().SetProjection(bson.M{"role_ids": 1, "permission_ids": 1})
+  err := r.db.FindOne(ctx, tenantFilter(ctx, bson.M{"username": username}), opts).Decode(&res)
+  if err != nil {
+    if errors.Is(err, mongo.ErrNoDocuments) { return nil, nil, ErrUserNotFound }
+    return nil, nil, err
+  }
+
+  return res.RoleIDs, res.PermissionIDs, nil
+}
+
+func (r *userRepo) SetUserGrants(ctx context.Context, username string, roleIDs []bson.ObjectID, permissionIDs []bson.ObjectID) error {
+
+  update := bson.M{"$set": bson.M{
+    "role_ids": roleIDs,
+    "permission_ids": permissionIDs,
+    "updated_at": time.Now(),
+  }}
+
+  res, err := r.db.UpdateOne(ctx, tenantFilter(ctx, bson.M{"username": username}), update)
+  if err != nil { return err }
+  if res.MatchedCount == 0 { return ErrUserNotFound }
+  return nil
+}
+
+func (r *userRepo) PullRole(ctx context.Context, roleID bson.ObjectID) error {
+  _, err := r.db.UpdateMany(ctx, bson.M{"role_ids": roleID}, bson.M{"$pull": bson.M{"role_ids": roleID}})
+  return err
+}
+
+func (r *userRepo) PullPermission(ctx context.Context, permissionID bson.ObjectID) error {
+  _, err := r.db.UpdateMany(ctx, bson.M{"permission_ids": permissionID}, bson.M{"$pull": bson.M{"permission_ids": permissionID}})
+  return err
+}
+
+// tenant_id on users / roles / permissions is an ObjectID, nil for the platform tenant
+
+// tenantObjectID : tenant of ctx to store on a new record
+func tenantObjectID(ctx context.Context) (*bson.ObjectID, error) {
+  tenantID, err := pkg.TenantStamp(ctx)
+  if err != nil { return nil, err }
+  if tenantID == "" { return nil, nil }
+  objectID, err := bson.ObjectIDFromHex(tenantID)
+  if err != nil { return nil, ErrInvalidTenant }
+  return &objectID, nil
+}
+
+// tenantFilter : pkg.TenantFilter for an ObjectID tenant_id ; a malformed id matches nothing
+func tenantFilter(ctx context.Context, filter bson.M) bson.M {
+  pkg.TenantFilter(ctx, filter)
+  if tenantID, ok := filter["tenant_id"].(string); ok {
+    if objectID, err := bson.ObjectIDFromHex(tenantID); err == nil {
+      filter["tenant_id"] = objectID
+    }
+  }
+  return filter
+}
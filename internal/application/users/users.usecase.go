@@ -24,15 +24,21 @@ type userService struct {
   Logger *zap.Logger
 
   UserRepository UserRepository
+  Access IAccessService
+  Account IAccountService
 }
 
 func NewUserService(cfg *env.Config, log *zap.Logger, 
   userRepo UserRepository,
+  access IAccessService,
+  account IAccountService,
 ) IUserService {
   return &userService{
     Config: cfg,
     Logger: log,
     UserRepository: userRepo,
+    Access: access,
+    Account: account,
   }
 }
 
@@ -65,6 +71,16 @@ func (s *userService) CreateUser(ctx context.Context, userCreate IReqCreateUserD
     // s.Logger.Error("usecase.CreateUser:",zap.Error(err)) 
     return nil,err }
 
+  // rbac : new accounts start with the default roles, an admin grants the rest
+  if err := s.Access.AssignDefaultRoles(ctx, savedUser.Username); err != nil {
+    s.Logger.Warn("usecase.CreateUser: AssignDefaultRoles", zap.String("username", savedUser.Username), zap.Error(err))
+  }
+
+  // the account exists either way, the user can ask for another link from /user/me/verify-email
+  if err := s.Account.SendEmailVerification(ctx, savedUser.Username); err != nil {
+    s.Logger.Warn("usecase.CreateUser: SendEmailVerification", zap.String("username", savedUser.Username), zap.Error(err))
+  }
+
   // -> To DTO
   respDTO,err := pkg.MapStruct[UserEntity,UserDTO](*savedUser)
 
@@ -3,11 +3,26 @@ package handler
 import (
 	"ecommerce/internal/application/auth"
 	"ecommerce/internal/application/demo"
+	"ecommerce/internal/application/fulfillment"
 	"ecommerce/internal/application/health"
+	"ecommerce/internal/application/inventory"
+	"ecommerce/internal/application/invoice"
+	"ecommerce/internal/application/marketplace"
+	"ecommerce/internal/application/product"
+	"ecommerce/internal/application/purchase"
 	"ecommerce/internal/application/shopee"
+	"ecommerce/internal/application/shopee/item"
+	"ecommerce/internal/application/shopee/label"
+	"ecommerce/internal/application/shopee/logistics"
 	"ecommerce/internal/application/shopee/partner"
+	"ecommerce/internal/application/shopee/payment"
+	"ecommerce/internal/application/shopee/push"
+	"ecommerce/internal/application/shopee/returns"
+	"ecommerce/internal/application/stocksync"
 	"ecommerce/internal/application/swagger"
+	"ecommerce/internal/application/tenant"
 	"ecommerce/internal/application/users"
+	"ecommerce/internal/delivery/http/middleware"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -15,38 +30,87 @@ import (
 type RouterHandler struct {
   callback       fiber.Handler
   shopeeMiddleware fiber.Handler
+  // the :shopeeShopID of the route belongs to the caller's tenant
+  shopOwner fiber.Handler
 	healthHandler  health.HealthHandler
 	swaggerHandler swagger.SwaggerHandler
 	demoHandler    demo.DemoHandler
 	shopeeHandler  shopee.IShopeeHandler
   partnerHandler partner.IShopeePartnerHandler
+  pushHandler    push.IShopeePushHandler
+  itemHandler    item.IShopeeItemHandler
+  logisticsHandler logistics.IShopeeLogisticsHandler
+  labelHandler   label.IShopeeLabelHandler
+  paymentHandler payment.IShopeePaymentHandler
+  returnHandler  returns.IShopeeReturnHandler
+  marketplaceHandler marketplace.IMarketplaceHandler
+  productHandler product.IProductHandler
+  inventoryHandler inventory.IInventoryHandler
+  stockSyncHandler stocksync.IStockSyncHandler
+  purchaseHandler purchase.IPurchaseHandler
+  fulfillmentHandler fulfillment.IFulfillmentHandler
+  invoiceHandler invoice.IInvoiceHandler
   authHandler    auth.AuthHandler
   usersHandle    users.IUserHandler 
+  accessHandler  users.IAccessHandler
+  tenantHandler  tenant.ITenantHandler
   // userHandle     user.IUserHandler
 }
 
 func NewRouterHandler(
   fn fiber.Handler,
   shop  fiber.Handler,
+  owner fiber.Handler,
 
 	health  health.HealthHandler,
 	swagger swagger.SwaggerHandler,
 	demo    demo.DemoHandler,
 	shopee  shopee.IShopeeHandler,
   partner partner.IShopeePartnerHandler,
+  push    push.IShopeePushHandler,
+  item    item.IShopeeItemHandler,
+  logistics logistics.IShopeeLogisticsHandler,
+  label   label.IShopeeLabelHandler,
+  payment payment.IShopeePaymentHandler,
+  ret     returns.IShopeeReturnHandler,
+  market  marketplace.IMarketplaceHandler,
+  product product.IProductHandler,
+  stock   inventory.IInventoryHandler,
+  sync    stocksync.IStockSyncHandler,
+  po      purchase.IPurchaseHandler,
+  ff      fulfillment.IFulfillmentHandler,
+  inv     invoice.IInvoiceHandler,
   auth    auth.AuthHandler,
   user    users.IUserHandler, // user *user.
+  access  users.IAccessHandler,
+  tenants tenant.ITenantHandler,
 ) *RouterHandler {
 	return &RouterHandler{
     callback: fn,
     shopeeMiddleware: shop,
+    shopOwner: owner,
 
 		healthHandler:  health,
 		swaggerHandler: swagger,
 		demoHandler:    demo, shopeeHandler:  shopee,
     partnerHandler: partner,
+    pushHandler: push,
+    itemHandler: item,
+    logisticsHandler: logistics,
+    labelHandler: label,
+    paymentHandler: payment,
+    returnHandler: ret,
+    marketplaceHandler: market,
+    productHandler: product,
+    inventoryHandler: stock,
+    stockSyncHandler: sync,
+    purchaseHandler: po,
+    fulfillmentHandler: ff,
+    invoiceHandler: inv,
     authHandler: auth,
     usersHandle: user,
+    accessHandler: access,
+    tenantHandler: tenants,
 	}
 }
 // SWAGGER : init
@@ -54,6 +118,15 @@ func NewRouterHandler(
 
 func (r *RouterHandler) RegisterHandlers(router fiber.Router) {
 
+  // RBAC : every group behind r.callback checks "resource:read" on GET, "resource:write" otherwise
+  can := middleware.RequireAccess
+  // state shared by every tenant : platform tenant accounts only, whatever their permissions
+  platform := middleware.RequirePlatformTenant()
+  // every /shopee/shop/:shopeeShopID route : the shop must belong to the token's tenant
+  owned := r.shopOwner
+  // public routes : no token, no tenant yet, lookups go across tenants on purpose
+  public := middleware.Unscoped()
+
 	health := router.Group("/health")
 	health.Get("/", r.healthHandler.HealthCheck)
 
@@ -64,26 +137,177 @@ func (r *RouterHandler) RegisterHandlers(router fiber.Router) {
   demo.Get("/", r.demoHandler.DemoCheck)
 
   // Auth Handler 
-  auth := router.Group("/auth")
+  auth := router.Group("/auth", public)
   // auth.Get("/", r.authHandler.CheckAuth)
   auth.Post("/login", r.authHandler.PostUserAuthLogin )
   auth.Post("/refresh", r.authHandler.PostUserAuthRefresh )
-  // auth/refresh
-  // auth/logout
+  auth.Post("/logout", r.authHandler.PostUserAuthLogout )
+  auth.Post("/logout-all", r.callback, r.authHandler.PostUserAuthLogoutAll )
+  auth.Post("/forgot-password", r.authHandler.PostUserAuthForgotPassword )
+  auth.Post("/reset-password", r.authHandler.PostUserAuthResetPassword )
+  auth.Post("/verify-email", r.authHandler.PostUserAuthVerifyEmail )
+  // 2fa second step : the mfa_token from /login stands for the access token
+  auth.Post("/mfa/verify", r.authHandler.PostUserAuthMFAVerify )
+  auth.Post("/mfa/enroll", r.authHandler.PostUserAuthMFAEnroll )
   // auth/register
   // auth/me
 
   // auth.Get("/", func(c *fiber.Ctx)error {return c.SendString("ok!")} )
 
+  // own account : any valid access token
   me := router.Group("/user",r.callback)
   me.Get("/me", r.usersHandle.GetUserMe)
+  me.Get("/me/access", r.accessHandler.GetUserMeAccess)
+  me.Get("/me/sessions", r.authHandler.GetUserMeSessions)
+  me.Delete("/me/sessions/:sessionID", r.authHandler.DeleteUserMeSession)
+  me.Post("/me/verify-email", r.authHandler.PostUserMeVerifyEmail)
+  me.Get("/me/mfa", r.authHandler.GetUserMeMFA)
+  me.Post("/me/mfa/enroll", r.authHandler.PostUserMeMFAEnroll)
+  me.Post("/me/mfa/activate", r.authHandler.PostUserMeMFAActivate)
+  me.Post("/me/mfa/disable", r.authHandler.PostUserMeMFADisable)
+  me.Post("/me/mfa/recovery-codes", r.authHandler.PostUserMeMFARecoveryCodes)
 
-  user := router.Group("/users")
+  user := router.Group("/users", r.callback, can("user"))
   user.Get("/", r.usersHandle.GetUsers)
   user.Get("/:userId", r.usersHandle.GetUserByID)
   user.Post("/", r.usersHandle.CreateUser)
   user.Patch("/:userId", r.usersHandle.UpdateUserByID) 
   user.Delete("/:userId", r.usersHandle.DeleteUserByID)
+  user.Delete("/:userId/mfa", r.authHandler.DeleteUserMFA)
+
+  // Roles / permissions / user grants : token permissions change on the user's next refresh
+  rbac := router.Group("/rbac", r.callback, can("rbac"))
+  rbac.Get("/permissions", r.accessHandler.GetPermissions)
+  rbac.Post("/permissions", r.accessHandler.PostPermission)
+  rbac.Put("/permissions/:permissionID", r.accessHandler.PutPermission)
+  rbac.Delete("/permissions/:permissionID", r.accessHandler.DeletePermission)
+  rbac.Get("/roles", r.accessHandler.GetRoles)
+  rbac.Post("/roles", r.accessHandler.PostRole)
+  rbac.Get("/roles/:roleID", r.accessHandler.GetRoleByID)
+  rbac.Put("/roles/:roleID", r.accessHandler.PutRole)
+  rbac.Delete("/roles/:roleID", r.accessHandler.DeleteRole)
+  rbac.Get("/users/:userId", r.accessHandler.GetUserAccess)
+  rbac.Put("/users/:userId", r.accessHandler.PutUserAccess)
+
+  // Tenants : platform tenant accounts only, every other group is scoped to the token's tenant
+  tenants := router.Group("/tenants", r.callback, can("tenant"))
+  tenants.Get("/", r.tenantHandler.GetTenants)
+  tenants.Post("/", r.tenantHandler.PostTenant)
+  tenants.Get("/:tenantID", r.tenantHandler.GetTenantByID)
+  tenants.Put("/:tenantID", r.tenantHandler.PutTenant)
+  tenants.Post("/:tenantID/owner", r.tenantHandler.PostTenantOwner)
+
+  // Shopee Push Mechanism : no JWT, verified by Authorization signature
+  // outside /shopee : that group applies r.callback to every path under it
+  webhook := router.Group("/webhook", public)
+  webhook.Post("/shopee/push/:partnerID", r.pushHandler.PostShopeePush)
+  // seller consent redirect (Lazada, ...) : verified by the signed state
+  webhook.Get("/marketplace/:channel/auth/callback", r.marketplaceHandler.GetMarketplaceAuthCallback)
+
+  // Marketplace : channel-neutral (shopee, lazada, tiktok)
+  mp := router.Group("/marketplace", r.callback, can("marketplace"))
+  mp.Get("/channels", r.marketplaceHandler.GetMarketplaceChannels)
+  mp.Get("/:channel/apps", r.marketplaceHandler.GetMarketplaceApps)
+  mp.Post("/:channel/apps", r.marketplaceHandler.PostMarketplaceApp)
+  mp.Get("/:channel/apps/:appKey/auth_link", r.marketplaceHandler.GetMarketplaceAuthLink)
+  mp.Get("/:channel/shops", r.marketplaceHandler.GetMarketplaceShops)
+  mpShop := mp.Group("/:channel/shops/:shopID")
+  mpShop.Get("/", r.marketplaceHandler.GetMarketplaceShop)
+  mpShop.Get("/orders", r.marketplaceHandler.GetMarketplaceOrders)
+  mpShop.Get("/orders/:orderID", r.marketplaceHandler.GetMarketplaceOrder)
+  mpShop.Post("/orders/:orderID/ship", r.marketplaceHandler.PostMarketplaceShip)
+  mpShop.Get("/items", r.marketplaceHandler.GetMarketplaceItems)
+  mpShop.Put("/stock", r.marketplaceHandler.PutMarketplaceStock)
+
+  // Master catalog : fixed paths before /:productID
+  products := router.Group("/products", r.callback, can("product"))
+  products.Get("/", r.productHandler.GetProducts)
+  products.Post("/", r.productHandler.PostProduct)
+  products.Get("/sku/:sku", r.productHandler.GetProductBySKU)
+  products.Get("/barcode/:barcode", r.productHandler.GetProductByBarcode)
+  products.Get("/mappings", r.productHandler.GetSKUMappings)
+  products.Post("/mappings", r.productHandler.PostSKUMapping)
+  products.Delete("/mappings/:mappingID", r.productHandler.DeleteSKUMapping)
+  products.Post("/resolve", r.productHandler.PostResolveSKU)
+  products.Get("/resolve/shopee/:shopeeShopID/orders/:orderSN", owned, r.productHandler.GetShopeeOrderSKUs)
+  products.Get("/:productID", r.productHandler.GetProductByID)
+  products.Put("/:productID", r.productHandler.PutProduct)
+  products.Delete("/:productID", r.productHandler.DeleteProduct)
+
+  // Inventory : ledger is append only, balances move through the postings below
+  stock := router.Group("/inventory", r.callback, can("inventory"))
+  stock.Get("/warehouses", r.inventoryHandler.GetWarehouses)
+  stock.Post("/warehouses", r.inventoryHandler.PostWarehouse)
+  stock.Get("/stock", r.inventoryHandler.GetStock)
+  stock.Get("/stock/:sku", r.inventoryHandler.GetStockBySKU)
+  stock.Put("/stock/:sku/location", r.inventoryHandler.PutStockLocation)
+  stock.Get("/movements", r.inventoryHandler.GetMovements)
+  stock.Post("/receipts", r.inventoryHandler.PostReceipt)
+  stock.Post("/returns", r.inventoryHandler.PostReturn)
+  stock.Post("/adjustments", r.inventoryHandler.PostAdjustment)
+  stock.Post("/transfers", r.inventoryHandler.PostTransfer)
+  stock.Get("/reservations/:channel/:shopID/:orderID", r.inventoryHandler.GetOrderReservations)
+
+  // Marketplace stock push : rules per channel / shop, manual (or dry run) push, push log
+  stockSync := router.Group("/stock-sync", r.callback, can("stock_sync"))
+  stockSync.Get("/status", r.stockSyncHandler.GetStockSyncStatus)
+  stockSync.Post("/push", r.stockSyncHandler.PostStockPush)
+  stockSync.Get("/logs", r.stockSyncHandler.GetStockPushLogs)
+  stockSync.Get("/rules", r.stockSyncHandler.GetStockSyncRules)
+  stockSync.Put("/rules", r.stockSyncHandler.PutStockSyncRule)
+  stockSync.Delete("/rules/:ruleID", r.stockSyncHandler.DeleteStockSyncRule)
+
+  // Purchasing : suppliers, purchase orders (DRAFT -> APPROVED -> PARTIALLY_RECEIVED -> CLOSED), goods receipts
+  suppliers := router.Group("/suppliers", r.callback, can("purchase"))
+  suppliers.Get("/", r.purchaseHandler.GetSuppliers)
+  suppliers.Post("/", r.purchaseHandler.PostSupplier)
+  suppliers.Get("/:supplierID", r.purchaseHandler.GetSupplierByID)
+  suppliers.Put("/:supplierID", r.purchaseHandler.PutSupplier)
+  suppliers.Delete("/:supplierID", r.purchaseHandler.DeleteSupplier)
+
+  po := router.Group("/purchase-orders", r.callback, can("purchase"))
+  po.Get("/", r.purchaseHandler.GetPurchaseOrders)
+  po.Post("/", r.purchaseHandler.PostPurchaseOrder)
+  po.Get("/:poID", r.purchaseHandler.GetPurchaseOrderByID)
+  po.Put("/:poID", r.purchaseHandler.PutPurchaseOrder)
+  po.Post("/:poID/approve", r.purchaseHandler.PostApprovePurchaseOrder)
+  po.Post("/:poID/cancel", r.purchaseHandler.PostCancelPurchaseOrder)
+  po.Post("/:poID/close", r.purchaseHandler.PostClosePurchaseOrder)
+  po.Get("/:poID/receipts", r.purchaseHandler.GetGoodsReceipts)
+  po.Post("/:poID/receipts", r.purchaseHandler.PostGoodsReceipt)
+
+  grn := router.Group("/goods-receipts", r.callback, can("purchase"))
+  grn.Get("/:grnID", r.purchaseHandler.GetGoodsReceiptByID)
+  grn.Post("/:grnID/post", r.purchaseHandler.PostRetryGoodsReceipt)
+
+  // Fulfillment : wave (READY_TO_SHIP orders) -> pick list by location -> scan to pack -> ship ;
+  // status is the warehouse side, the marketplace order_status is left to the channel
+  ff := router.Group("/fulfillment", r.callback, can("fulfillment"))
+  ff.Get("/waves", r.fulfillmentHandler.GetWaves)
+  ff.Post("/waves", r.fulfillmentHandler.PostWave)
+  ff.Get("/waves/:waveID", r.fulfillmentHandler.GetWaveByID)
+  ff.Get("/waves/:waveID/pick-list", r.fulfillmentHandler.GetPickList)
+  ff.Post("/waves/:waveID/pick", r.fulfillmentHandler.PostConfirmPicked)
+  ff.Post("/waves/:waveID/ship", r.fulfillmentHandler.PostShipWave)
+  ff.Get("/orders", r.fulfillmentHandler.GetFulfillmentOrders)
+  ff.Get("/orders/:fulfillmentID", r.fulfillmentHandler.GetFulfillmentOrderByID)
+  ff.Post("/orders/:fulfillmentID/scan", r.fulfillmentHandler.PostPackScan)
+  ff.Post("/orders/:fulfillmentID/pack/reset", r.fulfillmentHandler.PostPackReset)
+  ff.Post("/orders/:fulfillmentID/ship", r.fulfillmentHandler.PostShipFulfillmentOrder)
+  ff.Post("/orders/:fulfillmentID/cancel", r.fulfillmentHandler.PostCancelFulfillmentOrder)
+
+  // Tax documents (Thai VAT) : tax invoice / receipt per order, credit note per refunded return ;
+  // numbers are gap free per tenant, type and month, documents are voided never deleted
+  inv := router.Group("/invoices", r.callback, can("invoice"))
+  inv.Get("/profile", r.invoiceHandler.GetTaxProfile)
+  inv.Put("/profile", r.invoiceHandler.PutTaxProfile)
+  inv.Get("/", r.invoiceHandler.GetDocuments)
+  inv.Post("/", r.invoiceHandler.PostOrderDocument)
+  inv.Post("/credit-notes", r.invoiceHandler.PostCreditNote)
+  inv.Get("/:documentID", r.invoiceHandler.GetDocumentByID)
+  inv.Get("/:documentID/pdf", r.invoiceHandler.GetDocumentPDF)
+  inv.Get("/:documentID/xml", r.invoiceHandler.GetDocumentXML)
+  inv.Post("/:documentID/void", r.invoiceHandler.PostVoidDocument)
 
   // Shopee Handle
 	shopee := router.Group("/shopee", r.callback)
@@ -91,17 +315,20 @@ func (r *RouterHandler) RegisterHandlers(router fiber.Router) {
 
 	// Generate Link for Auth + add to DB
 	// shopee.Post("/shop/add_auth_partner", r.shopeeHandler.PostShopAuthPartner)
-  shopee.Post("/shop/auth_partner", r.shopeeHandler.PostShopAuthPartner)
-  shopee.Post("/shop/auth_token",  r.shopeeHandler.PostShopeeTokenAuthPartnerWithCode)
+  shopee.Post("/shop/auth_partner", can("shopee.shop"), r.shopeeHandler.PostShopAuthPartner)
+  shopee.Post("/shop/auth_token", can("shopee.shop"), r.shopeeHandler.PostShopeeTokenAuthPartnerWithCode)
 
-  shopee.Get("/shop/auth_token/:shopeeShopID",  r.shopeeHandler.GetShopeeTokenAuthPartnerByShopId)
+  // before :shopeeShopID
+  shopee.Get("/shop/auth_token/reauth", can("shopee.shop"), r.shopeeHandler.GetShopeeShopAuthNeedReauth)
+  shopee.Get("/shop/auth_token/:shopeeShopID", can("shopee.shop"), owned, r.shopeeHandler.GetShopeeTokenAuthPartnerByShopId)
+  shopee.Post("/shop/auth_token/:shopeeShopID/refresh", can("shopee.shop"), owned, r.shopeeHandler.PostShopeeRefreshTokenByShopId)
 
   // webhook - auth
-  shopee.Get("/webhook/auth_partner/:partnerId", r.shopeeHandler.GetWebHookAuthPartner)
+  shopee.Get("/webhook/auth_partner/:partnerId", can("shopee.shop"), r.shopeeHandler.GetWebHookAuthPartner)
    
 
 
-  partner := shopee.Group("/partner")
+  partner := shopee.Group("/partner", can("shopee.partner"))
   // Crud Shopee Partner
   partner.Post("/", r.partnerHandler.CreateShopeePartner)
   partner.Get("/", r.partnerHandler.GetAllShopeePartner)
@@ -115,6 +342,16 @@ func (r *RouterHandler) RegisterHandlers(router fiber.Router) {
   // to send code and shop id to request asccess and refresh from Shopee
   partner.Get("/:partnerID/webhook",r.shopeeHandler.GetWebHookAuthPartner, r.shopeeMiddleware )
 
+  // outbound Shopee client : circuit breaker per endpoint, shared by every tenant
+  shopeeAdmin := shopee.Group("/admin", platform, can("shopee.admin"))
+  shopeeAdmin.Get("/circuit_breakers", r.shopeeHandler.GetShopeeCircuitBreakers)
+  shopeeAdmin.Post("/circuit_breakers/reset", r.shopeeHandler.PostShopeeCircuitBreakerReset)
+
+  // push events : list + replay, raw bodies of every partner
+  pushEvent := shopee.Group("/push/events", platform, can("shopee.push"))
+  pushEvent.Get("/", r.pushHandler.GetShopeePushEvents)
+  pushEvent.Post("/:eventID/replay", r.pushHandler.PostShopeePushEventReplay)
+
   // waiting to update struct 
   // --> to partner check all shop is under manage
   // shopee.Get("/shop_list/:partnerID", r.shopeeHandler.GetShopeeShopListByPartnerID )
@@ -122,13 +359,57 @@ func (r *RouterHandler) RegisterHandlers(router fiber.Router) {
   // waiting 
   // shopee.Get("/partner/shop_detail/:shopID", func(c *fiber.Ctx) error { return c.SendString("OK")})
 
-  shopee.Get("/shop/:shopeeShopID/details", r.shopeeHandler.GetShopeeShopDetails )
+  shopee.Get("/shop/:shopeeShopID/details", can("shopee.shop"), owned, r.shopeeHandler.GetShopeeShopDetails )
 
   // |----> shopee.Get("/shop/order_list/:shopeeShopID", r.shopeeHandler.GetShopeeOrderListByShopID )
-  shopee.Get("/shop/:shopeeShopID/orders", r.shopeeHandler.GetShopeeOrderListByShopID )
+  shopee.Get("/shop/:shopeeShopID/orders", can("shopee.order"), owned, r.shopeeHandler.GetShopeeOrderListByShopID )
+
+  // incremental sync : before :orderSN
+  shopee.Post("/shop/:shopeeShopID/orders/sync", can("shopee.order"), owned, r.shopeeHandler.PostShopeeOrderSyncByShopID )
+  shopee.Get("/shop/:shopeeShopID/orders/sync", can("shopee.order"), owned, r.shopeeHandler.GetShopeeOrderSyncByShopID )
+  shopee.Post("/shop/:shopeeShopID/orders/batch_ship", can("shopee.logistics"), owned, r.logisticsHandler.PostBatchShipOrder )
   
   // |----> shopee.Get("/shop/order_detail/:shopeeShopID/:orderSN", )
-  shopee.Get("/shop/:shopeeShopID/orders/:orderSN", r.shopeeHandler.GetShopeeOrderDetailsByShopIDAndOrderSN )
+  shopee.Get("/shop/:shopeeShopID/orders/:orderSN", can("shopee.order"), owned, r.shopeeHandler.GetShopeeOrderDetailsByShopIDAndOrderSN )
+
+  // logistics
+  shopee.Get("/shop/:shopeeShopID/orders/:orderSN/shipping_parameter", can("shopee.logistics"), owned, r.logisticsHandler.GetShippingParameter )
+  shopee.Post("/shop/:shopeeShopID/orders/:orderSN/ship", can("shopee.logistics"), owned, r.logisticsHandler.PostShipOrder )
+  shopee.Get("/shop/:shopeeShopID/orders/:orderSN/tracking_number", can("shopee.logistics"), owned, r.logisticsHandler.GetTrackingNumber )
+  shopee.Get("/shop/:shopeeShopID/orders/:orderSN/tracking_info", can("shopee.logistics"), owned, r.logisticsHandler.GetTrackingInfo )
+
+  // shipping label / AWB
+  shopee.Post("/shop/:shopeeShopID/orders/:orderSN/label", can("shopee.label"), owned, r.labelHandler.PostShippingLabel )
+  shopee.Get("/shop/:shopeeShopID/orders/:orderSN/label", can("shopee.label"), owned, r.labelHandler.GetShippingLabel )
+  shopee.Post("/shop/:shopeeShopID/labels/batch", can("shopee.label"), owned, r.labelHandler.PostShippingLabelBatch )
+
+  // escrow / payout reconciliation
+  shopee.Get("/shop/:shopeeShopID/orders/:orderSN/escrow", can("shopee.payment"), owned, r.paymentHandler.GetShopeeOrderEscrow )
+  shopee.Post("/shop/:shopeeShopID/escrow/sync", can("shopee.payment"), owned, r.paymentHandler.PostShopeeEscrowSync )
+  shopee.Get("/shop/:shopeeShopID/payouts", can("shopee.payment"), owned, r.paymentHandler.GetShopeePayouts )
+  shopee.Get("/shop/:shopeeShopID/reconciliation", can("shopee.payment"), owned, r.paymentHandler.GetShopeeReconciliation )
+
+  // returns / refunds (RMA) : sync before :returnSN
+  shopee.Get("/shop/:shopeeShopID/orders/:orderSN/returns", can("shopee.return"), owned, r.returnHandler.GetShopeeReturnsByOrderSN )
+  returns := shopee.Group("/shop/:shopeeShopID/returns", can("shopee.return"), owned)
+  returns.Post("/sync", r.returnHandler.PostShopeeReturnSync)
+  returns.Get("/", r.returnHandler.GetShopeeReturns)
+  returns.Get("/:returnSN", r.returnHandler.GetShopeeReturnByReturnSN)
+  returns.Post("/:returnSN/accept", r.returnHandler.PostShopeeReturnAccept)
+  returns.Post("/:returnSN/offer", r.returnHandler.PostShopeeReturnOffer)
+  returns.Post("/:returnSN/evidence", r.returnHandler.PostShopeeReturnEvidence)
+  returns.Post("/:returnSN/dispute", r.returnHandler.PostShopeeReturnDispute)
+
+  // product / item
+  items := shopee.Group("/shop/:shopeeShopID/items", can("shopee.item"), owned)
+  items.Get("/", r.itemHandler.GetShopeeItemListByShopID)
+  items.Post("/", r.itemHandler.CreateShopeeItem)
+  items.Get("/:itemID", r.itemHandler.GetShopeeItemByItemID)
+  items.Patch("/:itemID", r.itemHandler.UpdateShopeeItem)
+  items.Get("/:itemID/models", r.itemHandler.GetShopeeItemModelList)
+  items.Put("/:itemID/price", r.itemHandler.UpdateShopeeItemPrice)
+  items.Put("/:itemID/stock", r.itemHandler.UpdateShopeeItemStock)
+  items.Post("/:itemID/unlist", r.itemHandler.UnlistShopeeItem)
 
   // shoperPartner := router.Group("/shopee-partner")
   // shoperPartner.Get("/", func(c *fiber.Ctx) error { return c.SendString("ok !")} )
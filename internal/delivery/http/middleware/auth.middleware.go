@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"ecommerce/internal/env"
+	"ecommerce/internal/pkg"
 	"strings"
 	"time"
 
@@ -23,6 +24,12 @@ type AccessAuthEntity struct {
   Sub      string `json:"sub"`
   Type     string `json:"type"`
   Username string `json:"username"`
+  Roles       []string `json:"roles"`
+  Permissions []string `json:"perms"`
+  // tenant hex id, pkg.DEFAULT_TENANT (or missing on older tokens) for the platform tenant
+  TenantID    string `json:"tenant_id"`
+  // refresh-token session the token was issued for, empty on tokens older than sessions
+  SessionID   string `json:"sid"`
   jwt.RegisteredClaims
 } 
 
@@ -67,9 +74,23 @@ func (m *authMiddleware) Handler() fiber.Handler {
       return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map {"error": "token expired" })
     }
 
+    // refresh tokens share the secret : only an access token opens a route
+    if tokenClaims.Type != "access" {
+      return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{ "error": "invalid or expired token", })
+    }
+
     // if possible
     c.Locals("user_id",tokenClaims.Sub)
     c.Locals("username",tokenClaims.Username)
+    c.Locals("roles",tokenClaims.Roles)
+    c.Locals("permissions",tokenClaims.Permissions)
+    c.Locals("session_id",tokenClaims.SessionID)
+
+    // tenant : repositories scope their queries on the request context (c.Context())
+    tenantID := tokenClaims.TenantID
+    if tenantID == "" { tenantID = pkg.DEFAULT_TENANT }
+    c.Locals("tenant_id",tenantID)
+    c.Context().SetUserValue(pkg.TenantContextKey, tenantID)
     return c.Next()
   }
 }
@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"ecommerce/internal/pkg"
+)
+
+// RequirePermission : route guard, runs after the auth middleware which puts the token permissions
+// ("resource:action") in Locals "permissions"
+func RequirePermission(resource, action string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		granted, ok := c.Locals("permissions").([]string)
+		if !ok {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "missing auth"})
+		}
+		if !HasPermission(granted, resource, action) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error":    "permission denied",
+				"required": resource + ":" + action,
+			})
+		}
+		return c.Next()
+	}
+}
+
+// RequireAccess : RequirePermission with the action from the method, read for GET / HEAD, write otherwise
+func RequireAccess(resource string) fiber.Handler {
+	read := RequirePermission(resource, "read")
+	write := RequirePermission(resource, "write")
+	return func(c *fiber.Ctx) error {
+		switch c.Method() {
+		case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions:
+			return read(c)
+		}
+		return write(c)
+	}
+}
+
+// RequirePlatformTenant : routes acting on every tenant at once (shopee client state, raw push
+// events), even a tenant's "*:*" doesn't reach them. Runs after the auth middleware.
+func RequirePlatformTenant() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		tenantID, _ := c.Locals("tenant_id").(string)
+		if tenantID != pkg.DEFAULT_TENANT {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "platform tenant only"})
+		}
+		return c.Next()
+	}
+}
+
+// HasPermission : "*" matches any resource / action, "shopee.*" any resource under shopee
+func HasPermission(granted []string, resource, action string) bool {
+	for _, key := range granted {
+		i := strings.LastIndex(key, ":")
+		if i < 0 {
+			continue
+		}
+		r, a := key[:i], key[i+1:]
+		if a != "*" && a != action {
+			continue
+		}
+		if r == "*" || r == resource || (strings.HasSuffix(r, ".*") && strings.HasPrefix(resource, r[:len(r)-1])) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"ecommerce/internal/pkg"
+)
+
+func TestHasPermission(t *testing.T) {
+	cases := []struct {
+		granted          []string
+		resource, action string
+		want             bool
+	}{
+		{[]string{"shopee.order:read"}, "shopee.order", "read", true},
+		{[]string{"shopee.order:read"}, "shopee.order", "write", false},
+		{[]string{"shopee.order:*"}, "shopee.order", "write", true},
+		{[]string{"shopee.*:read"}, "shopee.label", "read", true},
+		{[]string{"shopee.*:read"}, "product", "read", false},
+		{[]string{"*:*"}, "shopee.admin", "write", true},
+		{[]string{"bad"}, "product", "read", false},
+	}
+	for _, c := range cases {
+		if got := HasPermission(c.granted, c.resource, c.action); got != c.want {
+			t.Errorf("HasPermission(%v, %s, %s) = %v, want %v", c.granted, c.resource, c.action, got, c.want)
+		}
+	}
+}
+
+func TestRequirePlatformTenant(t *testing.T) {
+	for _, c := range []struct {
+		tenantID string
+		want     int
+	}{
+		{pkg.DEFAULT_TENANT, fiber.StatusOK},
+		{"6650f0c2a1b2c3d4e5f60718", fiber.StatusForbidden},
+		{"", fiber.StatusForbidden},
+	} {
+		app := fiber.New()
+		app.Use(func(c *fiber.Ctx) error {
+			if c.Query("tenant") != "" {
+				c.Locals("tenant_id", c.Query("tenant"))
+			}
+			// a tenant owner holds "*:*"
+			c.Locals("permissions", []string{"*:*"})
+			return c.Next()
+		})
+		app.Post("/admin", RequirePlatformTenant(), RequireAccess("shopee.admin"), func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) })
+
+		res, err := app.Test(httptest.NewRequest(fiber.MethodPost, "/admin?tenant="+c.tenantID, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != c.want {
+			t.Errorf("tenant %q: status %d, want %d", c.tenantID, res.StatusCode, c.want)
+		}
+	}
+}
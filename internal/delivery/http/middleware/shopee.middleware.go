@@ -58,3 +58,19 @@ func (m ShopeeMiddleware) Handler() fiber.Handler {
 		return c.Next()
 	}
 }
+
+// RequireShop : routes under /shop/:shopeeShopID only reach a shop of the caller's tenant, a shop of
+// another tenant answers like an unknown one. Runs after the auth middleware (tenant scoped context).
+func (m ShopeeMiddleware) RequireShop() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		shopID := c.Params("shopeeShopID")
+		if shopID == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "shopeeShopID is required"})
+		}
+		if _, err := m.shopeeAuthCollection.GetShopeeShopAuthByShopId(c.Context(), shopID); err != nil {
+			m.logger.Info("shopee.middleware.RequireShop:", zap.String("shop_id", shopID), zap.Error(err))
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "shop not found"})
+		}
+		return c.Next()
+	}
+}
@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.uber.org/zap"
+
+	"ecommerce/internal/application/shopee"
+	"ecommerce/internal/pkg"
+)
+
+const (
+	tenantA = "6650f0c2a1b2c3d4e5f60718"
+	tenantB = "6650f0c2a1b2c3d4e5f60719"
+)
+
+// fakeShopAuthRepo : shops by id, queried through pkg.TenantFilter like the mongo repository
+type fakeShopAuthRepo struct {
+	shopee.ShopeeAuthRepository
+	shops map[string]*shopee.ShopeeAuthModel
+}
+
+func (f *fakeShopAuthRepo) GetShopeeShopAuthByShopId(ctx context.Context, shopID string) (*shopee.ShopeeAuthModel, error) {
+	shop, ok := f.shops[shopID]
+	if !ok || !matchTenant(pkg.TenantFilter(ctx, bson.M{}), shop.TenantID) {
+		return nil, errors.New("mongo: no documents in result")
+	}
+	return shop, nil
+}
+
+func matchTenant(filter bson.M, tenantID string) bool {
+	want, ok := filter["tenant_id"]
+	if !ok {
+		return true
+	}
+	switch v := want.(type) {
+	case nil:
+		return tenantID == ""
+	case string:
+		return tenantID == v
+	default:
+		return false // {$in: []}
+	}
+}
+
+func newShopApp() *fiber.App {
+	repo := &fakeShopAuthRepo{shops: map[string]*shopee.ShopeeAuthModel{
+		"100": {ShopID: "100", TenantID: tenantA},
+		"200": {ShopID: "200"}, // platform tenant
+	}}
+	owned := NewShopeeMiddleware(zap.NewNop(), repo).RequireShop()
+	ok := func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) }
+
+	app := fiber.New()
+	// stands in for the auth middleware
+	app.Use(func(c *fiber.Ctx) error {
+		if tenant := c.Get("X-Tenant"); tenant != "" {
+			c.Context().SetUserValue(pkg.TenantContextKey, tenant)
+		}
+		return c.Next()
+	})
+	api := app.Group("/shopee")
+	api.Get("/shop/:shopeeShopID/orders", owned, ok)
+	api.Get("/shop/:shopeeShopID/orders/:orderSN", owned, ok)
+	items := api.Group("/shop/:shopeeShopID/items", owned)
+	items.Get("/", ok)
+	return app
+}
+
+func TestRequireShopTenantIsolation(t *testing.T) {
+	app := newShopApp()
+	for _, c := range []struct {
+		tenant, path string
+		want         int
+	}{
+		{tenantA, "/shopee/shop/100/orders", fiber.StatusOK},
+		{tenantA, "/shopee/shop/100/orders/2405ABC", fiber.StatusOK},
+		{tenantA, "/shopee/shop/100/items", fiber.StatusOK},
+		// another tenant's shop answers like an unknown one
+		{tenantB, "/shopee/shop/100/orders", fiber.StatusNotFound},
+		{tenantB, "/shopee/shop/100/orders/2405ABC", fiber.StatusNotFound},
+		{tenantB, "/shopee/shop/100/items", fiber.StatusNotFound},
+		{tenantA, "/shopee/shop/200/orders", fiber.StatusNotFound},
+		{pkg.DEFAULT_TENANT, "/shopee/shop/200/orders", fiber.StatusOK},
+		{pkg.DEFAULT_TENANT, "/shopee/shop/100/orders", fiber.StatusNotFound},
+		{tenantA, "/shopee/shop/999/orders", fiber.StatusNotFound},
+		// no tenant in the request context : fails closed
+		{"", "/shopee/shop/200/orders", fiber.StatusNotFound},
+	} {
+		req := httptest.NewRequest(fiber.MethodGet, c.path, nil)
+		req.Header.Set("X-Tenant", c.tenant)
+		res, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != c.want {
+			t.Errorf("tenant %q %s: status %d, want %d", c.tenant, c.path, res.StatusCode, c.want)
+		}
+	}
+}
+
+func TestUnscoped(t *testing.T) {
+	app := fiber.New()
+	app.Get("/auth/login", Unscoped(), func(c *fiber.Ctx) error {
+		if !pkg.Unscoped(c.Context()) {
+			return c.SendStatus(fiber.StatusForbidden)
+		}
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	res, err := app.Test(httptest.NewRequest(fiber.MethodGet, "/auth/login", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != fiber.StatusOK {
+		t.Errorf("status %d, want %d", res.StatusCode, fiber.StatusOK)
+	}
+}
@@ -0,0 +1,17 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"ecommerce/internal/pkg"
+)
+
+// Unscoped : public routes that run before any tenant is known (login, password reset, webhooks,
+// oauth callbacks) look their records up across tenants. Without it their context carries no
+// tenant and every tenant scoped query matches nothing.
+func Unscoped() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Context().SetUserValue(pkg.TenantContextKey, "")
+		return c.Next()
+	}
+}
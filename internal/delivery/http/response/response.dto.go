@@ -8,7 +8,15 @@ type APIResponse[T any] struct {
     TimestampUTC    string      `json:"timestamp_utc"`
     TimestampLocal  string      `json:"timestamp_local"`
     Data            T           `json:"data,omitempty"`
+    ErrorCode       string      `json:"error_code,omitempty"` // stable code, see ErrorDetail
     Error           any         `json:"error,omitempty"`
 }
 
+// ErrorDetail : "error" of a coded failure
+type ErrorDetail struct {
+    Code              string `json:"code"`
+    Message           string `json:"message"`
+    UpstreamRequestID string `json:"upstream_request_id,omitempty"`
+}
+
 
@@ -1,6 +1,7 @@
 package response
 
 import (
+	"errors"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -29,16 +30,64 @@ func SuccessResponse[T any](c *fiber.Ctx, message string, data T) error {
 	})
 }
 
+// ICodedError : error that knows its HTTP status and stable error code (adapter.ShopeeAPIError, ...)
+type ICodedError interface {
+	error
+	StatusCode() int
+	ErrorCode() string
+}
+
+// IUpstreamError : error carrying the request id of a third party (Shopee request_id)
+type IUpstreamError interface {
+	UpstreamRequestID() string
+}
+
+// ErrorResponse : errDetail may be an error ; a coded one (anywhere in the chain)
+// overrides statusCode and is answered as ErrorDetail with error_code set
 func ErrorResponse(c *fiber.Ctx, statusCode int, errMsg string, errDetail any) error {
 	timeNow := time.Now()
 	reqID := ConvertHeaderTraceID(c.Locals("request_id"))
+
+	var errCode string
+	if err, ok := errDetail.(error); ok {
+		var coded ICodedError
+		if errors.As(err, &coded) {
+			statusCode = coded.StatusCode()
+			errCode = coded.ErrorCode()
+			detail := ErrorDetail{Code: errCode, Message: err.Error()}
+			var upstream IUpstreamError
+			if errors.As(err, &upstream) {
+				detail.UpstreamRequestID = upstream.UpstreamRequestID()
+			}
+			errDetail = detail
+		} else {
+			errDetail = err.Error()
+		}
+	}
+
 	return c.Status(statusCode).JSON(APIResponse[any]{
 		Success:        false,
 		RequestID:      reqID,
 		Message:        errMsg,
+		ErrorCode:      errCode,
 		Error:          errDetail,
 		TimestampUnix:  timeNow.Unix(),
 		TimestampUTC:   timeNow.UTC().Format(time.RFC3339),
 		TimestampLocal: timeNow.Local().Format(time.RFC3339),
 	})
 }
+
+// AcceptedResponse : 202, work is still in progress (data carries its state)
+func AcceptedResponse[T any](c *fiber.Ctx, message string, data T) error {
+	timeNow := time.Now()
+	reqID := ConvertHeaderTraceID(c.Locals("request_id"))
+	return c.Status(fiber.StatusAccepted).JSON(APIResponse[T]{
+		Success:        true,
+		RequestID:      reqID,
+		Message:        message,
+		Data:           data,
+		TimestampUnix:  timeNow.Unix(),
+		TimestampUTC:   timeNow.UTC().Format(time.RFC3339),
+		TimestampLocal: timeNow.Local().Format(time.RFC3339),
+	})
+}
@@ -25,6 +25,21 @@ type JWTConfig struct {
   AuthJWTRefreshIN int64  `env:"AUTH_JWT_REFRESHES_IN"  envDefault:"1440"`
 }
 
+// 2fa (TOTP) : issuer shown by authenticator apps, challenge token lifetime (minutes) between password and code,
+// failed codes before the second step is locked for MFA_LOCK_MINUTES ; roles flagged require_mfa force enrollment
+type MFAConfig struct {
+  MFAIssuer      string `env:"MFA_ISSUER"       envDefault:"ecommerce"`
+  MFAChallengeIN int64  `env:"MFA_CHALLENGE_IN" envDefault:"5"`
+  MFAMaxAttempts int    `env:"MFA_MAX_ATTEMPTS" envDefault:"5"`
+  MFALockMinutes int64  `env:"MFA_LOCK_MINUTES" envDefault:"15"`
+}
+
+// rbac : route permissions are resolved at login / refresh and carried by the access token, grant changes
+// apply on the next refresh ; listed usernames always resolve to "*:*" (bootstrap before any role exists)
+type RBACConfig struct {
+  RBACAdminUsers []string `env:"RBAC_ADMIN_USERS" envSeparator:","`
+}
+
 type DBConfig struct {
   ConfigDBUrl      string `env:"CONFIG_DB_URL"`
   ConfigDBHost     string `env:"CONFIG_DB_HOST" envDefault:"localhost"`
@@ -97,11 +112,122 @@ type ShopeeConfig struct {
   ShopeeApiUrl           string `env:"SHOPEE_API_URL"`
   ShopeePartnerId        string `env:"SHOPEE_PARTNER_ID"`
   ShopeePartnerSecretKey string `env:"SHOPEE_PARTNER_SECRET_KEY"`
+
+  // background access-token refresh (minutes)
+  ShopeeTokenRefreshInterval int64 `env:"SHOPEE_TOKEN_REFRESH_INTERVAL" envDefault:"5"`
+  ShopeeTokenRefreshBefore   int64 `env:"SHOPEE_TOKEN_REFRESH_BEFORE"   envDefault:"30"`
+  ShopeeTokenRefreshMaxFail  int   `env:"SHOPEE_TOKEN_REFRESH_MAX_FAIL" envDefault:"5"`
+
+  // incremental order sync : interval (minutes), first-run lookback (days)
+  ShopeeOrderSyncInterval     int64 `env:"SHOPEE_ORDER_SYNC_INTERVAL"      envDefault:"10"`
+  ShopeeOrderSyncLookbackDays int64 `env:"SHOPEE_ORDER_SYNC_LOOKBACK_DAYS" envDefault:"15"`
+
+  // push receiver : callback url registered in Shopee console ({partner_id} is replaced),
+  // empty = rebuild from the incoming request (breaks behind a proxy rewriting scheme/host)
+  ShopeePushCallbackURL string `env:"SHOPEE_PUSH_CALLBACK_URL"`
+
+  // shipping label : inline polls of get_shipping_document_result (interval seconds)
+  ShopeeLabelPollAttempts int   `env:"SHOPEE_LABEL_POLL_ATTEMPTS" envDefault:"5"`
+  ShopeeLabelPollInterval int64 `env:"SHOPEE_LABEL_POLL_INTERVAL" envDefault:"2"`
+
+  // outbound client : timeout (seconds), token bucket per partner / per shop (req/s, burst ; rps 0 = off)
+  ShopeeHttpTimeout      int64   `env:"SHOPEE_HTTP_TIMEOUT"       envDefault:"10"`
+  ShopeeRatePartnerRPS   float64 `env:"SHOPEE_RATE_PARTNER_RPS"   envDefault:"10"`
+  ShopeeRatePartnerBurst int     `env:"SHOPEE_RATE_PARTNER_BURST" envDefault:"20"`
+  ShopeeRateShopRPS      float64 `env:"SHOPEE_RATE_SHOP_RPS"      envDefault:"5"`
+  ShopeeRateShopBurst    int     `env:"SHOPEE_RATE_SHOP_BURST"    envDefault:"10"`
+
+  // retry on retryable Shopee codes / 5xx : extra attempts, jittered backoff base / cap (milliseconds)
+  ShopeeRetryMax    int   `env:"SHOPEE_RETRY_MAX"     envDefault:"3"`
+  ShopeeRetryBaseMs int64 `env:"SHOPEE_RETRY_BASE_MS" envDefault:"200"`
+  ShopeeRetryMaxMs  int64 `env:"SHOPEE_RETRY_MAX_MS"  envDefault:"5000"`
+
+  // circuit breaker per endpoint : consecutive failures to open, cooldown (seconds) before a probe
+  ShopeeBreakerThreshold int   `env:"SHOPEE_BREAKER_THRESHOLD" envDefault:"5"`
+  ShopeeBreakerCooldown  int64 `env:"SHOPEE_BREAKER_COOLDOWN"  envDefault:"30"`
+}
+
+// Lazada Open Platform : LAZADA_API_BASE_URL empty = api host of the seller country (th, my, sg, vn, ph, id),
+// set = every call goes there (single country deployment, local fake)
+type LazadaConfig struct {
+  LazadaApiBaseUrl    string `env:"LAZADA_API_BASE_URL"`
+  LazadaAuthBaseUrl   string `env:"LAZADA_AUTH_BASE_URL"   envDefault:"https://auth.lazada.com/rest"`
+  LazadaAuthorizeUrl  string `env:"LAZADA_AUTHORIZE_URL"   envDefault:"https://auth.lazada.com/oauth/authorize"`
+  LazadaDefaultRegion string `env:"LAZADA_DEFAULT_REGION"  envDefault:"th"`
+  // redirect_uri registered in the Lazada console (consent page -> /webhook/marketplace/lazada/auth/callback)
+  LazadaCallbackURL   string `env:"LAZADA_CALLBACK_URL"`
+  LazadaHttpTimeout   int64  `env:"LAZADA_HTTP_TIMEOUT"    envDefault:"10"`
+
+  // incremental order sync : interval (minutes, 0 = off), first-run lookback (days)
+  LazadaOrderSyncInterval     int64 `env:"LAZADA_ORDER_SYNC_INTERVAL"      envDefault:"10"`
+  LazadaOrderSyncLookbackDays int64 `env:"LAZADA_ORDER_SYNC_LOOKBACK_DAYS" envDefault:"15"`
+}
+
+// stock : order driven movements (reservations, sales) land in the default warehouse
+type InventoryConfig struct {
+  InventoryDefaultWarehouse string `env:"INVENTORY_DEFAULT_WAREHOUSE" envDefault:"MAIN"`
+  // optimistic concurrency : attempts on a balance version conflict before giving up
+  InventoryMaxRetries       int    `env:"INVENTORY_MAX_RETRIES"       envDefault:"5"`
+}
+
+// marketplace stock push : a sku is pushed once it stayed unchanged for the debounce window (seconds,
+// 0 = worker off) or waited max wait ; dry run computes and logs quantities without calling the channel
+type StockSyncConfig struct {
+  StockSyncDebounce         int64 `env:"STOCK_SYNC_DEBOUNCE"           envDefault:"10"`
+  StockSyncMaxWait          int64 `env:"STOCK_SYNC_MAX_WAIT"           envDefault:"60"`
+  StockSyncDryRun           bool  `env:"STOCK_SYNC_DRY_RUN"            envDefault:"false"`
+  // failed batch (outage, dead token) : times a sku is queued again before giving up
+  StockSyncMaxAttempts      int   `env:"STOCK_SYNC_MAX_ATTEMPTS"       envDefault:"3"`
+  // push log TTL (days, 0 = keep forever)
+  StockSyncLogRetentionDays int64 `env:"STOCK_SYNC_LOG_RETENTION_DAYS" envDefault:"30"`
+}
+
+// tax documents : running numbers per tenant / document type / month of the issue date in the invoice
+// timezone ; Thai names and addresses need a TTF font with Thai glyphs (core PDF fonts are latin only)
+type InvoiceConfig struct {
+  InvoiceTimezone   string `env:"INVOICE_TIMEZONE"      envDefault:"Asia/Bangkok"`
+  InvoiceFontFile   string `env:"INVOICE_PDF_FONT_FILE"`
+  InvoiceFontDir    string `env:"INVOICE_PDF_FONT_DIR"  envDefault:"./data/fonts"`
+  // concurrent issuing : attempts on a number already taken before giving up
+  InvoiceMaxRetries int    `env:"INVOICE_MAX_RETRIES"   envDefault:"10"`
+}
+
+type BlobConfig struct {
+  BlobDriver   string `env:"BLOB_DRIVER"    envDefault:"local"`
+  BlobLocalDir string `env:"BLOB_LOCAL_DIR" envDefault:"./data/blob"`
+}
+
+// secrets at rest (partner keys, shop tokens) : AES-256 master keys as "kid:base64,kid:base64" ;
+// new values are sealed with the active kid, older kids stay listed until cmd/rotatekeys ran
+type CryptoConfig struct {
+  CryptoMasterKeys  string `env:"CRYPTO_MASTER_KEYS"`
+  CryptoActiveKeyID string `env:"CRYPTO_ACTIVE_KEY_ID"`
+}
+
+// outgoing mail : smtp, file (one .eml per message in MAIL_FILE_DIR) or console (logged) for offline setups
+type MailConfig struct {
+  MailDriver       string `env:"MAIL_DRIVER"        envDefault:"console"`
+  MailFrom         string `env:"MAIL_FROM"          envDefault:"no-reply@localhost"`
+  MailFileDir      string `env:"MAIL_FILE_DIR"      envDefault:"./data/mail"`
+  MailSMTPHost     string `env:"MAIL_SMTP_HOST"`
+  MailSMTPPort     int    `env:"MAIL_SMTP_PORT"     envDefault:"587"`
+  MailSMTPUsername string `env:"MAIL_SMTP_USERNAME"`
+  MailSMTPPassword string `env:"MAIL_SMTP_PASSWORD"`
+}
+
+// password reset / email verification : token lifetimes (minutes) ; the mailed link is the url + "?token=",
+// an empty url mails the bare token
+type AccountConfig struct {
+  AccountResetTokenIN  int64  `env:"ACCOUNT_RESET_TOKEN_IN"  envDefault:"30"`
+  AccountVerifyTokenIN int64  `env:"ACCOUNT_VERIFY_TOKEN_IN" envDefault:"1440"`
+  AccountResetURL      string `env:"ACCOUNT_RESET_URL"`
+  AccountVerifyURL     string `env:"ACCOUNT_VERIFY_URL"`
 }
 
 type Config struct {
   Server *ServerConfig
   JWT    *JWTConfig
+  RBAC   *RBACConfig
   DB     *DBConfig
   Store  *StoreConfig
   Redis  *RedisConfig
@@ -111,6 +237,15 @@ type Config struct {
   Sentry *SentryConfig
   Log    *LogConfig
   Shopee *ShopeeConfig
+  Lazada *LazadaConfig
+  Inventory *InventoryConfig
+  StockSync *StockSyncConfig
+  Invoice *InvoiceConfig
+  Blob   *BlobConfig
+  Crypto *CryptoConfig
+  Mail   *MailConfig
+  Account *AccountConfig
+  MFA    *MFAConfig
 }
 
 func LoadEnv(envSet string, logger *zap.Logger) (*Config,error) {
@@ -131,6 +266,11 @@ func LoadEnv(envSet string, logger *zap.Logger) (*Config,error) {
     return nil,fmt.Errorf("failed to parse env: %w", err)
   }
 
+  rbac := &RBACConfig{}
+  if err := env.Parse(rbac); err != nil {
+    return nil,fmt.Errorf("failed to parse env: %w", err)
+  }
+
   db := &DBConfig{}
   if err := env.Parse(db); err != nil {
     return nil,fmt.Errorf("failed to parse env: %w", err)
@@ -176,10 +316,56 @@ func LoadEnv(envSet string, logger *zap.Logger) (*Config,error) {
     return nil,fmt.Errorf("failed to parse env: %w", err)
   }
 
+  lazada := &LazadaConfig{}
+  if err := env.Parse(lazada); err != nil {
+    return nil,fmt.Errorf("failed to parse env: %w", err)
+  }
+
+  inventory := &InventoryConfig{}
+  if err := env.Parse(inventory); err != nil {
+    return nil,fmt.Errorf("failed to parse env: %w", err)
+  }
+
+  stockSync := &StockSyncConfig{}
+  if err := env.Parse(stockSync); err != nil {
+    return nil,fmt.Errorf("failed to parse env: %w", err)
+  }
+
+  invoice := &InvoiceConfig{}
+  if err := env.Parse(invoice); err != nil {
+    return nil,fmt.Errorf("failed to parse env: %w", err)
+  }
+
+  blob := &BlobConfig{}
+  if err := env.Parse(blob); err != nil {
+    return nil,fmt.Errorf("failed to parse env: %w", err)
+  }
+
+  crypto := &CryptoConfig{}
+  if err := env.Parse(crypto); err != nil {
+    return nil,fmt.Errorf("failed to parse env: %w", err)
+  }
+
+  mail := &MailConfig{}
+  if err := env.Parse(mail); err != nil {
+    return nil,fmt.Errorf("failed to parse env: %w", err)
+  }
+
+  account := &AccountConfig{}
+  if err := env.Parse(account); err != nil {
+    return nil,fmt.Errorf("failed to parse env: %w", err)
+  }
+
+  mfa := &MFAConfig{}
+  if err := env.Parse(mfa); err != nil {
+    return nil,fmt.Errorf("failed to parse env: %w", err)
+  }
+
   // logger.Sugar().Infow("Env loaded successfully", "env", envSet)
   return &Config{
     Server: server,
     JWT: jwt,
+    RBAC: rbac,
     DB: db,
     Store: store,
     Redis: redis,
@@ -189,5 +375,14 @@ func LoadEnv(envSet string, logger *zap.Logger) (*Config,error) {
     Sentry: sentry,
     Log: log,
     Shopee:shopee,
+    Lazada: lazada,
+    Inventory: inventory,
+    StockSync: stockSync,
+    Invoice: invoice,
+    Blob: blob,
+    Crypto: crypto,
+    Mail: mail,
+    Account: account,
+    MFA: mfa,
   }, nil 
 }
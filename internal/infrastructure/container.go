@@ -2,16 +2,34 @@ package infrastructure
 
 import (
 	"context"
+	"time"
 	"ecommerce/internal/adapter"
 	"ecommerce/internal/adapter/repository"
+	"ecommerce/internal/adapter/mail"
+	"ecommerce/internal/adapter/storage"
 	"ecommerce/internal/application/auth"
 	"ecommerce/internal/application/demo"
+	"ecommerce/internal/application/fulfillment"
 	"ecommerce/internal/application/health"
+	"ecommerce/internal/application/inventory"
+	"ecommerce/internal/application/invoice"
+	"ecommerce/internal/application/marketplace"
+	"ecommerce/internal/application/product"
+	"ecommerce/internal/application/purchase"
 	"ecommerce/internal/application/shopee"
+	"ecommerce/internal/application/shopee/item"
+	"ecommerce/internal/application/shopee/label"
+	"ecommerce/internal/application/shopee/logistics"
 	"ecommerce/internal/application/shopee/partner"
+	"ecommerce/internal/application/shopee/payment"
+	"ecommerce/internal/application/shopee/push"
+	"ecommerce/internal/application/shopee/returns"
+	"ecommerce/internal/application/stocksync"
+	"ecommerce/internal/application/tenant"
 	"ecommerce/internal/application/users"
 	"ecommerce/internal/delivery/http/handler"
 	"ecommerce/internal/delivery/http/middleware"
+	"ecommerce/internal/pkg"
 
 	"ecommerce/internal/application/swagger"
 	"ecommerce/internal/env"
@@ -36,6 +54,19 @@ type Repositories struct {
 
 type Adapter struct {
 	ShopeeAdapter adapter.IShopeeService
+  LazadaAdapter adapter.ILazadaService
+  // channel-neutral port : one IMarketplace per supported channel
+  Marketplace   *adapter.MarketplaceRegistry
+  BlobStore     storage.IBlobStore
+  Mail          mail.IMailSender
+}
+
+// background jobs : built in InitHandlers, started by StartWorkers
+type Workers struct {
+  ShopeeTokenRefresh shopee.IShopeeTokenRefreshWorker
+  ShopeeOrderSync    shopee.IShopeeOrderSyncWorker
+  MarketplaceOrderSync marketplace.IMarketplaceOrderSyncWorker
+  StockSync          stocksync.IStockSyncWorker
 }
 
 // Container holds all dependencies
@@ -44,10 +75,13 @@ type Container struct {
 	Logger      *zap.Logger
 	Valid       *validator.Validate
 	MongoClient *mongo.Client
+	// seals partner keys / shop tokens in the repositories : InitSecrets before anything else
+	Secret      pkg.ISecretCipher
 
 	Repository *Repositories
 	Middleware *MiddlewareHandle
 	Adapter    *Adapter
+	Workers    *Workers
 }
 
 func NewContainer(cfg *env.Config, mongo *mongo.Client, logger *zap.Logger, valid *validator.Validate) *Container {
@@ -59,29 +93,63 @@ func NewContainer(cfg *env.Config, mongo *mongo.Client, logger *zap.Logger, vali
 	}
 }
 
+func (c *Container) InitSecrets() {
+  secret, err := NewSecretCipher(c.Config, c.Logger)
+  if err != nil {
+    c.Logger.Fatal("Failed to init secret cipher", zap.Error(err))
+  }
+  c.Secret = secret
+}
+
 func (c *Container) InitRepositories() {
 	// c.MongoClient = mongoClient
 
+  // refresh-token sessions live with the other credentials, built before "auth" below shadows the package
+  sessionCollection := c.MongoClient.Database(c.Config.DB.ConfigDBAuthName).Collection("auth_sessions")
+  session := auth.NewSessionRepository(sessionCollection, c.Logger)
+  session.InitRepository()
+
+  accountTokenCollection := c.MongoClient.Database(c.Config.DB.ConfigDBAuthName).Collection("account_tokens")
+  accountToken := users.NewAccountTokenRepository(accountTokenCollection, c.Logger)
+  accountToken.InitRepository()
+
+  // totp secrets are sealed like the shop tokens
+  mfaCollection := c.MongoClient.Database(c.Config.DB.ConfigDBAuthName).Collection("user_mfa")
+  mfa := auth.NewMFARepository(mfaCollection, c.Logger, c.Secret)
+  mfa.InitRepository()
+
   // for DB name : auth
 	auth := c.MongoClient.Database(c.Config.DB.ConfigDBAuthName)
   db := c.MongoClient.Database(c.Config.DB.ConfigDBName)
 
 	shopeePartnerCollection := auth.Collection("shopee_partner")
-	shopeePartner := partner.NewShopeePartnerRepository(shopeePartnerCollection, c.Logger)
+	shopeePartner := partner.NewShopeePartnerRepository(shopeePartnerCollection, c.Logger, c.Secret)
 	shopeePartner.InitRepository()
 
 	shopeeAuthCollection := auth.Collection("shopee_shop_auth")
-	shopeeAuth := shopee.NewShopeeAuthRepository(shopeeAuthCollection, c.Logger)
+	shopeeAuth := shopee.NewShopeeAuthRepository(shopeeAuthCollection, c.Logger, c.Secret)
 	shopeeAuth.InitRepository()
 
 	shopeeAuthReqCollection := auth.Collection("shopee_auth_request")
-	shopeeAuthReq := shopee.NewShopeeAuthRequestRepository(shopeeAuthReqCollection, c.Logger)
+	shopeeAuthReq := shopee.NewShopeeAuthRequestRepository(shopeeAuthReqCollection, c.Logger, c.Secret)
 	shopeeAuthReq.InitRepository()
 
   userCollection := db.Collection("users")
   userReq := users.NewUserRepository(userCollection, c.Logger)
   userReq.InitRepository()
 
+  roleCollection := db.Collection("roles")
+  role := users.NewRoleRepository(roleCollection, c.Logger)
+  role.InitRepository()
+
+  permissionCollection := db.Collection("permissions")
+  permission := users.NewPermissionRepository(permissionCollection, c.Logger)
+  permission.InitRepository()
+
+  tenantCollection := db.Collection("tenants")
+  tenantRepository := tenant.NewTenantRepository(tenantCollection, c.Logger)
+  tenantRepository.InitRepository()
+
   shopeeShopCollection := db.Collection("shopee_shop")
   shopeeShop := shopee.NewShopeeShopDetailsRepository(shopeeShopCollection, c.Logger)
   shopeeShop.InitRepository()
@@ -90,8 +158,104 @@ func (c *Container) InitRepositories() {
   shopeeOrder := shopee.NewShopeeOrderRepository(ShopeeOrderCollection, c.Logger)
   shopeeOrder.InitRepository()
 
+  shopeeOrderSyncCollection := db.Collection("shopee_order_sync")
+  shopeeOrderSync := shopee.NewShopeeOrderSyncRepository(shopeeOrderSyncCollection, c.Logger)
+  shopeeOrderSync.InitRepository()
+
+  shopeePushEventCollection := db.Collection("shopee_push_event")
+  shopeePushEvent := push.NewShopeePushEventRepository(shopeePushEventCollection, c.Logger)
+  shopeePushEvent.InitRepository()
+
+  shopeeLabelCollection := db.Collection("shopee_label")
+  shopeeLabel := label.NewShopeeLabelRepository(shopeeLabelCollection, c.Logger)
+  shopeeLabel.InitRepository()
+
+  shopeeReturnCollection := db.Collection("shopee_return")
+  shopeeReturn := returns.NewShopeeReturnRepository(shopeeReturnCollection, c.Logger)
+  shopeeReturn.InitRepository()
+
+  marketplaceAppCollection := auth.Collection("marketplace_app")
+  marketplaceApp := marketplace.NewMarketplaceAppRepository(marketplaceAppCollection, c.Logger, c.Secret)
+  marketplaceApp.InitRepository()
+
+  marketplaceShopAuthCollection := auth.Collection("marketplace_shop_auth")
+  marketplaceShopAuth := marketplace.NewMarketplaceShopAuthRepository(marketplaceShopAuthCollection, c.Logger, c.Secret)
+  marketplaceShopAuth.InitRepository()
+
+  marketplaceOrderCollection := db.Collection("marketplace_order")
+  marketplaceOrder := marketplace.NewMarketplaceOrderRepository(marketplaceOrderCollection, c.Logger)
+  marketplaceOrder.InitRepository()
+
+  productCollection := db.Collection("product")
+  productRepo := product.NewProductRepository(productCollection, c.Logger)
+  productRepo.InitRepository()
+
+  skuMappingCollection := db.Collection("product_sku_mapping")
+  skuMapping := product.NewSKUMappingRepository(skuMappingCollection, c.Logger)
+  skuMapping.InitRepository()
+
+  warehouseCollection := db.Collection("warehouse")
+  warehouse := inventory.NewWarehouseRepository(warehouseCollection, c.Logger)
+  warehouse.InitRepository()
+  // sale / reserve from orders land in the default warehouse, it must exist : platform tenant here,
+  // other tenants get theirs on first use
+  if err := warehouse.EnsureWarehouse(pkg.WithTenant(context.TODO(), pkg.DEFAULT_TENANT), c.Config.Inventory.InventoryDefaultWarehouse, "Default warehouse"); err != nil {
+    c.Logger.Error("InitRepositories: ensure default warehouse", zap.Error(err))
+  }
+
+  inventoryBalanceCollection := db.Collection("inventory_balance")
+  inventoryBalance := inventory.NewBalanceRepository(inventoryBalanceCollection, c.Logger)
+  inventoryBalance.InitRepository()
+
+  inventoryMovementCollection := db.Collection("inventory_movement")
+  inventoryMovement := inventory.NewMovementRepository(inventoryMovementCollection, c.Logger)
+  inventoryMovement.InitRepository()
+
+  inventoryReservationCollection := db.Collection("inventory_reservation")
+  inventoryReservation := inventory.NewReservationRepository(inventoryReservationCollection, c.Logger)
+  inventoryReservation.InitRepository()
+
+  stockSyncRuleCollection := db.Collection("stock_sync_rule")
+  stockSyncRule := stocksync.NewStockSyncRuleRepository(stockSyncRuleCollection, c.Logger)
+  stockSyncRule.InitRepository()
+
+  stockPushLogCollection := db.Collection("stock_push_log")
+  stockPushLog := stocksync.NewStockPushLogRepository(stockPushLogCollection, c.Logger, time.Duration(c.Config.StockSync.StockSyncLogRetentionDays)*24*time.Hour)
+  stockPushLog.InitRepository()
+
+  supplierCollection := db.Collection("supplier")
+  supplier := purchase.NewSupplierRepository(supplierCollection, c.Logger)
+  supplier.InitRepository()
+
+  purchaseOrderCollection := db.Collection("purchase_order")
+  purchaseOrder := purchase.NewPurchaseOrderRepository(purchaseOrderCollection, c.Logger)
+  purchaseOrder.InitRepository()
+
+  goodsReceiptCollection := db.Collection("goods_receipt")
+  goodsReceipt := purchase.NewGoodsReceiptRepository(goodsReceiptCollection, c.Logger)
+  goodsReceipt.InitRepository()
+
+  purchaseSequenceCollection := db.Collection("purchase_sequence")
+  purchaseSequence := purchase.NewSequenceRepository(purchaseSequenceCollection, c.Logger)
+
+  waveCollection := db.Collection("fulfillment_wave")
+  wave := fulfillment.NewWaveRepository(waveCollection, c.Logger)
+  wave.InitRepository()
+
+  fulfillmentOrderCollection := db.Collection("fulfillment_order")
+  fulfillmentOrder := fulfillment.NewFulfillmentOrderRepository(fulfillmentOrderCollection, c.Logger)
+  fulfillmentOrder.InitRepository()
+
+  taxProfileCollection := db.Collection("tax_profile")
+  taxProfile := invoice.NewTaxProfileRepository(taxProfileCollection, c.Logger)
+  taxProfile.InitRepository()
+
+  taxDocumentCollection := db.Collection("tax_document")
+  taxDocument := invoice.NewTaxDocumentRepository(taxDocumentCollection, c.Logger)
+  taxDocument.InitRepository()
+
 	c.Repository = &Repositories{
-		MongoRepository: repository.NewMongoCollectionRepository(shopeeAuth, shopeeAuthReq, shopeePartner,userReq, shopeeShop, shopeeOrder),
+		MongoRepository: repository.NewMongoCollectionRepository(shopeeAuth, shopeeAuthReq, shopeePartner,userReq, shopeeShop, shopeeOrder, shopeeOrderSync, shopeePushEvent, shopeeLabel, shopeeReturn, marketplaceApp, marketplaceShopAuth, marketplaceOrder, productRepo, skuMapping, warehouse, inventoryBalance, inventoryMovement, inventoryReservation, stockSyncRule, stockPushLog, supplier, purchaseOrder, goodsReceipt, purchaseSequence, wave, fulfillmentOrder, taxProfile, taxDocument, role, permission, tenantRepository, session, accountToken, mfa),
 	}
   // next using in handle()
 }
@@ -108,7 +272,7 @@ func (c *Container) InitMiddleware() {
 
 	db := c.MongoClient.Database(c.Config.DB.ConfigDBName)
 	shopeeCollection := db.Collection("shopee_auth")
-	shopeeAuthCollection := shopee.NewShopeeAuthRepository(shopeeCollection, c.Logger)
+	shopeeAuthCollection := shopee.NewShopeeAuthRepository(shopeeCollection, c.Logger, c.Secret)
 
 	shopeeMiddleware := middleware.NewShopeeMiddleware(c.Logger, shopeeAuthCollection)
 
@@ -140,31 +304,129 @@ func (c *Container) InitHandlers(g fiber.Router) {
   userRepo := c.Repository.MongoRepository.UsersCollection()
   shopeeShopRepo := c.Repository.MongoRepository.ShopeeShopCollection()
   shopeeOrderRepo := c.Repository.MongoRepository.ShopeeOrderCollection()
+  shopeeOrderSyncRepo := c.Repository.MongoRepository.ShopeeOrderSyncCollection()
+  shopeePushEventRepo := c.Repository.MongoRepository.ShopeePushEventCollection()
+  shopeeLabelRepo := c.Repository.MongoRepository.ShopeeLabelCollection()
+  shopeeReturnRepo := c.Repository.MongoRepository.ShopeeReturnCollection()
+  marketplaceAppRepo := c.Repository.MongoRepository.MarketplaceAppCollection()
+  marketplaceShopAuthRepo := c.Repository.MongoRepository.MarketplaceShopAuthCollection()
+  marketplaceOrderRepo := c.Repository.MongoRepository.MarketplaceOrderCollection()
+  productRepo := c.Repository.MongoRepository.ProductCollection()
+  skuMappingRepo := c.Repository.MongoRepository.SKUMappingCollection()
+  warehouseRepo := c.Repository.MongoRepository.WarehouseCollection()
+  inventoryBalanceRepo := c.Repository.MongoRepository.InventoryBalanceCollection()
+  inventoryMovementRepo := c.Repository.MongoRepository.InventoryMovementCollection()
+  inventoryReservationRepo := c.Repository.MongoRepository.InventoryReservationCollection()
+  stockSyncRuleRepo := c.Repository.MongoRepository.StockSyncRuleCollection()
+  stockPushLogRepo := c.Repository.MongoRepository.StockPushLogCollection()
+  supplierRepo := c.Repository.MongoRepository.SupplierCollection()
+  purchaseOrderRepo := c.Repository.MongoRepository.PurchaseOrderCollection()
+  goodsReceiptRepo := c.Repository.MongoRepository.GoodsReceiptCollection()
+  purchaseSequenceRepo := c.Repository.MongoRepository.PurchaseSequenceCollection()
+  waveRepo := c.Repository.MongoRepository.WaveCollection()
+  fulfillmentOrderRepo := c.Repository.MongoRepository.FulfillmentOrderCollection()
+  taxProfileRepo := c.Repository.MongoRepository.TaxProfileCollection()
+  taxDocumentRepo := c.Repository.MongoRepository.TaxDocumentCollection()
+  roleRepo := c.Repository.MongoRepository.RoleCollection()
+  permissionRepo := c.Repository.MongoRepository.PermissionCollection()
+  tenantRepo := c.Repository.MongoRepository.TenantCollection()
+  sessionRepo := c.Repository.MongoRepository.SessionCollection()
+  accountTokenRepo := c.Repository.MongoRepository.AccountTokenCollection()
+  mfaRepo := c.Repository.MongoRepository.MFACollection()
   // waiting
   // shopeeShopRepo := c.Repository.MongoRepository.ShopeePartnerCollection()
   //usecase
   shopeePartnerUsecase := partner.NewShopeePartnerService(c.Config, c.Logger, shopeePartnerRepo)
-	shopeeUsecase := shopee.NewShopeeService(c.Config, c.Logger, c.Adapter.ShopeeAdapter, shopeeRepo, shopeeReqRepo, shopeePartnerRepo, shopeeShopRepo, shopeeOrderRepo)
-  usersUsecase := users.NewUserService(c.Config,c.Logger,userRepo)
-  authUsecase := auth.NewAuthService(c.Config,c.Logger,userRepo)
+	shopeeUsecase := shopee.NewShopeeService(c.Config, c.Logger, c.Adapter.ShopeeAdapter, shopeeRepo, shopeeReqRepo, shopeePartnerRepo, shopeeShopRepo, shopeeOrderRepo, shopeeOrderSyncRepo)
+  shopeePushUsecase := push.NewShopeePushService(c.Config, c.Logger, shopeePushEventRepo, shopeePartnerRepo, shopeeRepo, shopeeOrderRepo, shopeeUsecase)
+  shopeeItemUsecase := item.NewShopeeItemService(c.Config, c.Logger, c.Adapter.ShopeeAdapter, shopeeUsecase)
+  shopeeLogisticsUsecase := logistics.NewShopeeLogisticsService(c.Config, c.Logger, c.Adapter.ShopeeAdapter, shopeeUsecase, shopeeOrderRepo)
+  shopeeLabelUsecase := label.NewShopeeLabelService(c.Config, c.Logger, c.Adapter.ShopeeAdapter, shopeeUsecase, shopeeOrderRepo, shopeeLabelRepo, c.Adapter.BlobStore)
+  shopeePaymentUsecase := payment.NewShopeePaymentService(c.Config, c.Logger, c.Adapter.ShopeeAdapter, shopeeUsecase, shopeeOrderRepo)
+  shopeeReturnUsecase := returns.NewShopeeReturnService(c.Config, c.Logger, c.Adapter.ShopeeAdapter, shopeeUsecase, shopeeOrderRepo, shopeeReturnRepo, c.Adapter.BlobStore)
+  marketplaceUsecase := marketplace.NewMarketplaceService(c.Config, c.Logger, c.Adapter.Marketplace, shopeeUsecase, marketplaceAppRepo, marketplaceShopAuthRepo, marketplaceOrderRepo)
+  // stored Shopee orders are mirrored as marketplace orders, which feed inventory / fulfillment / invoicing
+  shopeeUsecase.AddShopeeOrderListener(marketplaceUsecase)
+  productUsecase := product.NewProductService(c.Config, c.Logger, productRepo, skuMappingRepo, shopeeOrderRepo)
+  inventoryUsecase := inventory.NewInventoryService(c.Config, c.Logger, productUsecase, warehouseRepo, inventoryBalanceRepo, inventoryMovementRepo, inventoryReservationRepo)
+  marketplaceUsecase.AddMarketplaceOrderListener(inventory.NewMarketplaceOrderListener(c.Logger, inventoryUsecase, productUsecase))
+  stockSyncUsecase := stocksync.NewStockSyncService(c.Config, c.Logger, inventoryUsecase, productUsecase, marketplaceUsecase, stockSyncRuleRepo, stockPushLogRepo)
+  inventoryUsecase.AddStockListener(stockSyncUsecase)
+  fulfillmentUsecase := fulfillment.NewFulfillmentService(c.Config, c.Logger, inventoryUsecase, productUsecase, shopeeLogisticsUsecase, marketplaceUsecase, marketplaceOrderRepo, waveRepo, fulfillmentOrderRepo)
+  marketplaceUsecase.AddMarketplaceOrderListener(fulfillmentUsecase)
+  purchaseUsecase := purchase.NewPurchaseService(c.Config, c.Logger, inventoryUsecase, productUsecase, supplierRepo, purchaseOrderRepo, goodsReceiptRepo, purchaseSequenceRepo)
+  invoiceUsecase := invoice.NewInvoiceService(c.Config, c.Logger, c.Adapter.BlobStore, marketplaceUsecase, shopeeReturnRepo, taxProfileRepo, taxDocumentRepo)
+  accessUsecase := users.NewAccessService(c.Config, c.Logger, userRepo, roleRepo, permissionRepo)
+  accountUsecase := users.NewAccountService(c.Config, c.Logger, userRepo, accountTokenRepo, c.Adapter.Mail)
+  usersUsecase := users.NewUserService(c.Config,c.Logger,userRepo, accessUsecase, accountUsecase)
+  tenantUsecase := tenant.NewTenantService(c.Config, c.Logger, usersUsecase, accessUsecase, tenantRepo)
+  authUsecase := auth.NewAuthService(c.Config,c.Logger,userRepo, sessionRepo, mfaRepo, accessUsecase, accountUsecase, tenantUsecase)
+
+  // worker
+  c.Workers = &Workers{
+    ShopeeTokenRefresh: shopee.NewShopeeTokenRefreshWorker(c.Config, c.Logger, shopeeUsecase, shopeeRepo),
+    ShopeeOrderSync:    shopee.NewShopeeOrderSyncWorker(c.Config, c.Logger, shopeeUsecase, shopeeRepo),
+    MarketplaceOrderSync: marketplace.NewMarketplaceOrderSyncWorker(c.Config, c.Logger, marketplaceUsecase, marketplaceShopAuthRepo),
+    StockSync:          stocksync.NewStockSyncWorker(c.Config, c.Logger, stockSyncUsecase),
+  }
 
   // shopeeShop := shopee.NewShopeeShopDetailsService () 
   // handler
 	shopee := shopee.NewShopeeHandler(shopeeUsecase, shopeePartnerUsecase,c.Logger, c.Valid)
   shopeePartner := partner.NewShopeePartnerHandler(c.Logger, c.Valid,shopeePartnerUsecase)
+  shopeePush := push.NewShopeePushHandler(c.Logger, c.Valid, shopeePushUsecase)
+  shopeeItem := item.NewShopeeItemHandler(c.Logger, c.Valid, shopeeItemUsecase)
+  shopeeLogistics := logistics.NewShopeeLogisticsHandler(c.Logger, c.Valid, shopeeLogisticsUsecase)
+  shopeeLabel := label.NewShopeeLabelHandler(c.Logger, c.Valid, shopeeLabelUsecase)
+  shopeePayment := payment.NewShopeePaymentHandler(c.Logger, c.Valid, shopeePaymentUsecase)
+  shopeeReturn := returns.NewShopeeReturnHandler(c.Logger, c.Valid, shopeeReturnUsecase)
+  marketplace := marketplace.NewMarketplaceHandler(c.Logger, c.Valid, marketplaceUsecase)
+  product := product.NewProductHandler(c.Logger, c.Valid, productUsecase)
+  inventory := inventory.NewInventoryHandler(c.Logger, c.Valid, inventoryUsecase)
+  stockSync := stocksync.NewStockSyncHandler(c.Logger, c.Valid, stockSyncUsecase)
+  purchase := purchase.NewPurchaseHandler(c.Logger, c.Valid, purchaseUsecase)
+  fulfillment := fulfillment.NewFulfillmentHandler(c.Logger, c.Valid, fulfillmentUsecase)
+  invoice := invoice.NewInvoiceHandler(c.Logger, c.Valid, invoiceUsecase)
+  tenant := tenant.NewTenantHandler(c.Logger, c.Valid, tenantUsecase)
+  access := users.NewAccessHandler(c.Logger, c.Valid, accessUsecase)
   users := users.NewUserHandler(usersUsecase,c.Logger, c.Valid)
   auth := auth.NewAuthHandle(c.Config,authUsecase, c.Logger, c.Valid)
 
 	h := handler.NewRouterHandler(
     c.Middleware.Auth.Handler(),
     c.Middleware.Shopee.Handler(),
-    health, swagger, demo, shopee, shopeePartner,auth,users)
+    c.Middleware.Shopee.RequireShop(),
+    health, swagger, demo, shopee, shopeePartner, shopeePush, shopeeItem, shopeeLogistics, shopeeLabel, shopeePayment, shopeeReturn, marketplace, product, inventory, stockSync, purchase, fulfillment, invoice, auth, users, access, tenant)
 	h.RegisterHandlers(g)
 }
 
+// StartWorkers : call after InitHandlers, workers stop when ctx is canceled
+func (c *Container) StartWorkers(ctx context.Context) {
+  if c.Workers == nil {
+    return
+  }
+  c.Workers.ShopeeTokenRefresh.Start(ctx)
+  c.Workers.ShopeeOrderSync.Start(ctx)
+  c.Workers.MarketplaceOrderSync.Start(ctx)
+  c.Workers.StockSync.Start(ctx)
+}
+
 func (c *Container) InitAdapter() {
-	shopeeAdapter := adapter.NewShopeeAPI(c.Config,c.Config.Shopee.ShopeeApiBaseUrl, c.Config.Shopee.ShopeeApiBasePrefix, c.Logger)
-	c.Adapter = &Adapter{ShopeeAdapter: shopeeAdapter}
+	shopeeAdapter := adapter.NewShopeeAPI(c.Config,c.Config.Shopee.ShopeeApiBaseUrl, c.Logger)
+  lazadaAdapter := adapter.NewLazadaAPI(c.Config, c.Logger)
+  marketplaceRegistry := adapter.NewMarketplaceRegistry(
+    adapter.NewShopeeMarketplace(c.Config, shopeeAdapter),
+    adapter.NewLazadaMarketplace(c.Config, lazadaAdapter),
+  )
+  blobStore, err := storage.NewBlobStore(c.Config, c.Logger)
+  if err != nil {
+    c.Logger.Fatal("Failed to init blob store", zap.Error(err))
+  }
+  mailSender, err := mail.NewMailSender(c.Config, c.Logger)
+  if err != nil {
+    c.Logger.Fatal("Failed to init mail sender", zap.Error(err))
+  }
+	c.Adapter = &Adapter{ShopeeAdapter: shopeeAdapter, LazadaAdapter: lazadaAdapter, Marketplace: marketplaceRegistry, BlobStore: blobStore, Mail: mailSender}
 }
 
 // Close cleans up resources
@@ -0,0 +1,34 @@
+package infrastructure
+
+import (
+	"errors"
+	"fmt"
+
+	"ecommerce/internal/env"
+	"ecommerce/internal/pkg"
+
+	"go.uber.org/zap"
+)
+
+// NewSecretCipher : keyring from CRYPTO_MASTER_KEYS ; without keys secrets stay in plaintext,
+// which is only accepted for APP_ENV=dev
+func NewSecretCipher(cfg *env.Config, logger *zap.Logger) (pkg.ISecretCipher, error) {
+	if cfg.Crypto == nil || cfg.Crypto.CryptoMasterKeys == "" {
+		if cfg.Server != nil && cfg.Server.AppEnv != "dev" {
+			return nil, errors.New("CRYPTO_MASTER_KEYS is required outside dev")
+		}
+		logger.Warn("CRYPTO_MASTER_KEYS is empty : partner keys and shop tokens are stored in plaintext")
+		return pkg.NewPlainSecretCipher(), nil
+	}
+
+	keys, err := pkg.ParseSecretKeyring(cfg.Crypto.CryptoMasterKeys)
+	if err != nil {
+		return nil, fmt.Errorf("CRYPTO_MASTER_KEYS : %w", err)
+	}
+	cipher, err := pkg.NewSecretCipher(keys, cfg.Crypto.CryptoActiveKeyID)
+	if err != nil {
+		return nil, fmt.Errorf("CRYPTO_ACTIVE_KEY_ID : %w", err)
+	}
+	logger.Info("secret cipher ready", zap.String("active_kid", cipher.ActiveKeyID()), zap.Int("keys", len(keys)))
+	return cipher, nil
+}
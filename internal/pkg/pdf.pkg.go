@@ -0,0 +1,90 @@
+package pkg
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/font"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+)
+
+var fontLock sync.Mutex
+
+func init() {
+	// pdfcpu otherwise creates ~/.config/pdfcpu on first use
+	api.DisableConfigDir()
+}
+
+// MergePDF : concatenate documents in order
+func MergePDF(files [][]byte) ([]byte, error) {
+	if len(files) == 0 {
+		return nil, errors.New("pkg.MergePDF : no document")
+	}
+	if len(files) == 1 {
+		return files[0], nil
+	}
+
+	rs := make([]io.ReadSeeker, len(files))
+	for i := range files {
+		rs[i] = bytes.NewReader(files[i])
+	}
+
+	var out bytes.Buffer
+	if err := api.MergeRaw(rs, &out, false, model.NewDefaultConfiguration()); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// CreatePDF : render a pdfcpu JSON layout (paper, fonts, header / footer, pages of text and tables)
+func CreatePDF(layout []byte) ([]byte, error) {
+	var out bytes.Buffer
+	if err := api.Create(nil, bytes.NewReader(layout), &out, model.NewDefaultConfiguration()); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// InstallPDFFont : make a TrueType font usable by CreatePDF, returns the name to put in layouts ;
+// fonts are kept in dir and loaded for the whole process
+func InstallPDFFont(dir string, ttf string) (string, error) {
+	if !strings.EqualFold(filepath.Ext(ttf), ".ttf") {
+		return "", errors.New("pkg.InstallPDFFont : .ttf file expected")
+	}
+
+	fontLock.Lock()
+	defer fontLock.Unlock()
+
+	// pdfcpu names the font after its PostScript name : install into a scratch dir first to learn it
+	tmp, err := os.MkdirTemp("", "pdffont")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+	if err := font.InstallTrueTypeFont(tmp, ttf); err != nil {
+		return "", err
+	}
+	files, err := filepath.Glob(filepath.Join(tmp, "*.gob"))
+	if err != nil || len(files) != 1 {
+		return "", errors.New("pkg.InstallPDFFont : font not installed")
+	}
+	name := strings.TrimSuffix(filepath.Base(files[0]), ".gob")
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	if err := font.InstallTrueTypeFont(dir, ttf); err != nil {
+		return "", err
+	}
+	font.UserFontDir = dir
+	if err := font.LoadUserFonts(); err != nil {
+		return "", err
+	}
+	return name, nil
+}
@@ -0,0 +1,261 @@
+package pkg
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Envelope encryption for secrets stored in Mongo (partner keys, shop tokens).
+//
+// Every value gets its own random data key (DEK) ; the value is sealed with the DEK (AES-256-GCM)
+// and the DEK is sealed with a master key (AES-256-GCM) identified by a kid.
+// Stored form : "enc:v1:<kid>:<base64 sealed dek>:<base64 sealed value>"
+// Anything without the "enc:v1:" prefix is a legacy plaintext value and is read as is,
+// cmd/rotatekeys encrypts those in place.
+
+const secretEnvelopePrefix = "enc:v1:"
+
+var (
+	ErrSecretUnknownKey  = errors.New("secret : master key id is not configured")
+	ErrSecretMalformed   = errors.New("secret : malformed envelope")
+	ErrSecretNoMasterKey = errors.New("secret : encrypted value but no master key configured")
+)
+
+type ISecretCipher interface {
+	Encrypt(plain string) (string, error)
+	Decrypt(stored string) (string, error)
+	// NeedsRotation : plaintext, or sealed with a kid other than the active one
+	NeedsRotation(stored string) bool
+	ActiveKeyID() string
+	Enabled() bool
+}
+
+type secretCipher struct {
+	active string
+	keys   map[string]cipher.AEAD
+}
+
+// ParseSecretKeyring : "kid:base64,kid:base64" -> kid -> 32 bytes key
+func ParseSecretKeyring(spec string) (map[string][]byte, error) {
+	keys := map[string][]byte{}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		kid, b64, ok := strings.Cut(part, ":")
+		kid = strings.TrimSpace(kid)
+		if !ok || kid == "" || strings.Contains(kid, ":") {
+			return nil, fmt.Errorf("secret : invalid keyring entry %q, want kid:base64", kid)
+		}
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(b64))
+		if err != nil {
+			return nil, fmt.Errorf("secret : key %s is not base64 : %w", kid, err)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("secret : key %s must be 32 bytes, got %d", kid, len(key))
+		}
+		if _, dup := keys[kid]; dup {
+			return nil, fmt.Errorf("secret : key %s is listed twice", kid)
+		}
+		keys[kid] = key
+	}
+	return keys, nil
+}
+
+// NewSecretCipher : active "" is only allowed with a single key
+func NewSecretCipher(keys map[string][]byte, active string) (ISecretCipher, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("secret : no master key")
+	}
+	if active == "" {
+		if len(keys) > 1 {
+			return nil, errors.New("secret : active key id is required with several master keys")
+		}
+		for kid := range keys {
+			active = kid
+		}
+	}
+	if _, ok := keys[active]; !ok {
+		return nil, fmt.Errorf("secret : active key %s is not in the keyring", active)
+	}
+
+	c := &secretCipher{active: active, keys: map[string]cipher.AEAD{}}
+	for kid, key := range keys {
+		aead, err := newAESGCM(key)
+		if err != nil {
+			return nil, fmt.Errorf("secret : key %s : %w", kid, err)
+		}
+		c.keys[kid] = aead
+	}
+	return c, nil
+}
+
+// NewPlainSecretCipher : no master key (local dev only), values are stored as given
+func NewPlainSecretCipher() ISecretCipher { return plainSecretCipher{} }
+
+func (c *secretCipher) ActiveKeyID() string { return c.active }
+func (c *secretCipher) Enabled() bool       { return true }
+
+// Encrypt : "" stays "" so "field is empty" filters keep working
+func (c *secretCipher) Encrypt(plain string) (string, error) {
+	if plain == "" {
+		return "", nil
+	}
+	dek := make([]byte, 32)
+	if _, err := rand.Read(dek); err != nil {
+		return "", err
+	}
+	data, err := newAESGCM(dek)
+	if err != nil {
+		return "", err
+	}
+	sealedValue, err := sealSecret(data, []byte(plain), nil)
+	if err != nil {
+		return "", err
+	}
+	// kid as AAD : a wrapped DEK can't be replayed under another kid
+	sealedDEK, err := sealSecret(c.keys[c.active], dek, []byte(c.active))
+	if err != nil {
+		return "", err
+	}
+	return secretEnvelopePrefix + c.active + ":" +
+		base64.RawStdEncoding.EncodeToString(sealedDEK) + ":" +
+		base64.RawStdEncoding.EncodeToString(sealedValue), nil
+}
+
+func (c *secretCipher) Decrypt(stored string) (string, error) {
+	kid, sealedDEK, sealedValue, ok, err := parseSecretEnvelope(stored)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return stored, nil
+	}
+	master, found := c.keys[kid]
+	if !found {
+		return "", fmt.Errorf("%w : %s", ErrSecretUnknownKey, kid)
+	}
+	dek, err := openSecret(master, sealedDEK, []byte(kid))
+	if err != nil {
+		return "", fmt.Errorf("secret : unwrap data key (kid %s) : %w", kid, err)
+	}
+	data, err := newAESGCM(dek)
+	if err != nil {
+		return "", err
+	}
+	plain, err := openSecret(data, sealedValue, nil)
+	if err != nil {
+		return "", fmt.Errorf("secret : decrypt value (kid %s) : %w", kid, err)
+	}
+	return string(plain), nil
+}
+
+func (c *secretCipher) NeedsRotation(stored string) bool {
+	if stored == "" {
+		return false
+	}
+	kid, ok := SecretKeyID(stored)
+	return !ok || kid != c.active
+}
+
+type plainSecretCipher struct{}
+
+func (plainSecretCipher) ActiveKeyID() string                  { return "" }
+func (plainSecretCipher) Enabled() bool                        { return false }
+func (plainSecretCipher) Encrypt(plain string) (string, error) { return plain, nil }
+func (plainSecretCipher) NeedsRotation(stored string) bool     { return false }
+func (plainSecretCipher) Decrypt(stored string) (string, error) {
+	if IsEncryptedSecret(stored) {
+		return "", ErrSecretNoMasterKey
+	}
+	return stored, nil
+}
+
+func IsEncryptedSecret(stored string) bool { return strings.HasPrefix(stored, secretEnvelopePrefix) }
+
+// SecretKeyID : kid of an envelope, false for plaintext
+func SecretKeyID(stored string) (string, bool) {
+	if !IsEncryptedSecret(stored) {
+		return "", false
+	}
+	kid, _, _ := strings.Cut(strings.TrimPrefix(stored, secretEnvelopePrefix), ":")
+	return kid, true
+}
+
+// RedactSecret : safe form for API responses and logs, keeps the last 4 chars of long values
+func RedactSecret(s string) string {
+	switch {
+	case s == "":
+		return ""
+	case len(s) < 16:
+		return "****"
+	default:
+		return "****" + s[len(s)-4:]
+	}
+}
+
+func parseSecretEnvelope(stored string) (kid string, sealedDEK []byte, sealedValue []byte, ok bool, err error) {
+	if !IsEncryptedSecret(stored) {
+		return "", nil, nil, false, nil
+	}
+	parts := strings.Split(strings.TrimPrefix(stored, secretEnvelopePrefix), ":")
+	if len(parts) != 3 || parts[0] == "" {
+		return "", nil, nil, false, ErrSecretMalformed
+	}
+	if sealedDEK, err = base64.RawStdEncoding.DecodeString(parts[1]); err != nil {
+		return "", nil, nil, false, ErrSecretMalformed
+	}
+	if sealedValue, err = base64.RawStdEncoding.DecodeString(parts[2]); err != nil {
+		return "", nil, nil, false, ErrSecretMalformed
+	}
+	return parts[0], sealedDEK, sealedValue, true, nil
+}
+
+func newAESGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealSecret : nonce || ciphertext
+func sealSecret(aead cipher.AEAD, plain []byte, aad []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plain, aad), nil
+}
+
+func openSecret(aead cipher.AEAD, sealed []byte, aad []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrSecretMalformed
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, aad)
+}
+
+// ReencryptSecret : open with whatever kid sealed it (or plaintext), seal with the active kid
+func ReencryptSecret(c ISecretCipher, stored string) (string, error) {
+	plain, err := c.Decrypt(stored)
+	if err != nil {
+		return "", err
+	}
+	return c.Encrypt(plain)
+}
+
+// SecretRotationResult : one collection pass of cmd/rotatekeys
+type SecretRotationResult struct {
+	Collection string `json:"collection"`
+	Scanned    int    `json:"scanned"`
+	Rotated    int    `json:"rotated"`
+	Skipped    int    `json:"skipped"` // changed by someone else during the pass
+	Failed     int    `json:"failed"`  // could not be opened : unknown kid / corrupted
+}
@@ -0,0 +1,158 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func testSecretKey(b byte) []byte { return bytes.Repeat([]byte{b}, 32) }
+
+func testSecretCipher(t *testing.T, keys map[string][]byte, active string) ISecretCipher {
+	t.Helper()
+	c, err := NewSecretCipher(keys, active)
+	if err != nil {
+		t.Fatalf("NewSecretCipher: %v", err)
+	}
+	return c
+}
+
+// tamperSecret : flips one byte of the sealed dek (part 1) or the sealed value (part 2)
+func tamperSecret(t *testing.T, stored string, part int) string {
+	t.Helper()
+	parts := strings.Split(strings.TrimPrefix(stored, secretEnvelopePrefix), ":")
+	raw, err := base64.RawStdEncoding.DecodeString(parts[part])
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw[len(raw)-1] ^= 0x01
+	parts[part] = base64.RawStdEncoding.EncodeToString(raw)
+	return secretEnvelopePrefix + strings.Join(parts, ":")
+}
+
+func TestSecretCipherRoundTrip(t *testing.T) {
+	c := testSecretCipher(t, map[string][]byte{"k1": testSecretKey(1)}, "")
+	for _, plain := range []string{"shpk-access-token", "ไทย secret", strings.Repeat("x", 4096)} {
+		stored, err := c.Encrypt(plain)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if kid, ok := SecretKeyID(stored); !ok || kid != "k1" || strings.Contains(stored, plain) {
+			t.Fatalf("stored %q : kid %q, want a k1 envelope without the plaintext", stored, kid)
+		}
+		got, err := c.Decrypt(stored)
+		if err != nil || got != plain {
+			t.Errorf("Decrypt = %q, %v, want %q", got, err, plain)
+		}
+		// a fresh data key and nonce each time
+		if again, _ := c.Encrypt(plain); again == stored {
+			t.Error("same envelope twice for the same value")
+		}
+	}
+
+	if stored, err := c.Encrypt(""); err != nil || stored != "" {
+		t.Errorf(`Encrypt("") = %q, %v, want ""`, stored, err)
+	}
+	if got, err := c.Decrypt("legacy-plain"); err != nil || got != "legacy-plain" {
+		t.Errorf("legacy plaintext = %q, %v", got, err)
+	}
+}
+
+func TestSecretCipherWrongKey(t *testing.T) {
+	stored, err := testSecretCipher(t, map[string][]byte{"k1": testSecretKey(1)}, "").Encrypt("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// same kid, another key : the data key does not unwrap
+	if _, err := testSecretCipher(t, map[string][]byte{"k1": testSecretKey(2)}, "").Decrypt(stored); err == nil {
+		t.Error("opened with the wrong master key")
+	}
+	// kid not in the keyring
+	if _, err := testSecretCipher(t, map[string][]byte{"k2": testSecretKey(1)}, "").Decrypt(stored); !errors.Is(err, ErrSecretUnknownKey) {
+		t.Errorf("unknown kid: err = %v, want ErrSecretUnknownKey", err)
+	}
+	// same key under another kid : the kid is the AAD of the wrapped data key
+	relabeled := secretEnvelopePrefix + "k2" + strings.TrimPrefix(stored, secretEnvelopePrefix+"k1")
+	if _, err := testSecretCipher(t, map[string][]byte{"k2": testSecretKey(1)}, "").Decrypt(relabeled); err == nil {
+		t.Error("opened a data key replayed under another kid")
+	}
+	if _, err := NewPlainSecretCipher().Decrypt(stored); !errors.Is(err, ErrSecretNoMasterKey) {
+		t.Errorf("plain cipher: err = %v, want ErrSecretNoMasterKey", err)
+	}
+}
+
+func TestSecretCipherTampered(t *testing.T) {
+	c := testSecretCipher(t, map[string][]byte{"k1": testSecretKey(1)}, "")
+	stored, err := c.Encrypt("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name   string
+		stored string
+	}{
+		{"data key", tamperSecret(t, stored, 1)},
+		{"ciphertext", tamperSecret(t, stored, 2)},
+		{"truncated", stored[:len(stored)-4]},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got, err := c.Decrypt(tc.stored); err == nil {
+				t.Fatalf("Decrypt = %q, want a GCM authentication error", got)
+			}
+		})
+	}
+
+	for _, malformed := range []string{"enc:v1:", "enc:v1:k1:abc", "enc:v1::AAAA:AAAA", "enc:v1:k1:!!!:AAAA"} {
+		if _, err := c.Decrypt(malformed); !errors.Is(err, ErrSecretMalformed) {
+			t.Errorf("%q: err = %v, want ErrSecretMalformed", malformed, err)
+		}
+	}
+}
+
+func TestReencryptSecret(t *testing.T) {
+	old := testSecretCipher(t, map[string][]byte{"k1": testSecretKey(1)}, "")
+	sealedOld, err := old.Encrypt("refresh-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// step 1 of cmd/rotatekeys : both keys, the new one active
+	c := testSecretCipher(t, map[string][]byte{"k1": testSecretKey(1), "k2": testSecretKey(2)}, "k2")
+
+	cases := []struct {
+		name   string
+		stored string
+	}{
+		{"from plaintext", "refresh-token"},
+		{"from the old kid", sealedOld},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if !c.NeedsRotation(tc.stored) {
+				t.Fatal("NeedsRotation = false")
+			}
+			rotated, err := ReencryptSecret(c, tc.stored)
+			if err != nil {
+				t.Fatalf("ReencryptSecret: %v", err)
+			}
+			if kid, _ := SecretKeyID(rotated); kid != "k2" || c.NeedsRotation(rotated) {
+				t.Errorf("rotated under %q, want k2", kid)
+			}
+			// step 4 : the old key dropped, the rotated value still opens
+			newOnly := testSecretCipher(t, map[string][]byte{"k2": testSecretKey(2)}, "")
+			if got, err := newOnly.Decrypt(rotated); err != nil || got != "refresh-token" {
+				t.Errorf("Decrypt with k2 only = %q, %v", got, err)
+			}
+		})
+	}
+
+	if c.NeedsRotation("") {
+		t.Error(`NeedsRotation("") = true`)
+	}
+	if _, err := ReencryptSecret(testSecretCipher(t, map[string][]byte{"k2": testSecretKey(2)}, ""), sealedOld); !errors.Is(err, ErrSecretUnknownKey) {
+		t.Errorf("old key already dropped: err = %v, want ErrSecretUnknownKey", err)
+	}
+}
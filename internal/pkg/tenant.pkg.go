@@ -0,0 +1,93 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Tenant scoping for repositories.
+//
+// The auth middleware puts the tenant of the access token in the request context ; repositories
+// read it back through TenantFilter / TenantStamp, handlers never pass it around by hand.
+// Workers, push callbacks and the cli see every tenant, but only through an explicit WithoutTenant :
+// a context that carries no tenant at all fails closed, its filters match nothing.
+// The platform tenant is DEFAULT_TENANT and is stored as a missing tenant_id, so records written
+// before tenants existed belong to it.
+
+const DEFAULT_TENANT = "default"
+
+// ErrNoTenant : write through a ctx that carries no tenant and was not made unscoped
+var ErrNoTenant = errors.New("no tenant in context")
+
+type tenantContextKey struct{}
+
+// TenantContextKey : key of the tenant id in a context, fasthttp request values included
+var TenantContextKey = tenantContextKey{}
+
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	if tenantID == "" {
+		tenantID = DEFAULT_TENANT
+	}
+	return context.WithValue(ctx, TenantContextKey, tenantID)
+}
+
+// WithoutTenant : unscoped copy of ctx, for system lookups that have to find a record before its
+// tenant is known (a push names a shop, not a tenant)
+func WithoutTenant(ctx context.Context) context.Context {
+	return context.WithValue(ctx, TenantContextKey, "")
+}
+
+func TenantFromContext(ctx context.Context) (string, bool) {
+	if ctx == nil {
+		return "", false
+	}
+	tenantID, ok := ctx.Value(TenantContextKey).(string)
+	return tenantID, ok && tenantID != ""
+}
+
+// Unscoped : ctx went through WithoutTenant
+func Unscoped(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	tenantID, ok := ctx.Value(TenantContextKey).(string)
+	return ok && tenantID == ""
+}
+
+// PlatformScope : platform tenant or unscoped system caller, the ones allowed to act across tenants
+func PlatformScope(ctx context.Context) bool {
+	tenantID, ok := TenantFromContext(ctx)
+	return (ok && tenantID == DEFAULT_TENANT) || Unscoped(ctx)
+}
+
+// TenantStamp : tenant_id to store for a new record, "" (left out) for the platform tenant and unscoped
+// callers ; like the filter it fails closed, a ctx without tenant gets ErrNoTenant instead of a platform record
+func TenantStamp(ctx context.Context) (string, error) {
+	tenantID, ok := TenantFromContext(ctx)
+	switch {
+	case ok && tenantID == DEFAULT_TENANT:
+		return "", nil
+	case ok:
+		return tenantID, nil
+	case Unscoped(ctx):
+		return "", nil
+	}
+	return "", ErrNoTenant
+}
+
+// TenantFilter : add the tenant of ctx to a filter on a string tenant_id ; an unscoped ctx leaves it
+// as is, a ctx without tenant gets a filter that matches nothing
+func TenantFilter(ctx context.Context, filter bson.M) bson.M {
+	tenantID, ok := TenantFromContext(ctx)
+	switch {
+	case ok && tenantID == DEFAULT_TENANT:
+		filter["tenant_id"] = nil // missing or null
+	case ok:
+		filter["tenant_id"] = tenantID
+	case !Unscoped(ctx):
+		filter["tenant_id"] = bson.M{"$in": bson.A{}}
+	}
+	return filter
+}
@@ -0,0 +1,63 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestTenantFilter(t *testing.T) {
+	cases := []struct {
+		name string
+		ctx  context.Context
+		want bson.M
+	}{
+		{"tenant", WithTenant(context.Background(), "t1"), bson.M{"shop_id": "1", "tenant_id": "t1"}},
+		{"platform", WithTenant(context.Background(), DEFAULT_TENANT), bson.M{"shop_id": "1", "tenant_id": nil}},
+		{"unscoped", WithoutTenant(context.Background()), bson.M{"shop_id": "1"}},
+		// fails closed : a forgotten tenant must not read every tenant
+		{"missing", context.Background(), bson.M{"shop_id": "1", "tenant_id": bson.M{"$in": bson.A{}}}},
+	}
+	for _, c := range cases {
+		if got := TenantFilter(c.ctx, bson.M{"shop_id": "1"}); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: TenantFilter = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestTenantStamp(t *testing.T) {
+	if got, err := TenantStamp(WithTenant(context.Background(), "t1")); got != "t1" || err != nil {
+		t.Errorf("tenant: TenantStamp = %q, %v", got, err)
+	}
+	for name, ctx := range map[string]context.Context{
+		"platform": WithTenant(context.Background(), DEFAULT_TENANT),
+		"unscoped": WithoutTenant(context.Background()),
+	} {
+		if got, err := TenantStamp(ctx); got != "" || err != nil {
+			t.Errorf("%s: TenantStamp = %q, %v, want empty", name, got, err)
+		}
+	}
+	// fails closed : a forgotten tenant must not write platform records
+	if _, err := TenantStamp(context.Background()); !errors.Is(err, ErrNoTenant) {
+		t.Errorf("missing: TenantStamp error = %v, want ErrNoTenant", err)
+	}
+}
+
+func TestPlatformScope(t *testing.T) {
+	for name, c := range map[string]struct {
+		ctx  context.Context
+		want bool
+	}{
+		"platform": {WithTenant(context.Background(), DEFAULT_TENANT), true},
+		"unscoped": {WithoutTenant(context.Background()), true},
+		"tenant":   {WithTenant(context.Background(), "t1"), false},
+		"missing":  {context.Background(), false},
+	} {
+		if got := PlatformScope(c.ctx); got != c.want {
+			t.Errorf("%s: PlatformScope = %v, want %v", name, got, c.want)
+		}
+	}
+}
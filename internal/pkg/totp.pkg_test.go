@@ -0,0 +1,111 @@
+package pkg
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// RFC 6238 appendix B, SHA1 key "12345678901234567890" ; the RFC prints 8 digits, we keep the last 6
+var rfc6238Vectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func rfc6238Secret() string {
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+}
+
+func TestTOTPCodeRFC6238(t *testing.T) {
+	secret := rfc6238Secret()
+	for _, v := range rfc6238Vectors {
+		code, err := TOTPCode(secret, TOTPStep(time.Unix(v.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != v.code {
+			t.Errorf("T=%d: code = %s, want %s", v.unix, code, v.code)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret := rfc6238Secret()
+	at := time.Unix(1111111111, 0)
+	step := TOTPStep(at)
+
+	for _, v := range rfc6238Vectors {
+		if v.unix != 1111111111 {
+			continue
+		}
+		got, ok := ValidateTOTP(secret, v.code, at, 1)
+		if !ok || got != step {
+			t.Fatalf("current step: (%d, %v), want (%d, true)", got, ok, step)
+		}
+		// spaces typed by the user are ignored
+		if _, ok := ValidateTOTP(secret, v.code[:3]+" "+v.code[3:], at, 1); !ok {
+			t.Error("code with a space rejected")
+		}
+	}
+
+	// one step of drift either way, not two
+	prev, _ := TOTPCode(secret, step-1)
+	next, _ := TOTPCode(secret, step+1)
+	far, _ := TOTPCode(secret, step+2)
+	if got, ok := ValidateTOTP(secret, prev, at, 1); !ok || got != step-1 {
+		t.Errorf("previous step: (%d, %v)", got, ok)
+	}
+	if got, ok := ValidateTOTP(secret, next, at, 1); !ok || got != step+1 {
+		t.Errorf("next step: (%d, %v)", got, ok)
+	}
+	if _, ok := ValidateTOTP(secret, far, at, 1); ok {
+		t.Error("code two steps ahead accepted")
+	}
+	if _, ok := ValidateTOTP(secret, prev, at, 0); ok {
+		t.Error("previous step accepted without skew")
+	}
+
+	for _, bad := range []string{"", "12345", "1234567", "abcdef"} {
+		if _, ok := ValidateTOTP(secret, bad, at, 1); ok {
+			t.Errorf("%q accepted", bad)
+		}
+	}
+	if _, ok := ValidateTOTP("not base32 !", "123456", at, 1); ok {
+		t.Error("invalid secret accepted")
+	}
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil || len(key) != 20 {
+		t.Fatalf("secret %q : %d bytes, %v", secret, len(key), err)
+	}
+	other, _ := GenerateTOTPSecret()
+	if other == secret {
+		t.Fatal("two identical secrets")
+	}
+}
+
+func TestTOTPProvisioningURI(t *testing.T) {
+	uri := TOTPProvisioningURI("My Shop", "alice@example.com", "JBSWY3DPEHPK3PXP")
+	for _, part := range []string{
+		"otpauth://totp/My%20Shop:alice@example.com?",
+		"secret=JBSWY3DPEHPK3PXP", "issuer=My+Shop", "digits=6", "period=30", "algorithm=SHA1",
+	} {
+		if !strings.Contains(uri, part) {
+			t.Errorf("%s : missing %s", uri, part)
+		}
+	}
+}